@@ -0,0 +1,175 @@
+// Copyright 2019 Bloomberg Finance LP
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"strconv"
+	"syscall"
+	"time"
+
+	"github.com/bloomberg/k8eraid/pkgs/alerters"
+	q "github.com/bloomberg/k8eraid/pkgs/queries"
+	"github.com/bloomberg/k8eraid/pkgs/secrets"
+)
+
+const defaultMuteSeconds = 3600
+
+// adminToken resolves ADMIN_TOKEN, which holds the bearer token of the admin endpoints or a
+// secret reference to it
+func adminToken() (string, error) {
+	return secrets.Expand(os.Getenv("ADMIN_TOKEN"))
+}
+
+// requireToken only lets requests bearing token in their Authorization header through to handler.
+// The admin endpoints share their listener with /metrics, so without a token they refuse every
+// request rather than trust the network.
+func requireToken(token string, handler http.Handler) http.Handler {
+	want := []byte("Bearer " + token)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if token == "" || subtle.ConstantTimeCompare([]byte(r.Header.Get("Authorization")), want) != 1 {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="k8eraid"`)
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		handler.ServeHTTP(w, r)
+	})
+}
+
+type muteStatus struct {
+	Muted bool       `json:"muted"`
+	Until *time.Time `json:"until,omitempty"`
+}
+
+// muteHandler serves /admin/mute. GET shows whether alert delivery is muted, POST mutes it for
+// the "seconds" query parameter (defaulting to muteSeconds) and DELETE unmutes it.
+func muteHandler(muteSeconds int) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet:
+		case http.MethodPost:
+			seconds := muteSeconds
+			if value := r.URL.Query().Get("seconds"); value != "" {
+				parsed, err := strconv.Atoi(value)
+				if err != nil || parsed <= 0 {
+					http.Error(w, fmt.Sprintf("seconds must be a positive integer, got %q", value), http.StatusBadRequest)
+					return
+				}
+				seconds = parsed
+			}
+			reason := r.URL.Query().Get("reason")
+			if reason == "" {
+				reason = "no reason given"
+			}
+			alerters.MuteAll(time.Duration(seconds)*time.Second, fmt.Sprintf("requested over HTTP by %s: %s", r.RemoteAddr, reason))
+		case http.MethodDelete:
+			alerters.Unmute(fmt.Sprintf("requested over HTTP by %s", r.RemoteAddr))
+		default:
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		status := muteStatus{}
+		if until, muted := alerters.MutedUntil(); muted {
+			status = muteStatus{Muted: true, Until: &until}
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(status)
+	}
+}
+
+// watchMuteSignals mutes alert delivery for muteSeconds on SIGUSR1 and unmutes it on SIGUSR2
+func watchMuteSignals(muteSeconds int) {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGUSR1, syscall.SIGUSR2)
+	for sig := range signals {
+		if sig == syscall.SIGUSR1 {
+			alerters.MuteAll(time.Duration(muteSeconds)*time.Second, "requested by SIGUSR1")
+		} else {
+			alerters.Unmute("requested by SIGUSR2")
+		}
+	}
+}
+
+// activeAlertStatus is an active alert as /admin/ack lists it
+type activeAlertStatus struct {
+	Key            string     `json:"key"`
+	Severity       string     `json:"severity"`
+	FirstSeen      time.Time  `json:"firstSeen"`
+	LastSeen       time.Time  `json:"lastSeen"`
+	Acknowledged   bool       `json:"acknowledged"`
+	AcknowledgedAt *time.Time `json:"acknowledgedAt,omitempty"`
+	AcknowledgedBy string     `json:"acknowledgedBy,omitempty"`
+}
+
+// ackHandler serves /admin/ack. GET lists the active alerts and their acknowledgements, POST
+// acknowledges the alert of the "key" query parameter on behalf of "by", stopping its escalation
+// until it resolves, and DELETE takes the acknowledgement back.
+func ackHandler(w http.ResponseWriter, r *http.Request) {
+	key := r.URL.Query().Get("key")
+	switch r.Method {
+	case http.MethodGet:
+	case http.MethodPost, http.MethodDelete:
+		if key == "" {
+			http.Error(w, "key is required", http.StatusBadRequest)
+			return
+		}
+		who := ""
+		if r.Method == http.MethodPost {
+			if who = r.URL.Query().Get("by"); who == "" {
+				who = r.RemoteAddr
+			}
+		}
+		if !q.AcknowledgeAlert(key, who, time.Now()) {
+			http.Error(w, fmt.Sprintf("no alert %q is active", key), http.StatusNotFound)
+			return
+		}
+		if who != "" {
+			log.Printf("AUDIT: alert %s acknowledged by %s, requested over HTTP by %s", key, who, r.RemoteAddr)
+		} else {
+			log.Printf("AUDIT: acknowledgement of alert %s taken back, requested over HTTP by %s", key, r.RemoteAddr)
+		}
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	statuses := []activeAlertStatus{}
+	for _, alert := range q.ActiveAlerts() {
+		if key != "" && alert.Key != key {
+			continue
+		}
+		status := activeAlertStatus{
+			Key:            alert.Key,
+			Severity:       alert.Severity,
+			FirstSeen:      alert.FirstSeen,
+			LastSeen:       alert.LastSeen,
+			AcknowledgedBy: alert.AcknowledgedBy,
+		}
+		if !alert.AcknowledgedAt.IsZero() {
+			acknowledgedAt := alert.AcknowledgedAt
+			status.Acknowledged, status.AcknowledgedAt = true, &acknowledgedAt
+		}
+		statuses = append(statuses, status)
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(statuses)
+}
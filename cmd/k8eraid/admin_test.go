@@ -0,0 +1,137 @@
+// Copyright 2019 Bloomberg Finance LP
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/bloomberg/k8eraid/pkgs/alerters"
+	q "github.com/bloomberg/k8eraid/pkgs/queries"
+	"github.com/bloomberg/k8eraid/pkgs/types"
+)
+
+func muteRequest(t *testing.T, method string, target string) (int, muteStatus) {
+	recorder := httptest.NewRecorder()
+	muteHandler(defaultMuteSeconds)(recorder, httptest.NewRequest(method, target, nil))
+	var status muteStatus
+	if recorder.Code == http.StatusOK {
+		if err := json.NewDecoder(recorder.Body).Decode(&status); err != nil {
+			t.Fatalf("unable to decode mute status: %s", err.Error())
+		}
+	}
+	return recorder.Code, status
+}
+
+func Test_MuteHandler(t *testing.T) {
+	defer alerters.Unmute("test cleanup")
+
+	if code, status := muteRequest(t, http.MethodGet, "/admin/mute"); code != http.StatusOK || status.Muted {
+		t.Errorf("expected delivery to start unmuted, got %d %+v", code, status)
+	}
+
+	code, status := muteRequest(t, http.MethodPost, "/admin/mute?seconds=600&reason=incident")
+	if code != http.StatusOK || !status.Muted {
+		t.Fatalf("expected delivery to be muted, got %d %+v", code, status)
+	}
+	if status.Until == nil {
+		t.Fatalf("expected the mute to say until when, got %+v", status)
+	}
+	if remaining := time.Until(*status.Until); remaining < 590*time.Second || remaining > 600*time.Second {
+		t.Errorf("expected a 600 second mute, %s remaining", remaining)
+	}
+
+	if code, _ := muteRequest(t, http.MethodPost, "/admin/mute?seconds=soon"); code != http.StatusBadRequest {
+		t.Errorf("expected invalid seconds to be rejected, got %d", code)
+	}
+
+	if code, status := muteRequest(t, http.MethodDelete, "/admin/mute"); code != http.StatusOK || status.Muted || status.Until != nil {
+		t.Errorf("expected delivery to be unmuted with no until, got %d %+v", code, status)
+	}
+}
+
+func ackRequest(t *testing.T, method string, target string) (int, []activeAlertStatus) {
+	recorder := httptest.NewRecorder()
+	ackHandler(recorder, httptest.NewRequest(method, target, nil))
+	var statuses []activeAlertStatus
+	if recorder.Code == http.StatusOK {
+		if err := json.NewDecoder(recorder.Body).Decode(&statuses); err != nil {
+			t.Fatalf("unable to decode active alerts: %s", err.Error())
+		}
+	}
+	return recorder.Code, statuses
+}
+
+func Test_AckHandler(t *testing.T) {
+	const key = "Pod/default/ack-test/PodReady"
+	track := q.TrackActiveAlerts(alerters.AlerterFunc(func(_ string, _ string, _ types.Alert, _ types.AlertersConfig) {}))
+	track.Alert("stderr", "default", types.Alert{Key: key, Severity: types.SeverityCritical, Time: time.Now()}, types.AlertersConfig{})
+	defer q.ResolveActiveAlerts(time.Now().Add(time.Hour))
+
+	if code, _ := ackRequest(t, http.MethodPost, "/admin/ack?key=Pod/default/absent/PodReady&by=oncall"); code != http.StatusNotFound {
+		t.Errorf("expected acknowledging an inactive alert to be rejected, got %d", code)
+	}
+	if code, _ := ackRequest(t, http.MethodPost, "/admin/ack"); code != http.StatusBadRequest {
+		t.Errorf("expected a missing key to be rejected, got %d", code)
+	}
+
+	code, statuses := ackRequest(t, http.MethodPost, "/admin/ack?key="+key+"&by=oncall")
+	if code != http.StatusOK || len(statuses) != 1 || !statuses[0].Acknowledged || statuses[0].AcknowledgedBy != "oncall" {
+		t.Fatalf("expected the alert to be acknowledged by oncall, got %d %+v", code, statuses)
+	}
+
+	code, statuses = ackRequest(t, http.MethodGet, "/admin/ack")
+	found := false
+	for _, status := range statuses {
+		found = found || (status.Key == key && status.Acknowledged)
+	}
+	if code != http.StatusOK || !found {
+		t.Errorf("expected the acknowledged alert to be listed, got %d %+v", code, statuses)
+	}
+
+	code, statuses = ackRequest(t, http.MethodDelete, "/admin/ack?key="+key)
+	if code != http.StatusOK || len(statuses) != 1 || statuses[0].Acknowledged || statuses[0].AcknowledgedAt != nil {
+		t.Errorf("expected the acknowledgement to be taken back, got %d %+v", code, statuses)
+	}
+}
+
+func Test_requireToken(t *testing.T) {
+	ok := http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) { w.WriteHeader(http.StatusOK) })
+	tests := []struct {
+		token         string
+		authorization string
+		want          int
+	}{
+		{token: "s3cret", authorization: "Bearer s3cret", want: http.StatusOK},
+		{token: "s3cret", authorization: "", want: http.StatusUnauthorized},
+		{token: "s3cret", authorization: "Bearer wrong", want: http.StatusUnauthorized},
+		{token: "s3cret", authorization: "s3cret", want: http.StatusUnauthorized},
+		{token: "", authorization: "Bearer ", want: http.StatusUnauthorized},
+	}
+	for _, test := range tests {
+		recorder := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodPost, "/admin/mute", nil)
+		if test.authorization != "" {
+			req.Header.Set("Authorization", test.authorization)
+		}
+		requireToken(test.token, ok).ServeHTTP(recorder, req)
+		if recorder.Code != test.want {
+			t.Errorf("token %q with Authorization %q: expected %d, got %d", test.token, test.authorization, test.want, recorder.Code)
+		}
+	}
+}
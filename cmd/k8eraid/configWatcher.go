@@ -58,9 +58,14 @@ func eventReceived(e watch.Event, config *types.ConfigRules) error {
 		log.Printf("ConfigMap %s changed, updating config", configMapName)
 		if configMap, ok := e.Object.(*corev1.ConfigMap); ok {
 			if configJSON, ok := configMap.Data["config.json"]; ok {
-				if err := json.Unmarshal([]byte(configJSON), config); err != nil {
+				var loaded types.ConfigRules
+				if err := json.Unmarshal([]byte(configJSON), &loaded); err != nil {
 					return fmt.Errorf("unable to parse new config from %s: %s", configMapName, err.Error())
 				}
+				if err := loaded.Validate(); err != nil {
+					return fmt.Errorf("unable to load new config from %s: %s", configMapName, err.Error())
+				}
+				*config = loaded
 				for _, pod := range config.Pods {
 					log.Println("Pod rule found for: ", pod.Name)
 				}
@@ -69,9 +74,69 @@ func eventReceived(e watch.Event, config *types.ConfigRules) error {
 					log.Println("Daemonset rule found for: ", daemonSet.Name)
 				}
 
+				for _, statefulSet := range config.Statefulsets {
+					log.Println("Statefulset rule found for: ", statefulSet.Name)
+				}
+
+				for _, replicaSet := range config.ReplicaSets {
+					log.Println("ReplicaSet rule found for: ", replicaSet.Name)
+				}
+
+				for _, job := range config.Jobs {
+					log.Println("Job rule found for: ", job.Name)
+				}
+
+				for _, cronJob := range config.CronJobs {
+					log.Println("CronJob rule found for: ", cronJob.Name)
+				}
+
 				for _, node := range config.Nodes {
 					log.Println("Node rule found for: ", node.Name)
 				}
+
+				for _, pvc := range config.PVCs {
+					log.Println("PersistentVolumeClaim rule found for: ", pvc.Name)
+				}
+
+				for _, pv := range config.PVs {
+					log.Println("PersistentVolume rule found for: ", pv.Name)
+				}
+
+				for _, storageClass := range config.StorageClasses {
+					log.Println("StorageClass rule found for: ", storageClass.Name)
+				}
+
+				for _, namespace := range config.Namespaces {
+					log.Println("Namespace rule found for: ", namespace.Name)
+				}
+
+				for _, quota := range config.ResourceQuotas {
+					log.Println("ResourceQuota rule found for: ", quota.Name)
+				}
+
+				for _, service := range config.Services {
+					log.Println("Service rule found for: ", service.Name)
+				}
+
+				for _, ingress := range config.Ingresses {
+					log.Println("Ingress rule found for: ", ingress.Name)
+				}
+
+				for _, hpa := range config.HPAs {
+					log.Println("HorizontalPodAutoscaler rule found for: ", hpa.Name)
+				}
+
+				for _, pdb := range config.PDBs {
+					log.Println("PodDisruptionBudget rule found for: ", pdb.Name)
+				}
+
+				for _, event := range config.Events {
+					log.Println("Event rule found for: ", event.Name)
+				}
+
+				for _, configObject := range config.ConfigObjects {
+					log.Println(configObject.Kind+" rule found for: ", configObject.Name)
+				}
 			} else {
 				return fmt.Errorf("ConfigMap %s missing config.json key", configMapName)
 			}
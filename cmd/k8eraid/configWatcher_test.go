@@ -270,6 +270,18 @@ func Test_EventReceived_err(t *testing.T) {
 			errString: "unable to parse",
 			eventType: watch.Added,
 		},
+		{
+			name: "conflicting rules",
+			configMap: &corev1.ConfigMap{
+				ObjectMeta: metav1.ObjectMeta{Name: "k8eraid-config"},
+				Data: map[string]string{"config.json": `{"nodes": [
+					{"name": "*", "alerterType": "slack", "alerterName": "oncall"},
+					{"name": "*", "alerterType": "stderr"}
+				]}`},
+			},
+			errString: `nodes[0] references undefined slack alerter "oncall"; nodes[1] duplicates nodes[0] (name "*", filter "")`,
+			eventType: watch.Added,
+		},
 		{
 			name: "missing config",
 			configMap: &corev1.ConfigMap{
@@ -0,0 +1,179 @@
+// Copyright 2019 Bloomberg Finance LP
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/bloomberg/k8eraid/pkgs/types"
+)
+
+const defaultPlanFormat = "json"
+
+// planFormatter writes a plan for one kind of consumer
+type planFormatter interface {
+	Format(w io.Writer, p plan) error
+}
+
+var planFormatters = map[string]planFormatter{
+	"json":  jsonFormatter{},
+	"table": tableFormatter{},
+	"junit": junitFormatter{},
+}
+
+// newPlanFormatter returns the formatter called name
+func newPlanFormatter(name string) (planFormatter, error) {
+	formatter, ok := planFormatters[name]
+	if !ok {
+		names := make([]string, 0, len(planFormatters))
+		for known := range planFormatters {
+			names = append(names, known)
+		}
+		sort.Strings(names)
+		return nil, fmt.Errorf("unknown format %q, expected one of %s", name, strings.Join(names, ", "))
+	}
+	return formatter, nil
+}
+
+// jsonFormatter writes the plan as indented JSON, for tooling
+type jsonFormatter struct{}
+
+func (jsonFormatter) Format(w io.Writer, p plan) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(p)
+}
+
+// tableFormatter writes a row per alert of either evaluation, for people
+type tableFormatter struct{}
+
+func (tableFormatter) Format(w io.Writer, p plan) error {
+	table := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(table, "STATUS\tSEVERITY\tKEY\tMESSAGE")
+	row := func(status string, alert types.Alert) {
+		fmt.Fprintf(table, "%s\t%s\t%s\t%s\n", status, alert.Severity, alert.Key, oneLine(alert.Message))
+	}
+	for _, alert := range p.Added {
+		row("added", alert)
+	}
+	for _, change := range p.Changed {
+		row("changed", change.After)
+	}
+	for _, alert := range p.Removed {
+		row("removed", alert)
+	}
+	unchanged := map[string]bool{}
+	for _, key := range p.Unchanged {
+		unchanged[key] = true
+	}
+	for _, alert := range p.candidate {
+		if unchanged[alert.Key] {
+			delete(unchanged, alert.Key)
+			row("unchanged", alert)
+		}
+	}
+	if err := table.Flush(); err != nil {
+		return err
+	}
+	_, err := fmt.Fprintf(w, "\n%d added, %d changed, %d removed, %d unchanged\n", len(p.Added), len(p.Changed), len(p.Removed), len(p.Unchanged))
+	return err
+}
+
+// oneLine keeps a multi-line alert message on its table row
+func oneLine(message string) string {
+	return strings.Join(strings.Fields(message), " ")
+}
+
+type junitTestSuites struct {
+	XMLName xml.Name         `xml:"testsuites"`
+	Suites  []junitTestSuite `xml:"testsuite"`
+}
+
+type junitTestSuite struct {
+	Name      string          `xml:"name,attr"`
+	Tests     int             `xml:"tests,attr"`
+	Failures  int             `xml:"failures,attr"`
+	Timestamp string          `xml:"timestamp,attr"`
+	TestCases []junitTestCase `xml:"testcase"`
+}
+
+type junitTestCase struct {
+	Name      string        `xml:"name,attr"`
+	ClassName string        `xml:"classname,attr"`
+	Failure   *junitFailure `xml:"failure,omitempty"`
+}
+
+type junitFailure struct {
+	Message string `xml:"message,attr"`
+	Type    string `xml:"type,attr"`
+	Text    string `xml:",chardata"`
+}
+
+// junitFormatter writes JUnit XML, so CI systems show findings as test results. Every alert the
+// candidate config raises is a failed test case, and every alert it no longer raises a passed one.
+type junitFormatter struct{}
+
+func (junitFormatter) Format(w io.Writer, p plan) error {
+	status := map[string]string{}
+	for _, alert := range p.Added {
+		status[alert.Key] = "added"
+	}
+	for _, change := range p.Changed {
+		status[change.Key] = "changed"
+	}
+	suite := junitTestSuite{Name: "k8eraid", Timestamp: p.CandidateTime.UTC().Format(time.RFC3339), TestCases: []junitTestCase{}}
+	for _, alert := range p.candidate {
+		if _, ok := status[alert.Key]; !ok {
+			status[alert.Key] = "unchanged"
+		}
+		suite.TestCases = append(suite.TestCases, junitTestCase{
+			Name:      alert.Key,
+			ClassName: junitClassName(alert.Key),
+			Failure: &junitFailure{
+				Message: oneLine(alert.Message),
+				Type:    alert.Severity,
+				Text:    fmt.Sprintf("%s\nseverity: %s\nresource: %s\nstatus: %s", alert.Message, alert.Severity, alert.Resource, status[alert.Key]),
+			},
+		})
+		suite.Failures++
+	}
+	for _, alert := range p.Removed {
+		suite.TestCases = append(suite.TestCases, junitTestCase{Name: alert.Key, ClassName: junitClassName(alert.Key)})
+	}
+	suite.Tests = len(suite.TestCases)
+
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	encoder := xml.NewEncoder(w)
+	encoder.Indent("", "  ")
+	if err := encoder.Encode(junitTestSuites{Suites: []junitTestSuite{suite}}); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
+
+// junitClassName groups test cases by the kind of object alerted on, the first part of the key
+func junitClassName(key string) string {
+	return "k8eraid." + strings.SplitN(key, "/", 2)[0]
+}
@@ -0,0 +1,104 @@
+// Copyright 2019 Bloomberg Finance LP
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/bloomberg/k8eraid/pkgs/types"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func formatPlan() plan {
+	baseline := evaluation{Alerts: []types.Alert{
+		planAlert("node/a/Ready", types.SeverityCritical, "a is not ready"),
+		planAlert("node/b/Ready", types.SeverityCritical, "b is not ready"),
+		planAlert("pod/ns/c/Ready", types.SeverityWarning, "c restarted 1 times"),
+	}}
+	candidate := evaluation{Time: time.Date(2019, 6, 1, 12, 0, 0, 0, time.UTC), Alerts: []types.Alert{
+		planAlert("pod/ns/c/Ready", types.SeverityWarning, "c restarted\n2 times"),
+		planAlert("node/a/Ready", types.SeverityCritical, "a is not ready"),
+		planAlert("node/d/Ready", types.SeverityCritical, "d is not ready"),
+	}}
+	return diffEvaluations(baseline, candidate)
+}
+
+func Test_newPlanFormatter(t *testing.T) {
+	for _, name := range []string{"json", "table", "junit"} {
+		_, err := newPlanFormatter(name)
+		assert.NoError(t, err, name)
+	}
+	_, err := newPlanFormatter("yaml")
+	assert.EqualError(t, err, `unknown format "yaml", expected one of json, junit, table`)
+}
+
+func Test_jsonFormatter(t *testing.T) {
+	var buf bytes.Buffer
+	require.NoError(t, jsonFormatter{}.Format(&buf, formatPlan()))
+	var p plan
+	require.NoError(t, json.Unmarshal(buf.Bytes(), &p))
+	assert.Equal(t, formatPlan().Added, p.Added)
+	assert.Equal(t, []string{"node/a/Ready"}, p.Unchanged)
+}
+
+func Test_tableFormatter(t *testing.T) {
+	var buf bytes.Buffer
+	require.NoError(t, tableFormatter{}.Format(&buf, formatPlan()))
+	assert.Equal(t, strings.Join([]string{
+		"STATUS     SEVERITY  KEY             MESSAGE",
+		"added      critical  node/d/Ready    d is not ready",
+		"changed    warning   pod/ns/c/Ready  c restarted 2 times",
+		"removed    critical  node/b/Ready    b is not ready",
+		"unchanged  critical  node/a/Ready    a is not ready",
+		"",
+		"1 added, 1 changed, 1 removed, 1 unchanged",
+		"",
+	}, "\n"), buf.String())
+}
+
+func Test_junitFormatter(t *testing.T) {
+	var buf bytes.Buffer
+	require.NoError(t, junitFormatter{}.Format(&buf, formatPlan()))
+	assert.True(t, strings.HasPrefix(buf.String(), xml.Header))
+
+	var suites junitTestSuites
+	require.NoError(t, xml.Unmarshal(buf.Bytes(), &suites))
+	require.Equal(t, 1, len(suites.Suites))
+	suite := suites.Suites[0]
+	assert.Equal(t, "k8eraid", suite.Name)
+	assert.Equal(t, "2019-06-01T12:00:00Z", suite.Timestamp)
+	assert.Equal(t, 4, suite.Tests)
+	assert.Equal(t, 3, suite.Failures)
+
+	cases := map[string]junitTestCase{}
+	for _, testCase := range suite.TestCases {
+		cases[testCase.Name] = testCase
+	}
+	require.NotNil(t, cases["pod/ns/c/Ready"].Failure, "every alert the candidate raises should fail")
+	assert.Equal(t, "k8eraid.pod", cases["pod/ns/c/Ready"].ClassName)
+	assert.Equal(t, "c restarted 2 times", cases["pod/ns/c/Ready"].Failure.Message)
+	assert.Equal(t, types.SeverityWarning, cases["pod/ns/c/Ready"].Failure.Type)
+	assert.Contains(t, cases["pod/ns/c/Ready"].Failure.Text, "status: changed")
+	assert.Contains(t, cases["node/d/Ready"].Failure.Text, "status: added")
+	assert.Contains(t, cases["node/a/Ready"].Failure.Text, "status: unchanged")
+	assert.Nil(t, cases["node/b/Ready"].Failure, "alerts the candidate no longer raises should pass")
+}
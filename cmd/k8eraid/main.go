@@ -15,15 +15,22 @@
 package main
 
 import (
+	"flag"
 	"log"
+	"net/http"
 	"os"
+	"os/signal"
 	"strconv"
+	"strings"
+	"syscall"
 	"time"
 
 	"github.com/bloomberg/k8eraid/pkgs/alerters"
+	"github.com/bloomberg/k8eraid/pkgs/metrics"
 	q "github.com/bloomberg/k8eraid/pkgs/queries"
 	"github.com/bloomberg/k8eraid/pkgs/types"
 
+	"k8s.io/client-go/dynamic"
 	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/rest"
 )
@@ -31,23 +38,70 @@ import (
 const (
 	maxConfigWacherRetries     = 5
 	configWatcherRetryInterval = time.Second
+	defaultMaxWorkers          = 4
+	defaultWorkerQueueSize     = 64
+	defaultListenAddress       = ":8080"
+	defaultAlertResolveTicks   = 2
+	defaultStateExpireTicks    = 10
+	defaultStateSaveSeconds    = 60
+	defaultStateFlushSeconds   = 5
+	defaultAlertQueueSize      = 1000
 )
 
 var (
 	configMapName string
 	config        *types.ConfigRules
 	tickertimeint int64
+	// clusterName identifies the cluster polled in alerts and their routing
+	clusterName string
 )
 
-func kubeClient() (*kubernetes.Clientset, error) {
+// envInt reads an integer from the environment, falling back to def when unset
+func envInt(name string, def int) int {
+	value := os.Getenv(name)
+	if value == "" {
+		return def
+	}
+	parsed, err := strconv.Atoi(value)
+	if err != nil {
+		log.Panicf("%s=%s cannot be converted to int: %s", name, value, err.Error())
+	}
+	return parsed
+}
+
+// kubeClient returns the typed client, and the dynamic client field conditions read objects of any
+// resource with
+func kubeClient() (*kubernetes.Clientset, dynamic.Interface, error) {
 	config, configerr := rest.InClusterConfig()
 	if configerr != nil {
-		return nil, configerr
+		return nil, nil, configerr
 	}
-	return kubernetes.NewForConfig(config)
+	// time every apiserver call for the apiserverLatency check
+	config.WrapTransport = q.InstrumentTransport
+	clientset, err := kubernetes.NewForConfig(config)
+	if err != nil {
+		return nil, nil, err
+	}
+	dynamicClient, err := dynamic.NewForConfig(config)
+	if err != nil {
+		return nil, nil, err
+	}
+	return clientset, dynamicClient, nil
 }
 
 func main() {
+	renderOnly := flag.Bool("render-alerts", false, "print the text of every check's alert against synthetic objects and exit")
+	renderConfig := flag.String("config", "", "config.json whose alerter templates -render-alerts also renders")
+	planConfig := flag.String("plan", "", "evaluate this candidate config.json (- for stdin) once without delivering alerts, print how its alerts differ from the running config's last evaluation and exit")
+	planBaseline := flag.String("baseline", "", "URL or file of the evaluation -plan compares against, or none to report every alert as added, defaults to /admin/evaluation on LISTEN_ADDRESS")
+	planFormat := flag.String("format", defaultPlanFormat, "output format of -plan: json, table or junit")
+	flag.Parse()
+	if *renderOnly {
+		if err := renderAlerts(os.Stdout, *renderConfig); err != nil {
+			log.Fatalf("Unable to render alerts: %s", err.Error())
+		}
+		return
+	}
 
 	if tickertime := os.Getenv("POLL_PERIOD"); tickertime == "" {
 		tickertimeint = 30
@@ -64,12 +118,72 @@ func main() {
 		configMapName = "k8eraid-config"
 	}
 
-	var clientset *kubernetes.Clientset
-	var err error
-	if clientset, err = kubeClient(); err != nil {
+	clusterName = os.Getenv("CLUSTER_NAME")
+
+	listenAddress := os.Getenv("LISTEN_ADDRESS")
+	if listenAddress == "" {
+		listenAddress = defaultListenAddress
+	}
+
+	token, err := adminToken()
+	if err != nil {
+		log.Panicf("Unable to resolve ADMIN_TOKEN: %s", err.Error())
+	}
+
+	clientset, dynamicClient, err := kubeClient()
+	if err != nil {
 		log.Panicf("Unable to create kubernetes client: %s", err.Error())
 	}
 
+	if *planConfig != "" {
+		formatter, err := newPlanFormatter(*planFormat)
+		if err != nil {
+			log.Fatalf("Unable to plan %s: %s", *planConfig, err.Error())
+		}
+		baseline := *planBaseline
+		if baseline == "" {
+			baseline = evaluationURL(listenAddress)
+		}
+		changed, err := runPlan(os.Stdout, formatter, clientset, dynamicClient, *planConfig, baseline, token)
+		if err != nil {
+			log.Fatalf("Unable to plan %s: %s", *planConfig, err.Error())
+		}
+		// like diff, exit 2 so pipelines can gate on whether alerts would change
+		if changed {
+			os.Exit(2)
+		}
+		return
+	}
+
+	// serve metrics for scraping and the admin endpoints
+	muteSeconds := envInt("MUTE_DURATION", defaultMuteSeconds)
+	if token == "" {
+		log.Print("ADMIN_TOKEN is not set, the admin endpoints refuse every request")
+	}
+	go func() {
+		mux := http.NewServeMux()
+		mux.Handle("/metrics", metrics.Handler())
+		mux.Handle("/admin/mute", requireToken(token, muteHandler(muteSeconds)))
+		mux.Handle("/admin/evaluation", requireToken(token, http.HandlerFunc(evaluationHandler)))
+		mux.Handle("/admin/ack", requireToken(token, http.HandlerFunc(ackHandler)))
+		if err := http.ListenAndServe(listenAddress, mux); err != nil {
+			log.Printf("HTTP server stopped: %s", err.Error())
+		}
+	}()
+	go watchMuteSignals(muteSeconds)
+
+	// carry what previous ticks observed over restarts, so a redeploy does not page again for
+	// conditions that were already alerted on
+	statePath := os.Getenv("STATE_FILE")
+	if statePath != "" {
+		if err := q.LoadState(statePath, time.Now()); err != nil {
+			log.Printf("Unable to restore state, starting without it: %s", err.Error())
+		}
+		go saveStateEvery(statePath, time.Duration(envInt("STATE_SAVE_INTERVAL", defaultStateSaveSeconds))*time.Second)
+	}
+	go flushStateEvery(time.Duration(envInt("STATE_FLUSH_INTERVAL", defaultStateFlushSeconds)) * time.Second)
+	go watchShutdownSignals(statePath)
+
 	// start a watch on the configmap for our config
 	go func() {
 		numRetries := maxConfigWacherRetries
@@ -95,61 +209,505 @@ func main() {
 		}
 	}
 
+	// Polls run on a bounded pool so large configs cannot spawn unbounded goroutines
+	pool := newWorkerPool(
+		envInt("MAX_WORKERS", defaultMaxWorkers),
+		envInt("WORKER_QUEUE_SIZE", defaultWorkerQueueSize),
+		os.Getenv("WORKER_OVERFLOW"),
+	)
+	defer pool.stop()
+
+	// an active alert resolves once it has not been raised again for this long
+	resolveAfter := time.Duration(int64(envInt("ALERT_RESOLVE_TICKS", defaultAlertResolveTicks))*tickertimeint) * time.Second
+	// the state of an object is forgotten once no poll has written it for this long, e.g. once it is deleted
+	expireAfter := time.Duration(int64(envInt("STATE_EXPIRE_TICKS", defaultStateExpireTicks))*tickertimeint) * time.Second
+
+	// during an incident a throttled delivery sends the critical alerts ahead of the rest
+	if perMinute := envInt("ALERT_RATE_LIMIT", 0); perMinute > 0 {
+		alerters.LimitDelivery(perMinute, envInt("ALERT_QUEUE_SIZE", defaultAlertQueueSize))
+	}
+
 	// Main logic routine, this will query the Kubernetes api for the intended resources periodically
 	timeTicker := time.NewTicker(time.Duration(tickertimeint) * time.Second)
-	for range timeTicker.C {
-		pollLoop(clientset)
+	alerter := alerters.Chain(alerters.DefaultAlerter, q.TrackActiveAlerts, q.SkipRestoredAlerts)
+	for now := range timeTicker.C {
+		q.ResolveActiveAlerts(now.Add(-resolveAfter))
+		q.ExpireState(now.Add(-expireAfter))
+		recorder := newEvaluationRecorder(now, setLastEvaluation)
+		jobs := recorder.track(pollJobs(clientset, dynamicClient, config, alerters.Chain(alerter, recorder.record)))
+		// a skipped tick keeps the previous evaluation
+		recorder.submitted(pool.submitTick(jobs))
 	}
 }
 
-func pollLoop(clientset kubernetes.Interface) {
-	// Iterate through Deployment rules
-	for _, deployment := range config.Deployments {
+// watchShutdownSignals writes out buffered alerts, and the state to statePath unless it is empty,
+// before exiting on SIGTERM or SIGINT
+func watchShutdownSignals(statePath string) {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGTERM, syscall.SIGINT)
+	sig := <-signals
+	log.Printf("Received %s, flushing alerters and exiting", sig)
+	alerters.FlushCSV()
+	if statePath != "" {
+		if err := q.SaveState(statePath, time.Now()); err != nil {
+			log.Printf("Unable to save state: %s", err.Error())
+		}
+	}
+	os.Exit(0)
+}
 
-		if err := q.PollDeployment(
-			clientset,
-			deployment,
-			tickertimeint,
-			alerters.Alert,
-			config.AlertersConfig,
-		); err != nil {
-			log.Printf("Error polling Deployments: %s", err.Error())
+// saveStateEvery saves the state to statePath on an interval, so a crash only loses what was
+// observed since the last save
+func saveStateEvery(statePath string, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for now := range ticker.C {
+		if err := q.SaveState(statePath, now); err != nil {
+			log.Printf("Unable to save state: %s", err.Error())
 		}
 	}
+}
+
+// flushStateEvery records the samples buffered on their way to the state on an interval, so they
+// are not held back while the buffers fill up on a quiet cluster
+func flushStateEvery(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for range ticker.C {
+		q.FlushState()
+	}
+}
+
+// evaluationURL is where the running k8eraid serves its last evaluation, from inside its pod
+func evaluationURL(listenAddress string) string {
+	if strings.HasPrefix(listenAddress, ":") {
+		listenAddress = "localhost" + listenAddress
+	}
+	return "http://" + listenAddress + "/admin/evaluation"
+}
+
+// pollJobs builds one job per rule of the config for the current tick, delivering alerts through alerter.
+// Rules of the kinds the config's check toggles switch off get no job.
+func pollJobs(clientset kubernetes.Interface, dynamicClient dynamic.Interface, rules *types.ConfigRules, alerter alerters.Alerter) []func() {
+	var jobs []func()
+	config := rules.EnabledRules()
+	alertersConfig := config.AlertersConfig
+	// silencing and ownership look up each object alerted on once per tick
+	objects := q.NewObjectCache(clientset)
+	silence := q.SilenceAnnotated(objects, config.Silencing)
+	severities, owners := q.OverrideSeverities(config.Severities), q.AnnotateOwners(objects, config.Ownership)
+	cluster := q.RouteByCluster(clusterName, config.ClusterAlerters)
+	// ruleAlert delivers the alerts of one rule, re-routing them up the rule's escalation chain, then
+	// to the alerters the cluster overrides
+	ruleAlert := func(escalation []types.EscalationLevel) func(string, string, types.Alert, types.AlertersConfig) {
+		return alerters.Chain(alerter, silence, severities, owners, q.Escalate(escalation), cluster).Alert
+	}
+
+	// Iterate through Deployment rules
+	for _, deployment := range config.Deployments {
+		deployment := deployment
+		jobs = append(jobs, func() {
+			if err := q.PollDeployment(
+				clientset,
+				deployment,
+				tickertimeint,
+				ruleAlert(deployment.Escalation),
+				alertersConfig,
+			); err != nil {
+				log.Printf("Error polling Deployments: %s", err.Error())
+			}
+		})
+	}
 	// Iterate through Pod rules
 	for _, pod := range config.Pods {
-		if err := q.PollPod(
-			clientset,
-			pod,
-			tickertimeint,
-			alerters.Alert,
-			config.AlertersConfig,
-		); err != nil {
-			log.Printf("Error polling pods: %s", err.Error())
-		}
+		pod := pod
+		jobs = append(jobs, func() {
+			if err := q.PollPod(
+				clientset,
+				pod,
+				tickertimeint,
+				ruleAlert(pod.Escalation),
+				alertersConfig,
+			); err != nil {
+				log.Printf("Error polling pods: %s", err.Error())
+			}
+		})
 	}
 	// Iterate through Daemonset rules
 	for _, daemonset := range config.Daemonsets {
-		if err := q.PollDaemonset(
-			clientset,
-			daemonset,
-			tickertimeint,
-			alerters.Alert,
-			config.AlertersConfig,
-		); err != nil {
-			log.Printf("Error polling DaemonSets: %s", err.Error())
-		}
+		daemonset := daemonset
+		jobs = append(jobs, func() {
+			if err := q.PollDaemonset(
+				clientset,
+				daemonset,
+				tickertimeint,
+				ruleAlert(daemonset.Escalation),
+				alertersConfig,
+			); err != nil {
+				log.Printf("Error polling DaemonSets: %s", err.Error())
+			}
+		})
+	}
+	// Iterate through ReplicaSet rules
+	for _, replicaSet := range config.ReplicaSets {
+		replicaSet := replicaSet
+		jobs = append(jobs, func() {
+			if err := q.PollReplicaSet(
+				clientset,
+				replicaSet,
+				tickertimeint,
+				ruleAlert(replicaSet.Escalation),
+				alertersConfig,
+			); err != nil {
+				log.Printf("Error polling ReplicaSets: %s", err.Error())
+			}
+		})
+	}
+	// Iterate through Statefulset rules
+	for _, statefulset := range config.Statefulsets {
+		statefulset := statefulset
+		jobs = append(jobs, func() {
+			if err := q.PollStatefulset(
+				clientset,
+				statefulset,
+				tickertimeint,
+				ruleAlert(statefulset.Escalation),
+				alertersConfig,
+			); err != nil {
+				log.Printf("Error polling StatefulSets: %s", err.Error())
+			}
+		})
+	}
+	// Iterate through Job rules
+	for _, job := range config.Jobs {
+		job := job
+		jobs = append(jobs, func() {
+			if err := q.PollJob(
+				clientset,
+				job,
+				tickertimeint,
+				ruleAlert(job.Escalation),
+				alertersConfig,
+			); err != nil {
+				log.Printf("Error polling Jobs: %s", err.Error())
+			}
+		})
+	}
+	// Iterate through CronJob rules
+	for _, cronJob := range config.CronJobs {
+		cronJob := cronJob
+		jobs = append(jobs, func() {
+			if err := q.PollCronJob(
+				clientset,
+				cronJob,
+				tickertimeint,
+				ruleAlert(cronJob.Escalation),
+				alertersConfig,
+			); err != nil {
+				log.Printf("Error polling CronJobs: %s", err.Error())
+			}
+		})
 	}
 	// Iterate through Node rules
 	for _, node := range config.Nodes {
-		if err := q.PollNode(
-			clientset,
-			node,
-			tickertimeint,
-			alerters.Alert,
-			config.AlertersConfig,
-		); err != nil {
-			log.Printf("Error polling nodes: %s", err.Error())
-		}
+		node := node
+		jobs = append(jobs, func() {
+			if err := q.PollNode(
+				clientset,
+				node,
+				tickertimeint,
+				ruleAlert(node.Escalation),
+				alertersConfig,
+			); err != nil {
+				log.Printf("Error polling nodes: %s", err.Error())
+			}
+		})
+	}
+	// Iterate through PersistentVolumeClaim rules
+	for _, pvc := range config.PVCs {
+		pvc := pvc
+		jobs = append(jobs, func() {
+			if err := q.PollPVC(
+				clientset,
+				pvc,
+				tickertimeint,
+				ruleAlert(pvc.Escalation),
+				alertersConfig,
+			); err != nil {
+				log.Printf("Error polling PersistentVolumeClaims: %s", err.Error())
+			}
+		})
+	}
+	// Iterate through PersistentVolume rules
+	for _, pv := range config.PVs {
+		pv := pv
+		jobs = append(jobs, func() {
+			if err := q.PollPV(
+				clientset,
+				pv,
+				tickertimeint,
+				ruleAlert(pv.Escalation),
+				alertersConfig,
+			); err != nil {
+				log.Printf("Error polling PersistentVolumes: %s", err.Error())
+			}
+		})
+	}
+	// Iterate through StorageClass rules
+	for _, storageClass := range config.StorageClasses {
+		storageClass := storageClass
+		jobs = append(jobs, func() {
+			if err := q.PollStorageClass(
+				clientset,
+				storageClass,
+				tickertimeint,
+				ruleAlert(storageClass.Escalation),
+				alertersConfig,
+			); err != nil {
+				log.Printf("Error polling StorageClasses: %s", err.Error())
+			}
+		})
+	}
+	// Iterate through Namespace rules
+	for _, namespace := range config.Namespaces {
+		namespace := namespace
+		jobs = append(jobs, func() {
+			if err := q.PollNamespace(
+				clientset,
+				namespace,
+				tickertimeint,
+				ruleAlert(namespace.Escalation),
+				alertersConfig,
+			); err != nil {
+				log.Printf("Error polling Namespaces: %s", err.Error())
+			}
+		})
+	}
+	// Iterate through ResourceQuota rules
+	for _, quota := range config.ResourceQuotas {
+		quota := quota
+		jobs = append(jobs, func() {
+			if err := q.PollResourceQuota(
+				clientset,
+				quota,
+				tickertimeint,
+				ruleAlert(quota.Escalation),
+				alertersConfig,
+			); err != nil {
+				log.Printf("Error polling ResourceQuotas: %s", err.Error())
+			}
+		})
+	}
+	// Iterate through Service rules
+	for _, service := range config.Services {
+		service := service
+		jobs = append(jobs, func() {
+			if err := q.PollService(
+				clientset,
+				service,
+				tickertimeint,
+				ruleAlert(service.Escalation),
+				alertersConfig,
+			); err != nil {
+				log.Printf("Error polling Services: %s", err.Error())
+			}
+		})
+	}
+	// Iterate through Ingress rules
+	for _, ingress := range config.Ingresses {
+		ingress := ingress
+		jobs = append(jobs, func() {
+			if err := q.PollIngress(
+				clientset,
+				ingress,
+				tickertimeint,
+				ruleAlert(ingress.Escalation),
+				alertersConfig,
+			); err != nil {
+				log.Printf("Error polling Ingresses: %s", err.Error())
+			}
+		})
+	}
+	// Iterate through HorizontalPodAutoscaler rules
+	for _, hpa := range config.HPAs {
+		hpa := hpa
+		jobs = append(jobs, func() {
+			if err := q.PollHPA(
+				clientset,
+				hpa,
+				tickertimeint,
+				ruleAlert(hpa.Escalation),
+				alertersConfig,
+			); err != nil {
+				log.Printf("Error polling HorizontalPodAutoscalers: %s", err.Error())
+			}
+		})
+	}
+	// Iterate through admission webhook rules
+	for _, webhook := range config.Webhooks {
+		webhook := webhook
+		jobs = append(jobs, func() {
+			if err := q.PollWebhookConfig(
+				clientset,
+				webhook,
+				tickertimeint,
+				ruleAlert(webhook.Escalation),
+				alertersConfig,
+			); err != nil {
+				log.Printf("Error polling webhook configurations: %s", err.Error())
+			}
+		})
+	}
+	// Iterate through unused resource rules
+	for _, unused := range config.Unused {
+		unused := unused
+		jobs = append(jobs, func() {
+			if err := q.PollUnused(
+				clientset,
+				unused,
+				tickertimeint,
+				ruleAlert(unused.Escalation),
+				alertersConfig,
+			); err != nil {
+				log.Printf("Error polling unused resources: %s", err.Error())
+			}
+		})
+	}
+	// Iterate through workload resource rules
+	for _, workloadResources := range config.WorkloadResources {
+		workloadResources := workloadResources
+		jobs = append(jobs, func() {
+			if err := q.PollWorkloadResources(
+				clientset,
+				workloadResources,
+				tickertimeint,
+				ruleAlert(workloadResources.Escalation),
+				alertersConfig,
+			); err != nil {
+				log.Printf("Error polling workload resources: %s", err.Error())
+			}
+		})
+	}
+	// Iterate through deprecated API rules
+	for _, deprecatedAPI := range config.DeprecatedAPIs {
+		deprecatedAPI := deprecatedAPI
+		jobs = append(jobs, func() {
+			if err := q.PollDeprecatedAPI(
+				clientset,
+				dynamicClient,
+				deprecatedAPI,
+				tickertimeint,
+				ruleAlert(deprecatedAPI.Escalation),
+				alertersConfig,
+			); err != nil {
+				log.Printf("Error polling deprecated APIs: %s", err.Error())
+			}
+		})
+	}
+	// Iterate through field condition rules
+	for _, fieldCondition := range config.FieldConditions {
+		fieldCondition := fieldCondition
+		jobs = append(jobs, func() {
+			if err := q.PollFieldCondition(
+				dynamicClient,
+				fieldCondition,
+				tickertimeint,
+				ruleAlert(fieldCondition.Escalation),
+				alertersConfig,
+			); err != nil {
+				log.Printf("Error polling field conditions: %s", err.Error())
+			}
+		})
+	}
+	// Iterate through CertificateSigningRequest rules
+	for _, csr := range config.CSRs {
+		csr := csr
+		jobs = append(jobs, func() {
+			if err := q.PollCSR(
+				dynamicClient,
+				csr,
+				tickertimeint,
+				ruleAlert(csr.Escalation),
+				alertersConfig,
+			); err != nil {
+				log.Printf("Error polling CertificateSigningRequests: %s", err.Error())
+			}
+		})
+	}
+	// Iterate through PodDisruptionBudget rules
+	for _, pdb := range config.PDBs {
+		pdb := pdb
+		jobs = append(jobs, func() {
+			if err := q.PollPDB(
+				clientset,
+				pdb,
+				tickertimeint,
+				ruleAlert(pdb.Escalation),
+				alertersConfig,
+			); err != nil {
+				log.Printf("Error polling PodDisruptionBudgets: %s", err.Error())
+			}
+		})
+	}
+	// Iterate through Event rules
+	for _, event := range config.Events {
+		event := event
+		jobs = append(jobs, func() {
+			if err := q.PollEvent(
+				clientset,
+				event,
+				tickertimeint,
+				ruleAlert(event.Escalation),
+				alertersConfig,
+			); err != nil {
+				log.Printf("Error polling Events: %s", err.Error())
+			}
+		})
+	}
+	// Iterate through ConfigMap and Secret rules
+	for _, configObject := range config.ConfigObjects {
+		configObject := configObject
+		jobs = append(jobs, func() {
+			if err := q.PollConfigObject(
+				clientset,
+				configObject,
+				tickertimeint,
+				ruleAlert(configObject.Escalation),
+				alertersConfig,
+			); err != nil {
+				log.Printf("Error polling %ss: %s", configObject.Kind, err.Error())
+			}
+		})
+	}
+	// Check the latency of the apiserver calls made so far
+	if config.APILatency.ThresholdSeconds > 0 {
+		apiLatency := config.APILatency
+		jobs = append(jobs, func() {
+			q.CheckAPILatency(apiLatency, time.Now(), ruleAlert(apiLatency.Escalation), alertersConfig)
+		})
+	}
+	// Check the health endpoints of the apiserver
+	if len(config.APIHealth.Endpoints) > 0 {
+		apiHealth := config.APIHealth
+		jobs = append(jobs, func() {
+			q.CheckAPIHealth(clientset, apiHealth, ruleAlert(apiHealth.Escalation), alertersConfig)
+		})
+	}
+	// Check the health of the scheduler and controller-manager
+	if len(config.ControlPlane.Components) > 0 {
+		controlPlane := config.ControlPlane
+		jobs = append(jobs, func() {
+			if err := q.CheckControlPlane(clientset, controlPlane, ruleAlert(controlPlane.Escalation), alertersConfig); err != nil {
+				log.Printf("Error checking the control plane: %s", err.Error())
+			}
+		})
+	}
+	// Check the health of etcd through the apiserver
+	if config.Etcd.Endpoint != "" {
+		etcd := config.Etcd
+		jobs = append(jobs, func() {
+			q.CheckEtcd(clientset, etcd, ruleAlert(etcd.Escalation), alertersConfig)
+		})
 	}
+	return jobs
 }
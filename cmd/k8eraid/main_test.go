@@ -13,3 +13,72 @@
 // limitations under the License.
 
 package main
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/bloomberg/k8eraid/pkgs/alerters"
+	"github.com/bloomberg/k8eraid/pkgs/types"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"k8s.io/apimachinery/pkg/runtime"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func Test_pollJobs_CheckToggles(t *testing.T) {
+	tests := []struct {
+		name     string
+		toggles  string
+		expected int
+	}{
+		{name: "every kind enabled by default", expected: 29},
+		{name: "nodes switched off", toggles: `"enableNodeChecks": false,`, expected: 28},
+		{name: "explicitly enabled", toggles: `"enablePodChecks": true,`, expected: 29},
+		{
+			name:     "only nodes",
+			toggles:  `"enableDeploymentChecks": false, "enablePodChecks": false, "enableDaemonsetChecks": false, "enableStatefulsetChecks": false, "enableReplicaSetChecks": false, "enableJobChecks": false, "enableCronJobChecks": false, "enablePersistentVolumeClaimChecks": false, "enablePersistentVolumeChecks": false, "enableStorageClassChecks": false, "enableNamespaceChecks": false, "enableResourceQuotaChecks": false, "enableServiceChecks": false, "enableIngressChecks": false, "enableHorizontalPodAutoscalerChecks": false, "enablePodDisruptionBudgetChecks": false, "enableApiserverLatencyChecks": false, "enableApiserverHealthChecks": false, "enableControlPlaneChecks": false, "enableEtcdChecks": false, "enableWebhookConfigurationChecks": false, "enableUnusedResourceChecks": false, "enableWorkloadResourceChecks": false, "enableDeprecatedApiChecks": false, "enableFieldConditionChecks": false, "enableCertificateSigningRequestChecks": false, "enableEventChecks": false, "enableConfigObjectChecks": false,`,
+			expected: 1,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(subT *testing.T) {
+			var config types.ConfigRules
+			require.NoError(subT, json.Unmarshal([]byte(`{`+test.toggles+`
+				"deployments": [{"name": "web", "filter": "default"}],
+				"pods": [{"name": "web-0", "filterNamespace": "default"}],
+				"daemonsets": [{"name": "agent", "filter": "default"}],
+				"statefulsets": [{"name": "db", "filter": "default"}],
+				"replicaSets": [{"name": "*"}],
+				"jobs": [{"name": "*"}],
+				"cronJobs": [{"name": "*"}],
+				"nodes": [{"name": "*"}],
+				"persistentVolumeClaims": [{"name": "data", "filterNamespace": "default"}],
+				"persistentVolumes": [{"name": "*"}],
+				"storageClasses": [{"name": "*"}],
+				"namespaces": [{"name": "*"}],
+				"resourceQuotas": [{"name": "*"}],
+				"services": [{"name": "web", "filterNamespace": "default"}],
+				"ingresses": [{"name": "web", "filterNamespace": "default"}],
+				"horizontalPodAutoscalers": [{"name": "*"}],
+				"podDisruptionBudgets": [{"name": "*"}],
+				"webhookConfigurations": [{"name": "*"}],
+				"unusedResources": [{"filterNamespace": "default"}],
+				"workloadResources": [{"filterNamespace": "default"}],
+				"deprecatedApis": [{"reportStatus": {"objects": true}}],
+				"fieldConditions": [{"name": "*", "group": "apps", "version": "v1", "resource": "deployments"}],
+				"certificateSigningRequests": [{"reportStatus": {"maxPending": 10}}],
+				"events": [{"name": "*", "filterKind": "Pod"}],
+				"configObjects": [{"name": "*", "kind": "ConfigMap"}],
+				"apiserverLatency": {"thresholdSeconds": 1},
+				"apiserverHealth": {"endpoints": ["/livez"]},
+				"controlPlane": {"components": ["scheduler"]},
+				"etcd": {"endpoint": "/readyz/etcd"}
+			}`), &config))
+			jobs := pollJobs(fake.NewSimpleClientset(), dynamicfake.NewSimpleDynamicClient(runtime.NewScheme()), &config, alerters.Discard)
+			assert.Equal(subT, test.expected, len(jobs))
+		})
+	}
+}
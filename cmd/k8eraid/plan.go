@@ -0,0 +1,294 @@
+// Copyright 2019 Bloomberg Finance LP
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/bloomberg/k8eraid/pkgs/alerters"
+	"github.com/bloomberg/k8eraid/pkgs/types"
+
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/kubernetes"
+)
+
+// evaluation is every alert raised by one tick's polls
+type evaluation struct {
+	Time time.Time `json:"time"`
+	// Complete is false when the worker pool dropped some of the tick's polls
+	Complete bool          `json:"complete"`
+	Alerts   []types.Alert `json:"alerts"`
+}
+
+// evaluationRecorder collects the alerts of the tick in progress
+type evaluationRecorder struct {
+	mu    sync.Mutex
+	eval  evaluation
+	total int
+	jobs  int32
+	onEnd func(evaluation)
+}
+
+func newEvaluationRecorder(now time.Time, onEnd func(evaluation)) *evaluationRecorder {
+	return &evaluationRecorder{eval: evaluation{Time: now, Complete: true, Alerts: []types.Alert{}}, onEnd: onEnd}
+}
+
+// record is an alerters.Middleware recording the alerts passed through it
+func (e *evaluationRecorder) record(next alerters.Alerter) alerters.Alerter {
+	return alerters.AlerterFunc(func(alerterType string, alerterName string, alert types.Alert, alertersConfig types.AlertersConfig) {
+		e.mu.Lock()
+		e.eval.Alerts = append(e.eval.Alerts, alert)
+		e.mu.Unlock()
+		next.Alert(alerterType, alerterName, alert, alertersConfig)
+	})
+}
+
+// track wraps jobs so the evaluation ends once every one of them has returned
+func (e *evaluationRecorder) track(jobs []func()) []func() {
+	e.total = len(jobs)
+	atomic.StoreInt32(&e.jobs, int32(len(jobs)))
+	tracked := make([]func(), len(jobs))
+	for i, job := range jobs {
+		job := job
+		tracked[i] = func() {
+			job()
+			e.finish(1)
+		}
+	}
+	return tracked
+}
+
+// submitted accounts for how many of the tracked jobs the pool accepted. A tick with no jobs ends
+// right away, and a tick whose jobs were all skipped never ends.
+func (e *evaluationRecorder) submitted(accepted int) {
+	switch {
+	case e.total == 0:
+		e.finish(0)
+	case accepted == 0:
+	case accepted < e.total:
+		e.mu.Lock()
+		e.eval.Complete = false
+		e.mu.Unlock()
+		e.finish(e.total - accepted)
+	}
+}
+
+func (e *evaluationRecorder) finish(count int) {
+	if atomic.AddInt32(&e.jobs, -int32(count)) != 0 {
+		return
+	}
+	e.mu.Lock()
+	eval := e.eval
+	e.mu.Unlock()
+	sort.SliceStable(eval.Alerts, func(i, j int) bool { return eval.Alerts[i].Key < eval.Alerts[j].Key })
+	e.onEnd(eval)
+}
+
+// lastEvaluation holds the most recent tick that ran any poll, for plan mode to compare against
+var lastEvaluation struct {
+	mu   sync.Mutex
+	eval *evaluation
+}
+
+func setLastEvaluation(eval evaluation) {
+	lastEvaluation.mu.Lock()
+	lastEvaluation.eval = &eval
+	lastEvaluation.mu.Unlock()
+}
+
+// evaluationHandler serves /admin/evaluation, the alerts raised by the last tick
+func evaluationHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	lastEvaluation.mu.Lock()
+	eval := lastEvaluation.eval
+	lastEvaluation.mu.Unlock()
+	if eval == nil {
+		http.Error(w, "no tick has completed yet", http.StatusServiceUnavailable)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(eval)
+}
+
+// alertChange is an alert raised by both evaluations with a different severity or message
+type alertChange struct {
+	Key    string      `json:"key"`
+	Before types.Alert `json:"before"`
+	After  types.Alert `json:"after"`
+}
+
+// plan is the difference between the alerts of the running config's last evaluation and the
+// ones the candidate config raises
+type plan struct {
+	BaselineTime  time.Time     `json:"baselineTime"`
+	CandidateTime time.Time     `json:"candidateTime"`
+	Added         []types.Alert `json:"added"`
+	Removed       []types.Alert `json:"removed"`
+	Changed       []alertChange `json:"changed"`
+	Unchanged     []string      `json:"unchanged"`
+	// candidate is every alert the candidate config raises, once per key, for the formatters
+	candidate []types.Alert
+}
+
+// Empty reports whether the candidate config raises exactly the alerts of the baseline
+func (p plan) Empty() bool {
+	return len(p.Added) == 0 && len(p.Removed) == 0 && len(p.Changed) == 0
+}
+
+// diffEvaluations compares alerts by key. Alerts are sorted by key in every list.
+func diffEvaluations(baseline evaluation, candidate evaluation) plan {
+	p := plan{
+		BaselineTime:  baseline.Time,
+		CandidateTime: candidate.Time,
+		Added:         []types.Alert{},
+		Removed:       []types.Alert{},
+		Changed:       []alertChange{},
+		Unchanged:     []string{},
+	}
+	before := map[string]types.Alert{}
+	for _, alert := range baseline.Alerts {
+		before[alert.Key] = alert
+	}
+	after := map[string]bool{}
+	for _, alert := range candidate.Alerts {
+		if after[alert.Key] {
+			continue
+		}
+		after[alert.Key] = true
+		p.candidate = append(p.candidate, alert)
+		previous, ok := before[alert.Key]
+		switch {
+		case !ok:
+			p.Added = append(p.Added, alert)
+		case previous.Severity != alert.Severity || previous.Message != alert.Message:
+			p.Changed = append(p.Changed, alertChange{Key: alert.Key, Before: previous, After: alert})
+		default:
+			p.Unchanged = append(p.Unchanged, alert.Key)
+		}
+	}
+	for _, alert := range baseline.Alerts {
+		if !after[alert.Key] {
+			after[alert.Key] = true
+			p.Removed = append(p.Removed, alert)
+		}
+	}
+	sort.Slice(p.Added, func(i, j int) bool { return p.Added[i].Key < p.Added[j].Key })
+	sort.Slice(p.Removed, func(i, j int) bool { return p.Removed[i].Key < p.Removed[j].Key })
+	sort.Slice(p.Changed, func(i, j int) bool { return p.Changed[i].Key < p.Changed[j].Key })
+	sort.Strings(p.Unchanged)
+	sort.SliceStable(p.candidate, func(i, j int) bool { return p.candidate[i].Key < p.candidate[j].Key })
+	return p
+}
+
+// noBaseline is the baseline source that compares against an evaluation without alerts, so every
+// alert of the candidate config is added
+const noBaseline = "none"
+
+// loadBaseline reads the last evaluation of the running k8eraid from an http(s) URL, presenting
+// token to its admin endpoint, or from a file
+func loadBaseline(source string, token string) (evaluation, error) {
+	var eval evaluation
+	var data []byte
+	if source == noBaseline {
+		return evaluation{Alerts: []types.Alert{}}, nil
+	}
+	if strings.HasPrefix(source, "http://") || strings.HasPrefix(source, "https://") {
+		req, err := http.NewRequest(http.MethodGet, source, nil)
+		if err != nil {
+			return eval, fmt.Errorf("unable to fetch baseline from %s: %s", source, err.Error())
+		}
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		client := &http.Client{Timeout: 10 * time.Second}
+		resp, err := client.Do(req)
+		if err != nil {
+			return eval, fmt.Errorf("unable to fetch baseline from %s: %s", source, err.Error())
+		}
+		defer resp.Body.Close()
+		data, err = ioutil.ReadAll(resp.Body)
+		if err != nil {
+			return eval, fmt.Errorf("unable to read baseline from %s: %s", source, err.Error())
+		}
+		if resp.StatusCode != http.StatusOK {
+			return eval, fmt.Errorf("unable to fetch baseline from %s: HTTP Status Code: %d: %s", source, resp.StatusCode, strings.TrimSpace(string(data)))
+		}
+	} else {
+		var err error
+		if data, err = ioutil.ReadFile(source); err != nil {
+			return eval, fmt.Errorf("unable to read baseline %s: %s", source, err.Error())
+		}
+	}
+	if err := json.Unmarshal(data, &eval); err != nil {
+		return eval, fmt.Errorf("unable to parse baseline from %s: %s", source, err.Error())
+	}
+	return eval, nil
+}
+
+// runPlan evaluates the candidate config at candidatePath, or on stdin for "-", once against the
+// cluster without delivering any alert, and writes the diff against the baseline evaluation to w
+// with formatter. It returns whether the candidate raises different alerts than the baseline.
+// token is the admin token a baseline URL is fetched with.
+func runPlan(w io.Writer, formatter planFormatter, clientset kubernetes.Interface, dynamicClient dynamic.Interface, candidatePath string, baselineSource string, token string) (bool, error) {
+	var data []byte
+	var err error
+	if candidatePath == "-" {
+		data, err = ioutil.ReadAll(os.Stdin)
+	} else {
+		data, err = ioutil.ReadFile(candidatePath)
+	}
+	if err != nil {
+		return false, fmt.Errorf("unable to read candidate config %s: %s", candidatePath, err.Error())
+	}
+	var candidate types.ConfigRules
+	if err := json.Unmarshal(data, &candidate); err != nil {
+		return false, fmt.Errorf("unable to parse candidate config %s: %s", candidatePath, err.Error())
+	}
+	if err := candidate.Validate(); err != nil {
+		return false, fmt.Errorf("candidate config %s: %s", candidatePath, err.Error())
+	}
+	baseline, err := loadBaseline(baselineSource, token)
+	if err != nil {
+		return false, err
+	}
+
+	var result evaluation
+	recorder := newEvaluationRecorder(time.Now(), func(eval evaluation) { result = eval })
+	jobs := recorder.track(pollJobs(clientset, dynamicClient, &candidate, alerters.Chain(alerters.Discard, recorder.record)))
+	recorder.submitted(len(jobs))
+	// one job at a time, so the evaluation is over when the loop returns
+	for _, job := range jobs {
+		job()
+	}
+
+	p := diffEvaluations(baseline, result)
+	if err := formatter.Format(w, p); err != nil {
+		return false, err
+	}
+	return !p.Empty(), nil
+}
@@ -0,0 +1,174 @@
+// Copyright 2019 Bloomberg Finance LP
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/bloomberg/k8eraid/pkgs/alerters"
+	"github.com/bloomberg/k8eraid/pkgs/types"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func planAlert(key string, severity string, message string) types.Alert {
+	return types.Alert{Key: key, Resource: key, Severity: severity, Message: message}
+}
+
+func Test_diffEvaluations(t *testing.T) {
+	baseline := evaluation{Alerts: []types.Alert{
+		planAlert("node/a/Ready", types.SeverityCritical, "a is not ready"),
+		planAlert("node/b/Ready", types.SeverityCritical, "b is not ready"),
+		planAlert("pod/ns/c/Ready", types.SeverityWarning, "c restarted 1 times"),
+	}}
+	candidate := evaluation{Alerts: []types.Alert{
+		planAlert("pod/ns/c/Ready", types.SeverityWarning, "c restarted 2 times"),
+		planAlert("node/a/Ready", types.SeverityCritical, "a is not ready"),
+		planAlert("node/d/Ready", types.SeverityCritical, "d is not ready"),
+		planAlert("node/d/Ready", types.SeverityCritical, "d is not ready"),
+	}}
+
+	p := diffEvaluations(baseline, candidate)
+	assert.False(t, p.Empty())
+	assert.Equal(t, []types.Alert{candidate.Alerts[2]}, p.Added)
+	assert.Equal(t, []types.Alert{baseline.Alerts[1]}, p.Removed)
+	assert.Equal(t, []alertChange{{Key: "pod/ns/c/Ready", Before: baseline.Alerts[2], After: candidate.Alerts[0]}}, p.Changed)
+	assert.Equal(t, []string{"node/a/Ready"}, p.Unchanged)
+	assert.True(t, diffEvaluations(baseline, baseline).Empty())
+}
+
+func Test_evaluationRecorder(t *testing.T) {
+	var ended []evaluation
+	onEnd := func(eval evaluation) { ended = append(ended, eval) }
+	recorder := newEvaluationRecorder(time.Unix(1000, 0), onEnd)
+	record := recorder.record(alerters.Discard).Alert
+	jobs := recorder.track([]func(){
+		func() {
+			record("stderr", "", planAlert("node/b/Ready", types.SeverityCritical, "b"), types.AlertersConfig{})
+		},
+		func() {
+			record("stderr", "", planAlert("node/a/Ready", types.SeverityCritical, "a"), types.AlertersConfig{})
+		},
+		func() {},
+	})
+	jobs[0]()
+	jobs[1]()
+	recorder.submitted(2)
+	require.Equal(t, 1, len(ended), "the evaluation should end once the accepted jobs ran")
+	assert.False(t, ended[0].Complete)
+	assert.Equal(t, "node/a/Ready", ended[0].Alerts[0].Key)
+	assert.Equal(t, "node/b/Ready", ended[0].Alerts[1].Key)
+
+	ended = nil
+	skipped := newEvaluationRecorder(time.Unix(1000, 0), onEnd)
+	skipped.track([]func(){func() {}})
+	skipped.submitted(0)
+	assert.Empty(t, ended, "a skipped tick should not replace the last evaluation")
+
+	empty := newEvaluationRecorder(time.Unix(1000, 0), onEnd)
+	empty.submitted(len(empty.track(nil)))
+	require.Equal(t, 1, len(ended))
+	assert.True(t, ended[0].Complete)
+	assert.Empty(t, ended[0].Alerts)
+}
+
+func Test_evaluationHandler(t *testing.T) {
+	lastEvaluation.eval = nil
+	recorder := httptest.NewRecorder()
+	evaluationHandler(recorder, httptest.NewRequest(http.MethodGet, "/admin/evaluation", nil))
+	assert.Equal(t, http.StatusServiceUnavailable, recorder.Code)
+
+	setLastEvaluation(evaluation{Time: time.Unix(1000, 0).UTC(), Complete: true, Alerts: []types.Alert{planAlert("node/a/Ready", types.SeverityCritical, "a")}})
+	recorder = httptest.NewRecorder()
+	evaluationHandler(recorder, httptest.NewRequest(http.MethodGet, "/admin/evaluation", nil))
+	require.Equal(t, http.StatusOK, recorder.Code)
+	var eval evaluation
+	require.NoError(t, json.NewDecoder(recorder.Body).Decode(&eval))
+	assert.Equal(t, "node/a/Ready", eval.Alerts[0].Key)
+
+	recorder = httptest.NewRecorder()
+	evaluationHandler(recorder, httptest.NewRequest(http.MethodPost, "/admin/evaluation", nil))
+	assert.Equal(t, http.StatusMethodNotAllowed, recorder.Code)
+}
+
+func Test_evaluationURL(t *testing.T) {
+	assert.Equal(t, "http://localhost:8080/admin/evaluation", evaluationURL(":8080"))
+	assert.Equal(t, "http://10.0.0.1:9090/admin/evaluation", evaluationURL("10.0.0.1:9090"))
+}
+
+func Test_runPlan(t *testing.T) {
+	dir, err := ioutil.TempDir("", "k8eraid-plan")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	candidate := filepath.Join(dir, "candidate.json")
+	require.NoError(t, ioutil.WriteFile(candidate, []byte(`{
+		"nodes": [{"name": "*", "filter": "pool=workers", "alerterType": "stderr", "reportStatus": {"minNodes": 3}}]
+	}`), 0644))
+	baseline := evaluation{Alerts: []types.Alert{planAlert("node/gone/Ready", types.SeverityCritical, "gone is not ready")}}
+	data, err := json.Marshal(baseline)
+	require.NoError(t, err)
+	server := httptest.NewServer(requireToken("s3cret", http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.Write(data)
+	})))
+	defer server.Close()
+
+	clientset := fake.NewSimpleClientset(&corev1.Node{
+		ObjectMeta: metav1.ObjectMeta{Name: "worker-1", Labels: map[string]string{"pool": "workers"}},
+	})
+	dynamicClient := dynamicfake.NewSimpleDynamicClient(runtime.NewScheme())
+	var buf bytes.Buffer
+	changed, err := runPlan(&buf, jsonFormatter{}, clientset, dynamicClient, candidate, server.URL, "s3cret")
+	require.NoError(t, err)
+	assert.True(t, changed)
+
+	var p plan
+	require.NoError(t, json.Unmarshal(buf.Bytes(), &p))
+	require.Equal(t, 1, len(p.Added))
+	assert.Equal(t, "nodes/pool=workers/MinNodes", p.Added[0].Key)
+	require.Equal(t, 1, len(p.Removed))
+	assert.Equal(t, "node/gone/Ready", p.Removed[0].Key)
+
+	invalid := filepath.Join(dir, "invalid.json")
+	require.NoError(t, ioutil.WriteFile(invalid, []byte(`{"nodes": [{"name": "*", "alerterType": "smtp", "alerterName": "missing"}]}`), 0644))
+	_, err = runPlan(&buf, jsonFormatter{}, clientset, dynamicClient, invalid, server.URL, "s3cret")
+	assert.Error(t, err, "an invalid candidate should be rejected before it is evaluated")
+
+	_, err = runPlan(&buf, jsonFormatter{}, clientset, dynamicClient, candidate, server.URL, "wrong")
+	assert.Error(t, err, "the baseline should not be served without the admin token")
+
+	_, err = runPlan(&buf, jsonFormatter{}, clientset, dynamicClient, candidate, filepath.Join(dir, "missing.json"), "")
+	assert.Error(t, err)
+
+	buf.Reset()
+	changed, err = runPlan(&buf, junitFormatter{}, clientset, dynamicClient, candidate, noBaseline, "")
+	require.NoError(t, err)
+	assert.True(t, changed)
+	assert.Contains(t, buf.String(), `<testcase name="nodes/pool=workers/MinNodes" classname="k8eraid.nodes">`)
+}
@@ -0,0 +1,62 @@
+// Copyright 2019 Bloomberg Finance LP
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"time"
+
+	"github.com/bloomberg/k8eraid/internal/renderfixtures"
+	"github.com/bloomberg/k8eraid/pkgs/alerters"
+	q "github.com/bloomberg/k8eraid/pkgs/queries"
+	"github.com/bloomberg/k8eraid/pkgs/types"
+)
+
+// renderAlerts prints the sample alert of every check, followed by how each templated alerter of
+// the config at configPath, if any, would render it. The output only depends on the message code and
+// the templates, so it can be snapshot tested.
+func renderAlerts(w io.Writer, configPath string) error {
+	var rules types.ConfigRules
+	if configPath != "" {
+		data, err := ioutil.ReadFile(configPath)
+		if err != nil {
+			return fmt.Errorf("unable to read config %s: %s", configPath, err.Error())
+		}
+		if err := json.Unmarshal(data, &rules); err != nil {
+			return fmt.Errorf("unable to parse config %s: %s", configPath, err.Error())
+		}
+	}
+
+	input, err := renderfixtures.Input(time.Now())
+	if err != nil {
+		return err
+	}
+	alerts, err := q.SampleAlerts(input)
+	if err != nil {
+		return err
+	}
+	for _, alert := range alerts {
+		fmt.Fprintf(w, "%s [%s]\n", alert.Key, alert.Severity)
+		fmt.Fprintf(w, "\t%s\n", alert.Message)
+		for _, rendered := range alerters.RenderTemplated(alert, rules.AlertersConfig) {
+			fmt.Fprintf(w, "\t%s/%s title: %s\n", rendered.AlerterType, rendered.AlerterName, rendered.Title)
+			fmt.Fprintf(w, "\t%s/%s message: %s\n", rendered.AlerterType, rendered.AlerterName, rendered.Body)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,42 @@
+// Copyright 2019 Bloomberg Finance LP
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bytes"
+	"flag"
+	"io/ioutil"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var updateGolden = flag.Bool("update", false, "rewrite the golden files of the snapshot tests")
+
+// Test_renderAlerts snapshots the text of every alert. When a message is changed on purpose,
+// regenerate the snapshot with `go test ./cmd/k8eraid -run Test_renderAlerts -update`.
+func Test_renderAlerts(t *testing.T) {
+	var buf bytes.Buffer
+	require.NoError(t, renderAlerts(&buf, "testdata/render-config.json"))
+
+	const golden = "testdata/rendered-alerts.golden"
+	if *updateGolden {
+		require.NoError(t, ioutil.WriteFile(golden, buf.Bytes(), 0644))
+	}
+	expected, err := ioutil.ReadFile(golden)
+	require.NoError(t, err)
+	assert.Equal(t, string(expected), buf.String(), "alert text changed, see %s", golden)
+}
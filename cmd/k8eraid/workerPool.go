@@ -0,0 +1,126 @@
+// Copyright 2019 Bloomberg Finance LP
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"log"
+	"sync/atomic"
+
+	"github.com/bloomberg/k8eraid/pkgs/metrics"
+)
+
+const (
+	// overflowSkip drops a whole tick when every worker is still busy with the previous one
+	overflowSkip = "skip"
+	// overflowQueue queues jobs in the bounded buffer and drops the ones that do not fit
+	overflowQueue = "queue"
+)
+
+var (
+	poolSizeGauge       = metrics.NewGauge("k8eraid_worker_pool_size", "Number of workers in the poll worker pool.")
+	poolActiveGauge     = metrics.NewGauge("k8eraid_worker_pool_active", "Number of workers currently running a poll.")
+	poolSaturationGauge = metrics.NewGauge("k8eraid_worker_pool_saturation", "Ratio of busy workers to pool size.")
+	poolQueueGauge      = metrics.NewGauge("k8eraid_worker_pool_queue_depth", "Number of polls waiting for a free worker.")
+	skippedTicksCounter = metrics.NewCounter("k8eraid_worker_pool_skipped_ticks_total", "Ticks skipped because the pool was saturated.")
+	skippedJobsCounter  = metrics.NewCounter("k8eraid_worker_pool_skipped_jobs_total", "Polls skipped because the pool was saturated.")
+	droppedJobsCounter  = metrics.NewCounter("k8eraid_worker_pool_dropped_jobs_total", "Polls dropped because the queue was full.")
+)
+
+// workerPool runs poll jobs on a fixed number of goroutines
+type workerPool struct {
+	jobs     chan func()
+	size     int
+	overflow string
+	active   int32
+}
+
+func newWorkerPool(size int, queueSize int, overflow string) *workerPool {
+	if size < 1 {
+		size = 1
+	}
+	if queueSize < 0 {
+		queueSize = 0
+	}
+	if overflow != overflowQueue {
+		overflow = overflowSkip
+	}
+	p := &workerPool{
+		jobs:     make(chan func(), queueSize),
+		size:     size,
+		overflow: overflow,
+	}
+	poolSizeGauge.Set(float64(size))
+	for i := 0; i < size; i++ {
+		go p.work()
+	}
+	return p
+}
+
+func (p *workerPool) work() {
+	for job := range p.jobs {
+		poolQueueGauge.Set(float64(len(p.jobs)))
+		p.setActive(atomic.AddInt32(&p.active, 1))
+		job()
+		p.setActive(atomic.AddInt32(&p.active, -1))
+	}
+}
+
+func (p *workerPool) setActive(active int32) {
+	poolActiveGauge.Set(float64(active))
+	poolSaturationGauge.Set(float64(active) / float64(p.size))
+}
+
+// saturated reports whether every worker is busy
+func (p *workerPool) saturated() bool {
+	return int(atomic.LoadInt32(&p.active)) >= p.size
+}
+
+// submitTick hands a tick's worth of jobs to the pool, applying the overflow policy.
+// Jobs are never waited on, so the ticker is not held up by a full queue.
+// It returns the number of jobs accepted.
+func (p *workerPool) submitTick(jobs []func()) int {
+	if p.overflow == overflowSkip && p.saturated() {
+		skippedTicksCounter.Inc()
+		skippedJobsCounter.Add(float64(len(jobs)))
+		log.Printf("All %d workers are busy, skipping this tick", p.size)
+		return 0
+	}
+
+	accepted := 0
+	for _, job := range jobs {
+		select {
+		case p.jobs <- job:
+			accepted++
+			poolQueueGauge.Set(float64(len(p.jobs)))
+		default:
+		}
+	}
+	if missed := len(jobs) - accepted; missed > 0 {
+		// the workers freed up for this tick but the queue is still full from the previous one
+		if p.overflow == overflowSkip {
+			skippedJobsCounter.Add(float64(missed))
+			log.Printf("Worker queue is still full, skipped %d of %d polls for this tick", missed, len(jobs))
+		} else {
+			droppedJobsCounter.Add(float64(missed))
+			log.Printf("Worker queue is full, dropped %d of %d polls for this tick", missed, len(jobs))
+		}
+	}
+	return accepted
+}
+
+// stop closes the job queue, workers exit once it drains
+func (p *workerPool) stop() {
+	close(p.jobs)
+}
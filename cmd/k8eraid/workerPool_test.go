@@ -0,0 +1,155 @@
+// Copyright 2019 Bloomberg Finance LP
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// blockingJobs returns n jobs that block until release is closed, tracking peak concurrency
+func blockingJobs(n int, release chan struct{}, running *int32, peak *int32, done *sync.WaitGroup) []func() {
+	jobs := make([]func(), n)
+	for i := range jobs {
+		jobs[i] = func() {
+			defer done.Done()
+			current := atomic.AddInt32(running, 1)
+			for {
+				old := atomic.LoadInt32(peak)
+				if current <= old || atomic.CompareAndSwapInt32(peak, old, current) {
+					break
+				}
+			}
+			<-release
+			atomic.AddInt32(running, -1)
+		}
+	}
+	return jobs
+}
+
+func waitSaturated(t *testing.T, p *workerPool) {
+	deadline := time.Now().Add(2 * time.Second)
+	for !p.saturated() {
+		if time.Now().After(deadline) {
+			t.Fatal("worker pool never became saturated")
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func Test_WorkerPool_CapsConcurrency(t *testing.T) {
+	p := newWorkerPool(2, 10, overflowQueue)
+	defer p.stop()
+
+	var running, peak int32
+	var done sync.WaitGroup
+	release := make(chan struct{})
+	done.Add(6)
+	if accepted := p.submitTick(blockingJobs(6, release, &running, &peak, &done)); accepted != 6 {
+		t.Fatalf("expected 6 jobs to be accepted, got %d", accepted)
+	}
+	waitSaturated(t, p)
+	close(release)
+	done.Wait()
+
+	if peak > 2 {
+		t.Errorf("expected at most 2 concurrent jobs, saw %d", peak)
+	}
+}
+
+func Test_WorkerPool_SkipsTickWhenSaturated(t *testing.T) {
+	p := newWorkerPool(1, 1, overflowSkip)
+	defer p.stop()
+
+	var running, peak int32
+	var done sync.WaitGroup
+	release := make(chan struct{})
+	done.Add(1)
+	p.submitTick(blockingJobs(1, release, &running, &peak, &done))
+	waitSaturated(t, p)
+
+	ran := false
+	if accepted := p.submitTick([]func(){func() { ran = true }}); accepted != 0 {
+		t.Errorf("expected the tick to be skipped, %d jobs were accepted", accepted)
+	}
+	close(release)
+	done.Wait()
+	if ran {
+		t.Error("job from a skipped tick should not have run")
+	}
+}
+
+func Test_WorkerPool_QueueDropsOverflow(t *testing.T) {
+	p := newWorkerPool(1, 2, overflowQueue)
+	defer p.stop()
+
+	var running, peak int32
+	var done sync.WaitGroup
+	release := make(chan struct{})
+	done.Add(1)
+	p.submitTick(blockingJobs(1, release, &running, &peak, &done))
+	waitSaturated(t, p)
+
+	done.Add(2)
+	if accepted := p.submitTick(blockingJobs(4, release, &running, &peak, &done)); accepted != 2 {
+		t.Errorf("expected 2 jobs to fit in the queue, got %d", accepted)
+	}
+	close(release)
+	done.Wait()
+}
+
+func Test_WorkerPool_SkipNeverBlocksOnFullQueue(t *testing.T) {
+	p := newWorkerPool(2, 1, overflowSkip)
+	defer p.stop()
+
+	var running, peak int32
+	var done sync.WaitGroup
+	release := make(chan struct{})
+	done.Add(1)
+	p.submitTick(blockingJobs(1, release, &running, &peak, &done))
+	waitRunning := func() {
+		deadline := time.Now().Add(2 * time.Second)
+		for atomic.LoadInt32(&p.active) < 1 {
+			if time.Now().After(deadline) {
+				t.Fatal("worker never picked the job up")
+			}
+			time.Sleep(time.Millisecond)
+		}
+	}
+	waitRunning()
+
+	// one worker is still free, so the tick is not skipped, but only one job fits in the queue
+	// beyond the job the free worker takes
+	skippedBefore := skippedJobsCounter.Value()
+	submitted := make(chan int)
+	go func() { submitted <- p.submitTick(blockingJobs(6, release, &running, &peak, &done)) }()
+	var accepted int
+	select {
+	case accepted = <-submitted:
+	case <-time.After(2 * time.Second):
+		t.Fatal("submitTick blocked on a full queue")
+	}
+	if accepted > 2 || accepted < 1 {
+		t.Errorf("expected 1 or 2 jobs to be accepted, got %d", accepted)
+	}
+	if skipped := skippedJobsCounter.Value() - skippedBefore; int(skipped) != 6-accepted {
+		t.Errorf("expected %d jobs to be counted as skipped, got %v", 6-accepted, skipped)
+	}
+	done.Add(accepted)
+	close(release)
+	done.Wait()
+}
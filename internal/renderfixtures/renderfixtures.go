@@ -0,0 +1,703 @@
+// Copyright 2019 Bloomberg Finance LP
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package renderfixtures builds the synthetic cluster `k8eraid -render-alerts` runs every check
+// against. It lives apart from the queries package so the fake clients it builds on never end up
+// in the code that polls real clusters.
+package renderfixtures
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+	"strings"
+	"time"
+
+	q "github.com/bloomberg/k8eraid/pkgs/queries"
+	"github.com/bloomberg/k8eraid/pkgs/types"
+
+	admissionv1beta1 "k8s.io/api/admissionregistration/v1beta1"
+	appsv1 "k8s.io/api/apps/v1"
+	autoscalingv2beta2 "k8s.io/api/autoscaling/v2beta2"
+	batchv1 "k8s.io/api/batch/v1"
+	batchv1beta1 "k8s.io/api/batch/v1beta1"
+	coordinationv1beta1 "k8s.io/api/coordination/v1beta1"
+	corev1 "k8s.io/api/core/v1"
+	extensionsv1beta1 "k8s.io/api/extensions/v1beta1"
+	policyv1beta1 "k8s.io/api/policy/v1beta1"
+	storagev1 "k8s.io/api/storage/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/version"
+	fakediscovery "k8s.io/client-go/discovery/fake"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+// The objects are named and labelled after the specs of queries.SampleAlerts, which filter on them
+const sampleNamespace = "sample"
+
+// Well known annotations, taints and reasons the checks look for
+const (
+	crashLoopBackOffReason       = "CrashLoopBackOff"
+	defaultZoneLabel             = "failure-domain.beta.kubernetes.io/zone"
+	deploymentDeadlineReason     = "ProgressDeadlineExceeded"
+	deploymentPausedReason       = "DeploymentPaused"
+	deploymentRevisionAnnotation = "deployment.kubernetes.io/revision"
+	hpaTooManyReplicasReason     = "TooManyReplicas"
+	ingressClassAnnotation       = "kubernetes.io/ingress.class"
+	jobBackoffLimitReason        = "BackoffLimitExceeded"
+	lastAppliedAnnotation        = "kubectl.kubernetes.io/last-applied-configuration"
+	nodeLeaseNamespace           = "kube-node-lease"
+	nodeUnschedulableTaint       = "node.kubernetes.io/unschedulable"
+	provisioningFailedReason     = "ProvisioningFailed"
+)
+
+var sampleNodeConditions = []corev1.NodeConditionType{
+	corev1.NodeReady,
+	corev1.NodeOutOfDisk,
+	corev1.NodeMemoryPressure,
+	corev1.NodeDiskPressure,
+	corev1.NodePIDPressure,
+	corev1.NodeNetworkUnavailable,
+	"KernelDeadlock",
+}
+
+func sampleNodeName(condition corev1.NodeConditionType) string {
+	return fmt.Sprintf("sample-node-%s", condition)
+}
+
+// Input builds fake clients serving objects created relative to now, and the credentials whose
+// expiry queries.SampleAlerts checks against the Unix epoch
+func Input(now time.Time) (q.SampleInput, error) {
+	clientset := fake.NewSimpleClientset(sampleObjects(now)...)
+	clientset.Discovery().(*fakediscovery.FakeDiscovery).FakedServerVersion = &version.Info{GitVersion: "v1.13.4"}
+	clientset.Discovery().(*fakediscovery.FakeDiscovery).Resources = []*metav1.APIResourceList{
+		{GroupVersion: "apps/v1", APIResources: []metav1.APIResource{{Name: "deployments", Namespaced: true}}},
+		{GroupVersion: "extensions/v1beta1", APIResources: []metav1.APIResource{{Name: "deployments", Namespaced: true}, {Name: "ingresses", Namespaced: true}}},
+	}
+	start := time.Unix(0, 0).UTC()
+	certificate, err := sampleCertificate("sample.example.com", start.Add(10*24*time.Hour+time.Hour))
+	if err != nil {
+		return q.SampleInput{}, err
+	}
+	return q.SampleInput{
+		Clientset:     clientset,
+		DynamicClient: dynamicfake.NewSimpleDynamicClient(runtime.NewScheme(), sampleCustomObjects(now)...),
+		Now:           now,
+		Certificate:   certificate,
+		Token:         sampleToken("system:serviceaccount:sample:deployer", start.Add(3*24*time.Hour)),
+	}, nil
+}
+
+// sampleObjects builds the objects queries.SampleAlerts polls, each of them tripping one check
+func sampleObjects(now time.Time) []runtime.Object {
+	old := metav1.Time{Time: now.Add(-time.Hour)}
+	recent := metav1.Time{Time: now.Add(-time.Second)}
+	abandoned := metav1.Time{Time: now.Add(-30 * 24 * time.Hour)}
+	fastClass, archiveClass := "sample-fast", "sample-archive"
+	objects := []runtime.Object{}
+
+	for _, condition := range sampleNodeConditions {
+		node := readyNode(sampleNodeName(condition), corev1.ConditionTrue)
+		node.CreationTimestamp = old
+		node.Status.Conditions = []corev1.NodeCondition{{Type: condition, Status: corev1.ConditionTrue, LastTransitionTime: recent}}
+		if condition == "KernelDeadlock" {
+			node.Status.Conditions[0].Reason, node.Status.Conditions[0].Message = "DockerHung", "task docker:7 blocked for more than 120 seconds."
+		}
+		objects = append(objects, node)
+	}
+	pleg := readyNode("sample-node-pleg", corev1.ConditionTrue)
+	pleg.Labels = map[string]string{"kubernetes.io/hostname": pleg.Name}
+	pleg.CreationTimestamp = old
+	pleg.Status.Conditions[0].Reason = "KubeletReady"
+	pleg.Status.Conditions[0].Message = "PLEG is not healthy: pleg was last seen active 3m0s ago"
+	pleg.Status.Allocatable = corev1.ResourceList{corev1.ResourceCPU: resource.MustParse("4"), corev1.ResourceMemory: resource.MustParse("16Gi")}
+	notReady := readyNode("sample-node-not-ready", corev1.ConditionFalse)
+	notReady.Labels = map[string]string{"pool": "sample"}
+	notReady.Status.NodeInfo = corev1.NodeSystemInfo{KernelVersion: "4.15.0-1040", OSImage: "Ubuntu 18.04.2 LTS", KubeletVersion: "v1.13.4"}
+	notReady.Spec.Taints = []corev1.Taint{{Key: "node.kubernetes.io/not-ready", Effect: corev1.TaintEffectNoExecute, TimeAdded: &metav1.Time{Time: now.Add(-20 * time.Minute)}}}
+	cordoned := readyNode("sample-node-cordoned", corev1.ConditionTrue)
+	cordoned.Labels = map[string]string{"pool": "sample"}
+	cordoned.Spec.Unschedulable = true
+	cordoned.Spec.Taints = []corev1.Taint{{Key: nodeUnschedulableTaint, Effect: corev1.TaintEffectNoSchedule, TimeAdded: &metav1.Time{Time: now.Add(-3 * 24 * time.Hour)}}}
+	cordoned.Status.NodeInfo = corev1.NodeSystemInfo{KernelVersion: "4.15.0-1036", OSImage: "Ubuntu 16.04.6 LTS", KubeletVersion: "v1.10.13"}
+	current := readyNode("sample-node-current", corev1.ConditionFalse)
+	current.Labels = map[string]string{"pool": "sample"}
+	current.Status.NodeInfo = corev1.NodeSystemInfo{KernelVersion: "4.15.0-1040", OSImage: "Ubuntu 18.04.2 LTS", KubeletVersion: "v1.13.4"}
+	current.Spec.Taints = []corev1.Taint{{Key: "aws-node-termination-handler/spot-itn", Value: "sample-event", Effect: corev1.TaintEffectNoSchedule}}
+	plegLease := &coordinationv1beta1.Lease{
+		ObjectMeta: metav1.ObjectMeta{Name: pleg.Name, Namespace: nodeLeaseNamespace},
+		Spec:       coordinationv1beta1.LeaseSpec{RenewTime: &metav1.MicroTime{Time: now.Add(-2 * time.Minute)}},
+	}
+	objects = append(objects, pleg, plegLease, notReady, cordoned, current)
+	for name, offset := range map[string]time.Duration{"sample-clock-a": -time.Second, "sample-clock-b": -2 * time.Second, "sample-clock-skewed": 5 * time.Minute} {
+		node := readyNode(name, corev1.ConditionTrue)
+		node.Labels = map[string]string{"clock": "sample", defaultZoneLabel: "sample-zone-a"}
+		node.Status.Conditions[0].LastHeartbeatTime = metav1.Time{Time: now.Add(offset)}
+		objects = append(objects, node)
+	}
+
+	restarting := samplePod("sample-restarting", old)
+	restarting.Status.Conditions = []corev1.PodCondition{{Type: corev1.PodReady, Status: corev1.ConditionTrue, LastTransitionTime: recent}}
+	restarting.Spec.Containers = []corev1.Container{{
+		Name:      "app",
+		Resources: corev1.ResourceRequirements{Limits: corev1.ResourceList{corev1.ResourceMemory: resource.MustParse("256Mi")}},
+	}}
+	restarting.Status.ContainerStatuses = []corev1.ContainerStatus{{
+		Name:                 "app",
+		RestartCount:         12,
+		State:                corev1.ContainerState{Waiting: &corev1.ContainerStateWaiting{Reason: crashLoopBackOffReason}},
+		LastTerminationState: corev1.ContainerState{Terminated: &corev1.ContainerStateTerminated{Reason: "OOMKilled", ExitCode: 137, FinishedAt: recent}},
+	}}
+	unscheduled := samplePod("sample-unscheduled", old)
+	unscheduled.Status.Phase = corev1.PodPending
+	unscheduled.Status.Conditions = []corev1.PodCondition{{
+		Type:               corev1.PodScheduled,
+		Status:             corev1.ConditionFalse,
+		Reason:             corev1.PodReasonUnschedulable,
+		LastTransitionTime: metav1.Time{Time: now.Add(-30 * time.Minute)},
+		Message:            "0/3 nodes are available: 1 node(s) had taints that the pod didn't tolerate, 2 Insufficient cpu.",
+	}}
+	terminating := samplePod("sample-terminating", old)
+	grace := int64(5)
+	terminating.DeletionTimestamp = &metav1.Time{Time: now.Add(-20 * time.Second)}
+	terminating.DeletionGracePeriodSeconds = &grace
+	database := samplePod("sample-db-0", old)
+	database.Spec.NodeName = "sample-node-pleg"
+	database.Spec.Volumes = []corev1.Volume{{
+		Name: "data",
+		VolumeSource: corev1.VolumeSource{
+			PersistentVolumeClaim: &corev1.PersistentVolumeClaimVolumeSource{ClaimName: "sample-data"},
+		},
+	}}
+	grpc := samplePod("sample-grpc", old)
+	grpc.Status.Phase = corev1.PodRunning
+	grpc.Status.PodIP = "10.0.0.5"
+	orphaned := samplePod("sample-orphaned", old)
+	orphaned.Spec.NodeName = "sample-node-deleted"
+	orphaned.Status.Phase = corev1.PodRunning
+	// created before the LimitRange of its namespace was tightened
+	unbounded := samplePod("sample-unbounded", old)
+	unbounded.Status.Phase = corev1.PodRunning
+	unbounded.Spec.Containers = []corev1.Container{{
+		Name: "app",
+		Resources: corev1.ResourceRequirements{
+			Limits:   corev1.ResourceList{corev1.ResourceMemory: resource.MustParse("2Gi")},
+			Requests: corev1.ResourceList{corev1.ResourceCPU: resource.MustParse("50m")},
+		},
+	}}
+	limits := &corev1.LimitRange{
+		ObjectMeta: metav1.ObjectMeta{Name: "sample-limits", Namespace: sampleNamespace},
+		Spec: corev1.LimitRangeSpec{Limits: []corev1.LimitRangeItem{{
+			Type: corev1.LimitTypeContainer,
+			Max:  corev1.ResourceList{corev1.ResourceMemory: resource.MustParse("1Gi")},
+			Min:  corev1.ResourceList{corev1.ResourceCPU: resource.MustParse("100m")},
+		}}},
+	}
+	configured := samplePod("sample-configured", old)
+	configured.Spec.Volumes = []corev1.Volume{
+		{Name: "settings", VolumeSource: corev1.VolumeSource{ConfigMap: &corev1.ConfigMapVolumeSource{LocalObjectReference: corev1.LocalObjectReference{Name: "sample-settings"}}}},
+		{Name: "flags", VolumeSource: corev1.VolumeSource{ConfigMap: &corev1.ConfigMapVolumeSource{LocalObjectReference: corev1.LocalObjectReference{Name: "sample-flags"}}}},
+	}
+	flags := &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: "sample-flags", Namespace: sampleNamespace, CreationTimestamp: old}}
+	badImage := samplePod("sample-bad-image", old)
+	badImage.Spec.Containers = []corev1.Container{{Name: "app", Image: "registry.example.com/sample:v1.0.1"}}
+	badImage.Status.ContainerStatuses = []corev1.ContainerStatus{{
+		Name: "app",
+		State: corev1.ContainerState{Waiting: &corev1.ContainerStateWaiting{
+			Reason:  "ImagePullBackOff",
+			Message: `Back-off pulling image "registry.example.com/sample:v1.0.1"`,
+		}},
+	}}
+	finalized := samplePod("sample-finalized", old)
+	finalized.Spec.NodeName = sampleNodeName(corev1.NodeReady)
+	finalized.DeletionTimestamp = &metav1.Time{Time: now.Add(-2 * time.Hour)}
+	finalized.DeletionGracePeriodSeconds = &grace
+	finalized.Finalizers = []string{"example.com/backup"}
+	shell := samplePod("sample-shell", old)
+	shell.Status.Phase = corev1.PodRunning
+	shell.Spec.Containers = []corev1.Container{{Name: "shell", Image: "busybox"}}
+	objects = append(objects, restarting, unscheduled, terminating, database, grpc, orphaned, unbounded, limits, configured, flags, badImage, finalized, shell)
+	for i, node := range []string{"sample-worker-1", "sample-worker-2", "sample-worker-1"} {
+		evicted := samplePod(fmt.Sprintf("sample-batch-%d", i), old)
+		evicted.Labels = map[string]string{"app": "sample-batch"}
+		evicted.Spec.NodeName = node
+		evicted.Status = corev1.PodStatus{Phase: corev1.PodFailed, Reason: "Evicted", Message: "The node was low on resource: memory."}
+		objects = append(objects, evicted)
+	}
+
+	replicas := int32(3)
+	failClosed := admissionv1beta1.Fail
+	web := &appsv1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{Name: "sample-web", Namespace: sampleNamespace, CreationTimestamp: old, UID: "sample-web"},
+		Spec: appsv1.DeploymentSpec{
+			Replicas: &replicas,
+			Selector: &metav1.LabelSelector{MatchLabels: map[string]string{"app": "sample-web"}},
+			Template: corev1.PodTemplateSpec{
+				ObjectMeta: metav1.ObjectMeta{Labels: map[string]string{"app": "sample-web"}},
+				Spec: corev1.PodSpec{Affinity: &corev1.Affinity{PodAntiAffinity: &corev1.PodAntiAffinity{
+					PreferredDuringSchedulingIgnoredDuringExecution: []corev1.WeightedPodAffinityTerm{{
+						Weight: 100,
+						PodAffinityTerm: corev1.PodAffinityTerm{
+							LabelSelector: &metav1.LabelSelector{MatchLabels: map[string]string{"app": "sample-web"}},
+							TopologyKey:   "kubernetes.io/hostname",
+						},
+					}},
+				}}},
+			},
+		},
+		Status: appsv1.DeploymentStatus{Replicas: 3, UpdatedReplicas: 3, AvailableReplicas: 1},
+	}
+	web.Spec.Paused = true
+	web.Status.Conditions = []appsv1.DeploymentCondition{{
+		Type:               appsv1.DeploymentProgressing,
+		Status:             corev1.ConditionUnknown,
+		Reason:             deploymentPausedReason,
+		LastTransitionTime: metav1.Time{Time: now.Add(-3 * time.Hour)},
+	}}
+	api := &appsv1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{Name: "sample-api", Namespace: sampleNamespace, CreationTimestamp: old, UID: "sample-api", Labels: map[string]string{"tier": "sample-api"}},
+		Spec: appsv1.DeploymentSpec{Replicas: &replicas, Template: corev1.PodTemplateSpec{Spec: corev1.PodSpec{Containers: []corev1.Container{{
+			Name: "api",
+			Resources: corev1.ResourceRequirements{
+				Requests: corev1.ResourceList{corev1.ResourceCPU: resource.MustParse("250m"), corev1.ResourceMemory: resource.MustParse("256Mi")},
+			},
+		}}}}},
+		Status: appsv1.DeploymentStatus{Replicas: 3, UpdatedReplicas: 1, AvailableReplicas: 3, Conditions: []appsv1.DeploymentCondition{{
+			Type:    appsv1.DeploymentProgressing,
+			Status:  corev1.ConditionFalse,
+			Reason:  deploymentDeadlineReason,
+			Message: `ReplicaSet "sample-api-7c9f" has timed out progressing.`,
+		}}},
+	}
+	objects = append(objects, api)
+	nightly := &batchv1beta1.CronJob{
+		ObjectMeta: metav1.ObjectMeta{Name: "sample-nightly", Namespace: sampleNamespace, CreationTimestamp: old, UID: "sample-nightly"},
+		Status:     batchv1beta1.CronJobStatus{LastScheduleTime: &metav1.Time{Time: now.Add(-10 * time.Minute)}},
+	}
+	objects = append(objects, nightly, failedCronJobRun(nightly, "sample-nightly-1", now.Add(-40*time.Minute)), failedCronJobRun(nightly, "sample-nightly-2", now.Add(-10*time.Minute)))
+
+	webReplicaSet := ownedReplicaSet(web, "sample-web-3", 2)
+	for _, pod := range []string{"sample-web-2", "sample-web-3"} {
+		objects = append(objects, &corev1.Event{
+			ObjectMeta:     metav1.ObjectMeta{Name: pod + ".probe", Namespace: sampleNamespace},
+			InvolvedObject: corev1.ObjectReference{Kind: "Pod", Namespace: sampleNamespace, Name: pod},
+			Type:           corev1.EventTypeWarning,
+			Reason:         "Unhealthy",
+			Count:          2,
+			FirstTimestamp: metav1.Time{Time: now.Add(-20 * time.Second)},
+			LastTimestamp:  metav1.Time{Time: now.Add(-10 * time.Second)},
+			Message:        "Readiness probe failed: HTTP probe failed with statuscode: 503",
+		})
+	}
+	objects = append(objects,
+		web,
+		ownedReplicaSet(web, "sample-web-1", 0),
+		ownedReplicaSet(web, "sample-web-2", 1),
+		webReplicaSet,
+		&appsv1.ReplicaSet{
+			ObjectMeta: metav1.ObjectMeta{Name: "sample-legacy", Namespace: sampleNamespace, CreationTimestamp: old},
+			Spec:       appsv1.ReplicaSetSpec{Replicas: new(int32)},
+		},
+		oomKilledPod("sample-web-1", sampleNamespace, "sample-web", now.Add(-5*time.Minute), now.Add(-10*time.Minute)),
+		ownedPod(scheduledPod("sample-web-2", sampleNamespace, "sample-web", "sample-node-pleg", corev1.ConditionTrue), webReplicaSet),
+		ownedPod(scheduledPod("sample-web-3", sampleNamespace, "sample-web", "sample-node-pleg", corev1.ConditionTrue), webReplicaSet),
+		scheduledPod("sample-web-4", sampleNamespace, "sample-web", sampleNodeName(corev1.NodeReady), corev1.ConditionTrue),
+		&appsv1.DaemonSet{
+			ObjectMeta: metav1.ObjectMeta{Name: "sample-agent", Namespace: sampleNamespace, CreationTimestamp: old},
+			Status:     appsv1.DaemonSetStatus{CurrentNumberScheduled: 1, NumberAvailable: 2, DesiredNumberScheduled: 3, NumberReady: 1},
+		},
+		&appsv1.StatefulSet{
+			ObjectMeta: metav1.ObjectMeta{Name: "sample-db", Namespace: sampleNamespace, CreationTimestamp: old},
+			Spec:       appsv1.StatefulSetSpec{Replicas: &replicas},
+			Status:     appsv1.StatefulSetStatus{Replicas: 3, ReadyReplicas: 2},
+		},
+		&batchv1.Job{
+			ObjectMeta: metav1.ObjectMeta{Name: "sample-load", Namespace: sampleNamespace, CreationTimestamp: old},
+			Status: batchv1.JobStatus{
+				Failed:     7,
+				Conditions: []batchv1.JobCondition{{Type: batchv1.JobFailed, Status: corev1.ConditionTrue, Reason: jobBackoffLimitReason}},
+			},
+		},
+		&batchv1.Job{
+			ObjectMeta: metav1.ObjectMeta{Name: "sample-report", Namespace: sampleNamespace, CreationTimestamp: old},
+			Status:     batchv1.JobStatus{StartTime: &metav1.Time{Time: now.Add(-2 * time.Hour)}, Active: 1, Failed: 1},
+		},
+		&corev1.PersistentVolumeClaim{
+			ObjectMeta: metav1.ObjectMeta{Name: "sample-data", Namespace: sampleNamespace},
+		},
+		&corev1.PersistentVolumeClaim{
+			ObjectMeta: metav1.ObjectMeta{Name: "sample-unprovisioned", Namespace: sampleNamespace, CreationTimestamp: old},
+			Status:     corev1.PersistentVolumeClaimStatus{Phase: corev1.ClaimPending},
+		},
+		&corev1.PersistentVolumeClaim{
+			ObjectMeta: metav1.ObjectMeta{Name: "sample-fast-data", Namespace: sampleNamespace, CreationTimestamp: metav1.Time{Time: now.Add(-5 * time.Minute)}},
+			Spec:       corev1.PersistentVolumeClaimSpec{StorageClassName: &fastClass},
+			Status:     corev1.PersistentVolumeClaimStatus{Phase: corev1.ClaimPending},
+		},
+		&corev1.PersistentVolumeClaim{
+			ObjectMeta: metav1.ObjectMeta{Name: "sample-backup", Namespace: sampleNamespace, CreationTimestamp: metav1.Time{Time: now.Add(-5 * time.Minute)}},
+			Spec:       corev1.PersistentVolumeClaimSpec{StorageClassName: &archiveClass},
+			Status:     corev1.PersistentVolumeClaimStatus{Phase: corev1.ClaimPending},
+		},
+		&storagev1.StorageClass{
+			ObjectMeta:  metav1.ObjectMeta{Name: fastClass},
+			Provisioner: "ebs.csi.aws.com",
+		},
+		&corev1.PersistentVolumeClaim{
+			ObjectMeta: metav1.ObjectMeta{Name: "sample-lost", Namespace: sampleNamespace, CreationTimestamp: old},
+			Spec:       corev1.PersistentVolumeClaimSpec{VolumeName: "sample-deleted-volume"},
+			Status:     corev1.PersistentVolumeClaimStatus{Phase: corev1.ClaimLost},
+		},
+		&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: sampleNamespace, CreationTimestamp: abandoned}},
+		&corev1.Namespace{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:              "sample-retired",
+				CreationTimestamp: abandoned,
+				DeletionTimestamp: &old,
+				Finalizers:        []string{"backup.example.com/snapshot"},
+			},
+			Spec:   corev1.NamespaceSpec{Finalizers: []corev1.FinalizerName{corev1.FinalizerKubernetes}},
+			Status: corev1.NamespaceStatus{Phase: corev1.NamespaceTerminating},
+		},
+		&corev1.ResourceQuota{
+			ObjectMeta: metav1.ObjectMeta{Name: "sample-compute", Namespace: sampleNamespace},
+			Status: corev1.ResourceQuotaStatus{
+				Hard: corev1.ResourceList{corev1.ResourceRequestsCPU: resource.MustParse("8"), corev1.ResourcePods: resource.MustParse("20")},
+				Used: corev1.ResourceList{corev1.ResourceRequestsCPU: resource.MustParse("7600m"), corev1.ResourcePods: resource.MustParse("12")},
+			},
+		},
+		&corev1.Service{ObjectMeta: metav1.ObjectMeta{Name: "sample-policy", Namespace: sampleNamespace}},
+		&corev1.Service{
+			ObjectMeta: metav1.ObjectMeta{Name: "sample-frontend", Namespace: sampleNamespace, CreationTimestamp: old},
+			Spec:       corev1.ServiceSpec{Selector: map[string]string{"app": "sample-frontnd"}},
+		},
+		&corev1.Service{
+			ObjectMeta: metav1.ObjectMeta{Name: "sample-checkout", Namespace: sampleNamespace, CreationTimestamp: old},
+			Spec:       corev1.ServiceSpec{Selector: map[string]string{"app": "sample-checkout"}},
+		},
+		&corev1.Endpoints{
+			ObjectMeta: metav1.ObjectMeta{Name: "sample-checkout", Namespace: sampleNamespace},
+			Subsets: []corev1.EndpointSubset{{
+				Addresses:         []corev1.EndpointAddress{{IP: "10.1.0.1"}},
+				NotReadyAddresses: []corev1.EndpointAddress{{IP: "10.1.0.2"}, {IP: "10.1.0.3"}, {IP: "10.1.0.4"}},
+			}},
+		},
+		&extensionsv1beta1.Ingress{
+			ObjectMeta: metav1.ObjectMeta{Name: "sample-storefront", Namespace: sampleNamespace, CreationTimestamp: old},
+			Spec: extensionsv1beta1.IngressSpec{
+				Rules: []extensionsv1beta1.IngressRule{{
+					Host: "shop.example.com",
+					IngressRuleValue: extensionsv1beta1.IngressRuleValue{HTTP: &extensionsv1beta1.HTTPIngressRuleValue{
+						Paths: []extensionsv1beta1.HTTPIngressPath{
+							{Path: "/", Backend: extensionsv1beta1.IngressBackend{ServiceName: "sample-frontend"}},
+							{Path: "/checkout", Backend: extensionsv1beta1.IngressBackend{ServiceName: "sample-checkout"}},
+							{Path: "/search", Backend: extensionsv1beta1.IngressBackend{ServiceName: "sample-search"}},
+						},
+					}},
+				}},
+			},
+			Status: extensionsv1beta1.IngressStatus{LoadBalancer: corev1.LoadBalancerStatus{
+				Ingress: []corev1.LoadBalancerIngress{{Hostname: "lb.example.com"}},
+			}},
+		},
+		&extensionsv1beta1.Ingress{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:              "sample-admin",
+				Namespace:         sampleNamespace,
+				CreationTimestamp: old,
+				Annotations:       map[string]string{ingressClassAnnotation: "nginx-internal"},
+			},
+			Spec: extensionsv1beta1.IngressSpec{Backend: &extensionsv1beta1.IngressBackend{ServiceName: "sample-checkout"}},
+		},
+		&autoscalingv2beta2.HorizontalPodAutoscaler{
+			ObjectMeta: metav1.ObjectMeta{Name: "sample-checkout", Namespace: sampleNamespace, CreationTimestamp: old},
+			Spec: autoscalingv2beta2.HorizontalPodAutoscalerSpec{
+				ScaleTargetRef: autoscalingv2beta2.CrossVersionObjectReference{Kind: "Deployment", Name: "sample-checkout"},
+				MaxReplicas:    4,
+			},
+			Status: autoscalingv2beta2.HorizontalPodAutoscalerStatus{
+				CurrentReplicas: 4,
+				LastScaleTime:   &old,
+				Conditions: []autoscalingv2beta2.HorizontalPodAutoscalerCondition{{
+					Type:    autoscalingv2beta2.ScalingLimited,
+					Status:  corev1.ConditionTrue,
+					Reason:  hpaTooManyReplicasReason,
+					Message: "the desired replica count is more than the maximum replica count",
+				}},
+			},
+		},
+		&autoscalingv2beta2.HorizontalPodAutoscaler{
+			ObjectMeta: metav1.ObjectMeta{Name: "sample-search", Namespace: sampleNamespace, CreationTimestamp: old},
+			Spec: autoscalingv2beta2.HorizontalPodAutoscalerSpec{
+				ScaleTargetRef: autoscalingv2beta2.CrossVersionObjectReference{Kind: "Deployment", Name: "sample-search"},
+				MaxReplicas:    8,
+			},
+			Status: autoscalingv2beta2.HorizontalPodAutoscalerStatus{
+				CurrentReplicas: 2,
+				Conditions: []autoscalingv2beta2.HorizontalPodAutoscalerCondition{{
+					Type:    autoscalingv2beta2.ScalingActive,
+					Status:  corev1.ConditionFalse,
+					Reason:  "FailedGetResourceMetric",
+					Message: "the HPA was unable to compute the replica count: missing request for cpu",
+				}},
+			},
+		},
+		&policyv1beta1.PodDisruptionBudget{
+			ObjectMeta: metav1.ObjectMeta{Name: "sample-web", Namespace: sampleNamespace, CreationTimestamp: old},
+			Status: policyv1beta1.PodDisruptionBudgetStatus{
+				PodDisruptionsAllowed: 0,
+				CurrentHealthy:        2,
+				DesiredHealthy:        3,
+				ExpectedPods:          3,
+			},
+		},
+		&corev1.ComponentStatus{
+			ObjectMeta: metav1.ObjectMeta{Name: types.ControlPlaneScheduler},
+			Conditions: []corev1.ComponentCondition{{
+				Type:   corev1.ComponentHealthy,
+				Status: corev1.ConditionFalse,
+				Error:  "Get http://127.0.0.1:10251/healthz: dial tcp 127.0.0.1:10251: connect: connection refused",
+			}},
+		},
+		&corev1.ComponentStatus{
+			ObjectMeta: metav1.ObjectMeta{Name: types.ControlPlaneControllerManager},
+			Conditions: []corev1.ComponentCondition{{Type: corev1.ComponentHealthy, Status: corev1.ConditionTrue, Message: "ok"}},
+		},
+		&corev1.ComponentStatus{
+			ObjectMeta: metav1.ObjectMeta{Name: "etcd-0"},
+			Conditions: []corev1.ComponentCondition{{Type: corev1.ComponentHealthy, Status: corev1.ConditionTrue, Message: `{"health":"true"}`}},
+		},
+		&corev1.ComponentStatus{
+			ObjectMeta: metav1.ObjectMeta{Name: "etcd-1"},
+			Conditions: []corev1.ComponentCondition{{Type: corev1.ComponentHealthy, Status: corev1.ConditionFalse, Error: "context deadline exceeded"}},
+		},
+		&corev1.ComponentStatus{
+			ObjectMeta: metav1.ObjectMeta{Name: "etcd-2"},
+			Conditions: []corev1.ComponentCondition{{Type: corev1.ComponentHealthy, Status: corev1.ConditionTrue, Message: `{"health":"true"}`}},
+		},
+		&admissionv1beta1.ValidatingWebhookConfiguration{
+			ObjectMeta: metav1.ObjectMeta{Name: "sample-policy"},
+			Webhooks: []admissionv1beta1.Webhook{{
+				Name: "validate.sample-policy.example.com",
+				ClientConfig: admissionv1beta1.WebhookClientConfig{
+					Service: &admissionv1beta1.ServiceReference{Namespace: sampleNamespace, Name: "sample-policy"},
+				},
+				FailurePolicy: &failClosed,
+			}},
+		},
+		&corev1.PersistentVolume{
+			ObjectMeta: metav1.ObjectMeta{Name: "sample-orphan", CreationTimestamp: abandoned},
+			Status:     corev1.PersistentVolumeStatus{Phase: corev1.VolumeReleased},
+		},
+		&corev1.Event{
+			ObjectMeta:     metav1.ObjectMeta{Name: "sample-orphan.reclaim", Namespace: metav1.NamespaceDefault},
+			InvolvedObject: corev1.ObjectReference{Kind: "PersistentVolume", Name: "sample-orphan"},
+			Type:           corev1.EventTypeWarning,
+			Reason:         "VolumeFailedDelete",
+			Count:          4,
+			LastTimestamp:  metav1.Time{Time: now.Add(-time.Minute)},
+			Message:        "error deleting volume: disk is still attached",
+		},
+		&corev1.Event{
+			ObjectMeta:     metav1.ObjectMeta{Name: "sample-fast-data.provisioning", Namespace: sampleNamespace},
+			InvolvedObject: corev1.ObjectReference{Kind: "PersistentVolumeClaim", Namespace: sampleNamespace, Name: "sample-fast-data"},
+			Type:           corev1.EventTypeWarning,
+			Reason:         provisioningFailedReason,
+			Count:          5,
+			LastTimestamp:  metav1.Time{Time: now.Add(-time.Minute)},
+			Message:        "failed to provision volume with StorageClass \"sample-fast\": UnauthorizedOperation: not authorized to perform ec2:CreateVolume",
+		},
+		&corev1.Event{
+			ObjectMeta:     metav1.ObjectMeta{Name: "sample-mounting.mount", Namespace: sampleNamespace},
+			InvolvedObject: corev1.ObjectReference{Kind: "Pod", Namespace: sampleNamespace, Name: "sample-mounting"},
+			Type:           corev1.EventTypeWarning,
+			Reason:         "FailedMount",
+			Count:          3,
+			FirstTimestamp: metav1.Time{Time: now.Add(-20 * time.Second)},
+			LastTimestamp:  metav1.Time{Time: now.Add(-5 * time.Second)},
+			Message:        `MountVolume.SetUp failed for volume "tls" : secret "sample-tls" not found`,
+		},
+		&corev1.Secret{ObjectMeta: metav1.ObjectMeta{Name: "sample-orphan", Namespace: sampleNamespace, CreationTimestamp: abandoned}},
+	)
+	return objects
+}
+
+// sampleCustomObjects builds the objects queries.SampleAlerts reads through the dynamic client
+func sampleCustomObjects(now time.Time) []runtime.Object {
+	return []runtime.Object{
+		sampleCSR("sample-csr-worker-1", "system:node:sample-worker-1", now.Add(-2*time.Hour)),
+		sampleCSR("sample-csr-worker-1-retry", "system:node:sample-worker-1", now.Add(-time.Minute)),
+		sampleCSR("sample-csr-worker-2", "system:node:sample-worker-2", now.Add(-time.Minute)),
+		&unstructured.Unstructured{Object: map[string]interface{}{
+			"apiVersion": "cert-manager.io/v1",
+			"kind":       "Certificate",
+			"metadata":   map[string]interface{}{"name": "sample-tls", "namespace": sampleNamespace},
+			"status": map[string]interface{}{
+				"conditions": []interface{}{
+					map[string]interface{}{"type": "Ready", "status": "False", "reason": "Failed"},
+				},
+			},
+		}},
+		&unstructured.Unstructured{Object: map[string]interface{}{
+			"apiVersion": "apps/v1",
+			"kind":       "Deployment",
+			"metadata": map[string]interface{}{
+				"name":      "sample-legacy",
+				"namespace": sampleNamespace,
+				"annotations": map[string]interface{}{
+					lastAppliedAnnotation: `{"apiVersion":"extensions/v1beta1","kind":"Deployment","metadata":{"name":"sample-legacy","namespace":"sample"}}`,
+				},
+			},
+		}},
+	}
+}
+
+// sampleCSR builds a pending kubelet serving CertificateSigningRequest
+func sampleCSR(name string, requestor string, created time.Time) *unstructured.Unstructured {
+	csr := &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "certificates.k8s.io/v1beta1",
+		"kind":       "CertificateSigningRequest",
+		"metadata":   map[string]interface{}{"name": name},
+		"spec":       map[string]interface{}{"username": requestor, "signerName": "kubernetes.io/kubelet-serving"},
+	}}
+	csr.SetCreationTimestamp(metav1.Time{Time: created})
+	return csr
+}
+
+// sampleToken builds an unsigned JWT for subject, which expires at expiry unless it is zero
+func sampleToken(subject string, expiry time.Time) string {
+	claims := fmt.Sprintf(`{"sub":%q}`, subject)
+	if !expiry.IsZero() {
+		claims = fmt.Sprintf(`{"sub":%q,"exp":%d}`, subject, expiry.Unix())
+	}
+	encode := base64.RawURLEncoding.EncodeToString
+	return encode([]byte(`{"alg":"none"}`)) + "." + encode([]byte(claims)) + "." + encode([]byte("signature"))
+}
+
+// sampleCertificate builds a PEM encoded self-signed certificate for commonName valid until notAfter
+func sampleCertificate(commonName string, notAfter time.Time) ([]byte, error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: commonName},
+		NotBefore:    notAfter.Add(-90 * 24 * time.Hour),
+		NotAfter:     notAfter,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		return nil, err
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), nil
+}
+
+func samplePod(name string, created metav1.Time) *corev1.Pod {
+	return &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: sampleNamespace, CreationTimestamp: created},
+	}
+}
+
+// readyNode builds a node whose only condition is Ready with the given status
+func readyNode(name string, status corev1.ConditionStatus) *corev1.Node {
+	return &corev1.Node{
+		ObjectMeta: metav1.ObjectMeta{Name: name},
+		Status: corev1.NodeStatus{
+			Conditions: []corev1.NodeCondition{{Type: corev1.NodeReady, Status: status}},
+		},
+	}
+}
+
+// ownedReplicaSet builds a ReplicaSet of deployment carrying its pod labels and scaled to replicas
+func ownedReplicaSet(deployment *appsv1.Deployment, name string, replicas int32) *appsv1.ReplicaSet {
+	return &appsv1.ReplicaSet{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:            name,
+			Namespace:       deployment.Namespace,
+			Labels:          deployment.Spec.Selector.MatchLabels,
+			Annotations:     map[string]string{deploymentRevisionAnnotation: strings.TrimPrefix(name, deployment.Name+"-")},
+			OwnerReferences: []metav1.OwnerReference{*metav1.NewControllerRef(deployment, appsv1.SchemeGroupVersion.WithKind("Deployment"))},
+		},
+		Spec:   appsv1.ReplicaSetSpec{Replicas: &replicas},
+		Status: appsv1.ReplicaSetStatus{Replicas: replicas},
+	}
+}
+
+// ownedPod makes replicaSet the controller of pod
+func ownedPod(pod *corev1.Pod, replicaSet *appsv1.ReplicaSet) *corev1.Pod {
+	pod.OwnerReferences = []metav1.OwnerReference{*metav1.NewControllerRef(replicaSet, appsv1.SchemeGroupVersion.WithKind("ReplicaSet"))}
+	return pod
+}
+
+// scheduledPod builds a pod labelled app=<app> running on node, whose only condition is Ready with the given status
+func scheduledPod(name string, namespace string, app string, node string, ready corev1.ConditionStatus) *corev1.Pod {
+	return &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: namespace,
+			Labels:    map[string]string{"app": app},
+		},
+		Spec: corev1.PodSpec{NodeName: node},
+		Status: corev1.PodStatus{
+			Conditions: []corev1.PodCondition{{Type: corev1.PodReady, Status: ready}},
+		},
+	}
+}
+
+// oomKilledPod builds a pod labelled app=<app> with one container per finish time, each last OOMKilled at that time
+func oomKilledPod(name string, namespace string, app string, finishedAt ...time.Time) *corev1.Pod {
+	pod := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: namespace,
+			Labels:    map[string]string{"app": app},
+		},
+	}
+	for i, at := range finishedAt {
+		pod.Status.ContainerStatuses = append(pod.Status.ContainerStatuses, corev1.ContainerStatus{
+			Name: fmt.Sprint("container-", i),
+			LastTerminationState: corev1.ContainerState{
+				Terminated: &corev1.ContainerStateTerminated{Reason: "OOMKilled", FinishedAt: metav1.Time{Time: at}},
+			},
+		})
+	}
+	return pod
+}
+
+// failedCronJobRun returns a Job of cronJob created at created that exceeded its backoff limit
+func failedCronJobRun(cronJob *batchv1beta1.CronJob, name string, created time.Time) *batchv1.Job {
+	return &batchv1.Job{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:              name,
+			Namespace:         cronJob.Namespace,
+			CreationTimestamp: metav1.Time{Time: created},
+			OwnerReferences:   []metav1.OwnerReference{{Kind: "CronJob", Name: cronJob.Name, UID: cronJob.UID}},
+		},
+		Status: batchv1.JobStatus{
+			Failed:     1,
+			Conditions: []batchv1.JobCondition{{Type: batchv1.JobFailed, Status: corev1.ConditionTrue, Reason: jobBackoffLimitReason}},
+		},
+	}
+}
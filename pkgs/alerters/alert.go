@@ -18,12 +18,18 @@ import (
 	"log"
 	"os"
 
+	"github.com/bloomberg/k8eraid/pkgs/metrics"
 	"github.com/bloomberg/k8eraid/pkgs/types"
 )
 
 var (
-	logger    *log.Logger
-	errLogger *log.Logger
+	logger        *log.Logger
+	errLogger     *log.Logger
+	alertsCounter = metrics.NewCounterVec(
+		"k8eraid_alerts_total",
+		"Alerts raised by the pollers, including the ones whose delivery was muted.",
+		"alerter_type", "severity", "muted",
+	)
 )
 
 func init() {
@@ -32,13 +38,29 @@ func init() {
 	errLogger = log.New(os.Stderr, "alerters", log.LstdFlags)
 }
 
-// Alert function takes alertType, alertName and alertMessage as inputs, and triggers the correct alert type
+// DefaultAlerter counts every alert and delivers the ones that are not muted. Muted alerts are still
+// counted, but never delivered.
+var DefaultAlerter = Chain(AlerterFunc(Dispatch), CountAlerts, MuteAlerts)
+
+// Alert function takes alertType, alertName and alert as inputs, and triggers the correct alert type
+// through DefaultAlerter
 func Alert(
 	alertType string,
 	alertName string,
-	alertMessage string,
+	alert types.Alert,
+	config types.AlertersConfig,
+) {
+	DefaultAlerter.Alert(alertType, alertName, alert, config)
+}
+
+// Dispatch delivers alert to every alerter of alertType named alertName, with no middleware
+func Dispatch(
+	alertType string,
+	alertName string,
+	alert types.Alert,
 	config types.AlertersConfig,
 ) {
+	alertMessage := alert.Message
 
 	// if alert type is stderr or blank, alert to stderr
 	if alertType == "stderr" || alertType == "" {
@@ -49,7 +71,11 @@ func Alert(
 	if alertType == "smtp" {
 		for _, alertRules := range config.Types.SMTPAlerterList {
 			if alertRules.Name == alertName {
-				AlertSMTP(alertRules, alertMessage)
+				title, body := renderAlert(alertRules.AlertTemplates, alert)
+				if title != "" {
+					alertRules.Subject = title
+				}
+				AlertSMTP(alertRules, body)
 			}
 		}
 	}
@@ -58,7 +84,11 @@ func Alert(
 	if alertType == "pagerdutyV2" {
 		for _, alertRules := range config.Types.PDAlerterList {
 			if alertRules.Name == alertName {
-				AlertPagerDuty(alertRules, alertMessage)
+				title, body := renderAlert(alertRules.AlertTemplates, alert)
+				if title != "" {
+					alertRules.Subject = title
+				}
+				AlertPagerDutyWithOwners(alertRules, body, alert.Owners)
 			}
 		}
 	}
@@ -67,14 +97,59 @@ func Alert(
 	if alertType == "webhook" {
 		for _, alertRules := range config.Types.WebhookAlerterList {
 			if alertRules.Name == alertName {
-				AlertWebhook(alertRules, alertMessage)
+				title, body := renderAlert(alertRules.AlertTemplates, alert)
+				if title != "" {
+					alertRules.Subject = title
+				}
+				AlertWebhookWithOwners(alertRules, body, alert.Owners)
 			}
 		}
 	}
+
+	// if alert type is slack, find matching rule and post the message
 	if alertType == "slack" {
 		for _, alertRules := range config.Types.SlackAlerterList {
 			if alertRules.Name == alertName {
-				AlertSlack(alertRules, alertMessage)
+				title, body := renderAlert(alertRules.AlertTemplates, alert)
+				AlertSlackWithMention(alertRules, title, body, alert.Mention)
+			}
+		}
+	}
+
+	// if alert type is telegram, find matching rule and send the message
+	if alertType == "telegram" {
+		for _, alertRules := range config.Types.TelegramAlerterList {
+			if alertRules.Name == alertName {
+				title, body := renderAlert(alertRules.AlertTemplates, alert)
+				if title != "" {
+					alertRules.Subject = title
+				}
+				rendered := alert
+				rendered.Message = body
+				AlertTelegram(alertRules, rendered)
+			}
+		}
+	}
+
+	// if alert type is csv, find matching rule and append the alert to the day's file
+	if alertType == "csv" {
+		for _, alertRules := range config.Types.CSVAlerterList {
+			if alertRules.Name == alertName {
+				title, body := renderAlert(alertRules.AlertTemplates, alert)
+				rendered := alert
+				rendered.Message = body
+				AlertCSV(alertRules, title, rendered)
+			}
+		}
+	}
+
+	// if alert type is sql, find matching rule and record the alert
+	if alertType == "sql" {
+		for _, alertRules := range config.Types.SQLAlerterList {
+			if alertRules.Name == alertName {
+				rendered := alert
+				rendered.Message = textAlert(renderAlert(alertRules.AlertTemplates, alert))
+				AlertSQL(alertRules, rendered)
 			}
 		}
 	}
@@ -0,0 +1,227 @@
+// Copyright 2019 Bloomberg Finance LP
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package alerters
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/bloomberg/k8eraid/pkgs/types"
+)
+
+const (
+	defaultCSVFilePrefix    = "k8eraid-alerts"
+	defaultCSVFlushInterval = 5
+	csvDateLayout           = "2006-01-02"
+)
+
+var (
+	csvSinksMu sync.Mutex
+	csvSinks   = map[string]*csvSink{}
+	// csvHeader names the columns so the files import into spreadsheets and BI tools as they are
+	csvHeader = []string{"time", "date", "severity", "resource_type", "resource", "check", "title", "message", "alert_key", "resolved"}
+)
+
+// csvSink owns the file of the current day of a single csv alerter
+type csvSink struct {
+	mu     sync.Mutex
+	config types.CSVAlerterConfig
+	date   string
+	file   *os.File
+	writer *csv.Writer
+	// retired is set once a reload replaces the sink, after which it never reopens a file
+	retired bool
+	stop    chan struct{}
+}
+
+var errCSVSinkRetired = errors.New("csv sink was replaced by a reload")
+
+// AlertCSV appends an alert as one row to the csv file of the alert's day, in UTC
+func AlertCSV(alertdata types.CSVAlerterConfig, title string, alert types.Alert) {
+	err := errCSVSinkRetired
+	for err == errCSVSinkRetired {
+		err = getCSVSink(alertdata).write(title, alert)
+	}
+	if err != nil {
+		errLogger.Printf("csv alerter %s dropped alert %s: %s", alertdata.Name, alert.Key, err.Error())
+	}
+}
+
+// FlushCSV writes out the buffered rows of every csv alerter and closes their files, for shutdown.
+// A later alert reopens its file.
+func FlushCSV() {
+	csvSinksMu.Lock()
+	defer csvSinksMu.Unlock()
+	for name, sink := range csvSinks {
+		if err := sink.close(); err != nil {
+			errLogger.Printf("csv alerter %s unable to flush: %s", name, err.Error())
+		}
+	}
+}
+
+func getCSVSink(alertdata types.CSVAlerterConfig) *csvSink {
+	csvSinksMu.Lock()
+	defer csvSinksMu.Unlock()
+
+	// Sinks are keyed by name, so a reload that changes an alerter replaces its sink instead of
+	// leaving the old file and flush goroutine open
+	if sink, ok := csvSinks[alertdata.Name]; ok {
+		if sink.config == alertdata {
+			return sink
+		}
+		sink.retire()
+	}
+	sink := &csvSink{config: alertdata, stop: make(chan struct{})}
+	csvSinks[alertdata.Name] = sink
+	go sink.flushEvery(alertdata.FlushInterval)
+	return sink
+}
+
+// flushEvery flushes the buffered rows on an interval, so the file stays current without
+// syncing every row
+func (s *csvSink) flushEvery(interval int64) {
+	if interval <= 0 {
+		interval = defaultCSVFlushInterval
+	}
+	ticker := time.NewTicker(time.Duration(interval) * time.Second)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+		case <-s.stop:
+			return
+		}
+		s.mu.Lock()
+		if s.writer != nil {
+			s.writer.Flush()
+			if err := s.writer.Error(); err != nil {
+				errLogger.Printf("csv alerter %s unable to flush: %s", s.config.Name, err.Error())
+			}
+		}
+		s.mu.Unlock()
+	}
+}
+
+func (s *csvSink) write(title string, alert types.Alert) error {
+	at := alert.Time
+	if at.IsZero() {
+		at = time.Now()
+	}
+	at = at.UTC()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.retired {
+		return errCSVSinkRetired
+	}
+	if err := s.rotate(at.Format(csvDateLayout)); err != nil {
+		return err
+	}
+	check := strings.TrimPrefix(alert.Key, alert.Resource+"/")
+	row := []string{
+		at.Format(time.RFC3339),
+		at.Format(csvDateLayout),
+		alert.Severity,
+		strings.SplitN(alert.Resource, "/", 2)[0],
+		alert.Resource,
+		check,
+		title,
+		alert.Message,
+		alert.Key,
+		strconv.FormatBool(alert.Resolved),
+	}
+	for i := range row {
+		row[i] = csvCell(row[i])
+	}
+	return s.writer.Write(row)
+}
+
+// rotate makes the file of date the current one, creating it with its header row if needed
+func (s *csvSink) rotate(date string) error {
+	if s.file != nil && s.date == date {
+		return nil
+	}
+	if err := s.closeLocked(); err != nil {
+		errLogger.Printf("csv alerter %s unable to close the file of %s: %s", s.config.Name, s.date, err.Error())
+	}
+
+	prefix := s.config.FilePrefix
+	if prefix == "" {
+		prefix = defaultCSVFilePrefix
+	}
+	path := filepath.Join(s.config.Directory, fmt.Sprintf("%s-%s.csv", prefix, date))
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		return fmt.Errorf("unable to open %s: %s", path, err.Error())
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return fmt.Errorf("unable to stat %s: %s", path, err.Error())
+	}
+	s.file, s.writer, s.date = file, csv.NewWriter(file), date
+	if info.Size() == 0 {
+		if err := s.writer.Write(csvHeader); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// retire flushes and closes the file of a replaced sink and stops its flush goroutine
+func (s *csvSink) retire() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.retired = true
+	close(s.stop)
+	if err := s.closeLocked(); err != nil {
+		errLogger.Printf("csv alerter %s unable to flush: %s", s.config.Name, err.Error())
+	}
+}
+
+func (s *csvSink) close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.closeLocked()
+}
+
+func (s *csvSink) closeLocked() error {
+	if s.file == nil {
+		return nil
+	}
+	s.writer.Flush()
+	err := s.writer.Error()
+	if closeErr := s.file.Close(); err == nil {
+		err = closeErr
+	}
+	s.file, s.writer = nil, nil
+	return err
+}
+
+// csvCell keeps a value starting like a formula from being evaluated when the file is opened in a
+// spreadsheet, since messages carry object names taken from the cluster
+func csvCell(value string) string {
+	if value != "" && strings.ContainsRune("=+-@\t\r", rune(value[0])) {
+		return "'" + value
+	}
+	return value
+}
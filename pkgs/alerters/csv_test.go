@@ -0,0 +1,134 @@
+// Copyright 2019 Bloomberg Finance LP
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package alerters
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/bloomberg/k8eraid/pkgs/types"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func readCSV(t *testing.T, path string) [][]string {
+	file, err := os.Open(path)
+	require.NoError(t, err)
+	defer file.Close()
+	rows, err := csv.NewReader(file).ReadAll()
+	require.NoError(t, err)
+	return rows
+}
+
+func Test_AlertCSV(t *testing.T) {
+	dir, err := ioutil.TempDir("", "k8eraid-csv")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	config := types.CSVAlerterConfig{Name: "report", Directory: dir, FilePrefix: "alerts"}
+
+	day := time.Date(2019, 3, 1, 23, 59, 0, 0, time.UTC)
+	AlertCSV(config, "Node not ready", types.Alert{
+		Key:      "node/worker-1/Ready",
+		Resource: "node/worker-1",
+		Severity: types.SeverityCritical,
+		Message:  "Node worker-1, is not ready",
+		Time:     day,
+	})
+	AlertCSV(config, "", types.Alert{
+		Key:      "pod/default/=cmd/Ready",
+		Resource: "pod/default/=cmd",
+		Severity: types.SeverityWarning,
+		Message:  "=HYPERLINK(\"http://example.com\")",
+		Time:     day.Add(2 * time.Minute),
+	})
+	FlushCSV()
+
+	assert.Equal(t, [][]string{
+		csvHeader,
+		{"2019-03-01T23:59:00Z", "2019-03-01", "critical", "node", "node/worker-1", "Ready", "Node not ready", "Node worker-1, is not ready", "node/worker-1/Ready", "false"},
+	}, readCSV(t, filepath.Join(dir, "alerts-2019-03-01.csv")))
+	assert.Equal(t, [][]string{
+		csvHeader,
+		{"2019-03-02T00:01:00Z", "2019-03-02", "warning", "pod", "pod/default/=cmd", "Ready", "", "'=HYPERLINK(\"http://example.com\")", "pod/default/=cmd/Ready", "false"},
+	}, readCSV(t, filepath.Join(dir, "alerts-2019-03-02.csv")), "a new day should rotate the file and escape formulas")
+
+	AlertCSV(config, "", types.Alert{Key: "node/worker-2/Ready", Resource: "node/worker-2", Time: day.Add(3 * time.Minute)})
+	FlushCSV()
+	rows := readCSV(t, filepath.Join(dir, "alerts-2019-03-02.csv"))
+	assert.Equal(t, 3, len(rows), "reopening a file should append without repeating the header")
+}
+
+func Test_AlertCSV_ReloadReplacesSink(t *testing.T) {
+	dir, err := ioutil.TempDir("", "k8eraid-csv")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	config := types.CSVAlerterConfig{Name: "reloaded", Directory: dir, FilePrefix: "before"}
+
+	day := time.Date(2019, 3, 1, 12, 0, 0, 0, time.UTC)
+	AlertCSV(config, "", types.Alert{Key: "node/worker-1/Ready", Resource: "node/worker-1", Time: day})
+	old := getCSVSink(config)
+
+	config.FilePrefix = "after"
+	AlertCSV(config, "", types.Alert{Key: "node/worker-2/Ready", Resource: "node/worker-2", Time: day})
+	old.mu.Lock()
+	assert.True(t, old.retired)
+	assert.Nil(t, old.file, "the replaced sink should close its file")
+	old.mu.Unlock()
+	FlushCSV()
+
+	assert.Equal(t, 2, len(readCSV(t, filepath.Join(dir, "before-2019-03-01.csv"))))
+	assert.Equal(t, 2, len(readCSV(t, filepath.Join(dir, "after-2019-03-01.csv"))))
+}
+
+func Test_AlertCSV_concurrent(t *testing.T) {
+	dir, err := ioutil.TempDir("", "k8eraid-csv")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	config := types.CSVAlerterConfig{Name: "concurrent", Directory: dir}
+
+	day := time.Date(2019, 3, 1, 12, 0, 0, 0, time.UTC)
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < 10; j++ {
+				resource := fmt.Sprintf("node/worker-%d-%d", i, j)
+				AlertCSV(config, "", types.Alert{Key: resource + "/Ready", Resource: resource, Message: "not ready", Time: day})
+			}
+		}(i)
+	}
+	wg.Wait()
+	FlushCSV()
+
+	rows := readCSV(t, filepath.Join(dir, defaultCSVFilePrefix+"-2019-03-01.csv"))
+	assert.Equal(t, 201, len(rows))
+	assert.Equal(t, csvHeader, rows[0])
+}
+
+func Test_csvCell(t *testing.T) {
+	assert.Equal(t, "plain text", csvCell("plain text"))
+	assert.Equal(t, "", csvCell(""))
+	for _, value := range []string{"=1+1", "+1", "-1", "@SUM(A1)"} {
+		assert.Equal(t, "'"+value, csvCell(value))
+	}
+}
@@ -0,0 +1,91 @@
+// Copyright 2019 Bloomberg Finance LP
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package alerters
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/bloomberg/k8eraid/pkgs/secrets"
+)
+
+// newHTTPClient builds the http client used by the http based alerters. Requests go through
+// proxyServer when it is set, and otherwise through the proxy HTTP_PROXY, HTTPS_PROXY and NO_PROXY
+// select. proxyServer may reference secrets, and credentials in it authenticate with the proxy.
+func newHTTPClient(proxyServer string) (*http.Client, error) {
+	transport := &http.Transport{
+		Dial: (&net.Dialer{
+			Timeout: 5 * time.Second,
+		}).Dial,
+		TLSHandshakeTimeout: 5 * time.Second,
+		Proxy:               http.ProxyFromEnvironment,
+	}
+	if proxyServer != "" {
+		expanded, err := secrets.Expand(proxyServer)
+		if err != nil {
+			return nil, fmt.Errorf("unresolvable proxy server: %s", err.Error())
+		}
+		proxyURL, err := url.Parse(expanded)
+		if err != nil || proxyURL.Host == "" {
+			// not repeating the URL, which may hold the proxy credentials
+			return nil, fmt.Errorf("invalid proxy server, expected a URL such as http://proxy:3128")
+		}
+		transport.Proxy = http.ProxyURL(proxyURL)
+	}
+	return &http.Client{
+		Timeout:   10 * time.Second,
+		Transport: transport,
+	}, nil
+}
+
+// redactURLError replaces the URL a failed request quotes in its error with the host of target.
+// The path and query of alerter URLs carry secrets, such as Telegram bot tokens and the secret
+// of Slack webhooks, and the error ends up in the log.
+func redactURLError(err error, target string) error {
+	urlErr, ok := err.(*url.Error)
+	if !ok {
+		return err
+	}
+	host := "the alerter"
+	if parsed, parseErr := url.Parse(target); parseErr == nil && parsed.Host != "" {
+		host = parsed.Host
+	}
+	return fmt.Errorf("%s to %s failed: %s", urlErr.Op, host, urlErr.Err.Error())
+}
+
+// postJSON posts payload to target and returns an error for any non-2xx response
+func postJSON(client *http.Client, target string, payload interface{}) error {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	resp, err := client.Post(target, "application/json", bytes.NewBuffer(data))
+	if err != nil {
+		return redactURLError(err, target)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		body, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("HTTP Status Code: %d: %s", resp.StatusCode, bytes.TrimSpace(body))
+	}
+	return nil
+}
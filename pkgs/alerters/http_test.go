@@ -0,0 +1,91 @@
+// Copyright 2019 Bloomberg Finance LP
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package alerters
+
+import (
+	"encoding/base64"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_newHTTPClient_Proxy(t *testing.T) {
+	var proxied []string
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		proxied = append(proxied, r.Method+" "+r.URL.String()+" "+r.Header.Get("Proxy-Authorization"))
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer proxy.Close()
+	credentials := "Basic " + base64.StdEncoding.EncodeToString([]byte("alerts:s3cret"))
+	authenticated := strings.Replace(proxy.URL, "http://", "http://alerts:s3cret@", 1)
+	os.Setenv("K8ERAID_TEST_PROXY", authenticated)
+	defer os.Unsetenv("K8ERAID_TEST_PROXY")
+
+	tests := []struct {
+		name        string
+		proxyServer string
+		expected    string
+	}{
+		{name: "proxy", proxyServer: proxy.URL, expected: "POST http://alerts.example.com/hook "},
+		{name: "proxy with credentials", proxyServer: authenticated, expected: "POST http://alerts.example.com/hook " + credentials},
+		{name: "proxy from a secret reference", proxyServer: "env:K8ERAID_TEST_PROXY", expected: "POST http://alerts.example.com/hook " + credentials},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(subT *testing.T) {
+			proxied = nil
+			client, err := newHTTPClient(test.proxyServer)
+			require.NoError(subT, err)
+			require.NoError(subT, postJSON(client, "http://alerts.example.com/hook", map[string]string{"text": "foo"}))
+			assert.Equal(subT, []string{test.expected}, proxied)
+		})
+	}
+}
+
+// failingTransport fails every request, the way an unreachable server does
+type failingTransport struct{}
+
+func (failingTransport) RoundTrip(_ *http.Request) (*http.Response, error) {
+	return nil, errors.New("connection refused")
+}
+
+func Test_postJSON_ErrorHidesTarget(t *testing.T) {
+	client := &http.Client{Transport: failingTransport{}}
+	for _, target := range []string{
+		"https://api.telegram.org/bot123456:s3cret/sendMessage",
+		"https://hooks.slack.com/services/T000/B000/s3cret",
+		"https://alerts.example.com/hook?token=s3cret",
+	} {
+		err := postJSON(client, target, map[string]string{"text": "foo"})
+		if assert.Error(t, err, target) {
+			assert.NotContains(t, err.Error(), "s3cret", "the error should not leak the secret in the URL")
+			assert.Contains(t, err.Error(), "connection refused")
+		}
+	}
+}
+
+func Test_newHTTPClient_InvalidProxy(t *testing.T) {
+	for _, proxyServer := range []string{"proxy:3128", "http://alerts:s3cret@%zz", "env:K8ERAID_TEST_UNSET"} {
+		_, err := newHTTPClient(proxyServer)
+		if assert.Error(t, err, proxyServer) {
+			assert.NotContains(t, err.Error(), "s3cret", "the error should not leak the proxy credentials")
+		}
+	}
+}
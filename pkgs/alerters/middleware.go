@@ -0,0 +1,70 @@
+// Copyright 2019 Bloomberg Finance LP
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package alerters
+
+import (
+	"strconv"
+
+	"github.com/bloomberg/k8eraid/pkgs/types"
+)
+
+// Alerter delivers an alert to the alerter of alertType named alertName
+type Alerter interface {
+	Alert(alertType string, alertName string, alert types.Alert, config types.AlertersConfig)
+}
+
+// AlerterFunc adapts an alert function, such as the ones the pollers take, to an Alerter
+type AlerterFunc func(alertType string, alertName string, alert types.Alert, config types.AlertersConfig)
+
+// Alert calls f
+func (f AlerterFunc) Alert(alertType string, alertName string, alert types.Alert, config types.AlertersConfig) {
+	f(alertType, alertName, alert, config)
+}
+
+// Middleware wraps an Alerter with one cross-cutting concern of delivery, such as muting or
+// counting. It may change the alert, drop it, or pass it on to next.
+type Middleware func(next Alerter) Alerter
+
+// Chain wraps base with middlewares. The first middleware is the outermost one, so it sees every
+// alert before the others do.
+func Chain(base Alerter, middlewares ...Middleware) Alerter {
+	alerter := base
+	for i := len(middlewares) - 1; i >= 0; i-- {
+		alerter = middlewares[i](alerter)
+	}
+	return alerter
+}
+
+// Discard is an Alerter that delivers nothing
+var Discard Alerter = AlerterFunc(func(string, string, types.Alert, types.AlertersConfig) {})
+
+// CountAlerts counts every alert in k8eraid_alerts_total, labelled with whether delivery is muted
+func CountAlerts(next Alerter) Alerter {
+	return AlerterFunc(func(alertType string, alertName string, alert types.Alert, config types.AlertersConfig) {
+		_, muted := MutedUntil()
+		alertsCounter.With(alertType, alert.Severity, strconv.FormatBool(muted)).Inc()
+		next.Alert(alertType, alertName, alert, config)
+	})
+}
+
+// MuteAlerts drops every alert while delivery is muted with MuteAll
+func MuteAlerts(next Alerter) Alerter {
+	return AlerterFunc(func(alertType string, alertName string, alert types.Alert, config types.AlertersConfig) {
+		if _, muted := MutedUntil(); muted {
+			return
+		}
+		next.Alert(alertType, alertName, alert, config)
+	})
+}
@@ -0,0 +1,81 @@
+// Copyright 2019 Bloomberg Finance LP
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package alerters
+
+import (
+	"testing"
+	"time"
+
+	"github.com/bloomberg/k8eraid/pkgs/types"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_Chain(t *testing.T) {
+	var calls []string
+	tag := func(name string) Middleware {
+		return func(next Alerter) Alerter {
+			return AlerterFunc(func(alertType string, alertName string, alert types.Alert, config types.AlertersConfig) {
+				calls = append(calls, name)
+				alert.Message += " " + name
+				next.Alert(alertType, alertName, alert, config)
+			})
+		}
+	}
+	var delivered types.Alert
+	base := AlerterFunc(func(_ string, _ string, alert types.Alert, _ types.AlertersConfig) {
+		delivered = alert
+	})
+
+	Chain(base, tag("outer"), tag("inner")).Alert("stderr", "", types.Alert{Message: "message"}, types.AlertersConfig{})
+	assert.Equal(t, []string{"outer", "inner"}, calls)
+	assert.Equal(t, "message outer inner", delivered.Message)
+
+	calls = nil
+	Chain(base).Alert("stderr", "", types.Alert{Message: "bare"}, types.AlertersConfig{})
+	assert.Empty(t, calls)
+	assert.Equal(t, "bare", delivered.Message)
+}
+
+func Test_MuteAlerts(t *testing.T) {
+	defer Unmute("test cleanup")
+	delivered := 0
+	alerter := MuteAlerts(AlerterFunc(func(string, string, types.Alert, types.AlertersConfig) {
+		delivered++
+	}))
+
+	alerter.Alert("stderr", "", types.Alert{}, types.AlertersConfig{})
+	MuteAll(time.Minute, "test")
+	alerter.Alert("stderr", "", types.Alert{}, types.AlertersConfig{})
+	Unmute("test")
+	alerter.Alert("stderr", "", types.Alert{}, types.AlertersConfig{})
+	assert.Equal(t, 2, delivered)
+}
+
+func Test_CountAlerts(t *testing.T) {
+	defer Unmute("test cleanup")
+	counter := func(muted string) float64 {
+		return alertsCounter.With("count-test", types.SeverityWarning, muted).Value()
+	}
+	alerter := Chain(Discard, CountAlerts, MuteAlerts)
+	alert := types.Alert{Severity: types.SeverityWarning}
+
+	alerter.Alert("count-test", "", alert, types.AlertersConfig{})
+	MuteAll(time.Minute, "test")
+	alerter.Alert("count-test", "", alert, types.AlertersConfig{})
+	alerter.Alert("count-test", "", alert, types.AlertersConfig{})
+	assert.Equal(t, 1.0, counter("false"))
+	assert.Equal(t, 2.0, counter("true"), "muted alerts should still be counted")
+}
@@ -0,0 +1,64 @@
+// Copyright 2019 Bloomberg Finance LP
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package alerters
+
+import (
+	"sync"
+	"time"
+)
+
+var (
+	muteMu     sync.Mutex
+	mutedUntil time.Time
+	unmuteTime *time.Timer
+)
+
+// MuteAll suppresses delivery of every alert for d. Alerts are still counted in metrics.
+// Calling it again replaces the previous mute.
+func MuteAll(d time.Duration, reason string) time.Time {
+	muteMu.Lock()
+	defer muteMu.Unlock()
+
+	mutedUntil = time.Now().Add(d)
+	if unmuteTime != nil {
+		unmuteTime.Stop()
+	}
+	until := mutedUntil
+	unmuteTime = time.AfterFunc(d, func() {
+		logger.Printf("AUDIT: alert delivery automatically unmuted, mute set until %s expired", until.Format(time.RFC3339))
+	})
+	logger.Printf("AUDIT: all alert delivery muted until %s: %s", until.Format(time.RFC3339), reason)
+	return until
+}
+
+// Unmute resumes alert delivery immediately
+func Unmute(reason string) {
+	muteMu.Lock()
+	defer muteMu.Unlock()
+
+	if unmuteTime != nil {
+		unmuteTime.Stop()
+		unmuteTime = nil
+	}
+	mutedUntil = time.Time{}
+	logger.Printf("AUDIT: alert delivery unmuted: %s", reason)
+}
+
+// MutedUntil returns when the current mute expires, and whether delivery is muted at all
+func MutedUntil() (time.Time, bool) {
+	muteMu.Lock()
+	defer muteMu.Unlock()
+	return mutedUntil, time.Now().Before(mutedUntil)
+}
@@ -15,28 +15,41 @@
 package alerters
 
 import (
-	"net"
 	"net/http"
-	"net/url"
-	"os"
 	"time"
 
 	pagerduty "github.com/PagerDuty/go-pagerduty"
+	"github.com/bloomberg/k8eraid/pkgs/secrets"
 	"github.com/bloomberg/k8eraid/pkgs/types"
 )
 
 // AlertPagerDuty triggers Pager Duty alerts via the v2API using data relayed from alerts.go
 func AlertPagerDuty(alertdata types.PDAlerterConfig, message string) {
-	myEvent, myClient := PagerDutyInput(alertdata, message)
+	AlertPagerDutyWithOwners(alertdata, message, nil)
+}
+
+// AlertPagerDutyWithOwners triggers a pagerduty alert whose details carry the owners of the object alerted on
+func AlertPagerDutyWithOwners(alertdata types.PDAlerterConfig, message string, owners map[string]string) {
+	myEvent, myClient := pagerDutyInput(alertdata, message, owners)
+	if myClient == nil {
+		return
+	}
 	resp := PagerDutyTrigger(myEvent, myClient)
 	logger.Print(resp)
 }
 
-// PagerDutyInput generates the formatted alert inputs for triggering a pagerduty alert
+// PagerDutyInput generates the formatted alert inputs for triggering a pagerduty alert. The client
+// is nil when the alerter's proxy server is unusable.
 func PagerDutyInput(a types.PDAlerterConfig, m string) (pagerduty.Event, *http.Client) {
-	// Get key from ENV that was specified
-	keyenvvar := a.ServiceKeyEnvVar
-	key := os.Getenv(keyenvvar)
+	return pagerDutyInput(a, m, nil)
+}
+
+func pagerDutyInput(a types.PDAlerterConfig, m string, owners map[string]string) (pagerduty.Event, *http.Client) {
+	// Get key from the ENV var or secret reference that was specified
+	key, err := secrets.Lookup(a.ServiceKeyEnvVar)
+	if err != nil {
+		errLogger.Print("Unable to resolve Pager Duty service key: ", err)
+	}
 	mytime := time.Now().Local()
 
 	// Specify alert details
@@ -44,6 +57,7 @@ func PagerDutyInput(a types.PDAlerterConfig, m string) (pagerduty.Event, *http.C
 		Subject: a.Subject,
 		Message: m,
 		Time:    mytime,
+		Owners:  owners,
 	}
 
 	// Construct event
@@ -54,34 +68,10 @@ func PagerDutyInput(a types.PDAlerterConfig, m string) (pagerduty.Event, *http.C
 		Details:     D,
 	}
 
-	var myTransport *http.Transport
-	const (
-		timeout5  = 5 * time.Second
-		timeout10 = 10 * time.Second
-	)
-
 	// Set http proxy and custom http client
-	if a.ProxyServer != "" {
-		proxyURL, _ := url.Parse(a.ProxyServer)
-		myTransport = &http.Transport{
-			Dial: (&net.Dialer{
-				Timeout: timeout5,
-			}).Dial,
-			TLSHandshakeTimeout: timeout5,
-			Proxy:               http.ProxyURL(proxyURL),
-		}
-	} else {
-		myTransport = &http.Transport{
-			Dial: (&net.Dialer{
-				Timeout: timeout5,
-			}).Dial,
-			TLSHandshakeTimeout: timeout5,
-		}
-	}
-
-	var myClient = &http.Client{
-		Timeout:   timeout10,
-		Transport: myTransport,
+	myClient, err := newHTTPClient(a.ProxyServer)
+	if err != nil {
+		errLogger.Printf("Pager Duty alerter %s: %s", a.Name, err.Error())
 	}
 	return event, myClient
 }
@@ -0,0 +1,182 @@
+// Copyright 2019 Bloomberg Finance LP
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package alerters
+
+import (
+	"sync"
+	"time"
+
+	"github.com/bloomberg/k8eraid/pkgs/metrics"
+	"github.com/bloomberg/k8eraid/pkgs/types"
+)
+
+const defaultQueueCapacity = 1000
+
+var (
+	queueDepthGauge = metrics.NewGaugeVec(
+		"k8eraid_alert_queue_depth",
+		"Alerts waiting in the delivery queue for their turn under the delivery rate limit.",
+		"priority",
+	)
+	queueDroppedCounter = metrics.NewCounterVec(
+		"k8eraid_alert_queue_dropped_total",
+		"Alerts dropped because the delivery queue was full.",
+		"priority",
+	)
+)
+
+// queuePriorities are the priorities of the queue, highest first. Alerts of any other severity are
+// queued as info.
+var queuePriorities = []string{types.SeverityCritical, types.SeverityWarning, types.SeverityInfo}
+
+func queuePriority(severity string) int {
+	for i, priority := range queuePriorities {
+		if severity == priority {
+			return i
+		}
+	}
+	return len(queuePriorities) - 1
+}
+
+// queuedAlert is one call of an Alerter waiting for its turn
+type queuedAlert struct {
+	next      Alerter
+	alertType string
+	alertName string
+	alert     types.Alert
+	config    types.AlertersConfig
+}
+
+// PriorityQueue delivers alerts at most one per interval, highest severity first. Alerts of the
+// same severity are delivered in the order they were raised, and a delivery in progress is never
+// interrupted. When the queue is full, a new alert replaces the most recently queued alert of a
+// lower severity, or is dropped if there is none.
+type PriorityQueue struct {
+	mu       sync.Mutex
+	queues   [][]queuedAlert
+	queued   int
+	capacity int
+	interval time.Duration
+	wake     chan struct{}
+}
+
+// NewPriorityQueue returns a PriorityQueue delivering at most perMinute alerts a minute, and starts
+// its delivery goroutine
+func NewPriorityQueue(perMinute int, capacity int) *PriorityQueue {
+	if capacity <= 0 {
+		capacity = defaultQueueCapacity
+	}
+	q := &PriorityQueue{
+		queues:   make([][]queuedAlert, len(queuePriorities)),
+		capacity: capacity,
+		interval: time.Minute / time.Duration(perMinute),
+		wake:     make(chan struct{}, 1),
+	}
+	for _, priority := range queuePriorities {
+		queueDepthGauge.With(priority).Set(0)
+	}
+	go q.run()
+	return q
+}
+
+// Middleware is an alerters.Middleware queueing every alert before passing it on to next
+func (q *PriorityQueue) Middleware(next Alerter) Alerter {
+	return AlerterFunc(func(alertType string, alertName string, alert types.Alert, config types.AlertersConfig) {
+		q.push(queuedAlert{next: next, alertType: alertType, alertName: alertName, alert: alert, config: config})
+	})
+}
+
+func (q *PriorityQueue) push(item queuedAlert) {
+	priority := queuePriority(item.alert.Severity)
+	q.mu.Lock()
+	if q.queued >= q.capacity {
+		evicted := q.evict(priority)
+		if evicted < 0 {
+			q.mu.Unlock()
+			queueDroppedCounter.With(queuePriorities[priority]).Inc()
+			errLogger.Printf("alert delivery queue is full, dropping alert %s", item.alert.Key)
+			return
+		}
+		queueDroppedCounter.With(queuePriorities[evicted]).Inc()
+	}
+	q.queues[priority] = append(q.queues[priority], item)
+	q.queued++
+	q.setDepths()
+	q.mu.Unlock()
+
+	select {
+	case q.wake <- struct{}{}:
+	default:
+	}
+}
+
+// evict drops the most recently queued alert of the lowest priority below priority and returns
+// that priority, or -1 when no alert of a lower priority is queued. q.mu must be held.
+func (q *PriorityQueue) evict(priority int) int {
+	for lower := len(q.queues) - 1; lower > priority; lower-- {
+		if n := len(q.queues[lower]); n > 0 {
+			errLogger.Printf("alert delivery queue is full, dropping alert %s for a more severe one", q.queues[lower][n-1].alert.Key)
+			q.queues[lower] = q.queues[lower][:n-1]
+			q.queued--
+			return lower
+		}
+	}
+	return -1
+}
+
+// pop takes the oldest alert of the highest priority off the queue
+func (q *PriorityQueue) pop() (queuedAlert, bool) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	for priority, queue := range q.queues {
+		if len(queue) > 0 {
+			item := queue[0]
+			queue[0] = queuedAlert{}
+			q.queues[priority] = queue[1:]
+			q.queued--
+			q.setDepths()
+			return item, true
+		}
+	}
+	return queuedAlert{}, false
+}
+
+// setDepths publishes the depth of every priority. q.mu must be held.
+func (q *PriorityQueue) setDepths() {
+	for priority, queue := range q.queues {
+		queueDepthGauge.With(queuePriorities[priority]).Set(float64(len(queue)))
+	}
+}
+
+// run delivers the queued alerts one at a time, waiting interval after each delivery
+func (q *PriorityQueue) run() {
+	for range q.wake {
+		for {
+			item, ok := q.pop()
+			if !ok {
+				break
+			}
+			item.next.Alert(item.alertType, item.alertName, item.alert, item.config)
+			time.Sleep(q.interval)
+		}
+	}
+}
+
+// LimitDelivery makes DefaultAlerter deliver at most perMinute alerts a minute through a
+// PriorityQueue of capacity alerts. Alerts are still counted, and dropped while muted, as they are
+// raised. It must be called before any alert is raised.
+func LimitDelivery(perMinute int, capacity int) {
+	DefaultAlerter = Chain(AlerterFunc(Dispatch), CountAlerts, MuteAlerts, NewPriorityQueue(perMinute, capacity).Middleware)
+}
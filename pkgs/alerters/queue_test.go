@@ -0,0 +1,71 @@
+// Copyright 2019 Bloomberg Finance LP
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package alerters
+
+import (
+	"testing"
+	"time"
+
+	"github.com/bloomberg/k8eraid/pkgs/types"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_PriorityQueue(t *testing.T) {
+	started, release := make(chan struct{}), make(chan struct{})
+	delivered := make(chan string, 10)
+	base := AlerterFunc(func(_ string, _ string, alert types.Alert, _ types.AlertersConfig) {
+		if alert.Key == "first" {
+			close(started)
+			<-release
+		}
+		delivered <- alert.Key
+	})
+	queue := NewPriorityQueue(60000, 4)
+	alerter := Chain(base, queue.Middleware)
+	raise := func(key string, severity string) {
+		alerter.Alert("stderr", "", types.Alert{Key: key, Severity: severity}, types.AlertersConfig{})
+	}
+
+	// the first alert holds up delivery while the others pile up behind it
+	raise("first", types.SeverityInfo)
+	<-started
+	raise("info", types.SeverityInfo)
+	raise("warning-1", types.SeverityWarning)
+	raise("critical", types.SeverityCritical)
+	raise("warning-2", types.SeverityWarning)
+	assert.Equal(t, 1.0, queueDepthGauge.With(types.SeverityCritical).Value())
+	assert.Equal(t, 2.0, queueDepthGauge.With(types.SeverityWarning).Value())
+	assert.Equal(t, 1.0, queueDepthGauge.With(types.SeverityInfo).Value())
+
+	// the queue is full: a critical alert takes the place of the info one, another info is dropped
+	dropped := queueDroppedCounter.With(types.SeverityInfo).Value()
+	raise("critical-2", types.SeverityCritical)
+	raise("unknown", "")
+	assert.Equal(t, dropped+2, queueDroppedCounter.With(types.SeverityInfo).Value())
+
+	close(release)
+	var order []string
+	for len(order) < 5 {
+		select {
+		case key := <-delivered:
+			order = append(order, key)
+		case <-time.After(time.Second):
+			t.Fatalf("only %v were delivered", order)
+		}
+	}
+	assert.Equal(t, []string{"first", "critical", "critical-2", "warning-1", "warning-2"}, order)
+	assert.Equal(t, 0.0, queueDepthGauge.With(types.SeverityCritical).Value())
+}
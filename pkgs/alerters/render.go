@@ -0,0 +1,62 @@
+// Copyright 2019 Bloomberg Finance LP
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package alerters
+
+import (
+	"github.com/bloomberg/k8eraid/pkgs/types"
+)
+
+// Rendered is an alert as a templated alerter would send it
+type Rendered struct {
+	AlerterType string
+	AlerterName string
+	Title       string
+	Body        string
+}
+
+// RenderTemplated renders alert with the templates of every alerter in config that sets any,
+// without sending anything.
+func RenderTemplated(alert types.Alert, config types.AlertersConfig) []Rendered {
+	var rendered []Rendered
+	add := func(alerterType string, alerterName string, templates types.AlertTemplates) {
+		if templates.TitleTemplate == "" && templates.MessageTemplate == "" {
+			return
+		}
+		title, body := renderAlert(templates, alert)
+		rendered = append(rendered, Rendered{AlerterType: alerterType, AlerterName: alerterName, Title: title, Body: body})
+	}
+	for _, alerter := range config.Types.PDAlerterList {
+		add("pagerdutyV2", alerter.Name, alerter.AlertTemplates)
+	}
+	for _, alerter := range config.Types.SlackAlerterList {
+		add("slack", alerter.Name, alerter.AlertTemplates)
+	}
+	for _, alerter := range config.Types.SMTPAlerterList {
+		add("smtp", alerter.Name, alerter.AlertTemplates)
+	}
+	for _, alerter := range config.Types.WebhookAlerterList {
+		add("webhook", alerter.Name, alerter.AlertTemplates)
+	}
+	for _, alerter := range config.Types.SQLAlerterList {
+		add("sql", alerter.Name, alerter.AlertTemplates)
+	}
+	for _, alerter := range config.Types.TelegramAlerterList {
+		add("telegram", alerter.Name, alerter.AlertTemplates)
+	}
+	for _, alerter := range config.Types.CSVAlerterList {
+		add("csv", alerter.Name, alerter.AlertTemplates)
+	}
+	return rendered
+}
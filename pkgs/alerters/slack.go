@@ -18,11 +18,9 @@ import (
 	"encoding/json"
 	"fmt"
 	"log"
-	"net"
-	"net/http"
-	"net/url"
 	"time"
 
+	"github.com/bloomberg/k8eraid/pkgs/secrets"
 	"github.com/bloomberg/k8eraid/pkgs/types"
 
 	"github.com/nlopes/slack"
@@ -30,48 +28,74 @@ import (
 
 // AlertSlack sends an alert to slack
 func AlertSlack(alertData types.SlackAlerterConfig, message string) {
-	msg := SlackInput(message)
-	origTransport := http.DefaultTransport
-	if alertData.ProxyServer != "" {
-		// we need to override the default transport to apply proxy settings
-		proxyURL, err := url.Parse(alertData.ProxyServer)
-		if err != nil {
-			log.Printf(
-				"Alert configuration %s has invalid proxy server(%s): %s",
-				alertData.Name,
-				alertData.ProxyServer,
-				err.Error(),
-			)
-			return
-		}
-		http.DefaultTransport = &http.Transport{
-			Proxy: http.ProxyURL(proxyURL),
-			DialContext: (&net.Dialer{
-				Timeout:   10 * time.Second,
-				KeepAlive: 10 * time.Second,
-			}).DialContext,
-			MaxIdleConns:          100,
-			IdleConnTimeout:       10 * time.Second,
-			TLSHandshakeTimeout:   10 * time.Second,
-			ExpectContinueTimeout: 1 * time.Second,
-		}
+	AlertSlackWithTitle(alertData, "", message)
+}
+
+// AlertSlackWithTitle sends an alert to slack with the given attachment title, or the default one when empty
+func AlertSlackWithTitle(alertData types.SlackAlerterConfig, title string, message string) {
+	AlertSlackWithMention(alertData, title, message, "")
+}
+
+// AlertSlackWithMention sends an alert to slack with the given attachment title, mentioning the
+// given handle or channel when not empty
+func AlertSlackWithMention(alertData types.SlackAlerterConfig, title string, message string, mention string) {
+	webhookURL, err := secrets.Expand(alertData.WebhookURL)
+	if err != nil {
+		log.Printf("Alert configuration %s has an unresolvable webhook URL: %s", alertData.Name, err.Error())
+		return
 	}
-	if err := slack.PostWebhook(alertData.WebhookURL, msg); err != nil {
+	client, err := newHTTPClient(alertData.ProxyServer)
+	if err != nil {
+		log.Printf("Alert configuration %s has an unusable proxy server: %s", alertData.Name, err.Error())
+		return
+	}
+	if err := postJSON(client, webhookURL, slackInputWithMention(title, message, mention)); err != nil {
 		log.Printf("Error sending alert to Slack: %s", err.Error())
 	}
-	http.DefaultTransport = origTransport
 }
 
 // SlackInput formats an alert for Slack
 func SlackInput(message string) *slack.WebhookMessage {
+	return SlackInputWithTitle("", message)
+}
+
+// SlackInputWithTitle formats an alert for Slack under the given title
+func SlackInputWithTitle(title string, message string) *slack.WebhookMessage {
+	if title == "" {
+		title = "k8eraid alert"
+	}
 	attach := slack.Attachment{
 		Fallback:   message,
 		Color:      "#ff0000",
 		AuthorName: "k8eraid",
 		AuthorIcon: "https://github.com/kubernetes/kubernetes/raw/master/logo/logo.png",
-		Title:      "k8eraid alert",
+		Title:      title,
 		Text:       message,
 		Ts:         json.Number(fmt.Sprint(time.Now().Unix())),
 	}
 	return &slack.WebhookMessage{Attachments: []slack.Attachment{attach}}
 }
+
+// slackMessage is a slack.WebhookMessage that can ask slack to link the @handles and #channels of its text
+type slackMessage struct {
+	*slack.WebhookMessage
+	LinkNames int `json:"link_names,omitempty"`
+}
+
+// slackInputWithMention formats an alert for Slack, with the mention in the message text since
+// mentions in attachments do not notify anyone
+func slackInputWithMention(title string, message string, mention string) slackMessage {
+	input := slackMessage{WebhookMessage: SlackInputWithTitle(title, message)}
+	if mention == "" {
+		return input
+	}
+	switch mention {
+	case "@here", "@channel", "@everyone":
+		input.Text = "<!" + mention[1:] + ">"
+	default:
+		// <@U024BE7LH> and <!subteam^SAZ94GDB8> style mentions are sent as they are
+		input.Text = mention
+	}
+	input.LinkNames = 1
+	return input
+}
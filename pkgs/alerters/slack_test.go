@@ -16,6 +16,7 @@ package alerters
 
 import (
 	"bytes"
+	"encoding/json"
 	"fmt"
 	"io"
 	"net/http"
@@ -38,6 +39,29 @@ func Test_AlertSlack_OK(t *testing.T) {
 	})
 }
 
+func Test_AlertSlackWithMention(t *testing.T) {
+	tests := []struct {
+		mention  string
+		expected string
+	}{
+		{mention: "#payments-oncall", expected: "#payments-oncall"},
+		{mention: "@here", expected: "<!here>"},
+		{mention: "<@U024BE7LH>", expected: "<@U024BE7LH>"},
+	}
+	for _, test := range tests {
+		withWebhookServer(t, false, func(buf *bytes.Buffer, url string) {
+			AlertSlackWithMention(types.SlackAlerterConfig{WebhookURL: url}, "", "foo", test.mention)
+			var message struct {
+				Text      string `json:"text"`
+				LinkNames int    `json:"link_names"`
+			}
+			require.NoError(t, json.Unmarshal(buf.Bytes(), &message))
+			assert.Equal(t, test.expected, message.Text)
+			assert.Equal(t, 1, message.LinkNames, "slack should link the names of the mention")
+		})
+	}
+}
+
 func withWebhookServer(t *testing.T, fail bool, f func(buf *bytes.Buffer, url string)) {
 	buf := &bytes.Buffer{}
 	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
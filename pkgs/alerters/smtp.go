@@ -16,9 +16,9 @@ package alerters
 
 import (
 	"net/smtp"
-	"os"
 	"strconv"
 
+	"github.com/bloomberg/k8eraid/pkgs/secrets"
 	"github.com/bloomberg/k8eraid/pkgs/types"
 )
 
@@ -27,7 +27,11 @@ func AlertSMTP(alertdata types.SMTPAlerterConfig, message string) {
 	from := alertdata.FromAddress
 	to := alertdata.ToAddress
 	subject := alertdata.Subject
-	pass := os.Getenv(alertdata.PasswordEnvVar)
+	pass, err := secrets.Lookup(alertdata.PasswordEnvVar)
+	if err != nil {
+		errLogger.Print("smtp error: ", err)
+		return
+	}
 
 	port := strconv.Itoa(alertdata.Port)
 	server := alertdata.MailServer + ":" + port
@@ -37,7 +41,7 @@ func AlertSMTP(alertdata types.SMTPAlerterConfig, message string) {
 		"Subject: " + subject + "\n\n" +
 		message
 
-	err := smtp.SendMail(server,
+	err = smtp.SendMail(server,
 		smtp.PlainAuth("", from, pass, alertdata.MailServer),
 		from, []string{to}, []byte(msg))
 
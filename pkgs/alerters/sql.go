@@ -0,0 +1,247 @@
+// Copyright 2019 Bloomberg Finance LP
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package alerters
+
+import (
+	"database/sql"
+	"fmt"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/bloomberg/k8eraid/pkgs/metrics"
+	"github.com/bloomberg/k8eraid/pkgs/secrets"
+	"github.com/bloomberg/k8eraid/pkgs/types"
+)
+
+const (
+	defaultSQLMaxOpenConns  = 4
+	defaultSQLBatchSize     = 50
+	defaultSQLFlushInterval = 5
+	sqlQueueSize            = 1024
+)
+
+var (
+	sqlSinksMu     sync.Mutex
+	sqlSinks       = map[string]*sqlSink{}
+	sqlTableRegexp = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*(\.[A-Za-z_][A-Za-z0-9_]*)?$`)
+
+	sqlDroppedCounter = metrics.NewCounterVec(
+		"k8eraid_sql_dropped_alerts_total",
+		"Alerts a sql alerter dropped because its queue was full or inserting their batch failed.",
+		"alerter", "reason",
+	)
+)
+
+// sqlSink owns the connection pool and insert queue of a single sql alerter
+type sqlSink struct {
+	config types.SQLAlerterConfig
+	db     *sql.DB
+	queue  chan types.Alert
+
+	// mu guards retired, so no alert is queued once the sink stops draining its queue
+	mu      sync.RWMutex
+	retired bool
+	stop    chan struct{}
+	done    chan struct{}
+}
+
+// AlertSQL queues an alert for insertion into the configured SQL table.
+// Inserts are batched on a background goroutine so a slow database never blocks polling.
+func AlertSQL(alertdata types.SQLAlerterConfig, alert types.Alert) {
+	// A reload may retire the sink between the lookup and the send, in which case the alert
+	// goes to its replacement
+	for {
+		sink, err := getSQLSink(alertdata)
+		if err != nil {
+			errLogger.Print("sql error: ", err)
+			return
+		}
+		if sink.enqueue(alertdata, alert) {
+			return
+		}
+	}
+}
+
+// enqueue queues an alert unless the sink is retired
+func (s *sqlSink) enqueue(alertdata types.SQLAlerterConfig, alert types.Alert) bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if s.retired {
+		return false
+	}
+	select {
+	case s.queue <- alert:
+	default:
+		errLogger.Printf("sql alerter %s queue is full, dropping alert %s", alertdata.Name, alert.Key)
+		sqlDroppedCounter.With(alertdata.Name, "queue_full").Inc()
+	}
+	return true
+}
+
+func getSQLSink(alertdata types.SQLAlerterConfig) (*sqlSink, error) {
+	sqlSinksMu.Lock()
+	defer sqlSinksMu.Unlock()
+
+	// Sinks are keyed by name, so a reload that changes an alerter replaces its sink instead of
+	// leaving the old pool and goroutine running
+	if sink, ok := sqlSinks[alertdata.Name]; ok {
+		if sink.config == alertdata {
+			return sink, nil
+		}
+		delete(sqlSinks, alertdata.Name)
+		go sink.retire()
+	}
+	if !sqlTableRegexp.MatchString(alertdata.Table) {
+		return nil, fmt.Errorf("sql alerter %s has invalid table name %q", alertdata.Name, alertdata.Table)
+	}
+	dsn, err := secrets.Lookup(alertdata.DSNEnvVar)
+	if err != nil {
+		return nil, fmt.Errorf("unable to resolve DSN for sql alerter %s: %s", alertdata.Name, err.Error())
+	}
+	db, err := sql.Open(alertdata.Driver, dsn)
+	if err != nil {
+		return nil, fmt.Errorf("unable to open %s database for sql alerter %s: %s", alertdata.Driver, alertdata.Name, err.Error())
+	}
+	maxOpenConns := alertdata.MaxOpenConns
+	if maxOpenConns <= 0 {
+		maxOpenConns = defaultSQLMaxOpenConns
+	}
+	db.SetMaxOpenConns(maxOpenConns)
+
+	sink := &sqlSink{
+		config: alertdata,
+		db:     db,
+		queue:  make(chan types.Alert, sqlQueueSize),
+		stop:   make(chan struct{}),
+		done:   make(chan struct{}),
+	}
+	sqlSinks[alertdata.Name] = sink
+	go sink.run()
+	return sink, nil
+}
+
+// retire stops the sink once its queued alerts are inserted and closes its connection pool
+func (s *sqlSink) retire() {
+	s.mu.Lock()
+	s.retired = true
+	s.mu.Unlock()
+	close(s.stop)
+	<-s.done
+}
+
+func (s *sqlSink) run() {
+	defer close(s.done)
+	defer s.db.Close()
+	if _, err := s.db.Exec(sqlCreateStatement(s.config.Table)); err != nil {
+		errLogger.Printf("sql alerter %s unable to create table %s: %s", s.config.Name, s.config.Table, err.Error())
+	}
+
+	batchSize := s.config.BatchSize
+	if batchSize <= 0 {
+		batchSize = defaultSQLBatchSize
+	}
+	flushInterval := s.config.FlushInterval
+	if flushInterval <= 0 {
+		flushInterval = defaultSQLFlushInterval
+	}
+	ticker := time.NewTicker(time.Duration(flushInterval) * time.Second)
+	defer ticker.Stop()
+
+	batch := make([]types.Alert, 0, batchSize)
+	for {
+		select {
+		case alert := <-s.queue:
+			batch = append(batch, alert)
+			if len(batch) < batchSize {
+				continue
+			}
+		case <-ticker.C:
+			if len(batch) == 0 {
+				continue
+			}
+		case <-s.stop:
+			for len(s.queue) > 0 {
+				batch = append(batch, <-s.queue)
+			}
+			if len(batch) > 0 {
+				s.flush(batch)
+			}
+			return
+		}
+		s.flush(batch)
+		batch = batch[:0]
+	}
+}
+
+func (s *sqlSink) flush(batch []types.Alert) {
+	if err := s.insert(batch); err != nil {
+		errLogger.Printf("sql alerter %s dropped %d alerts: %s", s.config.Name, len(batch), err.Error())
+		sqlDroppedCounter.With(s.config.Name, "insert_failed").Add(float64(len(batch)))
+	} else {
+		logger.Printf("sql alerter %s recorded %d alerts", s.config.Name, len(batch))
+	}
+}
+
+// insert writes a batch of alerts in a single transaction
+func (s *sqlSink) insert(batch []types.Alert) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	stmt, err := tx.Prepare(sqlInsertStatement(s.config.Driver, s.config.Table))
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	defer stmt.Close()
+	for _, alert := range batch {
+		if _, err := stmt.Exec(alert.Key, alert.Message, alert.Severity, alert.Resource, alert.Time, alert.Resolved); err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+func sqlCreateStatement(table string) string {
+	return fmt.Sprintf(
+		"CREATE TABLE IF NOT EXISTS %s ("+
+			"alert_key TEXT NOT NULL, "+
+			"message TEXT NOT NULL, "+
+			"severity TEXT NOT NULL, "+
+			"resource TEXT NOT NULL, "+
+			"alerted_at TIMESTAMP NOT NULL, "+
+			"resolved BOOLEAN NOT NULL)",
+		table,
+	)
+}
+
+func sqlInsertStatement(driver string, table string) string {
+	placeholders := make([]string, 6)
+	for i := range placeholders {
+		if driver == "postgres" {
+			placeholders[i] = fmt.Sprintf("$%d", i+1)
+		} else {
+			placeholders[i] = "?"
+		}
+	}
+	return fmt.Sprintf(
+		"INSERT INTO %s (alert_key, message, severity, resource, alerted_at, resolved) VALUES (%s)",
+		table,
+		strings.Join(placeholders, ", "),
+	)
+}
@@ -0,0 +1,24 @@
+// Copyright 2019 Bloomberg Finance LP
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build sqlite
+// +build sqlite
+
+package alerters
+
+import (
+	// Registers the "sqlite3" driver for the sql alerter. It needs cgo, so it
+	// is only built with `-tags sqlite` and is left out of the scratch images.
+	_ "github.com/mattn/go-sqlite3"
+)
@@ -0,0 +1,168 @@
+// Copyright 2019 Bloomberg Finance LP
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package alerters
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/bloomberg/k8eraid/pkgs/types"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// recordingDriver is a minimal database/sql driver that records executed statements
+type recordingDriver struct {
+	mu    sync.Mutex
+	execs []string
+	rows  [][]driver.Value
+}
+
+func (d *recordingDriver) Open(_ string) (driver.Conn, error) { return &recordingConn{d}, nil }
+
+type recordingConn struct{ d *recordingDriver }
+
+func (c *recordingConn) Prepare(query string) (driver.Stmt, error) {
+	return &recordingStmt{d: c.d, query: query}, nil
+}
+func (c *recordingConn) Close() error              { return nil }
+func (c *recordingConn) Begin() (driver.Tx, error) { return c, nil }
+func (c *recordingConn) Commit() error             { return nil }
+func (c *recordingConn) Rollback() error           { return nil }
+
+type recordingStmt struct {
+	d     *recordingDriver
+	query string
+}
+
+// failingInserts is a recordingDriver whose inserts fail
+type failingInserts struct{ recordingDriver }
+
+func (d *failingInserts) Open(_ string) (driver.Conn, error) {
+	return &failingConn{recordingConn{&d.recordingDriver}}, nil
+}
+
+type failingConn struct{ recordingConn }
+
+func (c *failingConn) Prepare(query string) (driver.Stmt, error) {
+	if strings.HasPrefix(query, "INSERT") {
+		return nil, errors.New("connection reset")
+	}
+	return c.recordingConn.Prepare(query)
+}
+
+func (s *recordingStmt) Close() error  { return nil }
+func (s *recordingStmt) NumInput() int { return -1 }
+func (s *recordingStmt) Exec(args []driver.Value) (driver.Result, error) {
+	s.d.mu.Lock()
+	defer s.d.mu.Unlock()
+	s.d.execs = append(s.d.execs, s.query)
+	if len(args) > 0 {
+		s.d.rows = append(s.d.rows, args)
+	}
+	return driver.RowsAffected(1), nil
+}
+func (s *recordingStmt) Query(_ []driver.Value) (driver.Rows, error) { return nil, driver.ErrSkip }
+
+func (d *recordingDriver) rowCount() int {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return len(d.rows)
+}
+
+var testDriver = &recordingDriver{}
+
+func init() {
+	sql.Register("k8eraid-recording", testDriver)
+	sql.Register("k8eraid-failing-inserts", &failingInserts{})
+}
+
+func Test_AlertSQL_BatchesInserts(t *testing.T) {
+	config := types.SQLAlerterConfig{
+		Name:      "audit",
+		Driver:    "k8eraid-recording",
+		Table:     "alerts",
+		BatchSize: 2,
+	}
+	now := time.Now()
+	AlertSQL(config, types.Alert{Key: "node/a/Ready", Message: "a", Severity: types.SeverityCritical, Resource: "node/a", Time: now})
+	AlertSQL(config, types.Alert{Key: "node/b/Ready", Message: "b", Severity: types.SeverityWarning, Resource: "node/b", Time: now})
+
+	deadline := time.Now().Add(2 * time.Second)
+	for testDriver.rowCount() < 2 && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	testDriver.mu.Lock()
+	defer testDriver.mu.Unlock()
+	assert.Contains(t, testDriver.execs[0], "CREATE TABLE IF NOT EXISTS alerts")
+	assert.Equal(t, 2, len(testDriver.rows))
+	assert.Equal(t, []driver.Value{"node/a/Ready", "a", "critical", "node/a", now, false}, testDriver.rows[0])
+}
+
+func Test_AlertSQL_CountsDroppedBatches(t *testing.T) {
+	config := types.SQLAlerterConfig{Name: "failing", Driver: "k8eraid-failing-inserts", Table: "alerts", BatchSize: 2}
+	AlertSQL(config, types.Alert{Key: "node/a/Ready", Time: time.Now()})
+	AlertSQL(config, types.Alert{Key: "node/b/Ready", Time: time.Now()})
+
+	dropped := sqlDroppedCounter.With("failing", "insert_failed")
+	deadline := time.Now().Add(2 * time.Second)
+	for dropped.Value() < 2 && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+	assert.Equal(t, 2.0, dropped.Value(), "every alert of a batch that failed to insert should be counted")
+}
+
+func Test_AlertSQL_ReloadReplacesSink(t *testing.T) {
+	config := types.SQLAlerterConfig{Name: "reloaded", Driver: "k8eraid-recording", Table: "alerts"}
+	old, err := getSQLSink(config)
+	assert.NoError(t, err)
+
+	config.BatchSize = 10
+	replaced, err := getSQLSink(config)
+	assert.NoError(t, err)
+	assert.NotEqual(t, old, replaced)
+
+	select {
+	case <-old.done:
+	case <-time.After(2 * time.Second):
+		t.Fatal("the replaced sink should stop")
+	}
+	sqlSinksMu.Lock()
+	assert.Equal(t, replaced, sqlSinks["reloaded"])
+	sqlSinksMu.Unlock()
+	assert.False(t, old.enqueue(config, types.Alert{Key: "node/a/Ready"}), "a retired sink should refuse alerts")
+}
+
+func Test_AlertSQL_InvalidTable(t *testing.T) {
+	_, err := getSQLSink(types.SQLAlerterConfig{Name: "bad", Driver: "k8eraid-recording", Table: "alerts; DROP TABLE x"})
+	assert.Error(t, err)
+}
+
+func Test_SQLInsertStatement_Placeholders(t *testing.T) {
+	assert.Equal(t,
+		"INSERT INTO alerts (alert_key, message, severity, resource, alerted_at, resolved) VALUES ($1, $2, $3, $4, $5, $6)",
+		sqlInsertStatement("postgres", "alerts"),
+	)
+	assert.Equal(t,
+		"INSERT INTO audit.alerts (alert_key, message, severity, resource, alerted_at, resolved) VALUES (?, ?, ?, ?, ?, ?)",
+		sqlInsertStatement("sqlite3", "audit.alerts"),
+	)
+}
@@ -0,0 +1,131 @@
+// Copyright 2019 Bloomberg Finance LP
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package alerters
+
+import (
+	"fmt"
+	"html"
+	"strings"
+
+	"github.com/bloomberg/k8eraid/pkgs/secrets"
+	"github.com/bloomberg/k8eraid/pkgs/types"
+)
+
+const (
+	defaultTelegramServer = "https://api.telegram.org"
+	// telegramMaxLength is the Bot API limit on the length of a message's text
+	telegramMaxLength = 4096
+)
+
+// telegramMessage is the body of a Bot API sendMessage call
+type telegramMessage struct {
+	ChatID    string `json:"chat_id"`
+	Text      string `json:"text"`
+	ParseMode string `json:"parse_mode,omitempty"`
+}
+
+// AlertTelegram sends an alert to a Telegram chat through the Bot API
+func AlertTelegram(alertdata types.TelegramAlerterConfig, alert types.Alert) {
+	if err := sendTelegram(alertdata, alert); err != nil {
+		errLogger.Println("Issue sending Telegram alert: ", err)
+		return
+	}
+	logger.Println("Telegram message sent for telegram alerter: ", alertdata.Name)
+}
+
+func sendTelegram(alertdata types.TelegramAlerterConfig, alert types.Alert) error {
+	client, err := newHTTPClient(alertdata.ProxyServer)
+	if err != nil {
+		return err
+	}
+	server := alertdata.Server
+	if server == "" {
+		server = defaultTelegramServer
+	}
+	token, err := secrets.Lookup(alertdata.BotTokenEnvVar)
+	if err != nil {
+		return err
+	}
+	target := fmt.Sprintf("%s/bot%s/sendMessage", strings.TrimRight(server, "/"), token)
+	return postJSON(client, target, telegramInput(alertdata, alert))
+}
+
+// telegramInput formats an alert for Telegram, rendering the severity as an emoji
+func telegramInput(alertdata types.TelegramAlerterConfig, alert types.Alert) telegramMessage {
+	parseMode := alertdata.ParseMode
+	if parseMode == "" {
+		parseMode = "HTML"
+	}
+	subject := alertdata.Subject
+	if subject == "" {
+		subject = "k8eraid alert"
+	}
+
+	var text string
+	switch parseMode {
+	case "HTML":
+		text = fmt.Sprintf("%s <b>%s</b>\n%s", severityEmoji(alert.Severity), html.EscapeString(subject), html.EscapeString(alert.Message))
+	case "Markdown":
+		text = fmt.Sprintf("%s *%s*\n%s", severityEmoji(alert.Severity), escapeTelegramMarkdown(subject), escapeTelegramMarkdown(alert.Message))
+	default:
+		text = fmt.Sprintf("%s %s\n%s", severityEmoji(alert.Severity), subject, alert.Message)
+		parseMode = ""
+	}
+	text = truncateRunes(text, telegramMaxLength)
+	if parseMode == "HTML" {
+		text = trimPartialEntity(text)
+	}
+	return telegramMessage{
+		ChatID:    alertdata.ChatID,
+		Text:      text,
+		ParseMode: parseMode,
+	}
+}
+
+func severityEmoji(severity string) string {
+	switch severity {
+	case types.SeverityCritical:
+		return "\U0001F6A8"
+	case types.SeverityWarning:
+		return "⚠️"
+	default:
+		return "ℹ️"
+	}
+}
+
+func escapeTelegramMarkdown(text string) string {
+	return strings.NewReplacer("_", "\\_", "*", "\\*", "`", "\\`", "[", "\\[").Replace(text)
+}
+
+// truncateRunes cuts text to at most max runes, marking the cut with an ellipsis
+func truncateRunes(text string, max int) string {
+	runes := []rune(text)
+	if len(runes) <= max {
+		return text
+	}
+	return string(runes[:max-1]) + "…"
+}
+
+// trimPartialEntity drops an HTML entity left incomplete by truncation, which Telegram would reject
+func trimPartialEntity(text string) string {
+	if !strings.HasSuffix(text, "…") {
+		return text
+	}
+	body := strings.TrimSuffix(text, "…")
+	if amp := strings.LastIndex(body, "&"); amp > strings.LastIndex(body, ";") {
+		return body[:amp] + "…"
+	}
+	return text
+}
@@ -0,0 +1,91 @@
+// Copyright 2019 Bloomberg Finance LP
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package alerters
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	"github.com/bloomberg/k8eraid/pkgs/types"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_sendTelegram(t *testing.T) {
+	os.Setenv("TEST_TELEGRAM_TOKEN", "123:abc")
+	defer os.Unsetenv("TEST_TELEGRAM_TOKEN")
+
+	var path string
+	var got telegramMessage
+	status := http.StatusOK
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		defer r.Body.Close()
+		path = r.URL.Path
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&got))
+		w.WriteHeader(status)
+	}))
+	defer server.Close()
+
+	config := types.TelegramAlerterConfig{
+		Name:           "test",
+		BotTokenEnvVar: "TEST_TELEGRAM_TOKEN",
+		ChatID:         "-100",
+		Server:         server.URL,
+	}
+	alert := types.Alert{Severity: types.SeverityCritical, Message: "Pod <foo> is not ready"}
+
+	assert.NoError(t, sendTelegram(config, alert))
+	assert.Equal(t, "/bot123:abc/sendMessage", path)
+	assert.Equal(t, "-100", got.ChatID)
+	assert.Equal(t, "HTML", got.ParseMode)
+	assert.Equal(t, "\U0001F6A8 <b>k8eraid alert</b>\nPod &lt;foo&gt; is not ready", got.Text)
+
+	status = http.StatusBadRequest
+	assert.EqualError(t, sendTelegram(config, alert), "HTTP Status Code: 400: ")
+}
+
+func Test_telegramInput(t *testing.T) {
+	tests := []struct {
+		name      string
+		parseMode string
+		message   string
+		severity  string
+		want      string
+		wantMode  string
+	}{
+		{"markdown", "Markdown", "a_b *c*", types.SeverityWarning, "⚠️ *k8eraid alert*\na\\_b \\*c\\*", "Markdown"},
+		{"plain", "None", "<b>", types.SeverityInfo, "ℹ️ k8eraid alert\n<b>", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			msg := telegramInput(types.TelegramAlerterConfig{ParseMode: tt.parseMode}, types.Alert{Severity: tt.severity, Message: tt.message})
+			assert.Equal(t, tt.want, msg.Text)
+			assert.Equal(t, tt.wantMode, msg.ParseMode)
+		})
+	}
+
+	long := telegramInput(types.TelegramAlerterConfig{}, types.Alert{Message: strings.Repeat("é", 5000)})
+	assert.Equal(t, telegramMaxLength, utf8.RuneCountInString(long.Text))
+	assert.True(t, strings.HasSuffix(long.Text, "…"))
+
+	escaped := telegramInput(types.TelegramAlerterConfig{}, types.Alert{Message: strings.Repeat("<", 5000)})
+	assert.True(t, strings.HasSuffix(escaped.Text, "&lt;…"), "truncation should not split an entity")
+}
@@ -0,0 +1,57 @@
+// Copyright 2019 Bloomberg Finance LP
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package alerters
+
+import (
+	"bytes"
+	"strings"
+	"text/template"
+
+	"github.com/bloomberg/k8eraid/pkgs/types"
+)
+
+// renderAlert renders the title and body of alert with an alerter's templates. title is empty when
+// no TitleTemplate is set, so the alerter keeps its configured subject, and body is the alert message
+// when no MessageTemplate is set. A template that fails to render falls back to those defaults.
+func renderAlert(templates types.AlertTemplates, alert types.Alert) (title string, body string) {
+	title = renderTemplate("title", templates.TitleTemplate, alert, "")
+	body = renderTemplate("message", templates.MessageTemplate, alert, alert.Message)
+	return title, body
+}
+
+// textAlert joins a title and body for alerters that only carry a single message
+func textAlert(title string, body string) string {
+	if title == "" {
+		return body
+	}
+	return title + ": " + body
+}
+
+func renderTemplate(name string, text string, alert types.Alert, fallback string) string {
+	if text == "" {
+		return fallback
+	}
+	tmpl, err := template.New(name).Option("missingkey=error").Parse(text)
+	if err != nil {
+		errLogger.Printf("Invalid %s template %q: %s", name, text, err.Error())
+		return fallback
+	}
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, alert); err != nil {
+		errLogger.Printf("Unable to render %s template %q: %s", name, text, err.Error())
+		return fallback
+	}
+	return strings.TrimSpace(buf.String())
+}
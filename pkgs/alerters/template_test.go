@@ -0,0 +1,73 @@
+// Copyright 2019 Bloomberg Finance LP
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package alerters
+
+import (
+	"testing"
+
+	"github.com/bloomberg/k8eraid/pkgs/types"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_renderAlert(t *testing.T) {
+	alert := types.Alert{
+		Key:      "pod/default/web-1/Ready",
+		Resource: "pod/default/web-1",
+		Severity: types.SeverityCritical,
+		Message:  "Pod web-1 is not ready",
+	}
+
+	tests := []struct {
+		name      string
+		templates types.AlertTemplates
+		wantTitle string
+		wantBody  string
+	}{
+		{
+			name:     "no templates",
+			wantBody: "Pod web-1 is not ready",
+		},
+		{
+			name: "title and message",
+			templates: types.AlertTemplates{
+				TitleTemplate:   "[{{.Severity}}] {{.Resource}}",
+				MessageTemplate: "{{.Message}} (key {{.Key}})",
+			},
+			wantTitle: "[critical] pod/default/web-1",
+			wantBody:  "Pod web-1 is not ready (key pod/default/web-1/Ready)",
+		},
+		{
+			name:      "broken templates fall back",
+			templates: types.AlertTemplates{TitleTemplate: "{{.Severity", MessageTemplate: "{{.Missing}}"},
+			wantBody:  "Pod web-1 is not ready",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			title, body := renderAlert(test.templates, alert)
+			assert.Equal(t, test.wantTitle, title)
+			assert.Equal(t, test.wantBody, body)
+		})
+	}
+
+	assert.Equal(t, "body", textAlert("", "body"))
+	assert.Equal(t, "title: body", textAlert("title", "body"))
+}
+
+func Test_SlackInputWithTitle(t *testing.T) {
+	assert.Equal(t, "k8eraid alert", SlackInputWithTitle("", "foo").Attachments[0].Title)
+	assert.Equal(t, "web-1 down", SlackInputWithTitle("web-1 down", "foo").Attachments[0].Title)
+}
@@ -18,16 +18,20 @@ import (
 	"bytes"
 	"encoding/json"
 	"fmt"
-	"net"
 	"net/http"
-	"net/url"
 	"time"
 
+	"github.com/bloomberg/k8eraid/pkgs/secrets"
 	"github.com/bloomberg/k8eraid/pkgs/types"
 )
 
 // AlertWebhook sends a general http(s) payload using data relayed from alerts.go
 func AlertWebhook(alertdata types.WebhookAlerterConfig, message string) {
+	AlertWebhookWithOwners(alertdata, message, nil)
+}
+
+// AlertWebhookWithOwners sends a general http(s) payload carrying the owners of the object alerted on
+func AlertWebhookWithOwners(alertdata types.WebhookAlerterConfig, message string, owners map[string]string) {
 	mytime := time.Now().Local()
 
 	// Specify alert details
@@ -35,33 +39,13 @@ func AlertWebhook(alertdata types.WebhookAlerterConfig, message string) {
 	D.Subject = alertdata.Subject
 	D.Msg = message
 	D.Time = mytime
+	D.Owners = owners
 
 	// Set http proxy and custom http client
-
-	var myTransport *http.Transport
-	var err error
-
-	if alertdata.ProxyServer != "" {
-		proxyURL, _ := url.Parse(alertdata.ProxyServer)
-		myTransport = &http.Transport{
-			Dial: (&net.Dialer{
-				Timeout: 5 * time.Second,
-			}).Dial,
-			TLSHandshakeTimeout: 5 * time.Second,
-			Proxy:               http.ProxyURL(proxyURL),
-		}
-	} else {
-		myTransport = &http.Transport{
-			Dial: (&net.Dialer{
-				Timeout: 5 * time.Second,
-			}).Dial,
-			TLSHandshakeTimeout: 5 * time.Second,
-		}
-	}
-
-	var myClient = &http.Client{
-		Timeout:   time.Second * 10,
-		Transport: myTransport,
+	myClient, err := newHTTPClient(alertdata.ProxyServer)
+	if err != nil {
+		errLogger.Printf("Issue sending Webhook alert: webhook alerter %s: %s", alertdata.Name, err.Error())
+		return
 	}
 
 	// Trigger event
@@ -77,11 +61,15 @@ func createWebhookWithHTTPClient(d types.WebhookAlertDetails, client *http.Clien
 	if err != nil {
 		return err
 	}
-	req, _ := http.NewRequest("POST", alertdata.Server, bytes.NewBuffer(data))
+	server, err := secrets.Expand(alertdata.Server)
+	if err != nil {
+		return err
+	}
+	req, _ := http.NewRequest("POST", server, bytes.NewBuffer(data))
 	req.Header.Set("Content-Type", "application/json")
 	resp, err := client.Do(req)
 	if err != nil {
-		return err
+		return redactURLError(err, server)
 	}
 	defer resp.Body.Close()
 	if resp.StatusCode != http.StatusOK {
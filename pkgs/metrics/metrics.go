@@ -0,0 +1,409 @@
+// Copyright 2019 Bloomberg Finance LP
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package metrics
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// DefaultRegistry is the registry used by the package level constructors and Handler
+var DefaultRegistry = NewRegistry()
+
+type collector interface {
+	name() string
+	write(w io.Writer)
+}
+
+// Registry holds a set of metrics and renders them in the Prometheus text format
+type Registry struct {
+	mu         sync.Mutex
+	collectors []collector
+	names      map[string]bool
+}
+
+// NewRegistry returns an empty Registry
+func NewRegistry() *Registry {
+	return &Registry{names: map[string]bool{}}
+}
+
+func (r *Registry) register(c collector) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.names[c.name()] {
+		panic(fmt.Sprintf("metric %s registered twice", c.name()))
+	}
+	r.names[c.name()] = true
+	r.collectors = append(r.collectors, c)
+}
+
+// Write renders every registered metric to w
+func (r *Registry) Write(w io.Writer) {
+	r.mu.Lock()
+	collectors := make([]collector, len(r.collectors))
+	copy(collectors, r.collectors)
+	r.mu.Unlock()
+
+	for _, c := range collectors {
+		c.write(w)
+	}
+}
+
+// ServeHTTP serves the registry contents for scraping
+func (r *Registry) ServeHTTP(w http.ResponseWriter, _ *http.Request) {
+	var buf bytes.Buffer
+	r.Write(&buf)
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+	w.Write(buf.Bytes())
+}
+
+// Handler returns an http.Handler for the DefaultRegistry
+func Handler() http.Handler {
+	return DefaultRegistry
+}
+
+type desc struct {
+	metricName string
+	help       string
+	metricType string
+	labelNames []string
+}
+
+func (d *desc) name() string {
+	return d.metricName
+}
+
+func (d *desc) writeHeader(w io.Writer) {
+	fmt.Fprintf(w, "# HELP %s %s\n", d.metricName, d.help)
+	fmt.Fprintf(w, "# TYPE %s %s\n", d.metricName, d.metricType)
+}
+
+func formatLabels(names []string, values []string) string {
+	if len(names) == 0 {
+		return ""
+	}
+	pairs := make([]string, len(names))
+	for i, labelName := range names {
+		value := strings.Replace(values[i], `\`, `\\`, -1)
+		value = strings.Replace(value, `"`, `\"`, -1)
+		value = strings.Replace(value, "\n", `\n`, -1)
+		pairs[i] = fmt.Sprintf(`%s="%s"`, labelName, value)
+	}
+	return "{" + strings.Join(pairs, ",") + "}"
+}
+
+// atomicFloat is a float64 updated without locks, so pollers raising metrics concurrently never
+// wait on each other
+type atomicFloat struct {
+	bits uint64
+}
+
+func (f *atomicFloat) add(v float64) {
+	for {
+		old := atomic.LoadUint64(&f.bits)
+		if atomic.CompareAndSwapUint64(&f.bits, old, math.Float64bits(math.Float64frombits(old)+v)) {
+			return
+		}
+	}
+}
+
+func (f *atomicFloat) set(v float64) {
+	atomic.StoreUint64(&f.bits, math.Float64bits(v))
+}
+
+func (f *atomicFloat) load() float64 {
+	return math.Float64frombits(atomic.LoadUint64(&f.bits))
+}
+
+// Counter is a monotonically increasing value
+type Counter struct {
+	value atomicFloat
+}
+
+// Inc increments the counter by one
+func (c *Counter) Inc() {
+	c.Add(1)
+}
+
+// Add increments the counter by v
+func (c *Counter) Add(v float64) {
+	c.value.add(v)
+}
+
+// Value returns the current value of the counter
+func (c *Counter) Value() float64 {
+	return c.value.load()
+}
+
+// Gauge is a value that can go up and down
+type Gauge struct {
+	value atomicFloat
+}
+
+// Set sets the gauge to v
+func (g *Gauge) Set(v float64) {
+	g.value.set(v)
+}
+
+// Inc increments the gauge by one
+func (g *Gauge) Inc() {
+	g.Add(1)
+}
+
+// Dec decrements the gauge by one
+func (g *Gauge) Dec() {
+	g.Add(-1)
+}
+
+// Add adds v to the gauge
+func (g *Gauge) Add(v float64) {
+	g.value.add(v)
+}
+
+// Value returns the current value of the gauge
+func (g *Gauge) Value() float64 {
+	return g.value.load()
+}
+
+type valuer interface {
+	Value() float64
+}
+
+// Histogram counts observations into cumulative buckets, and keeps their count and sum
+type Histogram struct {
+	mu      sync.Mutex
+	bounds  []float64
+	buckets []uint64
+	count   uint64
+	sum     float64
+}
+
+func newHistogram(bounds []float64) *Histogram {
+	return &Histogram{bounds: bounds, buckets: make([]uint64, len(bounds))}
+}
+
+// Observe adds v to the histogram
+func (h *Histogram) Observe(v float64) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for i, bound := range h.bounds {
+		if v <= bound {
+			h.buckets[i]++
+		}
+	}
+	h.count++
+	h.sum += v
+}
+
+// Count returns how many values were observed
+func (h *Histogram) Count() uint64 {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.count
+}
+
+// Sum returns the sum of the observed values
+func (h *Histogram) Sum() float64 {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.sum
+}
+
+// writeSamples renders the _bucket, _sum and _count series of the histogram
+func (h *Histogram) writeSamples(w io.Writer, name string, labelNames []string, labelValues []string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	bucketNames := append(append([]string(nil), labelNames...), "le")
+	for i, bound := range h.bounds {
+		bucketValues := append(append([]string(nil), labelValues...), fmt.Sprintf("%g", bound))
+		fmt.Fprintf(w, "%s_bucket%s %d\n", name, formatLabels(bucketNames, bucketValues), h.buckets[i])
+	}
+	fmt.Fprintf(w, "%s_bucket%s %d\n", name, formatLabels(bucketNames, append(append([]string(nil), labelValues...), "+Inf")), h.count)
+	fmt.Fprintf(w, "%s_sum%s %g\n", name, formatLabels(labelNames, labelValues), h.sum)
+	fmt.Fprintf(w, "%s_count%s %d\n", name, formatLabels(labelNames, labelValues), h.count)
+}
+
+// sampleWriter is a child metric rendering several series instead of a single value
+type sampleWriter interface {
+	writeSamples(w io.Writer, name string, labelNames []string, labelValues []string)
+}
+
+// vec holds one child metric per distinct set of label values. Looking up an existing child only
+// takes the read lock.
+type vec struct {
+	desc
+	mu       sync.RWMutex
+	children map[string]interface{}
+	values   map[string][]string
+	newChild func() interface{}
+}
+
+func (v *vec) with(labelValues []string) interface{} {
+	if len(labelValues) != len(v.labelNames) {
+		panic(fmt.Sprintf("metric %s expects %d label values, got %d", v.metricName, len(v.labelNames), len(labelValues)))
+	}
+	key := strings.Join(labelValues, "\xff")
+	v.mu.RLock()
+	child, ok := v.children[key]
+	v.mu.RUnlock()
+	if ok {
+		return child
+	}
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	child, ok = v.children[key]
+	if !ok {
+		child = v.newChild()
+		v.children[key] = child
+		v.values[key] = append([]string(nil), labelValues...)
+	}
+	return child
+}
+
+func (v *vec) reset() {
+	v.mu.Lock()
+	v.children = map[string]interface{}{}
+	v.values = map[string][]string{}
+	v.mu.Unlock()
+}
+
+func (v *vec) write(w io.Writer) {
+	v.writeHeader(w)
+	v.mu.RLock()
+	defer v.mu.RUnlock()
+	keys := make([]string, 0, len(v.children))
+	for key := range v.children {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		switch child := v.children[key].(type) {
+		case sampleWriter:
+			child.writeSamples(w, v.metricName, v.labelNames, v.values[key])
+		case valuer:
+			fmt.Fprintf(w, "%s%s %g\n", v.metricName, formatLabels(v.labelNames, v.values[key]), child.Value())
+		}
+	}
+}
+
+func (r *Registry) newVec(name, help, metricType string, labelNames []string, newChild func() interface{}) *vec {
+	v := &vec{
+		desc: desc{
+			metricName: name,
+			help:       help,
+			metricType: metricType,
+			labelNames: labelNames,
+		},
+		children: map[string]interface{}{},
+		values:   map[string][]string{},
+		newChild: newChild,
+	}
+	r.register(v)
+	return v
+}
+
+// CounterVec is a set of counters partitioned by label values
+type CounterVec struct {
+	*vec
+}
+
+// With returns the counter for the given label values, creating it if needed
+func (c *CounterVec) With(labelValues ...string) *Counter {
+	return c.with(labelValues).(*Counter)
+}
+
+// GaugeVec is a set of gauges partitioned by label values
+type GaugeVec struct {
+	*vec
+}
+
+// With returns the gauge for the given label values, creating it if needed
+func (g *GaugeVec) With(labelValues ...string) *Gauge {
+	return g.with(labelValues).(*Gauge)
+}
+
+// Reset drops every child gauge
+func (g *GaugeVec) Reset() {
+	g.reset()
+}
+
+// HistogramVec is a set of histograms partitioned by label values
+type HistogramVec struct {
+	*vec
+}
+
+// With returns the histogram for the given label values, creating it if needed
+func (h *HistogramVec) With(labelValues ...string) *Histogram {
+	return h.with(labelValues).(*Histogram)
+}
+
+// NewCounter registers a new unlabelled counter with the registry
+func (r *Registry) NewCounter(name, help string) *Counter {
+	return r.NewCounterVec(name, help).With()
+}
+
+// NewCounterVec registers a new labelled counter with the registry
+func (r *Registry) NewCounterVec(name, help string, labelNames ...string) *CounterVec {
+	return &CounterVec{r.newVec(name, help, "counter", labelNames, func() interface{} { return &Counter{} })}
+}
+
+// NewGauge registers a new unlabelled gauge with the registry
+func (r *Registry) NewGauge(name, help string) *Gauge {
+	return r.NewGaugeVec(name, help).With()
+}
+
+// NewGaugeVec registers a new labelled gauge with the registry
+func (r *Registry) NewGaugeVec(name, help string, labelNames ...string) *GaugeVec {
+	return &GaugeVec{r.newVec(name, help, "gauge", labelNames, func() interface{} { return &Gauge{} })}
+}
+
+// NewHistogramVec registers a new labelled histogram with the registry, counting observations into
+// buckets with the given upper bounds, in increasing order
+func (r *Registry) NewHistogramVec(name, help string, buckets []float64, labelNames ...string) *HistogramVec {
+	bounds := append([]float64(nil), buckets...)
+	return &HistogramVec{r.newVec(name, help, "histogram", labelNames, func() interface{} { return newHistogram(bounds) })}
+}
+
+// NewCounter registers a new unlabelled counter with the DefaultRegistry
+func NewCounter(name, help string) *Counter {
+	return DefaultRegistry.NewCounter(name, help)
+}
+
+// NewCounterVec registers a new labelled counter with the DefaultRegistry
+func NewCounterVec(name, help string, labelNames ...string) *CounterVec {
+	return DefaultRegistry.NewCounterVec(name, help, labelNames...)
+}
+
+// NewGauge registers a new unlabelled gauge with the DefaultRegistry
+func NewGauge(name, help string) *Gauge {
+	return DefaultRegistry.NewGauge(name, help)
+}
+
+// NewGaugeVec registers a new labelled gauge with the DefaultRegistry
+func NewGaugeVec(name, help string, labelNames ...string) *GaugeVec {
+	return DefaultRegistry.NewGaugeVec(name, help, labelNames...)
+}
+
+// NewHistogramVec registers a new labelled histogram with the DefaultRegistry
+func NewHistogramVec(name, help string, buckets []float64, labelNames ...string) *HistogramVec {
+	return DefaultRegistry.NewHistogramVec(name, help, buckets, labelNames...)
+}
@@ -0,0 +1,106 @@
+// Copyright 2019 Bloomberg Finance LP
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package metrics
+
+import (
+	"bytes"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_Registry_Write(t *testing.T) {
+	r := NewRegistry()
+	c := r.NewCounter("test_total", "A test counter")
+	g := r.NewGaugeVec("test_gauge", "A test gauge", "kind")
+
+	c.Inc()
+	c.Add(2)
+	g.With("node").Set(4)
+	g.With("pod").Inc()
+	g.With("pod").Dec()
+
+	expected := `# HELP test_total A test counter
+# TYPE test_total counter
+test_total 3
+# HELP test_gauge A test gauge
+# TYPE test_gauge gauge
+test_gauge{kind="node"} 4
+test_gauge{kind="pod"} 0
+`
+	var buf bytes.Buffer
+	r.Write(&buf)
+	assert.Equal(t, expected, buf.String())
+}
+
+func Test_Registry_WriteHistogram(t *testing.T) {
+	r := NewRegistry()
+	h := r.NewHistogramVec("test_seconds", "A test histogram", []float64{1, 10}, "kind")
+
+	h.With("node").Observe(0.5)
+	h.With("node").Observe(5)
+	h.With("node").Observe(50)
+
+	expected := `# HELP test_seconds A test histogram
+# TYPE test_seconds histogram
+test_seconds_bucket{kind="node",le="1"} 1
+test_seconds_bucket{kind="node",le="10"} 2
+test_seconds_bucket{kind="node",le="+Inf"} 3
+test_seconds_sum{kind="node"} 55.5
+test_seconds_count{kind="node"} 3
+`
+	var buf bytes.Buffer
+	r.Write(&buf)
+	assert.Equal(t, expected, buf.String())
+}
+
+func Test_Registry_DuplicatePanics(t *testing.T) {
+	r := NewRegistry()
+	r.NewCounter("test_total", "A test counter")
+	assert.Panics(t, func() { r.NewGauge("test_total", "A test gauge") })
+}
+
+func Test_FormatLabels_Escapes(t *testing.T) {
+	assert.Equal(t, `{name="a\"b"}`, formatLabels([]string{"name"}, []string{`a"b`}))
+}
+
+func Test_Counter_Concurrent(t *testing.T) {
+	r := NewRegistry()
+	c := r.NewCounterVec("test_total", "A test counter", "verb")
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 1000; j++ {
+				c.With("GET").Add(0.5)
+			}
+		}()
+	}
+	wg.Wait()
+	assert.Equal(t, 4000.0, c.With("GET").Value())
+}
+
+// BenchmarkCounterVec_Inc increments one labelled counter from GOMAXPROCS concurrent pollers, as
+// on every apiserver call, see go test -bench . -cpu 1,4,16
+func BenchmarkCounterVec_Inc(b *testing.B) {
+	c := NewRegistry().NewCounterVec("test_total", "A test counter", "verb")
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			c.With("GET").Inc()
+		}
+	})
+}
@@ -0,0 +1,111 @@
+// Copyright 2019 Bloomberg Finance LP
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package queries
+
+import (
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/bloomberg/k8eraid/pkgs/alerters"
+	"github.com/bloomberg/k8eraid/pkgs/metrics"
+	"github.com/bloomberg/k8eraid/pkgs/state"
+	"github.com/bloomberg/k8eraid/pkgs/types"
+)
+
+var (
+	activeAlertsGauge = metrics.NewGaugeVec(
+		"k8eraid_active_alerts",
+		"Alerts raised recently enough that they have not resolved yet, including muted ones.",
+		"severity", "resource_type",
+	)
+	// activeAlertsMu keeps concurrent updates from publishing counts out of order
+	activeAlertsMu sync.Mutex
+	// activeAlertLabels remembers every label set published so far, so it drops to 0 instead of vanishing
+	activeAlertLabels = map[[2]string]bool{}
+
+	// restoredAlerts are the keys of the active alerts LoadState restored that were not raised again yet
+	restoredAlerts   = map[string]bool{}
+	restoredAlertsMu sync.Mutex
+)
+
+// TrackActiveAlerts is an alerters.Middleware recording every alert as active in the state store
+// before passing it on.
+func TrackActiveAlerts(next alerters.Alerter) alerters.Alerter {
+	return alerters.AlerterFunc(func(alerterType string, alerterName string, alert types.Alert, alertersConfig types.AlertersConfig) {
+		if stateStore.FireAlert(state.ActiveAlert{
+			Key:          alert.Key,
+			Severity:     alert.Severity,
+			ResourceType: resourceType(alert.Resource),
+			LastSeen:     alert.Time,
+		}) {
+			publishActiveAlerts()
+		}
+		next.Alert(alerterType, alerterName, alert, alertersConfig)
+	})
+}
+
+// SkipRestoredAlerts is an alerters.Middleware dropping the first alert raised for each active alert
+// LoadState restored, so the first poll after a restart does not page again for the conditions that
+// were already alerted on. Later alerts of the key, and alerts raised after it resolved, are passed on.
+func SkipRestoredAlerts(next alerters.Alerter) alerters.Alerter {
+	return alerters.AlerterFunc(func(alerterType string, alerterName string, alert types.Alert, alertersConfig types.AlertersConfig) {
+		restoredAlertsMu.Lock()
+		restored := restoredAlerts[alert.Key]
+		delete(restoredAlerts, alert.Key)
+		restoredAlertsMu.Unlock()
+		if restored {
+			logger.Printf("Alert %s was already active before the restart, not delivering it again", alert.Key)
+			return
+		}
+		next.Alert(alerterType, alerterName, alert, alertersConfig)
+	})
+}
+
+// ResolveActiveAlerts resolves every active alert that was not raised again since cutoff and
+// returns how many were resolved. Pollers raise an alert on every tick its condition holds, so a
+// cutoff a few ticks back resolves the conditions that cleared.
+func ResolveActiveAlerts(cutoff time.Time) int {
+	resolved := stateStore.ResolveAlerts(cutoff)
+	restoredAlertsMu.Lock()
+	for _, alert := range resolved {
+		delete(restoredAlerts, alert.Key)
+	}
+	restoredAlertsMu.Unlock()
+	if len(resolved) > 0 {
+		publishActiveAlerts()
+	}
+	return len(resolved)
+}
+
+// publishActiveAlerts recounts the active alerts per severity and resource type into the gauge
+func publishActiveAlerts() {
+	activeAlertsMu.Lock()
+	defer activeAlertsMu.Unlock()
+	counts := map[[2]string]int{}
+	for _, alert := range stateStore.ActiveAlerts() {
+		labels := [2]string{alert.Severity, alert.ResourceType}
+		counts[labels]++
+		activeAlertLabels[labels] = true
+	}
+	for labels := range activeAlertLabels {
+		activeAlertsGauge.With(labels[0], labels[1]).Set(float64(counts[labels]))
+	}
+}
+
+// resourceType is the kind part of a resource ID, e.g. node for node/worker-1
+func resourceType(resource string) string {
+	return strings.SplitN(resource, "/", 2)[0]
+}
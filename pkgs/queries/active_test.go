@@ -0,0 +1,125 @@
+// Copyright 2019 Bloomberg Finance LP
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package queries
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/bloomberg/k8eraid/pkgs/alerters"
+	"github.com/bloomberg/k8eraid/pkgs/state"
+	. "github.com/bloomberg/k8eraid/pkgs/types"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_TrackActiveAlerts(t *testing.T) {
+	stateStore = state.NewStore()
+	_, conf := StubsInit()
+	delivered := 0
+	alertFn := TrackActiveAlerts(alerters.AlerterFunc(func(_ string, _ string, _ Alert, _ AlertersConfig) {
+		delivered++
+	})).Alert
+	start := time.Unix(1000, 0)
+	fire := func(resource string, check string, severity string, at time.Time) {
+		alert := newAlert(resource, check, severity, "message")
+		alert.Time = at
+		alertFn("stderr", "", alert, conf)
+	}
+	gauge := func(severity string, resourceType string) float64 {
+		return activeAlertsGauge.With(severity, resourceType).Value()
+	}
+
+	fire("node/a", "Ready", SeverityCritical, start)
+	fire("node/b", "Ready", SeverityCritical, start)
+	fire("pod/ns/c", "Ready", SeverityWarning, start)
+	fire("node/a", "Ready", SeverityCritical, start.Add(time.Minute))
+	assert.Equal(t, 4, delivered)
+	assert.Equal(t, 2.0, gauge(SeverityCritical, "node"), "repeating an alert should not count it twice")
+	assert.Equal(t, 1.0, gauge(SeverityWarning, "pod"))
+
+	assert.Equal(t, 2, ResolveActiveAlerts(start.Add(30*time.Second)))
+	assert.Equal(t, 1.0, gauge(SeverityCritical, "node"))
+	assert.Equal(t, 0.0, gauge(SeverityWarning, "pod"))
+
+	assert.Equal(t, 1, ResolveActiveAlerts(start.Add(2*time.Minute)))
+	assert.Equal(t, 0, ResolveActiveAlerts(start.Add(2*time.Minute)))
+	assert.Equal(t, 0.0, gauge(SeverityCritical, "node"))
+}
+
+func Test_LoadState(t *testing.T) {
+	dir, err := ioutil.TempDir("", "k8eraid-state")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "state.json")
+
+	start := time.Unix(1000, 0)
+	stateStore = state.NewStore()
+	stateStore.FireAlert(state.ActiveAlert{Key: "node/a/Ready", Severity: SeverityCritical, ResourceType: "node", LastSeen: start})
+	require.NoError(t, SaveState(path, start))
+
+	stateStore = state.NewStore()
+	restarted := start.Add(time.Hour)
+	require.NoError(t, LoadState(path, restarted))
+	assert.Equal(t, 1.0, activeAlertsGauge.With(SeverityCritical, "node").Value())
+	assert.Equal(t, 0, ResolveActiveAlerts(restarted.Add(-time.Minute)), "a restored alert should get the polls after the restart to be raised again")
+	assert.Equal(t, 1, ResolveActiveAlerts(restarted.Add(time.Minute)))
+	assert.Equal(t, 0.0, activeAlertsGauge.With(SeverityCritical, "node").Value())
+}
+
+func Test_SkipRestoredAlerts(t *testing.T) {
+	dir, err := ioutil.TempDir("", "k8eraid-state")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "state.json")
+	_, conf := StubsInit()
+
+	start := time.Unix(1000, 0)
+	stateStore = state.NewStore()
+	stateStore.FireAlert(state.ActiveAlert{Key: "node/a/Ready", Severity: SeverityCritical, ResourceType: "node", LastSeen: start})
+	stateStore.FireAlert(state.ActiveAlert{Key: "node/b/Ready", Severity: SeverityCritical, ResourceType: "node", LastSeen: start})
+	require.NoError(t, SaveState(path, start))
+
+	stateStore = state.NewStore()
+	restarted := start.Add(time.Hour)
+	require.NoError(t, LoadState(path, restarted))
+	delivered := []string{}
+	alertFn := alerters.Chain(alerters.AlerterFunc(func(_ string, _ string, alert Alert, _ AlertersConfig) {
+		delivered = append(delivered, alert.Key)
+	}), TrackActiveAlerts, SkipRestoredAlerts).Alert
+	fire := func(resource string, at time.Time) {
+		alert := newAlert(resource, "Ready", SeverityCritical, "not ready")
+		alert.Time = at
+		alertFn("stderr", "", alert, conf)
+	}
+
+	fire("node/a", restarted)
+	assert.Empty(t, delivered, "alerts active before the restart should not be delivered by the first poll after it")
+	active, _ := stateStore.ActiveAlert("node/a/Ready")
+	assert.True(t, start.Equal(active.FirstSeen), "a restored alert should keep when it first fired")
+	assert.Equal(t, restarted, active.LastSeen, "a skipped alert should still count as raised")
+
+	fire("node/c", restarted.Add(time.Minute))
+	fire("node/a", restarted.Add(time.Minute))
+	assert.Equal(t, []string{"node/c/Ready", "node/a/Ready"}, delivered, "new alerts and later polls should be delivered")
+
+	assert.Equal(t, 1, ResolveActiveAlerts(restarted.Add(30*time.Second)))
+	fire("node/b", restarted.Add(2*time.Minute))
+	assert.Equal(t, []string{"node/c/Ready", "node/a/Ready", "node/b/Ready"}, delivered, "an alert raised after its restored one resolved should be delivered")
+}
@@ -0,0 +1,118 @@
+// Copyright 2019 Bloomberg Finance LP
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package queries
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/bloomberg/k8eraid/pkgs/types"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/client-go/kubernetes"
+)
+
+// apiHealthResult is what a health endpoint of the apiserver answered, and how long it took to
+type apiHealthResult struct {
+	StatusCode int
+	Body       string
+	Elapsed    time.Duration
+}
+
+// apiHealthProbe calls a health endpoint of the apiserver, asking for the verbose list of its checks.
+// An error means no answer could be read. It is a variable so tests can stub the apiserver out.
+var apiHealthProbe = func(clientset kubernetes.Interface, endpoint string) (apiHealthResult, error) {
+	start := time.Now()
+	body, err := clientset.CoreV1().RESTClient().Get().
+		AbsPath(endpoint).
+		Param("verbose", "true").
+		Timeout(time.Duration(timeout) * time.Second).
+		DoRaw()
+	health := apiHealthResult{StatusCode: http.StatusOK, Body: string(body), Elapsed: time.Since(start)}
+	// an unhealthy answer is an error carrying its status, while having no answer at all carries none
+	if err != nil {
+		status, ok := err.(apierrors.APIStatus)
+		if !ok {
+			return health, err
+		}
+		health.StatusCode = int(status.Status().Code)
+	}
+	return health, nil
+}
+
+// CheckAPIHealth alerts on every health endpoint of the apiserver that does not answer ok, with the
+// checks it reports failing, and on the endpoints slower to answer than the threshold
+func CheckAPIHealth(
+	clientset kubernetes.Interface,
+	alertSpec types.APIHealthAlertSpec,
+	alertFn alertFunction,
+	alertersConfig types.AlertersConfig,
+) {
+	for _, endpoint := range alertSpec.Endpoints {
+		resource := resourceID("apiserver", "", strings.Trim(endpoint, "/"))
+		health, err := apiHealthProbe(clientset, endpoint)
+		if err != nil {
+			// ALERT
+			alertmessage := fmt.Sprintf("Apiserver %s could not be reached, the control plane may be down: %s", endpoint, err.Error())
+			alertFn(alertSpec.AlerterType, alertSpec.AlerterName, newAlert(resource, "Unhealthy", types.SeverityCritical, alertmessage), alertersConfig)
+			continue
+		}
+		if health.StatusCode != http.StatusOK {
+			// ALERT
+			alertmessage := fmt.Sprintf(
+				"Apiserver %s reports the control plane unhealthy (HTTP %d)! %s",
+				endpoint, health.StatusCode, failedHealthChecks(health.Body),
+			)
+			alertFn(alertSpec.AlerterType, alertSpec.AlerterName, newAlert(resource, "Unhealthy", types.SeverityCritical, alertmessage), alertersConfig)
+		}
+		if threshold := alertSpec.ThresholdSeconds; threshold > 0 && health.Elapsed.Seconds() > threshold {
+			// ALERT
+			alertmessage := fmt.Sprintf(
+				"Apiserver %s took %.2fs to answer, above the %.2fs threshold! The control plane may be degrading.",
+				endpoint, health.Elapsed.Seconds(), threshold,
+			)
+			alertFn(alertSpec.AlerterType, alertSpec.AlerterName, newAlert(resource, "Latency", types.SeverityWarning, alertmessage), alertersConfig)
+		}
+	}
+}
+
+// failedHealthChecks names the checks a verbose health endpoint answer lists as failed, as lines
+// such as "[-]etcd failed: reason withheld", or quotes the answer when it lists none
+func failedHealthChecks(body string) string {
+	var failed []string
+	for _, line := range strings.Split(body, "\n") {
+		if !strings.HasPrefix(line, "[-]") {
+			continue
+		}
+		check := strings.TrimPrefix(line, "[-]")
+		if i := strings.Index(check, " failed"); i >= 0 {
+			check = check[:i]
+		}
+		failed = append(failed, check)
+	}
+	if len(failed) > 0 {
+		return fmt.Sprintf("Failed checks: %s.", strings.Join(failed, ", "))
+	}
+	body = strings.TrimSpace(body)
+	if len(body) > 200 {
+		body = body[:200] + "..."
+	}
+	if body == "" {
+		return "It gave no reason."
+	}
+	return fmt.Sprintf("It answered: %s", body)
+}
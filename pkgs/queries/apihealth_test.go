@@ -0,0 +1,94 @@
+// Copyright 2019 Bloomberg Finance LP
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package queries
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	. "github.com/bloomberg/k8eraid/pkgs/types"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/rest"
+)
+
+func Test_apiHealthProbe(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("verbose") != "true" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		switch r.URL.Path {
+		case "/livez":
+			fmt.Fprint(w, "[+]ping ok\nlivez check passed\n")
+		case "/readyz":
+			w.WriteHeader(http.StatusInternalServerError)
+			fmt.Fprint(w, "[+]ping ok\n[-]etcd failed: reason withheld\nreadyz check failed\n")
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	clientset, err := kubernetes.NewForConfig(&rest.Config{Host: server.URL})
+	require.NoError(t, err)
+
+	health, err := apiHealthProbe(clientset, "/livez")
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, health.StatusCode)
+	health, err = apiHealthProbe(clientset, "/readyz")
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusInternalServerError, health.StatusCode)
+	assert.Contains(t, health.Body, "[-]etcd failed")
+
+	server.Close()
+	_, err = apiHealthProbe(clientset, "/livez")
+	assert.Error(t, err, "an apiserver not answering should fail the probe rather than report a status")
+}
+
+func Test_CheckAPIHealth(t *testing.T) {
+	_, conf := StubsInit()
+	original := apiHealthProbe
+	defer func() { apiHealthProbe = original }()
+	apiHealthProbe = func(_ kubernetes.Interface, endpoint string) (apiHealthResult, error) {
+		switch endpoint {
+		case "/livez":
+			return apiHealthResult{StatusCode: http.StatusOK, Body: "ok", Elapsed: 2500 * time.Millisecond}, nil
+		case "/readyz":
+			return apiHealthResult{StatusCode: http.StatusInternalServerError, Body: "[+]ping ok\n[-]etcd failed: reason withheld\n[-]informer-sync failed: reason withheld\n"}, nil
+		case "/healthz":
+			return apiHealthResult{StatusCode: http.StatusServiceUnavailable, Body: "\n"}, nil
+		}
+		return apiHealthResult{}, fmt.Errorf("connection refused")
+	}
+
+	var alerts []string
+	CheckAPIHealth(fake.NewSimpleClientset(), APIHealthAlertSpec{
+		Endpoints:        []string{"/livez", "/readyz", "/healthz", "/readyz/etcd"},
+		ThresholdSeconds: 1,
+	}, func(_ string, _ string, alert Alert, _ AlertersConfig) {
+		alerts = append(alerts, alert.Key+": "+alert.Message)
+	}, conf)
+	assert.Equal(t, []string{
+		"apiserver/livez/Latency: Apiserver /livez took 2.50s to answer, above the 1.00s threshold! The control plane may be degrading.",
+		"apiserver/readyz/Unhealthy: Apiserver /readyz reports the control plane unhealthy (HTTP 500)! Failed checks: etcd, informer-sync.",
+		"apiserver/healthz/Unhealthy: Apiserver /healthz reports the control plane unhealthy (HTTP 503)! It gave no reason.",
+		"apiserver/readyz/etcd/Unhealthy: Apiserver /readyz/etcd could not be reached, the control plane may be down: connection refused",
+	}, alerts)
+}
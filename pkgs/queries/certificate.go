@@ -0,0 +1,103 @@
+// Copyright 2019 Bloomberg Finance LP
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package queries
+
+import (
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"time"
+
+	"github.com/bloomberg/k8eraid/pkgs/types"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+// checkCertificateExpiry alerts on the kubernetes.io/tls Secrets whose certificate chain has a
+// certificate that expires within the rule's number of days, as critical once it has expired, and on
+// those whose certificates can not be read. The certificate expiring first is reported, which is
+// not always the leaf one.
+func checkCertificateExpiry(
+	objects []configObject,
+	alertSpec types.ConfigObjectAlertSpec,
+	now time.Time,
+	alertFn alertFunction,
+	alertersConfig types.AlertersConfig,
+) {
+	window := time.Duration(alertSpec.ReportStatus.CertificateExpiryDays) * 24 * time.Hour
+	for _, object := range objects {
+		if object.secretType != corev1.SecretTypeTLS {
+			continue
+		}
+		certificate, err := firstExpiringCertificate(object.data[corev1.TLSCertKey])
+		if err != nil {
+			// ALERT
+			alertmessage := fmt.Sprintf("Secret %s/%s has an unreadable %s: %s!", object.namespace, object.name, corev1.TLSCertKey, err.Error())
+			alert := newAlert(object.resource(), "CertificateExpiry", types.SeverityWarning, alertmessage)
+			alertFn(alertSpec.AlerterType, alertSpec.AlerterName, alert, alertersConfig)
+			continue
+		}
+		left := certificate.NotAfter.Sub(now)
+		if left > window {
+			continue
+		}
+		subject := certificate.Subject.CommonName
+		if subject == "" {
+			subject = certificate.Subject.String()
+		}
+		// ALERT
+		alertmessage := fmt.Sprintf(
+			"Secret %s/%s has a certificate for %s that expires in %s, within %d days! It is valid until %s.",
+			object.namespace, object.name, subject, formatLongDuration(left), alertSpec.ReportStatus.CertificateExpiryDays,
+			certificate.NotAfter.UTC().Format(time.RFC3339),
+		)
+		severity := types.SeverityWarning
+		if left <= 0 {
+			alertmessage = fmt.Sprintf(
+				"Secret %s/%s has a certificate for %s that expired %s ago, on %s!",
+				object.namespace, object.name, subject, formatLongDuration(-left), certificate.NotAfter.UTC().Format(time.RFC3339),
+			)
+			severity = types.SeverityCritical
+		}
+		alert := newAlert(object.resource(), "CertificateExpiry", severity, alertmessage)
+		alertFn(alertSpec.AlerterType, alertSpec.AlerterName, alert, alertersConfig)
+	}
+}
+
+// firstExpiringCertificate parses the PEM certificates of chain and returns the one that expires first
+func firstExpiringCertificate(chain []byte) (*x509.Certificate, error) {
+	var first *x509.Certificate
+	for {
+		var block *pem.Block
+		block, chain = pem.Decode(chain)
+		if block == nil {
+			break
+		}
+		if block.Type != "CERTIFICATE" {
+			continue
+		}
+		certificate, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return nil, err
+		}
+		if first == nil || certificate.NotAfter.Before(first.NotAfter) {
+			first = certificate
+		}
+	}
+	if first == nil {
+		return nil, fmt.Errorf("no PEM certificate found")
+	}
+	return first, nil
+}
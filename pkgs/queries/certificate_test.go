@@ -0,0 +1,83 @@
+// Copyright 2019 Bloomberg Finance LP
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package queries
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"testing"
+	"time"
+
+	. "github.com/bloomberg/k8eraid/pkgs/types"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+)
+
+func Test_checkCertificateExpiry(t *testing.T) {
+	_, conf := StubsInit()
+	now := time.Unix(1600000000, 0)
+	leaf, err := sampleCertificate("shop.example.com", now.Add(60*24*time.Hour))
+	require.NoError(t, err)
+	intermediate, err := sampleCertificate("Example CA", now.Add(5*24*time.Hour))
+	require.NoError(t, err)
+	expired, err := sampleCertificate("old.example.com", now.Add(-3*24*time.Hour))
+	require.NoError(t, err)
+	secret := func(name string, secretType corev1.SecretType, certificate []byte) configObject {
+		return configObject{kind: ConfigObjectSecret, namespace: "shop", name: name, secretType: secretType, data: map[string][]byte{corev1.TLSCertKey: certificate}}
+	}
+	objects := []configObject{
+		secret("chain", corev1.SecretTypeTLS, append(append([]byte{}, leaf...), intermediate...)),
+		secret("expired", corev1.SecretTypeTLS, expired),
+		secret("fresh", corev1.SecretTypeTLS, leaf),
+		secret("garbled", corev1.SecretTypeTLS, []byte("not a certificate")),
+		secret("opaque", corev1.SecretTypeOpaque, expired),
+	}
+
+	var alerts []string
+	checkCertificateExpiry(objects, ConfigObjectAlertSpec{ReportStatus: ConfigObjectAlertStatus{CertificateExpiryDays: 30}}, now, func(_ string, _ string, alert Alert, _ AlertersConfig) {
+		alerts = append(alerts, alert.Severity+" "+alert.Key+": "+alert.Message)
+	}, conf)
+	assert.Equal(t, []string{
+		"warning secret/shop/chain/CertificateExpiry: Secret shop/chain has a certificate for Example CA that expires in 5 days, within 30 days! It is valid until 2020-09-18T12:26:40Z.",
+		"critical secret/shop/expired/CertificateExpiry: Secret shop/expired has a certificate for old.example.com that expired 3 days ago, on 2020-09-10T12:26:40Z!",
+		"warning secret/shop/garbled/CertificateExpiry: Secret shop/garbled has an unreadable tls.crt: no PEM certificate found!",
+	}, alerts, "the certificate of a chain expiring first is reported")
+}
+
+// sampleCertificate builds a PEM encoded self-signed certificate for commonName valid until notAfter
+func sampleCertificate(commonName string, notAfter time.Time) ([]byte, error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: commonName},
+		NotBefore:    notAfter.Add(-90 * 24 * time.Hour),
+		NotAfter:     notAfter,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		return nil, err
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), nil
+}
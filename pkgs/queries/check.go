@@ -0,0 +1,184 @@
+// Copyright 2019 Bloomberg Finance LP
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package queries
+
+import (
+	"time"
+
+	"github.com/bloomberg/k8eraid/pkgs/types"
+
+	appsv1 "k8s.io/api/apps/v1"
+	autoscalingv2beta2 "k8s.io/api/autoscaling/v2beta2"
+	batchv1 "k8s.io/api/batch/v1"
+	batchv1beta1 "k8s.io/api/batch/v1beta1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+)
+
+// CheckResult lists the alerts a check raised, in the order it raised them
+type CheckResult struct {
+	Alerts []types.Alert
+}
+
+// Keys returns the key of every alert of the result
+func (r CheckResult) Keys() []string {
+	keys := []string{}
+	for _, alert := range r.Alerts {
+		keys = append(keys, alert.Key)
+	}
+	return keys
+}
+
+// Messages returns the message of every alert of the result
+func (r CheckResult) Messages() []string {
+	messages := []string{}
+	for _, alert := range r.Alerts {
+		messages = append(messages, alert.Message)
+	}
+	return messages
+}
+
+// runCheck runs check with an alertFunction capturing what it raises. Alerts are stamped with now,
+// the time the check ran at, so results compare equal across runs.
+func runCheck(now time.Time, check func(alertFn alertFunction)) CheckResult {
+	result := CheckResult{Alerts: []types.Alert{}}
+	check(func(_ string, _ string, alert types.Alert, _ types.AlertersConfig) {
+		alert.Time = now
+		result.Alerts = append(result.Alerts, alert)
+	})
+	return result
+}
+
+// The Check* functions run the checks a rule makes on one object, as its poller would at now, and
+// return the alerts they raise instead of delivering them. They take fully formed objects, so a
+// check can be tested end to end without a clientset. Checks comparing polls still share the state
+// of the pollers, and rule defaults the pollers fill in are applied the same way.
+
+// CheckNode runs the checks of alertSpec on node, but for the ones reading node metrics and Leases.
+// Condition changes count when they happened within tickertime seconds before now.
+func CheckNode(node *corev1.Node, alertSpec types.NodeAlertSpec, tickertime int64, now time.Time) CheckResult {
+	if alertSpec.ReportStatus.PendingThreshold == 0 {
+		alertSpec.ReportStatus.PendingThreshold = defaultPendingThreshold
+	}
+	return runCheck(now, func(alertFn alertFunction) {
+		checkNodeObject(nil, node, nil, alertSpec, tickertime, now, alertFn, types.AlertersConfig{})
+	})
+}
+
+// CheckPod runs the checks of alertSpec on pod, but for the gRPC health probe and the ones needing
+// what a poll lists, nodes, events, pod metrics and LimitRanges. Restarts count when they happened
+// within tickertime seconds before now.
+func CheckPod(pod *corev1.Pod, alertSpec types.PodAlertSpec, tickertime int64, now time.Time) CheckResult {
+	alertSpec = podRuleDefaults(alertSpec)
+	return runCheck(now, func(alertFn alertFunction) {
+		checkPodObject(nil, pod, podLookups{}, alertSpec, tickertime, now, alertFn, types.AlertersConfig{})
+	})
+}
+
+// CheckDeployment runs the checks of alertSpec on deployment, but for the pod spread check, with
+// replicaSets and the ones of pods it selects standing in for what a poll lists
+func CheckDeployment(
+	deployment *appsv1.Deployment,
+	replicaSets []appsv1.ReplicaSet,
+	pods []corev1.Pod,
+	alertSpec types.DeploymentAlertSpec,
+	now time.Time,
+) CheckResult {
+	if alertSpec.ReportStatus.PendingThreshold == 0 {
+		alertSpec.ReportStatus.PendingThreshold = defaultPendingThreshold
+	}
+	lookups := deploymentLookups{replicaSets: replicaSets, pods: selectedPods(deployment, pods)}
+	return runCheck(now, func(alertFn alertFunction) {
+		checkDeploymentObject(nil, deployment, lookups, alertSpec, now, alertFn, types.AlertersConfig{})
+	})
+}
+
+// selectedPods returns the pods of the deployment's namespace its selector matches
+func selectedPods(deployment *appsv1.Deployment, pods []corev1.Pod) []corev1.Pod {
+	selector, err := metav1.LabelSelectorAsSelector(deployment.Spec.Selector)
+	if err != nil {
+		return nil
+	}
+	var selected []corev1.Pod
+	for _, pod := range pods {
+		if pod.Namespace == deployment.Namespace && selector.Matches(labels.Set(pod.Labels)) {
+			selected = append(selected, pod)
+		}
+	}
+	return selected
+}
+
+// CheckDaemonset runs the checks of alertSpec on daemonSet
+func CheckDaemonset(daemonSet *appsv1.DaemonSet, alertSpec types.DaemonsetAlertSpec, now time.Time) CheckResult {
+	if alertSpec.ReportStatus.PendingThreshold == 0 {
+		alertSpec.ReportStatus.PendingThreshold = defaultPendingThreshold
+	}
+	return runCheck(now, func(alertFn alertFunction) {
+		checkDaemonset(daemonSet, alertSpec, now, alertFn, types.AlertersConfig{})
+	})
+}
+
+// CheckStatefulset runs the checks of alertSpec on statefulSet
+func CheckStatefulset(statefulSet *appsv1.StatefulSet, alertSpec types.StatefulsetAlertSpec, now time.Time) CheckResult {
+	if alertSpec.ReportStatus.PendingThreshold == 0 {
+		alertSpec.ReportStatus.PendingThreshold = defaultPendingThreshold
+	}
+	return runCheck(now, func(alertFn alertFunction) {
+		checkStatefulset(statefulSet, alertSpec, now, alertFn, types.AlertersConfig{})
+	})
+}
+
+// CheckReplicaSet runs the checks of alertSpec on replicaSet
+func CheckReplicaSet(replicaSet *appsv1.ReplicaSet, alertSpec types.ReplicaSetAlertSpec, now time.Time) CheckResult {
+	return runCheck(now, func(alertFn alertFunction) {
+		checkReplicaSet(replicaSet, alertSpec, now, alertFn, types.AlertersConfig{})
+	})
+}
+
+// CheckJob runs the checks of alertSpec on job
+func CheckJob(job *batchv1.Job, alertSpec types.JobAlertSpec, now time.Time) CheckResult {
+	return runCheck(now, func(alertFn alertFunction) {
+		checkJob(job, alertSpec, now, alertFn, types.AlertersConfig{})
+	})
+}
+
+// CheckCronJob runs the checks of alertSpec on cronJob, whose runs are jobs
+func CheckCronJob(cronJob *batchv1beta1.CronJob, jobs []batchv1.Job, alertSpec types.CronJobAlertSpec, now time.Time) CheckResult {
+	return runCheck(now, func(alertFn alertFunction) {
+		checkCronJob(cronJob, ownedJobs(cronJob, jobs), alertSpec, now, alertFn, types.AlertersConfig{})
+	})
+}
+
+// CheckHPA runs the checks of alertSpec on hpa
+func CheckHPA(hpa *autoscalingv2beta2.HorizontalPodAutoscaler, alertSpec types.HPAAlertSpec, now time.Time) CheckResult {
+	return runCheck(now, func(alertFn alertFunction) {
+		checkHPA(hpa, alertSpec, now, alertFn, types.AlertersConfig{})
+	})
+}
+
+// CheckResourceQuota runs the checks of alertSpec on quota
+func CheckResourceQuota(quota *corev1.ResourceQuota, alertSpec types.ResourceQuotaAlertSpec, now time.Time) CheckResult {
+	return runCheck(now, func(alertFn alertFunction) {
+		checkResourceQuota(quota, alertSpec, alertFn, types.AlertersConfig{})
+	})
+}
+
+// CheckClockSkew runs the clock skew check of alertSpec across nodes, from the heartbeats of their conditions
+func CheckClockSkew(nodes []corev1.Node, alertSpec types.NodeAlertSpec, now time.Time) CheckResult {
+	return runCheck(now, func(alertFn alertFunction) {
+		checkClockSkew(nodes, nil, alertSpec, now, alertFn, types.AlertersConfig{})
+	})
+}
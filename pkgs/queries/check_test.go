@@ -0,0 +1,115 @@
+// Copyright 2019 Bloomberg Finance LP
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package queries
+
+import (
+	"testing"
+	"time"
+
+	. "github.com/bloomberg/k8eraid/pkgs/types"
+
+	"github.com/stretchr/testify/assert"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+var checkTime = time.Unix(1000000, 0)
+
+func Test_CheckNode(t *testing.T) {
+	node := readyNode("worker-1", corev1.ConditionFalse)
+	node.CreationTimestamp = metav1.Time{Time: checkTime.Add(-time.Hour)}
+	node.Status.Conditions[0].LastTransitionTime = metav1.Time{Time: checkTime.Add(-10 * time.Second)}
+	alertSpec := NodeAlertSpec{Name: "worker-1", ReportStatus: NodeAlertStatus{NodeReady: true}}
+
+	result := CheckNode(node, alertSpec, 30, checkTime)
+	assert.Equal(t, CheckResult{Alerts: []Alert{{
+		Key:           "node/worker-1/Ready",
+		Resource:      "node/worker-1",
+		Severity:      SeverityCritical,
+		Message:       "Nodeworker-1has changed ready status since last poll and may be restarting!",
+		Time:          checkTime,
+		DetectedAfter: 10 * time.Second,
+	}}}, result)
+	assert.Equal(t, result, CheckNode(node, alertSpec, 30, checkTime), "a check should raise the same alerts for the same object and time")
+	assert.Empty(t, CheckNode(node, alertSpec, 30, checkTime.Add(time.Minute)).Alerts, "the transition is older than the last poll by then")
+
+	cordoned := readyNode("worker-2", corev1.ConditionTrue)
+	cordoned.Spec.Unschedulable = true
+	cordoned.Spec.Taints = []corev1.Taint{{Key: nodeUnschedulableTaint, Effect: corev1.TaintEffectNoSchedule, TimeAdded: &metav1.Time{Time: checkTime.Add(-2 * time.Hour)}}}
+	cordonSpec := NodeAlertSpec{Name: "worker-2", ReportStatus: NodeAlertStatus{CordonedThreshold: 3600}}
+	assert.Equal(t, []string{"node/worker-2/Cordoned"}, CheckNode(cordoned, cordonSpec, 30, checkTime).Keys(), "checks beyond the conditions should run too")
+}
+
+func Test_CheckPod(t *testing.T) {
+	pod := samplePod("web-0", metav1.Time{Time: checkTime.Add(-time.Hour)})
+	pod.Status.Conditions = []corev1.PodCondition{{Type: corev1.PodScheduled, Status: corev1.ConditionFalse}}
+	alertSpec := PodAlertSpec{Name: "web-0", PodFilterNamespace: sampleNamespace, ReportStatus: PodAlertStatus{FailedScheduling: true}}
+
+	assert.Equal(t, []string{"pod/sample/web-0/PodScheduled"}, CheckPod(pod, alertSpec, 30, checkTime).Keys())
+	pod.CreationTimestamp = metav1.Time{Time: checkTime.Add(-5 * time.Second)}
+	assert.Empty(t, CheckPod(pod, alertSpec, 30, checkTime).Alerts, "pods younger than the default pending threshold are not checked")
+
+	pod.Spec.Containers = []corev1.Container{{Name: "web", Image: "registry.example.com/web:latest"}}
+	policySpec := PodAlertSpec{Name: "web-0", PodFilterNamespace: sampleNamespace, ReportStatus: PodAlertStatus{ImagePolicy: ImagePolicy{DisallowLatest: true}}}
+	assert.Equal(t, []string{"pod/sample/web-0/ImagePolicy"}, CheckPod(pod, policySpec, 30, checkTime).Keys(), "checks beyond the conditions should run too")
+
+	restarting := samplePod("web-1", metav1.Time{Time: checkTime.Add(-time.Hour)})
+	restarting.Status.ContainerStatuses = []corev1.ContainerStatus{{Name: "web"}}
+	restartSpec := PodAlertSpec{Name: "web-1", PodFilterNamespace: sampleNamespace, ReportStatus: PodAlertStatus{RestartIncrease: 2}}
+	assert.Empty(t, CheckPod(restarting, restartSpec, 30, checkTime.Add(-time.Minute)).Alerts)
+	restarting.Status.ContainerStatuses[0].RestartCount = 10
+	assert.Equal(t, []string{"pod/sample/web-1/RestartRate"}, CheckPod(restarting, restartSpec, 30, checkTime).Keys(), "restarts should count within the default window")
+}
+
+func Test_CheckDeployment(t *testing.T) {
+	deployment := &appsv1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{Name: "web", Namespace: "default", CreationTimestamp: metav1.Time{Time: checkTime.Add(-time.Hour)}},
+		Status:     appsv1.DeploymentStatus{AvailableReplicas: 1},
+	}
+	alertSpec := DeploymentAlertSpec{Name: "web", DepFilter: "default", ReportStatus: DeploymentAlertStatus{MinReplicas: 2}}
+
+	assert.Equal(t, []string{"Deployment web does not have the specified required minimum replicas"}, CheckDeployment(deployment, nil, nil, alertSpec, checkTime).Messages())
+
+	deployment.UID = "web-uid"
+	deployment.Spec.Selector = &metav1.LabelSelector{MatchLabels: map[string]string{"app": "web"}}
+	replicaSets := []appsv1.ReplicaSet{*ownedReplicaSet(deployment, "web-1", 1), *ownedReplicaSet(deployment, "web-2", 1)}
+	pods := []corev1.Pod{
+		*oomKilledPod("web-1-a", "default", "web", checkTime.Add(-time.Minute), checkTime.Add(-2*time.Minute)),
+		*oomKilledPod("api-1-a", "default", "api", checkTime.Add(-time.Minute)),
+	}
+	listedSpec := DeploymentAlertSpec{Name: "web", DepFilter: "default", ReportStatus: DeploymentAlertStatus{
+		OOMKills:    OOMKillCheck{Threshold: 2},
+		ReplicaSets: ReplicaSetCheck{MaxActive: 1},
+	}}
+	assert.Equal(t, []string{"deployment/default/web/OOMKilled", "deployment/default/web/ActiveReplicaSets"}, CheckDeployment(deployment, replicaSets, pods, listedSpec, checkTime).Keys(),
+		"checks on the pods and ReplicaSets of the deployment should run too")
+}
+
+func Test_CheckDaemonset(t *testing.T) {
+	daemonSet := &appsv1.DaemonSet{
+		ObjectMeta: metav1.ObjectMeta{Name: "agent", Namespace: "default", CreationTimestamp: metav1.Time{Time: checkTime.Add(-time.Hour)}},
+		Status:     appsv1.DaemonSetStatus{CurrentNumberScheduled: 2, NumberAvailable: 2, DesiredNumberScheduled: 3},
+	}
+	alertSpec := DaemonsetAlertSpec{Name: "agent", DaemonFilter: "default", ReportStatus: DaemonsetAlertStatus{CheckReplicas: true, FailedScheduling: true}}
+
+	assert.Equal(t, []string{"daemonset/default/agent/FailedScheduling"}, CheckDaemonset(daemonSet, alertSpec, checkTime).Keys())
+}
+
+func samplePod(name string, created metav1.Time) *corev1.Pod {
+	return &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: sampleNamespace, CreationTimestamp: created},
+	}
+}
@@ -0,0 +1,169 @@
+// Copyright 2019 Bloomberg Finance LP
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package queries
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/bloomberg/k8eraid/pkgs/types"
+
+	coordinationv1beta1 "k8s.io/api/coordination/v1beta1"
+	corev1 "k8s.io/api/core/v1"
+)
+
+const defaultClockSkewMinNodes = 3
+
+// nodeLeaseRenewInterval is how often kubelets renew their Lease by default, so how old the renew
+// time of a healthy node's Lease can be
+const nodeLeaseRenewInterval = 10 * time.Second
+
+// checkClockSkew estimates the clock offset of every Ready node from the heartbeat times its
+// kubelet stamps on its conditions and its Lease with its own clock. By default the nodes are
+// compared to their median rather than to k8eraid's clock, so a skewed k8eraid node does not make
+// every node alert. The estimate is only as precise as the kubelets' heartbeat interval, so nodes
+// without a Ready heartbeat are left out and nothing is checked without enough of them.
+func checkClockSkew(
+	nodes []corev1.Node,
+	leases map[string]*coordinationv1beta1.Lease,
+	alertSpec types.NodeAlertSpec,
+	now time.Time,
+	alertFn alertFunction,
+	alertersConfig types.AlertersConfig,
+) {
+	check := alertSpec.ReportStatus.ClockSkew
+	if check.ThresholdSeconds <= 0 {
+		return
+	}
+	if check.Reference == types.ClockSkewReferenceMonitor {
+		checkMonitorClockSkew(nodes, leases, alertSpec, now, alertFn, alertersConfig)
+		return
+	}
+	if check.MinNodes <= 0 {
+		check.MinNodes = defaultClockSkewMinNodes
+	}
+
+	offsets := map[string]time.Duration{}
+	var sorted []time.Duration
+	for i := range nodes {
+		heartbeat, ok := nodeHeartbeat(&nodes[i], leases[nodes[i].Name])
+		if !ok {
+			continue
+		}
+		offsets[nodes[i].Name] = heartbeat.Sub(now)
+		sorted = append(sorted, heartbeat.Sub(now))
+	}
+	if len(sorted) < check.MinNodes {
+		return
+	}
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+	median := sorted[len(sorted)/2]
+	if len(sorted)%2 == 0 {
+		median = (sorted[len(sorted)/2-1] + sorted[len(sorted)/2]) / 2
+	}
+
+	threshold := time.Duration(check.ThresholdSeconds) * time.Second
+	names := make([]string, 0, len(offsets))
+	for name := range offsets {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		skew := offsets[name] - median
+		direction := "ahead of"
+		if skew < 0 {
+			skew, direction = -skew, "behind"
+		}
+		if skew <= threshold {
+			continue
+		}
+		// ALERT
+		alertmessage := fmt.Sprintf(
+			"Node %s clock is about %s %s the median of %d nodes with filter %s, more than the %s allowed, certificates and tokens may be rejected!",
+			name,
+			skew.Round(time.Second),
+			direction,
+			len(offsets),
+			alertSpec.NodeFilter,
+			threshold,
+		)
+		alertFn(alertSpec.AlerterType, alertSpec.AlerterName, newAlert(resourceID("node", "", name), "ClockSkew", types.SeverityWarning, alertmessage), alertersConfig)
+	}
+}
+
+// checkMonitorClockSkew compares the heartbeat of every Ready node with k8eraid's clock. A heartbeat
+// from the future can only come from a clock running ahead. Kubelets update their node status as
+// rarely as every 5 minutes once they renew a Lease, so only a node with a Lease, renewed every 10
+// seconds, can be told to run behind rather than to have reported a while ago.
+func checkMonitorClockSkew(
+	nodes []corev1.Node,
+	leases map[string]*coordinationv1beta1.Lease,
+	alertSpec types.NodeAlertSpec,
+	now time.Time,
+	alertFn alertFunction,
+	alertersConfig types.AlertersConfig,
+) {
+	threshold := time.Duration(alertSpec.ReportStatus.ClockSkew.ThresholdSeconds) * time.Second
+	sorted := append([]corev1.Node(nil), nodes...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].Name < sorted[j].Name })
+	for i := range sorted {
+		node := &sorted[i]
+		lease := leases[node.Name]
+		heartbeat, ok := nodeHeartbeat(node, lease)
+		if !ok {
+			continue
+		}
+		skew, direction := heartbeat.Sub(now), "ahead of"
+		if skew <= threshold {
+			if lease == nil || lease.Spec.RenewTime == nil {
+				continue
+			}
+			skew, direction = now.Sub(heartbeat)-nodeLeaseRenewInterval, "behind"
+			if skew <= threshold {
+				continue
+			}
+		}
+		// ALERT
+		alertmessage := fmt.Sprintf(
+			"Node %s clock is about %s %s the clock of k8eraid, more than the %s allowed, certificates and tokens may be rejected!",
+			node.Name,
+			skew.Round(time.Second),
+			direction,
+			threshold,
+		)
+		alertFn(alertSpec.AlerterType, alertSpec.AlerterName, newAlert(resourceID("node", "", node.Name), "ClockSkew", types.SeverityWarning, alertmessage), alertersConfig)
+	}
+}
+
+// nodeHeartbeat is the latest heartbeat of a node's conditions and Lease, as long as the node is
+// Ready. The node controller marks the Ready condition of a node that stopped reporting as Unknown
+// without touching its heartbeat, which would otherwise look like a clock falling behind.
+func nodeHeartbeat(node *corev1.Node, lease *coordinationv1beta1.Lease) (time.Time, bool) {
+	var heartbeat time.Time
+	ready := false
+	for _, condition := range node.Status.Conditions {
+		if condition.Type == corev1.NodeReady && condition.Status == corev1.ConditionTrue {
+			ready = true
+		}
+		if condition.LastHeartbeatTime.After(heartbeat) {
+			heartbeat = condition.LastHeartbeatTime.Time
+		}
+	}
+	if lease != nil && lease.Spec.RenewTime != nil && lease.Spec.RenewTime.After(heartbeat) {
+		heartbeat = lease.Spec.RenewTime.Time
+	}
+	return heartbeat, ready && !heartbeat.IsZero()
+}
@@ -0,0 +1,147 @@
+// Copyright 2019 Bloomberg Finance LP
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package queries
+
+import (
+	"testing"
+	"time"
+
+	. "github.com/bloomberg/k8eraid/pkgs/types"
+
+	"github.com/stretchr/testify/assert"
+	coordinationv1beta1 "k8s.io/api/coordination/v1beta1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+// heartbeatNode builds a node whose kubelet last reported at heartbeat, by its own clock
+func heartbeatNode(name string, ready corev1.ConditionStatus, heartbeat time.Time) *corev1.Node {
+	node := readyNode(name, ready)
+	node.Labels = map[string]string{"pool": "workers"}
+	node.Status.Conditions[0].LastHeartbeatTime = metav1.Time{Time: heartbeat}
+	node.Status.Conditions = append(node.Status.Conditions, corev1.NodeCondition{
+		Type:              corev1.NodeMemoryPressure,
+		Status:            corev1.ConditionFalse,
+		LastHeartbeatTime: metav1.Time{Time: heartbeat.Add(-time.Second)},
+	})
+	return node
+}
+
+func Test_checkClockSkew(t *testing.T) {
+	_, conf := StubsInit()
+	now := time.Unix(100000, 0)
+	nodes := []corev1.Node{
+		*heartbeatNode("a", corev1.ConditionTrue, now.Add(-5*time.Second)),
+		*heartbeatNode("b", corev1.ConditionTrue, now.Add(-2*time.Second)),
+		*heartbeatNode("c", corev1.ConditionTrue, now.Add(-8*time.Second)),
+		*heartbeatNode("ahead", corev1.ConditionTrue, now.Add(2*time.Minute)),
+		*heartbeatNode("behind", corev1.ConditionTrue, now.Add(-10*time.Minute)),
+		// stopped reporting, its heartbeat is stale rather than skewed
+		*heartbeatNode("lost", corev1.ConditionUnknown, now.Add(-time.Hour)),
+		*readyNode("no-heartbeat", corev1.ConditionTrue),
+	}
+
+	tests := []struct {
+		name     string
+		check    NodeClockSkew
+		nodes    []corev1.Node
+		expected []string
+	}{
+		{
+			name:  "skewed nodes",
+			check: NodeClockSkew{ThresholdSeconds: 30},
+			nodes: nodes,
+			expected: []string{
+				"node/ahead/ClockSkew: Node ahead clock is about 2m5s ahead of the median of 5 nodes with filter pool=workers, more than the 30s allowed, certificates and tokens may be rejected!",
+				"node/behind/ClockSkew: Node behind clock is about 9m55s behind the median of 5 nodes with filter pool=workers, more than the 30s allowed, certificates and tokens may be rejected!",
+			},
+		},
+		{
+			name:  "within threshold",
+			check: NodeClockSkew{ThresholdSeconds: 900},
+			nodes: nodes,
+		},
+		{
+			name:  "too few heartbeats",
+			check: NodeClockSkew{ThresholdSeconds: 30},
+			nodes: nodes[3:],
+		},
+		{
+			name:  "disabled",
+			nodes: nodes,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(subT *testing.T) {
+			var alerts []string
+			alertStub := func(_ string, _ string, alert Alert, _ AlertersConfig) {
+				alerts = append(alerts, alert.Key+": "+alert.Message)
+			}
+			alertSpec := NodeAlertSpec{Name: "*", NodeFilter: "pool=workers", ReportStatus: NodeAlertStatus{ClockSkew: test.check}}
+			checkClockSkew(test.nodes, nil, alertSpec, now, alertStub, conf)
+			assert.Equal(subT, test.expected, alerts)
+		})
+	}
+}
+
+func Test_checkClockSkew_Monitor(t *testing.T) {
+	_, conf := StubsInit()
+	now := time.Unix(100000, 0)
+	renewed := func(at time.Time) *coordinationv1beta1.Lease {
+		return &coordinationv1beta1.Lease{Spec: coordinationv1beta1.LeaseSpec{RenewTime: &metav1.MicroTime{Time: at}}}
+	}
+	nodes := []corev1.Node{
+		*heartbeatNode("ahead", corev1.ConditionTrue, now.Add(2*time.Minute)),
+		// kubelets renewing a Lease update their status every few minutes
+		*heartbeatNode("reported-earlier", corev1.ConditionTrue, now.Add(-4*time.Minute)),
+		*heartbeatNode("leased", corev1.ConditionTrue, now.Add(-4*time.Minute)),
+		*heartbeatNode("behind", corev1.ConditionTrue, now.Add(-5*time.Minute)),
+		*heartbeatNode("lost", corev1.ConditionUnknown, now.Add(-time.Hour)),
+	}
+	leases := map[string]*coordinationv1beta1.Lease{
+		"leased": renewed(now.Add(-3 * time.Second)),
+		"behind": renewed(now.Add(-2 * time.Minute)),
+		"lost":   renewed(now.Add(-time.Hour)),
+	}
+
+	var alerts []string
+	alertStub := func(_ string, _ string, alert Alert, _ AlertersConfig) {
+		alerts = append(alerts, alert.Key+": "+alert.Message)
+	}
+	alertSpec := NodeAlertSpec{Name: "*", ReportStatus: NodeAlertStatus{ClockSkew: NodeClockSkew{ThresholdSeconds: 30, Reference: ClockSkewReferenceMonitor}}}
+	checkClockSkew(nodes, leases, alertSpec, now, alertStub, conf)
+	assert.Equal(t, []string{
+		"node/ahead/ClockSkew: Node ahead clock is about 2m0s ahead of the clock of k8eraid, more than the 30s allowed, certificates and tokens may be rejected!",
+		"node/behind/ClockSkew: Node behind clock is about 1m50s behind the clock of k8eraid, more than the 30s allowed, certificates and tokens may be rejected!",
+	}, alerts, "only nodes with a Lease can be found behind")
+}
+
+func Test_PollNode_ClockSkew(t *testing.T) {
+	_, conf := StubsInit()
+	now := time.Now()
+	client := fake.NewSimpleClientset(
+		heartbeatNode("a", corev1.ConditionTrue, now),
+		heartbeatNode("b", corev1.ConditionTrue, now.Add(-time.Second)),
+		heartbeatNode("ahead", corev1.ConditionTrue, now.Add(time.Hour)),
+	)
+	var keys []string
+	alertStub := func(_ string, _ string, alert Alert, _ AlertersConfig) {
+		keys = append(keys, alert.Key)
+	}
+	alertSpec := NodeAlertSpec{Name: "*", NodeFilter: "pool=workers", ReportStatus: NodeAlertStatus{ClockSkew: NodeClockSkew{ThresholdSeconds: 60}}}
+	assert.NoError(t, PollNode(client, alertSpec, defaultTickerTime, alertStub, conf))
+	assert.Equal(t, []string{"node/ahead/ClockSkew"}, keys)
+}
@@ -0,0 +1,39 @@
+// Copyright 2019 Bloomberg Finance LP
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package queries
+
+import (
+	"fmt"
+
+	"github.com/bloomberg/k8eraid/pkgs/alerters"
+	"github.com/bloomberg/k8eraid/pkgs/types"
+)
+
+// RouteByCluster returns an alerters.Middleware naming cluster in every alert and its message, and
+// re-routing the alerts bound for the alerters that cluster overrides. Alerts pass unchanged when the
+// cluster has no name.
+func RouteByCluster(cluster string, overrides types.ClusterAlerters) alerters.Middleware {
+	return func(next alerters.Alerter) alerters.Alerter {
+		if cluster == "" {
+			return next
+		}
+		return alerters.AlerterFunc(func(alerterType string, alerterName string, alert types.Alert, alertersConfig types.AlertersConfig) {
+			alert.Cluster = cluster
+			alert.Message = fmt.Sprintf("%s (cluster: %s)", alert.Message, cluster)
+			alerterType, alerterName = overrides.Route(cluster, alerterType, alerterName)
+			next.Alert(alerterType, alerterName, alert, alertersConfig)
+		})
+	}
+}
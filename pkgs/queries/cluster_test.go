@@ -0,0 +1,61 @@
+// Copyright 2019 Bloomberg Finance LP
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package queries
+
+import (
+	"testing"
+
+	"github.com/bloomberg/k8eraid/pkgs/alerters"
+	. "github.com/bloomberg/k8eraid/pkgs/types"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_RouteByCluster(t *testing.T) {
+	_, conf := StubsInit()
+	overrides := ClusterAlerters{
+		"prod-eu": {
+			{AlerterType: "slack", AlerterName: "oncall", ToAlerterType: "pagerdutyV2", ToAlerterName: "prod-eu"},
+			{AlerterType: "slack", ToAlerterType: "slack", ToAlerterName: "prod-eu"},
+		},
+		"dev": {{ToAlerterType: "slack", ToAlerterName: "dev"}},
+	}
+	tests := []struct {
+		cluster     string
+		alerterType string
+		alerterName string
+		expected    string
+		message     string
+	}{
+		{cluster: "prod-eu", alerterType: "slack", alerterName: "oncall", expected: "pagerdutyV2/prod-eu", message: "down (cluster: prod-eu)"},
+		{cluster: "prod-eu", alerterType: "slack", alerterName: "team", expected: "slack/prod-eu", message: "down (cluster: prod-eu)"},
+		{cluster: "prod-eu", alerterType: "smtp", alerterName: "mail", expected: "smtp/mail", message: "down (cluster: prod-eu)"},
+		{cluster: "dev", alerterType: "pagerdutyV2", alerterName: "pager", expected: "slack/dev", message: "down (cluster: dev)"},
+		{cluster: "staging", alerterType: "slack", alerterName: "oncall", expected: "slack/oncall", message: "down (cluster: staging)"},
+		{cluster: "", alerterType: "slack", alerterName: "oncall", expected: "slack/oncall", message: "down"},
+	}
+	for _, test := range tests {
+		var routed string
+		var delivered Alert
+		alertFn := alerters.Chain(alerters.AlerterFunc(func(alerterType string, alerterName string, alert Alert, _ AlertersConfig) {
+			routed = alerterType + "/" + alerterName
+			delivered = alert
+		}), RouteByCluster(test.cluster, overrides)).Alert
+		alertFn(test.alerterType, test.alerterName, newAlert("node/a", "Ready", SeverityCritical, "down"), conf)
+		assert.Equal(t, test.expected, routed, "%s %s/%s", test.cluster, test.alerterType, test.alerterName)
+		assert.Equal(t, test.message, delivered.Message)
+		assert.Equal(t, test.cluster, delivered.Cluster)
+	}
+}
@@ -0,0 +1,320 @@
+// Copyright 2019 Bloomberg Finance LP
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package queries
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/bloomberg/k8eraid/pkgs/state"
+	"github.com/bloomberg/k8eraid/pkgs/types"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/client-go/kubernetes"
+)
+
+// configObject is a ConfigMap or a Secret, with the data of both kinds read alike
+type configObject struct {
+	kind      string
+	namespace string
+	name      string
+	labels    map[string]string
+	data      map[string][]byte
+	// secretType is the type of Secrets, empty for ConfigMaps
+	secretType corev1.SecretType
+}
+
+func (o *configObject) resource() string {
+	return resourceID(strings.ToLower(o.kind), o.namespace, o.name)
+}
+
+// PollConfigObject function takes inputs and iterates across ConfigMaps or Secrets in the kubernetes cluster, triggering alerts as needed.
+func PollConfigObject(
+	clientset kubernetes.Interface,
+	alertSpec types.ConfigObjectAlertSpec,
+	tickertime int64,
+	alertFn alertFunction,
+	alertersConfig types.AlertersConfig,
+) error {
+	var objects []configObject
+
+	// Check rules with matching literal object name
+	if alertSpec.Name != "*" {
+		if alertSpec.ConfigFilterNamespace == "" {
+			return &PollErr{
+				Message: fmt.Sprintf("%s rule for %s has no namespace filter specified, ignoring", alertSpec.Kind, alertSpec.Name),
+			}
+		}
+		object, objecterr := getConfigObject(clientset, alertSpec.Kind, alertSpec.ConfigFilterNamespace, alertSpec.Name)
+		if apierrors.IsNotFound(objecterr) {
+			if alertSpec.ReportStatus.Missing {
+				missing := configObject{kind: alertSpec.Kind, namespace: alertSpec.ConfigFilterNamespace, name: alertSpec.Name}
+				// ALERT
+				alertmessage := fmt.Sprintf("%s %s/%s does not exist!", missing.kind, missing.namespace, missing.name)
+				alert := newAlert(missing.resource(), "Missing", types.SeverityCritical, alertmessage)
+				alertFn(alertSpec.AlerterType, alertSpec.AlerterName, alert, alertersConfig)
+			}
+			return nil
+		}
+		if objecterr != nil {
+			return &PollErr{
+				Message: fmt.Sprintf("Error fetching %s %s: %s", alertSpec.Kind, alertSpec.Name, objecterr.Error()),
+			}
+		}
+		objects = append(objects, *object)
+		// If the object name is a wildcard, list based on filter and iterate through
+	} else {
+		selector, selectorerr := labels.Parse(alertSpec.ConfigFilterLabel)
+		if selectorerr != nil {
+			return &PollErr{
+				Message: fmt.Sprintf("%s rule for global has incorrect label filter specified (filter was: %s), ignoring", alertSpec.Kind, alertSpec.ConfigFilterLabel),
+			}
+		}
+		// Every object is listed, as the objects pods reference are looked up whatever their labels
+		all, listerr := listConfigObjects(clientset, alertSpec.Kind, alertSpec.ConfigFilterNamespace)
+		if listerr != nil {
+			return listerr
+		}
+		if alertSpec.ReportStatus.Missing {
+			if err := checkConfigReferences(clientset, all, alertSpec, alertFn, alertersConfig); err != nil {
+				return err
+			}
+		}
+		for _, object := range all {
+			if selector.Matches(labels.Set(object.labels)) {
+				objects = append(objects, object)
+			}
+		}
+	}
+
+	checkConfigObjects(objects, alertSpec, alertFn, alertersConfig)
+	if alertSpec.ReportStatus.CertificateExpiryDays > 0 {
+		checkCertificateExpiry(objects, alertSpec, time.Now(), alertFn, alertersConfig)
+	}
+	if alertSpec.ReportStatus.CredentialExpiryDays > 0 {
+		checkCredentialExpiry(objects, alertSpec, time.Now(), alertFn, alertersConfig)
+	}
+	return nil
+}
+
+// getConfigObject fetches the ConfigMap or Secret namespace/name
+func getConfigObject(clientset kubernetes.Interface, kind string, namespace string, name string) (*configObject, error) {
+	object := &configObject{kind: kind, namespace: namespace, name: name, data: map[string][]byte{}}
+	if kind == types.ConfigObjectSecret {
+		secret, err := clientset.CoreV1().Secrets(namespace).Get(name, metav1.GetOptions{})
+		if err != nil {
+			return nil, err
+		}
+		object.labels, object.secretType = secret.Labels, secret.Type
+		for key, value := range secret.Data {
+			object.data[key] = value
+		}
+		return object, nil
+	}
+	configMap, err := clientset.CoreV1().ConfigMaps(namespace).Get(name, metav1.GetOptions{})
+	if err != nil {
+		return nil, err
+	}
+	object.labels = configMap.Labels
+	for key, value := range configMap.Data {
+		object.data[key] = []byte(value)
+	}
+	for key, value := range configMap.BinaryData {
+		object.data[key] = value
+	}
+	return object, nil
+}
+
+// listConfigObjects lists the ConfigMaps or Secrets of namespace, or of every namespace when it is empty
+func listConfigObjects(clientset kubernetes.Interface, kind string, namespace string) ([]configObject, error) {
+	var objects []configObject
+	listopts := metav1.ListOptions{TimeoutSeconds: &timeout}
+	if kind == types.ConfigObjectSecret {
+		secrets, secretserr := clientset.CoreV1().Secrets(namespace).List(listopts)
+		if secretserr != nil {
+			return nil, &PollErr{
+				Message: fmt.Sprintf("Unable to list Secrets: %s", secretserr.Error()),
+			}
+		}
+		for _, secret := range secrets.Items {
+			object := configObject{kind: kind, namespace: secret.Namespace, name: secret.Name, labels: secret.Labels, data: map[string][]byte{}, secretType: secret.Type}
+			for key, value := range secret.Data {
+				object.data[key] = value
+			}
+			objects = append(objects, object)
+		}
+		return objects, nil
+	}
+	configMaps, configmapserr := clientset.CoreV1().ConfigMaps(namespace).List(listopts)
+	if configmapserr != nil {
+		return nil, &PollErr{
+			Message: fmt.Sprintf("Unable to list ConfigMaps: %s", configmapserr.Error()),
+		}
+	}
+	for _, configMap := range configMaps.Items {
+		object := configObject{kind: kind, namespace: configMap.Namespace, name: configMap.Name, labels: configMap.Labels, data: map[string][]byte{}}
+		for key, value := range configMap.Data {
+			object.data[key] = []byte(value)
+		}
+		for key, value := range configMap.BinaryData {
+			object.data[key] = value
+		}
+		objects = append(objects, object)
+	}
+	return objects, nil
+}
+
+// checkConfigReferences alerts on the objects of the rule's kind that pods or deployments reference
+// without marking the reference optional, but that do not exist. Pods referencing a missing object
+// do not start.
+func checkConfigReferences(
+	clientset kubernetes.Interface,
+	existing []configObject,
+	alertSpec types.ConfigObjectAlertSpec,
+	alertFn alertFunction,
+	alertersConfig types.AlertersConfig,
+) error {
+	references, err := podSpecReferences(clientset, alertSpec.ConfigFilterNamespace)
+	if err != nil {
+		return err
+	}
+	required := references.requiredConfigMaps
+	if alertSpec.Kind == types.ConfigObjectSecret {
+		required = references.requiredSecrets
+	}
+	exists := map[string]bool{}
+	for _, object := range existing {
+		exists[object.namespace+"/"+object.name] = true
+	}
+	var missing []string
+	for reference := range required {
+		if !exists[reference] {
+			missing = append(missing, reference)
+		}
+	}
+	sort.Strings(missing)
+	for _, reference := range missing {
+		fields := strings.SplitN(reference, "/", 2)
+		object := configObject{kind: alertSpec.Kind, namespace: fields[0], name: fields[1]}
+		// ALERT
+		alertmessage := fmt.Sprintf(
+			"%s %s does not exist, but pods or deployments of its namespace reference it! Pods referencing it do not start.",
+			object.kind, reference,
+		)
+		alert := newAlert(object.resource(), "Missing", types.SeverityCritical, alertmessage)
+		alertFn(alertSpec.AlerterType, alertSpec.AlerterName, alert, alertersConfig)
+	}
+	return nil
+}
+
+// checkConfigObjects alerts on the objects without data and, by the hash of every value it keeps
+// across ticks, on those whose data changed since the previous tick. Only the names of the keys
+// that changed are reported, so the values of Secrets never leave the cluster.
+func checkConfigObjects(
+	objects []configObject,
+	alertSpec types.ConfigObjectAlertSpec,
+	alertFn alertFunction,
+	alertersConfig types.AlertersConfig,
+) {
+	sort.Slice(objects, func(i, j int) bool { return objects[i].resource() < objects[j].resource() })
+	if alertSpec.ReportStatus.Empty {
+		for _, object := range objects {
+			if len(object.data) > 0 {
+				continue
+			}
+			// ALERT
+			alertmessage := fmt.Sprintf("%s %s/%s has no data!", object.kind, object.namespace, object.name)
+			alert := newAlert(object.resource(), "Empty", types.SeverityWarning, alertmessage)
+			alertFn(alertSpec.AlerterType, alertSpec.AlerterName, alert, alertersConfig)
+		}
+	}
+	if !alertSpec.ReportStatus.Changed {
+		return
+	}
+
+	changed := map[string]string{}
+	key := specKey("configobject", alertSpec.Kind+"/"+alertSpec.Name, alertSpec.ConfigFilterNamespace+"|"+alertSpec.ConfigFilterLabel)
+	stateStore.UpdateSnapshot(key, func(previous state.Snapshot, _ bool) state.Snapshot {
+		current := state.Snapshot{}
+		for _, object := range objects {
+			resource := object.resource()
+			// keys of ConfigMaps and Secrets may not contain a #
+			current[resource] = ""
+			for dataKey, value := range object.data {
+				current[resource+"#"+dataKey] = fmt.Sprintf("%x", sha256.Sum256(value))
+			}
+			if _, seen := previous[resource]; !seen {
+				continue
+			}
+			if change := configDataChange(resource, previous, current); change != "" {
+				changed[resource] = change
+			}
+		}
+		return current
+	})
+
+	for _, object := range objects {
+		change, ok := changed[object.resource()]
+		if !ok {
+			continue
+		}
+		// ALERT
+		alertmessage := fmt.Sprintf("%s %s/%s changed since the previous poll! %s", object.kind, object.namespace, object.name, change)
+		alert := newAlert(object.resource(), "Changed", types.SeverityWarning, alertmessage)
+		alertFn(alertSpec.AlerterType, alertSpec.AlerterName, alert, alertersConfig)
+	}
+}
+
+// configDataChange describes which keys of the data of resource were changed, added or removed
+// between the previous and the current snapshot, or returns "" if none were
+func configDataChange(resource string, previous state.Snapshot, current state.Snapshot) string {
+	prefix := resource + "#"
+	var changed, added, removed []string
+	for entry, hash := range current {
+		if !strings.HasPrefix(entry, prefix) {
+			continue
+		}
+		before, ok := previous[entry]
+		if !ok {
+			added = append(added, strings.TrimPrefix(entry, prefix))
+		} else if before != hash {
+			changed = append(changed, strings.TrimPrefix(entry, prefix))
+		}
+	}
+	for entry := range previous {
+		if _, ok := current[entry]; strings.HasPrefix(entry, prefix) && !ok {
+			removed = append(removed, strings.TrimPrefix(entry, prefix))
+		}
+	}
+
+	var parts []string
+	for _, keys := range []struct {
+		description string
+		names       []string
+	}{{"Changed", changed}, {"Added", added}, {"Removed", removed}} {
+		if len(keys.names) == 0 {
+			continue
+		}
+		sort.Strings(keys.names)
+		parts = append(parts, fmt.Sprintf("%s keys: %s.", keys.description, strings.Join(keys.names, ", ")))
+	}
+	return strings.Join(parts, " ")
+}
@@ -0,0 +1,85 @@
+// Copyright 2019 Bloomberg Finance LP
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package queries
+
+import (
+	"testing"
+
+	"github.com/bloomberg/k8eraid/pkgs/state"
+	. "github.com/bloomberg/k8eraid/pkgs/types"
+
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func Test_PollConfigObject(t *testing.T) {
+	stateStore = state.NewStore()
+	_, conf := StubsInit()
+	optional := true
+	pod := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: "web-1", Namespace: metav1.NamespaceDefault},
+		Spec: corev1.PodSpec{
+			Volumes: []corev1.Volume{
+				{Name: "config", VolumeSource: corev1.VolumeSource{ConfigMap: &corev1.ConfigMapVolumeSource{LocalObjectReference: corev1.LocalObjectReference{Name: "web"}}}},
+				{Name: "extra", VolumeSource: corev1.VolumeSource{ConfigMap: &corev1.ConfigMapVolumeSource{LocalObjectReference: corev1.LocalObjectReference{Name: "web-extra"}, Optional: &optional}}},
+				{Name: "flags", VolumeSource: corev1.VolumeSource{ConfigMap: &corev1.ConfigMapVolumeSource{LocalObjectReference: corev1.LocalObjectReference{Name: "web-flags"}}}},
+			},
+			Containers: []corev1.Container{{Name: "app", EnvFrom: []corev1.EnvFromSource{{SecretRef: &corev1.SecretEnvSource{LocalObjectReference: corev1.LocalObjectReference{Name: "web-db"}}}}}},
+		},
+	}
+	web := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: "web", Namespace: metav1.NamespaceDefault, Labels: map[string]string{"app": "web"}},
+		Data:       map[string]string{"listen": ":8080", "workers": "4", "tls": "off"},
+	}
+	empty := &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: "web-empty", Namespace: metav1.NamespaceDefault, Labels: map[string]string{"app": "web"}}}
+	other := &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: "other", Namespace: metav1.NamespaceDefault}}
+	client := fake.NewSimpleClientset(pod, web, empty, other)
+	poll := func(spec ConfigObjectAlertSpec) []string {
+		messages := []string{}
+		err := PollConfigObject(client, spec, defaultTickerTime, func(_ string, _ string, alert Alert, _ AlertersConfig) {
+			messages = append(messages, alert.Key+": "+alert.Message)
+		}, conf)
+		assert.NoError(t, err)
+		return messages
+	}
+	everything := ConfigObjectAlertStatus{Missing: true, Empty: true, Changed: true}
+	configMaps := ConfigObjectAlertSpec{Name: "*", Kind: ConfigObjectConfigMap, ConfigFilterLabel: "app=web", ReportStatus: everything}
+
+	assert.Equal(t, []string{
+		"configmap/default/web-flags/Missing: ConfigMap default/web-flags does not exist, but pods or deployments of its namespace reference it! Pods referencing it do not start.",
+		"configmap/default/web-empty/Empty: ConfigMap default/web-empty has no data!",
+	}, poll(configMaps), "optional references and objects outside the label filter are left alone")
+	assert.Equal(t, []string{
+		"secret/default/web-db/Missing: Secret default/web-db does not exist, but pods or deployments of its namespace reference it! Pods referencing it do not start.",
+	}, poll(ConfigObjectAlertSpec{Name: "*", Kind: ConfigObjectSecret, ReportStatus: everything}))
+
+	web.Data = map[string]string{"listen": ":9090", "workers": "4", "timeout": "30s"}
+	_, err := client.CoreV1().ConfigMaps(metav1.NamespaceDefault).Update(web)
+	assert.NoError(t, err)
+	configMaps.ReportStatus = ConfigObjectAlertStatus{Changed: true}
+	assert.Equal(t, []string{
+		"configmap/default/web/Changed: ConfigMap default/web changed since the previous poll! Changed keys: listen. Added keys: timeout. Removed keys: tls.",
+	}, poll(configMaps))
+	assert.Empty(t, poll(configMaps), "unchanged objects are not reported again")
+
+	named := ConfigObjectAlertSpec{Name: "web-flags", Kind: ConfigObjectConfigMap, ConfigFilterNamespace: metav1.NamespaceDefault, ReportStatus: everything}
+	assert.Equal(t, []string{"configmap/default/web-flags/Missing: ConfigMap default/web-flags does not exist!"}, poll(named))
+	named.Name = "web-empty"
+	assert.Equal(t, []string{"configmap/default/web-empty/Empty: ConfigMap default/web-empty has no data!"}, poll(named))
+
+	assert.Error(t, PollConfigObject(client, ConfigObjectAlertSpec{Name: "web", Kind: ConfigObjectConfigMap}, defaultTickerTime, nil, conf), "named rules need a namespace")
+}
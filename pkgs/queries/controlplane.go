@@ -0,0 +1,123 @@
+// Copyright 2019 Bloomberg Finance LP
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package queries
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/bloomberg/k8eraid/pkgs/types"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// controlPlaneLabels are the labels kubeadm, and most installers running the control plane as pods,
+// give the pods of each component in kube-system
+var controlPlaneLabels = map[string]string{
+	types.ControlPlaneScheduler:         "component=kube-scheduler",
+	types.ControlPlaneControllerManager: "component=kube-controller-manager",
+}
+
+// CheckControlPlane alerts on the scheduler and controller-manager when they are unhealthy. Their
+// ComponentStatus is read when the apiserver serves one, which managed clusters often do for
+// components they run out of sight. Otherwise the component is taken to be healthy when one of its
+// pods in kube-system is ready, and an alert says when neither can be found.
+func CheckControlPlane(
+	clientset kubernetes.Interface,
+	alertSpec types.ControlPlaneAlertSpec,
+	alertFn alertFunction,
+	alertersConfig types.AlertersConfig,
+) error {
+	// ComponentStatuses are deprecated, and may be forbidden or gone, so failing to list them is not an error
+	statuses := map[string]corev1.ComponentStatus{}
+	if list, listerr := clientset.CoreV1().ComponentStatuses().List(metav1.ListOptions{TimeoutSeconds: &timeout}); listerr == nil {
+		for _, status := range list.Items {
+			statuses[status.Name] = status
+		}
+	}
+
+	for _, component := range alertSpec.Components {
+		resource := resourceID("controlplane", "", component)
+		if status, ok := statuses[component]; ok {
+			if healthy, reason := componentHealthy(&status); !healthy {
+				// ALERT
+				alertmessage := fmt.Sprintf("Control plane component %s is unhealthy according to its ComponentStatus: %s", component, reason)
+				alertFn(alertSpec.AlerterType, alertSpec.AlerterName, newAlert(resource, "Unhealthy", types.SeverityCritical, alertmessage), alertersConfig)
+			}
+			continue
+		}
+
+		selector := controlPlaneLabels[component]
+		pods, podserr := clientset.CoreV1().Pods(metav1.NamespaceSystem).List(metav1.ListOptions{
+			LabelSelector:  selector,
+			TimeoutSeconds: &timeout,
+		})
+		if podserr != nil {
+			return &PollErr{
+				Message: fmt.Sprintf("Unable to list the pods of control plane component %s: %s", component, podserr.Error()),
+			}
+		}
+		if len(pods.Items) == 0 {
+			// ALERT
+			alertmessage := fmt.Sprintf(
+				"Control plane component %s has neither a ComponentStatus nor pods labelled %s in %s, so its health cannot be checked! Disable the check where the control plane is managed out of sight.",
+				component, selector, metav1.NamespaceSystem,
+			)
+			alertFn(alertSpec.AlerterType, alertSpec.AlerterName, newAlert(resource, "NotFound", types.SeverityWarning, alertmessage), alertersConfig)
+			continue
+		}
+		var notReady []string
+		for i := range pods.Items {
+			if podReady(&pods.Items[i]) {
+				notReady = nil
+				break
+			}
+			notReady = append(notReady, pods.Items[i].Name)
+		}
+		if len(notReady) > 0 {
+			sort.Strings(notReady)
+			// ALERT
+			alertmessage := fmt.Sprintf(
+				"Control plane component %s has none of its %d pods in %s ready! Not ready: %s.",
+				component, len(notReady), metav1.NamespaceSystem, strings.Join(notReady, ", "),
+			)
+			alertFn(alertSpec.AlerterType, alertSpec.AlerterName, newAlert(resource, "Unhealthy", types.SeverityCritical, alertmessage), alertersConfig)
+		}
+	}
+	return nil
+}
+
+// componentHealthy reads the Healthy condition of status, and why it is not when it is not
+func componentHealthy(status *corev1.ComponentStatus) (bool, string) {
+	for _, condition := range status.Conditions {
+		if condition.Type != corev1.ComponentHealthy {
+			continue
+		}
+		if condition.Status == corev1.ConditionTrue {
+			return true, ""
+		}
+		if condition.Error != "" {
+			return false, condition.Error
+		}
+		if condition.Message != "" {
+			return false, condition.Message
+		}
+		return false, fmt.Sprintf("its Healthy condition is %s", condition.Status)
+	}
+	return false, "it reports no Healthy condition"
+}
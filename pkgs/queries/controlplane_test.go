@@ -0,0 +1,77 @@
+// Copyright 2019 Bloomberg Finance LP
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package queries
+
+import (
+	"testing"
+
+	. "github.com/bloomberg/k8eraid/pkgs/types"
+
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func controlPlanePod(name string, component string, ready corev1.ConditionStatus) *corev1.Pod {
+	return &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: metav1.NamespaceSystem, Labels: map[string]string{"component": component}},
+		Status:     corev1.PodStatus{Conditions: []corev1.PodCondition{{Type: corev1.PodReady, Status: ready}}},
+	}
+}
+
+func Test_CheckControlPlane(t *testing.T) {
+	_, conf := StubsInit()
+	alertSpec := ControlPlaneAlertSpec{Components: []string{ControlPlaneScheduler, ControlPlaneControllerManager}}
+	check := func(objects ...runtime.Object) []string {
+		messages := []string{}
+		err := CheckControlPlane(fake.NewSimpleClientset(objects...), alertSpec, func(_ string, _ string, alert Alert, _ AlertersConfig) {
+			messages = append(messages, alert.Key+": "+alert.Message)
+		}, conf)
+		assert.NoError(t, err)
+		return messages
+	}
+	unhealthy := &corev1.ComponentStatus{
+		ObjectMeta: metav1.ObjectMeta{Name: "scheduler"},
+		Conditions: []corev1.ComponentCondition{{
+			Type:   corev1.ComponentHealthy,
+			Status: corev1.ConditionFalse,
+			Error:  "Get http://127.0.0.1:10251/healthz: dial tcp 127.0.0.1:10251: connect: connection refused",
+		}},
+	}
+	healthy := &corev1.ComponentStatus{
+		ObjectMeta: metav1.ObjectMeta{Name: "controller-manager"},
+		Conditions: []corev1.ComponentCondition{{Type: corev1.ComponentHealthy, Status: corev1.ConditionTrue, Message: "ok"}},
+	}
+
+	assert.Equal(t, []string{
+		"controlplane/scheduler/Unhealthy: Control plane component scheduler is unhealthy according to its ComponentStatus: Get http://127.0.0.1:10251/healthz: dial tcp 127.0.0.1:10251: connect: connection refused",
+	}, check(unhealthy, healthy, controlPlanePod("kube-scheduler-master-1", "kube-scheduler", corev1.ConditionTrue)),
+		"a ComponentStatus is trusted over the pods")
+
+	assert.Equal(t, []string{
+		"controlplane/controller-manager/Unhealthy: Control plane component controller-manager has none of its 2 pods in kube-system ready! Not ready: kube-controller-manager-master-1, kube-controller-manager-master-2.",
+	}, check(
+		controlPlanePod("kube-scheduler-master-1", "kube-scheduler", corev1.ConditionFalse),
+		controlPlanePod("kube-scheduler-master-2", "kube-scheduler", corev1.ConditionTrue),
+		controlPlanePod("kube-controller-manager-master-2", "kube-controller-manager", corev1.ConditionFalse),
+		controlPlanePod("kube-controller-manager-master-1", "kube-controller-manager", corev1.ConditionUnknown),
+	), "one ready pod is enough")
+
+	assert.Equal(t, []string{
+		"controlplane/scheduler/NotFound: Control plane component scheduler has neither a ComponentStatus nor pods labelled component=kube-scheduler in kube-system, so its health cannot be checked! Disable the check where the control plane is managed out of sight.",
+	}, check(healthy))
+}
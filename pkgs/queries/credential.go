@@ -0,0 +1,179 @@
+// Copyright 2019 Bloomberg Finance LP
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package queries
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/bloomberg/k8eraid/pkgs/types"
+
+	"gopkg.in/yaml.v2"
+	corev1 "k8s.io/api/core/v1"
+)
+
+// defaultKubeconfigKeys are the Secret keys kubeconfig files are looked for under
+var defaultKubeconfigKeys = []string{"kubeconfig", "config"}
+
+// credential is a credential a Secret holds and when it stops being accepted
+type credential struct {
+	description string
+	notAfter    time.Time
+}
+
+// kubeconfigFile is the part of a kubeconfig file holding the credentials of its users
+type kubeconfigFile struct {
+	Users []struct {
+		Name string `yaml:"name"`
+		User struct {
+			ClientCertificateData string `yaml:"client-certificate-data"`
+			Token                 string `yaml:"token"`
+		} `yaml:"user"`
+	} `yaml:"users"`
+}
+
+// checkCredentialExpiry alerts on the Secrets holding a credential that expires within the rule's
+// number of days, as critical once it has expired, and on those whose credentials can not be read.
+// Automation such as CI pipelines and out-of-cluster controllers often runs on long-lived tokens and
+// kubeconfig files nobody remembers to rotate. The credential expiring first is reported. Tokens
+// without an expiry, such as legacy service account tokens, are not alerted on.
+func checkCredentialExpiry(
+	objects []configObject,
+	alertSpec types.ConfigObjectAlertSpec,
+	now time.Time,
+	alertFn alertFunction,
+	alertersConfig types.AlertersConfig,
+) {
+	window := time.Duration(alertSpec.ReportStatus.CredentialExpiryDays) * 24 * time.Hour
+	keys := alertSpec.ReportStatus.KubeconfigKeys
+	if len(keys) == 0 {
+		keys = defaultKubeconfigKeys
+	}
+	for _, object := range objects {
+		credentials, err := secretCredentials(&object, keys)
+		if err != nil {
+			// ALERT
+			alertmessage := fmt.Sprintf("Secret %s/%s has an unreadable %s!", object.namespace, object.name, err.Error())
+			alert := newAlert(object.resource(), "CredentialExpiry", types.SeverityWarning, alertmessage)
+			alertFn(alertSpec.AlerterType, alertSpec.AlerterName, alert, alertersConfig)
+			continue
+		}
+		if len(credentials) == 0 {
+			continue
+		}
+		first := credentials[0]
+		for _, candidate := range credentials[1:] {
+			if candidate.notAfter.Before(first.notAfter) {
+				first = candidate
+			}
+		}
+		left := first.notAfter.Sub(now)
+		if left > window {
+			continue
+		}
+		// ALERT
+		alertmessage := fmt.Sprintf(
+			"Secret %s/%s has a %s that expires in %s, within %d days! It is valid until %s.",
+			object.namespace, object.name, first.description, formatLongDuration(left), alertSpec.ReportStatus.CredentialExpiryDays,
+			first.notAfter.UTC().Format(time.RFC3339),
+		)
+		severity := types.SeverityWarning
+		if left <= 0 {
+			alertmessage = fmt.Sprintf(
+				"Secret %s/%s has a %s that expired %s ago, on %s!",
+				object.namespace, object.name, first.description, formatLongDuration(-left), first.notAfter.UTC().Format(time.RFC3339),
+			)
+			severity = types.SeverityCritical
+		}
+		alert := newAlert(object.resource(), "CredentialExpiry", severity, alertmessage)
+		alertFn(alertSpec.AlerterType, alertSpec.AlerterName, alert, alertersConfig)
+	}
+}
+
+// secretCredentials returns the credentials with an expiry that object holds. The error names the
+// key that can not be read.
+func secretCredentials(object *configObject, kubeconfigKeys []string) ([]credential, error) {
+	var credentials []credential
+	if object.secretType == corev1.SecretTypeServiceAccountToken {
+		subject, expiry, err := tokenExpiry(string(object.data[corev1.ServiceAccountTokenKey]))
+		if err != nil {
+			return nil, fmt.Errorf("%s: %s", corev1.ServiceAccountTokenKey, err.Error())
+		}
+		if !expiry.IsZero() {
+			credentials = append(credentials, credential{description: "token for " + subject, notAfter: expiry})
+		}
+	}
+	for _, key := range kubeconfigKeys {
+		data, ok := object.data[key]
+		if !ok {
+			continue
+		}
+		var kubeconfig kubeconfigFile
+		if err := yaml.Unmarshal(data, &kubeconfig); err != nil {
+			return nil, fmt.Errorf("kubeconfig in %s: %s", key, err.Error())
+		}
+		for _, user := range kubeconfig.Users {
+			if user.User.ClientCertificateData != "" {
+				pemData, err := base64.StdEncoding.DecodeString(user.User.ClientCertificateData)
+				if err != nil {
+					return nil, fmt.Errorf("client certificate of user %s in %s: %s", user.Name, key, err.Error())
+				}
+				certificate, err := firstExpiringCertificate(pemData)
+				if err != nil {
+					return nil, fmt.Errorf("client certificate of user %s in %s: %s", user.Name, key, err.Error())
+				}
+				credentials = append(credentials, credential{
+					description: fmt.Sprintf("client certificate for user %s in %s", user.Name, key),
+					notAfter:    certificate.NotAfter,
+				})
+			}
+			// static tokens are not JWTs and never expire
+			if _, expiry, err := tokenExpiry(user.User.Token); err == nil && !expiry.IsZero() {
+				credentials = append(credentials, credential{
+					description: fmt.Sprintf("token for user %s in %s", user.Name, key),
+					notAfter:    expiry,
+				})
+			}
+		}
+	}
+	return credentials, nil
+}
+
+// tokenExpiry reads the subject and the expiry of a JWT, without verifying it. The expiry is zero
+// for tokens that do not expire.
+func tokenExpiry(token string) (string, time.Time, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return "", time.Time{}, fmt.Errorf("not a JWT")
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(parts[1], "="))
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	var claims struct {
+		Subject string  `json:"sub"`
+		Expiry  float64 `json:"exp"`
+	}
+	if err := json.Unmarshal(payload, &claims); err != nil {
+		return "", time.Time{}, err
+	}
+	if claims.Expiry == 0 {
+		return claims.Subject, time.Time{}, nil
+	}
+	return claims.Subject, time.Unix(int64(claims.Expiry), 0), nil
+}
@@ -0,0 +1,81 @@
+// Copyright 2019 Bloomberg Finance LP
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package queries
+
+import (
+	"encoding/base64"
+	"fmt"
+	"testing"
+	"time"
+
+	. "github.com/bloomberg/k8eraid/pkgs/types"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+)
+
+func Test_checkCredentialExpiry(t *testing.T) {
+	_, conf := StubsInit()
+	now := time.Unix(1600000000, 0)
+	certificate, err := sampleCertificate("ci-deployer", now.Add(7*24*time.Hour))
+	require.NoError(t, err)
+	kubeconfig := fmt.Sprintf(`apiVersion: v1
+kind: Config
+users:
+- name: ci
+  user:
+    client-certificate-data: %s
+- name: robot
+  user:
+    token: %s
+- name: static
+  user:
+    token: 0123456789abcdef
+`, base64.StdEncoding.EncodeToString(certificate), sampleToken("robot", now.Add(3*24*time.Hour)))
+	secret := func(name string, secretType corev1.SecretType, key string, value string) configObject {
+		return configObject{kind: ConfigObjectSecret, namespace: "ci", name: name, secretType: secretType, data: map[string][]byte{key: []byte(value)}}
+	}
+	objects := []configObject{
+		secret("deployer-token", corev1.SecretTypeServiceAccountToken, corev1.ServiceAccountTokenKey, sampleToken("system:serviceaccount:ci:deployer", now.Add(-2*24*time.Hour))),
+		secret("legacy-token", corev1.SecretTypeServiceAccountToken, corev1.ServiceAccountTokenKey, sampleToken("system:serviceaccount:ci:legacy", time.Time{})),
+		secret("fresh-token", corev1.SecretTypeServiceAccountToken, corev1.ServiceAccountTokenKey, sampleToken("system:serviceaccount:ci:fresh", now.Add(90*24*time.Hour))),
+		secret("garbled-token", corev1.SecretTypeServiceAccountToken, corev1.ServiceAccountTokenKey, "not a token"),
+		secret("pipeline", corev1.SecretTypeOpaque, "kubeconfig", kubeconfig),
+		secret("garbled-kubeconfig", corev1.SecretTypeOpaque, "config", "users: {"),
+		secret("unrelated", corev1.SecretTypeOpaque, "password", "hunter2"),
+	}
+
+	var alerts []string
+	checkCredentialExpiry(objects, ConfigObjectAlertSpec{ReportStatus: ConfigObjectAlertStatus{CredentialExpiryDays: 14}}, now, func(_ string, _ string, alert Alert, _ AlertersConfig) {
+		alerts = append(alerts, alert.Severity+" "+alert.Key+": "+alert.Message)
+	}, conf)
+	assert.Equal(t, []string{
+		"critical secret/ci/deployer-token/CredentialExpiry: Secret ci/deployer-token has a token for system:serviceaccount:ci:deployer that expired 2 days ago, on 2020-09-11T12:26:40Z!",
+		"warning secret/ci/garbled-token/CredentialExpiry: Secret ci/garbled-token has an unreadable token: not a JWT!",
+		"warning secret/ci/pipeline/CredentialExpiry: Secret ci/pipeline has a token for user robot in kubeconfig that expires in 3 days, within 14 days! It is valid until 2020-09-16T12:26:40Z.",
+		"warning secret/ci/garbled-kubeconfig/CredentialExpiry: Secret ci/garbled-kubeconfig has an unreadable kubeconfig in config: yaml: line 1: did not find expected node content!",
+	}, alerts, "the credential of a kubeconfig expiring first is reported")
+}
+
+// sampleToken builds an unsigned JWT for subject, which expires at expiry unless it is zero
+func sampleToken(subject string, expiry time.Time) string {
+	claims := fmt.Sprintf(`{"sub":%q}`, subject)
+	if !expiry.IsZero() {
+		claims = fmt.Sprintf(`{"sub":%q,"exp":%d}`, subject, expiry.Unix())
+	}
+	encode := base64.RawURLEncoding.EncodeToString
+	return encode([]byte(`{"alg":"none"}`)) + "." + encode([]byte(claims)) + "." + encode([]byte("signature"))
+}
@@ -0,0 +1,189 @@
+// Copyright 2019 Bloomberg Finance LP
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package queries
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/bloomberg/k8eraid/pkgs/types"
+
+	batchv1 "k8s.io/api/batch/v1"
+	batchv1beta1 "k8s.io/api/batch/v1beta1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// PollCronJob function takes inputs and iterates across cronjobs in the kubernetes cluster, triggering alerts as needed.
+// The runs of a CronJob are read from the Jobs it owns, so only the runs its history limits keep are seen.
+func PollCronJob(
+	clientset kubernetes.Interface,
+	alertSpec types.CronJobAlertSpec,
+	tickertime int64,
+	alertFn alertFunction,
+	alertersConfig types.AlertersConfig,
+) error {
+	var cronJobs []batchv1beta1.CronJob
+
+	// If the cronjob is not wildcard, search by name
+	if alertSpec.Name != "*" {
+		if alertSpec.CronJobFilter == "" {
+			return &PollErr{
+				Message: fmt.Sprintf("CronJob rule for %s has no namespace filter specified, ignoring", alertSpec.Name),
+			}
+		}
+		cronJob, cronjoberr := clientset.BatchV1beta1().CronJobs(alertSpec.CronJobFilter).Get(alertSpec.Name, metav1.GetOptions{})
+		if cronjoberr != nil {
+			return &PollErr{
+				Message: fmt.Sprintf("Error fetching cronjob %s: %s", alertSpec.Name, cronjoberr.Error()),
+			}
+		}
+		cronJobs = append(cronJobs, *cronJob)
+		// If the cronjob is a wildcard, list cronjobs and iterate through
+	} else {
+		if !strings.Contains(alertSpec.CronJobFilter, "=") && alertSpec.CronJobFilter != "" {
+			return &PollErr{
+				Message: fmt.Sprintf("CronJob rule for global has incorrect filter specified (filter was: %s), ignoring", alertSpec.CronJobFilter),
+			}
+		}
+		list, listerr := clientset.BatchV1beta1().CronJobs("").List(metav1.ListOptions{
+			LabelSelector:  alertSpec.CronJobFilter,
+			TimeoutSeconds: &timeout,
+		})
+		if listerr != nil {
+			return &PollErr{
+				Message: fmt.Sprintf("Unable to list CronJobs: %s", listerr.Error()),
+			}
+		}
+		cronJobs = list.Items
+	}
+
+	// Jobs are listed once per namespace, however many of its CronJobs the rule checks
+	jobsByNamespace := map[string][]batchv1.Job{}
+	for i := range cronJobs {
+		cronJob := &cronJobs[i]
+		jobs, listed := jobsByNamespace[cronJob.Namespace]
+		if !listed {
+			list, listerr := clientset.BatchV1().Jobs(cronJob.Namespace).List(metav1.ListOptions{TimeoutSeconds: &timeout})
+			if listerr != nil {
+				return &PollErr{
+					Message: fmt.Sprintf("Unable to list Jobs of CronJob %s: %s", cronJob.Name, listerr.Error()),
+				}
+			}
+			jobs = list.Items
+			jobsByNamespace[cronJob.Namespace] = jobs
+		}
+		checkCronJob(cronJob, ownedJobs(cronJob, jobs), alertSpec, time.Now(), alertFn, alertersConfig)
+	}
+	return nil
+}
+
+// ownedJobs returns the Jobs cronJob created, newest first
+func ownedJobs(cronJob *batchv1beta1.CronJob, jobs []batchv1.Job) []batchv1.Job {
+	var owned []batchv1.Job
+	for _, job := range jobs {
+		for _, owner := range job.OwnerReferences {
+			if owner.Kind == "CronJob" && owner.UID == cronJob.UID {
+				owned = append(owned, job)
+				break
+			}
+		}
+	}
+	sort.Slice(owned, func(i, j int) bool {
+		if !owned[i].CreationTimestamp.Equal(&owned[j].CreationTimestamp) {
+			return owned[j].CreationTimestamp.Before(&owned[i].CreationTimestamp)
+		}
+		return owned[i].Name > owned[j].Name
+	})
+	return owned
+}
+
+func checkCronJob(
+	cronJob *batchv1beta1.CronJob,
+	jobs []batchv1.Job,
+	alertSpec types.CronJobAlertSpec,
+	now time.Time,
+	alertFn alertFunction,
+	alertersConfig types.AlertersConfig,
+) {
+	resource := resourceID("cronjob", cronJob.Namespace, cronJob.Name)
+
+	if alertSpec.ReportStatus.Suspended && cronJob.Spec.Suspend != nil && *cronJob.Spec.Suspend {
+		// ALERT
+		alertmessage := fmt.Sprintf("CronJob %s/%s is suspended and schedules no runs!", cronJob.Namespace, cronJob.Name)
+		alert := newAlert(resource, "Suspended", types.SeverityWarning, alertmessage)
+		alertFn(alertSpec.AlerterType, alertSpec.AlerterName, alert, alertersConfig)
+	}
+
+	if window := alertSpec.ReportStatus.MaxSinceSuccess; window > 0 {
+		var lastSuccess *metav1.Time
+		for i := range jobs {
+			if jobCondition(&jobs[i], batchv1.JobComplete) != nil && jobs[i].Status.CompletionTime != nil &&
+				(lastSuccess == nil || lastSuccess.Before(jobs[i].Status.CompletionTime)) {
+				lastSuccess = jobs[i].Status.CompletionTime
+			}
+		}
+		var alertmessage string
+		if lastSuccess == nil {
+			if since := now.Sub(cronJob.CreationTimestamp.Time); since > time.Duration(window)*time.Second {
+				alertmessage = fmt.Sprintf(
+					"CronJob %s/%s has no successful run since it was created %s ago, longer than %ds!",
+					cronJob.Namespace, cronJob.Name, formatLongDuration(since), window,
+				)
+			}
+		} else if since := now.Sub(lastSuccess.Time); since > time.Duration(window)*time.Second {
+			alertmessage = fmt.Sprintf(
+				"CronJob %s/%s last succeeded %s ago, longer than %ds!",
+				cronJob.Namespace, cronJob.Name, formatLongDuration(since), window,
+			)
+		}
+		if alertmessage != "" {
+			if cronJob.Status.LastScheduleTime != nil {
+				alertmessage += fmt.Sprintf(" Its last run was scheduled %s ago.", formatLongDuration(now.Sub(cronJob.Status.LastScheduleTime.Time)))
+			} else {
+				alertmessage += " It has never been scheduled."
+			}
+			// ALERT
+			alert := newAlert(resource, "NoRecentSuccess", types.SeverityCritical, alertmessage)
+			alertFn(alertSpec.AlerterType, alertSpec.AlerterName, alert, alertersConfig)
+		}
+	}
+
+	if threshold := alertSpec.ReportStatus.ConsecutiveFailures; threshold > 0 {
+		failures := 0
+		latestFailed := ""
+		for i := range jobs {
+			if jobCondition(&jobs[i], batchv1.JobFailed) != nil {
+				if failures == 0 {
+					latestFailed = jobs[i].Name
+				}
+				failures++
+			} else if jobCondition(&jobs[i], batchv1.JobComplete) != nil {
+				break
+			}
+		}
+		if failures >= threshold {
+			// ALERT
+			alertmessage := fmt.Sprintf(
+				"CronJob %s/%s has failed its last %d runs, at least %d! Its latest failed Job is %s.",
+				cronJob.Namespace, cronJob.Name, failures, threshold, latestFailed,
+			)
+			alert := newAlert(resource, "ConsecutiveFailures", types.SeverityCritical, alertmessage)
+			alertFn(alertSpec.AlerterType, alertSpec.AlerterName, alert, alertersConfig)
+		}
+	}
+}
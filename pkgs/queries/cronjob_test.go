@@ -0,0 +1,108 @@
+// Copyright 2019 Bloomberg Finance LP
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package queries
+
+import (
+	"testing"
+	"time"
+
+	. "github.com/bloomberg/k8eraid/pkgs/types"
+
+	"github.com/stretchr/testify/assert"
+	batchv1 "k8s.io/api/batch/v1"
+	batchv1beta1 "k8s.io/api/batch/v1beta1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func cronJobRun(cronJob *batchv1beta1.CronJob, name string, created time.Time, conditionType batchv1.JobConditionType) batchv1.Job {
+	job := batchv1.Job{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:              name,
+			Namespace:         cronJob.Namespace,
+			CreationTimestamp: metav1.Time{Time: created},
+			OwnerReferences:   []metav1.OwnerReference{{Kind: "CronJob", Name: cronJob.Name, UID: cronJob.UID}},
+		},
+	}
+	if conditionType != "" {
+		job.Status.Conditions = []batchv1.JobCondition{{Type: conditionType, Status: corev1.ConditionTrue}}
+	}
+	if conditionType == batchv1.JobComplete {
+		job.Status.CompletionTime = &metav1.Time{Time: created.Add(time.Minute)}
+	}
+	return job
+}
+
+func Test_CheckCronJob(t *testing.T) {
+	now := time.Unix(1000000, 0)
+	suspend := true
+	cronJob := &batchv1beta1.CronJob{
+		ObjectMeta: metav1.ObjectMeta{Name: "load", Namespace: metav1.NamespaceDefault, UID: types.UID("load"), CreationTimestamp: metav1.Time{Time: now.Add(-72 * time.Hour)}},
+		Status:     batchv1beta1.CronJobStatus{LastScheduleTime: &metav1.Time{Time: now.Add(-time.Hour)}},
+	}
+	other := &batchv1beta1.CronJob{ObjectMeta: metav1.ObjectMeta{Name: "other", Namespace: metav1.NamespaceDefault, UID: types.UID("other")}}
+	jobs := []batchv1.Job{
+		cronJobRun(cronJob, "load-1", now.Add(-26*time.Hour), batchv1.JobComplete),
+		cronJobRun(cronJob, "load-3", now.Add(-2*time.Hour), batchv1.JobFailed),
+		cronJobRun(cronJob, "load-2", now.Add(-14*time.Hour), batchv1.JobFailed),
+		cronJobRun(cronJob, "load-4", now.Add(-time.Hour), ""),
+		cronJobRun(other, "other-1", now.Add(-time.Hour), batchv1.JobComplete),
+	}
+	alertSpec := CronJobAlertSpec{ReportStatus: CronJobAlertStatus{Suspended: true, MaxSinceSuccess: 86400, ConsecutiveFailures: 2}}
+
+	result := CheckCronJob(cronJob, jobs, alertSpec, now)
+	assert.Equal(t, []string{"cronjob/default/load/NoRecentSuccess", "cronjob/default/load/ConsecutiveFailures"}, result.Keys())
+	assert.Equal(t, []string{
+		"CronJob default/load last succeeded 25h59m0s ago, longer than 86400s! Its last run was scheduled 1h0m0s ago.",
+		"CronJob default/load has failed its last 2 runs, at least 2! Its latest failed Job is load-3.",
+	}, result.Messages(), "runs still active should not break a streak of failures")
+
+	cronJob.Spec.Suspend = &suspend
+	assert.Equal(t, []string{
+		"cronjob/default/load/Suspended",
+		"cronjob/default/load/NoRecentSuccess",
+	}, CheckCronJob(cronJob, jobs[:1], alertSpec, now).Keys())
+
+	assert.Equal(t, []string{
+		"CronJob default/load has no successful run since it was created 3 days ago, longer than 86400s! Its last run was scheduled 1h0m0s ago.",
+	}, CheckCronJob(cronJob, nil, CronJobAlertSpec{ReportStatus: CronJobAlertStatus{MaxSinceSuccess: 86400}}, now).Messages())
+}
+
+func Test_PollCronJob(t *testing.T) {
+	_, conf := StubsInit()
+	cronJob := &batchv1beta1.CronJob{
+		ObjectMeta: metav1.ObjectMeta{Name: "load", Namespace: metav1.NamespaceDefault, UID: types.UID("load"), Labels: map[string]string{"schedule": "nightly"}},
+	}
+	failed := cronJobRun(cronJob, "load-1", time.Now().Add(-time.Hour), batchv1.JobFailed)
+	client := fake.NewSimpleClientset(cronJob, &failed)
+	alertSpec := CronJobAlertSpec{ReportStatus: CronJobAlertStatus{ConsecutiveFailures: 1}}
+
+	for _, spec := range []CronJobAlertSpec{
+		{Name: "load", CronJobFilter: metav1.NamespaceDefault, ReportStatus: alertSpec.ReportStatus},
+		{Name: "*", CronJobFilter: "schedule=nightly", ReportStatus: alertSpec.ReportStatus},
+	} {
+		keys := []string{}
+		err := PollCronJob(client, spec, defaultTickerTime, func(_ string, _ string, alert Alert, _ AlertersConfig) {
+			keys = append(keys, alert.Key)
+		}, conf)
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"cronjob/default/load/ConsecutiveFailures"}, keys, spec.Name)
+	}
+
+	assert.Error(t, PollCronJob(client, CronJobAlertSpec{Name: "load"}, defaultTickerTime, nil, conf), "named rules need a namespace")
+	assert.Error(t, PollCronJob(client, CronJobAlertSpec{Name: "absent", CronJobFilter: metav1.NamespaceDefault}, defaultTickerTime, nil, conf))
+}
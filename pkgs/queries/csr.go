@@ -0,0 +1,173 @@
+// Copyright 2019 Bloomberg Finance LP
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package queries
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/bloomberg/k8eraid/pkgs/types"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/dynamic"
+)
+
+const defaultCSRVersion = "v1beta1"
+
+// maxCSRRequestors is how many requestors a backlog alert names
+const maxCSRRequestors = 5
+
+// pendingCSR is a CertificateSigningRequest that is neither approved nor denied
+type pendingCSR struct {
+	name      string
+	requestor string
+	signer    string
+	created   time.Time
+}
+
+// PollCSR function takes inputs and looks for a backlog of pending CertificateSigningRequests in the kubernetes cluster,
+// triggering alerts as needed. CSRs are read through the dynamic client, since the signer of a CSR is newer than the
+// typed client.
+func PollCSR(
+	client dynamic.Interface,
+	alertSpec types.CSRAlertSpec,
+	tickertime int64,
+	alertFn alertFunction,
+	alertersConfig types.AlertersConfig,
+) error {
+	version := alertSpec.Version
+	if version == "" {
+		version = defaultCSRVersion
+	}
+	gvr := schema.GroupVersionResource{Group: "certificates.k8s.io", Version: version, Resource: "certificatesigningrequests"}
+	list, listerr := client.Resource(gvr).List(metav1.ListOptions{TimeoutSeconds: &timeout})
+	if listerr != nil {
+		return &PollErr{
+			Message: fmt.Sprintf("Unable to list CertificateSigningRequests: %s", listerr.Error()),
+		}
+	}
+	var pending []pendingCSR
+	for _, object := range list.Items {
+		csr, ok := pendingCSRFrom(object)
+		if ok && (alertSpec.SignerName == "" || csr.signer == alertSpec.SignerName) {
+			pending = append(pending, csr)
+		}
+	}
+	checkCSRs(pending, alertSpec, time.Now(), alertFn, alertersConfig)
+	return nil
+}
+
+// pendingCSRFrom reads object as a pendingCSR, reporting false when it was approved, denied or failed.
+// Conditions without a status, which clusters older than 1.19 write, count as true.
+func pendingCSRFrom(object unstructured.Unstructured) (pendingCSR, bool) {
+	conditions, _, _ := unstructured.NestedSlice(object.Object, "status", "conditions")
+	for _, condition := range conditions {
+		fields, ok := condition.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		switch fields["type"] {
+		case "Approved", "Denied", "Failed":
+			if fields["status"] != "False" {
+				return pendingCSR{}, false
+			}
+		}
+	}
+	requestor, _, _ := unstructured.NestedString(object.Object, "spec", "username")
+	signer, _, _ := unstructured.NestedString(object.Object, "spec", "signerName")
+	return pendingCSR{
+		name:      object.GetName(),
+		requestor: requestor,
+		signer:    signer,
+		created:   object.GetCreationTimestamp().Time,
+	}, true
+}
+
+// checkCSRs alerts when more CSRs are pending than the rule allows, naming who requested them, and on
+// every CSR pending for longer than the threshold
+func checkCSRs(
+	pending []pendingCSR,
+	alertSpec types.CSRAlertSpec,
+	now time.Time,
+	alertFn alertFunction,
+	alertersConfig types.AlertersConfig,
+) {
+	sort.Slice(pending, func(i, j int) bool { return pending[i].name < pending[j].name })
+
+	if max := alertSpec.ReportStatus.MaxPending; max > 0 && len(pending) > max {
+		// ALERT
+		alertmessage := fmt.Sprintf(
+			"%d CertificateSigningRequests%s are pending, above the maximum of %d! Requested by %s. Nodes may be unable to get their certificates.",
+			len(pending), forSigner(alertSpec.SignerName), max, csrRequestors(pending),
+		)
+		alert := newAlert(resourceID("certificatesigningrequests", "", alertSpec.SignerName), "PendingBacklog", types.SeverityCritical, alertmessage)
+		alertFn(alertSpec.AlerterType, alertSpec.AlerterName, alert, alertersConfig)
+	}
+
+	if threshold := alertSpec.ReportStatus.PendingThreshold; threshold > 0 {
+		for _, csr := range pending {
+			pendingFor := now.Sub(csr.created)
+			if pendingFor <= time.Duration(threshold)*time.Second {
+				continue
+			}
+			// ALERT
+			alertmessage := fmt.Sprintf(
+				"CertificateSigningRequest %s requested by %s%s has been pending for %s, longer than %ds!",
+				csr.name, csr.requestor, forSigner(csr.signer), formatLongDuration(pendingFor), threshold,
+			)
+			alert := newAlert(resourceID("certificatesigningrequest", "", csr.name), "Pending", types.SeverityWarning, alertmessage)
+			alertFn(alertSpec.AlerterType, alertSpec.AlerterName, alert, alertersConfig)
+		}
+	}
+}
+
+// csrRequestors counts the pending CSRs of the requestors with the most, e.g. system:node:worker-1 (3)
+func csrRequestors(pending []pendingCSR) string {
+	counts := map[string]int{}
+	for _, csr := range pending {
+		counts[csr.requestor]++
+	}
+	requestors := make([]string, 0, len(counts))
+	for requestor := range counts {
+		requestors = append(requestors, requestor)
+	}
+	sort.Slice(requestors, func(i, j int) bool {
+		if counts[requestors[i]] != counts[requestors[j]] {
+			return counts[requestors[i]] > counts[requestors[j]]
+		}
+		return requestors[i] < requestors[j]
+	})
+	described := []string{}
+	for i, requestor := range requestors {
+		if i == maxCSRRequestors {
+			described = append(described, fmt.Sprintf("%d others", len(requestors)-i))
+			break
+		}
+		described = append(described, fmt.Sprintf("%s (%d)", requestor, counts[requestor]))
+	}
+	return strings.Join(described, ", ")
+}
+
+// forSigner names signer in alert messages, if there is one
+func forSigner(signer string) string {
+	if signer == "" {
+		return ""
+	}
+	return " for signer " + signer
+}
@@ -0,0 +1,112 @@
+// Copyright 2019 Bloomberg Finance LP
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package queries
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	. "github.com/bloomberg/k8eraid/pkgs/types"
+
+	"github.com/stretchr/testify/assert"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+)
+
+func testCSR(name string, requestor string, signer string, created time.Time, conditions ...map[string]interface{}) *unstructured.Unstructured {
+	spec := map[string]interface{}{"username": requestor}
+	if signer != "" {
+		spec["signerName"] = signer
+	}
+	csr := &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "certificates.k8s.io/v1beta1",
+		"kind":       "CertificateSigningRequest",
+		"metadata":   map[string]interface{}{"name": name},
+		"spec":       spec,
+	}}
+	if len(conditions) > 0 {
+		var list []interface{}
+		for _, condition := range conditions {
+			list = append(list, condition)
+		}
+		csr.Object["status"] = map[string]interface{}{"conditions": list}
+	}
+	csr.SetCreationTimestamp(metav1.Time{Time: created})
+	return csr
+}
+
+func Test_PollCSR(t *testing.T) {
+	now := time.Now()
+	const serving = "kubernetes.io/kubelet-serving"
+	client := dynamicfake.NewSimpleDynamicClient(runtime.NewScheme(),
+		testCSR("csr-old", "system:node:a", serving, now.Add(-2*time.Hour)),
+		testCSR("csr-new", "system:node:a", serving, now.Add(-time.Minute)),
+		testCSR("csr-b", "system:node:b", serving, now.Add(-time.Minute)),
+		testCSR("csr-client", "system:bootstrap:abcdef", "kubernetes.io/kube-apiserver-client-kubelet", now.Add(-2*time.Hour)),
+		testCSR("csr-approved", "system:node:c", serving, now.Add(-2*time.Hour), map[string]interface{}{"type": "Approved"}),
+		testCSR("csr-denied", "system:node:c", serving, now.Add(-2*time.Hour), map[string]interface{}{"type": "Denied", "status": "True"}),
+		testCSR("csr-undecided", "system:node:c", serving, now.Add(-time.Minute), map[string]interface{}{"type": "Approved", "status": "False"}),
+	)
+	tests := []struct {
+		name     string
+		spec     CSRAlertSpec
+		expected map[string]string
+	}{
+		{
+			name: "backlog of one signer",
+			spec: CSRAlertSpec{SignerName: serving, ReportStatus: CSRAlertStatus{MaxPending: 3}},
+			expected: map[string]string{
+				"certificatesigningrequests/kubernetes.io/kubelet-serving/PendingBacklog": "4 CertificateSigningRequests for signer kubernetes.io/kubelet-serving are pending, above the maximum of 3! " +
+					"Requested by system:node:a (2), system:node:b (1), system:node:c (1). Nodes may be unable to get their certificates.",
+			},
+		},
+		{
+			name:     "backlog within the maximum",
+			spec:     CSRAlertSpec{ReportStatus: CSRAlertStatus{MaxPending: 5}},
+			expected: map[string]string{},
+		},
+		{
+			name: "pending too long",
+			spec: CSRAlertSpec{ReportStatus: CSRAlertStatus{PendingThreshold: 3600}},
+			expected: map[string]string{
+				"certificatesigningrequest/csr-old/Pending":    "CertificateSigningRequest csr-old requested by system:node:a for signer kubernetes.io/kubelet-serving has been pending for 2h0m0s, longer than 3600s!",
+				"certificatesigningrequest/csr-client/Pending": "CertificateSigningRequest csr-client requested by system:bootstrap:abcdef for signer kubernetes.io/kube-apiserver-client-kubelet has been pending for 2h0m0s, longer than 3600s!",
+			},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(subT *testing.T) {
+			alerts := map[string]string{}
+			alertFn := func(_ string, _ string, alert Alert, _ AlertersConfig) {
+				alerts[alert.Key] = alert.Message
+			}
+			assert.NoError(subT, PollCSR(client, test.spec, defaultTickerTime, alertFn, AlertersConfig{}))
+			assert.Equal(subT, test.expected, alerts)
+		})
+	}
+}
+
+func Test_csrRequestors(t *testing.T) {
+	var pending []pendingCSR
+	for i := 0; i < 7; i++ {
+		for j := 0; j <= i; j++ {
+			pending = append(pending, pendingCSR{requestor: fmt.Sprintf("system:node:%d", i)})
+		}
+	}
+	assert.Equal(t, "system:node:6 (7), system:node:5 (6), system:node:4 (5), system:node:3 (4), system:node:2 (3), 2 others", csrRequestors(pending))
+}
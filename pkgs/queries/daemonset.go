@@ -19,9 +19,11 @@ import (
 	"strings"
 	"time"
 
+	"github.com/bloomberg/k8eraid/pkgs/state"
 	"github.com/bloomberg/k8eraid/pkgs/types"
 
 	appsv1 "k8s.io/api/apps/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/client-go/kubernetes"
 )
@@ -36,7 +38,11 @@ func PollDaemonset(
 ) error {
 
 	if alertSpec.ReportStatus.PendingThreshold == 0 {
-		alertSpec.ReportStatus.PendingThreshold = 10
+		alertSpec.ReportStatus.PendingThreshold = defaultPendingThreshold
+	}
+
+	if alertSpec.ReportStatus.ReportDiff {
+		return diffDaemonSets(clientset, alertSpec, alertFn, alertersConfig)
 	}
 
 	// If the daemon is not wildcard, search by name
@@ -53,7 +59,7 @@ func PollDaemonset(
 			}
 		}
 
-		checkDaemonset(daemonset, alertSpec, alertFn, alertersConfig)
+		checkDaemonset(daemonset, alertSpec, time.Now(), alertFn, alertersConfig)
 		// If the daemon is a wildcard, list daemons and iterate through
 	} else {
 		if strings.Contains(alertSpec.DaemonFilter, "=") || alertSpec.DaemonFilter == "" {
@@ -69,15 +75,20 @@ func PollDaemonset(
 					Message: fmt.Sprintf("Unable to list DaemonSets: %s", daemonsetserr.Error()),
 				}
 			}
+			objectErrs := newObjectErrors("daemonset", alertSpec.OnObjectError)
 			for _, daemonsetname := range daemonsets.Items {
 				daemonset, daemonseterr := clientset.AppsV1().DaemonSets(daemonsetname.GetNamespace()).Get(daemonsetname.GetName(), metav1.GetOptions{})
 				if daemonseterr != nil {
-					return &PollErr{
-						Message: fmt.Sprintf("Unable to get DaemonSet %s: %s", daemonsetname.Name, daemonsetserr.Error()),
+					if err := objectErrs.handle(&PollErr{
+						Message: fmt.Sprintf("Unable to get DaemonSet %s: %s", daemonsetname.Name, daemonseterr.Error()),
+					}); err != nil {
+						return err
 					}
+					continue
 				}
-				checkDaemonset(daemonset, alertSpec, alertFn, alertersConfig)
+				checkDaemonset(daemonset, alertSpec, time.Now(), alertFn, alertersConfig)
 			}
+			return objectErrs.err()
 		} else {
 			return &PollErr{
 				Message: fmt.Sprintf("Deployment rule for global has incorrect filter specified (filter was: %s), ignoring", alertSpec.DaemonFilter),
@@ -87,15 +98,62 @@ func PollDaemonset(
 	return nil
 }
 
+// diffDaemonSets reports the daemonsets that were added, removed or changed replica counts since the previous tick
+func diffDaemonSets(
+	clientset kubernetes.Interface,
+	alertSpec types.DaemonsetAlertSpec,
+	alertFn alertFunction,
+	alertersConfig types.AlertersConfig,
+) error {
+	snapshot := state.Snapshot{}
+	if alertSpec.Name != "*" {
+		if alertSpec.DaemonFilter == "" {
+			return &PollErr{
+				Message: fmt.Sprintf("DaemonSet rule for %s has no namespace filter specified, ignoring", alertSpec.Name),
+			}
+		}
+		daemonset, err := clientset.AppsV1().DaemonSets(alertSpec.DaemonFilter).Get(alertSpec.Name, metav1.GetOptions{})
+		if err == nil {
+			snapshot[resourceID("daemonset", daemonset.Namespace, daemonset.Name)] = daemonsetSummary(daemonset)
+		} else if !apierrors.IsNotFound(err) {
+			return &PollErr{
+				Message: fmt.Sprintf("Error fetching daemonset %s: %s", alertSpec.Name, err.Error()),
+			}
+		}
+	} else {
+		if !strings.Contains(alertSpec.DaemonFilter, "=") && alertSpec.DaemonFilter != "" {
+			return &PollErr{
+				Message: fmt.Sprintf("DaemonSet rule for global has incorrect filter specified (filter was: %s), ignoring", alertSpec.DaemonFilter),
+			}
+		}
+		list, err := clientset.AppsV1().DaemonSets("").List(metav1.ListOptions{
+			LabelSelector:  alertSpec.DaemonFilter,
+			TimeoutSeconds: &timeout,
+		})
+		if err != nil {
+			return &PollErr{
+				Message: fmt.Sprintf("Unable to list DaemonSets: %s", err.Error()),
+			}
+		}
+		for i := range list.Items {
+			snapshot[resourceID("daemonset", list.Items[i].Namespace, list.Items[i].Name)] = daemonsetSummary(&list.Items[i])
+		}
+	}
+	reportDiff(specKey("daemonset", alertSpec.Name, alertSpec.DaemonFilter), snapshot, alertSpec.AlerterType, alertSpec.AlerterName, alertFn, alertersConfig)
+	return nil
+}
+
 func checkDaemonset(
 	daemonSet *appsv1.DaemonSet,
 	alertSpec types.DaemonsetAlertSpec,
+	now time.Time,
 	alertFn alertFunction,
 	alertersConfig types.AlertersConfig,
 ) {
-	nowSeconds := time.Now().Unix()
+	nowSeconds := now.Unix()
 	// Get times for comparing to threshold
 	statusCreatedSecondsDiff := nowSeconds - daemonSet.ObjectMeta.CreationTimestamp.Unix()
+	resource := resourceID("daemonset", daemonSet.Namespace, daemonSet.Name)
 
 	// If daemonset hasnt been around longer than threshold, bail. otherwise check the status.
 	if statusCreatedSecondsDiff > alertSpec.ReportStatus.PendingThreshold {
@@ -110,7 +168,8 @@ func checkDaemonset(
 					alertSpec.DaemonFilter,
 					"does not have the specified required minimum replicas available!",
 				)
-				alertFn(alertSpec.AlerterType, alertSpec.AlerterName, alertmessage, alertersConfig)
+				alert := newAlert(resource, "CheckReplicas", types.SeverityCritical, alertmessage)
+				alertFn(alertSpec.AlerterType, alertSpec.AlerterName, alert, alertersConfig)
 			}
 		}
 		if alertSpec.ReportStatus.FailedScheduling {
@@ -123,8 +182,51 @@ func checkDaemonset(
 					alertSpec.DaemonFilter,
 					"does not have the desired number of replicas scheduled!",
 				)
-				alertFn(alertSpec.AlerterType, alertSpec.AlerterName, alertmessage, alertersConfig)
+				alert := newAlert(resource, "FailedScheduling", types.SeverityCritical, alertmessage)
+				alertFn(alertSpec.AlerterType, alertSpec.AlerterName, alert, alertersConfig)
 			}
 		}
 	}
+	checkDaemonsetCoverage(daemonSet, alertSpec, now, alertFn, alertersConfig)
+}
+
+// checkDaemonsetCoverage alerts on a daemonset that has left nodes without a ready pod, or kept pods
+// on nodes it no longer selects, for longer than the threshold. A node agent such as a CNI plugin or
+// a log shipper missing from some nodes is easily hidden by the nodes it does run on.
+func checkDaemonsetCoverage(
+	daemonSet *appsv1.DaemonSet,
+	alertSpec types.DaemonsetAlertSpec,
+	now time.Time,
+	alertFn alertFunction,
+	alertersConfig types.AlertersConfig,
+) {
+	threshold := alertSpec.ReportStatus.CoverageThreshold
+	if threshold <= 0 {
+		return
+	}
+	resource := resourceID("daemonset", daemonSet.Namespace, daemonSet.Name)
+	created := daemonSet.CreationTimestamp.Time
+	desired, ready := daemonSet.Status.DesiredNumberScheduled, daemonSet.Status.NumberReady
+
+	uncovered := ready < desired
+	if uncoveredFor := unhealthyFor("coverage/"+resource, uncovered, created, now); uncovered && uncoveredFor > time.Duration(threshold)*time.Second {
+		// ALERT
+		alertmessage := fmt.Sprintf(
+			"DaemonSet %s/%s has had only %d of %d pods ready for %s, longer than %ds! %d nodes have no ready pod of it.",
+			daemonSet.Namespace, daemonSet.Name, ready, desired, formatLongDuration(uncoveredFor), threshold, desired-ready,
+		)
+		alert := newAlert(resource, "Coverage", types.SeverityCritical, alertmessage)
+		alertFn(alertSpec.AlerterType, alertSpec.AlerterName, alert, alertersConfig)
+	}
+
+	misscheduled := daemonSet.Status.NumberMisscheduled
+	if misscheduledFor := unhealthyFor("misscheduled/"+resource, misscheduled > 0, created, now); misscheduled > 0 && misscheduledFor > time.Duration(threshold)*time.Second {
+		// ALERT
+		alertmessage := fmt.Sprintf(
+			"DaemonSet %s/%s has been running on %d nodes it should not run on for %s, longer than %ds!",
+			daemonSet.Namespace, daemonSet.Name, misscheduled, formatLongDuration(misscheduledFor), threshold,
+		)
+		alert := newAlert(resource, "Misscheduled", types.SeverityWarning, alertmessage)
+		alertFn(alertSpec.AlerterType, alertSpec.AlerterName, alert, alertersConfig)
+	}
 }
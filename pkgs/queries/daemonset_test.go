@@ -18,8 +18,10 @@ import (
 	"testing"
 	"time"
 
+	"github.com/bloomberg/k8eraid/pkgs/state"
 	. "github.com/bloomberg/k8eraid/pkgs/types"
 
+	"github.com/stretchr/testify/assert"
 	appsv1 "k8s.io/api/apps/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/client-go/kubernetes/fake"
@@ -120,7 +122,7 @@ func Test_PollDaemonset_ok(t *testing.T) {
 		t.Run(test.name, func(subT *testing.T) {
 			client := fake.NewSimpleClientset(test.daemonSet)
 			stubCalled := false
-			alertStub := func(_ string, _ string, _ string, _ AlertersConfig) {
+			alertStub := func(_ string, _ string, _ Alert, _ AlertersConfig) {
 				stubCalled = true
 			}
 			err := PollDaemonset(client, test.alertSpec, defaultTickerTime, alertStub, conf)
@@ -135,3 +137,34 @@ func Test_PollDaemonset_ok(t *testing.T) {
 		})
 	}
 }
+
+func Test_checkDaemonsetCoverage(t *testing.T) {
+	stateStore = state.NewStore()
+	_, conf := StubsInit()
+	now := time.Now()
+	alertSpec := DaemonsetAlertSpec{Name: "*", ReportStatus: DaemonsetAlertStatus{CoverageThreshold: 300}}
+	var messages []string
+	alertStub := func(_ string, _ string, alert Alert, _ AlertersConfig) {
+		messages = append(messages, alert.Key+": "+alert.Message)
+	}
+	daemonSet := &appsv1.DaemonSet{
+		ObjectMeta: metav1.ObjectMeta{Name: "fluentd", Namespace: "logging", CreationTimestamp: metav1.Time{Time: now.Add(-24 * time.Hour)}},
+		Status:     appsv1.DaemonSetStatus{DesiredNumberScheduled: 10, NumberReady: 7, NumberMisscheduled: 1},
+	}
+	checkDaemonsetCoverage(daemonSet, alertSpec, now, alertStub, conf)
+	assert.Empty(t, messages, "gaps first seen have just started")
+
+	checkDaemonsetCoverage(daemonSet, alertSpec, now.Add(10*time.Minute), alertStub, conf)
+	assert.Equal(t, []string{
+		"daemonset/logging/fluentd/Coverage: DaemonSet logging/fluentd has had only 7 of 10 pods ready for 10m0s, longer than 300s! 3 nodes have no ready pod of it.",
+		"daemonset/logging/fluentd/Misscheduled: DaemonSet logging/fluentd has been running on 1 nodes it should not run on for 10m0s, longer than 300s!",
+	}, messages)
+
+	messages = nil
+	daemonSet.Status.NumberReady = 10
+	daemonSet.Status.NumberMisscheduled = 0
+	checkDaemonsetCoverage(daemonSet, alertSpec, now.Add(20*time.Minute), alertStub, conf)
+	daemonSet.Status.NumberReady = 9
+	checkDaemonsetCoverage(daemonSet, alertSpec, now.Add(21*time.Minute), alertStub, conf)
+	assert.Empty(t, messages, "full coverage should restart the gap")
+}
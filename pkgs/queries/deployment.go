@@ -19,13 +19,23 @@ import (
 	"strings"
 	"time"
 
+	"github.com/bloomberg/k8eraid/pkgs/state"
 	"github.com/bloomberg/k8eraid/pkgs/types"
 
 	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/client-go/kubernetes"
 )
 
+// deploymentPausedReason is the reason of the Progressing condition of a paused deployment
+const deploymentPausedReason = "DeploymentPaused"
+
+// deploymentDeadlineReason is the reason of the Progressing condition of a deployment whose rollout
+// made no progress within its progress deadline
+const deploymentDeadlineReason = "ProgressDeadlineExceeded"
+
 // PollDeployment function takes inputs and iterates across deployments in the kubernetes cluster, triggering alerts as needed.
 func PollDeployment(
 	clientset kubernetes.Interface,
@@ -36,7 +46,11 @@ func PollDeployment(
 ) error {
 
 	if alertSpec.ReportStatus.PendingThreshold == 0 {
-		alertSpec.ReportStatus.PendingThreshold = 10
+		alertSpec.ReportStatus.PendingThreshold = defaultPendingThreshold
+	}
+
+	if alertSpec.ReportStatus.ReportDiff {
+		return diffDeployments(clientset, alertSpec, alertFn, alertersConfig)
 	}
 
 	// If the deployment is not wildcard, search by name
@@ -54,7 +68,9 @@ func PollDeployment(
 				Message: fmt.Sprintf("Error fetching deployment: %s", deploymenterr.Error()),
 			}
 		}
-		checkDeployment(deployment, alertSpec, alertFn, alertersConfig)
+		if err := checkDeploymentObject(clientset, deployment, deploymentLookups{}, alertSpec, time.Now(), alertFn, alertersConfig); err != nil {
+			return err
+		}
 
 		// If the deployment is a wildcard, list deployments and iterate through
 	} else {
@@ -71,15 +87,24 @@ func PollDeployment(
 					Message: fmt.Sprintf("Unable to get deployments: %s", deploymentserr.Error()),
 				}
 			}
+			objectErrs := newObjectErrors("deployment", alertSpec.OnObjectError)
 			for _, deploymentname := range deployments.Items {
 				deployment, deploymenterr := clientset.AppsV1().Deployments(deploymentname.GetNamespace()).Get(deploymentname.GetName(), metav1.GetOptions{})
 				if deploymenterr != nil {
-					return &PollErr{
+					if err := objectErrs.handle(&PollErr{
 						Message: fmt.Sprintf("Error fetching deployment %s: %s", deploymentname.GetName(), deploymenterr.Error()),
+					}); err != nil {
+						return err
+					}
+					continue
+				}
+				if err := checkDeploymentObject(clientset, deployment, deploymentLookups{}, alertSpec, time.Now(), alertFn, alertersConfig); err != nil {
+					if err := objectErrs.handle(err); err != nil {
+						return err
 					}
 				}
-				checkDeployment(deployment, alertSpec, alertFn, alertersConfig)
 			}
+			return objectErrs.err()
 		} else {
 
 			return &PollErr{
@@ -90,15 +115,135 @@ func PollDeployment(
 	return nil
 }
 
+// deploymentLookups are the pods and ReplicaSets selected by one deployment
+type deploymentLookups struct {
+	pods        []corev1.Pod
+	replicaSets []appsv1.ReplicaSet
+}
+
+// listDeploymentLookups lists the pods and ReplicaSets the checks of alertSpec need for deployment
+func listDeploymentLookups(
+	clientset kubernetes.Interface,
+	deployment *appsv1.Deployment,
+	alertSpec types.DeploymentAlertSpec,
+) (deploymentLookups, error) {
+	var lookups deploymentLookups
+	if deployment.Spec.Selector == nil {
+		return lookups, nil
+	}
+	listopts := metav1.ListOptions{
+		LabelSelector:  metav1.FormatLabelSelector(deployment.Spec.Selector),
+		TimeoutSeconds: &timeout,
+	}
+	if alertSpec.ReportStatus.OOMKills.Threshold > 0 {
+		pods, err := clientset.CoreV1().Pods(deployment.Namespace).List(listopts)
+		if err != nil {
+			return lookups, &PollErr{
+				Message: fmt.Sprintf("Unable to list pods of deployment %s: %s", deployment.Name, err.Error()),
+			}
+		}
+		lookups.pods = pods.Items
+	}
+	if check := alertSpec.ReportStatus.ReplicaSets; check.MaxActive > 0 || check.MaxRevisions > 0 {
+		replicaSets, err := clientset.AppsV1().ReplicaSets(deployment.Namespace).List(listopts)
+		if err != nil {
+			return lookups, &PollErr{
+				Message: fmt.Sprintf("Unable to list ReplicaSets of deployment %s: %s", deployment.Name, err.Error()),
+			}
+		}
+		lookups.replicaSets = replicaSets.Items
+	}
+	return lookups, nil
+}
+
+// checkDeploymentObject runs every check a deployment rule makes on one deployment, as of now. With a
+// clientset its pods and ReplicaSets are listed, replacing lookups, and its pod spread is checked.
+// Without one, lookups stand in for the listed pods and ReplicaSets and the spread check is skipped,
+// so the deployment can be checked without a cluster.
+func checkDeploymentObject(
+	clientset kubernetes.Interface,
+	deployment *appsv1.Deployment,
+	lookups deploymentLookups,
+	alertSpec types.DeploymentAlertSpec,
+	now time.Time,
+	alertFn alertFunction,
+	alertersConfig types.AlertersConfig,
+) error {
+	checkDeployment(deployment, alertSpec, now, alertFn, alertersConfig)
+	checkDeploymentPaused(deployment, alertSpec, now, alertFn, alertersConfig)
+	checkDeploymentRollout(deployment, alertSpec, now, alertFn, alertersConfig)
+	checkDeploymentImagePolicy(deployment, alertSpec, alertFn, alertersConfig)
+	if clientset != nil {
+		listed, err := listDeploymentLookups(clientset, deployment, alertSpec)
+		if err != nil {
+			return err
+		}
+		lookups = listed
+	}
+	checkDeploymentOOMKills(deployment, lookups.pods, alertSpec, now, alertFn, alertersConfig)
+	checkDeploymentReplicaSets(deployment, lookups.replicaSets, alertSpec, alertFn, alertersConfig)
+	if clientset == nil {
+		return nil
+	}
+	return checkDeploymentSpread(clientset, deployment, alertSpec, alertFn, alertersConfig)
+}
+
+// diffDeployments reports the deployments that were added, removed or changed replica counts since the previous tick
+func diffDeployments(
+	clientset kubernetes.Interface,
+	alertSpec types.DeploymentAlertSpec,
+	alertFn alertFunction,
+	alertersConfig types.AlertersConfig,
+) error {
+	snapshot := state.Snapshot{}
+	if alertSpec.Name != "*" {
+		if alertSpec.DepFilter == "" {
+			return &PollErr{
+				Message: fmt.Sprintf("Deployment rule for %s has no namespace filter specified, ignoring", alertSpec.Name),
+			}
+		}
+		deployment, err := clientset.AppsV1().Deployments(alertSpec.DepFilter).Get(alertSpec.Name, metav1.GetOptions{})
+		if err == nil {
+			snapshot[resourceID("deployment", deployment.Namespace, deployment.Name)] = deploymentSummary(deployment)
+		} else if !apierrors.IsNotFound(err) {
+			return &PollErr{
+				Message: fmt.Sprintf("Error fetching deployment %s: %s", alertSpec.Name, err.Error()),
+			}
+		}
+	} else {
+		if !strings.Contains(alertSpec.DepFilter, "=") && alertSpec.DepFilter != "" {
+			return &PollErr{
+				Message: fmt.Sprintf("Deployment rule for global has incorrect filter specified (filter was: %s), ignoring", alertSpec.DepFilter),
+			}
+		}
+		list, err := clientset.AppsV1().Deployments("").List(metav1.ListOptions{
+			LabelSelector:  alertSpec.DepFilter,
+			TimeoutSeconds: &timeout,
+		})
+		if err != nil {
+			return &PollErr{
+				Message: fmt.Sprintf("Unable to list Deployments: %s", err.Error()),
+			}
+		}
+		for i := range list.Items {
+			snapshot[resourceID("deployment", list.Items[i].Namespace, list.Items[i].Name)] = deploymentSummary(&list.Items[i])
+		}
+	}
+	reportDiff(specKey("deployment", alertSpec.Name, alertSpec.DepFilter), snapshot, alertSpec.AlerterType, alertSpec.AlerterName, alertFn, alertersConfig)
+	return nil
+}
+
 func checkDeployment(
 	deployment *appsv1.Deployment,
 	alertSpec types.DeploymentAlertSpec,
+	now time.Time,
 	alertFn alertFunction,
 	alertersConfig types.AlertersConfig,
 ) {
 
 	// Get times for comparing to threshold
-	statusCreatedSecondsDiff := time.Now().Unix() - deployment.ObjectMeta.CreationTimestamp.Unix()
+	statusCreatedSecondsDiff := now.Unix() - deployment.ObjectMeta.CreationTimestamp.Unix()
+	resource := resourceID("deployment", deployment.Namespace, deployment.Name)
 
 	// If deployment hasnt been around longer than threshold, bail. otherwise check the status.
 	if statusCreatedSecondsDiff > alertSpec.ReportStatus.PendingThreshold {
@@ -106,7 +251,224 @@ func checkDeployment(
 			// ALERT
 			s := []string{"Deployment", alertSpec.Name, "does not have the specified required minimum replicas"}
 			alertmessage := strings.Join(s, " ")
-			alertFn(alertSpec.AlerterType, alertSpec.AlerterName, alertmessage, alertersConfig)
+			alert := newAlert(resource, "MinReplicas", types.SeverityCritical, alertmessage)
+			alertFn(alertSpec.AlerterType, alertSpec.AlerterName, alert, alertersConfig)
+		}
+		checkTrend(
+			resource,
+			"AvailableTrend",
+			fmt.Sprint("Deployment ", deployment.Namespace, "/", deployment.Name, " available replicas"),
+			float64(deployment.Status.AvailableReplicas),
+			now,
+			alertSpec.ReportStatus.AvailableTrend,
+			alertSpec.AlerterType,
+			alertSpec.AlerterName,
+			alertFn,
+			alertersConfig,
+		)
+	}
+}
+
+// checkDeploymentPaused alerts on a deployment whose rollout has been paused for longer than the
+// threshold, most likely by a forgotten kubectl rollout pause, since it takes no updates until resumed
+func checkDeploymentPaused(
+	deployment *appsv1.Deployment,
+	alertSpec types.DeploymentAlertSpec,
+	now time.Time,
+	alertFn alertFunction,
+	alertersConfig types.AlertersConfig,
+) {
+	threshold := alertSpec.ReportStatus.PausedThreshold
+	if threshold <= 0 {
+		return
+	}
+	resource := resourceID("deployment", deployment.Namespace, deployment.Name)
+	key := "paused/" + resource
+	if !deployment.Spec.Paused {
+		stateStore.ForgetSnapshot(key)
+		return
+	}
+	pausedFor := now.Sub(deploymentPausedSince(key, deployment, now))
+	if pausedFor <= time.Duration(threshold)*time.Second {
+		return
+	}
+	// ALERT
+	alertmessage := fmt.Sprintf(
+		"Deployment %s/%s has been paused for %s, longer than %ds! It receives no updates until its rollout is resumed.",
+		deployment.Namespace, deployment.Name, formatLongDuration(pausedFor), threshold,
+	)
+	alert := newAlert(resource, "Paused", types.SeverityWarning, alertmessage)
+	alertFn(alertSpec.AlerterType, alertSpec.AlerterName, alert, alertersConfig)
+}
+
+// deploymentPausedSince returns when the deployment was paused, as its Progressing condition records
+// it. Deployments without a progress deadline have no such condition, so when k8eraid first saw them
+// paused is remembered under key instead.
+func deploymentPausedSince(key string, deployment *appsv1.Deployment, now time.Time) time.Time {
+	for _, condition := range deployment.Status.Conditions {
+		if condition.Type == appsv1.DeploymentProgressing && condition.Reason == deploymentPausedReason {
+			return condition.LastTransitionTime.Time
+		}
+	}
+	since := now
+	stateStore.UpdateSnapshot(key, func(previous state.Snapshot, _ bool) state.Snapshot {
+		if recorded, err := time.Parse(time.RFC3339, previous["since"]); err == nil {
+			since = recorded
+		}
+		return state.Snapshot{"since": since.UTC().Format(time.RFC3339)}
+	})
+	return since
+}
+
+// checkDeploymentRollout alerts on a rollout that does not complete: one the controller reports past
+// its progress deadline, or one that has left replicas on the old pod template for longer than the
+// threshold. Either can go unnoticed while the old ReplicaSet keeps the minimum replicas available.
+// Paused deployments are left to checkDeploymentPaused, since they are not expected to progress.
+func checkDeploymentRollout(
+	deployment *appsv1.Deployment,
+	alertSpec types.DeploymentAlertSpec,
+	now time.Time,
+	alertFn alertFunction,
+	alertersConfig types.AlertersConfig,
+) {
+	resource := resourceID("deployment", deployment.Namespace, deployment.Name)
+	if alertSpec.ReportStatus.ProgressDeadlineExceeded && !deployment.Spec.Paused {
+		for _, condition := range deployment.Status.Conditions {
+			if condition.Type != appsv1.DeploymentProgressing || condition.Status != corev1.ConditionFalse || condition.Reason != deploymentDeadlineReason {
+				continue
+			}
+			deadline := int32(600)
+			if deployment.Spec.ProgressDeadlineSeconds != nil {
+				deadline = *deployment.Spec.ProgressDeadlineSeconds
+			}
+			// ALERT
+			alertmessage := fmt.Sprintf(
+				"Deployment %s/%s has made no rollout progress within its %ds progress deadline! %s",
+				deployment.Namespace, deployment.Name, deadline, condition.Message,
+			)
+			alert := newAlert(resource, "ProgressDeadlineExceeded", types.SeverityCritical, alertmessage)
+			alertFn(alertSpec.AlerterType, alertSpec.AlerterName, alert, alertersConfig)
+		}
+	}
+
+	threshold := alertSpec.ReportStatus.UpdateLagThreshold
+	if threshold <= 0 {
+		return
+	}
+	wanted := int32(1)
+	if deployment.Spec.Replicas != nil {
+		wanted = *deployment.Spec.Replicas
+	}
+	updated := deployment.Status.UpdatedReplicas
+	lagging := !deployment.Spec.Paused && updated < wanted
+	if lagFor := unhealthyFor("updatelag/"+resource, lagging, deployment.CreationTimestamp.Time, now); lagging && lagFor > time.Duration(threshold)*time.Second {
+		// ALERT
+		alertmessage := fmt.Sprintf(
+			"Deployment %s/%s has had only %d of %d replicas updated for %s, longer than %ds! Its rollout has not completed.",
+			deployment.Namespace, deployment.Name, updated, wanted, formatLongDuration(lagFor), threshold,
+		)
+		alert := newAlert(resource, "UpdateLag", types.SeverityWarning, alertmessage)
+		alertFn(alertSpec.AlerterType, alertSpec.AlerterName, alert, alertersConfig)
+	}
+}
+
+// checkDeploymentOOMKills rolls the OOMKilled containers of all the pods selected by a deployment up
+// into one alert, which points at memory limits that are too low for the whole deployment.
+func checkDeploymentOOMKills(
+	deployment *appsv1.Deployment,
+	pods []corev1.Pod,
+	alertSpec types.DeploymentAlertSpec,
+	now time.Time,
+	alertFn alertFunction,
+	alertersConfig types.AlertersConfig,
+) {
+	check := alertSpec.ReportStatus.OOMKills
+	if check.Threshold <= 0 || deployment.Spec.Selector == nil {
+		return
+	}
+	if check.Window <= 0 {
+		check.Window = 3600
+	}
+
+	// A termination is first reported as the container state and then as its last state once it
+	// restarts; both carry the same finish time, so the store only counts it once.
+	kills := map[string]time.Time{}
+	for _, pod := range pods {
+		for _, status := range pod.Status.ContainerStatuses {
+			for _, terminated := range []*corev1.ContainerStateTerminated{status.State.Terminated, status.LastTerminationState.Terminated} {
+				if terminated == nil || terminated.Reason != "OOMKilled" || terminated.FinishedAt.IsZero() {
+					continue
+				}
+				kills[fmt.Sprintf("%s/%s/%d", pod.Name, status.Name, terminated.FinishedAt.Unix())] = terminated.FinishedAt.Time
+			}
+		}
+	}
+
+	window := time.Duration(check.Window) * time.Second
+	resource := resourceID("deployment", deployment.Namespace, deployment.Name)
+	count := stateStore.RecordEvents(resource+"/OOMKilled", kills, now, window)
+	if count >= check.Threshold {
+		// ALERT
+		alertmessage := fmt.Sprintf(
+			"Deployment %s/%s had %d OOMKilled containers across its pods in the last %s, its memory limits may be too low!",
+			deployment.Namespace,
+			deployment.Name,
+			count,
+			window,
+		)
+		alertFn(alertSpec.AlerterType, alertSpec.AlerterName, newAlert(resource, "OOMKilled", types.SeverityWarning, alertmessage), alertersConfig)
+	}
+}
+
+// checkDeploymentReplicaSets counts the ReplicaSets a deployment owns. More than one of them with
+// replicas means a rollout is stuck or was only partly rolled back, which the deployment status
+// does not show, and a long tail of scaled down ones is revision history bloat.
+func checkDeploymentReplicaSets(
+	deployment *appsv1.Deployment,
+	replicaSets []appsv1.ReplicaSet,
+	alertSpec types.DeploymentAlertSpec,
+	alertFn alertFunction,
+	alertersConfig types.AlertersConfig,
+) {
+	check := alertSpec.ReportStatus.ReplicaSets
+	if (check.MaxActive <= 0 && check.MaxRevisions <= 0) || deployment.Spec.Selector == nil {
+		return
+	}
+
+	owned, active := 0, 0
+	for _, replicaSet := range replicaSets {
+		if !metav1.IsControlledBy(&replicaSet, deployment) {
+			continue
+		}
+		owned++
+		// a ReplicaSet scaled to zero may still be waiting on its pods to terminate
+		if (replicaSet.Spec.Replicas != nil && *replicaSet.Spec.Replicas > 0) || replicaSet.Status.Replicas > 0 {
+			active++
 		}
 	}
+
+	resource := resourceID("deployment", deployment.Namespace, deployment.Name)
+	if check.MaxActive > 0 && active > check.MaxActive {
+		// ALERT
+		alertmessage := fmt.Sprintf(
+			"Deployment %s/%s has %d ReplicaSets with replicas, more than the %d allowed, a rollout may be stuck or partially rolled back!",
+			deployment.Namespace,
+			deployment.Name,
+			active,
+			check.MaxActive,
+		)
+		alertFn(alertSpec.AlerterType, alertSpec.AlerterName, newAlert(resource, "ActiveReplicaSets", types.SeverityWarning, alertmessage), alertersConfig)
+	}
+	if check.MaxRevisions > 0 && owned > check.MaxRevisions {
+		// ALERT
+		alertmessage := fmt.Sprintf(
+			"Deployment %s/%s keeps %d ReplicaSets (%d with replicas), more than the %d revisions allowed, its revisionHistoryLimit may be too high!",
+			deployment.Namespace,
+			deployment.Name,
+			owned,
+			active,
+			check.MaxRevisions,
+		)
+		alertFn(alertSpec.AlerterType, alertSpec.AlerterName, newAlert(resource, "RevisionHistory", types.SeverityInfo, alertmessage), alertersConfig)
+	}
 }
@@ -15,12 +15,17 @@
 package queries
 
 import (
+	"fmt"
+	"strings"
 	"testing"
 	"time"
 
+	"github.com/bloomberg/k8eraid/pkgs/state"
 	. "github.com/bloomberg/k8eraid/pkgs/types"
 
+	"github.com/stretchr/testify/assert"
 	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/client-go/kubernetes/fake"
 )
@@ -91,7 +96,7 @@ func Test_PollDeployment_ok(t *testing.T) {
 		t.Run(test.name, func(subT *testing.T) {
 			client := fake.NewSimpleClientset(test.deployment)
 			stubCalled := false
-			alertStub := func(_ string, _ string, _ string, _ AlertersConfig) {
+			alertStub := func(_ string, _ string, _ Alert, _ AlertersConfig) {
 				stubCalled = true
 			}
 			err := PollDeployment(client, test.alertSpec, defaultTickerTime, alertStub, conf)
@@ -106,3 +111,318 @@ func Test_PollDeployment_ok(t *testing.T) {
 		})
 	}
 }
+
+func Test_PollDeployment_OOMKills(t *testing.T) {
+	stateStore = state.NewStore()
+	_, conf := StubsInit()
+	now := time.Now()
+	deployment := &appsv1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{Name: "web", Namespace: metav1.NamespaceDefault},
+		Spec: appsv1.DeploymentSpec{
+			Selector: &metav1.LabelSelector{MatchLabels: map[string]string{"app": "web"}},
+		},
+	}
+	alertSpec := DeploymentAlertSpec{
+		Name:      "web",
+		DepFilter: metav1.NamespaceDefault,
+		ReportStatus: DeploymentAlertStatus{
+			OOMKills: OOMKillCheck{Threshold: 3, Window: 600},
+		},
+	}
+
+	var alerts []Alert
+	alertStub := func(_ string, _ string, alert Alert, _ AlertersConfig) {
+		alerts = append(alerts, alert)
+	}
+
+	other := oomKilledPod("other", metav1.NamespaceDefault, "other", now, now, now)
+	client := fake.NewSimpleClientset(
+		deployment,
+		other,
+		oomKilledPod("web-1", metav1.NamespaceDefault, "web", now.Add(-time.Minute), now.Add(-time.Hour)),
+		oomKilledPod("web-2", metav1.NamespaceDefault, "web", now.Add(-2*time.Minute)),
+	)
+	assert.NoError(t, PollDeployment(client, alertSpec, defaultTickerTime, alertStub, conf))
+	assert.Empty(t, alerts, "kills outside the window or of other pods should not count")
+
+	assert.NoError(t, PollDeployment(client, alertSpec, defaultTickerTime, alertStub, conf))
+	assert.Empty(t, alerts, "kills seen on a previous tick should only count once")
+
+	_, err := client.CoreV1().Pods(metav1.NamespaceDefault).Create(oomKilledPod("web-3", metav1.NamespaceDefault, "web", now))
+	assert.NoError(t, err)
+	assert.NoError(t, PollDeployment(client, alertSpec, defaultTickerTime, alertStub, conf))
+	if assert.Equal(t, 1, len(alerts)) {
+		assert.Equal(t, "deployment/default/web/OOMKilled", alerts[0].Key)
+		assert.Equal(t, "Deployment default/web had 3 OOMKilled containers across its pods in the last 10m0s, its memory limits may be too low!", alerts[0].Message)
+	}
+}
+
+func Test_PollDeployment_ReplicaSets(t *testing.T) {
+	_, conf := StubsInit()
+	deployment := &appsv1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{Name: "web", Namespace: metav1.NamespaceDefault, UID: "web-uid"},
+		Spec: appsv1.DeploymentSpec{
+			Selector: &metav1.LabelSelector{MatchLabels: map[string]string{"app": "web"}},
+		},
+	}
+	other := &appsv1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{Name: "web-canary", Namespace: metav1.NamespaceDefault, UID: "canary-uid"},
+		Spec:       deployment.Spec,
+	}
+	client := fake.NewSimpleClientset(
+		deployment,
+		ownedReplicaSet(deployment, "web-1", 0),
+		ownedReplicaSet(deployment, "web-2", 0),
+		ownedReplicaSet(deployment, "web-3", 2),
+		ownedReplicaSet(deployment, "web-4", 1),
+		ownedReplicaSet(other, "web-canary-1", 1),
+	)
+
+	tests := []struct {
+		name     string
+		check    ReplicaSetCheck
+		expected []string
+	}{
+		{
+			name:  "stuck rollout",
+			check: ReplicaSetCheck{MaxActive: 1},
+			expected: []string{
+				"Deployment default/web has 2 ReplicaSets with replicas, more than the 1 allowed, a rollout may be stuck or partially rolled back!",
+			},
+		},
+		{
+			name:  "revision history",
+			check: ReplicaSetCheck{MaxActive: 2, MaxRevisions: 3},
+			expected: []string{
+				"Deployment default/web keeps 4 ReplicaSets (2 with replicas), more than the 3 revisions allowed, its revisionHistoryLimit may be too high!",
+			},
+		},
+		{
+			name:  "within limits",
+			check: ReplicaSetCheck{MaxActive: 2, MaxRevisions: 4},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(subT *testing.T) {
+			var messages []string
+			alertStub := func(_ string, _ string, alert Alert, _ AlertersConfig) {
+				messages = append(messages, alert.Message)
+			}
+			alertSpec := DeploymentAlertSpec{
+				Name:         "web",
+				DepFilter:    metav1.NamespaceDefault,
+				ReportStatus: DeploymentAlertStatus{ReplicaSets: test.check},
+			}
+			assert.NoError(subT, PollDeployment(client, alertSpec, defaultTickerTime, alertStub, conf))
+			assert.Equal(subT, test.expected, messages)
+		})
+	}
+}
+
+func Test_PollDeployment_PodSpread(t *testing.T) {
+	_, conf := StubsInit()
+	deployment := &appsv1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{Name: "web", Namespace: metav1.NamespaceDefault},
+		Spec: appsv1.DeploymentSpec{
+			Selector: &metav1.LabelSelector{MatchLabels: map[string]string{"app": "web"}},
+		},
+	}
+	zoned := func(name string, zone string) *corev1.Node {
+		node := readyNode(name, corev1.ConditionTrue)
+		node.Labels = map[string]string{"zone": zone}
+		return node
+	}
+	client := fake.NewSimpleClientset(
+		deployment,
+		zoned("node-a", "zone-1"),
+		zoned("node-b", "zone-1"),
+		zoned("node-c", "zone-2"),
+		readyNode("node-d", corev1.ConditionTrue),
+		scheduledPod("web-1", metav1.NamespaceDefault, "web", "node-a", corev1.ConditionTrue),
+		scheduledPod("web-2", metav1.NamespaceDefault, "web", "node-a", corev1.ConditionTrue),
+		scheduledPod("web-3", metav1.NamespaceDefault, "web", "node-b", corev1.ConditionTrue),
+		scheduledPod("web-4", metav1.NamespaceDefault, "web", "node-c", corev1.ConditionTrue),
+		scheduledPod("web-5", metav1.NamespaceDefault, "web", "node-d", corev1.ConditionTrue),
+		scheduledPod("web-6", metav1.NamespaceDefault, "web", "node-c", corev1.ConditionFalse),
+		scheduledPod("web-7", metav1.NamespaceDefault, "web", "", corev1.ConditionFalse),
+		scheduledPod("other-1", metav1.NamespaceDefault, "other", "node-c", corev1.ConditionTrue),
+	)
+
+	tests := []struct {
+		name     string
+		check    PodSpreadCheck
+		expected []string
+	}{
+		{
+			name:  "concentrated on a node",
+			check: PodSpreadCheck{MaxNodePercent: 30},
+			expected: []string{
+				"Deployment default/web runs 2 of 5 ready pods (40%) on node node-a, more than the 30% allowed! Pods per node: node-a=2, node-b=1, node-c=1, node-d=1",
+			},
+		},
+		{
+			name:  "concentrated in a zone",
+			check: PodSpreadCheck{MaxNodePercent: 40, MaxZonePercent: 50, ZoneLabel: "zone"},
+			expected: []string{
+				"Deployment default/web runs 3 of 4 ready pods (75%) on zone zone-1, more than the 50% allowed! Pods per zone: zone-1=3, zone-2=1",
+			},
+		},
+		{
+			name:  "too few pods to spread",
+			check: PodSpreadCheck{MaxNodePercent: 30, MinPods: 6},
+		},
+		{
+			name:  "nodes without the default zone label",
+			check: PodSpreadCheck{MaxZonePercent: 50},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(subT *testing.T) {
+			var messages []string
+			alertStub := func(_ string, _ string, alert Alert, _ AlertersConfig) {
+				messages = append(messages, alert.Message)
+			}
+			alertSpec := DeploymentAlertSpec{
+				Name:         "web",
+				DepFilter:    metav1.NamespaceDefault,
+				ReportStatus: DeploymentAlertStatus{PodSpread: test.check},
+			}
+			assert.NoError(subT, PollDeployment(client, alertSpec, defaultTickerTime, alertStub, conf))
+			assert.Equal(subT, test.expected, messages)
+		})
+	}
+}
+
+func Test_checkDeploymentPaused(t *testing.T) {
+	stateStore = state.NewStore()
+	_, conf := StubsInit()
+	now := time.Now()
+	alertSpec := DeploymentAlertSpec{Name: "*", ReportStatus: DeploymentAlertStatus{PausedThreshold: 3600}}
+	var messages []string
+	alertStub := func(_ string, _ string, alert Alert, _ AlertersConfig) {
+		assert.Equal(t, "deployment/default/web/Paused", alert.Key)
+		messages = append(messages, alert.Message)
+	}
+	deployment := &appsv1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{Name: "web", Namespace: metav1.NamespaceDefault},
+		Spec:       appsv1.DeploymentSpec{Paused: true},
+		Status: appsv1.DeploymentStatus{Conditions: []appsv1.DeploymentCondition{{
+			Type:               appsv1.DeploymentProgressing,
+			Status:             corev1.ConditionUnknown,
+			Reason:             deploymentPausedReason,
+			LastTransitionTime: metav1.Time{Time: now.Add(-3 * time.Hour)},
+		}}},
+	}
+	checkDeploymentPaused(deployment, alertSpec, now, alertStub, conf)
+	assert.Equal(t, []string{"Deployment default/web has been paused for 3h0m0s, longer than 3600s! It receives no updates until its rollout is resumed."}, messages)
+
+	messages = nil
+	deployment.Status.Conditions = nil
+	checkDeploymentPaused(deployment, alertSpec, now, alertStub, conf)
+	assert.Empty(t, messages, "without a condition the pause counts from when it was first seen")
+	checkDeploymentPaused(deployment, alertSpec, now.Add(2*time.Hour), alertStub, conf)
+	assert.Equal(t, []string{"Deployment default/web has been paused for 2h0m0s, longer than 3600s! It receives no updates until its rollout is resumed."}, messages)
+
+	messages = nil
+	deployment.Spec.Paused = false
+	checkDeploymentPaused(deployment, alertSpec, now.Add(3*time.Hour), alertStub, conf)
+	deployment.Spec.Paused = true
+	checkDeploymentPaused(deployment, alertSpec, now.Add(4*time.Hour), alertStub, conf)
+	assert.Empty(t, messages, "resuming should restart the pause")
+
+	alertSpec.ReportStatus.PausedThreshold = 0
+	checkDeploymentPaused(deployment, alertSpec, now.Add(24*time.Hour), alertStub, conf)
+	assert.Empty(t, messages, "the check should be off without a threshold")
+}
+
+func Test_checkDeploymentRollout(t *testing.T) {
+	stateStore = state.NewStore()
+	_, conf := StubsInit()
+	now := time.Now()
+	replicas := int32(4)
+	alertSpec := DeploymentAlertSpec{Name: "*", ReportStatus: DeploymentAlertStatus{ProgressDeadlineExceeded: true, UpdateLagThreshold: 600}}
+	var keys, messages []string
+	alertStub := func(_ string, _ string, alert Alert, _ AlertersConfig) {
+		keys = append(keys, alert.Key)
+		messages = append(messages, alert.Message)
+	}
+	deployment := &appsv1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{Name: "web", Namespace: metav1.NamespaceDefault, CreationTimestamp: metav1.Time{Time: now.Add(-24 * time.Hour)}},
+		Spec:       appsv1.DeploymentSpec{Replicas: &replicas},
+		Status: appsv1.DeploymentStatus{UpdatedReplicas: 1, AvailableReplicas: 4, Conditions: []appsv1.DeploymentCondition{{
+			Type:    appsv1.DeploymentProgressing,
+			Status:  corev1.ConditionFalse,
+			Reason:  deploymentDeadlineReason,
+			Message: `ReplicaSet "web-2" has timed out progressing.`,
+		}}},
+	}
+	checkDeploymentRollout(deployment, alertSpec, now, alertStub, conf)
+	assert.Equal(t, []string{"deployment/default/web/ProgressDeadlineExceeded"}, keys, "a lag first seen has just started")
+	assert.Equal(t, []string{`Deployment default/web has made no rollout progress within its 600s progress deadline! ReplicaSet "web-2" has timed out progressing.`}, messages)
+
+	keys, messages = nil, nil
+	deployment.Status.Conditions = nil
+	checkDeploymentRollout(deployment, alertSpec, now.Add(20*time.Minute), alertStub, conf)
+	assert.Equal(t, []string{"Deployment default/web has had only 1 of 4 replicas updated for 20m0s, longer than 600s! Its rollout has not completed."}, messages)
+
+	messages = nil
+	deployment.Spec.Paused = true
+	checkDeploymentRollout(deployment, alertSpec, now.Add(30*time.Minute), alertStub, conf)
+	deployment.Spec.Paused = false
+	checkDeploymentRollout(deployment, alertSpec, now.Add(35*time.Minute), alertStub, conf)
+	assert.Empty(t, messages, "pausing the rollout should restart the lag")
+
+	deployment.Status.UpdatedReplicas = 4
+	checkDeploymentRollout(deployment, alertSpec, now.Add(2*time.Hour), alertStub, conf)
+	assert.Empty(t, messages, "a completed rollout should not alert")
+}
+
+// ownedReplicaSet builds a ReplicaSet of deployment carrying its pod labels and scaled to replicas
+func ownedReplicaSet(deployment *appsv1.Deployment, name string, replicas int32) *appsv1.ReplicaSet {
+	return &appsv1.ReplicaSet{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:            name,
+			Namespace:       deployment.Namespace,
+			Labels:          deployment.Spec.Selector.MatchLabels,
+			Annotations:     map[string]string{deploymentRevisionAnnotation: strings.TrimPrefix(name, deployment.Name+"-")},
+			OwnerReferences: []metav1.OwnerReference{*metav1.NewControllerRef(deployment, appsv1.SchemeGroupVersion.WithKind("Deployment"))},
+		},
+		Spec:   appsv1.ReplicaSetSpec{Replicas: &replicas},
+		Status: appsv1.ReplicaSetStatus{Replicas: replicas},
+	}
+}
+
+// scheduledPod builds a pod labelled app=<app> running on node, whose only condition is Ready with the given status
+func scheduledPod(name string, namespace string, app string, node string, ready corev1.ConditionStatus) *corev1.Pod {
+	return &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: namespace,
+			Labels:    map[string]string{"app": app},
+		},
+		Spec: corev1.PodSpec{NodeName: node},
+		Status: corev1.PodStatus{
+			Conditions: []corev1.PodCondition{{Type: corev1.PodReady, Status: ready}},
+		},
+	}
+}
+
+// oomKilledPod builds a pod labelled app=<app> with one container per finish time, each last OOMKilled at that time
+func oomKilledPod(name string, namespace string, app string, finishedAt ...time.Time) *corev1.Pod {
+	pod := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: namespace,
+			Labels:    map[string]string{"app": app},
+		},
+	}
+	for i, at := range finishedAt {
+		pod.Status.ContainerStatuses = append(pod.Status.ContainerStatuses, corev1.ContainerStatus{
+			Name: fmt.Sprint("container-", i),
+			LastTerminationState: corev1.ContainerState{
+				Terminated: &corev1.ContainerStateTerminated{Reason: "OOMKilled", FinishedAt: metav1.Time{Time: at}},
+			},
+		})
+	}
+	return pod
+}
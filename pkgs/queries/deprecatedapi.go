@@ -0,0 +1,338 @@
+// Copyright 2019 Bloomberg Finance LP
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package queries
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/bloomberg/k8eraid/pkgs/types"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/kubernetes"
+)
+
+// lastAppliedAnnotation is where kubectl apply records the manifest an object was last applied from
+const lastAppliedAnnotation = "kubectl.kubernetes.io/last-applied-configuration"
+
+// deprecatedAPI is a deprecated group-version of a kind, the Kubernetes release that stops serving it and
+// the group-version to move its manifests to
+type deprecatedAPI struct {
+	gvr         schema.GroupVersionResource
+	kind        string
+	removedIn   string
+	replacement string
+	namespaced  bool
+}
+
+// deprecatedAPIs are the deprecated group-versions of built-in kinds Kubernetes has removed or plans to remove
+var deprecatedAPIs = []deprecatedAPI{
+	{schema.GroupVersionResource{Group: "extensions", Version: "v1beta1", Resource: "deployments"}, "Deployment", "1.16", "apps/v1", true},
+	{schema.GroupVersionResource{Group: "extensions", Version: "v1beta1", Resource: "daemonsets"}, "DaemonSet", "1.16", "apps/v1", true},
+	{schema.GroupVersionResource{Group: "extensions", Version: "v1beta1", Resource: "replicasets"}, "ReplicaSet", "1.16", "apps/v1", true},
+	{schema.GroupVersionResource{Group: "extensions", Version: "v1beta1", Resource: "networkpolicies"}, "NetworkPolicy", "1.16", "networking.k8s.io/v1", true},
+	{schema.GroupVersionResource{Group: "extensions", Version: "v1beta1", Resource: "ingresses"}, "Ingress", "1.22", "networking.k8s.io/v1", true},
+	{schema.GroupVersionResource{Group: "apps", Version: "v1beta1", Resource: "deployments"}, "Deployment", "1.16", "apps/v1", true},
+	{schema.GroupVersionResource{Group: "apps", Version: "v1beta1", Resource: "statefulsets"}, "StatefulSet", "1.16", "apps/v1", true},
+	{schema.GroupVersionResource{Group: "apps", Version: "v1beta2", Resource: "deployments"}, "Deployment", "1.16", "apps/v1", true},
+	{schema.GroupVersionResource{Group: "apps", Version: "v1beta2", Resource: "statefulsets"}, "StatefulSet", "1.16", "apps/v1", true},
+	{schema.GroupVersionResource{Group: "apps", Version: "v1beta2", Resource: "daemonsets"}, "DaemonSet", "1.16", "apps/v1", true},
+	{schema.GroupVersionResource{Group: "apps", Version: "v1beta2", Resource: "replicasets"}, "ReplicaSet", "1.16", "apps/v1", true},
+	{schema.GroupVersionResource{Group: "networking.k8s.io", Version: "v1beta1", Resource: "ingresses"}, "Ingress", "1.22", "networking.k8s.io/v1", true},
+	{schema.GroupVersionResource{Group: "admissionregistration.k8s.io", Version: "v1beta1", Resource: "validatingwebhookconfigurations"}, "ValidatingWebhookConfiguration", "1.22", "admissionregistration.k8s.io/v1", false},
+	{schema.GroupVersionResource{Group: "admissionregistration.k8s.io", Version: "v1beta1", Resource: "mutatingwebhookconfigurations"}, "MutatingWebhookConfiguration", "1.22", "admissionregistration.k8s.io/v1", false},
+	{schema.GroupVersionResource{Group: "apiextensions.k8s.io", Version: "v1beta1", Resource: "customresourcedefinitions"}, "CustomResourceDefinition", "1.22", "apiextensions.k8s.io/v1", false},
+	{schema.GroupVersionResource{Group: "rbac.authorization.k8s.io", Version: "v1beta1", Resource: "clusterroles"}, "ClusterRole", "1.22", "rbac.authorization.k8s.io/v1", false},
+	{schema.GroupVersionResource{Group: "rbac.authorization.k8s.io", Version: "v1beta1", Resource: "clusterrolebindings"}, "ClusterRoleBinding", "1.22", "rbac.authorization.k8s.io/v1", false},
+	{schema.GroupVersionResource{Group: "rbac.authorization.k8s.io", Version: "v1beta1", Resource: "roles"}, "Role", "1.22", "rbac.authorization.k8s.io/v1", true},
+	{schema.GroupVersionResource{Group: "rbac.authorization.k8s.io", Version: "v1beta1", Resource: "rolebindings"}, "RoleBinding", "1.22", "rbac.authorization.k8s.io/v1", true},
+	{schema.GroupVersionResource{Group: "scheduling.k8s.io", Version: "v1beta1", Resource: "priorityclasses"}, "PriorityClass", "1.22", "scheduling.k8s.io/v1", false},
+	{schema.GroupVersionResource{Group: "storage.k8s.io", Version: "v1beta1", Resource: "storageclasses"}, "StorageClass", "1.22", "storage.k8s.io/v1", false},
+	{schema.GroupVersionResource{Group: "batch", Version: "v1beta1", Resource: "cronjobs"}, "CronJob", "1.25", "batch/v1", true},
+	{schema.GroupVersionResource{Group: "policy", Version: "v1beta1", Resource: "poddisruptionbudgets"}, "PodDisruptionBudget", "1.25", "policy/v1", true},
+	{schema.GroupVersionResource{Group: "autoscaling", Version: "v2beta1", Resource: "horizontalpodautoscalers"}, "HorizontalPodAutoscaler", "1.25", "autoscaling/v2", true},
+	{schema.GroupVersionResource{Group: "autoscaling", Version: "v2beta2", Resource: "horizontalpodautoscalers"}, "HorizontalPodAutoscaler", "1.26", "autoscaling/v2", true},
+}
+
+// PollDeprecatedAPI function takes inputs and looks for uses of deprecated API versions in the kubernetes cluster,
+// triggering alerts as needed ahead of the upgrades that remove them.
+func PollDeprecatedAPI(
+	clientset kubernetes.Interface,
+	client dynamic.Interface,
+	alertSpec types.DeprecatedAPIAlertSpec,
+	tickertime int64,
+	alertFn alertFunction,
+	alertersConfig types.AlertersConfig,
+) error {
+	target, hasTarget := types.ParseKubernetesVersion(alertSpec.ReportStatus.TargetVersion)
+	if alertSpec.ReportStatus.TargetVersion != "" && !hasTarget {
+		return &PollErr{
+			Message: fmt.Sprintf("Deprecated API rule has an unparseable target version %q, ignoring", alertSpec.ReportStatus.TargetVersion),
+		}
+	}
+	// removedBy tells whether an API version removed in release goes before the target of the rule.
+	// An API version with no planned removal is only reported when the rule has no target.
+	removedBy := func(release string) bool {
+		if !hasTarget {
+			return true
+		}
+		removed, parsed := types.ParseKubernetesVersion(release)
+		return parsed && !target.Less(removed)
+	}
+
+	if alertSpec.ReportStatus.Objects {
+		served, servederr := servedResources(clientset)
+		if servederr != nil {
+			return servederr
+		}
+		if err := checkDeprecatedObjects(client, served, removedBy, alertSpec, alertFn, alertersConfig); err != nil {
+			return err
+		}
+	}
+	if alertSpec.ReportStatus.Requested {
+		metrics, metricserr := apiMetricsProbe(clientset)
+		if metricserr != nil {
+			return &PollErr{
+				Message: fmt.Sprintf("Unable to read the apiserver metrics: %s", metricserr.Error()),
+			}
+		}
+		checkRequestedDeprecatedAPIs(metrics, removedBy, alertSpec, alertFn, alertersConfig)
+	}
+	return nil
+}
+
+// servedResources asks discovery which of the group-versions deprecatedAPIs involves the apiserver serves,
+// and which resources it serves in each of them
+func servedResources(clientset kubernetes.Interface) (map[schema.GroupVersionResource]bool, error) {
+	groups, groupserr := clientset.Discovery().ServerGroups()
+	if groupserr != nil {
+		return nil, &PollErr{
+			Message: fmt.Sprintf("Unable to discover the served API groups: %s", groupserr.Error()),
+		}
+	}
+	wanted := map[string]bool{}
+	for _, api := range deprecatedAPIs {
+		wanted[api.gvr.GroupVersion().String()] = true
+		wanted[api.replacement] = true
+	}
+	served := map[schema.GroupVersionResource]bool{}
+	for _, group := range groups.Groups {
+		for _, groupVersion := range group.Versions {
+			if !wanted[groupVersion.GroupVersion] {
+				continue
+			}
+			resources, resourceserr := clientset.Discovery().ServerResourcesForGroupVersion(groupVersion.GroupVersion)
+			if resourceserr != nil {
+				return nil, &PollErr{
+					Message: fmt.Sprintf("Unable to discover the resources served in %s: %s", groupVersion.GroupVersion, resourceserr.Error()),
+				}
+			}
+			gv, _ := schema.ParseGroupVersion(groupVersion.GroupVersion)
+			for _, resource := range resources.APIResources {
+				served[gv.WithResource(resource.Name)] = true
+			}
+		}
+	}
+	return served, nil
+}
+
+// checkDeprecatedObjects alerts on every object last applied with a deprecated API version. An object
+// reads the same through whichever version of its kind is served, so each kind is listed once, through
+// its replacement version when served and else through a deprecated one. The version an object was
+// created with is not recorded by the apiserver, so only objects applied with kubectl apply are found.
+func checkDeprecatedObjects(
+	client dynamic.Interface,
+	served map[schema.GroupVersionResource]bool,
+	removedBy func(string) bool,
+	alertSpec types.DeprecatedAPIAlertSpec,
+	alertFn alertFunction,
+	alertersConfig types.AlertersConfig,
+) error {
+	byAppliedVersion := map[string]deprecatedAPI{}
+	// versions of each kind to list it through, its replacement version first
+	var kinds []*deprecatedKind
+	byReplacement := map[string]*deprecatedKind{}
+	for _, api := range deprecatedAPIs {
+		byAppliedVersion[api.gvr.GroupVersion().String()+" "+api.kind] = api
+		if !removedBy(api.removedIn) || (!api.namespaced && alertSpec.DeprecatedFilterNamespace != "") {
+			continue
+		}
+		kind, seen := byReplacement[api.replacement+" "+api.kind]
+		if !seen {
+			replacement, _ := schema.ParseGroupVersion(api.replacement)
+			kind = &deprecatedKind{namespaced: api.namespaced, versions: []schema.GroupVersionResource{replacement.WithResource(api.gvr.Resource)}}
+			byReplacement[api.replacement+" "+api.kind] = kind
+			kinds = append(kinds, kind)
+		}
+		kind.versions = append(kind.versions, api.gvr)
+	}
+
+	for _, kind := range kinds {
+		listed, ok := kind.servedVersion(served)
+		if !ok {
+			continue
+		}
+		var resourceClient dynamic.ResourceInterface = client.Resource(listed)
+		if kind.namespaced {
+			resourceClient = client.Resource(listed).Namespace(alertSpec.DeprecatedFilterNamespace)
+		}
+		list, listerr := resourceClient.List(metav1.ListOptions{
+			LabelSelector:  alertSpec.DeprecatedFilterLabel,
+			TimeoutSeconds: &timeout,
+		})
+		if listerr != nil {
+			return &PollErr{
+				Message: fmt.Sprintf("Unable to list %s: %s", listed.String(), listerr.Error()),
+			}
+		}
+		sort.Slice(list.Items, func(i, j int) bool {
+			return list.Items[i].GetNamespace()+"/"+list.Items[i].GetName() < list.Items[j].GetNamespace()+"/"+list.Items[j].GetName()
+		})
+		for i := range list.Items {
+			api, deprecated := byAppliedVersion[lastAppliedVersion(&list.Items[i])]
+			if !deprecated || !removedBy(api.removedIn) {
+				continue
+			}
+			alertDeprecatedObject(&list.Items[i], api, served[api.gvr], alertSpec, alertFn, alertersConfig)
+		}
+	}
+	return nil
+}
+
+// deprecatedKind is a kind with deprecated API versions, and the versions it can be listed through
+type deprecatedKind struct {
+	namespaced bool
+	versions   []schema.GroupVersionResource
+}
+
+// servedVersion returns the first version of kind the apiserver serves
+func (kind *deprecatedKind) servedVersion(served map[schema.GroupVersionResource]bool) (schema.GroupVersionResource, bool) {
+	for _, gvr := range kind.versions {
+		if served[gvr] {
+			return gvr, true
+		}
+	}
+	return schema.GroupVersionResource{}, false
+}
+
+// lastAppliedVersion reads the "<apiVersion> <kind>" object was last applied as, empty when it was never applied
+func lastAppliedVersion(object *unstructured.Unstructured) string {
+	applied, ok := object.GetAnnotations()[lastAppliedAnnotation]
+	if !ok {
+		return ""
+	}
+	var manifest struct {
+		APIVersion string `json:"apiVersion"`
+		Kind       string `json:"kind"`
+	}
+	if err := json.Unmarshal([]byte(applied), &manifest); err != nil {
+		return ""
+	}
+	return manifest.APIVersion + " " + manifest.Kind
+}
+
+// alertDeprecatedObject alerts on object last applied as api. Applying its manifests again fails once
+// the apiserver no longer serves api, so that is critical.
+func alertDeprecatedObject(
+	object *unstructured.Unstructured,
+	api deprecatedAPI,
+	stillServed bool,
+	alertSpec types.DeprecatedAPIAlertSpec,
+	alertFn alertFunction,
+	alertersConfig types.AlertersConfig,
+) {
+	name := object.GetName()
+	if object.GetNamespace() != "" {
+		name = object.GetNamespace() + "/" + name
+	}
+	resource := resourceID(strings.ToLower(api.kind), object.GetNamespace(), object.GetName())
+	applied := api.gvr.GroupVersion().String()
+	if !stillServed {
+		// ALERT
+		alertmessage := fmt.Sprintf(
+			"%s %s was last applied as %s, which this cluster no longer serves! Its manifests must be updated to %s before they can be applied again.",
+			api.kind, name, applied, api.replacement,
+		)
+		alertFn(alertSpec.AlerterType, alertSpec.AlerterName, newAlert(resource, "DeprecatedAPI", types.SeverityCritical, alertmessage), alertersConfig)
+		return
+	}
+	// ALERT
+	alertmessage := fmt.Sprintf(
+		"%s %s was last applied as %s, which is removed in Kubernetes %s! Update its manifests to %s before upgrading.",
+		api.kind, name, applied, api.removedIn, api.replacement,
+	)
+	alertFn(alertSpec.AlerterType, alertSpec.AlerterName, newAlert(resource, "DeprecatedAPI", types.SeverityWarning, alertmessage), alertersConfig)
+}
+
+// apiMetricsProbe reads the metrics of the apiserver in the Prometheus text format. It is a variable so
+// tests can stub the apiserver out.
+var apiMetricsProbe = func(clientset kubernetes.Interface) (string, error) {
+	body, err := clientset.CoreV1().RESTClient().Get().
+		AbsPath("/metrics").
+		Timeout(time.Duration(timeout) * time.Second).
+		DoRaw()
+	return string(body), err
+}
+
+// requestedDeprecatedAPIPattern matches the samples of apiserver_requested_deprecated_apis that are set,
+// one per deprecated resource clients requested since the apiserver started
+var requestedDeprecatedAPIPattern = regexp.MustCompile(`(?m)^apiserver_requested_deprecated_apis\{([^}]*)\}\s+1(?:\s|$)`)
+
+// metricLabelPattern matches a name="value" label of a Prometheus sample
+var metricLabelPattern = regexp.MustCompile(`(\w+)="((?:[^"\\]|\\.)*)"`)
+
+// checkRequestedDeprecatedAPIs alerts on every deprecated resource the apiserver metrics report clients
+// requested. The metrics do not say who requested it, which the audit log of the apiserver records.
+func checkRequestedDeprecatedAPIs(
+	metrics string,
+	removedBy func(string) bool,
+	alertSpec types.DeprecatedAPIAlertSpec,
+	alertFn alertFunction,
+	alertersConfig types.AlertersConfig,
+) {
+	for _, sample := range requestedDeprecatedAPIPattern.FindAllStringSubmatch(metrics, -1) {
+		labels := map[string]string{}
+		for _, label := range metricLabelPattern.FindAllStringSubmatch(sample[1], -1) {
+			labels[label[1]] = label[2]
+		}
+		release := labels["removed_release"]
+		if labels["resource"] == "" || !removedBy(release) {
+			continue
+		}
+		groupVersion := schema.GroupVersion{Group: labels["group"], Version: labels["version"]}.String()
+		requested := groupVersion + " " + labels["resource"]
+		if labels["subresource"] != "" {
+			requested += "/" + labels["subresource"]
+		}
+		removal := "which is deprecated"
+		if release != "" {
+			removal = "which is removed in Kubernetes " + release
+		}
+		// ALERT
+		alertmessage := fmt.Sprintf(
+			"Clients requested %s since the apiserver started, %s! The audit log of the apiserver records who requested it.",
+			requested, removal,
+		)
+		resource := resourceID("deprecatedapi", groupVersion, strings.TrimSuffix(labels["resource"]+"/"+labels["subresource"], "/"))
+		alertFn(alertSpec.AlerterType, alertSpec.AlerterName, newAlert(resource, "Requested", types.SeverityWarning, alertmessage), alertersConfig)
+	}
+}
@@ -0,0 +1,107 @@
+// Copyright 2019 Bloomberg Finance LP
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package queries
+
+import (
+	"testing"
+
+	. "github.com/bloomberg/k8eraid/pkgs/types"
+
+	"github.com/stretchr/testify/assert"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	fakediscovery "k8s.io/client-go/discovery/fake"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func appliedObject(apiVersion string, kind string, namespace string, name string, appliedAs string) *unstructured.Unstructured {
+	object := &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": apiVersion,
+		"kind":       kind,
+		"metadata":   map[string]interface{}{"name": name, "namespace": namespace},
+	}}
+	if appliedAs != "" {
+		object.SetAnnotations(map[string]string{lastAppliedAnnotation: `{"apiVersion":"` + appliedAs + `","kind":"` + kind + `"}`})
+	}
+	return object
+}
+
+func Test_PollDeprecatedAPI_Objects(t *testing.T) {
+	_, conf := StubsInit()
+	clientset := fake.NewSimpleClientset()
+	clientset.Discovery().(*fakediscovery.FakeDiscovery).Resources = []*metav1.APIResourceList{
+		{GroupVersion: "apps/v1", APIResources: []metav1.APIResource{{Name: "deployments", Namespaced: true}}},
+		{GroupVersion: "extensions/v1beta1", APIResources: []metav1.APIResource{{Name: "ingresses", Namespaced: true}}},
+		{GroupVersion: "rbac.authorization.k8s.io/v1", APIResources: []metav1.APIResource{{Name: "clusterroles"}}},
+	}
+	client := dynamicfake.NewSimpleDynamicClient(runtime.NewScheme(),
+		appliedObject("apps/v1", "Deployment", "web", "web", "extensions/v1beta1"),
+		appliedObject("apps/v1", "Deployment", "web", "current", "apps/v1"),
+		appliedObject("apps/v1", "Deployment", "db", "created", ""),
+		appliedObject("extensions/v1beta1", "Ingress", "web", "web", "extensions/v1beta1"),
+		appliedObject("rbac.authorization.k8s.io/v1", "ClusterRole", "", "reader", "rbac.authorization.k8s.io/v1beta1"),
+	)
+	poll := func(status DeprecatedAPIAlertStatus, namespace string) []string {
+		messages := []string{}
+		err := PollDeprecatedAPI(clientset, client, DeprecatedAPIAlertSpec{DeprecatedFilterNamespace: namespace, ReportStatus: status}, defaultTickerTime, func(_ string, _ string, alert Alert, _ AlertersConfig) {
+			messages = append(messages, alert.Severity+" "+alert.Message)
+		}, conf)
+		assert.NoError(t, err)
+		return messages
+	}
+
+	assert.Equal(t, []string{
+		"critical Deployment web/web was last applied as extensions/v1beta1, which this cluster no longer serves! Its manifests must be updated to apps/v1 before they can be applied again.",
+		"warning Ingress web/web was last applied as extensions/v1beta1, which is removed in Kubernetes 1.22! Update its manifests to networking.k8s.io/v1 before upgrading.",
+		"critical ClusterRole reader was last applied as rbac.authorization.k8s.io/v1beta1, which this cluster no longer serves! Its manifests must be updated to rbac.authorization.k8s.io/v1 before they can be applied again.",
+	}, poll(DeprecatedAPIAlertStatus{Objects: true}, ""), "kinds are listed through their served version, deprecated or not")
+	assert.Len(t, poll(DeprecatedAPIAlertStatus{Objects: true, TargetVersion: "v1.16"}, ""), 1, "only APIs removed by the target version")
+	assert.Len(t, poll(DeprecatedAPIAlertStatus{Objects: true}, "web"), 2, "cluster-scoped kinds are left out of a namespace")
+	assert.Error(t, PollDeprecatedAPI(clientset, client, DeprecatedAPIAlertSpec{ReportStatus: DeprecatedAPIAlertStatus{TargetVersion: "next"}}, defaultTickerTime, nil, conf))
+}
+
+func Test_PollDeprecatedAPI_Requested(t *testing.T) {
+	savedProbe := apiMetricsProbe
+	defer func() { apiMetricsProbe = savedProbe }()
+	apiMetricsProbe = func(_ kubernetes.Interface) (string, error) {
+		return `# HELP apiserver_requested_deprecated_apis [STABLE] Gauge of deprecated APIs that have been requested
+# TYPE apiserver_requested_deprecated_apis gauge
+apiserver_requested_deprecated_apis{group="batch",removed_release="1.25",resource="cronjobs",subresource="",version="v1beta1"} 1
+apiserver_requested_deprecated_apis{group="policy",removed_release="1.25",resource="podsecuritypolicies",subresource="status",version="v1beta1"} 1
+apiserver_requested_deprecated_apis{group="flowcontrol.apiserver.k8s.io",removed_release="",resource="flowschemas",subresource="",version="v1beta3"} 1
+apiserver_request_total{code="200",resource="cronjobs",verb="LIST",version="v1beta1"} 12
+`, nil
+	}
+	_, conf := StubsInit()
+	poll := func(target string) []string {
+		keys := []string{}
+		err := PollDeprecatedAPI(fake.NewSimpleClientset(), nil, DeprecatedAPIAlertSpec{ReportStatus: DeprecatedAPIAlertStatus{Requested: true, TargetVersion: target}}, defaultTickerTime, func(_ string, _ string, alert Alert, _ AlertersConfig) {
+			keys = append(keys, alert.Key)
+		}, conf)
+		assert.NoError(t, err)
+		return keys
+	}
+
+	assert.Equal(t, []string{
+		"deprecatedapi/batch/v1beta1/cronjobs/Requested",
+		"deprecatedapi/policy/v1beta1/podsecuritypolicies/status/Requested",
+		"deprecatedapi/flowcontrol.apiserver.k8s.io/v1beta3/flowschemas/Requested",
+	}, poll(""))
+	assert.Empty(t, poll("v1.24"), "none is removed by the target version")
+	assert.Len(t, poll("v1.25"), 2, "no planned removal is left out when a target is set")
+}
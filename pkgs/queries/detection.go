@@ -0,0 +1,45 @@
+// Copyright 2019 Bloomberg Finance LP
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package queries
+
+import (
+	"time"
+
+	"github.com/bloomberg/k8eraid/pkgs/metrics"
+	"github.com/bloomberg/k8eraid/pkgs/types"
+)
+
+// detectionLatencyBuckets span a fraction of the default 30s poll period up to the slowest periods
+// worth alerting with
+var detectionLatencyBuckets = []float64{1, 5, 10, 15, 30, 60, 120, 300, 600}
+
+var detectionLatency = metrics.NewHistogramVec(
+	"k8eraid_detection_latency_seconds",
+	"Seconds between a condition's last transition and the poll alerting on it.",
+	detectionLatencyBuckets,
+	"resource_type", "condition",
+)
+
+// recordDetection records on alert, and in the detection latency histogram, how long after the
+// condition transitioned at transition the alert was raised at now
+func recordDetection(alert *types.Alert, condition string, transition time.Time, now time.Time) {
+	latency := now.Sub(transition)
+	if latency < 0 {
+		// the apiserver's clock is ahead of ours
+		latency = 0
+	}
+	alert.DetectedAfter = latency
+	detectionLatency.With(resourceType(alert.Resource), condition).Observe(latency.Seconds())
+}
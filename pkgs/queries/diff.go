@@ -0,0 +1,110 @@
+// Copyright 2019 Bloomberg Finance LP
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package queries
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/bloomberg/k8eraid/pkgs/state"
+	"github.com/bloomberg/k8eraid/pkgs/types"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+)
+
+// specKey identifies a rule across ticks
+func specKey(kind string, name string, filter string) string {
+	return strings.Join([]string{kind, name, filter}, "|")
+}
+
+// reportDiff alerts on every object that was added, removed or changed state since the previous tick.
+// The first tick for a rule only records its snapshot.
+func reportDiff(
+	key string,
+	current state.Snapshot,
+	alerterType string,
+	alerterName string,
+	alertFn alertFunction,
+	alertersConfig types.AlertersConfig,
+) {
+	previous, ok := stateStore.SwapSnapshot(key, current)
+	if !ok {
+		return
+	}
+	diff := state.DiffSnapshots(previous, current)
+	for _, change := range diff.Added {
+		// ALERT
+		alertmessage := fmt.Sprintf("%s was added since last poll (now: %s)", change.Resource, change.After)
+		alertFn(alerterType, alerterName, newAlert(change.Resource, "Added", types.SeverityInfo, alertmessage), alertersConfig)
+	}
+	for _, change := range diff.Removed {
+		// ALERT
+		alertmessage := fmt.Sprintf("%s was removed since last poll (was: %s)", change.Resource, change.Before)
+		alertFn(alerterType, alerterName, newAlert(change.Resource, "Removed", types.SeverityWarning, alertmessage), alertersConfig)
+	}
+	for _, change := range diff.Changed {
+		// ALERT
+		alertmessage := fmt.Sprintf("%s changed since last poll (before: %s, after: %s)", change.Resource, change.Before, change.After)
+		alertFn(alerterType, alerterName, newAlert(change.Resource, "Changed", types.SeverityWarning, alertmessage), alertersConfig)
+	}
+}
+
+func nodeSummary(node *corev1.Node) string {
+	parts := make([]string, 0, len(node.Status.Conditions)+1)
+	for _, condition := range node.Status.Conditions {
+		parts = append(parts, fmt.Sprintf("%s=%s", condition.Type, condition.Status))
+	}
+	if node.Spec.Unschedulable {
+		parts = append(parts, "unschedulable")
+	}
+	return strings.Join(parts, " ")
+}
+
+func podSummary(pod *corev1.Pod) string {
+	ready := corev1.ConditionUnknown
+	for _, condition := range pod.Status.Conditions {
+		if condition.Type == corev1.PodReady {
+			ready = condition.Status
+		}
+	}
+	restarts := int32(0)
+	for _, status := range pod.Status.ContainerStatuses {
+		restarts += status.RestartCount
+	}
+	return fmt.Sprintf("phase=%s ready=%s restarts=%d", pod.Status.Phase, ready, restarts)
+}
+
+func deploymentSummary(deployment *appsv1.Deployment) string {
+	desired := int32(1)
+	if deployment.Spec.Replicas != nil {
+		desired = *deployment.Spec.Replicas
+	}
+	return fmt.Sprintf(
+		"replicas=%d updated=%d available=%d",
+		desired,
+		deployment.Status.UpdatedReplicas,
+		deployment.Status.AvailableReplicas,
+	)
+}
+
+func daemonsetSummary(daemonSet *appsv1.DaemonSet) string {
+	return fmt.Sprintf(
+		"desired=%d scheduled=%d ready=%d",
+		daemonSet.Status.DesiredNumberScheduled,
+		daemonSet.Status.CurrentNumberScheduled,
+		daemonSet.Status.NumberReady,
+	)
+}
@@ -0,0 +1,103 @@
+// Copyright 2019 Bloomberg Finance LP
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package queries
+
+import (
+	"testing"
+
+	"github.com/bloomberg/k8eraid/pkgs/state"
+	. "github.com/bloomberg/k8eraid/pkgs/types"
+
+	"github.com/stretchr/testify/assert"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func Test_PollNode_ReportDiff(t *testing.T) {
+	stateStore = state.NewStore()
+	_, conf := StubsInit()
+	alertSpec := NodeAlertSpec{
+		Name:         "*",
+		ReportStatus: NodeAlertStatus{ReportDiff: true},
+	}
+
+	var alerts []Alert
+	alertStub := func(_ string, _ string, alert Alert, _ AlertersConfig) {
+		alerts = append(alerts, alert)
+	}
+
+	client := fake.NewSimpleClientset(readyNode("a", corev1.ConditionTrue), readyNode("b", corev1.ConditionTrue))
+	assert.NoError(t, PollNode(client, alertSpec, defaultTickerTime, alertStub, conf))
+	assert.Empty(t, alerts, "first tick should only record a snapshot")
+
+	assert.NoError(t, client.CoreV1().Nodes().Delete("b", &metav1.DeleteOptions{}))
+	_, err := client.CoreV1().Nodes().Create(readyNode("c", corev1.ConditionTrue))
+	assert.NoError(t, err)
+	_, err = client.CoreV1().Nodes().Update(readyNode("a", corev1.ConditionFalse))
+	assert.NoError(t, err)
+
+	assert.NoError(t, PollNode(client, alertSpec, defaultTickerTime, alertStub, conf))
+	assert.Equal(t, 3, len(alerts))
+	assert.Equal(t, "node/c was added since last poll (now: Ready=True)", alerts[0].Message)
+	assert.Equal(t, "node/b was removed since last poll (was: Ready=True)", alerts[1].Message)
+	assert.Equal(t, "node/a changed since last poll (before: Ready=True, after: Ready=False)", alerts[2].Message)
+
+	alerts = nil
+	assert.NoError(t, PollNode(client, alertSpec, defaultTickerTime, alertStub, conf))
+	assert.Empty(t, alerts, "unchanged nodes should not alert")
+}
+
+func Test_PollDeployment_ReportDiff(t *testing.T) {
+	stateStore = state.NewStore()
+	_, conf := StubsInit()
+	alertSpec := DeploymentAlertSpec{
+		Name:         "test-deployment",
+		DepFilter:    metav1.NamespaceDefault,
+		ReportStatus: DeploymentAlertStatus{ReportDiff: true},
+	}
+
+	var alerts []Alert
+	alertStub := func(_ string, _ string, alert Alert, _ AlertersConfig) {
+		alerts = append(alerts, alert)
+	}
+
+	deployment := &appsv1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{Name: "test-deployment", Namespace: metav1.NamespaceDefault},
+		Status:     appsv1.DeploymentStatus{AvailableReplicas: 3, UpdatedReplicas: 3},
+	}
+	client := fake.NewSimpleClientset(deployment)
+	assert.NoError(t, PollDeployment(client, alertSpec, defaultTickerTime, alertStub, conf))
+
+	deployment.Status.AvailableReplicas = 2
+	_, err := client.AppsV1().Deployments(metav1.NamespaceDefault).Update(deployment)
+	assert.NoError(t, err)
+	assert.NoError(t, PollDeployment(client, alertSpec, defaultTickerTime, alertStub, conf))
+
+	assert.Equal(t, 1, len(alerts))
+	assert.Equal(t, "deployment/default/test-deployment/Changed", alerts[0].Key)
+	assert.Contains(t, alerts[0].Message, "before: replicas=1 updated=3 available=3, after: replicas=1 updated=3 available=2")
+}
+
+// readyNode builds a node whose only condition is Ready with the given status
+func readyNode(name string, status corev1.ConditionStatus) *corev1.Node {
+	return &corev1.Node{
+		ObjectMeta: metav1.ObjectMeta{Name: name},
+		Status: corev1.NodeStatus{
+			Conditions: []corev1.NodeCondition{{Type: corev1.NodeReady, Status: status}},
+		},
+	}
+}
@@ -0,0 +1,84 @@
+// Copyright 2019 Bloomberg Finance LP
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package queries
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/bloomberg/k8eraid/pkgs/alerters"
+	"github.com/bloomberg/k8eraid/pkgs/metrics"
+	"github.com/bloomberg/k8eraid/pkgs/state"
+	"github.com/bloomberg/k8eraid/pkgs/types"
+)
+
+var escalatedAlertsCounter = metrics.NewCounterVec(
+	"k8eraid_escalated_alerts_total",
+	"Critical alerts delivered to a level of their rule's escalation chain instead of the rule's alerter.",
+	"level",
+)
+
+// Escalate returns an alerters.Middleware re-routing the unacknowledged critical alerts of a rule up
+// its escalation chain. An alert that has been active for at least a level's afterSeconds goes to
+// the alerter of the highest such level instead of the rule's, and its message says which level it
+// reached. Acknowledging the alert stops its escalation until it resolves.
+func Escalate(levels []types.EscalationLevel) alerters.Middleware {
+	return func(next alerters.Alerter) alerters.Alerter {
+		if len(levels) == 0 {
+			return next
+		}
+		return alerters.AlerterFunc(func(alerterType string, alerterName string, alert types.Alert, alertersConfig types.AlertersConfig) {
+			if alert.Severity != types.SeverityCritical {
+				next.Alert(alerterType, alerterName, alert, alertersConfig)
+				return
+			}
+			active, ok := stateStore.ActiveAlert(alert.Key)
+			if !ok || !active.AcknowledgedAt.IsZero() {
+				next.Alert(alerterType, alerterName, alert, alertersConfig)
+				return
+			}
+			firing := alert.Time.Sub(active.FirstSeen)
+			reached := 0
+			for i, level := range levels {
+				if firing >= time.Duration(level.AfterSeconds)*time.Second {
+					reached = i + 1
+				}
+			}
+			if reached == 0 {
+				next.Alert(alerterType, alerterName, alert, alertersConfig)
+				return
+			}
+			// the rule's own alerter is level 1
+			alert.EscalationLevel = reached + 1
+			alert.Message = fmt.Sprintf("%s (escalation level %d, unacknowledged for %s)", alert.Message, alert.EscalationLevel, firing.Round(time.Second))
+			escalatedAlertsCounter.With(strconv.Itoa(alert.EscalationLevel)).Inc()
+			level := levels[reached-1]
+			next.Alert(level.AlerterType, level.AlerterName, alert, alertersConfig)
+		})
+	}
+}
+
+// AcknowledgeAlert marks the active alert of key as taken on by who, stopping its escalation until it
+// resolves, or as unacknowledged again when who is empty. It returns false when no alert of key is
+// active.
+func AcknowledgeAlert(key string, who string, now time.Time) bool {
+	return stateStore.AcknowledgeAlert(key, who, now)
+}
+
+// ActiveAlerts returns the alerts currently active, with their acknowledgements, sorted by key
+func ActiveAlerts() []state.ActiveAlert {
+	return stateStore.ActiveAlerts()
+}
@@ -0,0 +1,73 @@
+// Copyright 2019 Bloomberg Finance LP
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package queries
+
+import (
+	"testing"
+	"time"
+
+	"github.com/bloomberg/k8eraid/pkgs/alerters"
+	"github.com/bloomberg/k8eraid/pkgs/state"
+	. "github.com/bloomberg/k8eraid/pkgs/types"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_Escalate(t *testing.T) {
+	stateStore = state.NewStore()
+	_, conf := StubsInit()
+	type delivery struct {
+		alerterName string
+		level       int
+		message     string
+	}
+	var deliveries []delivery
+	levels := []EscalationLevel{
+		{AfterSeconds: 600, AlerterType: "stderr", AlerterName: "secondary"},
+		{AfterSeconds: 1800, AlerterType: "stderr", AlerterName: "managers"},
+	}
+	alertFn := alerters.Chain(alerters.AlerterFunc(func(_ string, alerterName string, alert Alert, _ AlertersConfig) {
+		deliveries = append(deliveries, delivery{alerterName, alert.EscalationLevel, alert.Message})
+	}), Escalate(levels), TrackActiveAlerts).Alert
+	start := time.Unix(1000, 0)
+	fire := func(resource string, severity string, after time.Duration) {
+		alert := newAlert(resource, "Ready", severity, "node down!")
+		alert.Time = start.Add(after)
+		alertFn("stderr", "primary", alert, conf)
+	}
+
+	fire("node/a", SeverityCritical, 0)
+	fire("node/a", SeverityCritical, 5*time.Minute)
+	fire("node/a", SeverityCritical, 10*time.Minute)
+	fire("node/a", SeverityCritical, 45*time.Minute)
+	assert.Equal(t, []delivery{
+		{"primary", 0, "node down!"},
+		{"primary", 0, "node down!"},
+		{"secondary", 2, "node down! (escalation level 2, unacknowledged for 10m0s)"},
+		{"managers", 3, "node down! (escalation level 3, unacknowledged for 45m0s)"},
+	}, deliveries)
+
+	deliveries = nil
+	assert.True(t, AcknowledgeAlert("node/a/Ready", "oncall", start.Add(46*time.Minute)))
+	fire("node/a", SeverityCritical, 50*time.Minute)
+	assert.Equal(t, []delivery{{"primary", 0, "node down!"}}, deliveries, "acknowledged alerts should not escalate")
+
+	deliveries = nil
+	fire("pod/ns/b", SeverityWarning, 0)
+	fire("pod/ns/b", SeverityWarning, time.Hour)
+	assert.Equal(t, []delivery{{"primary", 0, "node down!"}, {"primary", 0, "node down!"}}, deliveries, "only critical alerts escalate")
+
+	assert.False(t, AcknowledgeAlert("node/absent/Ready", "oncall", start), "inactive alerts cannot be acknowledged")
+}
@@ -0,0 +1,114 @@
+// Copyright 2019 Bloomberg Finance LP
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package queries
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/bloomberg/k8eraid/pkgs/types"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// etcdMemberPrefix starts the names of the ComponentStatuses of the etcd servers the apiserver uses
+const etcdMemberPrefix = "etcd-"
+
+// CheckEtcd alerts when the etcd health endpoint of the apiserver does not answer ok, or answers slower
+// than the threshold, and when the etcd members the apiserver reports ComponentStatuses for are too
+// few healthy for comfort. Losing one member of three only degrades quorum, losing two loses it.
+func CheckEtcd(
+	clientset kubernetes.Interface,
+	alertSpec types.EtcdAlertSpec,
+	alertFn alertFunction,
+	alertersConfig types.AlertersConfig,
+) {
+	resource := resourceID("etcd", "", "cluster")
+	health, err := apiHealthProbe(clientset, alertSpec.Endpoint)
+	switch {
+	case err != nil:
+		// ALERT
+		alertmessage := fmt.Sprintf("The health of etcd could not be read, apiserver %s could not be reached: %s", alertSpec.Endpoint, err.Error())
+		alertFn(alertSpec.AlerterType, alertSpec.AlerterName, newAlert(resource, "Unknown", types.SeverityWarning, alertmessage), alertersConfig)
+	case health.StatusCode != http.StatusOK:
+		// ALERT
+		alertmessage := fmt.Sprintf(
+			"Apiserver %s reports etcd unhealthy (HTTP %d), the cluster may have lost its datastore! %s",
+			alertSpec.Endpoint, health.StatusCode, failedHealthChecks(health.Body),
+		)
+		alertFn(alertSpec.AlerterType, alertSpec.AlerterName, newAlert(resource, "Unhealthy", types.SeverityCritical, alertmessage), alertersConfig)
+	case alertSpec.ThresholdSeconds > 0 && health.Elapsed.Seconds() > alertSpec.ThresholdSeconds:
+		// ALERT
+		alertmessage := fmt.Sprintf(
+			"Apiserver %s took %.2fs to check etcd, above the %.2fs threshold! etcd may be overloaded or short of disk throughput.",
+			alertSpec.Endpoint, health.Elapsed.Seconds(), alertSpec.ThresholdSeconds,
+		)
+		alertFn(alertSpec.AlerterType, alertSpec.AlerterName, newAlert(resource, "Latency", types.SeverityWarning, alertmessage), alertersConfig)
+	}
+
+	// ComponentStatuses are deprecated, and may be forbidden or gone, so without them only the endpoint is checked
+	list, listerr := clientset.CoreV1().ComponentStatuses().List(metav1.ListOptions{TimeoutSeconds: &timeout})
+	if listerr != nil {
+		return
+	}
+	checkEtcdQuorum(list.Items, alertSpec, alertFn, alertersConfig)
+}
+
+// checkEtcdQuorum alerts on the etcd members among statuses that are unhealthy, critically once fewer
+// than a majority of them are left healthy
+func checkEtcdQuorum(
+	statuses []corev1.ComponentStatus,
+	alertSpec types.EtcdAlertSpec,
+	alertFn alertFunction,
+	alertersConfig types.AlertersConfig,
+) {
+	members := 0
+	var unhealthy []string
+	for i := range statuses {
+		if !strings.HasPrefix(statuses[i].Name, etcdMemberPrefix) {
+			continue
+		}
+		members++
+		if healthy, reason := componentHealthy(&statuses[i]); !healthy {
+			unhealthy = append(unhealthy, fmt.Sprintf("%s (%s)", statuses[i].Name, reason))
+		}
+	}
+	if len(unhealthy) == 0 {
+		return
+	}
+	sort.Strings(unhealthy)
+	resource := resourceID("etcd", "", "cluster")
+	quorum := members/2 + 1
+	healthy := members - len(unhealthy)
+	if healthy < quorum {
+		// ALERT
+		alertmessage := fmt.Sprintf(
+			"%d of %d etcd members are unhealthy, etcd has lost quorum and the cluster cannot be written to! Unhealthy: %s",
+			len(unhealthy), members, strings.Join(unhealthy, ", "),
+		)
+		alertFn(alertSpec.AlerterType, alertSpec.AlerterName, newAlert(resource, "QuorumLost", types.SeverityCritical, alertmessage), alertersConfig)
+		return
+	}
+	// ALERT
+	alertmessage := fmt.Sprintf(
+		"%d of %d etcd members are unhealthy, and etcd loses quorum if %d more fail! Unhealthy: %s",
+		len(unhealthy), members, healthy-quorum+1, strings.Join(unhealthy, ", "),
+	)
+	alertFn(alertSpec.AlerterType, alertSpec.AlerterName, newAlert(resource, "QuorumDegraded", types.SeverityWarning, alertmessage), alertersConfig)
+}
@@ -0,0 +1,82 @@
+// Copyright 2019 Bloomberg Finance LP
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package queries
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	. "github.com/bloomberg/k8eraid/pkgs/types"
+
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func etcdMember(name string, status corev1.ConditionStatus) *corev1.ComponentStatus {
+	condition := corev1.ComponentCondition{Type: corev1.ComponentHealthy, Status: status, Message: `{"health":"true"}`}
+	if status != corev1.ConditionTrue {
+		condition = corev1.ComponentCondition{Type: corev1.ComponentHealthy, Status: status, Error: "context deadline exceeded"}
+	}
+	return &corev1.ComponentStatus{ObjectMeta: metav1.ObjectMeta{Name: name}, Conditions: []corev1.ComponentCondition{condition}}
+}
+
+func Test_CheckEtcd(t *testing.T) {
+	_, conf := StubsInit()
+	original := apiHealthProbe
+	defer func() { apiHealthProbe = original }()
+	apiHealthProbe = func(_ kubernetes.Interface, endpoint string) (apiHealthResult, error) {
+		switch endpoint {
+		case "/readyz/etcd":
+			return apiHealthResult{StatusCode: http.StatusOK, Body: "ok", Elapsed: 1500 * time.Millisecond}, nil
+		case "/healthz/etcd":
+			return apiHealthResult{StatusCode: http.StatusInternalServerError, Body: "[-]etcd failed: reason withheld\nhealthz check failed\n"}, nil
+		}
+		return apiHealthResult{}, fmt.Errorf("connection refused")
+	}
+	check := func(endpoint string, members ...*corev1.ComponentStatus) []string {
+		clientset := fake.NewSimpleClientset(&corev1.ComponentStatus{ObjectMeta: metav1.ObjectMeta{Name: "scheduler"}})
+		for _, member := range members {
+			_, err := clientset.CoreV1().ComponentStatuses().Create(member)
+			assert.NoError(t, err)
+		}
+		alerts := []string{}
+		CheckEtcd(clientset, EtcdAlertSpec{Endpoint: endpoint, ThresholdSeconds: 1}, func(_ string, _ string, alert Alert, _ AlertersConfig) {
+			alerts = append(alerts, alert.Key+": "+alert.Message)
+		}, conf)
+		return alerts
+	}
+
+	assert.Equal(t, []string{
+		"etcd/cluster/Latency: Apiserver /readyz/etcd took 1.50s to check etcd, above the 1.00s threshold! etcd may be overloaded or short of disk throughput.",
+	}, check("/readyz/etcd", etcdMember("etcd-0", corev1.ConditionTrue), etcdMember("etcd-1", corev1.ConditionTrue)))
+
+	assert.Equal(t, []string{
+		"etcd/cluster/Unhealthy: Apiserver /healthz/etcd reports etcd unhealthy (HTTP 500), the cluster may have lost its datastore! Failed checks: etcd.",
+		"etcd/cluster/QuorumDegraded: 1 of 5 etcd members are unhealthy, and etcd loses quorum if 2 more fail! Unhealthy: etcd-3 (context deadline exceeded)",
+	}, check("/healthz/etcd",
+		etcdMember("etcd-0", corev1.ConditionTrue), etcdMember("etcd-1", corev1.ConditionTrue), etcdMember("etcd-2", corev1.ConditionTrue),
+		etcdMember("etcd-3", corev1.ConditionFalse), etcdMember("etcd-4", corev1.ConditionTrue),
+	))
+
+	assert.Equal(t, []string{
+		"etcd/cluster/Unknown: The health of etcd could not be read, apiserver /livez/etcd could not be reached: connection refused",
+		"etcd/cluster/QuorumLost: 2 of 3 etcd members are unhealthy, etcd has lost quorum and the cluster cannot be written to! Unhealthy: etcd-0 (context deadline exceeded), etcd-2 (context deadline exceeded)",
+	}, check("/livez/etcd", etcdMember("etcd-0", corev1.ConditionFalse), etcdMember("etcd-1", corev1.ConditionTrue), etcdMember("etcd-2", corev1.ConditionUnknown)))
+}
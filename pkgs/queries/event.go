@@ -0,0 +1,280 @@
+// Copyright 2019 Bloomberg Finance LP
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package queries
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/bloomberg/k8eraid/pkgs/state"
+	"github.com/bloomberg/k8eraid/pkgs/types"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// PollEvent function takes inputs and lists the Events recorded in the kubernetes cluster, triggering alerts as needed.
+func PollEvent(
+	clientset kubernetes.Interface,
+	alertSpec types.EventAlertSpec,
+	tickertime int64,
+	alertFn alertFunction,
+	alertersConfig types.AlertersConfig,
+) error {
+	if alertSpec.EventFilterType == "" {
+		alertSpec.EventFilterType = corev1.EventTypeWarning
+	}
+	if alertSpec.ReportStatus.Threshold <= 0 {
+		alertSpec.ReportStatus.Threshold = 1
+	}
+
+	// The apiserver only selects on a single reason, other reasons are filtered out below
+	selectors := []string{"type=" + alertSpec.EventFilterType}
+	if alertSpec.EventFilterKind != "" {
+		selectors = append(selectors, "involvedObject.kind="+alertSpec.EventFilterKind)
+	}
+	if alertSpec.Name != "*" {
+		selectors = append(selectors, "involvedObject.name="+alertSpec.Name)
+	}
+	if len(alertSpec.EventFilterReasons) == 1 {
+		selectors = append(selectors, "reason="+alertSpec.EventFilterReasons[0])
+	}
+	list, listerr := clientset.CoreV1().Events(alertSpec.EventFilterNamespace).List(metav1.ListOptions{
+		FieldSelector:  strings.Join(selectors, ","),
+		TimeoutSeconds: &timeout,
+	})
+	if listerr != nil {
+		return &PollErr{
+			Message: fmt.Sprintf("Unable to list Events: %s", listerr.Error()),
+		}
+	}
+
+	var events []corev1.Event
+	for _, event := range list.Items {
+		if eventMatches(&event, alertSpec) {
+			events = append(events, event)
+		}
+	}
+	var owners map[string]corev1.ObjectReference
+	if alertSpec.ReportStatus.GroupByWorkload {
+		var ownerserr error
+		if owners, ownerserr = workloadOwners(clientset, alertSpec.EventFilterNamespace); ownerserr != nil {
+			return ownerserr
+		}
+	}
+	checkEvents(events, owners, alertSpec, time.Duration(tickertime)*time.Second, time.Now(), alertFn, alertersConfig)
+	return nil
+}
+
+// workloadOwners maps the namespace/name of every pod of namespace to the workload that controls it:
+// the Deployment owning its ReplicaSet, or else its ReplicaSet, StatefulSet, DaemonSet or Job. Pods
+// without a controller are left out.
+func workloadOwners(clientset kubernetes.Interface, namespace string) (map[string]corev1.ObjectReference, error) {
+	pods, podserr := clientset.CoreV1().Pods(namespace).List(metav1.ListOptions{TimeoutSeconds: &timeout})
+	if podserr != nil {
+		return nil, &PollErr{
+			Message: fmt.Sprintf("Unable to list pods: %s", podserr.Error()),
+		}
+	}
+	replicaSets, replicasetserr := clientset.AppsV1().ReplicaSets(namespace).List(metav1.ListOptions{TimeoutSeconds: &timeout})
+	if replicasetserr != nil {
+		return nil, &PollErr{
+			Message: fmt.Sprintf("Unable to list ReplicaSets: %s", replicasetserr.Error()),
+		}
+	}
+	deployments := map[string]*metav1.OwnerReference{}
+	for i := range replicaSets.Items {
+		if controller := metav1.GetControllerOf(&replicaSets.Items[i]); controller != nil && controller.Kind == "Deployment" {
+			deployments[replicaSets.Items[i].Namespace+"/"+replicaSets.Items[i].Name] = controller
+		}
+	}
+
+	owners := map[string]corev1.ObjectReference{}
+	for i := range pods.Items {
+		pod := &pods.Items[i]
+		controller := metav1.GetControllerOf(pod)
+		if controller == nil {
+			continue
+		}
+		if deployment, ok := deployments[pod.Namespace+"/"+controller.Name]; ok && controller.Kind == "ReplicaSet" {
+			controller = deployment
+		}
+		owners[pod.Namespace+"/"+pod.Name] = corev1.ObjectReference{Kind: controller.Kind, Namespace: pod.Namespace, Name: controller.Name}
+	}
+	return owners, nil
+}
+
+// eventMatches reports whether event passes the filters of alertSpec
+func eventMatches(event *corev1.Event, alertSpec types.EventAlertSpec) bool {
+	if event.Type != alertSpec.EventFilterType {
+		return false
+	}
+	if alertSpec.EventFilterKind != "" && event.InvolvedObject.Kind != alertSpec.EventFilterKind {
+		return false
+	}
+	if alertSpec.Name != "*" && event.InvolvedObject.Name != alertSpec.Name {
+		return false
+	}
+	if len(alertSpec.EventFilterReasons) == 0 {
+		return true
+	}
+	for _, reason := range alertSpec.EventFilterReasons {
+		if event.Reason == reason {
+			return true
+		}
+	}
+	return false
+}
+
+// eventGroup is the events recorded for one object with one reason. When events are grouped by
+// workload the object is the workload, and members the pods the events were recorded for.
+type eventGroup struct {
+	object      corev1.ObjectReference
+	reason      string
+	occurrences int
+	latest      *corev1.Event
+	members     map[string]bool
+}
+
+// checkEvents counts how often every object had events with the same reason within window before
+// now, and alerts on those that reach the threshold. An event recorded again bumps its count
+// instead of making a new Event, so the count each event had on the previous tick is remembered
+// and only the rise since is counted. Events first seen are counted in full when they were first
+// recorded within window, and once otherwise if they were last recorded within it. The events of
+// pods owners maps are counted for their workload instead.
+func checkEvents(
+	events []corev1.Event,
+	owners map[string]corev1.ObjectReference,
+	alertSpec types.EventAlertSpec,
+	window time.Duration,
+	now time.Time,
+	alertFn alertFunction,
+	alertersConfig types.AlertersConfig,
+) {
+	since := now.Add(-window)
+	groups := map[string]*eventGroup{}
+	key := specKey("event", alertSpec.Name, strings.Join([]string{
+		alertSpec.EventFilterNamespace, alertSpec.EventFilterKind, alertSpec.EventFilterType, strings.Join(alertSpec.EventFilterReasons, ","),
+	}, "|"))
+	stateStore.UpdateSnapshot(key, func(previous state.Snapshot, _ bool) state.Snapshot {
+		current := state.Snapshot{}
+		for i := range events {
+			event := &events[i]
+			count := eventCount(event)
+			id := event.Namespace + "/" + event.Name
+			current[id] = strconv.Itoa(int(count))
+
+			occurrences := 0
+			if seen, err := strconv.Atoi(previous[id]); err == nil {
+				occurrences = int(count) - seen
+			} else if first := eventFirstSeen(event); !first.IsZero() && !first.Before(since) {
+				occurrences = int(count)
+			} else if !eventLastSeen(event).Before(since) {
+				occurrences = 1
+			}
+			if occurrences <= 0 {
+				continue
+			}
+
+			object := event.InvolvedObject
+			member := ""
+			if owner, owned := owners[object.Namespace+"/"+object.Name]; owned && object.Kind == "Pod" {
+				object, member = owner, event.InvolvedObject.Name
+			}
+			groupKey := strings.Join([]string{object.Kind, object.Namespace, object.Name, event.Reason}, "/")
+			group, ok := groups[groupKey]
+			if !ok {
+				group = &eventGroup{object: object, reason: event.Reason, members: map[string]bool{}}
+				groups[groupKey] = group
+			}
+			group.occurrences += occurrences
+			if member != "" {
+				group.members[member] = true
+			}
+			if group.latest == nil || eventLastSeen(group.latest).Before(eventLastSeen(event)) {
+				group.latest = event
+			}
+		}
+		return current
+	})
+
+	groupKeys := make([]string, 0, len(groups))
+	for groupKey := range groups {
+		groupKeys = append(groupKeys, groupKey)
+	}
+	sort.Strings(groupKeys)
+	threshold := alertSpec.ReportStatus.Threshold
+	for _, groupKey := range groupKeys {
+		group := groups[groupKey]
+		if group.occurrences < threshold {
+			continue
+		}
+		across := ""
+		if len(group.members) > 0 {
+			across = fmt.Sprintf(" across %d of its pods", len(group.members))
+		}
+		// ALERT
+		alertmessage := fmt.Sprintf(
+			"%s had %d %s events%s in the last %s, at least %d! The latest said: %s",
+			eventObject(group.object), group.occurrences, group.reason, across, window, threshold, group.latest.Message,
+		)
+		resource := resourceID(strings.ToLower(group.object.Kind), group.object.Namespace, group.object.Name)
+		alert := newAlert(resource, group.reason, types.SeverityWarning, alertmessage)
+		alertFn(alertSpec.AlerterType, alertSpec.AlerterName, alert, alertersConfig)
+	}
+}
+
+// eventCount returns how many times event was recorded. Events of the events.k8s.io API keep the
+// count of a series of repeated events in the series instead.
+func eventCount(event *corev1.Event) int32 {
+	if event.Series != nil {
+		return event.Series.Count
+	}
+	if event.Count > 0 {
+		return event.Count
+	}
+	return 1
+}
+
+// eventFirstSeen returns when event was first recorded
+func eventFirstSeen(event *corev1.Event) time.Time {
+	if !event.FirstTimestamp.IsZero() {
+		return event.FirstTimestamp.Time
+	}
+	return event.EventTime.Time
+}
+
+// eventLastSeen returns when event was last recorded
+func eventLastSeen(event *corev1.Event) time.Time {
+	if event.Series != nil && !event.Series.LastObservedTime.IsZero() {
+		return event.Series.LastObservedTime.Time
+	}
+	if !event.LastTimestamp.IsZero() {
+		return event.LastTimestamp.Time
+	}
+	return eventFirstSeen(event)
+}
+
+// eventObject names the object an event was recorded for
+func eventObject(object corev1.ObjectReference) string {
+	if object.Namespace == "" {
+		return object.Kind + " " + object.Name
+	}
+	return object.Kind + " " + object.Namespace + "/" + object.Name
+}
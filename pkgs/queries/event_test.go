@@ -0,0 +1,174 @@
+// Copyright 2019 Bloomberg Finance LP
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package queries
+
+import (
+	"testing"
+	"time"
+
+	"github.com/bloomberg/k8eraid/pkgs/state"
+	. "github.com/bloomberg/k8eraid/pkgs/types"
+
+	"github.com/stretchr/testify/assert"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func podEvent(name string, pod string, reason string, count int32, first time.Time, last time.Time, message string) corev1.Event {
+	return corev1.Event{
+		ObjectMeta:     metav1.ObjectMeta{Name: name, Namespace: metav1.NamespaceDefault},
+		InvolvedObject: corev1.ObjectReference{Kind: "Pod", Namespace: metav1.NamespaceDefault, Name: pod},
+		Type:           corev1.EventTypeWarning,
+		Reason:         reason,
+		Count:          count,
+		FirstTimestamp: metav1.Time{Time: first},
+		LastTimestamp:  metav1.Time{Time: last},
+		Message:        message,
+	}
+}
+
+func Test_checkEvents(t *testing.T) {
+	stateStore = state.NewStore()
+	_, conf := StubsInit()
+	alertSpec := EventAlertSpec{Name: "*", EventFilterType: corev1.EventTypeWarning, ReportStatus: EventAlertStatus{Threshold: 3}}
+	now := time.Unix(100000, 0)
+	check := func(events []corev1.Event, now time.Time) []string {
+		messages := []string{}
+		checkEvents(events, nil, alertSpec, time.Minute, now, func(_ string, _ string, alert Alert, _ AlertersConfig) {
+			messages = append(messages, alert.Key+": "+alert.Message)
+		}, conf)
+		return messages
+	}
+
+	events := []corev1.Event{
+		podEvent("web-1.a", "web-1", "BackOff", 3, now.Add(-30*time.Second), now, "Back-off restarting failed container"),
+		podEvent("web-1.b", "web-1", "Unhealthy", 2, now.Add(-30*time.Second), now, "Liveness probe failed"),
+		podEvent("web-1.c", "web-1", "Unhealthy", 1, now.Add(-10*time.Second), now.Add(-10*time.Second), "Readiness probe failed"),
+		podEvent("web-2.a", "web-2", "BackOff", 40, now.Add(-time.Hour), now, "Back-off restarting failed container"),
+	}
+	assert.Equal(t, []string{
+		"pod/default/web-1/BackOff: Pod default/web-1 had 3 BackOff events in the last 1m0s, at least 3! The latest said: Back-off restarting failed container",
+		"pod/default/web-1/Unhealthy: Pod default/web-1 had 3 Unhealthy events in the last 1m0s, at least 3! The latest said: Liveness probe failed",
+	}, check(events, now), "events first recorded before the window only count once")
+
+	events[0].Count = 4
+	events[3].Count = 45
+	assert.Equal(t, []string{
+		"pod/default/web-2/BackOff: Pod default/web-2 had 5 BackOff events in the last 1m0s, at least 3! The latest said: Back-off restarting failed container",
+	}, check(events, now.Add(time.Minute)), "only the rise of the counts since the previous tick is counted")
+}
+
+func Test_PollEvent(t *testing.T) {
+	stateStore = state.NewStore()
+	_, conf := StubsInit()
+	now := time.Now()
+	mount := podEvent("web-1.a", "web-1", "FailedMount", 2, now.Add(-10*time.Second), now, "secret \"tls\" not found")
+	normal := podEvent("web-1.b", "web-1", "Pulled", 2, now.Add(-10*time.Second), now, "image pulled")
+	normal.Type = corev1.EventTypeNormal
+	node := podEvent("worker-1.a", "worker-1", "FailedMount", 2, now.Add(-10*time.Second), now, "volume busy")
+	node.InvolvedObject = corev1.ObjectReference{Kind: "Node", Name: "worker-1"}
+	client := fake.NewSimpleClientset(&mount, &normal, &node)
+
+	for _, test := range []struct {
+		spec     EventAlertSpec
+		expected []string
+	}{
+		{
+			spec: EventAlertSpec{Name: "*", ReportStatus: EventAlertStatus{Threshold: 2}},
+			expected: []string{
+				"Node worker-1 had 2 FailedMount events in the last 42s, at least 2! The latest said: volume busy",
+				"Pod default/web-1 had 2 FailedMount events in the last 42s, at least 2! The latest said: secret \"tls\" not found",
+			},
+		},
+		{
+			spec: EventAlertSpec{Name: "*", EventFilterKind: "Pod", EventFilterReasons: []string{"FailedMount", "FailedAttachVolume"}},
+			expected: []string{
+				"Pod default/web-1 had 2 FailedMount events in the last 42s, at least 1! The latest said: secret \"tls\" not found",
+			},
+		},
+		{
+			spec: EventAlertSpec{Name: "web-1", EventFilterType: corev1.EventTypeNormal},
+			expected: []string{
+				"Pod default/web-1 had 2 Pulled events in the last 42s, at least 1! The latest said: image pulled",
+			},
+		},
+		{spec: EventAlertSpec{Name: "*", EventFilterReasons: []string{"BackOff"}}, expected: []string{}},
+		{spec: EventAlertSpec{Name: "*", ReportStatus: EventAlertStatus{Threshold: 3}}, expected: []string{}},
+	} {
+		messages := []string{}
+		err := PollEvent(client, test.spec, defaultTickerTime, func(_ string, _ string, alert Alert, _ AlertersConfig) {
+			messages = append(messages, alert.Message)
+		}, conf)
+		assert.NoError(t, err)
+		assert.Equal(t, test.expected, messages)
+	}
+}
+
+func Test_PollEvent_GroupByWorkload(t *testing.T) {
+	stateStore = state.NewStore()
+	_, conf := StubsInit()
+	now := time.Now()
+	controller := true
+	ownedPod := func(name string, kind string, owner string) *corev1.Pod {
+		return &corev1.Pod{ObjectMeta: metav1.ObjectMeta{
+			Name:            name,
+			Namespace:       metav1.NamespaceDefault,
+			OwnerReferences: []metav1.OwnerReference{{Kind: kind, Name: owner, Controller: &controller}},
+		}}
+	}
+	replicaSet := &appsv1.ReplicaSet{ObjectMeta: metav1.ObjectMeta{
+		Name:            "web-7c9f",
+		Namespace:       metav1.NamespaceDefault,
+		OwnerReferences: []metav1.OwnerReference{{Kind: "Deployment", Name: "web", Controller: &controller}},
+	}}
+	unhealthy := func(name string, pod string, count int32, message string) *corev1.Event {
+		event := podEvent(name, pod, "Unhealthy", count, now.Add(-10*time.Second), now.Add(-time.Duration(count)*time.Second), message)
+		return &event
+	}
+	client := fake.NewSimpleClientset(
+		replicaSet,
+		ownedPod("web-7c9f-a", "ReplicaSet", "web-7c9f"),
+		ownedPod("web-7c9f-b", "ReplicaSet", "web-7c9f"),
+		ownedPod("db-0", "StatefulSet", "db"),
+		&corev1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "debug", Namespace: metav1.NamespaceDefault}},
+		unhealthy("web-7c9f-a.1", "web-7c9f-a", 2, "Readiness probe failed: HTTP probe failed with statuscode: 503"),
+		unhealthy("web-7c9f-b.1", "web-7c9f-b", 1, "Readiness probe failed: connection refused"),
+		unhealthy("db-0.1", "db-0", 1, "Liveness probe failed: timeout"),
+		unhealthy("debug.1", "debug", 3, "Readiness probe failed: not ready"),
+	)
+
+	messages := []string{}
+	err := PollEvent(client, EventAlertSpec{
+		Name:               "*",
+		EventFilterKind:    "Pod",
+		EventFilterReasons: []string{"Unhealthy"},
+		ReportStatus:       EventAlertStatus{Threshold: 3, GroupByWorkload: true},
+	}, defaultTickerTime, func(_ string, _ string, alert Alert, _ AlertersConfig) {
+		messages = append(messages, alert.Key+": "+alert.Message)
+	}, conf)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{
+		"deployment/default/web/Unhealthy: Deployment default/web had 3 Unhealthy events across 2 of its pods in the last 42s, at least 3! The latest said: Readiness probe failed: connection refused",
+		"pod/default/debug/Unhealthy: Pod default/debug had 3 Unhealthy events in the last 42s, at least 3! The latest said: Readiness probe failed: not ready",
+	}, messages, "pods without a controller are counted on their own")
+}
+
+// ownedPod makes replicaSet the controller of pod
+func ownedPod(pod *corev1.Pod, replicaSet *appsv1.ReplicaSet) *corev1.Pod {
+	pod.OwnerReferences = []metav1.OwnerReference{*metav1.NewControllerRef(replicaSet, appsv1.SchemeGroupVersion.WithKind("ReplicaSet"))}
+	return pod
+}
@@ -0,0 +1,275 @@
+// Copyright 2019 Bloomberg Finance LP
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package queries
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/bloomberg/k8eraid/pkgs/types"
+
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/util/jsonpath"
+)
+
+const defaultFieldConditionCheck = "FieldCondition"
+
+// PollFieldCondition function takes inputs and evaluates a field condition against objects of any
+// resource in the kubernetes cluster, custom resources included, triggering alerts as needed.
+func PollFieldCondition(
+	client dynamic.Interface,
+	alertSpec types.FieldConditionAlertSpec,
+	tickertime int64,
+	alertFn alertFunction,
+	alertersConfig types.AlertersConfig,
+) error {
+	gvr := schema.GroupVersionResource{Group: alertSpec.Group, Version: alertSpec.Version, Resource: alertSpec.Resource}
+	kind := gvr.GroupResource().String()
+	var resourceClient dynamic.ResourceInterface = client.Resource(gvr)
+	if alertSpec.FieldFilterNamespace != "" {
+		resourceClient = client.Resource(gvr).Namespace(alertSpec.FieldFilterNamespace)
+	}
+
+	var objects []unstructured.Unstructured
+	if alertSpec.Name == "*" {
+		list, listerr := resourceClient.List(metav1.ListOptions{
+			LabelSelector:  alertSpec.FieldFilterLabel,
+			TimeoutSeconds: &timeout,
+		})
+		if listerr != nil {
+			return &PollErr{
+				Message: fmt.Sprintf("Unable to list %s: %s", kind, listerr.Error()),
+			}
+		}
+		objects = list.Items
+	} else {
+		object, geterr := resourceClient.Get(alertSpec.Name, metav1.GetOptions{})
+		if geterr != nil {
+			return &PollErr{
+				Message: fmt.Sprintf("Unable to get %s %s: %s", kind, alertSpec.Name, geterr.Error()),
+			}
+		}
+		objects = append(objects, *object)
+	}
+
+	condition, err := newFieldCondition(alertSpec.Condition)
+	if err != nil {
+		return err
+	}
+	check := alertSpec.Check
+	if check == "" {
+		check = defaultFieldConditionCheck
+	}
+	severity := alertSpec.Severity
+	if severity == "" {
+		severity = types.SeverityWarning
+	}
+
+	// an object the condition cannot be evaluated on should not hide the others
+	objectErrs := newObjectErrors(kind, types.OnObjectErrorContinue)
+	for _, object := range objects {
+		name := object.GetName()
+		if object.GetNamespace() != "" {
+			name = object.GetNamespace() + "/" + name
+		}
+		matched, got, want, matcherr := condition.matches(object)
+		if matcherr != nil {
+			objectErrs.handle(&PollErr{
+				Message: fmt.Sprintf("Unable to evaluate the condition on %s %s: %s", kind, name, matcherr.Error()),
+			})
+			continue
+		}
+		if !matched {
+			continue
+		}
+		// ALERT
+		alertmessage := fmt.Sprintf("%s %s matches %s (%s %s %s)!", kind, name, condition, got, condition.operator, want)
+		alert := newAlert(resourceID(kind, object.GetNamespace(), object.GetName()), check, severity, alertmessage)
+		alertFn(alertSpec.AlerterType, alertSpec.AlerterName, alert, alertersConfig)
+	}
+	return objectErrs.err()
+}
+
+// fieldCondition is a types.FieldCondition with its JSONPath expressions parsed
+type fieldCondition struct {
+	field    *jsonpath.JSONPath
+	compare  *jsonpath.JSONPath
+	operator string
+	spec     types.FieldCondition
+}
+
+func newFieldCondition(spec types.FieldCondition) (*fieldCondition, error) {
+	condition := &fieldCondition{operator: spec.Operator, spec: spec}
+	var err error
+	if condition.field, err = types.ParseJSONPath(spec.JSONPath); err != nil {
+		return nil, &PollErr{
+			Message: fmt.Sprintf("Unable to parse jsonPath %q: %s", spec.JSONPath, err.Error()),
+		}
+	}
+	if spec.CompareJSONPath != "" {
+		if condition.compare, err = types.ParseJSONPath(spec.CompareJSONPath); err != nil {
+			return nil, &PollErr{
+				Message: fmt.Sprintf("Unable to parse compareJsonPath %q: %s", spec.CompareJSONPath, err.Error()),
+			}
+		}
+	}
+	return condition, nil
+}
+
+// String describes the condition for alert messages, such as {.spec.replicas} != {.status.readyReplicas}
+func (c *fieldCondition) String() string {
+	want := strconv.Quote(c.spec.Value)
+	if c.compare != nil {
+		want = types.NormalizeJSONPath(c.spec.CompareJSONPath)
+	}
+	return fmt.Sprintf("%s %s %s", types.NormalizeJSONPath(c.spec.JSONPath), c.operator, want)
+}
+
+// matches reports whether any of the values the field expression selects from object satisfies
+// the condition, and the values compared when one does. An object missing a field matches nothing
+// unless the condition has a MissingValue.
+func (c *fieldCondition) matches(object unstructured.Unstructured) (bool, string, string, error) {
+	values, err := c.values(c.field, object)
+	if err != nil || len(values) == 0 {
+		return false, "", "", err
+	}
+	var want interface{} = c.spec.Value
+	if c.compare != nil {
+		compared, err := c.values(c.compare, object)
+		if err != nil || len(compared) == 0 {
+			return false, "", "", err
+		}
+		if len(compared) > 1 {
+			return false, "", "", fmt.Errorf("compareJsonPath %s selects %d values, expected one", c.spec.CompareJSONPath, len(compared))
+		}
+		want = compared[0]
+	}
+	for _, value := range values {
+		matched, err := compareValues(value, c.operator, want)
+		if err != nil {
+			return false, "", "", err
+		}
+		if matched {
+			return true, fieldText(value), fieldText(want), nil
+		}
+	}
+	return false, "", "", nil
+}
+
+// values evaluates parser against object. A field that is missing or null yields the condition's
+// MissingValue, if it has one.
+func (c *fieldCondition) values(parser *jsonpath.JSONPath, object unstructured.Unstructured) ([]interface{}, error) {
+	results, err := parser.FindResults(object.UnstructuredContent())
+	if err != nil {
+		return nil, err
+	}
+	var values []interface{}
+	for _, result := range results {
+		for _, value := range result {
+			for value.Kind() == reflect.Interface && !value.IsNil() {
+				value = value.Elem()
+			}
+			if !value.IsValid() || (value.Kind() == reflect.Interface && value.IsNil()) {
+				continue
+			}
+			values = append(values, value.Interface())
+		}
+	}
+	if len(values) == 0 && c.spec.MissingValue != nil {
+		values = append(values, *c.spec.MissingValue)
+	}
+	return values, nil
+}
+
+// compareValues compares as numbers when both sides read as numbers or quantities, and as text
+// otherwise. Text only compares with == and !=.
+func compareValues(got interface{}, operator string, want interface{}) (bool, error) {
+	gotNumber, gotOk := fieldNumber(got)
+	wantNumber, wantOk := fieldNumber(want)
+	if gotOk && wantOk {
+		switch operator {
+		case types.OperatorEqual:
+			return gotNumber == wantNumber, nil
+		case types.OperatorNotEqual:
+			return gotNumber != wantNumber, nil
+		case types.OperatorLess:
+			return gotNumber < wantNumber, nil
+		case types.OperatorLessOrEqual:
+			return gotNumber <= wantNumber, nil
+		case types.OperatorGreater:
+			return gotNumber > wantNumber, nil
+		case types.OperatorGreaterOrEqual:
+			return gotNumber >= wantNumber, nil
+		}
+		return false, fmt.Errorf("unknown operator %q", operator)
+	}
+	switch operator {
+	case types.OperatorEqual:
+		return fieldText(got) == fieldText(want), nil
+	case types.OperatorNotEqual:
+		return fieldText(got) != fieldText(want), nil
+	}
+	return false, fmt.Errorf("%q %s %q needs numbers on both sides", fieldText(got), operator, fieldText(want))
+}
+
+// fieldNumber reads numbers, numeric strings and quantities such as 500m or 2Gi as a float64
+func fieldNumber(value interface{}) (float64, bool) {
+	switch v := value.(type) {
+	case int64:
+		return float64(v), true
+	case int32:
+		return float64(v), true
+	case int:
+		return float64(v), true
+	case float64:
+		return v, true
+	case float32:
+		return float64(v), true
+	case string:
+		text := strings.TrimSpace(v)
+		if number, err := strconv.ParseFloat(text, 64); err == nil {
+			return number, true
+		}
+		quantity, err := resource.ParseQuantity(text)
+		if err != nil {
+			return 0, false
+		}
+		number, err := strconv.ParseFloat(quantity.AsDec().String(), 64)
+		return number, err == nil
+	}
+	return 0, false
+}
+
+// fieldText renders a value the way it reads in the object, with maps and lists as JSON
+func fieldText(value interface{}) string {
+	switch v := value.(type) {
+	case string:
+		return v
+	case map[string]interface{}, []interface{}:
+		data, err := json.Marshal(v)
+		if err != nil {
+			return fmt.Sprint(v)
+		}
+		return string(data)
+	}
+	return fmt.Sprint(value)
+}
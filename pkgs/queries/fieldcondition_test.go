@@ -0,0 +1,126 @@
+// Copyright 2019 Bloomberg Finance LP
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package queries
+
+import (
+	"testing"
+
+	. "github.com/bloomberg/k8eraid/pkgs/types"
+
+	"github.com/stretchr/testify/assert"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+)
+
+func fieldDeployment(name string, replicas int64, status map[string]interface{}) *unstructured.Unstructured {
+	return &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "apps/v1",
+		"kind":       "Deployment",
+		"metadata":   map[string]interface{}{"name": name, "namespace": "default"},
+		"spec":       map[string]interface{}{"replicas": replicas},
+		"status":     status,
+	}}
+}
+
+func Test_PollFieldCondition(t *testing.T) {
+	missing := "0"
+	client := dynamicfake.NewSimpleDynamicClient(runtime.NewScheme(),
+		fieldDeployment("ready", 3, map[string]interface{}{"readyReplicas": int64(3)}),
+		fieldDeployment("degraded", 3, map[string]interface{}{"readyReplicas": int64(1)}),
+		fieldDeployment("down", 2, map[string]interface{}{}),
+	)
+	tests := []struct {
+		name      string
+		condition FieldCondition
+		expected  []string
+	}{
+		{
+			name:      "field against field",
+			condition: FieldCondition{JSONPath: "spec.replicas", Operator: OperatorNotEqual, CompareJSONPath: "status.readyReplicas"},
+			expected:  []string{"deployments.apps default/degraded matches {.spec.replicas} != {.status.readyReplicas} (3 != 1)!"},
+		},
+		{
+			name:      "missing field stands in",
+			condition: FieldCondition{JSONPath: "spec.replicas", Operator: OperatorNotEqual, CompareJSONPath: "status.readyReplicas", MissingValue: &missing},
+			expected: []string{
+				"deployments.apps default/degraded matches {.spec.replicas} != {.status.readyReplicas} (3 != 1)!",
+				"deployments.apps default/down matches {.spec.replicas} != {.status.readyReplicas} (2 != 0)!",
+			},
+		},
+		{
+			name:      "number against literal",
+			condition: FieldCondition{JSONPath: "{.status.readyReplicas}", Operator: OperatorLess, Value: "2"},
+			expected:  []string{`deployments.apps default/degraded matches {.status.readyReplicas} < "2" (1 < 2)!`},
+		},
+		{
+			name:      "text against literal",
+			condition: FieldCondition{JSONPath: "metadata.name", Operator: OperatorEqual, Value: "ready"},
+			expected:  []string{`deployments.apps default/ready matches {.metadata.name} == "ready" (ready == ready)!`},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(subT *testing.T) {
+			var messages []string
+			alertFn := func(_ string, _ string, alert Alert, _ AlertersConfig) {
+				assert.Equal(subT, SeverityWarning, alert.Severity)
+				messages = append(messages, alert.Message)
+			}
+			spec := FieldConditionAlertSpec{Name: "*", Group: "apps", Version: "v1", Resource: "deployments", FieldFilterNamespace: "default", Condition: test.condition}
+			assert.NoError(subT, PollFieldCondition(client, spec, defaultTickerTime, alertFn, AlertersConfig{}))
+			assert.ElementsMatch(subT, test.expected, messages)
+		})
+	}
+
+	var keys []string
+	alertFn := func(_ string, _ string, alert Alert, _ AlertersConfig) {
+		keys = append(keys, alert.Key)
+	}
+	spec := FieldConditionAlertSpec{
+		Name: "degraded", Group: "apps", Version: "v1", Resource: "deployments", FieldFilterNamespace: "default",
+		Check: "Replicas", Condition: FieldCondition{JSONPath: "metadata.name", Operator: OperatorGreater, Value: "a"},
+	}
+	assert.Error(t, PollFieldCondition(client, spec, defaultTickerTime, alertFn, AlertersConfig{}), "ordering text should not evaluate")
+	spec.Condition = FieldCondition{JSONPath: "status.readyReplicas", Operator: OperatorLessOrEqual, Value: "1"}
+	assert.NoError(t, PollFieldCondition(client, spec, defaultTickerTime, alertFn, AlertersConfig{}))
+	assert.Equal(t, []string{"deployments.apps/default/degraded/Replicas"}, keys)
+
+	spec.Name = "absent"
+	assert.Error(t, PollFieldCondition(client, spec, defaultTickerTime, alertFn, AlertersConfig{}))
+}
+
+func Test_compareValues(t *testing.T) {
+	tests := []struct {
+		got      interface{}
+		operator string
+		want     interface{}
+		expected bool
+	}{
+		{got: int64(3), operator: OperatorEqual, want: "3", expected: true},
+		{got: "500m", operator: OperatorLess, want: "1", expected: true},
+		{got: "2Gi", operator: OperatorGreaterOrEqual, want: "2048Mi", expected: true},
+		{got: 1.5, operator: OperatorGreater, want: int64(1), expected: true},
+		{got: true, operator: OperatorEqual, want: "true", expected: true},
+		{got: "True", operator: OperatorNotEqual, want: "False", expected: true},
+		{got: map[string]interface{}{"a": int64(1)}, operator: OperatorEqual, want: `{"a":1}`, expected: true},
+	}
+	for _, test := range tests {
+		matched, err := compareValues(test.got, test.operator, test.want)
+		assert.NoError(t, err)
+		assert.Equal(t, test.expected, matched, "%v %s %v", test.got, test.operator, test.want)
+	}
+	_, err := compareValues("abc", OperatorLess, "def")
+	assert.Error(t, err)
+}
@@ -0,0 +1,240 @@
+// Copyright 2019 Bloomberg Finance LP
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package queries
+
+import (
+	"bytes"
+	"crypto/tls"
+	"encoding/binary"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/bloomberg/k8eraid/pkgs/types"
+
+	"golang.org/x/net/http2"
+	corev1 "k8s.io/api/core/v1"
+)
+
+const defaultGRPCHealthTimeout = 5
+
+// grpcHealthStatus is the ServingStatus of a grpc.health.v1.HealthCheckResponse
+type grpcHealthStatus int
+
+const (
+	grpcHealthUnknown grpcHealthStatus = iota
+	grpcHealthServing
+	grpcHealthNotServing
+	grpcHealthServiceUnknown
+)
+
+func (s grpcHealthStatus) String() string {
+	switch s {
+	case grpcHealthUnknown:
+		return "UNKNOWN"
+	case grpcHealthServing:
+		return "SERVING"
+	case grpcHealthNotServing:
+		return "NOT_SERVING"
+	case grpcHealthServiceUnknown:
+		return "SERVICE_UNKNOWN"
+	}
+	return fmt.Sprintf("ServingStatus(%d)", int(s))
+}
+
+// grpcHealthProbe calls grpc.health.v1.Health/Check for service at address, in plaintext. An error
+// means no status could be read. It is a variable so tests can stub the network out.
+var grpcHealthProbe = func(address string, service string, timeout time.Duration) (grpcHealthStatus, error) {
+	transport := &http2.Transport{
+		// gRPC without TLS is HTTP/2 with prior knowledge, which AllowHTTP dials through DialTLS
+		AllowHTTP: true,
+		DialTLS: func(network string, addr string, _ *tls.Config) (net.Conn, error) {
+			return net.DialTimeout(network, addr, timeout)
+		},
+	}
+	defer transport.CloseIdleConnections()
+	client := &http.Client{Transport: transport, Timeout: timeout}
+
+	request, err := http.NewRequest(http.MethodPost, "http://"+address+"/grpc.health.v1.Health/Check", bytes.NewReader(grpcFrame(encodeHealthCheckRequest(service))))
+	if err != nil {
+		return grpcHealthUnknown, err
+	}
+	request.Header.Set("Content-Type", "application/grpc")
+	request.Header.Set("TE", "trailers")
+	response, err := client.Do(request)
+	if err != nil {
+		return grpcHealthUnknown, err
+	}
+	defer response.Body.Close()
+	body, err := ioutil.ReadAll(response.Body)
+	if err != nil {
+		return grpcHealthUnknown, err
+	}
+	if response.StatusCode != http.StatusOK {
+		return grpcHealthUnknown, fmt.Errorf("HTTP Status Code: %d", response.StatusCode)
+	}
+
+	// a call failing before any message carries its status in the headers instead of the trailers
+	code := response.Trailer.Get("Grpc-Status")
+	if code == "" {
+		code = response.Header.Get("Grpc-Status")
+	}
+	switch code {
+	case "0":
+	case "5":
+		// NOT_FOUND is how the reference health servers answer about a service they do not know
+		return grpcHealthServiceUnknown, nil
+	case "12":
+		return grpcHealthUnknown, fmt.Errorf("the server does not implement grpc.health.v1.Health")
+	default:
+		return grpcHealthUnknown, fmt.Errorf("grpc-status %s: %s", code, response.Trailer.Get("Grpc-Message"))
+	}
+	message, err := grpcMessage(body)
+	if err != nil {
+		return grpcHealthUnknown, err
+	}
+	return decodeHealthCheckResponse(message)
+}
+
+// checkPodGRPCHealth probes the gRPC health service on the IP of a running pod
+func checkPodGRPCHealth(
+	pod *corev1.Pod,
+	alertSpec types.PodAlertSpec,
+	alertFn alertFunction,
+	alertersConfig types.AlertersConfig,
+) {
+	check := alertSpec.ReportStatus.GRPCHealth
+	if check.Port <= 0 || check.Address != "" || pod.Status.Phase != corev1.PodRunning || pod.Status.PodIP == "" {
+		return
+	}
+	address := net.JoinHostPort(pod.Status.PodIP, fmt.Sprint(check.Port))
+	target := fmt.Sprintf("pod %s/%s (%s)", pod.Namespace, pod.Name, address)
+	checkGRPCHealth(resourceID("pod", pod.Namespace, pod.Name), target, address, alertSpec, alertFn, alertersConfig)
+}
+
+// checkGRPCHealth alerts when the health service at address, described as target, answers anything
+// but SERVING, and separately when it cannot be asked at all
+func checkGRPCHealth(
+	resource string,
+	target string,
+	address string,
+	alertSpec types.PodAlertSpec,
+	alertFn alertFunction,
+	alertersConfig types.AlertersConfig,
+) {
+	check := alertSpec.ReportStatus.GRPCHealth
+	if check.TimeoutSeconds <= 0 {
+		check.TimeoutSeconds = defaultGRPCHealthTimeout
+	}
+	service := "the server"
+	if check.Service != "" {
+		service = fmt.Sprintf("service %q", check.Service)
+	}
+
+	status, err := grpcHealthProbe(address, check.Service, time.Duration(check.TimeoutSeconds)*time.Second)
+	if err != nil {
+		// ALERT
+		alertmessage := fmt.Sprintf("gRPC health check of %s at %s failed, its health is unknown: %s", service, target, err.Error())
+		alertFn(alertSpec.AlerterType, alertSpec.AlerterName, newAlert(resource, "GRPCUnreachable", types.SeverityWarning, alertmessage), alertersConfig)
+		return
+	}
+	if status != grpcHealthServing {
+		// ALERT
+		alertmessage := fmt.Sprintf("gRPC health check of %s at %s returned %s!", service, target, status)
+		alertFn(alertSpec.AlerterType, alertSpec.AlerterName, newAlert(resource, "GRPCNotServing", types.SeverityCritical, alertmessage), alertersConfig)
+	}
+}
+
+// encodeHealthCheckRequest marshals a grpc.health.v1.HealthCheckRequest, whose only field is
+// service = 1
+func encodeHealthCheckRequest(service string) []byte {
+	if service == "" {
+		return nil
+	}
+	message := []byte{1<<3 | 2}
+	message = appendVarint(message, uint64(len(service)))
+	return append(message, service...)
+}
+
+// decodeHealthCheckResponse unmarshals the status = 1 field of a grpc.health.v1.HealthCheckResponse,
+// skipping unknown fields
+func decodeHealthCheckResponse(message []byte) (grpcHealthStatus, error) {
+	status := grpcHealthUnknown
+	for len(message) > 0 {
+		tag, n := binary.Uvarint(message)
+		if n <= 0 {
+			return grpcHealthUnknown, fmt.Errorf("malformed health check response")
+		}
+		message = message[n:]
+		switch tag & 7 {
+		case 0:
+			value, n := binary.Uvarint(message)
+			if n <= 0 {
+				return grpcHealthUnknown, fmt.Errorf("malformed health check response")
+			}
+			message = message[n:]
+			if tag>>3 == 1 {
+				status = grpcHealthStatus(value)
+			}
+		case 1, 5:
+			size := 8
+			if tag&7 == 5 {
+				size = 4
+			}
+			if len(message) < size {
+				return grpcHealthUnknown, fmt.Errorf("malformed health check response")
+			}
+			message = message[size:]
+		case 2:
+			length, n := binary.Uvarint(message)
+			if n <= 0 || uint64(len(message)-n) < length {
+				return grpcHealthUnknown, fmt.Errorf("malformed health check response")
+			}
+			message = message[n+int(length):]
+		default:
+			return grpcHealthUnknown, fmt.Errorf("malformed health check response")
+		}
+	}
+	return status, nil
+}
+
+// grpcFrame prefixes an uncompressed message with its gRPC length prefix
+func grpcFrame(message []byte) []byte {
+	frame := make([]byte, 5, 5+len(message))
+	binary.BigEndian.PutUint32(frame[1:], uint32(len(message)))
+	return append(frame, message...)
+}
+
+// grpcMessage returns the first message of a gRPC response body
+func grpcMessage(body []byte) ([]byte, error) {
+	if len(body) < 5 {
+		return nil, fmt.Errorf("health check response has no message")
+	}
+	if body[0] != 0 {
+		return nil, fmt.Errorf("health check response is compressed")
+	}
+	length := binary.BigEndian.Uint32(body[1:5])
+	if uint64(len(body)-5) < uint64(length) {
+		return nil, fmt.Errorf("health check response is truncated")
+	}
+	return body[5 : 5+length], nil
+}
+
+func appendVarint(b []byte, v uint64) []byte {
+	var buf [binary.MaxVarintLen64]byte
+	return append(b, buf[:binary.PutUvarint(buf[:], v)]...)
+}
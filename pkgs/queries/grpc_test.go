@@ -0,0 +1,177 @@
+// Copyright 2019 Bloomberg Finance LP
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package queries
+
+import (
+	"errors"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"testing"
+	"time"
+
+	. "github.com/bloomberg/k8eraid/pkgs/types"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/net/http2"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+// serveGRPCHealth serves grpc.health.v1.Health/Check over plaintext HTTP/2, answering with the
+// status of the requested service or NOT_FOUND when it has none
+func serveGRPCHealth(t *testing.T, statuses map[string]grpcHealthStatus) (string, func()) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		message, _ := grpcMessage(body)
+		service := ""
+		if len(message) > 2 {
+			service = string(message[2:])
+		}
+		w.Header().Set("Content-Type", "application/grpc")
+		status, ok := statuses[service]
+		if !ok {
+			w.Header().Set("Grpc-Status", "5")
+			w.WriteHeader(http.StatusOK)
+			return
+		}
+		w.Header().Set("Trailer", "Grpc-Status")
+		w.WriteHeader(http.StatusOK)
+		w.Write(grpcFrame([]byte{1 << 3, byte(status)}))
+		w.Header().Set("Grpc-Status", "0")
+	})
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go (&http2.Server{}).ServeConn(conn, &http2.ServeConnOpts{Handler: handler})
+		}
+	}()
+	return listener.Addr().String(), func() { listener.Close() }
+}
+
+func Test_grpcHealthProbe(t *testing.T) {
+	address, stop := serveGRPCHealth(t, map[string]grpcHealthStatus{
+		"":        grpcHealthServing,
+		"payment": grpcHealthNotServing,
+	})
+	defer stop()
+
+	status, err := grpcHealthProbe(address, "", time.Second)
+	assert.NoError(t, err)
+	assert.Equal(t, grpcHealthServing, status)
+	status, err = grpcHealthProbe(address, "payment", time.Second)
+	assert.NoError(t, err)
+	assert.Equal(t, grpcHealthNotServing, status)
+	status, err = grpcHealthProbe(address, "unknown", time.Second)
+	assert.NoError(t, err)
+	assert.Equal(t, grpcHealthServiceUnknown, status)
+
+	stop()
+	_, err = grpcHealthProbe(address, "", time.Second)
+	assert.Error(t, err, "a closed port should fail the probe rather than report a status")
+}
+
+func Test_decodeHealthCheckResponse(t *testing.T) {
+	// unknown fields of every wire type around the status
+	status, err := decodeHealthCheckResponse([]byte{2<<3 | 2, 1, 'x', 1 << 3, 2, 3<<3 | 5, 0, 0, 0, 0})
+	assert.NoError(t, err)
+	assert.Equal(t, grpcHealthNotServing, status)
+
+	status, err = decodeHealthCheckResponse(nil)
+	assert.NoError(t, err)
+	assert.Equal(t, grpcHealthUnknown, status, "an empty message is the default status")
+
+	_, err = decodeHealthCheckResponse([]byte{2<<3 | 2, 5, 'x'})
+	assert.Error(t, err)
+}
+
+func Test_PollPod_GRPCHealth(t *testing.T) {
+	_, conf := StubsInit()
+	running := func(name string, ip string) *corev1.Pod {
+		return &corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: metav1.NamespaceDefault, Labels: map[string]string{"app": "api"}},
+			Status:     corev1.PodStatus{Phase: corev1.PodRunning, PodIP: ip},
+		}
+	}
+	pending := running("api-pending", "")
+	pending.Status.Phase = corev1.PodPending
+	client := fake.NewSimpleClientset(running("api-0", "10.0.0.1"), running("api-1", "10.0.0.2"), running("api-2", "10.0.0.3"), pending)
+
+	original := grpcHealthProbe
+	defer func() { grpcHealthProbe = original }()
+	var probed []string
+	grpcHealthProbe = func(address string, service string, _ time.Duration) (grpcHealthStatus, error) {
+		probed = append(probed, address+"/"+service)
+		switch address {
+		case "10.0.0.2:50051":
+			return grpcHealthNotServing, nil
+		case "10.0.0.3:50051", "api.default:50051":
+			return grpcHealthUnknown, errors.New("connection refused")
+		}
+		return grpcHealthServing, nil
+	}
+
+	tests := []struct {
+		name     string
+		check    GRPCHealthCheck
+		probed   []string
+		expected []string
+	}{
+		{
+			name:   "every running pod",
+			check:  GRPCHealthCheck{Port: 50051, Service: "api"},
+			probed: []string{"10.0.0.1:50051/api", "10.0.0.2:50051/api", "10.0.0.3:50051/api"},
+			expected: []string{
+				"pod/default/api-1/GRPCNotServing: gRPC health check of service \"api\" at pod default/api-1 (10.0.0.2:50051) returned NOT_SERVING!",
+				"pod/default/api-2/GRPCUnreachable: gRPC health check of service \"api\" at pod default/api-2 (10.0.0.3:50051) failed, its health is unknown: connection refused",
+			},
+		},
+		{
+			name:   "address",
+			check:  GRPCHealthCheck{Port: 50051, Address: "api.default:50051"},
+			probed: []string{"api.default:50051/"},
+			expected: []string{
+				"grpc/api.default:50051/GRPCUnreachable: gRPC health check of the server at api.default:50051 failed, its health is unknown: connection refused",
+			},
+		},
+		{
+			name: "disabled",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(subT *testing.T) {
+			probed = nil
+			var alerts []string
+			alertStub := func(_ string, _ string, alert Alert, _ AlertersConfig) {
+				alerts = append(alerts, alert.Key+": "+alert.Message)
+			}
+			alertSpec := PodAlertSpec{
+				Name:           "*",
+				PodFilterLabel: "app=api",
+				ReportStatus:   PodAlertStatus{GRPCHealth: test.check},
+			}
+			assert.NoError(subT, PollPod(client, alertSpec, defaultTickerTime, alertStub, conf))
+			assert.ElementsMatch(subT, test.probed, probed)
+			assert.ElementsMatch(subT, test.expected, alerts)
+		})
+	}
+}
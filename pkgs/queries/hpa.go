@@ -0,0 +1,146 @@
+// Copyright 2019 Bloomberg Finance LP
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package queries
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/bloomberg/k8eraid/pkgs/types"
+
+	autoscalingv2beta2 "k8s.io/api/autoscaling/v2beta2"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// hpaTooManyReplicasReason is the reason of the ScalingLimited condition of autoscalers capped at their maxReplicas
+const hpaTooManyReplicasReason = "TooManyReplicas"
+
+// PollHPA function takes inputs and iterates across HorizontalPodAutoscalers in the kubernetes cluster, triggering alerts as needed.
+// Autoscalers are read from autoscaling/v2beta2, the first version with their conditions.
+func PollHPA(
+	clientset kubernetes.Interface,
+	alertSpec types.HPAAlertSpec,
+	tickertime int64,
+	alertFn alertFunction,
+	alertersConfig types.AlertersConfig,
+) error {
+	var hpas []autoscalingv2beta2.HorizontalPodAutoscaler
+
+	// Check rules with matching literal autoscaler name
+	if alertSpec.Name != "*" {
+		if alertSpec.HPAFilterNamespace == "" {
+			return &PollErr{
+				Message: fmt.Sprintf("HorizontalPodAutoscaler rule for %s has no namespace filter specified, ignoring", alertSpec.Name),
+			}
+		}
+		hpa, hpaerr := clientset.AutoscalingV2beta2().HorizontalPodAutoscalers(alertSpec.HPAFilterNamespace).Get(alertSpec.Name, metav1.GetOptions{})
+		if hpaerr != nil {
+			return &PollErr{
+				Message: fmt.Sprintf("Error fetching HorizontalPodAutoscaler %s: %s", alertSpec.Name, hpaerr.Error()),
+			}
+		}
+		hpas = append(hpas, *hpa)
+		// If the autoscaler name is a wildcard, list based on filter and iterate through
+	} else {
+		list, listerr := clientset.AutoscalingV2beta2().HorizontalPodAutoscalers(alertSpec.HPAFilterNamespace).List(metav1.ListOptions{
+			LabelSelector:  alertSpec.HPAFilterLabel,
+			TimeoutSeconds: &timeout,
+		})
+		if listerr != nil {
+			return &PollErr{
+				Message: fmt.Sprintf("Unable to list HorizontalPodAutoscalers: %s", listerr.Error()),
+			}
+		}
+		hpas = list.Items
+	}
+
+	now := time.Now()
+	for i := range hpas {
+		checkHPA(&hpas[i], alertSpec, now, alertFn, alertersConfig)
+	}
+	return nil
+}
+
+func checkHPA(
+	hpa *autoscalingv2beta2.HorizontalPodAutoscaler,
+	alertSpec types.HPAAlertSpec,
+	now time.Time,
+	alertFn alertFunction,
+	alertersConfig types.AlertersConfig,
+) {
+	resource := resourceID("horizontalpodautoscaler", hpa.Namespace, hpa.Name)
+	target := hpa.Spec.ScaleTargetRef.Kind + "/" + hpa.Spec.ScaleTargetRef.Name
+
+	// An autoscaler at its maximum has been there since it last scaled, unless it never scaled
+	pinned := false
+	if threshold := alertSpec.ReportStatus.MaxReplicasThreshold; threshold > 0 && hpa.Status.CurrentReplicas >= hpa.Spec.MaxReplicas {
+		since := hpa.CreationTimestamp.Time
+		if hpa.Status.LastScaleTime != nil {
+			since = hpa.Status.LastScaleTime.Time
+		}
+		if atMaxFor := now.Sub(since); atMaxFor > time.Duration(threshold)*time.Second {
+			pinned = true
+			// ALERT
+			alertmessage := fmt.Sprintf(
+				"HorizontalPodAutoscaler %s/%s has been at its maximum of %d replicas for %s, longer than %ds! %s may need more capacity than it can scale to.",
+				hpa.Namespace, hpa.Name, hpa.Spec.MaxReplicas, formatLongDuration(atMaxFor), threshold, target,
+			)
+			alert := newAlert(resource, "MaxReplicas", types.SeverityCritical, alertmessage)
+			alertFn(alertSpec.AlerterType, alertSpec.AlerterName, alert, alertersConfig)
+		}
+	}
+
+	// Being capped is part of having been at the maximum for too long, so it is not reported twice
+	limited := hpaCondition(hpa, autoscalingv2beta2.ScalingLimited)
+	if alertSpec.ReportStatus.ScalingLimited && !pinned && limited != nil && limited.Status == corev1.ConditionTrue && limited.Reason == hpaTooManyReplicasReason {
+		// ALERT
+		alertmessage := fmt.Sprintf(
+			"HorizontalPodAutoscaler %s/%s wants more replicas of %s than its maximum of %d! %s",
+			hpa.Namespace, hpa.Name, target, hpa.Spec.MaxReplicas, limited.Message,
+		)
+		alert := newAlert(resource, "ScalingLimited", types.SeverityWarning, alertmessage)
+		alertFn(alertSpec.AlerterType, alertSpec.AlerterName, alert, alertersConfig)
+	}
+
+	if alertSpec.ReportStatus.FailedGetMetrics {
+		for _, conditionType := range []autoscalingv2beta2.HorizontalPodAutoscalerConditionType{autoscalingv2beta2.ScalingActive, autoscalingv2beta2.AbleToScale} {
+			condition := hpaCondition(hpa, conditionType)
+			if condition == nil || condition.Status != corev1.ConditionFalse || !strings.HasPrefix(condition.Reason, "FailedGet") {
+				continue
+			}
+			// ALERT
+			alertmessage := fmt.Sprintf(
+				"HorizontalPodAutoscaler %s/%s cannot scale %s! %s: %s",
+				hpa.Namespace, hpa.Name, target, condition.Reason, condition.Message,
+			)
+			alert := newAlert(resource, "FailedGetMetrics", types.SeverityCritical, alertmessage)
+			alertFn(alertSpec.AlerterType, alertSpec.AlerterName, alert, alertersConfig)
+			break
+		}
+	}
+}
+
+// hpaCondition returns the condition of hpa of the given type, or nil when it has none
+func hpaCondition(hpa *autoscalingv2beta2.HorizontalPodAutoscaler, conditionType autoscalingv2beta2.HorizontalPodAutoscalerConditionType) *autoscalingv2beta2.HorizontalPodAutoscalerCondition {
+	for i := range hpa.Status.Conditions {
+		if hpa.Status.Conditions[i].Type == conditionType {
+			return &hpa.Status.Conditions[i]
+		}
+	}
+	return nil
+}
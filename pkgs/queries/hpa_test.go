@@ -0,0 +1,96 @@
+// Copyright 2019 Bloomberg Finance LP
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package queries
+
+import (
+	"testing"
+	"time"
+
+	. "github.com/bloomberg/k8eraid/pkgs/types"
+
+	"github.com/stretchr/testify/assert"
+	autoscalingv2beta2 "k8s.io/api/autoscaling/v2beta2"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func autoscaler(current int32, lastScale time.Time, conditions ...autoscalingv2beta2.HorizontalPodAutoscalerCondition) *autoscalingv2beta2.HorizontalPodAutoscaler {
+	return &autoscalingv2beta2.HorizontalPodAutoscaler{
+		ObjectMeta: metav1.ObjectMeta{Name: "web", Namespace: metav1.NamespaceDefault, Labels: map[string]string{"tier": "front"}},
+		Spec: autoscalingv2beta2.HorizontalPodAutoscalerSpec{
+			ScaleTargetRef: autoscalingv2beta2.CrossVersionObjectReference{Kind: "Deployment", Name: "web"},
+			MaxReplicas:    10,
+		},
+		Status: autoscalingv2beta2.HorizontalPodAutoscalerStatus{
+			CurrentReplicas: current,
+			LastScaleTime:   &metav1.Time{Time: lastScale},
+			Conditions:      conditions,
+		},
+	}
+}
+
+func Test_CheckHPA(t *testing.T) {
+	now := time.Unix(1000000, 0)
+	limited := autoscalingv2beta2.HorizontalPodAutoscalerCondition{
+		Type:    autoscalingv2beta2.ScalingLimited,
+		Status:  corev1.ConditionTrue,
+		Reason:  "TooManyReplicas",
+		Message: "the desired replica count is more than the maximum replica count",
+	}
+	blind := autoscalingv2beta2.HorizontalPodAutoscalerCondition{
+		Type:    autoscalingv2beta2.ScalingActive,
+		Status:  corev1.ConditionFalse,
+		Reason:  "FailedGetResourceMetric",
+		Message: "the HPA was unable to compute the replica count: unable to get metrics for resource cpu",
+	}
+	alertSpec := HPAAlertSpec{ReportStatus: HPAAlertStatus{MaxReplicasThreshold: 3600, ScalingLimited: true, FailedGetMetrics: true}}
+
+	assert.Equal(t, []string{
+		"HorizontalPodAutoscaler default/web has been at its maximum of 10 replicas for 2h0m0s, longer than 3600s! Deployment/web may need more capacity than it can scale to.",
+	}, CheckHPA(autoscaler(10, now.Add(-2*time.Hour), limited), alertSpec, now).Messages(), "being capped is not reported twice")
+	assert.Equal(t, []string{
+		"HorizontalPodAutoscaler default/web wants more replicas of Deployment/web than its maximum of 10! the desired replica count is more than the maximum replica count",
+	}, CheckHPA(autoscaler(10, now.Add(-time.Minute), limited), alertSpec, now).Messages())
+
+	tooFew := limited
+	tooFew.Reason = "TooFewReplicas"
+	assert.Empty(t, CheckHPA(autoscaler(2, now.Add(-2*time.Hour), tooFew), alertSpec, now).Alerts, "idling at the minimum is fine")
+
+	assert.Equal(t, []string{
+		"HorizontalPodAutoscaler default/web cannot scale Deployment/web! FailedGetResourceMetric: the HPA was unable to compute the replica count: unable to get metrics for resource cpu",
+	}, CheckHPA(autoscaler(4, now.Add(-2*time.Hour), blind), alertSpec, now).Messages())
+}
+
+func Test_PollHPA(t *testing.T) {
+	_, conf := StubsInit()
+	client := fake.NewSimpleClientset(autoscaler(10, time.Now().Add(-2*time.Hour)))
+
+	for _, spec := range []HPAAlertSpec{
+		{Name: "web", HPAFilterNamespace: metav1.NamespaceDefault},
+		{Name: "*", HPAFilterLabel: "tier=front"},
+	} {
+		spec.ReportStatus = HPAAlertStatus{MaxReplicasThreshold: 3600}
+		keys := []string{}
+		err := PollHPA(client, spec, defaultTickerTime, func(_ string, _ string, alert Alert, _ AlertersConfig) {
+			keys = append(keys, alert.Key)
+		}, conf)
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"horizontalpodautoscaler/default/web/MaxReplicas"}, keys, spec.Name)
+	}
+
+	assert.Error(t, PollHPA(client, HPAAlertSpec{Name: "web"}, defaultTickerTime, nil, conf), "named rules need a namespace")
+	assert.Error(t, PollHPA(client, HPAAlertSpec{Name: "absent", HPAFilterNamespace: metav1.NamespaceDefault}, defaultTickerTime, nil, conf))
+}
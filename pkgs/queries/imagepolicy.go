@@ -0,0 +1,133 @@
+// Copyright 2019 Bloomberg Finance LP
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package queries
+
+import (
+	"fmt"
+	"path"
+	"strings"
+
+	"github.com/bloomberg/k8eraid/pkgs/types"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+)
+
+// defaultImageRegistry is the registry images that name none are pulled from
+const defaultImageRegistry = "docker.io"
+
+// checkPodImagePolicy alerts on a pod with containers, init containers included, running images its
+// rule's image policy disallows. Pods that already finished are left alone.
+func checkPodImagePolicy(
+	pod *corev1.Pod,
+	alertSpec types.PodAlertSpec,
+	alertFn alertFunction,
+	alertersConfig types.AlertersConfig,
+) {
+	if pod.Status.Phase == corev1.PodSucceeded || pod.Status.Phase == corev1.PodFailed {
+		return
+	}
+	violations := imagePolicyViolations(&pod.Spec, alertSpec.ReportStatus.ImagePolicy)
+	if len(violations) == 0 {
+		return
+	}
+	// ALERT
+	alertmessage := fmt.Sprintf(
+		"Pod %s/%s runs images its policy disallows: %s!",
+		pod.Namespace, pod.Name, strings.Join(violations, ", "),
+	)
+	alert := newAlert(resourceID("pod", pod.Namespace, pod.Name), "ImagePolicy", types.SeverityWarning, alertmessage)
+	alertFn(alertSpec.AlerterType, alertSpec.AlerterName, alert, alertersConfig)
+}
+
+// checkDeploymentImagePolicy alerts on a Deployment whose pod template runs images its rule's image
+// policy disallows, so every pod it creates would
+func checkDeploymentImagePolicy(
+	deployment *appsv1.Deployment,
+	alertSpec types.DeploymentAlertSpec,
+	alertFn alertFunction,
+	alertersConfig types.AlertersConfig,
+) {
+	violations := imagePolicyViolations(&deployment.Spec.Template.Spec, alertSpec.ReportStatus.ImagePolicy)
+	if len(violations) == 0 {
+		return
+	}
+	// ALERT
+	alertmessage := fmt.Sprintf(
+		"Deployment %s/%s has a pod template with images its policy disallows: %s!",
+		deployment.Namespace, deployment.Name, strings.Join(violations, ", "),
+	)
+	alert := newAlert(resourceID("deployment", deployment.Namespace, deployment.Name), "ImagePolicy", types.SeverityWarning, alertmessage)
+	alertFn(alertSpec.AlerterType, alertSpec.AlerterName, alert, alertersConfig)
+}
+
+// imagePolicyViolations describes each container of spec, init containers included, whose image
+// policy disallows, with why
+func imagePolicyViolations(spec *corev1.PodSpec, policy types.ImagePolicy) []string {
+	if !policy.DisallowLatest && len(policy.AllowedRegistries) == 0 {
+		return nil
+	}
+	var violations []string
+	containers := append(append([]corev1.Container{}, spec.InitContainers...), spec.Containers...)
+	for _, container := range containers {
+		registry, tag, digest := splitImage(container.Image)
+		var reasons []string
+		if policy.DisallowLatest && digest == "" {
+			if tag == "" {
+				reasons = append(reasons, "no tag")
+			} else if tag == "latest" {
+				reasons = append(reasons, "the latest tag")
+			}
+		}
+		if len(policy.AllowedRegistries) > 0 && !allowedRegistry(registry, policy.AllowedRegistries) {
+			reasons = append(reasons, fmt.Sprintf("registry %s is not allowed", registry))
+		}
+		if len(reasons) > 0 {
+			violations = append(violations, fmt.Sprintf("%s (%s: %s)", container.Name, container.Image, strings.Join(reasons, ", ")))
+		}
+	}
+	return violations
+}
+
+// splitImage returns the registry, tag and digest of an image reference such as
+// registry.example.com:5000/team/app:1.2@sha256:..., with the registry docker.io when it names none
+func splitImage(image string) (string, string, string) {
+	name, digest := image, ""
+	if at := strings.Index(image, "@"); at >= 0 {
+		name, digest = image[:at], image[at+1:]
+	}
+	tag := ""
+	if colon := strings.LastIndex(name, ":"); colon > strings.LastIndex(name, "/") {
+		name, tag = name[:colon], name[colon+1:]
+	}
+	// the first component of the name is a registry when it looks like a host
+	registry := defaultImageRegistry
+	if slash := strings.Index(name, "/"); slash >= 0 {
+		if first := name[:slash]; strings.ContainsAny(first, ".:") || first == "localhost" {
+			registry = first
+		}
+	}
+	return registry, tag, digest
+}
+
+// allowedRegistry tells whether registry matches one of patterns
+func allowedRegistry(registry string, patterns []string) bool {
+	for _, pattern := range patterns {
+		if matched, _ := path.Match(pattern, registry); matched {
+			return true
+		}
+	}
+	return false
+}
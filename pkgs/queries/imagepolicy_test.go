@@ -0,0 +1,82 @@
+// Copyright 2019 Bloomberg Finance LP
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package queries
+
+import (
+	"testing"
+
+	. "github.com/bloomberg/k8eraid/pkgs/types"
+
+	"github.com/stretchr/testify/assert"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func Test_splitImage(t *testing.T) {
+	tests := []struct {
+		image    string
+		registry string
+		tag      string
+		digest   string
+	}{
+		{image: "nginx", registry: "docker.io"},
+		{image: "nginx:1.17", registry: "docker.io", tag: "1.17"},
+		{image: "team/app:latest", registry: "docker.io", tag: "latest"},
+		{image: "registry.example.com:5000/team/app", registry: "registry.example.com:5000"},
+		{image: "localhost/app:dev", registry: "localhost", tag: "dev"},
+		{image: "quay.io/team/app:2.0@sha256:abcd", registry: "quay.io", tag: "2.0", digest: "sha256:abcd"},
+		{image: "gcr.io/team/app@sha256:abcd", registry: "gcr.io", digest: "sha256:abcd"},
+	}
+	for _, test := range tests {
+		registry, tag, digest := splitImage(test.image)
+		assert.Equal(t, []string{test.registry, test.tag, test.digest}, []string{registry, tag, digest}, test.image)
+	}
+}
+
+func Test_checkImagePolicy(t *testing.T) {
+	_, conf := StubsInit()
+	spec := corev1.PodSpec{
+		InitContainers: []corev1.Container{{Name: "migrate", Image: "registry.example.com/shop/migrate"}},
+		Containers: []corev1.Container{
+			{Name: "app", Image: "registry.example.com/shop/app:1.4"},
+			{Name: "proxy", Image: "envoyproxy/envoy:latest"},
+			{Name: "pinned", Image: "registry.example.com/shop/agent:latest@sha256:abcd"},
+		},
+	}
+	var messages []string
+	alertStub := func(_ string, _ string, alert Alert, _ AlertersConfig) {
+		messages = append(messages, alert.Key+": "+alert.Message)
+	}
+	policy := ImagePolicy{DisallowLatest: true, AllowedRegistries: []string{"registry.example.com", "*.dkr.ecr.*.amazonaws.com"}}
+
+	pod := &corev1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "web-0", Namespace: "shop"}, Spec: spec, Status: corev1.PodStatus{Phase: corev1.PodRunning}}
+	checkPodImagePolicy(pod, PodAlertSpec{ReportStatus: PodAlertStatus{ImagePolicy: policy}}, alertStub, conf)
+	deployment := &appsv1.Deployment{ObjectMeta: metav1.ObjectMeta{Name: "web", Namespace: "shop"}}
+	deployment.Spec.Template.Spec = spec
+	checkDeploymentImagePolicy(deployment, DeploymentAlertSpec{ReportStatus: DeploymentAlertStatus{ImagePolicy: policy}}, alertStub, conf)
+	assert.Equal(t, []string{
+		"pod/shop/web-0/ImagePolicy: Pod shop/web-0 runs images its policy disallows: migrate (registry.example.com/shop/migrate: no tag)," +
+			" proxy (envoyproxy/envoy:latest: the latest tag, registry docker.io is not allowed)!",
+		"deployment/shop/web/ImagePolicy: Deployment shop/web has a pod template with images its policy disallows: migrate (registry.example.com/shop/migrate: no tag)," +
+			" proxy (envoyproxy/envoy:latest: the latest tag, registry docker.io is not allowed)!",
+	}, messages, "images pinned to a digest may use any tag")
+
+	messages = nil
+	pod.Status.Phase = corev1.PodSucceeded
+	checkPodImagePolicy(pod, PodAlertSpec{ReportStatus: PodAlertStatus{ImagePolicy: policy}}, alertStub, conf)
+	checkDeploymentImagePolicy(deployment, DeploymentAlertSpec{}, alertStub, conf)
+	assert.Empty(t, messages, "finished pods and rules without a policy are left alone")
+}
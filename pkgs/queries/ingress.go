@@ -0,0 +1,189 @@
+// Copyright 2019 Bloomberg Finance LP
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package queries
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/bloomberg/k8eraid/pkgs/types"
+
+	extensionsv1beta1 "k8s.io/api/extensions/v1beta1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// ingressClassAnnotation names the ingress controller meant to serve an Ingress
+const ingressClassAnnotation = "kubernetes.io/ingress.class"
+
+// ingressRoute is a host and path of an Ingress, and the Service it sends them to
+type ingressRoute struct {
+	route   string
+	service string
+}
+
+// PollIngress function takes inputs and iterates across Ingresses in the kubernetes cluster, triggering alerts as needed.
+func PollIngress(
+	clientset kubernetes.Interface,
+	alertSpec types.IngressAlertSpec,
+	tickertime int64,
+	alertFn alertFunction,
+	alertersConfig types.AlertersConfig,
+) error {
+
+	if alertSpec.ReportStatus.PendingThreshold == 0 {
+		alertSpec.ReportStatus.PendingThreshold = defaultPendingThreshold
+	}
+
+	var ingresses []extensionsv1beta1.Ingress
+
+	// Check rules with matching literal ingress name
+	if alertSpec.Name != "*" {
+		if alertSpec.IngressFilterNamespace == "" {
+			return &PollErr{
+				Message: fmt.Sprintf("Ingress rule for %s has no namespace filter specified, ignoring", alertSpec.Name),
+			}
+		}
+		ingress, ingresserr := clientset.ExtensionsV1beta1().Ingresses(alertSpec.IngressFilterNamespace).Get(alertSpec.Name, metav1.GetOptions{})
+		if ingresserr != nil {
+			return &PollErr{
+				Message: fmt.Sprintf("Error fetching Ingress %s: %s", alertSpec.Name, ingresserr.Error()),
+			}
+		}
+		ingresses = append(ingresses, *ingress)
+		// If the ingress name is a wildcard, list based on filter and iterate through
+	} else {
+		list, listerr := clientset.ExtensionsV1beta1().Ingresses(alertSpec.IngressFilterNamespace).List(metav1.ListOptions{
+			LabelSelector:  alertSpec.IngressFilterLabel,
+			TimeoutSeconds: &timeout,
+		})
+		if listerr != nil {
+			return &PollErr{
+				Message: fmt.Sprintf("Unable to list Ingresses: %s", listerr.Error()),
+			}
+		}
+		ingresses = list.Items
+	}
+
+	// Services shared by several routes or Ingresses are only fetched once
+	problems := map[string]string{}
+	now := time.Now()
+	for i := range ingresses {
+		ingress := &ingresses[i]
+		if alertSpec.ReportStatus.BrokenBackend {
+			if err := checkIngressBackends(clientset, ingress, problems, alertSpec, alertFn, alertersConfig); err != nil {
+				return err
+			}
+		}
+		if alertSpec.ReportStatus.NoLoadBalancer {
+			checkIngressLoadBalancer(ingress, alertSpec, now, alertFn, alertersConfig)
+		}
+	}
+	return nil
+}
+
+// ingressRoutes lists the routes of ingress in the order they are declared, its default backend first
+func ingressRoutes(ingress *extensionsv1beta1.Ingress) []ingressRoute {
+	var routes []ingressRoute
+	if ingress.Spec.Backend != nil {
+		routes = append(routes, ingressRoute{route: "its default backend", service: ingress.Spec.Backend.ServiceName})
+	}
+	for _, rule := range ingress.Spec.Rules {
+		if rule.HTTP == nil {
+			continue
+		}
+		host := rule.Host
+		if host == "" {
+			host = "*"
+		}
+		for _, path := range rule.HTTP.Paths {
+			routes = append(routes, ingressRoute{route: host + path.Path, service: path.Backend.ServiceName})
+		}
+	}
+	return routes
+}
+
+// checkIngressBackends alerts on an Ingress routing to Services, always in its own namespace, that do
+// not exist or have no ready endpoints. Every broken route of the Ingress is named in one alert.
+func checkIngressBackends(
+	clientset kubernetes.Interface,
+	ingress *extensionsv1beta1.Ingress,
+	problems map[string]string,
+	alertSpec types.IngressAlertSpec,
+	alertFn alertFunction,
+	alertersConfig types.AlertersConfig,
+) error {
+	var broken []string
+	for _, route := range ingressRoutes(ingress) {
+		serviceName := ingress.Namespace + "/" + route.service
+		problem, checked := problems[serviceName]
+		if !checked {
+			var err error
+			if problem, err = serviceProblem(clientset, ingress.Namespace, route.service); err != nil {
+				return err
+			}
+			problems[serviceName] = problem
+		}
+		if problem != "" {
+			broken = append(broken, fmt.Sprintf("%s to Service %s, which %s", route.route, route.service, problem))
+		}
+	}
+	if len(broken) == 0 {
+		return nil
+	}
+
+	// ALERT
+	alertmessage := fmt.Sprintf(
+		"Ingress %s/%s sends traffic to Services that cannot serve it: %s!",
+		ingress.Namespace, ingress.Name, strings.Join(broken, "; "),
+	)
+	alert := newAlert(resourceID("ingress", ingress.Namespace, ingress.Name), "BrokenBackend", types.SeverityCritical, alertmessage)
+	alertFn(alertSpec.AlerterType, alertSpec.AlerterName, alert, alertersConfig)
+	return nil
+}
+
+// checkIngressLoadBalancer alerts on an Ingress that its ingress controller has not given a
+// load-balancer address for longer than the pending threshold after it was created
+func checkIngressLoadBalancer(
+	ingress *extensionsv1beta1.Ingress,
+	alertSpec types.IngressAlertSpec,
+	now time.Time,
+	alertFn alertFunction,
+	alertersConfig types.AlertersConfig,
+) {
+	for _, address := range ingress.Status.LoadBalancer.Ingress {
+		if address.IP != "" || address.Hostname != "" {
+			return
+		}
+	}
+	threshold := alertSpec.ReportStatus.PendingThreshold
+	age := now.Sub(ingress.CreationTimestamp.Time)
+	if age <= time.Duration(threshold)*time.Second {
+		return
+	}
+
+	hint := "Its ingress controller may not be running."
+	if class := ingress.Annotations[ingressClassAnnotation]; class != "" {
+		hint = fmt.Sprintf("No running ingress controller may serve its class %s.", class)
+	}
+	// ALERT
+	alertmessage := fmt.Sprintf(
+		"Ingress %s/%s has had no load-balancer address for the %s since it was created, longer than %ds! %s",
+		ingress.Namespace, ingress.Name, formatLongDuration(age), threshold, hint,
+	)
+	alert := newAlert(resourceID("ingress", ingress.Namespace, ingress.Name), "NoLoadBalancer", types.SeverityWarning, alertmessage)
+	alertFn(alertSpec.AlerterType, alertSpec.AlerterName, alert, alertersConfig)
+}
@@ -0,0 +1,102 @@
+// Copyright 2019 Bloomberg Finance LP
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package queries
+
+import (
+	"testing"
+	"time"
+
+	. "github.com/bloomberg/k8eraid/pkgs/types"
+
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	extensionsv1beta1 "k8s.io/api/extensions/v1beta1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func ingressPath(path string, service string) extensionsv1beta1.HTTPIngressPath {
+	return extensionsv1beta1.HTTPIngressPath{Path: path, Backend: extensionsv1beta1.IngressBackend{ServiceName: service}}
+}
+
+func Test_PollIngress(t *testing.T) {
+	_, conf := StubsInit()
+	created := metav1.Time{Time: time.Now().Add(-time.Hour)}
+	shop := &extensionsv1beta1.Ingress{
+		ObjectMeta: metav1.ObjectMeta{Name: "shop", Namespace: metav1.NamespaceDefault, Labels: map[string]string{"tier": "front"}, CreationTimestamp: created},
+		Spec: extensionsv1beta1.IngressSpec{
+			Backend: &extensionsv1beta1.IngressBackend{ServiceName: "web"},
+			Rules: []extensionsv1beta1.IngressRule{
+				{
+					Host: "shop.example.com",
+					IngressRuleValue: extensionsv1beta1.IngressRuleValue{HTTP: &extensionsv1beta1.HTTPIngressRuleValue{
+						Paths: []extensionsv1beta1.HTTPIngressPath{ingressPath("/cart", "cart"), ingressPath("/", "web")},
+					}},
+				},
+				{
+					IngressRuleValue: extensionsv1beta1.IngressRuleValue{HTTP: &extensionsv1beta1.HTTPIngressRuleValue{
+						Paths: []extensionsv1beta1.HTTPIngressPath{ingressPath("/api", "api")},
+					}},
+				},
+			},
+		},
+	}
+	client := fake.NewSimpleClientset(
+		shop,
+		&corev1.Service{ObjectMeta: metav1.ObjectMeta{Name: "web", Namespace: metav1.NamespaceDefault}},
+		&corev1.Endpoints{
+			ObjectMeta: metav1.ObjectMeta{Name: "web", Namespace: metav1.NamespaceDefault},
+			Subsets:    []corev1.EndpointSubset{{Addresses: []corev1.EndpointAddress{{IP: "10.0.0.1"}}}},
+		},
+		&corev1.Service{ObjectMeta: metav1.ObjectMeta{Name: "api", Namespace: metav1.NamespaceDefault}},
+	)
+	poll := func(spec IngressAlertSpec) []string {
+		messages := []string{}
+		err := PollIngress(client, spec, defaultTickerTime, func(_ string, _ string, alert Alert, _ AlertersConfig) {
+			messages = append(messages, alert.Key+": "+alert.Message)
+		}, conf)
+		assert.NoError(t, err)
+		return messages
+	}
+
+	for _, spec := range []IngressAlertSpec{
+		{Name: "shop", IngressFilterNamespace: metav1.NamespaceDefault},
+		{Name: "*", IngressFilterLabel: "tier=front"},
+	} {
+		spec.ReportStatus = IngressAlertStatus{BrokenBackend: true}
+		assert.Equal(t, []string{
+			"ingress/default/shop/BrokenBackend: Ingress default/shop sends traffic to Services that cannot serve it: " +
+				"shop.example.com/cart to Service cart, which does not exist; */api to Service api, which has no ready endpoints!",
+		}, poll(spec), spec.Name)
+	}
+
+	loadBalancer := IngressAlertSpec{Name: "*", ReportStatus: IngressAlertStatus{NoLoadBalancer: true, PendingThreshold: 600}}
+	assert.Equal(t, []string{
+		"ingress/default/shop/NoLoadBalancer: Ingress default/shop has had no load-balancer address for the 1h0m0s since it was created, longer than 600s! Its ingress controller may not be running.",
+	}, poll(loadBalancer))
+	shop.Annotations = map[string]string{ingressClassAnnotation: "nginx-internal"}
+	_, err := client.ExtensionsV1beta1().Ingresses(metav1.NamespaceDefault).Update(shop)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{
+		"ingress/default/shop/NoLoadBalancer: Ingress default/shop has had no load-balancer address for the 1h0m0s since it was created, longer than 600s! No running ingress controller may serve its class nginx-internal.",
+	}, poll(loadBalancer))
+	shop.Status.LoadBalancer.Ingress = []corev1.LoadBalancerIngress{{Hostname: "lb.example.com"}}
+	_, err = client.ExtensionsV1beta1().Ingresses(metav1.NamespaceDefault).Update(shop)
+	assert.NoError(t, err)
+	assert.Empty(t, poll(loadBalancer))
+
+	assert.Error(t, PollIngress(client, IngressAlertSpec{Name: "shop"}, defaultTickerTime, nil, conf), "named rules need a namespace")
+	assert.Error(t, PollIngress(client, IngressAlertSpec{Name: "absent", IngressFilterNamespace: metav1.NamespaceDefault}, defaultTickerTime, nil, conf))
+}
@@ -0,0 +1,143 @@
+// Copyright 2019 Bloomberg Finance LP
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package queries
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/bloomberg/k8eraid/pkgs/types"
+
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// jobBackoffLimitReason is the reason of the Failed condition of a Job whose pods failed too often
+const jobBackoffLimitReason = "BackoffLimitExceeded"
+
+// defaultBackoffLimit is the backoffLimit of Jobs that do not set one
+const defaultBackoffLimit = 6
+
+// PollJob function takes inputs and iterates across batch jobs in the kubernetes cluster, triggering alerts as needed.
+func PollJob(
+	clientset kubernetes.Interface,
+	alertSpec types.JobAlertSpec,
+	tickertime int64,
+	alertFn alertFunction,
+	alertersConfig types.AlertersConfig,
+) error {
+
+	// If the job is not wildcard, search by name
+	if alertSpec.Name != "*" {
+		if alertSpec.JobFilter == "" {
+			return &PollErr{
+				Message: fmt.Sprintf("Job rule for %s has no namespace filter specified, ignoring", alertSpec.Name),
+			}
+		}
+		job, joberr := clientset.BatchV1().Jobs(alertSpec.JobFilter).Get(alertSpec.Name, metav1.GetOptions{})
+		if joberr != nil {
+			return &PollErr{
+				Message: fmt.Sprintf("Error fetching job %s: %s", alertSpec.Name, joberr.Error()),
+			}
+		}
+
+		checkJob(job, alertSpec, time.Now(), alertFn, alertersConfig)
+		// If the job is a wildcard, list jobs and iterate through
+	} else {
+		if !strings.Contains(alertSpec.JobFilter, "=") && alertSpec.JobFilter != "" {
+			return &PollErr{
+				Message: fmt.Sprintf("Job rule for global has incorrect filter specified (filter was: %s), ignoring", alertSpec.JobFilter),
+			}
+		}
+		jobs, jobserr := clientset.BatchV1().Jobs("").List(metav1.ListOptions{
+			LabelSelector:  alertSpec.JobFilter,
+			TimeoutSeconds: &timeout,
+		})
+		if jobserr != nil {
+			return &PollErr{
+				Message: fmt.Sprintf("Unable to list Jobs: %s", jobserr.Error()),
+			}
+		}
+		for i := range jobs.Items {
+			checkJob(&jobs.Items[i], alertSpec, time.Now(), alertFn, alertersConfig)
+		}
+	}
+	return nil
+}
+
+func checkJob(
+	job *batchv1.Job,
+	alertSpec types.JobAlertSpec,
+	now time.Time,
+	alertFn alertFunction,
+	alertersConfig types.AlertersConfig,
+) {
+	resource := resourceID("job", job.Namespace, job.Name)
+	failed := jobCondition(job, batchv1.JobFailed)
+	backoffLimitExceeded := failed != nil && failed.Reason == jobBackoffLimitReason
+
+	if alertSpec.ReportStatus.BackoffLimitExceeded && backoffLimitExceeded {
+		backoffLimit := int32(defaultBackoffLimit)
+		if job.Spec.BackoffLimit != nil {
+			backoffLimit = *job.Spec.BackoffLimit
+		}
+		// ALERT
+		alertmessage := fmt.Sprintf(
+			"Job %s/%s has failed after %d failed pods, exceeding its backoffLimit of %d!",
+			job.Namespace, job.Name, job.Status.Failed, backoffLimit,
+		)
+		alert := newAlert(resource, "BackoffLimitExceeded", types.SeverityCritical, alertmessage)
+		alertFn(alertSpec.AlerterType, alertSpec.AlerterName, alert, alertersConfig)
+	}
+
+	// A Job that gave up is reported by its backoff limit alone, when that is checked
+	if threshold := alertSpec.ReportStatus.FailedPods; threshold > 0 && job.Status.Failed >= threshold &&
+		!(alertSpec.ReportStatus.BackoffLimitExceeded && backoffLimitExceeded) {
+		// ALERT
+		alertmessage := fmt.Sprintf(
+			"Job %s/%s has %d failed pods, at least %d!",
+			job.Namespace, job.Name, job.Status.Failed, threshold,
+		)
+		alert := newAlert(resource, "FailedPods", types.SeverityWarning, alertmessage)
+		alertFn(alertSpec.AlerterType, alertSpec.AlerterName, alert, alertersConfig)
+	}
+
+	if deadline := alertSpec.ReportStatus.CompletionDeadline; deadline > 0 && failed == nil && job.Status.StartTime != nil &&
+		jobCondition(job, batchv1.JobComplete) == nil {
+		runningFor := now.Sub(job.Status.StartTime.Time)
+		if runningFor > time.Duration(deadline)*time.Second {
+			// ALERT
+			alertmessage := fmt.Sprintf(
+				"Job %s/%s has not completed %s after it started, longer than %ds! %d pods are active and %d succeeded.",
+				job.Namespace, job.Name, formatLongDuration(runningFor), deadline, job.Status.Active, job.Status.Succeeded,
+			)
+			alert := newAlert(resource, "CompletionDeadline", types.SeverityWarning, alertmessage)
+			alertFn(alertSpec.AlerterType, alertSpec.AlerterName, alert, alertersConfig)
+		}
+	}
+}
+
+// jobCondition returns the condition of job of conditionType when it is true, or nil
+func jobCondition(job *batchv1.Job, conditionType batchv1.JobConditionType) *batchv1.JobCondition {
+	for i, condition := range job.Status.Conditions {
+		if condition.Type == conditionType && condition.Status == corev1.ConditionTrue {
+			return &job.Status.Conditions[i]
+		}
+	}
+	return nil
+}
@@ -0,0 +1,126 @@
+// Copyright 2019 Bloomberg Finance LP
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package queries
+
+import (
+	"testing"
+	"time"
+
+	. "github.com/bloomberg/k8eraid/pkgs/types"
+
+	"github.com/stretchr/testify/assert"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func Test_PollJob(t *testing.T) {
+	_, conf := StubsInit()
+	backoffLimit := int32(2)
+	failed := &batchv1.Job{
+		ObjectMeta: metav1.ObjectMeta{Name: "load", Namespace: metav1.NamespaceDefault, Labels: map[string]string{"schedule": "nightly"}},
+		Spec:       batchv1.JobSpec{BackoffLimit: &backoffLimit},
+		Status: batchv1.JobStatus{
+			Failed:     3,
+			Conditions: []batchv1.JobCondition{{Type: batchv1.JobFailed, Status: corev1.ConditionTrue, Reason: jobBackoffLimitReason}},
+		},
+	}
+	completed := &batchv1.Job{
+		ObjectMeta: metav1.ObjectMeta{Name: "report", Namespace: metav1.NamespaceDefault, Labels: map[string]string{"schedule": "nightly"}},
+		Status: batchv1.JobStatus{
+			StartTime:  &metav1.Time{Time: time.Now().Add(-2 * time.Hour)},
+			Succeeded:  1,
+			Conditions: []batchv1.JobCondition{{Type: batchv1.JobComplete, Status: corev1.ConditionTrue}},
+		},
+	}
+
+	tests := []struct {
+		name        string
+		alertSpec   JobAlertSpec
+		objects     []runtime.Object
+		expected    []string
+		expectedErr bool
+	}{
+		{
+			name:      "backoff limit exceeded, alert",
+			objects:   []runtime.Object{failed},
+			alertSpec: JobAlertSpec{Name: "load", JobFilter: metav1.NamespaceDefault, ReportStatus: JobAlertStatus{BackoffLimitExceeded: true, FailedPods: 1}},
+			expected:  []string{"job/default/load/BackoffLimitExceeded"},
+		},
+		{
+			name:      "failed pods without the backoff limit check, alert",
+			objects:   []runtime.Object{failed},
+			alertSpec: JobAlertSpec{Name: "load", JobFilter: metav1.NamespaceDefault, ReportStatus: JobAlertStatus{FailedPods: 3}},
+			expected:  []string{"job/default/load/FailedPods"},
+		},
+		{
+			name:      "completed after the deadline, no alert",
+			objects:   []runtime.Object{completed},
+			alertSpec: JobAlertSpec{Name: "report", JobFilter: metav1.NamespaceDefault, ReportStatus: JobAlertStatus{CompletionDeadline: 3600}},
+			expected:  []string{},
+		},
+		{
+			name:      "wildcard, alert on matching jobs",
+			objects:   []runtime.Object{failed, completed},
+			alertSpec: JobAlertSpec{Name: "*", JobFilter: "schedule=nightly", ReportStatus: JobAlertStatus{BackoffLimitExceeded: true}},
+			expected:  []string{"job/default/load/BackoffLimitExceeded"},
+		},
+		{
+			name:        "missing, error",
+			alertSpec:   JobAlertSpec{Name: "load", JobFilter: metav1.NamespaceDefault},
+			expected:    []string{},
+			expectedErr: true,
+		},
+		{
+			name:        "no namespace filter, error",
+			alertSpec:   JobAlertSpec{Name: "load"},
+			expected:    []string{},
+			expectedErr: true,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(subT *testing.T) {
+			client := fake.NewSimpleClientset(test.objects...)
+			keys := []string{}
+			alertStub := func(_ string, _ string, alert Alert, _ AlertersConfig) {
+				keys = append(keys, alert.Key)
+			}
+			err := PollJob(client, test.alertSpec, defaultTickerTime, alertStub, conf)
+			assert.Equal(subT, test.expectedErr, err != nil, "unexpected error: %v", err)
+			assert.Equal(subT, test.expected, keys)
+		})
+	}
+}
+
+func Test_CheckJob_messages(t *testing.T) {
+	now := time.Unix(100000, 0)
+	job := &batchv1.Job{
+		ObjectMeta: metav1.ObjectMeta{Name: "load", Namespace: metav1.NamespaceDefault},
+		Status:     batchv1.JobStatus{StartTime: &metav1.Time{Time: now.Add(-90 * time.Minute)}, Active: 1, Failed: 2},
+	}
+	alertSpec := JobAlertSpec{ReportStatus: JobAlertStatus{BackoffLimitExceeded: true, CompletionDeadline: 3600, FailedPods: 2}}
+	assert.Equal(t, []string{
+		"Job default/load has 2 failed pods, at least 2!",
+		"Job default/load has not completed 1h30m0s after it started, longer than 3600s! 1 pods are active and 0 succeeded.",
+	}, CheckJob(job, alertSpec, now).Messages())
+
+	job.Status.Failed = 7
+	job.Status.Conditions = []batchv1.JobCondition{{Type: batchv1.JobFailed, Status: corev1.ConditionTrue, Reason: jobBackoffLimitReason}}
+	assert.Equal(t, []string{
+		"Job default/load has failed after 7 failed pods, exceeding its backoffLimit of 6!",
+	}, CheckJob(job, alertSpec, now).Messages(), "a failed job only alerts on its backoff limit")
+}
@@ -0,0 +1,130 @@
+// Copyright 2019 Bloomberg Finance LP
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package queries
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"k8s.io/client-go/kubernetes"
+)
+
+// kubeletSummary is the subset of the kubelet stats summary API read by k8eraid. These are the same
+// numbers the kubelet exports as kubelet_volume_stats_*_bytes, and compares to its eviction thresholds.
+type kubeletSummary struct {
+	Node struct {
+		Memory *struct {
+			AvailableBytes *uint64 `json:"availableBytes"`
+		} `json:"memory"`
+		Fs *struct {
+			AvailableBytes *uint64 `json:"availableBytes"`
+			CapacityBytes  *uint64 `json:"capacityBytes"`
+		} `json:"fs"`
+	} `json:"node"`
+	Pods []struct {
+		Volumes []struct {
+			PVCRef *struct {
+				Name      string `json:"name"`
+				Namespace string `json:"namespace"`
+			} `json:"pvcRef"`
+			CapacityBytes uint64 `json:"capacityBytes"`
+			UsedBytes     uint64 `json:"usedBytes"`
+		} `json:"volume"`
+	} `json:"pods"`
+}
+
+// volumeUsage is the usage of a PVC backed volume
+type volumeUsage struct {
+	CapacityBytes uint64
+	UsedBytes     uint64
+}
+
+// nodeVolumeUsage fetches the usage of every PVC mounted on a node through the apiserver node proxy,
+// keyed by namespace/name. It is a variable so tests can stub the kubelet out.
+var nodeVolumeUsage = func(clientset kubernetes.Interface, nodeName string) (map[string]volumeUsage, error) {
+	summary, err := kubeletStatsSummary(clientset, nodeName)
+	if err != nil {
+		return nil, err
+	}
+	usage := map[string]volumeUsage{}
+	for _, pod := range summary.Pods {
+		for _, volume := range pod.Volumes {
+			if volume.PVCRef == nil || volume.CapacityBytes == 0 {
+				continue
+			}
+			usage[volume.PVCRef.Namespace+"/"+volume.PVCRef.Name] = volumeUsage{
+				CapacityBytes: volume.CapacityBytes,
+				UsedBytes:     volume.UsedBytes,
+			}
+		}
+	}
+	return usage, nil
+}
+
+// nodeAvailability is what a node has left of the resources its pressure conditions watch. A field
+// is nil when the kubelet does not report it.
+type nodeAvailability struct {
+	MemoryAvailableBytes *uint64
+	NodeFsAvailableBytes *uint64
+	NodeFsCapacityBytes  *uint64
+}
+
+// nodeResourceAvailability fetches the available memory and root filesystem space of a node through
+// the apiserver node proxy. It is a variable so tests can stub the kubelet out.
+var nodeResourceAvailability = func(clientset kubernetes.Interface, nodeName string) (nodeAvailability, error) {
+	summary, err := kubeletStatsSummary(clientset, nodeName)
+	if err != nil {
+		return nodeAvailability{}, err
+	}
+	var availability nodeAvailability
+	if memory := summary.Node.Memory; memory != nil {
+		availability.MemoryAvailableBytes = memory.AvailableBytes
+	}
+	if fs := summary.Node.Fs; fs != nil {
+		availability.NodeFsAvailableBytes, availability.NodeFsCapacityBytes = fs.AvailableBytes, fs.CapacityBytes
+	}
+	return availability, nil
+}
+
+func kubeletStatsSummary(clientset kubernetes.Interface, nodeName string) (kubeletSummary, error) {
+	var summary kubeletSummary
+	data, err := clientset.CoreV1().RESTClient().Get().
+		Resource("nodes").
+		Name(nodeName).
+		SubResource("proxy").
+		Suffix("stats/summary").
+		DoRaw()
+	if err != nil {
+		return summary, err
+	}
+	if err := json.Unmarshal(data, &summary); err != nil {
+		return summary, fmt.Errorf("unable to parse stats summary of node %s: %s", nodeName, err.Error())
+	}
+	return summary, nil
+}
+
+// formatBytes renders a byte count using binary units
+func formatBytes(b uint64) string {
+	const unit = 1024
+	if b < unit {
+		return fmt.Sprintf("%dB", b)
+	}
+	div, exp := uint64(unit), 0
+	for n := b / unit; n >= unit; n /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f%ciB", float64(b)/float64(div), "KMGTPE"[exp])
+}
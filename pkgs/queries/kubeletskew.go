@@ -0,0 +1,94 @@
+// Copyright 2019 Bloomberg Finance LP
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package queries
+
+import (
+	"fmt"
+
+	"github.com/bloomberg/k8eraid/pkgs/types"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// checkKubeletSkew alerts on the nodes whose kubelet is older than the minimum version of the rule,
+// or further behind the apiserver than the supported skew, such as stragglers left behind by an
+// upgrade. A kubelet newer than the apiserver is never supported. Nodes reporting a kubelet version
+// that cannot be parsed are skipped.
+func checkKubeletSkew(
+	clientset kubernetes.Interface,
+	nodes []corev1.Node,
+	alertSpec types.NodeAlertSpec,
+	alertFn alertFunction,
+	alertersConfig types.AlertersConfig,
+) error {
+	skew := alertSpec.ReportStatus.KubeletSkew
+	if skew.MaxMinorVersions <= 0 && skew.MinVersion == "" {
+		return nil
+	}
+	var server types.KubernetesVersion
+	var serverVersion string
+	if skew.MaxMinorVersions > 0 {
+		info, infoerr := clientset.Discovery().ServerVersion()
+		if infoerr != nil {
+			return &PollErr{
+				Message: fmt.Sprintf("Unable to get the apiserver version: %s", infoerr.Error()),
+			}
+		}
+		var parsed bool
+		if server, parsed = types.ParseKubernetesVersion(info.GitVersion); !parsed {
+			return &PollErr{
+				Message: fmt.Sprintf("Unable to parse the apiserver version %q", info.GitVersion),
+			}
+		}
+		serverVersion = info.GitVersion
+	}
+	minimum, hasMinimum := types.ParseKubernetesVersion(skew.MinVersion)
+
+	for i := range nodes {
+		node := &nodes[i]
+		kubeletVersion := node.Status.NodeInfo.KubeletVersion
+		kubelet, parsed := types.ParseKubernetesVersion(kubeletVersion)
+		if !parsed {
+			continue
+		}
+		resource := resourceID("node", "", node.Name)
+		if hasMinimum && kubelet.Less(minimum) {
+			// ALERT
+			alertmessage := fmt.Sprintf("Node %s runs kubelet %s, older than the minimum of %s!", node.Name, kubeletVersion, skew.MinVersion)
+			alertFn(alertSpec.AlerterType, alertSpec.AlerterName, newAlert(resource, "KubeletVersion", types.SeverityWarning, alertmessage), alertersConfig)
+		}
+		if skew.MaxMinorVersions <= 0 {
+			continue
+		}
+		var alertmessage string
+		if server.Less(types.KubernetesVersion{Major: kubelet.Major, Minor: kubelet.Minor}) {
+			alertmessage = fmt.Sprintf(
+				"Node %s runs kubelet %s, newer than the apiserver at %s, which Kubernetes does not support!",
+				node.Name, kubeletVersion, serverVersion,
+			)
+		} else if behind := server.Minor - kubelet.Minor; behind > skew.MaxMinorVersions {
+			alertmessage = fmt.Sprintf(
+				"Node %s runs kubelet %s, %d minor versions behind the apiserver at %s, more than the %d allowed!",
+				node.Name, kubeletVersion, behind, serverVersion, skew.MaxMinorVersions,
+			)
+		}
+		if alertmessage != "" {
+			// ALERT
+			alertFn(alertSpec.AlerterType, alertSpec.AlerterName, newAlert(resource, "KubeletSkew", types.SeverityWarning, alertmessage), alertersConfig)
+		}
+	}
+	return nil
+}
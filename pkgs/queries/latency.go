@@ -0,0 +1,121 @@
+// Copyright 2019 Bloomberg Finance LP
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package queries
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/bloomberg/k8eraid/pkgs/metrics"
+	"github.com/bloomberg/k8eraid/pkgs/state"
+	"github.com/bloomberg/k8eraid/pkgs/types"
+)
+
+const (
+	apiLatencyKey           = "apiserver/latency"
+	defaultAPILatencyWindow = 300
+	// apiLatencyRetention bounds how long call latencies are kept, whatever the configured window
+	apiLatencyRetention = time.Hour
+	// apiLatencyBatch is how many call latencies are buffered before they are recorded in the state
+	apiLatencyBatch = 64
+)
+
+var (
+	apiRequests = metrics.NewCounterVec(
+		"k8eraid_apiserver_requests_total",
+		"Apiserver calls made by the pollers, excluding watches.",
+		"verb",
+	)
+	apiRequestSeconds = metrics.NewCounterVec(
+		"k8eraid_apiserver_request_duration_seconds_total",
+		"Total time spent in apiserver calls made by the pollers, excluding watches.",
+		"verb",
+	)
+	apiLatencyMean = metrics.NewGauge(
+		"k8eraid_apiserver_request_latency_seconds",
+		"Mean latency of apiserver calls over the apiserverLatency window, set on every tick the check runs.",
+	)
+	// apiLatencySamples batches the latency of every apiserver call on its way to the state store
+	apiLatencySamples = state.NewSampleBuffer(apiLatencyKey, apiLatencyRetention, apiLatencyBatch)
+)
+
+// latencyRoundTripper times every apiserver call made through it
+type latencyRoundTripper struct {
+	next http.RoundTripper
+}
+
+// InstrumentTransport wraps a client transport so the latency of its apiserver calls is recorded.
+// It is meant for rest.Config's WrapTransport.
+func InstrumentTransport(next http.RoundTripper) http.RoundTripper {
+	return &latencyRoundTripper{next: next}
+}
+
+func (l *latencyRoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	// watches stay open for minutes by design, their duration says nothing about the control plane
+	if req.URL.Query().Get("watch") == "true" || strings.Contains(req.URL.Path, "/watch/") {
+		return l.next.RoundTrip(req)
+	}
+	start := time.Now()
+	resp, err := l.next.RoundTrip(req)
+	recordAPILatency(req.Method, start, time.Since(start))
+	return resp, err
+}
+
+func recordAPILatency(verb string, start time.Time, elapsed time.Duration) {
+	apiRequests.With(verb).Inc()
+	apiRequestSeconds.With(verb).Add(elapsed.Seconds())
+	apiLatencySamples.Add(stateStore, state.Sample{Time: start, Value: elapsed.Seconds()})
+}
+
+// CheckAPILatency alerts when the apiserver calls made within the window were slower than the threshold on average
+func CheckAPILatency(
+	alertSpec types.APILatencyAlertSpec,
+	now time.Time,
+	alertFn alertFunction,
+	alertersConfig types.AlertersConfig,
+) {
+	if alertSpec.ThresholdSeconds <= 0 {
+		return
+	}
+	if alertSpec.Window <= 0 {
+		alertSpec.Window = defaultAPILatencyWindow
+	}
+	window := time.Duration(alertSpec.Window) * time.Second
+	apiLatencySamples.Flush(stateStore)
+	samples := stateStore.Samples(apiLatencyKey, now.Add(-window))
+	if len(samples) == 0 {
+		return
+	}
+	total := 0.0
+	for _, sample := range samples {
+		total += sample.Value
+	}
+	mean := total / float64(len(samples))
+	apiLatencyMean.Set(mean)
+
+	if mean > alertSpec.ThresholdSeconds {
+		// ALERT
+		alertmessage := fmt.Sprintf(
+			"Apiserver calls took %.2fs on average over the last %s (%d calls), above the %.2fs threshold! The control plane may be degrading.",
+			mean,
+			window,
+			len(samples),
+			alertSpec.ThresholdSeconds,
+		)
+		alertFn(alertSpec.AlerterType, alertSpec.AlerterName, newAlert("apiserver", "Latency", types.SeverityWarning, alertmessage), alertersConfig)
+	}
+}
@@ -0,0 +1,91 @@
+// Copyright 2019 Bloomberg Finance LP
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package queries
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/bloomberg/k8eraid/pkgs/state"
+	. "github.com/bloomberg/k8eraid/pkgs/types"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_InstrumentTransport(t *testing.T) {
+	stateStore = state.NewStore()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	client := &http.Client{Transport: InstrumentTransport(http.DefaultTransport)}
+	for _, path := range []string{"/api/v1/nodes", "/api/v1/nodes?watch=true", "/api/v1/watch/configmaps"} {
+		resp, err := client.Get(server.URL + path)
+		assert.NoError(t, err)
+		resp.Body.Close()
+	}
+	FlushState()
+	assert.Equal(t, 1, len(stateStore.Samples(apiLatencyKey, time.Time{})), "watches should not be recorded")
+}
+
+func Test_CheckAPILatency(t *testing.T) {
+	_, conf := StubsInit()
+	now := time.Now()
+	alertSpec := APILatencyAlertSpec{ThresholdSeconds: 1, Window: 60, AlerterType: "stderr"}
+
+	tests := []struct {
+		name        string
+		latencies   map[time.Duration]time.Duration
+		shouldAlert bool
+	}{
+		{
+			name:      "fast calls: no alert",
+			latencies: map[time.Duration]time.Duration{10 * time.Second: 100 * time.Millisecond, 20 * time.Second: 200 * time.Millisecond},
+		},
+		{
+			name:      "slow calls outside the window: no alert",
+			latencies: map[time.Duration]time.Duration{5 * time.Minute: 5 * time.Second, 10 * time.Second: 100 * time.Millisecond},
+		},
+		{
+			name:        "slow calls within the window: alert",
+			latencies:   map[time.Duration]time.Duration{10 * time.Second: 3 * time.Second, 20 * time.Second: 100 * time.Millisecond},
+			shouldAlert: true,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(subT *testing.T) {
+			stateStore = state.NewStore()
+			for ago, elapsed := range test.latencies {
+				recordAPILatency(http.MethodGet, now.Add(-ago), elapsed)
+			}
+			var alerts []Alert
+			alertStub := func(_ string, _ string, alert Alert, _ AlertersConfig) {
+				alerts = append(alerts, alert)
+			}
+			CheckAPILatency(alertSpec, now, alertStub, conf)
+			if !test.shouldAlert {
+				assert.Empty(subT, alerts)
+				return
+			}
+			if assert.Equal(subT, 1, len(alerts)) {
+				assert.Equal(subT, "apiserver/Latency", alerts[0].Key)
+				assert.Equal(subT, "Apiserver calls took 1.55s on average over the last 1m0s (2 calls), above the 1.00s threshold! The control plane may be degrading.", alerts[0].Message)
+			}
+		})
+	}
+}
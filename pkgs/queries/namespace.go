@@ -0,0 +1,169 @@
+// Copyright 2019 Bloomberg Finance LP
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package queries
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/bloomberg/k8eraid/pkgs/types"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// PollNamespace function takes inputs and iterates across Namespaces in the kubernetes cluster, triggering alerts as needed.
+func PollNamespace(
+	clientset kubernetes.Interface,
+	alertSpec types.NamespaceAlertSpec,
+	tickertime int64,
+	alertFn alertFunction,
+	alertersConfig types.AlertersConfig,
+) error {
+	var namespaces []corev1.Namespace
+
+	// If the namespace is not wildcard, search by name
+	if alertSpec.Name != "*" {
+		namespace, namespaceerr := clientset.CoreV1().Namespaces().Get(alertSpec.Name, metav1.GetOptions{})
+		if namespaceerr != nil {
+			return &PollErr{
+				Message: fmt.Sprintf("Error fetching Namespace %s: %s", alertSpec.Name, namespaceerr.Error()),
+			}
+		}
+		namespaces = append(namespaces, *namespace)
+		// If the namespace is a wildcard, list namespaces and iterate through
+	} else {
+		list, listerr := clientset.CoreV1().Namespaces().List(metav1.ListOptions{
+			LabelSelector:  alertSpec.NamespaceFilter,
+			TimeoutSeconds: &timeout,
+		})
+		if listerr != nil {
+			return &PollErr{
+				Message: fmt.Sprintf("Unable to list Namespaces: %s", listerr.Error()),
+			}
+		}
+		namespaces = list.Items
+	}
+
+	now := time.Now()
+	for i := range namespaces {
+		checkNamespace(&namespaces[i], alertSpec, now, alertFn, alertersConfig)
+	}
+	if alertSpec.ReportStatus.NetworkPolicies {
+		return checkNetworkPolicies(clientset, namespaces, alertSpec, alertFn, alertersConfig)
+	}
+	return nil
+}
+
+// checkNetworkPolicies alerts on the namespaces that run pods but contain no NetworkPolicy. Pods and
+// policies are listed once for wildcard rules, however many namespaces they match. Finished pods and
+// pods on the host network, which policies do not apply to, are not counted.
+func checkNetworkPolicies(
+	clientset kubernetes.Interface,
+	namespaces []corev1.Namespace,
+	alertSpec types.NamespaceAlertSpec,
+	alertFn alertFunction,
+	alertersConfig types.AlertersConfig,
+) error {
+	scope := metav1.NamespaceAll
+	if alertSpec.Name != "*" {
+		scope = alertSpec.Name
+	}
+	pods, podserr := clientset.CoreV1().Pods(scope).List(metav1.ListOptions{TimeoutSeconds: &timeout})
+	if podserr != nil {
+		return &PollErr{
+			Message: fmt.Sprintf("Unable to list pods: %s", podserr.Error()),
+		}
+	}
+	policies, policieserr := clientset.NetworkingV1().NetworkPolicies(scope).List(metav1.ListOptions{TimeoutSeconds: &timeout})
+	if policieserr != nil {
+		return &PollErr{
+			Message: fmt.Sprintf("Unable to list NetworkPolicies: %s", policieserr.Error()),
+		}
+	}
+	running := map[string]int{}
+	for _, pod := range pods.Items {
+		if pod.Spec.HostNetwork || pod.Status.Phase == corev1.PodSucceeded || pod.Status.Phase == corev1.PodFailed {
+			continue
+		}
+		running[pod.Namespace]++
+	}
+	isolated := map[string]bool{}
+	for _, policy := range policies.Items {
+		isolated[policy.Namespace] = true
+	}
+
+	for _, namespace := range namespaces {
+		if namespace.Status.Phase == corev1.NamespaceTerminating || running[namespace.Name] == 0 || isolated[namespace.Name] {
+			continue
+		}
+		// ALERT
+		alertmessage := fmt.Sprintf(
+			"Namespace %s runs %d pods but contains no NetworkPolicy! None of its pods is isolated, so they accept traffic from anywhere.",
+			namespace.Name, running[namespace.Name],
+		)
+		alert := newAlert(resourceID("namespace", "", namespace.Name), "NoNetworkPolicy", types.SeverityWarning, alertmessage)
+		alertFn(alertSpec.AlerterType, alertSpec.AlerterName, alert, alertersConfig)
+	}
+	return nil
+}
+
+// checkNamespace alerts on a namespace that has been Terminating for longer than the threshold,
+// counted from its deletion, with the finalizers it still waits on
+func checkNamespace(
+	namespace *corev1.Namespace,
+	alertSpec types.NamespaceAlertSpec,
+	now time.Time,
+	alertFn alertFunction,
+	alertersConfig types.AlertersConfig,
+) {
+	threshold := alertSpec.ReportStatus.TerminatingThreshold
+	if threshold <= 0 || namespace.Status.Phase != corev1.NamespaceTerminating || namespace.DeletionTimestamp == nil {
+		return
+	}
+	terminatingFor := now.Sub(namespace.DeletionTimestamp.Time)
+	if terminatingFor <= time.Duration(threshold)*time.Second {
+		return
+	}
+
+	// finalizers of the namespace controller are kept in the spec; the others in the metadata
+	var finalizers []string
+	contents := false
+	for _, finalizer := range namespace.Spec.Finalizers {
+		finalizers = append(finalizers, string(finalizer))
+		contents = contents || finalizer == corev1.FinalizerKubernetes
+	}
+	finalizers = append(finalizers, namespace.Finalizers...)
+
+	cause := "It has no finalizers left, so its deletion should be done."
+	if len(finalizers) > 0 {
+		cause = fmt.Sprintf("It waits on the finalizers %s.", strings.Join(finalizers, ", "))
+	}
+	if contents {
+		cause += fmt.Sprintf(
+			" The %s finalizer stays until every object in the namespace is deleted, which objects with finalizers of their own or an unavailable aggregated API can block.",
+			corev1.FinalizerKubernetes,
+		)
+	}
+	// ALERT
+	alertmessage := fmt.Sprintf(
+		"Namespace %s has been Terminating for %s, longer than %ds! %s",
+		namespace.Name, formatLongDuration(terminatingFor), threshold, cause,
+	)
+	alert := newAlert(resourceID("namespace", "", namespace.Name), "Terminating", types.SeverityWarning, alertmessage)
+	alertFn(alertSpec.AlerterType, alertSpec.AlerterName, alert, alertersConfig)
+}
@@ -0,0 +1,123 @@
+// Copyright 2019 Bloomberg Finance LP
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package queries
+
+import (
+	"testing"
+	"time"
+
+	. "github.com/bloomberg/k8eraid/pkgs/types"
+
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func terminatingNamespace(name string, deleted time.Time, finalizers ...string) *corev1.Namespace {
+	return &corev1.Namespace{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:              name,
+			Labels:            map[string]string{"team": "shop"},
+			DeletionTimestamp: &metav1.Time{Time: deleted},
+			Finalizers:        finalizers,
+		},
+		Spec:   corev1.NamespaceSpec{Finalizers: []corev1.FinalizerName{corev1.FinalizerKubernetes}},
+		Status: corev1.NamespaceStatus{Phase: corev1.NamespaceTerminating},
+	}
+}
+
+func Test_PollNamespace(t *testing.T) {
+	_, conf := StubsInit()
+	now := time.Now()
+	client := fake.NewSimpleClientset(
+		terminatingNamespace("shop-old", now.Add(-2*time.Hour), "example.com/backup"),
+		terminatingNamespace("shop-new", now.Add(-time.Minute)),
+		&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "shop", Labels: map[string]string{"team": "shop"}}},
+	)
+	poll := func(spec NamespaceAlertSpec) []string {
+		messages := []string{}
+		err := PollNamespace(client, spec, defaultTickerTime, func(_ string, _ string, alert Alert, _ AlertersConfig) {
+			messages = append(messages, alert.Key+": "+alert.Message)
+		}, conf)
+		assert.NoError(t, err)
+		return messages
+	}
+
+	expected := []string{
+		"namespace/shop-old/Terminating: Namespace shop-old has been Terminating for 2h0m0s, longer than 600s! It waits on the finalizers kubernetes, example.com/backup." +
+			" The kubernetes finalizer stays until every object in the namespace is deleted, which objects with finalizers of their own or an unavailable aggregated API can block.",
+	}
+	assert.Equal(t, expected, poll(NamespaceAlertSpec{Name: "*", NamespaceFilter: "team=shop", ReportStatus: NamespaceAlertStatus{TerminatingThreshold: 600}}))
+	assert.Equal(t, expected, poll(NamespaceAlertSpec{Name: "shop-old", ReportStatus: NamespaceAlertStatus{TerminatingThreshold: 600}}))
+	assert.Empty(t, poll(NamespaceAlertSpec{Name: "*"}), "the check is off without a threshold")
+
+	assert.Error(t, PollNamespace(client, NamespaceAlertSpec{Name: "absent"}, defaultTickerTime, nil, conf))
+}
+
+func Test_PollNamespace_NetworkPolicies(t *testing.T) {
+	_, conf := StubsInit()
+	pod := func(namespace string, name string, phase corev1.PodPhase, hostNetwork bool) *corev1.Pod {
+		return &corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: namespace},
+			Spec:       corev1.PodSpec{HostNetwork: hostNetwork},
+			Status:     corev1.PodStatus{Phase: phase},
+		}
+	}
+	namespace := func(name string) *corev1.Namespace {
+		return &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: name, Labels: map[string]string{"team": "shop"}}}
+	}
+	client := fake.NewSimpleClientset(
+		namespace("shop"), namespace("shop-isolated"), namespace("shop-batch"), namespace("shop-empty"),
+		terminatingNamespace("shop-old", time.Now()),
+		pod("shop", "web-0", corev1.PodRunning, false),
+		pod("shop", "web-1", corev1.PodPending, false),
+		pod("shop-isolated", "web-0", corev1.PodRunning, false),
+		pod("shop-batch", "load-0", corev1.PodSucceeded, false),
+		pod("shop-batch", "agent-0", corev1.PodRunning, true),
+		pod("shop-old", "web-0", corev1.PodRunning, false),
+		&networkingv1.NetworkPolicy{ObjectMeta: metav1.ObjectMeta{Name: "default-deny", Namespace: "shop-isolated"}},
+	)
+	poll := func(spec NamespaceAlertSpec) []string {
+		messages := []string{}
+		err := PollNamespace(client, spec, defaultTickerTime, func(_ string, _ string, alert Alert, _ AlertersConfig) {
+			messages = append(messages, alert.Key+": "+alert.Message)
+		}, conf)
+		assert.NoError(t, err)
+		return messages
+	}
+
+	expected := []string{
+		"namespace/shop/NoNetworkPolicy: Namespace shop runs 2 pods but contains no NetworkPolicy! None of its pods is isolated, so they accept traffic from anywhere.",
+	}
+	assert.Equal(t, expected, poll(NamespaceAlertSpec{Name: "*", NamespaceFilter: "team=shop", ReportStatus: NamespaceAlertStatus{NetworkPolicies: true}}))
+	assert.Equal(t, expected, poll(NamespaceAlertSpec{Name: "shop", ReportStatus: NamespaceAlertStatus{NetworkPolicies: true}}))
+	assert.Empty(t, poll(NamespaceAlertSpec{Name: "shop-isolated", ReportStatus: NamespaceAlertStatus{NetworkPolicies: true}}))
+}
+
+func Test_checkNamespace_NoFinalizers(t *testing.T) {
+	_, conf := StubsInit()
+	deleted := time.Unix(1000, 0)
+	namespace := terminatingNamespace("shop-old", deleted)
+	namespace.Spec.Finalizers = nil
+	messages := []string{}
+	checkNamespace(namespace, NamespaceAlertSpec{ReportStatus: NamespaceAlertStatus{TerminatingThreshold: 600}}, deleted.Add(time.Hour), func(_ string, _ string, alert Alert, _ AlertersConfig) {
+		messages = append(messages, alert.Message)
+	}, conf)
+	assert.Equal(t, []string{
+		"Namespace shop-old has been Terminating for 1h0m0s, longer than 600s! It has no finalizers left, so its deletion should be done.",
+	}, messages)
+}
@@ -16,11 +16,16 @@ package queries
 
 import (
 	"fmt"
+	"path"
+	"strings"
 	"time"
 
+	"github.com/bloomberg/k8eraid/pkgs/state"
 	"github.com/bloomberg/k8eraid/pkgs/types"
 
+	coordinationv1beta1 "k8s.io/api/coordination/v1beta1"
 	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/client-go/kubernetes"
 )
@@ -35,7 +40,20 @@ func PollNode(
 ) error {
 
 	if alertSpec.ReportStatus.PendingThreshold == 0 {
-		alertSpec.ReportStatus.PendingThreshold = 10
+		alertSpec.ReportStatus.PendingThreshold = defaultPendingThreshold
+	}
+
+	if alertSpec.ReportStatus.ReportDiff {
+		return diffNodes(clientset, alertSpec, alertFn, alertersConfig)
+	}
+
+	// Node Leases are listed once per poll rather than fetched for every node
+	var leases map[string]*coordinationv1beta1.Lease
+	if alertSpec.ReportStatus.LeaseThreshold > 0 || alertSpec.ReportStatus.ClockSkew.ThresholdSeconds > 0 {
+		var leaseserr error
+		if leases, leaseserr = nodeLeases(clientset); leaseserr != nil {
+			return leaseserr
+		}
 	}
 
 	// Check rules with matching literal node name
@@ -48,7 +66,12 @@ func PollNode(
 			}
 		}
 
-		checkNode(node, alertSpec, tickertime, alertFn, alertersConfig)
+		checkNodeObject(clientset, node, leases, alertSpec, tickertime, time.Now(), alertFn, alertersConfig)
+		checkNodeVersions([]corev1.Node{*node}, alertSpec, alertFn, alertersConfig)
+		checkClockSkew([]corev1.Node{*node}, leases, alertSpec, time.Now(), alertFn, alertersConfig)
+		if err := checkKubeletSkew(clientset, []corev1.Node{*node}, alertSpec, alertFn, alertersConfig); err != nil {
+			return err
+		}
 
 		// If nodename is a wildcard, list based on filter and iterate through
 	} else {
@@ -68,70 +91,518 @@ func PollNode(
 		}
 
 		// Check to see if there are the minimum specified nodes matching rule
-		if int32(len(nodes.Items)) < alertSpec.ReportStatus.MinNodes {
+		if alertSpec.ReportStatus.MinNodesReadyOnly {
+			usable := countUsableNodes(nodes.Items)
+			if usable < alertSpec.ReportStatus.MinNodes {
+				// ALERT
+				alertmessage := fmt.Sprintf(
+					"Ready, schedulable node count with filter %s is %d of %d listed, under minimum specification of %d!",
+					alertSpec.NodeFilter,
+					usable,
+					len(nodes.Items),
+					alertSpec.ReportStatus.MinNodes,
+				)
+				alert := newAlert(resourceID("nodes", "", alertSpec.NodeFilter), "MinNodes", types.SeverityCritical, alertmessage)
+				alertFn(alertSpec.AlerterType, alertSpec.AlerterName, alert, alertersConfig)
+			}
+		} else if int32(len(nodes.Items)) < alertSpec.ReportStatus.MinNodes {
 			// ALERT
 			alertmessage := fmt.Sprint("Node count with filter", alertSpec.NodeFilter, "in under minimum specification!")
-			alertFn(alertSpec.AlerterType, alertSpec.AlerterName, alertmessage, alertersConfig)
+			alert := newAlert(resourceID("nodes", "", alertSpec.NodeFilter), "MinNodes", types.SeverityCritical, alertmessage)
+			alertFn(alertSpec.AlerterType, alertSpec.AlerterName, alert, alertersConfig)
+		}
+		// Every listed node costs money, so the upper bound counts them all whatever MinNodesReadyOnly says
+		if maximum := alertSpec.ReportStatus.MaxNodes; maximum > 0 && int32(len(nodes.Items)) > maximum {
+			// ALERT
+			alertmessage := fmt.Sprintf(
+				"Node count with filter %s is %d, over maximum specification of %d!",
+				alertSpec.NodeFilter,
+				len(nodes.Items),
+				maximum,
+			)
+			alert := newAlert(resourceID("nodes", "", alertSpec.NodeFilter), "MaxNodes", types.SeverityWarning, alertmessage)
+			alertFn(alertSpec.AlerterType, alertSpec.AlerterName, alert, alertersConfig)
+		}
+
+		checkNodeVersions(nodes.Items, alertSpec, alertFn, alertersConfig)
+		if err := checkKubeletSkew(clientset, nodes.Items, alertSpec, alertFn, alertersConfig); err != nil {
+			return err
+		}
+		checkClockSkew(nodes.Items, leases, alertSpec, time.Now(), alertFn, alertersConfig)
+		checkNodeGroupBalance(nodes.Items, alertSpec, alertFn, alertersConfig)
+		if err := checkPoolSize(nodes.Items, alertSpec, alertFn, alertersConfig); err != nil {
+			return err
 		}
 
 		// Iterate through node items
+		objectErrs := newObjectErrors("node", alertSpec.OnObjectError)
 		for _, nodedata := range nodes.Items {
 			node, nodeerr := clientset.CoreV1().Nodes().Get(nodedata.GetName(), metav1.GetOptions{})
 			if nodeerr != nil {
-				return &PollErr{
+				if err := objectErrs.handle(&PollErr{
 					Message: fmt.Sprintf("Unable to get node %s: %s", nodedata.Name, nodeerr.Error()),
+				}); err != nil {
+					return err
 				}
+				continue
 			}
-			checkNode(node, alertSpec, tickertime, alertFn, alertersConfig)
+			checkNodeObject(clientset, node, leases, alertSpec, tickertime, time.Now(), alertFn, alertersConfig)
 		}
+		return objectErrs.err()
 	}
 	return nil
 }
 
+// checkNodeObject runs every check a node rule makes on one node, as of now. leases are the node
+// Leases the poll listed. With a nil clientset the checks reading node metrics are skipped, so the
+// node can be checked without an apiserver.
+func checkNodeObject(
+	clientset kubernetes.Interface,
+	node *corev1.Node,
+	leases map[string]*coordinationv1beta1.Lease,
+	alertSpec types.NodeAlertSpec,
+	tickertime int64,
+	now time.Time,
+	alertFn alertFunction,
+	alertersConfig types.AlertersConfig,
+) {
+	checkNode(node, alertSpec, tickertime, now, alertFn, alertersConfig)
+	checkNodeCordoned(node, alertSpec, now, alertFn, alertersConfig)
+	checkNodeLease(node, leases, alertSpec, now, alertFn, alertersConfig)
+	checkNodeTaints(node, alertSpec, now, alertFn, alertersConfig)
+	checkNodeTermination(node, alertSpec, now, alertFn, alertersConfig)
+	if clientset != nil {
+		checkNodeUtilization(clientset, node, alertSpec, alertFn, alertersConfig)
+		checkNodePressureForecast(clientset, node, alertSpec, now, alertFn, alertersConfig)
+	}
+}
+
+// countUsableNodes counts the nodes that are Ready and not cordoned
+func countUsableNodes(nodes []corev1.Node) int32 {
+	usable := int32(0)
+	for _, node := range nodes {
+		if node.Spec.Unschedulable {
+			continue
+		}
+		for _, condition := range node.Status.Conditions {
+			if condition.Type == corev1.NodeReady && condition.Status == corev1.ConditionTrue {
+				usable++
+				break
+			}
+		}
+	}
+	return usable
+}
+
+// diffNodes reports the nodes that joined, left or changed conditions since the previous tick
+func diffNodes(
+	clientset kubernetes.Interface,
+	alertSpec types.NodeAlertSpec,
+	alertFn alertFunction,
+	alertersConfig types.AlertersConfig,
+) error {
+	snapshot := state.Snapshot{}
+	if alertSpec.Name != "*" {
+		node, nodeerr := clientset.CoreV1().Nodes().Get(alertSpec.Name, metav1.GetOptions{})
+		if nodeerr == nil {
+			snapshot[resourceID("node", "", node.Name)] = nodeSummary(node)
+		} else if !apierrors.IsNotFound(nodeerr) {
+			return &PollErr{
+				Message: fmt.Sprintf("Unable to get node %s: %s", alertSpec.Name, nodeerr.Error()),
+			}
+		}
+	} else {
+		nodes, nodeserr := clientset.CoreV1().Nodes().List(metav1.ListOptions{
+			LabelSelector:  alertSpec.NodeFilter,
+			TimeoutSeconds: &timeout,
+		})
+		if nodeserr != nil {
+			return &PollErr{
+				Message: fmt.Sprintf("Unable to get nodes: %s", nodeserr.Error()),
+			}
+		}
+		for i := range nodes.Items {
+			snapshot[resourceID("node", "", nodes.Items[i].Name)] = nodeSummary(&nodes.Items[i])
+		}
+	}
+	reportDiff(specKey("node", alertSpec.Name, alertSpec.NodeFilter), snapshot, alertSpec.AlerterType, alertSpec.AlerterName, alertFn, alertersConfig)
+	return nil
+}
+
 func checkNode(
 	node *corev1.Node,
 	alertSpec types.NodeAlertSpec,
 	tickertime int64,
+	now time.Time,
 	alertFn alertFunction,
 	alertersConfig types.AlertersConfig,
 ) {
 
-	nowSeconds := time.Now().Unix()
+	nowSeconds := now.Unix()
 	statusCreatedSecondsDiff := nowSeconds - node.ObjectMeta.CreationTimestamp.Unix()
+	resource := resourceID("node", "", node.Name)
 
-	// If node hasnt been around longer than threshold, bail. otherwise check the status.
-	if statusCreatedSecondsDiff > alertSpec.ReportStatus.PendingThreshold {
-		for _, condition := range node.Status.Conditions {
-			transitiontimeDiff := nowSeconds - condition.LastTransitionTime.Unix()
-			if condition.Type == "Ready" {
-				if transitiontimeDiff < tickertime && alertSpec.ReportStatus.NodeReady {
-					// ALERT
-					alertmessage := fmt.Sprint("Node", alertSpec.Name, "has changed ready status since last poll and may be restarting!")
-					alertFn(alertSpec.AlerterType, alertSpec.AlerterName, alertmessage, alertersConfig)
-					return
-				}
-			} else if condition.Type == "OutOfDisk" {
-				if transitiontimeDiff < tickertime && alertSpec.ReportStatus.NodeOutOfDisk {
-					// ALERT
-					alertmessage := fmt.Sprint("Node", alertSpec.Name, "has changed OutOfDisk status since last poll and may have observed disk space issues!")
-					alertFn(alertSpec.AlerterType, alertSpec.AlerterName, alertmessage, alertersConfig)
-					return
-				}
-			} else if condition.Type == "MemoryPressure" {
-				if transitiontimeDiff < tickertime && alertSpec.ReportStatus.NodeMemoryPressure {
-					// ALERT
-					alertmessage := fmt.Sprint("Node", alertSpec.Name, "has changed MemoryPressure status since last poll and may have observed memory pressure!")
-					alertFn(alertSpec.AlerterType, alertSpec.AlerterName, alertmessage, alertersConfig)
-					return
-				}
-			} else if condition.Type == "DiskPressure" {
-				if transitiontimeDiff < tickertime && alertSpec.ReportStatus.NodeDiskPressure {
-					// ALERT
-					alertmessage := fmt.Sprint("Node", alertSpec.Name, "has changed DiskPressure tatus since last poll and may have observed disk pressure!")
-					alertFn(alertSpec.AlerterType, alertSpec.AlerterName, alertmessage, alertersConfig)
-					return
+	capacityType, interruptible := nodeCapacityType(node, alertSpec.ReportStatus.CapacityType)
+	note := ""
+	if capacityType != "" {
+		note = fmt.Sprintf(" (capacity type %s)", capacityType)
+	}
+
+	checkNodeConditionMatches(node, alertSpec, statusCreatedSecondsDiff, alertFn, alertersConfig)
+	for _, condition := range node.Status.Conditions {
+		// If node hasnt been around longer than the condition's threshold, skip it. otherwise check the status.
+		if statusCreatedSecondsDiff <= conditionPendingThreshold(alertSpec.ReportStatus, condition.Type) {
+			continue
+		}
+		transitiontimeDiff := nowSeconds - condition.LastTransitionTime.Unix()
+		if condition.Type == "Ready" {
+			if transitiontimeDiff < tickertime && alertSpec.ReportStatus.NodeReady {
+				// interruptible nodes are expected to be reclaimed and replaced
+				severity := types.SeverityCritical
+				if interruptible {
+					if alertSpec.ReportStatus.CapacityType.SuppressInterruptibleReady {
+						continue
+					}
+					if override := alertSpec.ReportStatus.CapacityType.InterruptibleReadySeverity; override != "" {
+						severity = override
+					}
 				}
+				// ALERT
+				alertmessage := fmt.Sprint("Node", alertSpec.Name, "has changed ready status since last poll and may be restarting!") + note
+				alert := newAlert(resource, "Ready", severity, alertmessage)
+				recordDetection(&alert, "Ready", condition.LastTransitionTime.Time, now)
+				alertFn(alertSpec.AlerterType, alertSpec.AlerterName, alert, alertersConfig)
+				return
 			}
+		} else if condition.Type == "OutOfDisk" {
+			if transitiontimeDiff < tickertime && alertSpec.ReportStatus.NodeOutOfDisk {
+				// ALERT
+				alertmessage := fmt.Sprint("Node", alertSpec.Name, "has changed OutOfDisk status since last poll and may have observed disk space issues!") + note
+				alert := newAlert(resource, "OutOfDisk", types.SeverityCritical, alertmessage)
+				recordDetection(&alert, "OutOfDisk", condition.LastTransitionTime.Time, now)
+				alertFn(alertSpec.AlerterType, alertSpec.AlerterName, alert, alertersConfig)
+				return
+			}
+		} else if condition.Type == "MemoryPressure" {
+			if transitiontimeDiff < tickertime && alertSpec.ReportStatus.NodeMemoryPressure {
+				// ALERT
+				alertmessage := fmt.Sprint("Node", alertSpec.Name, "has changed MemoryPressure status since last poll and may have observed memory pressure!") + note
+				alert := newAlert(resource, "MemoryPressure", types.SeverityWarning, alertmessage)
+				recordDetection(&alert, "MemoryPressure", condition.LastTransitionTime.Time, now)
+				alertFn(alertSpec.AlerterType, alertSpec.AlerterName, alert, alertersConfig)
+				return
+			}
+		} else if condition.Type == "DiskPressure" {
+			if transitiontimeDiff < tickertime && alertSpec.ReportStatus.NodeDiskPressure {
+				// ALERT
+				alertmessage := fmt.Sprint("Node", alertSpec.Name, "has changed DiskPressure tatus since last poll and may have observed disk pressure!") + note
+				alert := newAlert(resource, "DiskPressure", types.SeverityWarning, alertmessage)
+				recordDetection(&alert, "DiskPressure", condition.LastTransitionTime.Time, now)
+				alertFn(alertSpec.AlerterType, alertSpec.AlerterName, alert, alertersConfig)
+				return
+			}
+		} else if condition.Type == corev1.NodePIDPressure {
+			if transitiontimeDiff < tickertime && alertSpec.ReportStatus.NodePIDPressure {
+				// ALERT
+				alertmessage := fmt.Sprintf("Node %s has changed PIDPressure status since last poll and may be running out of process IDs!", node.Name) + note
+				alert := newAlert(resource, "PIDPressure", types.SeverityWarning, alertmessage)
+				recordDetection(&alert, "PIDPressure", condition.LastTransitionTime.Time, now)
+				alertFn(alertSpec.AlerterType, alertSpec.AlerterName, alert, alertersConfig)
+				return
+			}
+		} else if condition.Type == corev1.NodeNetworkUnavailable {
+			if transitiontimeDiff < tickertime && alertSpec.ReportStatus.NodeNetworkUnavailable {
+				// ALERT
+				alertmessage := fmt.Sprintf("Node %s has changed NetworkUnavailable status since last poll and its pod network may be down!", node.Name) + note
+				alert := newAlert(resource, "NetworkUnavailable", types.SeverityCritical, alertmessage)
+				recordDetection(&alert, "NetworkUnavailable", condition.LastTransitionTime.Time, now)
+				alertFn(alertSpec.AlerterType, alertSpec.AlerterName, alert, alertersConfig)
+				return
+			}
+		} else if transitiontimeDiff < tickertime && customCondition(alertSpec.ReportStatus, condition.Type) {
+			// ALERT
+			alertmessage := fmt.Sprintf(
+				"Node %s has changed %s status to %s since last poll: %s",
+				node.Name, condition.Type, condition.Status, conditionReason(condition),
+			) + note
+			alert := newAlert(resource, string(condition.Type), types.SeverityWarning, alertmessage)
+			recordDetection(&alert, string(condition.Type), condition.LastTransitionTime.Time, now)
+			alertFn(alertSpec.AlerterType, alertSpec.AlerterName, alert, alertersConfig)
+			return
+		}
+	}
+}
+
+// nodeUnschedulableTaint is the taint the node lifecycle controller adds to cordoned nodes
+const nodeUnschedulableTaint = "node.kubernetes.io/unschedulable"
+
+// checkNodeCordoned alerts on a node that has been cordoned for longer than the threshold, such as
+// one forgotten after maintenance, which silently shrinks the capacity of the cluster. When the node
+// was cordoned is read from the time the node lifecycle controller added its unschedulable taint, or
+// else counted from the first poll that saw it cordoned.
+func checkNodeCordoned(
+	node *corev1.Node,
+	alertSpec types.NodeAlertSpec,
+	now time.Time,
+	alertFn alertFunction,
+	alertersConfig types.AlertersConfig,
+) {
+	threshold := alertSpec.ReportStatus.CordonedThreshold
+	if threshold <= 0 {
+		return
+	}
+	resource := resourceID("node", "", node.Name)
+	cordonedFor := unhealthyFor("cordoned/"+resource, node.Spec.Unschedulable, node.CreationTimestamp.Time, now)
+	if !node.Spec.Unschedulable {
+		return
+	}
+	for _, taint := range node.Spec.Taints {
+		if taint.Key == nodeUnschedulableTaint && taint.TimeAdded != nil && !taint.TimeAdded.IsZero() {
+			cordonedFor = now.Sub(taint.TimeAdded.Time)
+		}
+	}
+	if cordonedFor <= time.Duration(threshold)*time.Second {
+		return
+	}
+	// ALERT
+	alertmessage := fmt.Sprintf(
+		"Node %s has been cordoned for %s, longer than %ds! Pods cannot be scheduled on it, uncordon it if its maintenance is over.",
+		node.Name, formatLongDuration(cordonedFor), threshold,
+	)
+	alertFn(alertSpec.AlerterType, alertSpec.AlerterName, newAlert(resource, "Cordoned", types.SeverityWarning, alertmessage), alertersConfig)
+}
+
+// checkNodeTaints alerts on every taint of node matching the rule that has been on the node for
+// longer than the threshold of its match. Failures such as an unreachable node or a custom
+// maintenance taint show up as taints well before, or instead of, a condition. Only NoExecute taints
+// record when they were added, the others are counted from the first poll that saw them.
+func checkNodeTaints(
+	node *corev1.Node,
+	alertSpec types.NodeAlertSpec,
+	now time.Time,
+	alertFn alertFunction,
+	alertersConfig types.AlertersConfig,
+) {
+	if len(alertSpec.ReportStatus.Taints) == 0 {
+		return
+	}
+	resource := resourceID("node", "", node.Name)
+	matches := map[string]types.NodeTaintMatch{}
+	since := map[string]time.Time{}
+	stateStore.UpdateSnapshot("taints/"+resource, func(previous state.Snapshot, _ bool) state.Snapshot {
+		current := state.Snapshot{}
+		for _, taint := range node.Spec.Taints {
+			match, matched := nodeTaintMatch(taint, alertSpec.ReportStatus.Taints)
+			if !matched {
+				continue
+			}
+			id := taint.Key + ":" + string(taint.Effect)
+			added := now
+			if recorded, err := time.Parse(time.RFC3339, previous[id]); err == nil {
+				added = recorded
+			}
+			matches[id], since[id] = match, added
+			current[id] = added.UTC().Format(time.RFC3339)
+		}
+		return current
+	})
+
+	for _, taint := range node.Spec.Taints {
+		id := taint.Key + ":" + string(taint.Effect)
+		match, matched := matches[id]
+		if !matched {
+			continue
+		}
+		added := since[id]
+		if taint.TimeAdded != nil && !taint.TimeAdded.IsZero() {
+			added = taint.TimeAdded.Time
+		} else if added.Before(node.CreationTimestamp.Time) {
+			added = node.CreationTimestamp.Time
+		}
+		taintedFor := now.Sub(added)
+		if taintedFor <= time.Duration(match.ThresholdSeconds)*time.Second {
+			continue
+		}
+		severity := match.Severity
+		if severity == "" {
+			severity = types.SeverityWarning
+		}
+		// ALERT
+		alertmessage := fmt.Sprintf(
+			"Node %s has been tainted %s for %s, longer than %ds!",
+			node.Name, formatTaint(taint), formatLongDuration(taintedFor), match.ThresholdSeconds,
+		)
+		alertFn(alertSpec.AlerterType, alertSpec.AlerterName, newAlert(resource, "Taint/"+id, severity, alertmessage), alertersConfig)
+	}
+}
+
+// nodeTaintMatch returns the first of matches that taint matches
+func nodeTaintMatch(taint corev1.Taint, matches []types.NodeTaintMatch) (types.NodeTaintMatch, bool) {
+	for _, match := range matches {
+		if keyMatched, _ := path.Match(match.Key, taint.Key); !keyMatched {
+			continue
+		}
+		if (match.Value != "" && match.Value != taint.Value) || (match.Effect != "" && match.Effect != string(taint.Effect)) {
+			continue
+		}
+		return match, true
+	}
+	return types.NodeTaintMatch{}, false
+}
+
+// formatTaint writes taint the way kubectl taint takes it, key=value:effect
+func formatTaint(taint corev1.Taint) string {
+	if taint.Value == "" {
+		return taint.Key + ":" + string(taint.Effect)
+	}
+	return taint.Key + "=" + taint.Value + ":" + string(taint.Effect)
+}
+
+// nodeCapacityType returns the capacity type label value of node, and whether it makes the node
+// interruptible
+func nodeCapacityType(node *corev1.Node, classification types.NodeCapacityType) (string, bool) {
+	if classification.Label == "" {
+		return "", false
+	}
+	capacityType := node.Labels[classification.Label]
+	interruptible := classification.Interruptible
+	if len(interruptible) == 0 {
+		interruptible = []string{"spot"}
+	}
+	for _, value := range interruptible {
+		if capacityType != "" && strings.EqualFold(capacityType, value) {
+			return capacityType, true
+		}
+	}
+	return capacityType, false
+}
+
+// customCondition tells whether status lists conditionType among its custom conditions
+func customCondition(status types.NodeAlertStatus, conditionType corev1.NodeConditionType) bool {
+	for _, custom := range status.CustomConditions {
+		if custom == string(conditionType) {
+			return true
+		}
+	}
+	return false
+}
+
+// conditionReason describes why condition has its status, as the component setting it reports
+func conditionReason(condition corev1.NodeCondition) string {
+	switch {
+	case condition.Reason != "" && condition.Message != "":
+		return condition.Reason + ", " + condition.Message
+	case condition.Reason != "":
+		return condition.Reason
+	case condition.Message != "":
+		return condition.Message
+	}
+	return "no reason given"
+}
+
+// conditionPendingThreshold is how many seconds old a node must be before its conditions of
+// conditionType are alerted on
+func conditionPendingThreshold(status types.NodeAlertStatus, conditionType corev1.NodeConditionType) int64 {
+	if threshold, ok := status.ConditionPendingThresholds[string(conditionType)]; ok {
+		return threshold
+	}
+	return status.PendingThreshold
+}
+
+// checkNodeConditionMatches alerts on every condition whose reason or message matches the rule, whatever its status
+func checkNodeConditionMatches(
+	node *corev1.Node,
+	alertSpec types.NodeAlertSpec,
+	nodeAge int64,
+	alertFn alertFunction,
+	alertersConfig types.AlertersConfig,
+) {
+	for _, match := range alertSpec.ReportStatus.ConditionMatches {
+		if match.ReasonContains == "" && match.MessageContains == "" {
+			continue
+		}
+		for _, condition := range node.Status.Conditions {
+			if match.Type != "" && string(condition.Type) != match.Type {
+				continue
+			}
+			if nodeAge <= conditionPendingThreshold(alertSpec.ReportStatus, condition.Type) {
+				continue
+			}
+			if match.ReasonContains != "" && !strings.Contains(condition.Reason, match.ReasonContains) {
+				continue
+			}
+			if match.MessageContains != "" && !strings.Contains(condition.Message, match.MessageContains) {
+				continue
+			}
+			// ALERT
+			alertmessage := fmt.Sprintf(
+				"Node %s condition %s=%s has reason %q: %s",
+				node.Name,
+				condition.Type,
+				condition.Status,
+				condition.Reason,
+				condition.Message,
+			)
+			alert := newAlert(resourceID("node", "", node.Name), string(condition.Type)+"Reason", types.SeverityWarning, alertmessage)
+			alertFn(alertSpec.AlerterType, alertSpec.AlerterName, alert, alertersConfig)
+		}
+	}
+}
+
+// nodeVersionMajority makes a NodeVersionDrift field expect the most common value among the matched nodes
+const nodeVersionMajority = "majority"
+
+// checkNodeVersions alerts on every node whose kernel, OS image or container runtime version differs
+// from the expected one, which surfaces node pools left half way through an upgrade.
+func checkNodeVersions(
+	nodes []corev1.Node,
+	alertSpec types.NodeAlertSpec,
+	alertFn alertFunction,
+	alertersConfig types.AlertersConfig,
+) {
+	drift := alertSpec.ReportStatus.VersionDrift
+	fields := []struct {
+		check    string
+		label    string
+		expected string
+		value    func(corev1.NodeSystemInfo) string
+	}{
+		{"KernelVersionDrift", "kernel version", drift.KernelVersion, func(i corev1.NodeSystemInfo) string { return i.KernelVersion }},
+		{"OSImageDrift", "OS image", drift.OSImage, func(i corev1.NodeSystemInfo) string { return i.OSImage }},
+		{"ContainerRuntimeVersionDrift", "container runtime version", drift.ContainerRuntimeVersion, func(i corev1.NodeSystemInfo) string { return i.ContainerRuntimeVersion }},
+	}
+	for _, field := range fields {
+		if field.expected == "" {
+			continue
+		}
+		expected, source := field.expected, "configured"
+		if expected == nodeVersionMajority {
+			counts := map[string]int{}
+			for _, node := range nodes {
+				counts[field.value(node.Status.NodeInfo)]++
+			}
+			expected, source = majorityValue(counts), "cluster majority"
+		}
+		for _, node := range nodes {
+			found := field.value(node.Status.NodeInfo)
+			if found == expected {
+				continue
+			}
+			// ALERT
+			alertmessage := fmt.Sprintf("Node %s %s is %q, expected %q (%s)!", node.Name, field.label, found, expected, source)
+			alert := newAlert(resourceID("node", "", node.Name), field.check, types.SeverityWarning, alertmessage)
+			alertFn(alertSpec.AlerterType, alertSpec.AlerterName, alert, alertersConfig)
+		}
+	}
+}
+
+// majorityValue returns the most common value, preferring the greatest one on ties so the result is stable
+func majorityValue(counts map[string]int) string {
+	majority, best := "", 0
+	for value, count := range counts {
+		if count > best || (count == best && value > majority) {
+			majority, best = value, count
 		}
 	}
+	return majority
 }
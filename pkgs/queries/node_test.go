@@ -15,14 +15,23 @@
 package queries
 
 import (
+	"errors"
 	"testing"
 	"time"
 
+	"github.com/bloomberg/k8eraid/pkgs/state"
 	. "github.com/bloomberg/k8eraid/pkgs/types"
 
+	"github.com/stretchr/testify/assert"
+
+	coordinationv1beta1 "k8s.io/api/coordination/v1beta1"
 	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/version"
+	fakediscovery "k8s.io/client-go/discovery/fake"
 	"k8s.io/client-go/kubernetes/fake"
+	clienttesting "k8s.io/client-go/testing"
 )
 
 func Test_PollNode_ok(t *testing.T) {
@@ -168,12 +177,72 @@ func Test_PollNode_ok(t *testing.T) {
 			shouldAlert:    true,
 			alertersConfig: conf,
 		},
+		{
+			name: "ready node with matching condition reason: alert",
+			node: &corev1.Node{
+				ObjectMeta: metav1.ObjectMeta{
+					CreationTimestamp: metav1.Time{Time: time.Now().Add(time.Second * -100)},
+					Name:              "test-node",
+				},
+				Status: corev1.NodeStatus{
+					Conditions: []corev1.NodeCondition{
+						{
+							LastTransitionTime: metav1.Time{Time: time.Now().Add(time.Hour * -1)},
+							Type:               corev1.NodeReady,
+							Status:             corev1.ConditionTrue,
+							Reason:             "KubeletReady",
+							Message:            "kubelet is posting ready status. PLEG is not healthy",
+						},
+					},
+				},
+			},
+			alertSpec: NodeAlertSpec{
+				Name: "test-node",
+				ReportStatus: NodeAlertStatus{
+					PendingThreshold: 5,
+					ConditionMatches: []NodeConditionMatch{
+						{Type: "Ready", MessageContains: "PLEG is not healthy"},
+					},
+				},
+			},
+			shouldAlert:    true,
+			alertersConfig: conf,
+		},
+		{
+			name: "ready node with other condition reason: no alert",
+			node: &corev1.Node{
+				ObjectMeta: metav1.ObjectMeta{
+					CreationTimestamp: metav1.Time{Time: time.Now().Add(time.Second * -100)},
+					Name:              "test-node",
+				},
+				Status: corev1.NodeStatus{
+					Conditions: []corev1.NodeCondition{
+						{
+							LastTransitionTime: metav1.Time{Time: time.Now().Add(time.Hour * -1)},
+							Type:               corev1.NodeReady,
+							Status:             corev1.ConditionTrue,
+							Reason:             "KubeletReady",
+						},
+					},
+				},
+			},
+			alertSpec: NodeAlertSpec{
+				Name: "test-node",
+				ReportStatus: NodeAlertStatus{
+					PendingThreshold: 5,
+					ConditionMatches: []NodeConditionMatch{
+						{Type: "Ready", ReasonContains: "KubeletNotReady"},
+					},
+				},
+			},
+			alertersConfig: conf,
+		},
 	}
 	for _, test := range tests {
 		t.Run(test.name, func(subT *testing.T) {
 			client := fake.NewSimpleClientset(test.node)
 			stubCalled := false
-			alertStub := func(_ string, _ string, _ string, _ AlertersConfig) {
+			alertStub := func(_ string, _ string, _ Alert, _ AlertersConfig) {
 				stubCalled = true
 			}
 			err := PollNode(client, test.alertSpec, defaultTickerTime, alertStub, conf)
@@ -188,3 +257,501 @@ func Test_PollNode_ok(t *testing.T) {
 		})
 	}
 }
+
+func Test_PollNode_MinNodesReadyOnly(t *testing.T) {
+	_, conf := StubsInit()
+	cordoned := readyNode("cordoned", corev1.ConditionTrue)
+	cordoned.Spec.Unschedulable = true
+
+	tests := []struct {
+		name        string
+		readyOnly   bool
+		shouldAlert bool
+	}{
+		{name: "counting every listed node: no alert", readyOnly: false},
+		{name: "counting ready, schedulable nodes: alert", readyOnly: true, shouldAlert: true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(subT *testing.T) {
+			client := fake.NewSimpleClientset(
+				readyNode("ready", corev1.ConditionTrue),
+				readyNode("not-ready", corev1.ConditionFalse),
+				readyNode("unknown", corev1.ConditionUnknown),
+				cordoned,
+			)
+			var alerts []Alert
+			alertStub := func(_ string, _ string, alert Alert, _ AlertersConfig) {
+				alerts = append(alerts, alert)
+			}
+			alertSpec := NodeAlertSpec{
+				Name: "*",
+				ReportStatus: NodeAlertStatus{
+					MinNodes:          2,
+					MinNodesReadyOnly: test.readyOnly,
+				},
+			}
+			assert.NoError(subT, PollNode(client, alertSpec, defaultTickerTime, alertStub, conf))
+			if !test.shouldAlert {
+				assert.Empty(subT, alerts)
+				return
+			}
+			if assert.Equal(subT, 1, len(alerts)) {
+				assert.Equal(subT, "nodes/MinNodes", alerts[0].Key)
+				assert.Equal(subT, "Ready, schedulable node count with filter  is 1 of 4 listed, under minimum specification of 2!", alerts[0].Message)
+			}
+		})
+	}
+}
+
+func Test_PollNode_MaxNodes(t *testing.T) {
+	_, conf := StubsInit()
+	client := fake.NewSimpleClientset(
+		readyNode("ready", corev1.ConditionTrue),
+		readyNode("not-ready", corev1.ConditionFalse),
+		readyNode("unknown", corev1.ConditionUnknown),
+	)
+	for _, test := range []struct {
+		maxNodes int32
+		messages []string
+	}{
+		{maxNodes: 0},
+		{maxNodes: 3},
+		{maxNodes: 2, messages: []string{"Node count with filter  is 3, over maximum specification of 2!"}},
+	} {
+		var messages []string
+		alertStub := func(_ string, _ string, alert Alert, _ AlertersConfig) {
+			assert.Equal(t, "nodes/MaxNodes", alert.Key)
+			messages = append(messages, alert.Message)
+		}
+		alertSpec := NodeAlertSpec{Name: "*", ReportStatus: NodeAlertStatus{MaxNodes: test.maxNodes, MinNodesReadyOnly: true}}
+		assert.NoError(t, PollNode(client, alertSpec, defaultTickerTime, alertStub, conf))
+		assert.Equal(t, test.messages, messages, "maxNodes %d", test.maxNodes)
+	}
+}
+
+func Test_PollNode_VersionDrift(t *testing.T) {
+	_, conf := StubsInit()
+	versionedNode := func(name string, kernel string, osImage string) *corev1.Node {
+		node := readyNode(name, corev1.ConditionTrue)
+		node.Status.NodeInfo = corev1.NodeSystemInfo{KernelVersion: kernel, OSImage: osImage, ContainerRuntimeVersion: "docker://18.9.2"}
+		return node
+	}
+
+	tests := []struct {
+		name     string
+		drift    NodeVersionDrift
+		expected []string
+	}{
+		{
+			name:  "majority",
+			drift: NodeVersionDrift{KernelVersion: "majority", ContainerRuntimeVersion: "majority"},
+			expected: []string{
+				`Node c kernel version is "4.15.0-1036", expected "4.15.0-1040" (cluster majority)!`,
+			},
+		},
+		{
+			name:  "expected value",
+			drift: NodeVersionDrift{OSImage: "Ubuntu 18.04.2 LTS"},
+			expected: []string{
+				`Node b OS image is "Ubuntu 16.04.6 LTS", expected "Ubuntu 18.04.2 LTS" (configured)!`,
+				`Node c OS image is "Ubuntu 16.04.6 LTS", expected "Ubuntu 18.04.2 LTS" (configured)!`,
+			},
+		},
+		{
+			name: "no drift options: no alert",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(subT *testing.T) {
+			client := fake.NewSimpleClientset(
+				versionedNode("a", "4.15.0-1040", "Ubuntu 18.04.2 LTS"),
+				versionedNode("b", "4.15.0-1040", "Ubuntu 16.04.6 LTS"),
+				versionedNode("c", "4.15.0-1036", "Ubuntu 16.04.6 LTS"),
+			)
+			var messages []string
+			alertStub := func(_ string, _ string, alert Alert, _ AlertersConfig) {
+				messages = append(messages, alert.Message)
+			}
+			alertSpec := NodeAlertSpec{Name: "*", ReportStatus: NodeAlertStatus{VersionDrift: test.drift}}
+			assert.NoError(subT, PollNode(client, alertSpec, defaultTickerTime, alertStub, conf))
+			assert.Equal(subT, test.expected, messages)
+		})
+	}
+}
+
+func Test_majorityValue(t *testing.T) {
+	assert.Equal(t, "", majorityValue(map[string]int{}))
+	assert.Equal(t, "b", majorityValue(map[string]int{"a": 1, "b": 2}))
+	assert.Equal(t, "b", majorityValue(map[string]int{"a": 2, "b": 2}))
+}
+
+func Test_PollNode_ConditionPendingThresholds(t *testing.T) {
+	_, conf := StubsInit()
+	node := readyNode("joined", corev1.ConditionTrue)
+	node.CreationTimestamp = metav1.Time{Time: time.Now().Add(-time.Minute)}
+	node.Status.Conditions = []corev1.NodeCondition{
+		{Type: corev1.NodeReady, Status: corev1.ConditionTrue, LastTransitionTime: metav1.Time{Time: time.Now().Add(-time.Second)}},
+		{Type: corev1.NodeMemoryPressure, Status: corev1.ConditionFalse, LastTransitionTime: metav1.Time{Time: time.Now().Add(-time.Second)}},
+	}
+	client := fake.NewSimpleClientset(node)
+
+	tests := []struct {
+		name       string
+		pending    int64
+		thresholds map[string]int64
+		expected   []string
+	}{
+		{name: "global threshold passed", pending: 30, expected: []string{"node/joined/Ready"}},
+		{name: "ready still settling", pending: 30, thresholds: map[string]int64{"Ready": 120}, expected: []string{"node/joined/MemoryPressure"}},
+		{name: "every condition still settling", pending: 30, thresholds: map[string]int64{"Ready": 120, "MemoryPressure": 300}},
+		{name: "global threshold not passed", pending: 300},
+		{name: "shorter than global", pending: 300, thresholds: map[string]int64{"MemoryPressure": 0}, expected: []string{"node/joined/MemoryPressure"}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(subT *testing.T) {
+			var keys []string
+			alertStub := func(_ string, _ string, alert Alert, _ AlertersConfig) {
+				keys = append(keys, alert.Key)
+			}
+			alertSpec := NodeAlertSpec{
+				Name: "joined",
+				ReportStatus: NodeAlertStatus{
+					PendingThreshold:           test.pending,
+					NodeReady:                  true,
+					NodeMemoryPressure:         true,
+					ConditionPendingThresholds: test.thresholds,
+				},
+			}
+			assert.NoError(subT, PollNode(client, alertSpec, defaultTickerTime, alertStub, conf))
+			assert.Equal(subT, test.expected, keys)
+		})
+	}
+}
+
+func Test_PollNode_CapacityType(t *testing.T) {
+	_, conf := StubsInit()
+	restarted := func(name string, capacityType string) *corev1.Node {
+		node := readyNode(name, corev1.ConditionTrue)
+		node.CreationTimestamp = metav1.Time{Time: time.Now().Add(-time.Hour)}
+		node.Labels = map[string]string{"pool": "workers"}
+		if capacityType != "" {
+			node.Labels["karpenter.sh/capacity-type"] = capacityType
+		}
+		node.Status.Conditions[0].LastTransitionTime = metav1.Time{Time: time.Now().Add(-time.Second)}
+		return node
+	}
+	client := fake.NewSimpleClientset(restarted("spot-1", "spot"), restarted("spot-2", "SPOT"), restarted("on-demand-1", "on-demand"), restarted("unlabelled", ""))
+
+	tests := []struct {
+		name           string
+		classification NodeCapacityType
+		expected       []string
+	}{
+		{
+			name: "not classified",
+			expected: []string{
+				"critical Node*has changed ready status since last poll and may be restarting!",
+				"critical Node*has changed ready status since last poll and may be restarting!",
+				"critical Node*has changed ready status since last poll and may be restarting!",
+				"critical Node*has changed ready status since last poll and may be restarting!",
+			},
+		},
+		{
+			name:           "spot downgraded",
+			classification: NodeCapacityType{Label: "karpenter.sh/capacity-type", InterruptibleReadySeverity: SeverityInfo},
+			expected: []string{
+				"critical Node*has changed ready status since last poll and may be restarting! (capacity type on-demand)",
+				"info Node*has changed ready status since last poll and may be restarting! (capacity type spot)",
+				"info Node*has changed ready status since last poll and may be restarting! (capacity type SPOT)",
+				"critical Node*has changed ready status since last poll and may be restarting!",
+			},
+		},
+		{
+			name:           "spot suppressed",
+			classification: NodeCapacityType{Label: "karpenter.sh/capacity-type", SuppressInterruptibleReady: true},
+			expected: []string{
+				"critical Node*has changed ready status since last poll and may be restarting! (capacity type on-demand)",
+				"critical Node*has changed ready status since last poll and may be restarting!",
+			},
+		},
+		{
+			name:           "custom interruptible values",
+			classification: NodeCapacityType{Label: "karpenter.sh/capacity-type", Interruptible: []string{"on-demand"}, SuppressInterruptibleReady: true},
+			expected: []string{
+				"critical Node*has changed ready status since last poll and may be restarting! (capacity type spot)",
+				"critical Node*has changed ready status since last poll and may be restarting! (capacity type SPOT)",
+				"critical Node*has changed ready status since last poll and may be restarting!",
+			},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(subT *testing.T) {
+			var alerts []string
+			alertStub := func(_ string, _ string, alert Alert, _ AlertersConfig) {
+				alerts = append(alerts, alert.Severity+" "+alert.Message)
+			}
+			alertSpec := NodeAlertSpec{
+				Name:         "*",
+				NodeFilter:   "pool=workers",
+				ReportStatus: NodeAlertStatus{NodeReady: true, CapacityType: test.classification},
+			}
+			assert.NoError(subT, PollNode(client, alertSpec, defaultTickerTime, alertStub, conf))
+			assert.ElementsMatch(subT, test.expected, alerts)
+		})
+	}
+}
+
+func Test_PollNode_OnObjectError(t *testing.T) {
+	_, conf := StubsInit()
+	restarted := func(name string) *corev1.Node {
+		node := readyNode(name, corev1.ConditionTrue)
+		node.CreationTimestamp = metav1.Time{Time: time.Now().Add(-time.Hour)}
+		node.Status.Conditions[0].LastTransitionTime = metav1.Time{Time: time.Now().Add(-time.Second)}
+		return node
+	}
+
+	tests := []struct {
+		name     string
+		policy   string
+		expected []string
+	}{
+		{name: "abort by default", policy: ""},
+		{name: "abort", policy: OnObjectErrorAbort},
+		{name: "continue", policy: OnObjectErrorContinue, expected: []string{"node/worker-2/Ready", "node/worker-3/Ready"}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(subT *testing.T) {
+			client := fake.NewSimpleClientset(restarted("worker-1"), restarted("worker-2"), restarted("worker-3"))
+			client.PrependReactor("get", "nodes", func(action clienttesting.Action) (bool, runtime.Object, error) {
+				if action.(clienttesting.GetAction).GetName() == "worker-1" {
+					return true, nil, errors.New("connection reset")
+				}
+				return false, nil, nil
+			})
+			var alerts []string
+			alertStub := func(_ string, _ string, alert Alert, _ AlertersConfig) {
+				alerts = append(alerts, alert.Key)
+			}
+			alertSpec := NodeAlertSpec{Name: "*", ReportStatus: NodeAlertStatus{NodeReady: true}, OnObjectError: test.policy}
+
+			err := PollNode(client, alertSpec, defaultTickerTime, alertStub, conf)
+			if test.policy == OnObjectErrorContinue {
+				assert.EqualError(subT, err, "1 nodes could not be checked: Unable to get node worker-1: connection reset")
+			} else {
+				assert.EqualError(subT, err, "Unable to get node worker-1: connection reset")
+			}
+			assert.Equal(subT, test.expected, alerts)
+		})
+	}
+}
+
+func Test_checkNode_DetectionLatency(t *testing.T) {
+	_, conf := StubsInit()
+	now := time.Unix(100000, 0)
+	node := readyNode("worker-1", corev1.ConditionFalse)
+	node.CreationTimestamp = metav1.Time{Time: now.Add(-time.Hour)}
+	node.Status.Conditions[0].LastTransitionTime = metav1.Time{Time: now.Add(-12 * time.Second)}
+	alertSpec := NodeAlertSpec{ReportStatus: NodeAlertStatus{NodeReady: true, PendingThreshold: defaultPendingThreshold}}
+
+	var alerts []Alert
+	alertStub := func(_ string, _ string, alert Alert, _ AlertersConfig) {
+		alerts = append(alerts, alert)
+	}
+	observed := detectionLatency.With("node", "Ready").Count()
+	checkNode(node, alertSpec, defaultTickerTime, now, alertStub, conf)
+	if assert.Equal(t, 1, len(alerts)) {
+		assert.Equal(t, 12*time.Second, alerts[0].DetectedAfter)
+	}
+	assert.Equal(t, observed+1, detectionLatency.With("node", "Ready").Count())
+}
+
+func Test_checkNodeCordoned(t *testing.T) {
+	stateStore = state.NewStore()
+	_, conf := StubsInit()
+	start := time.Unix(100000, 0)
+	node := readyNode("worker-1", corev1.ConditionTrue)
+	node.CreationTimestamp = metav1.Time{Time: start.Add(-72 * time.Hour)}
+	alertSpec := NodeAlertSpec{ReportStatus: NodeAlertStatus{CordonedThreshold: 3600}}
+	check := func(after time.Duration) []string {
+		messages := []string{}
+		checkNodeCordoned(node, alertSpec, start.Add(after), func(_ string, _ string, alert Alert, _ AlertersConfig) {
+			messages = append(messages, alert.Key+": "+alert.Message)
+		}, conf)
+		return messages
+	}
+
+	assert.Empty(t, check(0))
+	node.Spec.Unschedulable = true
+	assert.Empty(t, check(time.Minute), "a node first seen cordoned was just cordoned")
+	assert.Equal(t, []string{
+		"node/worker-1/Cordoned: Node worker-1 has been cordoned for 2h0m0s, longer than 3600s! Pods cannot be scheduled on it, uncordon it if its maintenance is over.",
+	}, check(2*time.Hour+time.Minute))
+
+	node.Spec.Taints = []corev1.Taint{{Key: nodeUnschedulableTaint, Effect: corev1.TaintEffectNoSchedule, TimeAdded: &metav1.Time{Time: start.Add(-72 * time.Hour)}}}
+	assert.Equal(t, []string{
+		"node/worker-1/Cordoned: Node worker-1 has been cordoned for 3 days, longer than 3600s! Pods cannot be scheduled on it, uncordon it if its maintenance is over.",
+	}, check(0), "the unschedulable taint records when the node was cordoned")
+
+	node.Spec.Unschedulable = false
+	assert.Empty(t, check(3*time.Hour))
+	node.Spec.Unschedulable, node.Spec.Taints = true, nil
+	assert.Empty(t, check(3*time.Hour+time.Minute), "uncordoning starts counting again")
+}
+
+func Test_checkNodeTaints(t *testing.T) {
+	stateStore = state.NewStore()
+	_, conf := StubsInit()
+	start := time.Unix(100000, 0)
+	node := readyNode("worker-1", corev1.ConditionTrue)
+	node.CreationTimestamp = metav1.Time{Time: start.Add(-72 * time.Hour)}
+	alertSpec := NodeAlertSpec{ReportStatus: NodeAlertStatus{Taints: []NodeTaintMatch{
+		{Key: "node.kubernetes.io/unreachable", ThresholdSeconds: 300, Severity: SeverityCritical},
+		{Key: "maintenance.example.com/*", Effect: string(corev1.TaintEffectNoSchedule), ThresholdSeconds: 3600},
+	}}}
+	check := func(after time.Duration) []string {
+		messages := []string{}
+		checkNodeTaints(node, alertSpec, start.Add(after), func(_ string, _ string, alert Alert, _ AlertersConfig) {
+			messages = append(messages, alert.Key+" ["+alert.Severity+"]: "+alert.Message)
+		}, conf)
+		return messages
+	}
+
+	node.Spec.Taints = []corev1.Taint{
+		{Key: "node.kubernetes.io/unreachable", Effect: corev1.TaintEffectNoExecute, TimeAdded: &metav1.Time{Time: start.Add(-10 * time.Minute)}},
+		{Key: "maintenance.example.com/drain", Value: "kernel-upgrade", Effect: corev1.TaintEffectNoSchedule},
+		{Key: "maintenance.example.com/drain", Effect: corev1.TaintEffectPreferNoSchedule},
+		{Key: "dedicated", Value: "gpu", Effect: corev1.TaintEffectNoSchedule},
+	}
+	assert.Equal(t, []string{
+		"node/worker-1/Taint/node.kubernetes.io/unreachable:NoExecute [critical]: Node worker-1 has been tainted node.kubernetes.io/unreachable:NoExecute for 10m0s, longer than 300s!",
+	}, check(0), "taints without the time they were added were just added")
+	assert.Equal(t, []string{
+		"node/worker-1/Taint/node.kubernetes.io/unreachable:NoExecute [critical]: Node worker-1 has been tainted node.kubernetes.io/unreachable:NoExecute for 2h10m0s, longer than 300s!",
+		"node/worker-1/Taint/maintenance.example.com/drain:NoSchedule [warning]: Node worker-1 has been tainted maintenance.example.com/drain=kernel-upgrade:NoSchedule for 2h0m0s, longer than 3600s!",
+	}, check(2*time.Hour))
+
+	node.Spec.Taints = node.Spec.Taints[2:]
+	assert.Empty(t, check(3*time.Hour))
+	node.Spec.Taints = []corev1.Taint{{Key: "maintenance.example.com/drain", Effect: corev1.TaintEffectNoSchedule}}
+	assert.Empty(t, check(3*time.Hour+time.Minute), "a taint removed and added again starts counting again")
+}
+
+func Test_PollNode_KubeletSkew(t *testing.T) {
+	_, conf := StubsInit()
+	kubelet := func(name string, version string) *corev1.Node {
+		node := readyNode(name, corev1.ConditionTrue)
+		node.Labels = map[string]string{"pool": "workers"}
+		node.Status.NodeInfo.KubeletVersion = version
+		return node
+	}
+	client := fake.NewSimpleClientset(
+		kubelet("current", "v1.13.4"),
+		kubelet("previous", "v1.12.7"),
+		kubelet("straggler", "v1.10.11-gke.1"),
+		kubelet("ahead", "v1.14.0"),
+		kubelet("unknown", ""),
+	)
+	client.Discovery().(*fakediscovery.FakeDiscovery).FakedServerVersion = &version.Info{GitVersion: "v1.13.0"}
+
+	var messages []string
+	alertStub := func(_ string, _ string, alert Alert, _ AlertersConfig) {
+		messages = append(messages, alert.Key+": "+alert.Message)
+	}
+	alertSpec := NodeAlertSpec{Name: "*", NodeFilter: "pool=workers", ReportStatus: NodeAlertStatus{KubeletSkew: NodeKubeletSkew{MaxMinorVersions: 2, MinVersion: "v1.12.5"}}}
+	assert.NoError(t, PollNode(client, alertSpec, defaultTickerTime, alertStub, conf))
+	assert.ElementsMatch(t, []string{
+		"node/straggler/KubeletVersion: Node straggler runs kubelet v1.10.11-gke.1, older than the minimum of v1.12.5!",
+		"node/straggler/KubeletSkew: Node straggler runs kubelet v1.10.11-gke.1, 3 minor versions behind the apiserver at v1.13.0, more than the 2 allowed!",
+		"node/ahead/KubeletSkew: Node ahead runs kubelet v1.14.0, newer than the apiserver at v1.13.0, which Kubernetes does not support!",
+	}, messages)
+
+	messages = nil
+	alertSpec = NodeAlertSpec{Name: "previous", ReportStatus: NodeAlertStatus{KubeletSkew: NodeKubeletSkew{MinVersion: "v1.13"}}}
+	assert.NoError(t, PollNode(client, alertSpec, defaultTickerTime, alertStub, conf))
+	assert.Equal(t, []string{"node/previous/KubeletVersion: Node previous runs kubelet v1.12.7, older than the minimum of v1.13!"}, messages)
+
+	client.Discovery().(*fakediscovery.FakeDiscovery).FakedServerVersion = &version.Info{GitVersion: "unknown"}
+	alertSpec.ReportStatus.KubeletSkew.MaxMinorVersions = 1
+	assert.Error(t, PollNode(client, alertSpec, defaultTickerTime, alertStub, conf))
+}
+
+func Test_PollNode_LeaseStale(t *testing.T) {
+	_, conf := StubsInit()
+	now := time.Now()
+	lease := func(name string, renewed time.Time) *coordinationv1beta1.Lease {
+		return &coordinationv1beta1.Lease{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: nodeLeaseNamespace},
+			Spec:       coordinationv1beta1.LeaseSpec{RenewTime: &metav1.MicroTime{Time: renewed}},
+		}
+	}
+	client := fake.NewSimpleClientset(
+		readyNode("fresh", corev1.ConditionTrue),
+		readyNode("stale", corev1.ConditionTrue),
+		readyNode("down", corev1.ConditionUnknown),
+		readyNode("leaseless", corev1.ConditionTrue),
+		lease("fresh", now.Add(-5*time.Second)),
+		lease("stale", now.Add(-90*time.Second)),
+		lease("down", now.Add(-10*time.Minute)),
+	)
+
+	var messages []string
+	alertStub := func(_ string, _ string, alert Alert, _ AlertersConfig) {
+		messages = append(messages, alert.Key+": "+alert.Message)
+	}
+	assert.NoError(t, PollNode(client, NodeAlertSpec{Name: "*", ReportStatus: NodeAlertStatus{LeaseThreshold: 40}}, defaultTickerTime, alertStub, conf))
+	assert.ElementsMatch(t, []string{
+		"node/stale/LeaseStale: Node stale has not renewed its Lease for 1m30s, longer than 40s! It is still Ready, but its kubelet may have stopped heartbeating.",
+		"node/down/LeaseStale: Node down has not renewed its Lease for 10m0s, longer than 40s! Its kubelet may have stopped heartbeating.",
+	}, messages)
+
+	messages = nil
+	assert.NoError(t, PollNode(client, NodeAlertSpec{Name: "fresh", ReportStatus: NodeAlertStatus{LeaseThreshold: 40}}, defaultTickerTime, alertStub, conf))
+	assert.Empty(t, messages)
+}
+
+func Test_checkNode_MoreConditions(t *testing.T) {
+	_, conf := StubsInit()
+	now := time.Unix(100000, 0)
+	changed := metav1.Time{Time: now.Add(-10 * time.Second)}
+	node := readyNode("worker-1", corev1.ConditionTrue)
+	node.CreationTimestamp = metav1.Time{Time: now.Add(-time.Hour)}
+	node.Status.Conditions = []corev1.NodeCondition{
+		{Type: corev1.NodeReady, Status: corev1.ConditionTrue},
+		{Type: "KernelDeadlock", Status: corev1.ConditionTrue, LastTransitionTime: changed, Reason: "DockerHung", Message: "task docker:7 blocked for more than 120 seconds."},
+		{Type: corev1.NodePIDPressure, Status: corev1.ConditionTrue, LastTransitionTime: changed},
+		{Type: corev1.NodeNetworkUnavailable, Status: corev1.ConditionTrue, LastTransitionTime: changed},
+	}
+	tests := []struct {
+		name     string
+		status   NodeAlertStatus
+		expected []string
+	}{
+		{name: "none enabled"},
+		{
+			name:     "custom condition",
+			status:   NodeAlertStatus{CustomConditions: []string{"KernelDeadlock"}},
+			expected: []string{"node/worker-1/KernelDeadlock: Node worker-1 has changed KernelDeadlock status to True since last poll: DockerHung, task docker:7 blocked for more than 120 seconds."},
+		},
+		{
+			name:     "pid pressure",
+			status:   NodeAlertStatus{NodePIDPressure: true},
+			expected: []string{"node/worker-1/PIDPressure: Node worker-1 has changed PIDPressure status since last poll and may be running out of process IDs!"},
+		},
+		{
+			name:     "network unavailable",
+			status:   NodeAlertStatus{NodeNetworkUnavailable: true},
+			expected: []string{"node/worker-1/NetworkUnavailable: Node worker-1 has changed NetworkUnavailable status since last poll and its pod network may be down!"},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(subT *testing.T) {
+			var alerts []string
+			alertStub := func(_ string, _ string, alert Alert, _ AlertersConfig) {
+				alerts = append(alerts, alert.Key+": "+alert.Message)
+			}
+			test.status.PendingThreshold = defaultPendingThreshold
+			checkNode(node, NodeAlertSpec{Name: "worker-1", ReportStatus: test.status}, defaultTickerTime, now, alertStub, conf)
+			assert.Equal(subT, test.expected, alerts)
+		})
+	}
+}
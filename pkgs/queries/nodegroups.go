@@ -0,0 +1,105 @@
+// Copyright 2019 Bloomberg Finance LP
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package queries
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/bloomberg/k8eraid/pkgs/types"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+// checkNodeGroupBalance counts the Ready, schedulable nodes of every group of nodes sharing a label
+// value, e.g. a zone. A group left without any is lost, which gets its own critical alert: losing a
+// whole zone is an outage waiting for the next failure even while the node count looks healthy.
+// Nodes without the label belong to no group and are left out.
+func checkNodeGroupBalance(
+	nodes []corev1.Node,
+	alertSpec types.NodeAlertSpec,
+	alertFn alertFunction,
+	alertersConfig types.AlertersConfig,
+) {
+	balance := alertSpec.ReportStatus.GroupBalance
+	if balance.MinPerGroup <= 0 && balance.MaxRatio <= 0 && len(balance.Groups) == 0 {
+		return
+	}
+	if balance.Label == "" {
+		balance.Label = defaultZoneLabel
+	}
+	resource := resourceID("nodes", "", alertSpec.NodeFilter)
+
+	usable := map[string]int{}
+	listed := map[string]int{}
+	for _, group := range balance.Groups {
+		usable[group] = 0
+	}
+	for i := range nodes {
+		group, labelled := nodes[i].Labels[balance.Label]
+		if !labelled {
+			continue
+		}
+		listed[group]++
+		if _, seen := usable[group]; !seen {
+			usable[group] = 0
+		}
+		if nodeReady(&nodes[i]) && !nodes[i].Spec.Unschedulable {
+			usable[group]++
+		}
+	}
+	groups := spreadBreakdown(usable)
+	if len(groups) == 0 {
+		return
+	}
+
+	for _, group := range groups {
+		if usable[group] == 0 {
+			// ALERT
+			alertmessage := fmt.Sprintf(
+				"Node group %s=%s with filter %s has no Ready, schedulable node left (%d listed)! The whole group appears lost.",
+				balance.Label, group, alertSpec.NodeFilter, listed[group],
+			)
+			alertFn(alertSpec.AlerterType, alertSpec.AlerterName, newAlert(resource, "GroupLost/"+group, types.SeverityCritical, alertmessage), alertersConfig)
+		} else if usable[group] < balance.MinPerGroup {
+			// ALERT
+			alertmessage := fmt.Sprintf(
+				"Node group %s=%s with filter %s has %d Ready, schedulable nodes of %d listed, under minimum specification of %d!",
+				balance.Label, group, alertSpec.NodeFilter, usable[group], listed[group], balance.MinPerGroup,
+			)
+			alertFn(alertSpec.AlerterType, alertSpec.AlerterName, newAlert(resource, "GroupMinNodes/"+group, types.SeverityCritical, alertmessage), alertersConfig)
+		}
+	}
+
+	// Lost groups are alerted on above, the ratio is only meaningful between groups that have nodes
+	largest, smallest := groups[0], groups[len(groups)-1]
+	if balance.MaxRatio <= 0 || usable[smallest] == 0 {
+		return
+	}
+	ratio := float64(usable[largest]) / float64(usable[smallest])
+	if ratio <= balance.MaxRatio {
+		return
+	}
+	parts := make([]string, len(groups))
+	for i, group := range groups {
+		parts[i] = fmt.Sprintf("%s=%d", group, usable[group])
+	}
+	// ALERT
+	alertmessage := fmt.Sprintf(
+		"Node groups by %s with filter %s are out of balance: %s has %.1f times the Ready, schedulable nodes of %s, more than the %.1f allowed! Nodes per group: %s",
+		balance.Label, alertSpec.NodeFilter, largest, ratio, smallest, balance.MaxRatio, strings.Join(parts, ", "),
+	)
+	alertFn(alertSpec.AlerterType, alertSpec.AlerterName, newAlert(resource, "GroupBalance", types.SeverityWarning, alertmessage), alertersConfig)
+}
@@ -0,0 +1,90 @@
+// Copyright 2019 Bloomberg Finance LP
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package queries
+
+import (
+	"testing"
+
+	. "github.com/bloomberg/k8eraid/pkgs/types"
+
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+)
+
+func Test_checkNodeGroupBalance(t *testing.T) {
+	_, conf := StubsInit()
+	zonedNode := func(name string, zone string, status corev1.ConditionStatus) corev1.Node {
+		node := readyNode(name, status)
+		node.Labels = map[string]string{defaultZoneLabel: zone}
+		return *node
+	}
+	cordoned := zonedNode("b-2", "zone-b", corev1.ConditionTrue)
+	cordoned.Spec.Unschedulable = true
+	nodes := []corev1.Node{
+		zonedNode("a-1", "zone-a", corev1.ConditionTrue),
+		zonedNode("a-2", "zone-a", corev1.ConditionTrue),
+		zonedNode("a-3", "zone-a", corev1.ConditionTrue),
+		zonedNode("a-4", "zone-a", corev1.ConditionTrue),
+		zonedNode("b-1", "zone-b", corev1.ConditionTrue),
+		cordoned,
+		zonedNode("c-1", "zone-c", corev1.ConditionFalse),
+		*readyNode("unzoned", corev1.ConditionTrue),
+	}
+
+	tests := []struct {
+		name     string
+		balance  NodeGroupBalance
+		nodes    []corev1.Node
+		keys     []string
+		messages []string
+	}{
+		{name: "disabled", nodes: nodes},
+		{
+			name:    "lost and small groups",
+			balance: NodeGroupBalance{MinPerGroup: 2, Groups: []string{"zone-d"}},
+			nodes:   nodes,
+			keys:    []string{"nodes/GroupMinNodes/zone-b", "nodes/GroupLost/zone-c", "nodes/GroupLost/zone-d"},
+			messages: []string{
+				"Node group failure-domain.beta.kubernetes.io/zone=zone-b with filter  has 1 Ready, schedulable nodes of 2 listed, under minimum specification of 2!",
+				"Node group failure-domain.beta.kubernetes.io/zone=zone-c with filter  has no Ready, schedulable node left (1 listed)! The whole group appears lost.",
+				"Node group failure-domain.beta.kubernetes.io/zone=zone-d with filter  has no Ready, schedulable node left (0 listed)! The whole group appears lost.",
+			},
+		},
+		{
+			name:    "imbalance",
+			balance: NodeGroupBalance{MaxRatio: 2},
+			nodes:   nodes[:6],
+			keys:    []string{"nodes/GroupBalance"},
+			messages: []string{
+				"Node groups by failure-domain.beta.kubernetes.io/zone with filter  are out of balance: zone-a has 4.0 times the Ready, schedulable nodes of zone-b, more than the 2.0 allowed! Nodes per group: zone-a=4, zone-b=1",
+			},
+		},
+		{name: "balanced enough", balance: NodeGroupBalance{MaxRatio: 4}, nodes: nodes[:6]},
+		{name: "other label", balance: NodeGroupBalance{Label: "pool", MinPerGroup: 1}, nodes: nodes},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(subT *testing.T) {
+			var keys, messages []string
+			alertStub := func(_ string, _ string, alert Alert, _ AlertersConfig) {
+				keys = append(keys, alert.Key)
+				messages = append(messages, alert.Message)
+			}
+			alertSpec := NodeAlertSpec{Name: "*", ReportStatus: NodeAlertStatus{GroupBalance: test.balance}}
+			checkNodeGroupBalance(test.nodes, alertSpec, alertStub, conf)
+			assert.Equal(subT, test.keys, keys)
+			assert.Equal(subT, test.messages, messages)
+		})
+	}
+}
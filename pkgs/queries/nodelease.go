@@ -0,0 +1,78 @@
+// Copyright 2019 Bloomberg Finance LP
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package queries
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/bloomberg/k8eraid/pkgs/types"
+
+	coordinationv1beta1 "k8s.io/api/coordination/v1beta1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// nodeLeaseNamespace holds the Lease every kubelet renews as its heartbeat, named after its node
+const nodeLeaseNamespace = "kube-node-lease"
+
+// nodeLeases returns the node Leases by node name
+func nodeLeases(clientset kubernetes.Interface) (map[string]*coordinationv1beta1.Lease, error) {
+	list, listerr := clientset.CoordinationV1beta1().Leases(nodeLeaseNamespace).List(metav1.ListOptions{TimeoutSeconds: &timeout})
+	if listerr != nil {
+		return nil, &PollErr{
+			Message: fmt.Sprintf("Unable to list node Leases: %s", listerr.Error()),
+		}
+	}
+	leases := map[string]*coordinationv1beta1.Lease{}
+	for i := range list.Items {
+		leases[list.Items[i].Name] = &list.Items[i]
+	}
+	return leases, nil
+}
+
+// checkNodeLease alerts on a node whose kubelet has not renewed its Lease for longer than the
+// threshold. Kubelets renew it every 10 seconds by default, so a stale Lease shows a kubelet that
+// stopped heartbeating well before the node controller marks the node NotReady. Nodes without a
+// Lease, such as on clusters without the NodeLease feature, are skipped.
+func checkNodeLease(
+	node *corev1.Node,
+	leases map[string]*coordinationv1beta1.Lease,
+	alertSpec types.NodeAlertSpec,
+	now time.Time,
+	alertFn alertFunction,
+	alertersConfig types.AlertersConfig,
+) {
+	threshold := alertSpec.ReportStatus.LeaseThreshold
+	lease, found := leases[node.Name]
+	if threshold <= 0 || !found || lease.Spec.RenewTime == nil {
+		return
+	}
+	staleFor := now.Sub(lease.Spec.RenewTime.Time)
+	if staleFor <= time.Duration(threshold)*time.Second {
+		return
+	}
+	hint := "Its kubelet may have stopped heartbeating."
+	if nodeReady(node) {
+		hint = "It is still Ready, but its kubelet may have stopped heartbeating."
+	}
+	// ALERT
+	alertmessage := fmt.Sprintf(
+		"Node %s has not renewed its Lease for %s, longer than %ds! %s",
+		node.Name, staleFor.Truncate(time.Second), threshold, hint,
+	)
+	alertFn(alertSpec.AlerterType, alertSpec.AlerterName, newAlert(resourceID("node", "", node.Name), "LeaseStale", types.SeverityCritical, alertmessage), alertersConfig)
+}
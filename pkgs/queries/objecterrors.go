@@ -0,0 +1,62 @@
+// Copyright 2019 Bloomberg Finance LP
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package queries
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/bloomberg/k8eraid/pkgs/metrics"
+	"github.com/bloomberg/k8eraid/pkgs/types"
+)
+
+var objectErrorsCounter = metrics.NewCounterVec(
+	"k8eraid_poll_object_errors_total",
+	"Objects matched by a wildcard rule that could not be fetched or checked.",
+	"kind",
+)
+
+// objectErrors applies a wildcard rule's onObjectError policy to the errors of the objects it matched
+type objectErrors struct {
+	kind      string
+	keepGoing bool
+	messages  []string
+}
+
+func newObjectErrors(kind string, policy string) *objectErrors {
+	return &objectErrors{kind: kind, keepGoing: policy == types.OnObjectErrorContinue}
+}
+
+// handle counts and logs err. It returns err when the rule aborts on object errors, and nil when
+// the remaining objects should still be checked.
+func (e *objectErrors) handle(err error) error {
+	objectErrorsCounter.With(e.kind).Inc()
+	if !e.keepGoing {
+		return err
+	}
+	errLogger.Printf("%s, checking the remaining %ss", err.Error(), e.kind)
+	e.messages = append(e.messages, err.Error())
+	return nil
+}
+
+// err sums up the errors the rule continued past, so the poll still reports them
+func (e *objectErrors) err() error {
+	if len(e.messages) == 0 {
+		return nil
+	}
+	return &PollErr{
+		Message: fmt.Sprintf("%d %ss could not be checked: %s", len(e.messages), e.kind, strings.Join(e.messages, "; ")),
+	}
+}
@@ -0,0 +1,226 @@
+// Copyright 2019 Bloomberg Finance LP
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package queries
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/bloomberg/k8eraid/pkgs/alerters"
+	"github.com/bloomberg/k8eraid/pkgs/types"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// ownerKinds are the resource types of the alerts about a single object whose owners can be looked
+// up, mapped to whether the object is namespaced
+var ownerKinds = map[string]bool{
+	"node":                    false,
+	"persistentvolume":        false,
+	"namespace":               false,
+	"pod":                     true,
+	"pvc":                     true,
+	"resourcequota":           true,
+	"secret":                  true,
+	"configmap":               true,
+	"deployment":              true,
+	"daemonset":               true,
+	"statefulset":             true,
+	"replicaset":              true,
+	"job":                     true,
+	"cronjob":                 true,
+	"service":                 true,
+	"ingress":                 true,
+	"horizontalpodautoscaler": true,
+	"poddisruptionbudget":     true,
+}
+
+// ownedObjectGetters fetch the metadata of objects by the resource type of their alerts. Secrets are
+// not fetched, since that returns their contents, so only their namespace names their owners.
+var ownedObjectGetters = map[string]func(clientset kubernetes.Interface, namespace string, name string) (metav1.Object, error){
+	"node": func(clientset kubernetes.Interface, _ string, name string) (metav1.Object, error) {
+		return clientset.CoreV1().Nodes().Get(name, metav1.GetOptions{})
+	},
+	"persistentvolume": func(clientset kubernetes.Interface, _ string, name string) (metav1.Object, error) {
+		return clientset.CoreV1().PersistentVolumes().Get(name, metav1.GetOptions{})
+	},
+	"namespace": func(clientset kubernetes.Interface, _ string, name string) (metav1.Object, error) {
+		return clientset.CoreV1().Namespaces().Get(name, metav1.GetOptions{})
+	},
+	"pod": func(clientset kubernetes.Interface, namespace string, name string) (metav1.Object, error) {
+		return clientset.CoreV1().Pods(namespace).Get(name, metav1.GetOptions{})
+	},
+	"pvc": func(clientset kubernetes.Interface, namespace string, name string) (metav1.Object, error) {
+		return clientset.CoreV1().PersistentVolumeClaims(namespace).Get(name, metav1.GetOptions{})
+	},
+	"resourcequota": func(clientset kubernetes.Interface, namespace string, name string) (metav1.Object, error) {
+		return clientset.CoreV1().ResourceQuotas(namespace).Get(name, metav1.GetOptions{})
+	},
+	"configmap": func(clientset kubernetes.Interface, namespace string, name string) (metav1.Object, error) {
+		return clientset.CoreV1().ConfigMaps(namespace).Get(name, metav1.GetOptions{})
+	},
+	"deployment": func(clientset kubernetes.Interface, namespace string, name string) (metav1.Object, error) {
+		return clientset.AppsV1().Deployments(namespace).Get(name, metav1.GetOptions{})
+	},
+	"daemonset": func(clientset kubernetes.Interface, namespace string, name string) (metav1.Object, error) {
+		return clientset.AppsV1().DaemonSets(namespace).Get(name, metav1.GetOptions{})
+	},
+	"statefulset": func(clientset kubernetes.Interface, namespace string, name string) (metav1.Object, error) {
+		return clientset.AppsV1().StatefulSets(namespace).Get(name, metav1.GetOptions{})
+	},
+	"job": func(clientset kubernetes.Interface, namespace string, name string) (metav1.Object, error) {
+		return clientset.BatchV1().Jobs(namespace).Get(name, metav1.GetOptions{})
+	},
+	"replicaset": func(clientset kubernetes.Interface, namespace string, name string) (metav1.Object, error) {
+		return clientset.AppsV1().ReplicaSets(namespace).Get(name, metav1.GetOptions{})
+	},
+	"cronjob": func(clientset kubernetes.Interface, namespace string, name string) (metav1.Object, error) {
+		return clientset.BatchV1beta1().CronJobs(namespace).Get(name, metav1.GetOptions{})
+	},
+	"service": func(clientset kubernetes.Interface, namespace string, name string) (metav1.Object, error) {
+		return clientset.CoreV1().Services(namespace).Get(name, metav1.GetOptions{})
+	},
+	"ingress": func(clientset kubernetes.Interface, namespace string, name string) (metav1.Object, error) {
+		return clientset.ExtensionsV1beta1().Ingresses(namespace).Get(name, metav1.GetOptions{})
+	},
+	"horizontalpodautoscaler": func(clientset kubernetes.Interface, namespace string, name string) (metav1.Object, error) {
+		return clientset.AutoscalingV2beta2().HorizontalPodAutoscalers(namespace).Get(name, metav1.GetOptions{})
+	},
+	"poddisruptionbudget": func(clientset kubernetes.Interface, namespace string, name string) (metav1.Object, error) {
+		return clientset.PolicyV1beta1().PodDisruptionBudgets(namespace).Get(name, metav1.GetOptions{})
+	},
+}
+
+// ObjectCache fetches the objects alerts are about once, for the alerts of one tick. It is safe for
+// concurrent use by the jobs of the tick.
+type ObjectCache struct {
+	clientset kubernetes.Interface
+	mu        sync.Mutex
+	objects   map[string]cachedObject
+}
+
+type cachedObject struct {
+	object metav1.Object
+	err    error
+}
+
+// NewObjectCache returns an empty ObjectCache fetching objects with clientset
+func NewObjectCache(clientset kubernetes.Interface) *ObjectCache {
+	return &ObjectCache{clientset: clientset, objects: map[string]cachedObject{}}
+}
+
+// get returns the object of one of ownedObjectGetters' resource types, fetching it unless it, or the
+// failure to fetch it, is cached
+func (c *ObjectCache) get(kind string, namespace string, name string) (metav1.Object, error) {
+	id := resourceID(kind, namespace, name)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if cached, ok := c.objects[id]; ok {
+		return cached.object, cached.err
+	}
+	object, err := ownedObjectGetters[kind](c.clientset, namespace, name)
+	c.objects[id] = cachedObject{object: object, err: err}
+	return object, err
+}
+
+// AnnotateOwners returns an alerters.Middleware adding the ownership labels and annotations of the
+// object alerted on, or of its namespace, to the alert and its message. Alerts about objects that
+// cannot be fetched, or about no single object, are passed on as they are.
+func AnnotateOwners(objects *ObjectCache, ownership types.Ownership) alerters.Middleware {
+	return func(next alerters.Alerter) alerters.Alerter {
+		if len(ownership.Keys) == 0 && ownership.SlackMentionKey == "" {
+			return next
+		}
+		return alerters.AlerterFunc(func(alerterType string, alerterName string, alert types.Alert, alertersConfig types.AlertersConfig) {
+			metadata := ownerMetadata(objects, alert.Resource)
+			if len(metadata) > 0 {
+				var described []string
+				for _, key := range ownership.Keys {
+					if value, ok := ownerValue(metadata, key); ok {
+						if alert.Owners == nil {
+							alert.Owners = map[string]string{}
+						}
+						alert.Owners[key] = value
+						described = append(described, key+"="+value)
+					}
+				}
+				if len(described) > 0 {
+					alert.Message = fmt.Sprintf("%s (owners: %s)", alert.Message, strings.Join(described, ", "))
+				}
+				if ownership.SlackMentionKey != "" {
+					alert.Mention, _ = ownerValue(metadata, ownership.SlackMentionKey)
+				}
+			}
+			next.Alert(alerterType, alerterName, alert, alertersConfig)
+		})
+	}
+}
+
+// ownerMetadata returns the metadata owners are looked up in, the object of resource first and its
+// namespace last
+func ownerMetadata(objects *ObjectCache, resource string) []metav1.Object {
+	kind, namespace, name, ok := alertedObject(resource)
+	if !ok {
+		return nil
+	}
+
+	var metadata []metav1.Object
+	if _, ok := ownedObjectGetters[kind]; ok {
+		object, objecterr := objects.get(kind, namespace, name)
+		if objecterr != nil {
+			errLogger.Printf("Unable to get %s to look up its owners: %s", resource, objecterr.Error())
+		} else {
+			metadata = append(metadata, object)
+		}
+	}
+	if namespace != "" {
+		ns, nserr := objects.get("namespace", "", namespace)
+		if nserr != nil {
+			errLogger.Printf("Unable to get namespace %s to look up the owners of %s: %s", namespace, resource, nserr.Error())
+		} else {
+			metadata = append(metadata, ns)
+		}
+	}
+	return metadata
+}
+
+// alertedObject splits the resource of an alert about a single object of one of ownerKinds into the
+// resource type, namespace and name of the object
+func alertedObject(resource string) (string, string, string, bool) {
+	parts := strings.Split(resource, "/")
+	namespaced, ok := ownerKinds[parts[0]]
+	switch {
+	case ok && namespaced && len(parts) == 3:
+		return parts[0], parts[1], parts[2], true
+	case ok && !namespaced && len(parts) == 2:
+		return parts[0], "", parts[1], true
+	}
+	return "", "", "", false
+}
+
+// ownerValue looks key up in the labels, then the annotations, of each of metadata in turn
+func ownerValue(metadata []metav1.Object, key string) (string, bool) {
+	for _, object := range metadata {
+		if value, ok := object.GetLabels()[key]; ok && value != "" {
+			return value, true
+		}
+		if value, ok := object.GetAnnotations()[key]; ok && value != "" {
+			return value, true
+		}
+	}
+	return "", false
+}
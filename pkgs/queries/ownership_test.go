@@ -0,0 +1,120 @@
+// Copyright 2019 Bloomberg Finance LP
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package queries
+
+import (
+	"testing"
+
+	"github.com/bloomberg/k8eraid/pkgs/alerters"
+	. "github.com/bloomberg/k8eraid/pkgs/types"
+
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+	clienttesting "k8s.io/client-go/testing"
+)
+
+func Test_AnnotateOwners(t *testing.T) {
+	_, conf := StubsInit()
+	client := fake.NewSimpleClientset(
+		&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{
+			Name:        "payments",
+			Labels:      map[string]string{"team": "payments"},
+			Annotations: map[string]string{"slack-channel": "#payments-oncall"},
+		}},
+		&corev1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "api-0", Namespace: "payments", Labels: map[string]string{"owner": "alice"}}},
+		&corev1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "batch-0", Namespace: "payments", Labels: map[string]string{"team": "batch"}}},
+		&corev1.Node{ObjectMeta: metav1.ObjectMeta{Name: "worker-1", Annotations: map[string]string{"owner": "platform"}}},
+	)
+	ownership := Ownership{Keys: []string{"team", "owner"}, SlackMentionKey: "slack-channel"}
+
+	tests := []struct {
+		name     string
+		alert    Alert
+		owners   map[string]string
+		mention  string
+		expected string
+	}{
+		{
+			name:     "object and namespace labels",
+			alert:    newAlert("pod/payments/api-0", "Ready", SeverityCritical, "api-0 is not ready"),
+			owners:   map[string]string{"team": "payments", "owner": "alice"},
+			mention:  "#payments-oncall",
+			expected: "api-0 is not ready (owners: team=payments, owner=alice)",
+		},
+		{
+			name:     "object wins over namespace",
+			alert:    newAlert("pod/payments/batch-0", "Ready", SeverityCritical, "batch-0 is not ready"),
+			owners:   map[string]string{"team": "batch"},
+			mention:  "#payments-oncall",
+			expected: "batch-0 is not ready (owners: team=batch)",
+		},
+		{
+			name:     "gone object still gets its namespace owners",
+			alert:    newAlert("pod/payments/api-1", "Ready", SeverityCritical, "api-1 is not ready"),
+			owners:   map[string]string{"team": "payments"},
+			mention:  "#payments-oncall",
+			expected: "api-1 is not ready (owners: team=payments)",
+		},
+		{
+			name:     "cluster scoped annotation",
+			alert:    newAlert("node/worker-1", "Ready", SeverityCritical, "worker-1 is not ready"),
+			owners:   map[string]string{"owner": "platform"},
+			expected: "worker-1 is not ready (owners: owner=platform)",
+		},
+		{
+			name:     "not about a single object",
+			alert:    newAlert("nodes/pool=workers", "MinNodes", SeverityCritical, "too few nodes"),
+			expected: "too few nodes",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(subT *testing.T) {
+			var annotated Alert
+			alerter := AnnotateOwners(NewObjectCache(client), ownership)(alerters.AlerterFunc(func(_ string, _ string, alert Alert, _ AlertersConfig) {
+				annotated = alert
+			}))
+			alerter.Alert("slack", "oncall", test.alert, conf)
+			assert.Equal(subT, test.owners, annotated.Owners)
+			assert.Equal(subT, test.mention, annotated.Mention)
+			assert.Equal(subT, test.expected, annotated.Message)
+		})
+	}
+}
+
+func Test_ObjectCache(t *testing.T) {
+	_, conf := StubsInit()
+	client := fake.NewSimpleClientset(
+		&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "payments", Labels: map[string]string{"team": "payments"}}},
+		&corev1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "api-0", Namespace: "payments"}},
+	)
+	gets := map[string]int{}
+	client.PrependReactor("get", "*", func(action clienttesting.Action) (bool, runtime.Object, error) {
+		gets[action.GetResource().Resource]++
+		return false, nil, nil
+	})
+
+	objects := NewObjectCache(client)
+	alertFn := alerters.Chain(alerters.AlerterFunc(func(_ string, _ string, _ Alert, _ AlertersConfig) {}),
+		SilenceAnnotated(objects, Silencing{AnnotationKey: "k8eraid.io/silence-until"}),
+		AnnotateOwners(objects, Ownership{Keys: []string{"team"}}),
+	).Alert
+	for _, resource := range []string{"pod/payments/api-0", "pod/payments/api-0", "pod/payments/gone", "pod/payments/gone"} {
+		alertFn("stderr", "", newAlert(resource, "Ready", SeverityCritical, "not ready"), conf)
+	}
+	assert.Equal(t, map[string]int{"pods": 2, "namespaces": 1}, gets, "each object should be fetched once, found or not")
+}
@@ -0,0 +1,116 @@
+// Copyright 2019 Bloomberg Finance LP
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package queries
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/bloomberg/k8eraid/pkgs/types"
+
+	policyv1beta1 "k8s.io/api/policy/v1beta1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// PollPDB function takes inputs and iterates across PodDisruptionBudgets in the kubernetes cluster, triggering alerts as needed.
+func PollPDB(
+	clientset kubernetes.Interface,
+	alertSpec types.PDBAlertSpec,
+	tickertime int64,
+	alertFn alertFunction,
+	alertersConfig types.AlertersConfig,
+) error {
+	var pdbs []policyv1beta1.PodDisruptionBudget
+
+	// Check rules with matching literal budget name
+	if alertSpec.Name != "*" {
+		if alertSpec.PDBFilterNamespace == "" {
+			return &PollErr{
+				Message: fmt.Sprintf("PodDisruptionBudget rule for %s has no namespace filter specified, ignoring", alertSpec.Name),
+			}
+		}
+		pdb, pdberr := clientset.PolicyV1beta1().PodDisruptionBudgets(alertSpec.PDBFilterNamespace).Get(alertSpec.Name, metav1.GetOptions{})
+		if pdberr != nil {
+			return &PollErr{
+				Message: fmt.Sprintf("Error fetching PodDisruptionBudget %s: %s", alertSpec.Name, pdberr.Error()),
+			}
+		}
+		pdbs = append(pdbs, *pdb)
+		// If the budget name is a wildcard, list based on filter and iterate through
+	} else {
+		list, listerr := clientset.PolicyV1beta1().PodDisruptionBudgets(alertSpec.PDBFilterNamespace).List(metav1.ListOptions{
+			LabelSelector:  alertSpec.PDBFilterLabel,
+			TimeoutSeconds: &timeout,
+		})
+		if listerr != nil {
+			return &PollErr{
+				Message: fmt.Sprintf("Unable to list PodDisruptionBudgets: %s", listerr.Error()),
+			}
+		}
+		pdbs = list.Items
+	}
+
+	now := time.Now()
+	for i := range pdbs {
+		checkPDB(&pdbs[i], alertSpec, now, alertFn, alertersConfig)
+	}
+	return nil
+}
+
+// checkPDB alerts on a budget with fewer healthy pods than it wants, and on one that has allowed no
+// disruptions for longer than the threshold, which stalls node drains and cluster upgrades. Budgets
+// report nothing of since when they allow no disruptions, so that is remembered across ticks.
+// Budgets matching no pods block nothing and are skipped.
+func checkPDB(
+	pdb *policyv1beta1.PodDisruptionBudget,
+	alertSpec types.PDBAlertSpec,
+	now time.Time,
+	alertFn alertFunction,
+	alertersConfig types.AlertersConfig,
+) {
+	resource := resourceID("poddisruptionbudget", pdb.Namespace, pdb.Name)
+	status := pdb.Status
+	matched := status.ExpectedPods > 0
+
+	unhealthy := matched && status.CurrentHealthy < status.DesiredHealthy
+	if alertSpec.ReportStatus.Unhealthy && unhealthy {
+		// ALERT
+		alertmessage := fmt.Sprintf(
+			"PodDisruptionBudget %s/%s has %d healthy pods of %d, fewer than the %d it wants! Its pods are already disrupted beyond its budget.",
+			pdb.Namespace, pdb.Name, status.CurrentHealthy, status.ExpectedPods, status.DesiredHealthy,
+		)
+		alert := newAlert(resource, "Unhealthy", types.SeverityCritical, alertmessage)
+		alertFn(alertSpec.AlerterType, alertSpec.AlerterName, alert, alertersConfig)
+	}
+
+	threshold := alertSpec.ReportStatus.NoDisruptionsThreshold
+	blocked := threshold > 0 && matched && status.PodDisruptionsAllowed == 0
+	blockedFor := unhealthyFor("nodisruptions/"+resource, blocked, pdb.CreationTimestamp.Time, now)
+	if !blocked || blockedFor <= time.Duration(threshold)*time.Second {
+		return
+	}
+	cause := fmt.Sprintf("%d of its %d pods are healthy, and it wants %d.", status.CurrentHealthy, status.ExpectedPods, status.DesiredHealthy)
+	if !unhealthy && status.DesiredHealthy >= status.ExpectedPods {
+		cause = fmt.Sprintf("It wants all of its %d pods healthy, so it allows no disruption even when they are.", status.ExpectedPods)
+	}
+	// ALERT
+	alertmessage := fmt.Sprintf(
+		"PodDisruptionBudget %s/%s has allowed no disruptions for %s, longer than %ds! Draining the nodes of its pods will stall. %s",
+		pdb.Namespace, pdb.Name, formatLongDuration(blockedFor), threshold, cause,
+	)
+	alert := newAlert(resource, "NoDisruptionsAllowed", types.SeverityWarning, alertmessage)
+	alertFn(alertSpec.AlerterType, alertSpec.AlerterName, alert, alertersConfig)
+}
@@ -0,0 +1,104 @@
+// Copyright 2019 Bloomberg Finance LP
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package queries
+
+import (
+	"testing"
+	"time"
+
+	"github.com/bloomberg/k8eraid/pkgs/state"
+	. "github.com/bloomberg/k8eraid/pkgs/types"
+
+	"github.com/stretchr/testify/assert"
+	policyv1beta1 "k8s.io/api/policy/v1beta1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func disruptionBudget(name string, allowed int32, healthy int32, desired int32, expected int32) policyv1beta1.PodDisruptionBudget {
+	return policyv1beta1.PodDisruptionBudget{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: metav1.NamespaceDefault, Labels: map[string]string{"team": "web"}},
+		Status: policyv1beta1.PodDisruptionBudgetStatus{
+			PodDisruptionsAllowed: allowed,
+			CurrentHealthy:        healthy,
+			DesiredHealthy:        desired,
+			ExpectedPods:          expected,
+		},
+	}
+}
+
+func Test_checkPDB(t *testing.T) {
+	stateStore = state.NewStore()
+	_, conf := StubsInit()
+	alertSpec := PDBAlertSpec{ReportStatus: PDBAlertStatus{Unhealthy: true, NoDisruptionsThreshold: 600}}
+	pdbs := []policyv1beta1.PodDisruptionBudget{
+		disruptionBudget("api", 0, 2, 3, 4),
+		disruptionBudget("db", 0, 3, 3, 3),
+		disruptionBudget("idle", 0, 0, 0, 0),
+		disruptionBudget("web", 1, 4, 3, 4),
+	}
+	start := time.Unix(1000, 0)
+	check := func(after time.Duration) []string {
+		messages := []string{}
+		for i := range pdbs {
+			checkPDB(&pdbs[i], alertSpec, start.Add(after), func(_ string, _ string, alert Alert, _ AlertersConfig) {
+				messages = append(messages, alert.Key+": "+alert.Message)
+			}, conf)
+		}
+		return messages
+	}
+
+	assert.Equal(t, []string{
+		"poddisruptionbudget/default/api/Unhealthy: PodDisruptionBudget default/api has 2 healthy pods of 4, fewer than the 3 it wants! Its pods are already disrupted beyond its budget.",
+	}, check(0), "budgets first seen allowing no disruptions only just started to")
+	assert.Equal(t, []string{
+		"poddisruptionbudget/default/api/Unhealthy: PodDisruptionBudget default/api has 2 healthy pods of 4, fewer than the 3 it wants! Its pods are already disrupted beyond its budget.",
+		"poddisruptionbudget/default/api/NoDisruptionsAllowed: PodDisruptionBudget default/api has allowed no disruptions for 15m0s, longer than 600s! Draining the nodes of its pods will stall. 2 of its 4 pods are healthy, and it wants 3.",
+		"poddisruptionbudget/default/db/NoDisruptionsAllowed: PodDisruptionBudget default/db has allowed no disruptions for 15m0s, longer than 600s! Draining the nodes of its pods will stall. It wants all of its 3 pods healthy, so it allows no disruption even when they are.",
+	}, check(15*time.Minute), "budgets matching no pods block nothing")
+
+	pdbs[1].Status.PodDisruptionsAllowed = 1
+	check(16 * time.Minute)
+	pdbs[1].Status.PodDisruptionsAllowed = 0
+	assert.Len(t, check(20*time.Minute), 2, "a budget allowing disruptions again starts counting anew")
+}
+
+func Test_PollPDB(t *testing.T) {
+	stateStore = state.NewStore()
+	_, conf := StubsInit()
+	api := disruptionBudget("api", 0, 2, 3, 4)
+	web := disruptionBudget("web", 0, 1, 3, 4)
+	web.Labels = nil
+	client := fake.NewSimpleClientset(&api, &web)
+	alertStatus := PDBAlertStatus{Unhealthy: true}
+
+	for _, test := range []struct {
+		spec     PDBAlertSpec
+		expected []string
+	}{
+		{PDBAlertSpec{Name: "api", PDBFilterNamespace: metav1.NamespaceDefault, ReportStatus: alertStatus}, []string{"poddisruptionbudget/default/api/Unhealthy"}},
+		{PDBAlertSpec{Name: "*", PDBFilterLabel: "team=web", ReportStatus: alertStatus}, []string{"poddisruptionbudget/default/api/Unhealthy"}},
+	} {
+		keys := []string{}
+		err := PollPDB(client, test.spec, defaultTickerTime, func(_ string, _ string, alert Alert, _ AlertersConfig) {
+			keys = append(keys, alert.Key)
+		}, conf)
+		assert.NoError(t, err)
+		assert.Equal(t, test.expected, keys, test.spec.Name)
+	}
+
+	assert.Error(t, PollPDB(client, PDBAlertSpec{Name: "api"}, defaultTickerTime, nil, conf), "named rules need a namespace")
+	assert.Error(t, PollPDB(client, PDBAlertSpec{Name: "absent", PDBFilterNamespace: metav1.NamespaceDefault}, defaultTickerTime, nil, conf))
+}
@@ -16,15 +16,23 @@ package queries
 
 import (
 	"fmt"
+	"path"
+	"sort"
+	"strings"
 	"time"
 
+	"github.com/bloomberg/k8eraid/pkgs/state"
 	"github.com/bloomberg/k8eraid/pkgs/types"
 
 	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/client-go/kubernetes"
 )
 
+// nodeLostReason is the status reason the node controller gives the pods of an unreachable node
+const nodeLostReason = "NodeLost"
+
 // PollPod function takes inputs and iterates across pods in the kubernetes cluster, triggering alerts as needed.
 func PollPod(
 	clientset kubernetes.Interface,
@@ -34,8 +42,46 @@ func PollPod(
 	alertersConfig types.AlertersConfig,
 ) error {
 
-	if alertSpec.ReportStatus.PendingThreshold == 0 {
-		alertSpec.ReportStatus.PendingThreshold = 10
+	alertSpec = podRuleDefaults(alertSpec)
+
+	if alertSpec.ReportStatus.ReportDiff {
+		return diffPods(clientset, alertSpec, alertFn, alertersConfig)
+	}
+
+	// A configured address, e.g. of a Service, is probed once instead of every pod
+	if address := alertSpec.ReportStatus.GRPCHealth.Address; address != "" {
+		checkGRPCHealth(resourceID("grpc", "", address), address, address, alertSpec, alertFn, alertersConfig)
+	}
+
+	var lookups podLookups
+	// Nodes are listed once per poll rather than fetched for every pod
+	if alertSpec.ReportStatus.NodeLost {
+		var nodeserr error
+		if lookups.nodes, nodeserr = nodeNames(clientset); nodeserr != nil {
+			return nodeserr
+		}
+	}
+	// FailedScheduling events are listed once per poll rather than for every unscheduled pod
+	if alertSpec.ReportStatus.UnschedulableThreshold > 0 {
+		var eventserr error
+		if lookups.schedulingEvents, eventserr = failedSchedulingEvents(clientset, alertSpec.PodFilterNamespace); eventserr != nil {
+			return eventserr
+		}
+	}
+	// Pod metrics are listed once per poll rather than fetched for every pod
+	if podUsageChecked(alertSpec.ReportStatus.Usage) {
+		selector := alertSpec.PodFilterLabel
+		if alertSpec.Name != "*" {
+			selector = ""
+		}
+		var usageerr error
+		if lookups.usage, usageerr = podMetricsUsage(clientset, alertSpec.PodFilterNamespace, selector); usageerr != nil {
+			errLogger.Printf("Pod metrics unavailable, skipping the usage check of pod rule %s: %s", alertSpec.Name, usageerr.Error())
+		}
+	}
+	// LimitRanges are listed once per namespace the matched pods are in
+	if alertSpec.ReportStatus.LimitRangeViolations {
+		lookups.limitRanges = map[string][]corev1.LimitRange{}
 	}
 
 	// Check rules with matching literal pod name
@@ -52,7 +98,9 @@ func PollPod(
 				Message: fmt.Sprintf("error getting pod %s: %s", alertSpec.Name, poderr.Error()),
 			}
 		}
-		checkPod(pod, alertSpec, tickertime, alertFn, alertersConfig)
+		if err := checkPodObject(clientset, pod, lookups, alertSpec, tickertime, time.Now(), alertFn, alertersConfig); err != nil {
+			return err
+		}
 		// If podname is a wildcard, list based on filter and iterate through
 	} else {
 		listopts := metav1.ListOptions{
@@ -61,8 +109,8 @@ func PollPod(
 			Watch:                false,
 			TimeoutSeconds:       &timeout,
 		}
-		// Check rules by label
-		pods, podserr := clientset.CoreV1().Pods("").List(listopts)
+		// Check rules by label, within the namespace filter when one is set
+		pods, podserr := clientset.CoreV1().Pods(alertSpec.PodFilterNamespace).List(listopts)
 		if podserr != nil {
 			return &PollErr{
 				Message: fmt.Sprintf("error fetching pods: %s", podserr.Error()),
@@ -73,50 +121,161 @@ func PollPod(
 		if len(pods.Items) < int(alertSpec.ReportStatus.MinPods) {
 			// ALERT
 			alertmessage := fmt.Sprint("Number of pods for label", alertSpec.PodFilterLabel, "is under minimum specification!")
-			alertFn(alertSpec.AlerterType, alertSpec.AlerterName, alertmessage, alertersConfig)
+			alert := newAlert(resourceID("pods", "", alertSpec.PodFilterLabel), "MinPods", types.SeverityCritical, alertmessage)
+			alertFn(alertSpec.AlerterType, alertSpec.AlerterName, alert, alertersConfig)
 		}
+		checkEvictedPods(pods.Items, alertSpec, alertFn, alertersConfig)
 
 		// Iterate through pod items
+		objectErrs := newObjectErrors("pod", alertSpec.OnObjectError)
 		for _, poddata := range pods.Items {
 			pod, poderr := clientset.CoreV1().Pods(poddata.GetNamespace()).Get(poddata.GetName(), metav1.GetOptions{})
 			if poderr != nil {
-				return &PollErr{
+				if err := objectErrs.handle(&PollErr{
 					Message: fmt.Sprintf("Unable to get pod %s: %s", poddata.Name, poderr.Error()),
+				}); err != nil {
+					return err
 				}
+				continue
 			}
 
-			checkPod(pod, alertSpec, tickertime, alertFn, alertersConfig)
+			if err := checkPodObject(clientset, pod, lookups, alertSpec, tickertime, time.Now(), alertFn, alertersConfig); err != nil {
+				if err := objectErrs.handle(err); err != nil {
+					return err
+				}
+			}
 		}
+		return objectErrs.err()
 	}
 	return nil
 }
 
+// podLookups holds what a pod poll lists once for the checks of every pod it matches. A nil field
+// skips the checks that need it.
+type podLookups struct {
+	nodes            map[string]bool
+	schedulingEvents map[string]*corev1.Event
+	usage            map[string]corev1.ResourceList
+	limitRanges      map[string][]corev1.LimitRange
+}
+
+// podRuleDefaults fills in the settings alertSpec leaves unset
+func podRuleDefaults(alertSpec types.PodAlertSpec) types.PodAlertSpec {
+	if alertSpec.ReportStatus.PendingThreshold == 0 {
+		alertSpec.ReportStatus.PendingThreshold = defaultPendingThreshold
+	}
+	if alertSpec.ReportStatus.RestartWindow == 0 {
+		alertSpec.ReportStatus.RestartWindow = defaultRestartWindow
+	}
+	return alertSpec
+}
+
+// checkPodObject runs every check a pod rule makes on one pod, as of now. With a nil clientset the
+// gRPC health probe and the LimitRange check are skipped, so the pod can be checked without a
+// cluster.
+func checkPodObject(
+	clientset kubernetes.Interface,
+	pod *corev1.Pod,
+	lookups podLookups,
+	alertSpec types.PodAlertSpec,
+	tickertime int64,
+	now time.Time,
+	alertFn alertFunction,
+	alertersConfig types.AlertersConfig,
+) error {
+	checkPod(pod, alertSpec, tickertime, now, alertFn, alertersConfig)
+	if clientset != nil {
+		checkPodGRPCHealth(pod, alertSpec, alertFn, alertersConfig)
+	}
+	checkPodNodeLost(pod, lookups.nodes, alertSpec, alertFn, alertersConfig)
+	checkPodMissingLimits(pod, alertSpec, alertFn, alertersConfig)
+	checkPodImagePolicy(pod, alertSpec, alertFn, alertersConfig)
+	checkPodRestartCounts(pod, alertSpec, now, alertFn, alertersConfig)
+	checkPodOOMKills(pod, alertSpec, tickertime, now, alertFn, alertersConfig)
+	checkPodCrashLoops(pod, alertSpec, now, alertFn, alertersConfig)
+	checkPodImagePulls(pod, alertSpec, now, alertFn, alertersConfig)
+	checkPodUnschedulable(pod, lookups.schedulingEvents, alertSpec, now, alertFn, alertersConfig)
+	checkPodTerminating(pod, alertSpec, now, alertFn, alertersConfig)
+	checkPodOrphaned(pod, alertSpec, now, alertFn, alertersConfig)
+	checkPodUsage(pod, lookups.usage, alertSpec, now, alertFn, alertersConfig)
+	if clientset == nil {
+		return nil
+	}
+	return checkPodLimitRanges(clientset, pod, lookups.limitRanges, alertSpec, alertFn, alertersConfig)
+}
+
+// diffPods reports the pods that appeared, disappeared or changed phase, readiness or restarts since the previous tick
+func diffPods(
+	clientset kubernetes.Interface,
+	alertSpec types.PodAlertSpec,
+	alertFn alertFunction,
+	alertersConfig types.AlertersConfig,
+) error {
+	snapshot := state.Snapshot{}
+	if alertSpec.Name != "*" {
+		if alertSpec.PodFilterNamespace == "" {
+			return &PollErr{
+				Message: fmt.Sprintf("pod rule for %s has no namespace filter specified, ignoring\n", alertSpec.Name),
+			}
+		}
+		pod, poderr := clientset.CoreV1().Pods(alertSpec.PodFilterNamespace).Get(alertSpec.Name, metav1.GetOptions{})
+		if poderr == nil {
+			snapshot[resourceID("pod", pod.Namespace, pod.Name)] = podSummary(pod)
+		} else if !apierrors.IsNotFound(poderr) {
+			return &PollErr{
+				Message: fmt.Sprintf("error getting pod %s: %s", alertSpec.Name, poderr.Error()),
+			}
+		}
+	} else {
+		pods, podserr := clientset.CoreV1().Pods(alertSpec.PodFilterNamespace).List(metav1.ListOptions{
+			LabelSelector:  alertSpec.PodFilterLabel,
+			TimeoutSeconds: &timeout,
+		})
+		if podserr != nil {
+			return &PollErr{
+				Message: fmt.Sprintf("error fetching pods: %s", podserr.Error()),
+			}
+		}
+		for i := range pods.Items {
+			snapshot[resourceID("pod", pods.Items[i].Namespace, pods.Items[i].Name)] = podSummary(&pods.Items[i])
+		}
+	}
+	key := specKey("pod", alertSpec.Name, alertSpec.PodFilterNamespace+"/"+alertSpec.PodFilterLabel)
+	reportDiff(key, snapshot, alertSpec.AlerterType, alertSpec.AlerterName, alertFn, alertersConfig)
+	return nil
+}
+
 func checkPod(
 	pod *corev1.Pod,
 	alertSpec types.PodAlertSpec,
 	tickertime int64,
+	now time.Time,
 	alertFn alertFunction,
 	alertersConfig types.AlertersConfig,
 ) {
-	nowSeconds := time.Now().Unix()
+	nowSeconds := now.Unix()
 	// Get times for comparing to threshold
 	statusCreatedSecondsDiff := nowSeconds - pod.ObjectMeta.CreationTimestamp.Unix()
+	resource := resourceID("pod", pod.Namespace, pod.Name)
 
 	// If pod hasnt been around longer than threshold, bail. otherwise check the status.
 	if statusCreatedSecondsDiff > alertSpec.ReportStatus.PendingThreshold {
 		for _, condition := range pod.Status.Conditions {
 			if condition.Type == "Ready" {
-				transitiontimeDiff := time.Now().Unix() - condition.LastTransitionTime.Unix()
+				transitiontimeDiff := nowSeconds - condition.LastTransitionTime.Unix()
 				if transitiontimeDiff < tickertime && alertSpec.ReportStatus.PodRestarts {
 					// ALERT
 					alertmessage := fmt.Sprint("Pod", alertSpec.Name, "has changed ready status since last poll and may be restarting!")
-					alertFn(alertSpec.AlerterType, alertSpec.AlerterName, alertmessage, alertersConfig)
+					alert := newAlert(resource, "Ready", types.SeverityCritical, alertmessage)
+					recordDetection(&alert, "Ready", condition.LastTransitionTime.Time, now)
+					alertFn(alertSpec.AlerterType, alertSpec.AlerterName, alert, alertersConfig)
 				}
 			} else if condition.Type == "PodScheduled" {
 				if condition.Status != "True" && alertSpec.ReportStatus.FailedScheduling {
 					// ALERT
 					alertmessage := fmt.Sprint("Pod", alertSpec.Name, "has not been scheduled yet and has passed scheduling timeline!")
-					alertFn(alertSpec.AlerterType, alertSpec.AlerterName, alertmessage, alertersConfig)
+					alert := newAlert(resource, "PodScheduled", types.SeverityCritical, alertmessage)
+					alertFn(alertSpec.AlerterType, alertSpec.AlerterName, alert, alertersConfig)
 				}
 			}
 		}
@@ -136,7 +295,192 @@ func checkPod(
 		if deletionDeadline < nowSeconds && deletionDeadline > lastpollDiff {
 			// ALERT
 			alertmessage := fmt.Sprint("Pod", alertSpec.Name, "has passed its deletion timeline and may be stuck in terminating status!")
-			alertFn(alertSpec.AlerterType, alertSpec.AlerterName, alertmessage, alertersConfig)
+			alert := newAlert(resource, "StuckTerminating", types.SeverityWarning, alertmessage)
+			alertFn(alertSpec.AlerterType, alertSpec.AlerterName, alert, alertersConfig)
+		}
+	}
+}
+
+// nodeNames returns the names of the nodes in the cluster
+func nodeNames(clientset kubernetes.Interface) (map[string]bool, error) {
+	nodes, nodeserr := clientset.CoreV1().Nodes().List(metav1.ListOptions{TimeoutSeconds: &timeout})
+	if nodeserr != nil {
+		return nil, &PollErr{
+			Message: fmt.Sprintf("error fetching nodes: %s", nodeserr.Error()),
 		}
 	}
+	names := make(map[string]bool, len(nodes.Items))
+	for _, node := range nodes.Items {
+		names[node.Name] = true
+	}
+	return names, nil
+}
+
+// checkPodNodeLost alerts on a pod the node controller marked NodeLost, or bound to a node missing
+// from nodes. Such pods can linger in the Running phase until they are garbage collected, although
+// nothing runs them. Pods that already finished are left alone.
+func checkPodNodeLost(
+	pod *corev1.Pod,
+	nodes map[string]bool,
+	alertSpec types.PodAlertSpec,
+	alertFn alertFunction,
+	alertersConfig types.AlertersConfig,
+) {
+	if nodes == nil || pod.Status.Phase == corev1.PodSucceeded || pod.Status.Phase == corev1.PodFailed {
+		return
+	}
+	node := pod.Spec.NodeName
+	var alertmessage string
+	switch {
+	case pod.Status.Reason == nodeLostReason:
+		alertmessage = fmt.Sprintf("Pod %s/%s was lost with its node %s and is not actually running!", pod.Namespace, pod.Name, node)
+	case node != "" && !nodes[node]:
+		alertmessage = fmt.Sprintf("Pod %s/%s is bound to node %s, which no longer exists, and is not actually running!", pod.Namespace, pod.Name, node)
+	default:
+		return
+	}
+	// ALERT
+	alert := newAlert(resourceID("pod", pod.Namespace, pod.Name), "NodeLost", types.SeverityCritical, alertmessage)
+	alertFn(alertSpec.AlerterType, alertSpec.AlerterName, alert, alertersConfig)
+}
+
+// checkPodTerminating alerts on a pod still present longer than the threshold after its deletion
+// deadline, the deletionTimestamp its grace period ends at, naming its node and the finalizers
+// holding it. Unlike stuckTerminating, it keeps alerting for as long as the pod is stuck.
+func checkPodTerminating(
+	pod *corev1.Pod,
+	alertSpec types.PodAlertSpec,
+	now time.Time,
+	alertFn alertFunction,
+	alertersConfig types.AlertersConfig,
+) {
+	threshold := alertSpec.ReportStatus.TerminatingThreshold
+	if threshold <= 0 || pod.DeletionTimestamp == nil {
+		return
+	}
+	overdue := now.Sub(pod.DeletionTimestamp.Time)
+	if overdue <= time.Duration(threshold)*time.Second {
+		return
+	}
+	node := pod.Spec.NodeName
+	if node == "" {
+		node = "(none)"
+	}
+	cause := "Its node's kubelet may be unresponsive."
+	if len(pod.Finalizers) > 0 {
+		cause = fmt.Sprintf("Its finalizers %s may be stuck.", strings.Join(pod.Finalizers, ", "))
+	}
+	// ALERT
+	alertmessage := fmt.Sprintf(
+		"Pod %s/%s on node %s is still terminating %s after its deletion deadline, longer than %ds! %s",
+		pod.Namespace, pod.Name, node, formatLongDuration(overdue), threshold, cause,
+	)
+	alert := newAlert(resourceID("pod", pod.Namespace, pod.Name), "Terminating", types.SeverityWarning, alertmessage)
+	alertFn(alertSpec.AlerterType, alertSpec.AlerterName, alert, alertersConfig)
+}
+
+// evictedReason is the status reason of the pods the kubelet evicted
+const evictedReason = "Evicted"
+
+// checkEvictedPods alerts on the namespaces with more evicted pods among pods than the threshold,
+// naming the nodes that evicted them. Evicted pods stay Failed until they are deleted, or until the
+// pod garbage collector reaches its threshold, so they pile up in etcd as long as nodes are under
+// pressure.
+func checkEvictedPods(
+	pods []corev1.Pod,
+	alertSpec types.PodAlertSpec,
+	alertFn alertFunction,
+	alertersConfig types.AlertersConfig,
+) {
+	threshold := alertSpec.ReportStatus.EvictedThreshold
+	if threshold <= 0 {
+		return
+	}
+	evicted := map[string]map[string]int{}
+	for _, pod := range pods {
+		if pod.Status.Phase != corev1.PodFailed || pod.Status.Reason != evictedReason {
+			continue
+		}
+		if evicted[pod.Namespace] == nil {
+			evicted[pod.Namespace] = map[string]int{}
+		}
+		evicted[pod.Namespace][pod.Spec.NodeName]++
+	}
+	var namespaces []string
+	for namespace := range evicted {
+		namespaces = append(namespaces, namespace)
+	}
+	sort.Strings(namespaces)
+
+	for _, namespace := range namespaces {
+		count := 0
+		var nodes []string
+		for node, evictions := range evicted[namespace] {
+			count += evictions
+			nodes = append(nodes, node)
+		}
+		if count <= threshold {
+			continue
+		}
+		// nodes that evicted the most come first
+		byNode := evicted[namespace]
+		sort.Slice(nodes, func(i, j int) bool {
+			if byNode[nodes[i]] != byNode[nodes[j]] {
+				return byNode[nodes[i]] > byNode[nodes[j]]
+			}
+			return nodes[i] < nodes[j]
+		})
+		for i, node := range nodes {
+			nodes[i] = fmt.Sprintf("%s (%d)", node, byNode[node])
+		}
+		// ALERT
+		alertmessage := fmt.Sprintf(
+			"Namespace %s has %d evicted pods, more than %d! Evicted by nodes %s, which may be under pressure.",
+			namespace, count, threshold, strings.Join(nodes, ", "),
+		)
+		alert := newAlert(resourceID("namespace", "", namespace), "EvictedPods", types.SeverityWarning, alertmessage)
+		alertFn(alertSpec.AlerterType, alertSpec.AlerterName, alert, alertersConfig)
+	}
+}
+
+// mirrorPodAnnotation marks the pods the kubelet mirrors in the apiserver for its static pods
+const mirrorPodAnnotation = "kubernetes.io/config.mirror"
+
+// checkPodOrphaned alerts on a pod that no controller owns, such as a debug pod or a pod created by
+// hand, once it has been running for longer than the threshold. Nothing recreates such a pod if its
+// node goes away, and nothing cleans it up. Static pods and the pods the allowlist names are left alone.
+func checkPodOrphaned(
+	pod *corev1.Pod,
+	alertSpec types.PodAlertSpec,
+	now time.Time,
+	alertFn alertFunction,
+	alertersConfig types.AlertersConfig,
+) {
+	threshold := alertSpec.ReportStatus.OrphanedThreshold
+	if threshold <= 0 || pod.Status.Phase != corev1.PodRunning || len(pod.OwnerReferences) > 0 {
+		return
+	}
+	if _, mirror := pod.Annotations[mirrorPodAnnotation]; mirror {
+		return
+	}
+	for _, pattern := range alertSpec.ReportStatus.OrphanedAllowlist {
+		if matched, _ := path.Match(pattern, pod.Name); matched {
+			return
+		}
+	}
+	started := pod.CreationTimestamp.Time
+	if pod.Status.StartTime != nil {
+		started = pod.Status.StartTime.Time
+	}
+	runningFor := now.Sub(started)
+	if runningFor <= time.Duration(threshold)*time.Second {
+		return
+	}
+	// ALERT
+	alertmessage := fmt.Sprintf(
+		"Pod %s/%s has no owner and has been running for %s, longer than %ds! It may be a debug or hand-made pod left behind.",
+		pod.Namespace, pod.Name, formatLongDuration(runningFor), threshold,
+	)
+	alert := newAlert(resourceID("pod", pod.Namespace, pod.Name), "Orphaned", types.SeverityWarning, alertmessage)
+	alertFn(alertSpec.AlerterType, alertSpec.AlerterName, alert, alertersConfig)
 }
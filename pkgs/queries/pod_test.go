@@ -173,7 +173,7 @@ func Test_PullPod_ok(t *testing.T) {
 		t.Run(test.name, func(subT *testing.T) {
 			client := fake.NewSimpleClientset(test.pod)
 			stubCalled := false
-			alertStub := func(_ string, _ string, _ string, _ AlertersConfig) {
+			alertStub := func(_ string, _ string, _ Alert, _ AlertersConfig) {
 				stubCalled = true
 			}
 			err := PollPod(client, test.alertSpec, defaultTickerTime, alertStub, conf)
@@ -188,3 +188,178 @@ func Test_PullPod_ok(t *testing.T) {
 		})
 	}
 }
+
+func Test_PollPod_NodeLost(t *testing.T) {
+	_, conf := StubsInit()
+	pod := func(name string, node string, phase corev1.PodPhase, reason string) *corev1.Pod {
+		return &corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: metav1.NamespaceDefault},
+			Spec:       corev1.PodSpec{NodeName: node},
+			Status:     corev1.PodStatus{Phase: phase, Reason: reason},
+		}
+	}
+	client := fake.NewSimpleClientset(
+		&corev1.Node{ObjectMeta: metav1.ObjectMeta{Name: "node-a"}},
+		pod("healthy", "node-a", corev1.PodRunning, ""),
+		pod("lost", "node-a", corev1.PodRunning, nodeLostReason),
+		pod("orphaned", "node-gone", corev1.PodRunning, ""),
+		pod("finished", "node-gone", corev1.PodSucceeded, ""),
+		pod("unscheduled", "", corev1.PodPending, ""),
+	)
+	alerts := map[string]string{}
+	alertFn := func(_ string, _ string, alert Alert, _ AlertersConfig) {
+		alerts[alert.Key] = alert.Message
+	}
+
+	spec := PodAlertSpec{Name: "*", ReportStatus: PodAlertStatus{NodeLost: true}}
+	if err := PollPod(client, spec, defaultTickerTime, alertFn, conf); err != nil {
+		t.Fatalf("PollPod returned an unexpected error: %s", err.Error())
+	}
+	expected := map[string]string{
+		"pod/default/lost/NodeLost":     "Pod default/lost was lost with its node node-a and is not actually running!",
+		"pod/default/orphaned/NodeLost": "Pod default/orphaned is bound to node node-gone, which no longer exists, and is not actually running!",
+	}
+	if len(alerts) != len(expected) {
+		t.Errorf("expected %d alerts, got %v", len(expected), alerts)
+	}
+	for key, message := range expected {
+		if alerts[key] != message {
+			t.Errorf("expected %s to alert %q, got %q", key, message, alerts[key])
+		}
+	}
+
+	alerts = map[string]string{}
+	spec.ReportStatus.NodeLost = false
+	if err := PollPod(client, spec, defaultTickerTime, alertFn, conf); err != nil {
+		t.Fatalf("PollPod returned an unexpected error: %s", err.Error())
+	}
+	if len(alerts) != 0 {
+		t.Errorf("expected no alerts with the check off, got %v", alerts)
+	}
+}
+
+func Test_PollPod_Terminating(t *testing.T) {
+	_, conf := StubsInit()
+	now := time.Now()
+	pod := func(name string, node string, deleted time.Time, finalizers ...string) *corev1.Pod {
+		return &corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:                       name,
+				Namespace:                  metav1.NamespaceDefault,
+				DeletionTimestamp:          &metav1.Time{Time: deleted},
+				DeletionGracePeriodSeconds: &defaultDeletionGracePeriod,
+				Finalizers:                 finalizers,
+			},
+			Spec: corev1.PodSpec{NodeName: node},
+		}
+	}
+	client := fake.NewSimpleClientset(
+		pod("stuck", "node-a", now.Add(-30*time.Minute)),
+		pod("finalized", "node-b", now.Add(-3*time.Hour), "example.com/backup", "example.com/audit"),
+		pod("deleting", "node-a", now.Add(-time.Minute)),
+	)
+	alerts := map[string]string{}
+	alertFn := func(_ string, _ string, alert Alert, _ AlertersConfig) {
+		alerts[alert.Key] = alert.Message
+	}
+
+	spec := PodAlertSpec{Name: "*", ReportStatus: PodAlertStatus{TerminatingThreshold: 600}}
+	if err := PollPod(client, spec, defaultTickerTime, alertFn, conf); err != nil {
+		t.Fatalf("PollPod returned an unexpected error: %s", err.Error())
+	}
+	expected := map[string]string{
+		"pod/default/stuck/Terminating":     "Pod default/stuck on node node-a is still terminating 30m0s after its deletion deadline, longer than 600s! Its node's kubelet may be unresponsive.",
+		"pod/default/finalized/Terminating": "Pod default/finalized on node node-b is still terminating 3h0m0s after its deletion deadline, longer than 600s! Its finalizers example.com/backup, example.com/audit may be stuck.",
+	}
+	if len(alerts) != len(expected) {
+		t.Errorf("expected %d alerts, got %v", len(expected), alerts)
+	}
+	for key, message := range expected {
+		if alerts[key] != message {
+			t.Errorf("expected %s to alert %q, got %q", key, message, alerts[key])
+		}
+	}
+}
+
+func Test_PollPod_Evicted(t *testing.T) {
+	_, conf := StubsInit()
+	pod := func(namespace string, name string, node string, reason string) *corev1.Pod {
+		return &corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: namespace, Labels: map[string]string{"app": "web"}},
+			Spec:       corev1.PodSpec{NodeName: node},
+			Status:     corev1.PodStatus{Phase: corev1.PodFailed, Reason: reason},
+		}
+	}
+	client := fake.NewSimpleClientset(
+		pod("shop", "web-1", "node-a", "Evicted"),
+		pod("shop", "web-2", "node-b", "Evicted"),
+		pod("shop", "web-3", "node-b", "Evicted"),
+		pod("shop", "web-4", "node-a", "Evicted"),
+		pod("shop", "web-5", "node-b", "Evicted"),
+		pod("shop", "web-6", "node-c", "Error"),
+		pod("blog", "web-1", "node-a", "Evicted"),
+	)
+	alerts := map[string]string{}
+	alertFn := func(_ string, _ string, alert Alert, _ AlertersConfig) {
+		alerts[alert.Key] = alert.Message
+	}
+
+	spec := PodAlertSpec{Name: "*", PodFilterLabel: "app=web", ReportStatus: PodAlertStatus{EvictedThreshold: 2}}
+	if err := PollPod(client, spec, defaultTickerTime, alertFn, conf); err != nil {
+		t.Fatalf("PollPod returned an unexpected error: %s", err.Error())
+	}
+	expected := map[string]string{
+		"namespace/shop/EvictedPods": "Namespace shop has 5 evicted pods, more than 2! Evicted by nodes node-b (3), node-a (2), which may be under pressure.",
+	}
+	if len(alerts) != len(expected) {
+		t.Errorf("expected %d alerts, got %v", len(expected), alerts)
+	}
+	for key, message := range expected {
+		if alerts[key] != message {
+			t.Errorf("expected %s to alert %q, got %q", key, message, alerts[key])
+		}
+	}
+}
+
+func Test_PollPod_Orphaned(t *testing.T) {
+	_, conf := StubsInit()
+	now := time.Now()
+	pod := func(name string, started time.Time) *corev1.Pod {
+		return &corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: metav1.NamespaceDefault, CreationTimestamp: metav1.Time{Time: started}},
+			Status:     corev1.PodStatus{Phase: corev1.PodRunning, StartTime: &metav1.Time{Time: started}},
+		}
+	}
+	owned := pod("web-5d8f9", now.Add(-48*time.Hour))
+	owned.OwnerReferences = []metav1.OwnerReference{{Kind: "ReplicaSet", Name: "web"}}
+	static := pod("kube-apiserver-master-1", now.Add(-48*time.Hour))
+	static.Annotations = map[string]string{"kubernetes.io/config.mirror": "8b0b5e1d"}
+	finished := pod("migrate", now.Add(-48*time.Hour))
+	finished.Status.Phase = corev1.PodSucceeded
+	client := fake.NewSimpleClientset(
+		pod("shell", now.Add(-3*time.Hour)),
+		pod("debug-net", now.Add(-3*time.Hour)),
+		pod("curl", now.Add(-10*time.Minute)),
+		owned, static, finished,
+	)
+	alerts := map[string]string{}
+	alertFn := func(_ string, _ string, alert Alert, _ AlertersConfig) {
+		alerts[alert.Key] = alert.Message
+	}
+
+	spec := PodAlertSpec{Name: "*", ReportStatus: PodAlertStatus{OrphanedThreshold: 3600, OrphanedAllowlist: []string{"debug-*"}}}
+	if err := PollPod(client, spec, defaultTickerTime, alertFn, conf); err != nil {
+		t.Fatalf("PollPod returned an unexpected error: %s", err.Error())
+	}
+	expected := map[string]string{
+		"pod/default/shell/Orphaned": "Pod default/shell has no owner and has been running for 3h0m0s, longer than 3600s! It may be a debug or hand-made pod left behind.",
+	}
+	if len(alerts) != len(expected) {
+		t.Errorf("expected %d alerts, got %v", len(expected), alerts)
+	}
+	for key, message := range expected {
+		if alerts[key] != message {
+			t.Errorf("expected %s to alert %q, got %q", key, message, alerts[key])
+		}
+	}
+}
@@ -0,0 +1,73 @@
+// Copyright 2019 Bloomberg Finance LP
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package queries
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/bloomberg/k8eraid/pkgs/types"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+// imagePullErrorReasons are the reasons the kubelet gives a container waiting for an image it failed to pull
+var imagePullErrorReasons = map[string]bool{
+	"ErrImagePull":     true,
+	"ImagePullBackOff": true,
+}
+
+// checkPodImagePulls alerts on a pod with containers, init containers included, waiting for an image
+// that cannot be pulled, such as a mistyped tag or one that was never pushed. Pods younger than the
+// pending threshold are left alone, so a pull that failed once on a flaky registry can be retried.
+func checkPodImagePulls(
+	pod *corev1.Pod,
+	alertSpec types.PodAlertSpec,
+	now time.Time,
+	alertFn alertFunction,
+	alertersConfig types.AlertersConfig,
+) {
+	if !alertSpec.ReportStatus.ImagePullErrors || now.Sub(pod.CreationTimestamp.Time) <= time.Duration(alertSpec.ReportStatus.PendingThreshold)*time.Second {
+		return
+	}
+	images := map[string]string{}
+	for _, container := range podContainers(pod) {
+		images[container.Name] = container.Image
+	}
+	var failing []string
+	statuses := append(append([]corev1.ContainerStatus(nil), pod.Status.InitContainerStatuses...), pod.Status.ContainerStatuses...)
+	for _, status := range statuses {
+		waiting := status.State.Waiting
+		if waiting == nil || !imagePullErrorReasons[waiting.Reason] {
+			continue
+		}
+		image, ok := images[status.Name]
+		if !ok {
+			image = status.Image
+		}
+		failure := fmt.Sprintf("%s cannot pull %s (%s", status.Name, image, waiting.Reason)
+		if waiting.Message != "" {
+			failure += ": " + waiting.Message
+		}
+		failing = append(failing, failure+")")
+	}
+	if len(failing) == 0 {
+		return
+	}
+	// ALERT
+	alertmessage := fmt.Sprintf("Pod %s/%s has containers waiting for images: %s!", pod.Namespace, pod.Name, strings.Join(failing, ", "))
+	alertFn(alertSpec.AlerterType, alertSpec.AlerterName, newAlert(resourceID("pod", pod.Namespace, pod.Name), "ImagePull", types.SeverityCritical, alertmessage), alertersConfig)
+}
@@ -0,0 +1,64 @@
+// Copyright 2019 Bloomberg Finance LP
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package queries
+
+import (
+	"testing"
+	"time"
+
+	. "github.com/bloomberg/k8eraid/pkgs/types"
+
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func Test_PollPod_ImagePulls(t *testing.T) {
+	_, conf := StubsInit()
+	waiting := func(reason string, message string) corev1.ContainerState {
+		return corev1.ContainerState{Waiting: &corev1.ContainerStateWaiting{Reason: reason, Message: message}}
+	}
+	pod := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: "web", Namespace: metav1.NamespaceDefault, CreationTimestamp: metav1.Time{Time: time.Now().Add(-time.Hour)}},
+		Spec: corev1.PodSpec{
+			InitContainers: []corev1.Container{{Name: "migrate", Image: "registry.example.com/migrate:v2"}},
+			Containers: []corev1.Container{
+				{Name: "app", Image: "registry.example.com/web:v1.2.3"},
+				{Name: "proxy", Image: "envoyproxy/envoy:v1.10.0"},
+			},
+		},
+		Status: corev1.PodStatus{
+			InitContainerStatuses: []corev1.ContainerStatus{{Name: "migrate", State: waiting("ErrImagePull", "rpc error: code = NotFound desc = manifest unknown")}},
+			ContainerStatuses: []corev1.ContainerStatus{
+				{Name: "app", State: waiting("ImagePullBackOff", `Back-off pulling image "registry.example.com/web:v1.2.3"`)},
+				{Name: "proxy", State: waiting("PodInitializing", "")},
+			},
+		},
+	}
+	young := pod.DeepCopy()
+	young.Name = "web-new"
+	young.CreationTimestamp = metav1.Time{Time: time.Now()}
+	client := fake.NewSimpleClientset(pod, young)
+
+	var alerts []string
+	err := PollPod(client, PodAlertSpec{Name: "*", ReportStatus: PodAlertStatus{ImagePullErrors: true, PendingThreshold: 60}}, defaultTickerTime, func(_ string, _ string, alert Alert, _ AlertersConfig) {
+		alerts = append(alerts, alert.Key+": "+alert.Message)
+	}, conf)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{
+		`pod/default/web/ImagePull: Pod default/web has containers waiting for images: migrate cannot pull registry.example.com/migrate:v2 (ErrImagePull: rpc error: code = NotFound desc = manifest unknown), app cannot pull registry.example.com/web:v1.2.3 (ImagePullBackOff: Back-off pulling image "registry.example.com/web:v1.2.3")!`,
+	}, alerts, "pods younger than the pending threshold are left alone")
+}
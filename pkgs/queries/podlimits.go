@@ -0,0 +1,190 @@
+// Copyright 2019 Bloomberg Finance LP
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package queries
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/bloomberg/k8eraid/pkgs/types"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// limitedResources are the resources a container is expected to set a limit for
+var limitedResources = []corev1.ResourceName{corev1.ResourceCPU, corev1.ResourceMemory}
+
+// checkPodMissingLimits alerts on a pod with containers, init containers included, that set no
+// CPU or memory limit. Pods that already finished are left alone.
+func checkPodMissingLimits(
+	pod *corev1.Pod,
+	alertSpec types.PodAlertSpec,
+	alertFn alertFunction,
+	alertersConfig types.AlertersConfig,
+) {
+	if !alertSpec.ReportStatus.MissingLimits || pod.Status.Phase == corev1.PodSucceeded || pod.Status.Phase == corev1.PodFailed {
+		return
+	}
+	var unlimited []string
+	for _, container := range podContainers(pod) {
+		var missing []string
+		for _, name := range limitedResources {
+			if _, ok := container.Resources.Limits[name]; !ok {
+				missing = append(missing, string(name))
+			}
+		}
+		if len(missing) > 0 {
+			unlimited = append(unlimited, fmt.Sprintf("%s (%s)", container.Name, strings.Join(missing, ", ")))
+		}
+	}
+	if len(unlimited) == 0 {
+		return
+	}
+	// ALERT
+	alertmessage := fmt.Sprintf(
+		"Pod %s/%s has containers without resource limits: %s!",
+		pod.Namespace, pod.Name, strings.Join(unlimited, ", "),
+	)
+	alert := newAlert(resourceID("pod", pod.Namespace, pod.Name), "MissingLimits", types.SeverityWarning, alertmessage)
+	alertFn(alertSpec.AlerterType, alertSpec.AlerterName, alert, alertersConfig)
+}
+
+// checkPodLimitRanges alerts on a pod whose containers fall outside the container limits of the
+// LimitRanges of its namespace. The LimitRanger only enforces them when a pod is created, so pods
+// created before a LimitRange was added or tightened can still violate it. The LimitRanges of each
+// namespace are listed once into limitRanges, which is nil when the check is off.
+func checkPodLimitRanges(
+	clientset kubernetes.Interface,
+	pod *corev1.Pod,
+	limitRanges map[string][]corev1.LimitRange,
+	alertSpec types.PodAlertSpec,
+	alertFn alertFunction,
+	alertersConfig types.AlertersConfig,
+) error {
+	if limitRanges == nil || pod.Status.Phase == corev1.PodSucceeded || pod.Status.Phase == corev1.PodFailed {
+		return nil
+	}
+	ranges, listed := limitRanges[pod.Namespace]
+	if !listed {
+		list, listerr := clientset.CoreV1().LimitRanges(pod.Namespace).List(metav1.ListOptions{TimeoutSeconds: &timeout})
+		if listerr != nil {
+			return &PollErr{
+				Message: fmt.Sprintf("Unable to list LimitRanges of namespace %s: %s", pod.Namespace, listerr.Error()),
+			}
+		}
+		ranges = list.Items
+		limitRanges[pod.Namespace] = ranges
+	}
+
+	var violations []string
+	for _, limitRange := range ranges {
+		for _, item := range limitRange.Spec.Limits {
+			if item.Type != corev1.LimitTypeContainer {
+				continue
+			}
+			for _, container := range podContainers(pod) {
+				for _, violation := range containerLimitViolations(container, item) {
+					violations = append(violations, fmt.Sprintf("container %s %s of LimitRange %s", container.Name, violation, limitRange.Name))
+				}
+			}
+		}
+	}
+	if len(violations) == 0 {
+		return nil
+	}
+	// ALERT
+	alertmessage := fmt.Sprintf(
+		"Pod %s/%s violates the LimitRanges of its namespace: %s!",
+		pod.Namespace, pod.Name, strings.Join(violations, "; "),
+	)
+	alert := newAlert(resourceID("pod", pod.Namespace, pod.Name), "LimitRange", types.SeverityWarning, alertmessage)
+	alertFn(alertSpec.AlerterType, alertSpec.AlerterName, alert, alertersConfig)
+	return nil
+}
+
+// containerLimitViolations describes how container falls outside item the way the LimitRanger
+// would have rejected it: a limit or request above max, a request or limit below min, or a limit
+// more than maxLimitRequestRatio times the request
+func containerLimitViolations(container corev1.Container, item corev1.LimitRangeItem) []string {
+	var violations []string
+	for _, name := range sortedResourceNames(item.Max) {
+		max := item.Max[name]
+		limit, ok := container.Resources.Limits[name]
+		if !ok {
+			violations = append(violations, fmt.Sprintf("sets no %s limit, while the max is %s", name, max.String()))
+			continue
+		}
+		if limit.Cmp(max) > 0 {
+			violations = append(violations, fmt.Sprintf("%s limit %s is above the max %s", name, limit.String(), max.String()))
+		}
+	}
+	for _, name := range sortedResourceNames(item.Min) {
+		min := item.Min[name]
+		request, ok := container.Resources.Requests[name]
+		if !ok {
+			violations = append(violations, fmt.Sprintf("sets no %s request, while the min is %s", name, min.String()))
+			continue
+		}
+		if request.Cmp(min) < 0 {
+			violations = append(violations, fmt.Sprintf("%s request %s is below the min %s", name, request.String(), min.String()))
+		}
+	}
+	for _, name := range sortedResourceNames(item.MaxLimitRequestRatio) {
+		limit, limited := container.Resources.Limits[name]
+		request, requested := container.Resources.Requests[name]
+		ratio := item.MaxLimitRequestRatio[name]
+		if !limited || !requested {
+			continue
+		}
+		limitAmount, limiterr := quantityFloat(limit)
+		requestAmount, requesterr := quantityFloat(request)
+		maxRatio, ratioerr := quantityFloat(ratio)
+		if limiterr != nil || requesterr != nil || ratioerr != nil || requestAmount <= 0 {
+			continue
+		}
+		if limitAmount/requestAmount > maxRatio {
+			violations = append(violations, fmt.Sprintf(
+				"%s limit %s is %.1f times its request %s, above the max ratio %s",
+				name, limit.String(), limitAmount/requestAmount, request.String(), ratio.String(),
+			))
+		}
+	}
+	return violations
+}
+
+// podContainers returns the init containers and containers of pod
+func podContainers(pod *corev1.Pod) []corev1.Container {
+	return append(append([]corev1.Container{}, pod.Spec.InitContainers...), pod.Spec.Containers...)
+}
+
+// sortedResourceNames returns the resource names of list in order
+func sortedResourceNames(list corev1.ResourceList) []corev1.ResourceName {
+	var names []corev1.ResourceName
+	for name := range list {
+		names = append(names, name)
+	}
+	sort.Slice(names, func(i, j int) bool { return names[i] < names[j] })
+	return names
+}
+
+// quantityFloat returns the approximate value of quantity
+func quantityFloat(quantity resource.Quantity) (float64, error) {
+	return strconv.ParseFloat(quantity.AsDec().String(), 64)
+}
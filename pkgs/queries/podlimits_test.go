@@ -0,0 +1,133 @@
+// Copyright 2019 Bloomberg Finance LP
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package queries
+
+import (
+	"errors"
+	"testing"
+
+	. "github.com/bloomberg/k8eraid/pkgs/types"
+
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+	clienttesting "k8s.io/client-go/testing"
+)
+
+func limitedContainer(name string, limits corev1.ResourceList, requests corev1.ResourceList) corev1.Container {
+	return corev1.Container{Name: name, Resources: corev1.ResourceRequirements{Limits: limits, Requests: requests}}
+}
+
+func Test_PollPod_Limits(t *testing.T) {
+	_, conf := StubsInit()
+	pod := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: "web", Namespace: metav1.NamespaceDefault, Labels: map[string]string{"app": "web"}},
+		Spec: corev1.PodSpec{
+			InitContainers: []corev1.Container{limitedContainer("migrate", nil, nil)},
+			Containers: []corev1.Container{
+				limitedContainer("app",
+					corev1.ResourceList{corev1.ResourceCPU: resource.MustParse("4"), corev1.ResourceMemory: resource.MustParse("1Gi")},
+					corev1.ResourceList{corev1.ResourceCPU: resource.MustParse("500m")},
+				),
+				limitedContainer("proxy",
+					corev1.ResourceList{corev1.ResourceMemory: resource.MustParse("128Mi")},
+					corev1.ResourceList{corev1.ResourceCPU: resource.MustParse("200m"), corev1.ResourceMemory: resource.MustParse("128Mi")},
+				),
+			},
+		},
+		Status: corev1.PodStatus{Phase: corev1.PodRunning},
+	}
+	finished := pod.DeepCopy()
+	finished.Name = "web-done"
+	finished.Status.Phase = corev1.PodSucceeded
+	client := fake.NewSimpleClientset(pod, finished, &corev1.LimitRange{
+		ObjectMeta: metav1.ObjectMeta{Name: "defaults", Namespace: metav1.NamespaceDefault},
+		Spec: corev1.LimitRangeSpec{Limits: []corev1.LimitRangeItem{
+			{
+				Type:                 corev1.LimitTypeContainer,
+				Max:                  corev1.ResourceList{corev1.ResourceCPU: resource.MustParse("2")},
+				Min:                  corev1.ResourceList{corev1.ResourceMemory: resource.MustParse("64Mi")},
+				MaxLimitRequestRatio: corev1.ResourceList{corev1.ResourceCPU: resource.MustParse("4")},
+			},
+			{Type: corev1.LimitTypePod, Max: corev1.ResourceList{corev1.ResourceCPU: resource.MustParse("1")}},
+		}},
+	})
+	poll := func(spec PodAlertSpec) []string {
+		messages := []string{}
+		err := PollPod(client, spec, defaultTickerTime, func(_ string, _ string, alert Alert, _ AlertersConfig) {
+			messages = append(messages, alert.Key+": "+alert.Message)
+		}, conf)
+		assert.NoError(t, err)
+		return messages
+	}
+
+	assert.Equal(t, []string{
+		"pod/default/web/MissingLimits: Pod default/web has containers without resource limits: migrate (cpu, memory), proxy (cpu)!",
+	}, poll(PodAlertSpec{Name: "web", PodFilterNamespace: metav1.NamespaceDefault, ReportStatus: PodAlertStatus{MissingLimits: true}}))
+	assert.Equal(t, []string{
+		"pod/default/web/LimitRange: Pod default/web violates the LimitRanges of its namespace: " +
+			"container migrate sets no cpu limit, while the max is 2 of LimitRange defaults; " +
+			"container migrate sets no memory request, while the min is 64Mi of LimitRange defaults; " +
+			"container app cpu limit 4 is above the max 2 of LimitRange defaults; " +
+			"container app sets no memory request, while the min is 64Mi of LimitRange defaults; " +
+			"container app cpu limit 4 is 8.0 times its request 500m, above the max ratio 4 of LimitRange defaults; " +
+			"container proxy sets no cpu limit, while the max is 2 of LimitRange defaults!",
+	}, poll(PodAlertSpec{Name: "*", PodFilterLabel: "app=web", ReportStatus: PodAlertStatus{LimitRangeViolations: true}}), "finished pods are left alone")
+}
+
+func Test_PollPod_LimitRangesOnObjectError(t *testing.T) {
+	_, conf := StubsInit()
+	unbounded := func(namespace string) *corev1.Pod {
+		return &corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{Name: "web", Namespace: namespace, Labels: map[string]string{"app": "web"}},
+			Spec:       corev1.PodSpec{Containers: []corev1.Container{limitedContainer("app", nil, nil)}},
+			Status:     corev1.PodStatus{Phase: corev1.PodRunning},
+		}
+	}
+	limits := &corev1.LimitRange{
+		ObjectMeta: metav1.ObjectMeta{Name: "defaults", Namespace: "shop"},
+		Spec: corev1.LimitRangeSpec{Limits: []corev1.LimitRangeItem{
+			{Type: corev1.LimitTypeContainer, Max: corev1.ResourceList{corev1.ResourceCPU: resource.MustParse("2")}},
+		}},
+	}
+
+	for _, policy := range []string{OnObjectErrorAbort, OnObjectErrorContinue} {
+		t.Run(policy, func(subT *testing.T) {
+			client := fake.NewSimpleClientset(unbounded("billing"), unbounded("shop"), limits)
+			client.PrependReactor("list", "limitranges", func(action clienttesting.Action) (bool, runtime.Object, error) {
+				if action.GetNamespace() == "billing" {
+					return true, nil, errors.New("connection reset")
+				}
+				return false, nil, nil
+			})
+			var alerts []string
+			alertStub := func(_ string, _ string, alert Alert, _ AlertersConfig) {
+				alerts = append(alerts, alert.Key)
+			}
+			alertSpec := PodAlertSpec{Name: "*", PodFilterLabel: "app=web", ReportStatus: PodAlertStatus{LimitRangeViolations: true}, OnObjectError: policy}
+
+			err := PollPod(client, alertSpec, defaultTickerTime, alertStub, conf)
+			if policy == OnObjectErrorContinue {
+				assert.EqualError(subT, err, "1 pods could not be checked: Unable to list LimitRanges of namespace billing: connection reset")
+				assert.Equal(subT, []string{"pod/shop/web/LimitRange"}, alerts)
+			} else {
+				assert.EqualError(subT, err, "Unable to list LimitRanges of namespace billing: connection reset")
+			}
+		})
+	}
+}
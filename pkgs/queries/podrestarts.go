@@ -0,0 +1,185 @@
+// Copyright 2019 Bloomberg Finance LP
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package queries
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/bloomberg/k8eraid/pkgs/state"
+	"github.com/bloomberg/k8eraid/pkgs/types"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+// defaultRestartWindow is how many seconds back restarts are counted, unless the rule says otherwise
+const defaultRestartWindow = 3600
+
+// crashLoopBackOffReason is the reason the kubelet gives a container waiting to be restarted after crashing
+const crashLoopBackOffReason = "CrashLoopBackOff"
+
+// crashLoopMaxBackOff is the longest the kubelet waits before restarting a crashing container. One
+// that has been running for less than that since it last terminated may still be crash looping.
+const crashLoopMaxBackOff = 5 * time.Minute
+
+// checkPodRestartCounts alerts on a pod with containers, init containers included, restarted more
+// times than the rule allows in all, or within its window. The restart counts of each container are
+// recorded every poll, so restarts within the window are only counted from the first poll that saw
+// the container.
+func checkPodRestartCounts(
+	pod *corev1.Pod,
+	alertSpec types.PodAlertSpec,
+	now time.Time,
+	alertFn alertFunction,
+	alertersConfig types.AlertersConfig,
+) {
+	threshold, increase := alertSpec.ReportStatus.RestartThreshold, alertSpec.ReportStatus.RestartIncrease
+	if threshold <= 0 && increase <= 0 {
+		return
+	}
+	resource := resourceID("pod", pod.Namespace, pod.Name)
+	window := time.Duration(alertSpec.ReportStatus.RestartWindow) * time.Second
+	var overThreshold, overIncrease []string
+	statuses := append(append([]corev1.ContainerStatus(nil), pod.Status.InitContainerStatuses...), pod.Status.ContainerStatuses...)
+	for _, status := range statuses {
+		if threshold > 0 && status.RestartCount > threshold {
+			overThreshold = append(overThreshold, fmt.Sprintf("%s restarted %d times%s", status.Name, status.RestartCount, lastTermination(&status)))
+		}
+		if increase <= 0 {
+			continue
+		}
+		samples := stateStore.RecordSample("restarts/"+resource+"/"+status.Name, state.Sample{Time: now, Value: float64(status.RestartCount)}, window)
+		if restarts := int32(samples[len(samples)-1].Value - samples[0].Value); restarts > increase {
+			overIncrease = append(overIncrease, fmt.Sprintf("%s restarted %d times%s", status.Name, restarts, lastTermination(&status)))
+		}
+	}
+
+	if len(overThreshold) > 0 {
+		// ALERT
+		alertmessage := fmt.Sprintf(
+			"Pod %s/%s has containers restarted more than %d times: %s!",
+			pod.Namespace, pod.Name, threshold, strings.Join(overThreshold, ", "),
+		)
+		alertFn(alertSpec.AlerterType, alertSpec.AlerterName, newAlert(resource, "RestartCount", types.SeverityWarning, alertmessage), alertersConfig)
+	}
+	if len(overIncrease) > 0 {
+		// ALERT
+		alertmessage := fmt.Sprintf(
+			"Pod %s/%s has containers restarted more than %d times in the last %s: %s!",
+			pod.Namespace, pod.Name, increase, formatLongDuration(window), strings.Join(overIncrease, ", "),
+		)
+		alertFn(alertSpec.AlerterType, alertSpec.AlerterName, newAlert(resource, "RestartRate", types.SeverityCritical, alertmessage), alertersConfig)
+	}
+}
+
+// checkPodOOMKills alerts on a pod with containers, init containers included, that were OOMKilled
+// within tickertime seconds before now, naming their memory limit. A termination is first reported
+// as the container state and then as its last state once the container restarts, so either is read.
+func checkPodOOMKills(
+	pod *corev1.Pod,
+	alertSpec types.PodAlertSpec,
+	tickertime int64,
+	now time.Time,
+	alertFn alertFunction,
+	alertersConfig types.AlertersConfig,
+) {
+	if !alertSpec.ReportStatus.OOMKilled {
+		return
+	}
+	limits := map[string]string{}
+	for _, container := range podContainers(pod) {
+		if limit, ok := container.Resources.Limits[corev1.ResourceMemory]; ok {
+			limits[container.Name] = "memory limit " + limit.String()
+		}
+	}
+	since := now.Add(-time.Duration(tickertime) * time.Second)
+	var killed []string
+	statuses := append(append([]corev1.ContainerStatus(nil), pod.Status.InitContainerStatuses...), pod.Status.ContainerStatuses...)
+	for _, status := range statuses {
+		for _, terminated := range []*corev1.ContainerStateTerminated{status.State.Terminated, status.LastTerminationState.Terminated} {
+			if terminated == nil || terminated.Reason != "OOMKilled" || !terminated.FinishedAt.After(since) {
+				continue
+			}
+			limit, ok := limits[status.Name]
+			if !ok {
+				limit = "no memory limit"
+			}
+			killed = append(killed, fmt.Sprintf("%s (%s)", status.Name, limit))
+			break
+		}
+	}
+	if len(killed) == 0 {
+		return
+	}
+	// ALERT
+	alertmessage := fmt.Sprintf(
+		"Pod %s/%s had containers OOMKilled since the last poll: %s!",
+		pod.Namespace, pod.Name, strings.Join(killed, ", "),
+	)
+	alertFn(alertSpec.AlerterType, alertSpec.AlerterName, newAlert(resourceID("pod", pod.Namespace, pod.Name), "OOMKilled", types.SeverityWarning, alertmessage), alertersConfig)
+}
+
+// checkPodCrashLoops alerts on a pod with containers, init containers included, waiting in
+// CrashLoopBackOff after crash looping for longer than the threshold. The pod stays Running all along.
+// Between two back-offs a container briefly runs or is terminated, which does not end the loop unless
+// it runs for longer than the kubelet ever backs off.
+func checkPodCrashLoops(
+	pod *corev1.Pod,
+	alertSpec types.PodAlertSpec,
+	now time.Time,
+	alertFn alertFunction,
+	alertersConfig types.AlertersConfig,
+) {
+	threshold := alertSpec.ReportStatus.CrashLoopThreshold
+	if threshold <= 0 {
+		return
+	}
+	resource := resourceID("pod", pod.Namespace, pod.Name)
+	var looping []string
+	statuses := append(append([]corev1.ContainerStatus(nil), pod.Status.InitContainerStatuses...), pod.Status.ContainerStatuses...)
+	for _, status := range statuses {
+		backingOff := status.State.Waiting != nil && status.State.Waiting.Reason == crashLoopBackOffReason
+		restarted := status.RestartCount > 0 && (status.State.Terminated != nil ||
+			status.State.Running != nil && now.Sub(status.State.Running.StartedAt.Time) < crashLoopMaxBackOff)
+		loopingFor := unhealthyFor("crashloop/"+resource+"/"+status.Name, backingOff || restarted, pod.CreationTimestamp.Time, now)
+		if backingOff && loopingFor > time.Duration(threshold)*time.Second {
+			looping = append(looping, fmt.Sprintf(
+				"%s for %s after %d restarts%s", status.Name, formatLongDuration(loopingFor), status.RestartCount, lastTermination(&status),
+			))
+		}
+	}
+	if len(looping) == 0 {
+		return
+	}
+	// ALERT
+	alertmessage := fmt.Sprintf(
+		"Pod %s/%s has containers in CrashLoopBackOff for longer than %ds: %s!",
+		pod.Namespace, pod.Name, threshold, strings.Join(looping, ", "),
+	)
+	alertFn(alertSpec.AlerterType, alertSpec.AlerterName, newAlert(resource, crashLoopBackOffReason, types.SeverityCritical, alertmessage), alertersConfig)
+}
+
+// lastTermination describes why the previous instance of the container of status terminated
+func lastTermination(status *corev1.ContainerStatus) string {
+	terminated := status.LastTerminationState.Terminated
+	if terminated == nil {
+		return ""
+	}
+	if terminated.Reason != "" {
+		return fmt.Sprintf(" (last %s, exit code %d)", terminated.Reason, terminated.ExitCode)
+	}
+	return fmt.Sprintf(" (last exit code %d)", terminated.ExitCode)
+}
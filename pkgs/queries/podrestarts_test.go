@@ -0,0 +1,182 @@
+// Copyright 2019 Bloomberg Finance LP
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package queries
+
+import (
+	"testing"
+	"time"
+
+	"github.com/bloomberg/k8eraid/pkgs/state"
+	. "github.com/bloomberg/k8eraid/pkgs/types"
+
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func restartedPod(app int32, proxy int32) *corev1.Pod {
+	return &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: "web", Namespace: metav1.NamespaceDefault},
+		Status: corev1.PodStatus{ContainerStatuses: []corev1.ContainerStatus{
+			{
+				Name:                 "app",
+				RestartCount:         app,
+				LastTerminationState: corev1.ContainerState{Terminated: &corev1.ContainerStateTerminated{Reason: "OOMKilled", ExitCode: 137}},
+			},
+			{Name: "proxy", RestartCount: proxy},
+		}},
+	}
+}
+
+func Test_checkPodRestartCounts(t *testing.T) {
+	stateStore = state.NewStore()
+	_, conf := StubsInit()
+	alertSpec := PodAlertSpec{ReportStatus: PodAlertStatus{RestartThreshold: 10, RestartIncrease: 3, RestartWindow: 600}}
+	start := time.Unix(1000, 0)
+	check := func(pod *corev1.Pod, after time.Duration) []string {
+		alerts := []string{}
+		checkPodRestartCounts(pod, alertSpec, start.Add(after), func(_ string, _ string, alert Alert, _ AlertersConfig) {
+			alerts = append(alerts, alert.Key+": "+alert.Message)
+		}, conf)
+		return alerts
+	}
+
+	assert.Equal(t, []string{
+		"pod/default/web/RestartCount: Pod default/web has containers restarted more than 10 times: app restarted 12 times (last OOMKilled, exit code 137)!",
+	}, check(restartedPod(12, 1), 0), "restarts before the first poll only count towards the threshold")
+	assert.Equal(t, []string{
+		"pod/default/web/RestartCount: Pod default/web has containers restarted more than 10 times: app restarted 14 times (last OOMKilled, exit code 137)!",
+	}, check(restartedPod(14, 3), 5*time.Minute))
+	assert.Equal(t, []string{
+		"pod/default/web/RestartCount: Pod default/web has containers restarted more than 10 times: app restarted 16 times (last OOMKilled, exit code 137)!",
+		"pod/default/web/RestartRate: Pod default/web has containers restarted more than 3 times in the last 10m0s: app restarted 4 times (last OOMKilled, exit code 137), proxy restarted 4 times!",
+	}, check(restartedPod(16, 5), 9*time.Minute))
+	assert.Equal(t, []string{
+		"pod/default/web/RestartCount: Pod default/web has containers restarted more than 10 times: app restarted 16 times (last OOMKilled, exit code 137)!",
+	}, check(restartedPod(16, 5), 20*time.Minute), "restarts older than the window are forgotten")
+}
+
+func Test_PollPod_Restarts(t *testing.T) {
+	stateStore = state.NewStore()
+	_, conf := StubsInit()
+	client := fake.NewSimpleClientset(restartedPod(12, 0))
+	keys := []string{}
+	err := PollPod(client, PodAlertSpec{Name: "web", PodFilterNamespace: metav1.NamespaceDefault, ReportStatus: PodAlertStatus{RestartThreshold: 5, RestartIncrease: 1}}, defaultTickerTime, func(_ string, _ string, alert Alert, _ AlertersConfig) {
+		keys = append(keys, alert.Key)
+	}, conf)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"pod/default/web/RestartCount"}, keys)
+	assert.Len(t, stateStore.Samples("restarts/pod/default/web/app", time.Time{}), 1, "the default window keeps the samples")
+}
+
+func Test_ExpireState_DeletedPod(t *testing.T) {
+	stateStore = state.NewStore()
+	_, conf := StubsInit()
+	crashLooping := func(name string) *corev1.Pod {
+		pod := restartedPod(3, 0)
+		pod.Name = name
+		pod.Status.ContainerStatuses[0].State = corev1.ContainerState{Waiting: &corev1.ContainerStateWaiting{Reason: crashLoopBackOffReason}}
+		return pod
+	}
+	client := fake.NewSimpleClientset(crashLooping("web"), crashLooping("api"))
+	alertSpec := PodAlertSpec{Name: "*", PodFilterNamespace: metav1.NamespaceDefault, ReportStatus: PodAlertStatus{RestartIncrease: 5, CrashLoopThreshold: 600}}
+	poll := func() {
+		assert.NoError(t, PollPod(client, alertSpec, defaultTickerTime, func(_ string, _ string, _ Alert, _ AlertersConfig) {}, conf))
+	}
+
+	poll()
+	assert.NoError(t, client.CoreV1().Pods(metav1.NamespaceDefault).Delete("web", &metav1.DeleteOptions{}))
+	time.Sleep(time.Millisecond)
+	cutoff := time.Now()
+	time.Sleep(time.Millisecond)
+	poll()
+	assert.Equal(t, 3, ExpireState(cutoff), "the restart series of both containers and the crash loop of web should be forgotten")
+
+	assert.Empty(t, stateStore.Samples("restarts/pod/default/web/app", time.Time{}))
+	_, looping := stateStore.SwapSnapshot("crashloop/pod/default/web/app", state.Snapshot{})
+	assert.False(t, looping)
+	assert.Len(t, stateStore.Samples("restarts/pod/default/api/app", time.Time{}), 2, "pods still listed keep their state")
+}
+
+func Test_checkPodOOMKills(t *testing.T) {
+	_, conf := StubsInit()
+	now := time.Unix(100000, 0)
+	oomKilled := func(at time.Time) *corev1.ContainerStateTerminated {
+		return &corev1.ContainerStateTerminated{Reason: "OOMKilled", ExitCode: 137, FinishedAt: metav1.Time{Time: at}}
+	}
+	pod := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: "web", Namespace: metav1.NamespaceDefault},
+		Spec: corev1.PodSpec{
+			InitContainers: []corev1.Container{limitedContainer("migrate", nil, nil)},
+			Containers: []corev1.Container{
+				limitedContainer("app", corev1.ResourceList{corev1.ResourceMemory: resource.MustParse("256Mi")}, nil),
+				limitedContainer("proxy", corev1.ResourceList{corev1.ResourceMemory: resource.MustParse("64Mi")}, nil),
+				limitedContainer("logs", nil, nil),
+			},
+		},
+		Status: corev1.PodStatus{
+			InitContainerStatuses: []corev1.ContainerStatus{{Name: "migrate", State: corev1.ContainerState{Terminated: oomKilled(now.Add(-5 * time.Second))}}},
+			ContainerStatuses: []corev1.ContainerStatus{
+				{Name: "app", LastTerminationState: corev1.ContainerState{Terminated: oomKilled(now.Add(-20 * time.Second))}},
+				{Name: "proxy", LastTerminationState: corev1.ContainerState{Terminated: oomKilled(now.Add(-time.Hour))}},
+				{Name: "logs", LastTerminationState: corev1.ContainerState{Terminated: &corev1.ContainerStateTerminated{Reason: "Error", ExitCode: 1, FinishedAt: metav1.Time{Time: now}}}},
+			},
+		},
+	}
+	check := func(alertSpec PodAlertSpec) []string {
+		alerts := []string{}
+		checkPodOOMKills(pod, alertSpec, 30, now, func(_ string, _ string, alert Alert, _ AlertersConfig) {
+			alerts = append(alerts, alert.Key+": "+alert.Message)
+		}, conf)
+		return alerts
+	}
+
+	assert.Empty(t, check(PodAlertSpec{}))
+	assert.Equal(t, []string{
+		"pod/default/web/OOMKilled: Pod default/web had containers OOMKilled since the last poll: migrate (no memory limit), app (memory limit 256Mi)!",
+	}, check(PodAlertSpec{ReportStatus: PodAlertStatus{OOMKilled: true}}), "kills before the previous poll were already reported")
+}
+
+func Test_checkPodCrashLoops(t *testing.T) {
+	stateStore = state.NewStore()
+	_, conf := StubsInit()
+	start := time.Unix(100000, 0)
+	alertSpec := PodAlertSpec{ReportStatus: PodAlertStatus{CrashLoopThreshold: 600}}
+	pod := restartedPod(5, 0)
+	pod.CreationTimestamp = metav1.Time{Time: start.Add(-time.Hour)}
+	backOff := corev1.ContainerState{Waiting: &corev1.ContainerStateWaiting{Reason: "CrashLoopBackOff"}}
+	running := func(since time.Time) corev1.ContainerState {
+		return corev1.ContainerState{Running: &corev1.ContainerStateRunning{StartedAt: metav1.Time{Time: since}}}
+	}
+	pod.Status.ContainerStatuses[1].State = running(start.Add(-time.Hour))
+	check := func(app corev1.ContainerState, after time.Duration) []string {
+		pod.Status.ContainerStatuses[0].State = app
+		alerts := []string{}
+		checkPodCrashLoops(pod, alertSpec, start.Add(after), func(_ string, _ string, alert Alert, _ AlertersConfig) {
+			alerts = append(alerts, alert.Key+": "+alert.Message)
+		}, conf)
+		return alerts
+	}
+
+	assert.Empty(t, check(backOff, 0))
+	assert.Empty(t, check(running(start.Add(5*time.Minute)), 6*time.Minute), "running between two back-offs")
+	assert.Equal(t, []string{
+		"pod/default/web/CrashLoopBackOff: Pod default/web has containers in CrashLoopBackOff for longer than 600s: app for 12m0s after 5 restarts (last OOMKilled, exit code 137)!",
+	}, check(backOff, 12*time.Minute))
+	assert.Empty(t, check(running(start.Add(13*time.Minute)), 30*time.Minute), "running for longer than any back-off ends the loop")
+	assert.Empty(t, check(backOff, 31*time.Minute))
+}
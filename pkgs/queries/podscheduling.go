@@ -0,0 +1,99 @@
+// Copyright 2019 Bloomberg Finance LP
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package queries
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/bloomberg/k8eraid/pkgs/types"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// failedSchedulingReason is the reason of the events the scheduler records for a pod it found no node for
+const failedSchedulingReason = "FailedScheduling"
+
+// failedSchedulingEvents returns the latest FailedScheduling event of every pod in namespace, all of
+// them when it is empty, by namespace/name
+func failedSchedulingEvents(clientset kubernetes.Interface, namespace string) (map[string]*corev1.Event, error) {
+	list, listerr := clientset.CoreV1().Events(namespace).List(metav1.ListOptions{
+		FieldSelector:  "involvedObject.kind=Pod,reason=" + failedSchedulingReason,
+		TimeoutSeconds: &timeout,
+	})
+	if listerr != nil {
+		return nil, &PollErr{
+			Message: fmt.Sprintf("Unable to list %s events: %s", failedSchedulingReason, listerr.Error()),
+		}
+	}
+	latest := map[string]*corev1.Event{}
+	for i := range list.Items {
+		event := &list.Items[i]
+		if event.InvolvedObject.Kind != "Pod" || event.Reason != failedSchedulingReason {
+			continue
+		}
+		key := event.InvolvedObject.Namespace + "/" + event.InvolvedObject.Name
+		if previous, ok := latest[key]; !ok || eventLastSeen(previous).Before(eventLastSeen(event)) {
+			latest[key] = event
+		}
+	}
+	return latest, nil
+}
+
+// checkPodUnschedulable alerts on a Pending pod the scheduler has found no node for for longer than
+// the threshold, with the reason the scheduler gave. That is read from the PodScheduled condition the
+// scheduler sets, or from its latest FailedScheduling event among events when the condition has none.
+func checkPodUnschedulable(
+	pod *corev1.Pod,
+	events map[string]*corev1.Event,
+	alertSpec types.PodAlertSpec,
+	now time.Time,
+	alertFn alertFunction,
+	alertersConfig types.AlertersConfig,
+) {
+	threshold := alertSpec.ReportStatus.UnschedulableThreshold
+	if threshold <= 0 || pod.Status.Phase != corev1.PodPending || pod.Spec.NodeName != "" {
+		return
+	}
+	since, reason := pod.CreationTimestamp.Time, ""
+	for _, condition := range pod.Status.Conditions {
+		if condition.Type == corev1.PodScheduled && condition.Status == corev1.ConditionFalse && condition.Reason == corev1.PodReasonUnschedulable {
+			reason = condition.Message
+			if !condition.LastTransitionTime.IsZero() {
+				since = condition.LastTransitionTime.Time
+			}
+		}
+	}
+	event := events[pod.Namespace+"/"+pod.Name]
+	if reason == "" && event != nil {
+		reason = event.Message
+	}
+	if reason == "" {
+		return
+	}
+	unschedulableFor := now.Sub(since)
+	if unschedulableFor <= time.Duration(threshold)*time.Second {
+		return
+	}
+	// ALERT
+	alertmessage := fmt.Sprintf(
+		"Pod %s/%s has been unschedulable for %s, longer than %ds! The scheduler says: %s",
+		pod.Namespace, pod.Name, formatLongDuration(unschedulableFor), threshold, reason,
+	)
+	alert := newAlert(resourceID("pod", pod.Namespace, pod.Name), corev1.PodReasonUnschedulable, types.SeverityCritical, alertmessage)
+	alertFn(alertSpec.AlerterType, alertSpec.AlerterName, alert, alertersConfig)
+}
@@ -0,0 +1,79 @@
+// Copyright 2019 Bloomberg Finance LP
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package queries
+
+import (
+	"testing"
+	"time"
+
+	. "github.com/bloomberg/k8eraid/pkgs/types"
+
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func pendingPod(name string, created time.Time, conditions ...corev1.PodCondition) *corev1.Pod {
+	return &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: metav1.NamespaceDefault, CreationTimestamp: metav1.Time{Time: created}},
+		Status:     corev1.PodStatus{Phase: corev1.PodPending, Conditions: conditions},
+	}
+}
+
+func Test_PollPod_Unschedulable(t *testing.T) {
+	_, conf := StubsInit()
+	now := time.Now()
+	unschedulable := func(since time.Time, message string) corev1.PodCondition {
+		return corev1.PodCondition{
+			Type:               corev1.PodScheduled,
+			Status:             corev1.ConditionFalse,
+			Reason:             corev1.PodReasonUnschedulable,
+			LastTransitionTime: metav1.Time{Time: since},
+			Message:            message,
+		}
+	}
+	event := func(name string, pod string, last time.Time, message string) *corev1.Event {
+		return &corev1.Event{
+			ObjectMeta:     metav1.ObjectMeta{Name: name, Namespace: metav1.NamespaceDefault},
+			InvolvedObject: corev1.ObjectReference{Kind: "Pod", Namespace: metav1.NamespaceDefault, Name: pod},
+			Reason:         "FailedScheduling",
+			LastTimestamp:  metav1.Time{Time: last},
+			Message:        message,
+		}
+	}
+	scheduled := pendingPod("web-3", now.Add(-time.Hour))
+	scheduled.Spec.NodeName = "worker-1"
+	client := fake.NewSimpleClientset(
+		pendingPod("web-0", now.Add(-time.Hour), unschedulable(now.Add(-10*time.Minute), "0/3 nodes are available: 3 Insufficient cpu.")),
+		pendingPod("web-1", now.Add(-time.Hour), unschedulable(now.Add(-time.Minute), "0/3 nodes are available: 3 Insufficient memory.")),
+		pendingPod("web-2", now.Add(-20*time.Minute)),
+		scheduled,
+		pendingPod("web-4", now.Add(-time.Hour)),
+		event("web-2.1", "web-2", now.Add(-10*time.Minute), "pod has unbound immediate PersistentVolumeClaims"),
+		event("web-2.2", "web-2", now.Add(-time.Minute), `0/3 nodes are available: 3 node(s) didn't match node selector.`),
+		event("web-3.1", "web-3", now.Add(-time.Hour), "0/3 nodes are available: 3 Insufficient cpu."),
+	)
+
+	var alerts []string
+	err := PollPod(client, PodAlertSpec{Name: "*", ReportStatus: PodAlertStatus{UnschedulableThreshold: 300}}, defaultTickerTime, func(_ string, _ string, alert Alert, _ AlertersConfig) {
+		alerts = append(alerts, alert.Key+": "+alert.Message)
+	}, conf)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{
+		"pod/default/web-0/Unschedulable: Pod default/web-0 has been unschedulable for 10m0s, longer than 300s! The scheduler says: 0/3 nodes are available: 3 Insufficient cpu.",
+		"pod/default/web-2/Unschedulable: Pod default/web-2 has been unschedulable for 20m0s, longer than 300s! The scheduler says: 0/3 nodes are available: 3 node(s) didn't match node selector.",
+	}, alerts, "pods without a reason or bound to a node are left to other checks")
+}
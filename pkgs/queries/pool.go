@@ -0,0 +1,125 @@
+// Copyright 2019 Bloomberg Finance LP
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package queries
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/bloomberg/k8eraid/pkgs/types"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+// maxListedInstances bounds how many unregistered instances an alert names
+const maxListedInstances = 10
+
+// InstanceProvider lists the provider IDs of the instances running in a pool, in the form nodes
+// report them in Spec.ProviderID, e.g. aws:///us-east-1a/i-0123456789abcdef0 for the members of an
+// autoscaling group.
+type InstanceProvider func(pool string) ([]string, error)
+
+var (
+	instanceProvidersMu sync.Mutex
+	instanceProviders   = map[string]InstanceProvider{}
+)
+
+// RegisterInstanceProvider makes provider available to expectedPoolSize rules under name
+func RegisterInstanceProvider(name string, provider InstanceProvider) {
+	instanceProvidersMu.Lock()
+	defer instanceProvidersMu.Unlock()
+	instanceProviders[name] = provider
+}
+
+func instanceProvider(name string) (InstanceProvider, bool) {
+	instanceProvidersMu.Lock()
+	defer instanceProvidersMu.Unlock()
+	provider, ok := instanceProviders[name]
+	return provider, ok
+}
+
+// checkPoolSize alerts when fewer nodes are registered than the instances expected to back them,
+// which catches instances that booted but never joined the cluster.
+func checkPoolSize(
+	nodes []corev1.Node,
+	alertSpec types.NodeAlertSpec,
+	alertFn alertFunction,
+	alertersConfig types.AlertersConfig,
+) error {
+	expected := alertSpec.ReportStatus.ExpectedPoolSize
+	resource := resourceID("nodes", "", alertSpec.NodeFilter)
+
+	if expected.Provider == "" {
+		if expected.Count > 0 && len(nodes) < expected.Count {
+			// ALERT
+			alertmessage := fmt.Sprintf(
+				"Node count with filter %s is %d, but %d instances are expected, %d may have never joined the cluster!",
+				alertSpec.NodeFilter,
+				len(nodes),
+				expected.Count,
+				expected.Count-len(nodes),
+			)
+			alertFn(alertSpec.AlerterType, alertSpec.AlerterName, newAlert(resource, "ExpectedPoolSize", types.SeverityWarning, alertmessage), alertersConfig)
+		}
+		return nil
+	}
+
+	provider, ok := instanceProvider(expected.Provider)
+	if !ok {
+		return &PollErr{
+			Message: fmt.Sprintf("Node rule with filter %s uses unknown instance provider %s, ignoring", alertSpec.NodeFilter, expected.Provider),
+		}
+	}
+	instances, err := provider(expected.Pool)
+	if err != nil {
+		return &PollErr{
+			Message: fmt.Sprintf("Unable to list instances of pool %s from provider %s: %s", expected.Pool, expected.Provider, err.Error()),
+		}
+	}
+
+	registered := map[string]bool{}
+	for _, node := range nodes {
+		registered[node.Spec.ProviderID] = true
+	}
+	var missing []string
+	for _, instance := range instances {
+		if !registered[instance] {
+			missing = append(missing, instance)
+		}
+	}
+	if len(missing) == 0 {
+		return nil
+	}
+	sort.Strings(missing)
+	listed := missing
+	if len(listed) > maxListedInstances {
+		listed = append(listed[:maxListedInstances:maxListedInstances], fmt.Sprintf("and %d more", len(missing)-maxListedInstances))
+	}
+	// ALERT
+	alertmessage := fmt.Sprintf(
+		"Node count with filter %s is %d, but provider %s runs %d instances in pool %s, %d never joined the cluster: %s",
+		alertSpec.NodeFilter,
+		len(nodes),
+		expected.Provider,
+		len(instances),
+		expected.Pool,
+		len(missing),
+		strings.Join(listed, ", "),
+	)
+	alertFn(alertSpec.AlerterType, alertSpec.AlerterName, newAlert(resource, "ExpectedPoolSize", types.SeverityWarning, alertmessage), alertersConfig)
+	return nil
+}
@@ -0,0 +1,110 @@
+// Copyright 2019 Bloomberg Finance LP
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package queries
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	. "github.com/bloomberg/k8eraid/pkgs/types"
+
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func Test_PollNode_ExpectedPoolSize(t *testing.T) {
+	_, conf := StubsInit()
+	RegisterInstanceProvider("test", func(pool string) ([]string, error) {
+		if pool != "workers" {
+			return nil, errors.New("no such pool")
+		}
+		return []string{"test:///i-1", "test:///i-2", "test:///i-3"}, nil
+	})
+	poolNode := func(name string, providerID string) *corev1.Node {
+		node := readyNode(name, corev1.ConditionTrue)
+		node.Labels = map[string]string{"pool": "workers"}
+		node.Spec.ProviderID = providerID
+		return node
+	}
+
+	tests := []struct {
+		name     string
+		expected NodePoolSize
+		err      bool
+		messages []string
+	}{
+		{
+			name:     "static count",
+			expected: NodePoolSize{Count: 4},
+			messages: []string{"Node count with filter pool=workers is 2, but 4 instances are expected, 2 may have never joined the cluster!"},
+		},
+		{
+			name:     "static count met",
+			expected: NodePoolSize{Count: 2},
+		},
+		{
+			name:     "provider",
+			expected: NodePoolSize{Provider: "test", Pool: "workers"},
+			messages: []string{"Node count with filter pool=workers is 2, but provider test runs 3 instances in pool workers, 1 never joined the cluster: test:///i-2"},
+		},
+		{
+			name:     "provider error",
+			expected: NodePoolSize{Provider: "test", Pool: "missing"},
+			err:      true,
+		},
+		{
+			name:     "unknown provider",
+			expected: NodePoolSize{Provider: "unknown"},
+			err:      true,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(subT *testing.T) {
+			client := fake.NewSimpleClientset(poolNode("a", "test:///i-1"), poolNode("b", "test:///i-3"))
+			var messages []string
+			alertStub := func(_ string, _ string, alert Alert, _ AlertersConfig) {
+				messages = append(messages, alert.Message)
+			}
+			alertSpec := NodeAlertSpec{Name: "*", NodeFilter: "pool=workers", ReportStatus: NodeAlertStatus{ExpectedPoolSize: test.expected}}
+			err := PollNode(client, alertSpec, defaultTickerTime, alertStub, conf)
+			if test.err {
+				assert.Error(subT, err)
+				return
+			}
+			assert.NoError(subT, err)
+			assert.Equal(subT, test.messages, messages)
+		})
+	}
+}
+
+func Test_checkPoolSize_truncates(t *testing.T) {
+	_, conf := StubsInit()
+	var instances []string
+	for i := 0; i < 12; i++ {
+		instances = append(instances, fmt.Sprintf("test:///i-%02d", i))
+	}
+	RegisterInstanceProvider("many", func(string) ([]string, error) { return instances, nil })
+
+	var message string
+	alertStub := func(_ string, _ string, alert Alert, _ AlertersConfig) {
+		message = alert.Message
+	}
+	alertSpec := NodeAlertSpec{Name: "*", ReportStatus: NodeAlertStatus{ExpectedPoolSize: NodePoolSize{Provider: "many", Pool: "big"}}}
+	assert.NoError(t, checkPoolSize(nil, alertSpec, alertStub, conf))
+	assert.Contains(t, message, "12 never joined the cluster: test:///i-00, ")
+	assert.Contains(t, message, "test:///i-09, and 2 more")
+}
@@ -0,0 +1,130 @@
+// Copyright 2019 Bloomberg Finance LP
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package queries
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/bloomberg/k8eraid/pkgs/state"
+	"github.com/bloomberg/k8eraid/pkgs/types"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+const (
+	defaultPressureMemoryAvailableBytes   = 100 << 20
+	defaultPressureNodeFsAvailablePercent = 10
+)
+
+// checkNodePressureForecast samples the available memory and root filesystem space of a Ready node and
+// alerts when their trend reaches the kubelet's eviction thresholds within the rule's horizon. Nodes
+// whose kubelet cannot be reached are skipped, and so is any resource the kubelet does not report.
+func checkNodePressureForecast(
+	clientset kubernetes.Interface,
+	node *corev1.Node,
+	alertSpec types.NodeAlertSpec,
+	now time.Time,
+	alertFn alertFunction,
+	alertersConfig types.AlertersConfig,
+) {
+	forecast := alertSpec.ReportStatus.PressureForecast
+	if forecast.Window <= 0 || !nodeReady(node) {
+		return
+	}
+	availability, err := nodeResourceAvailability(clientset, node.Name)
+	if err != nil {
+		errLogger.Printf("Stats summary unavailable for node %s, skipping its pressure forecast: %s", node.Name, err.Error())
+		return
+	}
+
+	resource := resourceID("node", "", node.Name)
+	if available := availability.MemoryAvailableBytes; available != nil {
+		threshold := forecast.MemoryAvailableBytes
+		if threshold == 0 {
+			threshold = defaultPressureMemoryAvailableBytes
+		}
+		forecastPressure(resource, "MemoryPressureForecast", node.Name, string(corev1.NodeMemoryPressure), "of memory",
+			*available, threshold, now, alertSpec, alertFn, alertersConfig)
+	}
+	if available, capacity := availability.NodeFsAvailableBytes, availability.NodeFsCapacityBytes; available != nil && capacity != nil && *capacity > 0 {
+		percent := forecast.NodeFsAvailablePercent
+		if percent == 0 {
+			percent = defaultPressureNodeFsAvailablePercent
+		}
+		forecastPressure(resource, "DiskPressureForecast", node.Name, string(corev1.NodeDiskPressure), "of the "+formatBytes(*capacity)+" root filesystem",
+			*available, uint64(float64(*capacity)*percent/100), now, alertSpec, alertFn, alertersConfig)
+	}
+}
+
+// forecastPressure records what is available of one resource of a node and alerts when its trend
+// crosses threshold within the horizon. A resource already below threshold is left to the
+// condition itself.
+func forecastPressure(
+	resource string,
+	check string,
+	nodeName string,
+	condition string,
+	description string,
+	available uint64,
+	threshold uint64,
+	now time.Time,
+	alertSpec types.NodeAlertSpec,
+	alertFn alertFunction,
+	alertersConfig types.AlertersConfig,
+) {
+	forecast := alertSpec.ReportStatus.PressureForecast
+	samples, ok := recordTrend(resource+"/"+check, float64(available), now, time.Duration(forecast.Window)*time.Second, forecast.MinSamples)
+	if !ok || available <= threshold {
+		return
+	}
+	perSecond := state.Slope(samples)
+	if perSecond >= 0 {
+		return
+	}
+	eta := time.Duration(float64(available-threshold) / -perSecond * float64(time.Second))
+	if eta > time.Duration(forecast.HorizonSeconds)*time.Second {
+		return
+	}
+
+	precision := time.Minute
+	if eta < time.Minute {
+		precision = time.Second
+	}
+
+	// ALERT
+	alertmessage := fmt.Sprintf(
+		"Node %s is projected to come under %s in about %s: %s %s is available, falling by %s per hour towards the %s eviction threshold!",
+		nodeName,
+		condition,
+		eta.Round(precision),
+		formatBytes(available),
+		description,
+		formatBytes(uint64(-perSecond*time.Hour.Seconds())),
+		formatBytes(threshold),
+	)
+	alert := newAlert(resource, check, types.SeverityWarning, alertmessage)
+	alertFn(alertSpec.AlerterType, alertSpec.AlerterName, alert, alertersConfig)
+}
+
+func nodeReady(node *corev1.Node) bool {
+	for _, condition := range node.Status.Conditions {
+		if condition.Type == corev1.NodeReady {
+			return condition.Status == corev1.ConditionTrue
+		}
+	}
+	return false
+}
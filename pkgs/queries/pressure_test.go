@@ -0,0 +1,138 @@
+// Copyright 2019 Bloomberg Finance LP
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package queries
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/bloomberg/k8eraid/pkgs/state"
+	. "github.com/bloomberg/k8eraid/pkgs/types"
+
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func bytesPtr(b uint64) *uint64 {
+	return &b
+}
+
+func Test_checkNodePressureForecast(t *testing.T) {
+	_, conf := StubsInit()
+	const gi = uint64(1) << 30
+	falling := []nodeAvailability{
+		{MemoryAvailableBytes: bytesPtr(6 * gi), NodeFsAvailableBytes: bytesPtr(30 * gi), NodeFsCapacityBytes: bytesPtr(100 * gi)},
+		{MemoryAvailableBytes: bytesPtr(5 * gi), NodeFsAvailableBytes: bytesPtr(25 * gi), NodeFsCapacityBytes: bytesPtr(100 * gi)},
+		{MemoryAvailableBytes: bytesPtr(4 * gi), NodeFsAvailableBytes: bytesPtr(20 * gi), NodeFsCapacityBytes: bytesPtr(100 * gi)},
+	}
+	memory := "node/worker-1/MemoryPressureForecast: Node worker-1 is projected to come under MemoryPressure in about 1h18m0s: " +
+		"4.0GiB of memory is available, falling by 3.0GiB per hour towards the 100.0MiB eviction threshold!"
+	disk := "node/worker-1/DiskPressureForecast: Node worker-1 is projected to come under DiskPressure in about 40m0s: " +
+		"20.0GiB of the 100.0GiB root filesystem is available, falling by 15.0GiB per hour towards the 10.0GiB eviction threshold!"
+
+	tests := []struct {
+		name         string
+		ready        corev1.ConditionStatus
+		forecast     NodePressureForecast
+		availability []nodeAvailability
+		statsErr     error
+		expected     []string
+	}{
+		{
+			name:         "disk runs out within the horizon",
+			forecast:     NodePressureForecast{Window: 3600, HorizonSeconds: 3600},
+			availability: falling,
+			expected:     []string{disk},
+		},
+		{
+			name:         "both run out within a longer horizon",
+			forecast:     NodePressureForecast{Window: 3600, HorizonSeconds: 2 * 3600},
+			availability: falling,
+			expected:     []string{memory, disk},
+		},
+		{
+			name:         "lower thresholds are further away",
+			forecast:     NodePressureForecast{Window: 3600, HorizonSeconds: 3600, NodeFsAvailablePercent: 4},
+			availability: falling,
+		},
+		{
+			name:         "too few samples",
+			forecast:     NodePressureForecast{Window: 3600, HorizonSeconds: 3600},
+			availability: falling[:2],
+		},
+		{
+			name:         "rising availability",
+			forecast:     NodePressureForecast{Window: 3600, HorizonSeconds: 3600},
+			availability: []nodeAvailability{falling[2], falling[1], falling[0]},
+		},
+		{
+			name:     "memory not reported",
+			forecast: NodePressureForecast{Window: 3600, HorizonSeconds: 2 * 3600},
+			availability: []nodeAvailability{
+				{NodeFsAvailableBytes: falling[0].NodeFsAvailableBytes, NodeFsCapacityBytes: falling[0].NodeFsCapacityBytes},
+				{NodeFsAvailableBytes: falling[1].NodeFsAvailableBytes, NodeFsCapacityBytes: falling[1].NodeFsCapacityBytes},
+				{NodeFsAvailableBytes: falling[2].NodeFsAvailableBytes, NodeFsCapacityBytes: falling[2].NodeFsCapacityBytes},
+			},
+			expected: []string{disk},
+		},
+		{
+			name:         "stats unavailable",
+			forecast:     NodePressureForecast{Window: 3600, HorizonSeconds: 3600},
+			availability: falling,
+			statsErr:     errors.New("the server could not find the requested resource"),
+		},
+		{
+			name:         "node not ready",
+			ready:        corev1.ConditionFalse,
+			forecast:     NodePressureForecast{Window: 3600, HorizonSeconds: 3600},
+			availability: falling,
+		},
+		{
+			name:         "disabled",
+			availability: falling,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(subT *testing.T) {
+			stateStore = state.NewStore()
+			original := nodeResourceAvailability
+			defer func() { nodeResourceAvailability = original }()
+			var alerts []string
+			alertStub := func(_ string, _ string, alert Alert, _ AlertersConfig) {
+				alerts = append(alerts, alert.Key+": "+alert.Message)
+			}
+			ready := test.ready
+			if ready == "" {
+				ready = corev1.ConditionTrue
+			}
+			node := readyNode("worker-1", ready)
+			alertSpec := NodeAlertSpec{Name: "worker-1", ReportStatus: NodeAlertStatus{PressureForecast: test.forecast}}
+
+			start := time.Unix(10000, 0)
+			for i, availability := range test.availability {
+				availability := availability
+				nodeResourceAvailability = func(_ kubernetes.Interface, _ string) (nodeAvailability, error) {
+					return availability, test.statsErr
+				}
+				now := start.Add(time.Duration(i) * 20 * time.Minute)
+				checkNodePressureForecast(fake.NewSimpleClientset(), node, alertSpec, now, alertStub, conf)
+			}
+			assert.Equal(subT, test.expected, alerts)
+		})
+	}
+}
@@ -0,0 +1,220 @@
+// Copyright 2019 Bloomberg Finance LP
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package queries
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/bloomberg/k8eraid/pkgs/state"
+	"github.com/bloomberg/k8eraid/pkgs/types"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// volumeReclaimErrorReasons are the reasons of the events the PersistentVolume controller records
+// when it fails to reclaim a volume
+var volumeReclaimErrorReasons = map[string]bool{
+	"VolumeFailedDelete":  true,
+	"VolumeFailedRecycle": true,
+}
+
+// PollPV function takes inputs and iterates across PersistentVolumes in the kubernetes cluster, triggering alerts as needed.
+func PollPV(
+	clientset kubernetes.Interface,
+	alertSpec types.PVAlertSpec,
+	tickertime int64,
+	alertFn alertFunction,
+	alertersConfig types.AlertersConfig,
+) error {
+	var volumes []corev1.PersistentVolume
+
+	// If the volume is not wildcard, search by name
+	if alertSpec.Name != "*" {
+		volume, volumeerr := clientset.CoreV1().PersistentVolumes().Get(alertSpec.Name, metav1.GetOptions{})
+		if volumeerr != nil {
+			return &PollErr{
+				Message: fmt.Sprintf("Error fetching PersistentVolume %s: %s", alertSpec.Name, volumeerr.Error()),
+			}
+		}
+		volumes = append(volumes, *volume)
+		// If the volume is a wildcard, list volumes and iterate through
+	} else {
+		list, listerr := clientset.CoreV1().PersistentVolumes().List(metav1.ListOptions{
+			LabelSelector:  alertSpec.PVFilter,
+			TimeoutSeconds: &timeout,
+		})
+		if listerr != nil {
+			return &PollErr{
+				Message: fmt.Sprintf("Unable to list PersistentVolumes: %s", listerr.Error()),
+			}
+		}
+		volumes = list.Items
+	}
+	sort.Slice(volumes, func(i, j int) bool { return volumes[i].Name < volumes[j].Name })
+
+	now := time.Now()
+	checkPVPhases(volumes, alertSpec, now, alertFn, alertersConfig)
+	if alertSpec.ReportStatus.ReclaimErrors {
+		return checkPVReclaimErrors(clientset, volumes, alertSpec, alertFn, alertersConfig)
+	}
+	return nil
+}
+
+// checkPVPhases alerts on volumes that have been Failed or Released for longer than their threshold.
+// Volumes have no record of when they entered their phase, so when k8eraid first saw them in it is
+// remembered. That is never taken to be earlier than the first tick of the rule: the creation time
+// of a volume says nothing of when it was released.
+func checkPVPhases(
+	volumes []corev1.PersistentVolume,
+	alertSpec types.PVAlertSpec,
+	now time.Time,
+	alertFn alertFunction,
+	alertersConfig types.AlertersConfig,
+) {
+	thresholds := map[corev1.PersistentVolumePhase]int64{
+		corev1.VolumeFailed:   alertSpec.ReportStatus.FailedThreshold,
+		corev1.VolumeReleased: alertSpec.ReportStatus.ReleasedThreshold,
+	}
+	since := map[string]time.Time{}
+	stateStore.UpdateSnapshot(specKey("persistentvolume", alertSpec.Name, alertSpec.PVFilter), func(previous state.Snapshot, _ bool) state.Snapshot {
+		current := state.Snapshot{}
+		for _, volume := range volumes {
+			if thresholds[volume.Status.Phase] <= 0 {
+				continue
+			}
+			entered := now
+			if phase, at := splitPhaseSince(previous[volume.Name]); phase == string(volume.Status.Phase) {
+				entered = at
+			}
+			since[volume.Name] = entered
+			current[volume.Name] = string(volume.Status.Phase) + " " + entered.UTC().Format(time.RFC3339)
+		}
+		return current
+	})
+
+	for _, volume := range volumes {
+		entered, tracked := since[volume.Name]
+		if !tracked {
+			continue
+		}
+		threshold := thresholds[volume.Status.Phase]
+		inPhaseFor := now.Sub(entered)
+		if inPhaseFor <= time.Duration(threshold)*time.Second {
+			continue
+		}
+		resource := resourceID("persistentvolume", "", volume.Name)
+		if volume.Status.Phase == corev1.VolumeFailed {
+			// ALERT
+			alertmessage := fmt.Sprintf(
+				"PersistentVolume %s has been Failed for %s, longer than %ds! Its %s reclaim failed: %s",
+				volume.Name, formatLongDuration(inPhaseFor), threshold, volume.Spec.PersistentVolumeReclaimPolicy, pvStatusMessage(&volume),
+			)
+			alert := newAlert(resource, "Failed", types.SeverityCritical, alertmessage)
+			alertFn(alertSpec.AlerterType, alertSpec.AlerterName, alert, alertersConfig)
+			continue
+		}
+		hint := "Its reclaim policy is Retain, so it must be cleaned up and deleted by hand."
+		if volume.Spec.PersistentVolumeReclaimPolicy != corev1.PersistentVolumeReclaimRetain {
+			hint = fmt.Sprintf("Its reclaim policy is %s, so its reclaim appears stuck.", volume.Spec.PersistentVolumeReclaimPolicy)
+		}
+		// ALERT
+		alertmessage := fmt.Sprintf(
+			"PersistentVolume %s has been Released from its claim %s for %s, longer than %ds! %s",
+			volume.Name, pvClaim(&volume), formatLongDuration(inPhaseFor), threshold, hint,
+		)
+		alert := newAlert(resource, "Released", types.SeverityWarning, alertmessage)
+		alertFn(alertSpec.AlerterType, alertSpec.AlerterName, alert, alertersConfig)
+	}
+}
+
+// splitPhaseSince reads a "<phase> <RFC 3339 time>" snapshot value
+func splitPhaseSince(value string) (string, time.Time) {
+	fields := strings.SplitN(value, " ", 2)
+	if len(fields) != 2 {
+		return "", time.Time{}
+	}
+	at, err := time.Parse(time.RFC3339, fields[1])
+	if err != nil {
+		return "", time.Time{}
+	}
+	return fields[0], at
+}
+
+// checkPVReclaimErrors alerts on the volumes not bound to a claim that the PersistentVolume
+// controller recorded a failed deletion or recycling for, with the latest error. Events of
+// cluster-scoped objects are recorded in the default namespace.
+func checkPVReclaimErrors(
+	clientset kubernetes.Interface,
+	volumes []corev1.PersistentVolume,
+	alertSpec types.PVAlertSpec,
+	alertFn alertFunction,
+	alertersConfig types.AlertersConfig,
+) error {
+	events, eventserr := clientset.CoreV1().Events(metav1.NamespaceDefault).List(metav1.ListOptions{
+		FieldSelector:  "involvedObject.kind=PersistentVolume,type=" + corev1.EventTypeWarning,
+		TimeoutSeconds: &timeout,
+	})
+	if eventserr != nil {
+		return &PollErr{
+			Message: fmt.Sprintf("Unable to list PersistentVolume events: %s", eventserr.Error()),
+		}
+	}
+	latest := map[string]corev1.Event{}
+	for _, event := range events.Items {
+		if event.InvolvedObject.Kind != "PersistentVolume" || !volumeReclaimErrorReasons[event.Reason] {
+			continue
+		}
+		previous, ok := latest[event.InvolvedObject.Name]
+		if !ok || previous.LastTimestamp.Before(&event.LastTimestamp) {
+			latest[event.InvolvedObject.Name] = event
+		}
+	}
+
+	for _, volume := range volumes {
+		event, failed := latest[volume.Name]
+		if !failed || volume.Status.Phase == corev1.VolumeBound || volume.Status.Phase == corev1.VolumeAvailable {
+			continue
+		}
+		// ALERT
+		alertmessage := fmt.Sprintf(
+			"PersistentVolume %s could not be reclaimed (%s, %d times): %s",
+			volume.Name, event.Reason, event.Count, event.Message,
+		)
+		alert := newAlert(resourceID("persistentvolume", "", volume.Name), "ReclaimError", types.SeverityCritical, alertmessage)
+		alertFn(alertSpec.AlerterType, alertSpec.AlerterName, alert, alertersConfig)
+	}
+	return nil
+}
+
+// pvClaim names the claim volume was bound to
+func pvClaim(volume *corev1.PersistentVolume) string {
+	if volume.Spec.ClaimRef == nil {
+		return "(unknown)"
+	}
+	return volume.Spec.ClaimRef.Namespace + "/" + volume.Spec.ClaimRef.Name
+}
+
+// pvStatusMessage describes why volume failed, as its status records it
+func pvStatusMessage(volume *corev1.PersistentVolume) string {
+	if volume.Status.Message == "" {
+		return "no reason was recorded"
+	}
+	return volume.Status.Message
+}
@@ -0,0 +1,115 @@
+// Copyright 2019 Bloomberg Finance LP
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package queries
+
+import (
+	"testing"
+	"time"
+
+	"github.com/bloomberg/k8eraid/pkgs/state"
+	. "github.com/bloomberg/k8eraid/pkgs/types"
+
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func persistentVolume(name string, phase corev1.PersistentVolumePhase, policy corev1.PersistentVolumeReclaimPolicy) corev1.PersistentVolume {
+	return corev1.PersistentVolume{
+		ObjectMeta: metav1.ObjectMeta{Name: name},
+		Spec: corev1.PersistentVolumeSpec{
+			PersistentVolumeReclaimPolicy: policy,
+			ClaimRef:                      &corev1.ObjectReference{Namespace: "db", Name: "data-" + name},
+		},
+		Status: corev1.PersistentVolumeStatus{Phase: phase},
+	}
+}
+
+func Test_checkPVPhases(t *testing.T) {
+	stateStore = state.NewStore()
+	_, conf := StubsInit()
+	alertSpec := PVAlertSpec{Name: "*", ReportStatus: PVAlertStatus{FailedThreshold: 60, ReleasedThreshold: 3600}}
+	failed := persistentVolume("pv-a", corev1.VolumeFailed, corev1.PersistentVolumeReclaimRecycle)
+	failed.Status.Message = "recycler pod failed"
+	volumes := []corev1.PersistentVolume{
+		failed,
+		persistentVolume("pv-b", corev1.VolumeReleased, corev1.PersistentVolumeReclaimRetain),
+		persistentVolume("pv-c", corev1.VolumeReleased, corev1.PersistentVolumeReclaimDelete),
+		persistentVolume("pv-d", corev1.VolumeBound, corev1.PersistentVolumeReclaimDelete),
+	}
+	start := time.Unix(1000, 0)
+	check := func(volumes []corev1.PersistentVolume, after time.Duration) []string {
+		messages := []string{}
+		checkPVPhases(volumes, alertSpec, start.Add(after), func(_ string, _ string, alert Alert, _ AlertersConfig) {
+			messages = append(messages, alert.Message)
+		}, conf)
+		return messages
+	}
+
+	assert.Empty(t, check(volumes, 0), "volumes first seen in a phase just entered it")
+	assert.Equal(t, []string{
+		"PersistentVolume pv-a has been Failed for 2m0s, longer than 60s! Its Recycle reclaim failed: recycler pod failed",
+	}, check(volumes, 2*time.Minute))
+	assert.Equal(t, []string{
+		"PersistentVolume pv-a has been Failed for 2h0m0s, longer than 60s! Its Recycle reclaim failed: recycler pod failed",
+		"PersistentVolume pv-b has been Released from its claim db/data-pv-b for 2h0m0s, longer than 3600s! Its reclaim policy is Retain, so it must be cleaned up and deleted by hand.",
+		"PersistentVolume pv-c has been Released from its claim db/data-pv-c for 2h0m0s, longer than 3600s! Its reclaim policy is Delete, so its reclaim appears stuck.",
+	}, check(volumes, 2*time.Hour))
+
+	volumes[2].Status.Phase = corev1.VolumeFailed
+	assert.Equal(t, []string{
+		"PersistentVolume pv-a has been Failed for 2h1m0s, longer than 60s! Its Recycle reclaim failed: recycler pod failed",
+		"PersistentVolume pv-b has been Released from its claim db/data-pv-b for 2h1m0s, longer than 3600s! Its reclaim policy is Retain, so it must be cleaned up and deleted by hand.",
+	}, check(volumes, 2*time.Hour+time.Minute), "a volume changing phase starts counting again")
+}
+
+func Test_PollPV_ReclaimErrors(t *testing.T) {
+	stateStore = state.NewStore()
+	_, conf := StubsInit()
+	released := persistentVolume("pv-a", corev1.VolumeReleased, corev1.PersistentVolumeReclaimDelete)
+	bound := persistentVolume("pv-b", corev1.VolumeBound, corev1.PersistentVolumeReclaimDelete)
+	event := func(name string, volume string, reason string, last time.Time, message string) *corev1.Event {
+		return &corev1.Event{
+			ObjectMeta:     metav1.ObjectMeta{Name: name, Namespace: metav1.NamespaceDefault},
+			InvolvedObject: corev1.ObjectReference{Kind: "PersistentVolume", Name: volume},
+			Type:           corev1.EventTypeWarning,
+			Reason:         reason,
+			Count:          3,
+			LastTimestamp:  metav1.Time{Time: last},
+			Message:        message,
+		}
+	}
+	now := time.Now()
+	client := fake.NewSimpleClientset(
+		&released, &bound,
+		event("pv-a.1", "pv-a", "VolumeFailedDelete", now.Add(-time.Hour), "timed out"),
+		event("pv-a.2", "pv-a", "VolumeFailedDelete", now.Add(-time.Minute), "disk is still attached to node worker-1"),
+		event("pv-a.3", "pv-a", "ProvisioningFailed", now, "unrelated"),
+		event("pv-b.1", "pv-b", "VolumeFailedDelete", now, "bound again since"),
+	)
+
+	var messages []string
+	alertStub := func(_ string, _ string, alert Alert, _ AlertersConfig) {
+		messages = append(messages, alert.Message)
+	}
+	err := PollPV(client, PVAlertSpec{Name: "*", ReportStatus: PVAlertStatus{ReclaimErrors: true}}, defaultTickerTime, alertStub, conf)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{
+		"PersistentVolume pv-a could not be reclaimed (VolumeFailedDelete, 3 times): disk is still attached to node worker-1",
+	}, messages)
+
+	assert.Error(t, PollPV(client, PVAlertSpec{Name: "pv-absent"}, defaultTickerTime, alertStub, conf))
+}
@@ -0,0 +1,188 @@
+// Copyright 2019 Bloomberg Finance LP
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package queries
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/bloomberg/k8eraid/pkgs/types"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// PollPVC function takes inputs and iterates across PersistentVolumeClaims in the kubernetes cluster, triggering alerts as needed.
+func PollPVC(
+	clientset kubernetes.Interface,
+	alertSpec types.PVCAlertSpec,
+	tickertime int64,
+	alertFn alertFunction,
+	alertersConfig types.AlertersConfig,
+) error {
+
+	var pvcs []corev1.PersistentVolumeClaim
+
+	// Check rules with matching literal claim name
+	if alertSpec.Name != "*" {
+		if alertSpec.PVCFilterNamespace == "" {
+			return &PollErr{
+				Message: fmt.Sprintf("PersistentVolumeClaim rule for %s has no namespace filter specified, ignoring", alertSpec.Name),
+			}
+		}
+		pvc, pvcerr := clientset.CoreV1().PersistentVolumeClaims(alertSpec.PVCFilterNamespace).Get(alertSpec.Name, metav1.GetOptions{})
+		if pvcerr != nil {
+			return &PollErr{
+				Message: fmt.Sprintf("Error fetching PersistentVolumeClaim %s: %s", alertSpec.Name, pvcerr.Error()),
+			}
+		}
+		pvcs = append(pvcs, *pvc)
+		// If the claim name is a wildcard, list based on filter and iterate through
+	} else {
+		listopts := metav1.ListOptions{
+			LabelSelector:  alertSpec.PVCFilterLabel,
+			TimeoutSeconds: &timeout,
+		}
+		pvclist, pvcserr := clientset.CoreV1().PersistentVolumeClaims(alertSpec.PVCFilterNamespace).List(listopts)
+		if pvcserr != nil {
+			return &PollErr{
+				Message: fmt.Sprintf("Unable to list PersistentVolumeClaims: %s", pvcserr.Error()),
+			}
+		}
+		pvcs = pvclist.Items
+	}
+
+	for i := range pvcs {
+		checkPVCPhase(&pvcs[i], alertSpec, time.Now(), alertFn, alertersConfig)
+	}
+	if alertSpec.ReportStatus.UsagePercent > 0 {
+		if err := checkPVCUsage(clientset, pvcs, alertSpec, alertFn, alertersConfig); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// checkPVCPhase alerts on claims that were not bound within the pending threshold, which points at
+// failing provisioning, and on claims whose volume was lost
+func checkPVCPhase(
+	pvc *corev1.PersistentVolumeClaim,
+	alertSpec types.PVCAlertSpec,
+	now time.Time,
+	alertFn alertFunction,
+	alertersConfig types.AlertersConfig,
+) {
+	resource := resourceID("pvc", pvc.Namespace, pvc.Name)
+	switch pvc.Status.Phase {
+	case corev1.ClaimPending:
+		threshold := alertSpec.ReportStatus.PendingThreshold
+		pendingFor := now.Sub(pvc.CreationTimestamp.Time)
+		if threshold <= 0 || pendingFor <= time.Duration(threshold)*time.Second {
+			return
+		}
+		storageClass := "the default storage class"
+		if pvc.Spec.StorageClassName != nil {
+			storageClass = fmt.Sprintf("storage class %q", *pvc.Spec.StorageClassName)
+		}
+		// ALERT
+		alertmessage := fmt.Sprintf(
+			"PersistentVolumeClaim %s/%s has been Pending for %s, longer than %ds! No volume of %s was provisioned or bound for it.",
+			pvc.Namespace, pvc.Name, formatLongDuration(pendingFor), threshold, storageClass,
+		)
+		alert := newAlert(resource, "Pending", types.SeverityWarning, alertmessage)
+		alertFn(alertSpec.AlerterType, alertSpec.AlerterName, alert, alertersConfig)
+	case corev1.ClaimLost:
+		if !alertSpec.ReportStatus.Lost {
+			return
+		}
+		// ALERT
+		alertmessage := fmt.Sprintf(
+			"PersistentVolumeClaim %s/%s is Lost, its PersistentVolume %s no longer exists! Pods mounting it cannot start.",
+			pvc.Namespace, pvc.Name, pvc.Spec.VolumeName,
+		)
+		alert := newAlert(resource, "Lost", types.SeverityCritical, alertmessage)
+		alertFn(alertSpec.AlerterType, alertSpec.AlerterName, alert, alertersConfig)
+	}
+}
+
+// checkPVCUsage alerts on claims whose volume usage, as reported by the kubelet of the node
+// mounting them, is above the configured percentage. Claims without stats are skipped.
+func checkPVCUsage(
+	clientset kubernetes.Interface,
+	pvcs []corev1.PersistentVolumeClaim,
+	alertSpec types.PVCAlertSpec,
+	alertFn alertFunction,
+	alertersConfig types.AlertersConfig,
+) error {
+	if len(pvcs) == 0 {
+		return nil
+	}
+
+	// Only the kubelet of the node mounting a claim knows its usage, so find those nodes first
+	pods, podserr := clientset.CoreV1().Pods(alertSpec.PVCFilterNamespace).List(metav1.ListOptions{TimeoutSeconds: &timeout})
+	if podserr != nil {
+		return &PollErr{
+			Message: fmt.Sprintf("Unable to list pods mounting PersistentVolumeClaims: %s", podserr.Error()),
+		}
+	}
+	claimNodes := map[string]string{}
+	for _, pod := range pods.Items {
+		if pod.Spec.NodeName == "" {
+			continue
+		}
+		for _, volume := range pod.Spec.Volumes {
+			if volume.PersistentVolumeClaim != nil {
+				claimNodes[pod.Namespace+"/"+volume.PersistentVolumeClaim.ClaimName] = pod.Spec.NodeName
+			}
+		}
+	}
+
+	nodeUsage := map[string]map[string]volumeUsage{}
+	for _, pvc := range pvcs {
+		claim := pvc.Namespace + "/" + pvc.Name
+		nodeName, mounted := claimNodes[claim]
+		if !mounted {
+			continue
+		}
+		usage, fetched := nodeUsage[nodeName]
+		if !fetched {
+			var err error
+			if usage, err = nodeVolumeUsage(clientset, nodeName); err != nil {
+				errLogger.Printf("Volume stats unavailable for node %s, skipping its claims: %s", nodeName, err.Error())
+			}
+			nodeUsage[nodeName] = usage
+		}
+		stats, ok := usage[claim]
+		if !ok {
+			continue
+		}
+		usedPercent := float64(stats.UsedBytes) / float64(stats.CapacityBytes) * 100
+		if usedPercent >= alertSpec.ReportStatus.UsagePercent {
+			// ALERT
+			alertmessage := fmt.Sprintf(
+				"PersistentVolumeClaim %s is %.1f%% full (%s of %s used), above the %.0f%% threshold!",
+				claim,
+				usedPercent,
+				formatBytes(stats.UsedBytes),
+				formatBytes(stats.CapacityBytes),
+				alertSpec.ReportStatus.UsagePercent,
+			)
+			alert := newAlert(resourceID("pvc", pvc.Namespace, pvc.Name), "UsagePercent", types.SeverityWarning, alertmessage)
+			alertFn(alertSpec.AlerterType, alertSpec.AlerterName, alert, alertersConfig)
+		}
+	}
+	return nil
+}
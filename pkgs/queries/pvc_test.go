@@ -0,0 +1,167 @@
+// Copyright 2019 Bloomberg Finance LP
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package queries
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	. "github.com/bloomberg/k8eraid/pkgs/types"
+
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func pvcWithPod(usage map[string]volumeUsage, statsErr error) (kubernetes.Interface, func()) {
+	pvc := &corev1.PersistentVolumeClaim{
+		ObjectMeta: metav1.ObjectMeta{Name: "data", Namespace: metav1.NamespaceDefault},
+	}
+	pod := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: "db-0", Namespace: metav1.NamespaceDefault},
+		Spec: corev1.PodSpec{
+			NodeName: "worker-1",
+			Volumes: []corev1.Volume{{
+				Name: "data",
+				VolumeSource: corev1.VolumeSource{
+					PersistentVolumeClaim: &corev1.PersistentVolumeClaimVolumeSource{ClaimName: "data"},
+				},
+			}},
+		},
+	}
+	original := nodeVolumeUsage
+	nodeVolumeUsage = func(_ kubernetes.Interface, _ string) (map[string]volumeUsage, error) {
+		return usage, statsErr
+	}
+	return fake.NewSimpleClientset(pvc, pod), func() { nodeVolumeUsage = original }
+}
+
+func Test_PollPVC_UsagePercent(t *testing.T) {
+	_, conf := StubsInit()
+	alertSpec := PVCAlertSpec{
+		Name:               "data",
+		PVCFilterNamespace: metav1.NamespaceDefault,
+		ReportStatus:       PVCAlertStatus{UsagePercent: 90},
+	}
+
+	tests := []struct {
+		name        string
+		usage       map[string]volumeUsage
+		statsErr    error
+		shouldAlert bool
+	}{
+		{
+			name:        "pvc over threshold: alert",
+			usage:       map[string]volumeUsage{"default/data": {CapacityBytes: 100, UsedBytes: 95}},
+			shouldAlert: true,
+		},
+		{
+			name:  "pvc under threshold: no alert",
+			usage: map[string]volumeUsage{"default/data": {CapacityBytes: 100, UsedBytes: 50}},
+		},
+		{
+			name:  "pvc without stats: no alert",
+			usage: map[string]volumeUsage{},
+		},
+		{
+			name:     "stats unavailable: no alert, no error",
+			statsErr: errors.New("the server could not find the requested resource"),
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(subT *testing.T) {
+			client, restore := pvcWithPod(test.usage, test.statsErr)
+			defer restore()
+			var message string
+			alertStub := func(_ string, _ string, alert Alert, _ AlertersConfig) {
+				message = alert.Message
+			}
+			if err := PollPVC(client, alertSpec, defaultTickerTime, alertStub, conf); err != nil {
+				subT.Errorf("PollPVC returned an unexpected error: %s", err.Error())
+			}
+			if test.shouldAlert != (message != "") {
+				subT.Errorf("expected alert: %t, got message %q", test.shouldAlert, message)
+			}
+			if test.shouldAlert && message != "PersistentVolumeClaim default/data is 95.0% full (95B of 100B used), above the 90% threshold!" {
+				subT.Errorf("unexpected alert message %q", message)
+			}
+		})
+	}
+}
+
+func Test_PollPVC_Phase(t *testing.T) {
+	_, conf := StubsInit()
+	fast := "fast"
+	created := metav1.Time{Time: time.Now().Add(-time.Hour)}
+	client := fake.NewSimpleClientset(
+		&corev1.PersistentVolumeClaim{
+			ObjectMeta: metav1.ObjectMeta{Name: "pending", Namespace: metav1.NamespaceDefault, CreationTimestamp: created},
+			Spec:       corev1.PersistentVolumeClaimSpec{StorageClassName: &fast},
+			Status:     corev1.PersistentVolumeClaimStatus{Phase: corev1.ClaimPending},
+		},
+		&corev1.PersistentVolumeClaim{
+			ObjectMeta: metav1.ObjectMeta{Name: "lost", Namespace: metav1.NamespaceDefault, CreationTimestamp: created},
+			Spec:       corev1.PersistentVolumeClaimSpec{VolumeName: "pv-1"},
+			Status:     corev1.PersistentVolumeClaimStatus{Phase: corev1.ClaimLost},
+		},
+		&corev1.PersistentVolumeClaim{
+			ObjectMeta: metav1.ObjectMeta{Name: "bound", Namespace: metav1.NamespaceDefault, CreationTimestamp: created},
+			Status:     corev1.PersistentVolumeClaimStatus{Phase: corev1.ClaimBound},
+		},
+	)
+
+	tests := []struct {
+		name     string
+		status   PVCAlertStatus
+		expected []string
+	}{
+		{name: "checks disabled: no alert", expected: []string{}},
+		{
+			name:   "pending and lost: alert",
+			status: PVCAlertStatus{PendingThreshold: 600, Lost: true},
+			expected: []string{
+				"PersistentVolumeClaim default/lost is Lost, its PersistentVolume pv-1 no longer exists! Pods mounting it cannot start.",
+				"PersistentVolumeClaim default/pending has been Pending for 1h0m0s, longer than 600s! No volume of storage class \"fast\" was provisioned or bound for it.",
+			},
+		},
+		{name: "pending within the threshold: no alert", status: PVCAlertStatus{PendingThreshold: 7200}, expected: []string{}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(subT *testing.T) {
+			messages := []string{}
+			alertStub := func(_ string, _ string, alert Alert, _ AlertersConfig) {
+				messages = append(messages, alert.Message)
+			}
+			alertSpec := PVCAlertSpec{Name: "*", PVCFilterNamespace: metav1.NamespaceDefault, ReportStatus: test.status}
+			assert.NoError(subT, PollPVC(client, alertSpec, defaultTickerTime, alertStub, conf))
+			assert.ElementsMatch(subT, test.expected, messages)
+		})
+	}
+}
+
+func Test_FormatBytes(t *testing.T) {
+	for input, expected := range map[uint64]string{
+		512:                     "512B",
+		1536:                    "1.5KiB",
+		10 * 1024 * 1024 * 1024: "10.0GiB",
+	} {
+		if got := formatBytes(input); got != expected {
+			t.Errorf("formatBytes(%d) = %s, expected %s", input, got, expected)
+		}
+	}
+}
@@ -16,16 +16,71 @@ package queries
 
 import (
 	"log"
+	"os"
+	"strings"
+	"time"
 
+	"github.com/bloomberg/k8eraid/pkgs/state"
 	"github.com/bloomberg/k8eraid/pkgs/types"
 )
 
+// defaultPendingThreshold is how many seconds an object must exist before it is checked, unless its rule says otherwise
+const defaultPendingThreshold = 10
+
 var (
 	logger    *log.Logger
 	errLogger *log.Logger
 	timeout   = int64(5)
+	// stateStore remembers what previous ticks observed
+	stateStore = state.NewStore()
 )
 
+func init() {
+	// Set up stdout and stderr loggers
+	logger = log.New(os.Stdout, "queries", log.LstdFlags)
+	errLogger = log.New(os.Stderr, "queries", log.LstdFlags)
+}
+
+// LoadState restores what a previous run of k8eraid remembered from the file SaveState wrote to
+// path, if any. Restored active alerts count as raised at now, so they only resolve when the polls
+// after the restart stop raising them, and SkipRestoredAlerts does not deliver them again.
+func LoadState(path string, now time.Time) error {
+	store, err := state.Load(path)
+	if err != nil {
+		return err
+	}
+	restored := map[string]bool{}
+	for _, alert := range store.ActiveAlerts() {
+		alert.LastSeen = now
+		store.FireAlert(alert)
+		restored[alert.Key] = true
+	}
+	restoredAlertsMu.Lock()
+	restoredAlerts = restored
+	restoredAlertsMu.Unlock()
+	stateStore = store
+	publishActiveAlerts()
+	return nil
+}
+
+// SaveState writes what k8eraid remembers from previous ticks to path
+func SaveState(path string, now time.Time) error {
+	FlushState()
+	return stateStore.Save(path, now)
+}
+
+// FlushState records the samples still buffered on their way to the state, such as the latency of
+// the latest apiserver calls
+func FlushState() {
+	apiLatencySamples.Flush(stateStore)
+}
+
+// ExpireState forgets the state no poll wrote since cutoff, such as the restart counts of deleted
+// pods, and returns how many keys it forgot
+func ExpireState(cutoff time.Time) int {
+	return stateStore.Expire(cutoff)
+}
+
 // PollErr is an error returned by Poll*
 type PollErr struct {
 	Message string
@@ -35,4 +90,48 @@ func (err *PollErr) Error() string {
 	return err.Message
 }
 
-type alertFunction func(string, string, string, types.AlertersConfig)
+type alertFunction func(string, string, types.Alert, types.AlertersConfig)
+
+// resourceID builds the identifier of an object, e.g. pod/kube-system/kube-dns-1234.
+// Empty parts are left out, so cluster scoped objects become node/worker-1.
+func resourceID(kind string, namespace string, name string) string {
+	parts := []string{kind}
+	for _, part := range []string{namespace, name} {
+		if part != "" {
+			parts = append(parts, part)
+		}
+	}
+	return strings.Join(parts, "/")
+}
+
+// unhealthyFor returns how long an object has been unhealthy in the way key tracks, remembering when
+// k8eraid first saw it so across ticks, and forgets it once it is healthy again. An object is never
+// unhealthy for longer than it exists, since it was created.
+func unhealthyFor(key string, unhealthy bool, created time.Time, now time.Time) time.Duration {
+	if !unhealthy {
+		stateStore.ForgetSnapshot(key)
+		return 0
+	}
+	since := now
+	stateStore.UpdateSnapshot(key, func(previous state.Snapshot, _ bool) state.Snapshot {
+		if recorded, err := time.Parse(time.RFC3339, previous["since"]); err == nil {
+			since = recorded
+		}
+		return state.Snapshot{"since": since.UTC().Format(time.RFC3339)}
+	})
+	if since.Before(created) {
+		since = created
+	}
+	return now.Sub(since)
+}
+
+// newAlert builds an alert about resource for the named check
+func newAlert(resource string, check string, severity string, message string) types.Alert {
+	return types.Alert{
+		Key:      resource + "/" + check,
+		Resource: resource,
+		Severity: severity,
+		Message:  message,
+		Time:     time.Now(),
+	}
+}
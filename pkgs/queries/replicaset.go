@@ -0,0 +1,131 @@
+// Copyright 2019 Bloomberg Finance LP
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package queries
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/bloomberg/k8eraid/pkgs/types"
+
+	appsv1 "k8s.io/api/apps/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// deploymentRevisionAnnotation is the revision of its Deployment a ReplicaSet runs
+const deploymentRevisionAnnotation = "deployment.kubernetes.io/revision"
+
+// PollReplicaSet function takes inputs and iterates across ReplicaSets in the kubernetes cluster, triggering alerts as needed.
+func PollReplicaSet(
+	clientset kubernetes.Interface,
+	alertSpec types.ReplicaSetAlertSpec,
+	tickertime int64,
+	alertFn alertFunction,
+	alertersConfig types.AlertersConfig,
+) error {
+	var replicaSets []appsv1.ReplicaSet
+
+	// Check rules with matching literal replicaset name
+	if alertSpec.Name != "*" {
+		if alertSpec.ReplicaSetFilterNamespace == "" {
+			return &PollErr{
+				Message: fmt.Sprintf("ReplicaSet rule for %s has no namespace filter specified, ignoring", alertSpec.Name),
+			}
+		}
+		replicaSet, replicaseterr := clientset.AppsV1().ReplicaSets(alertSpec.ReplicaSetFilterNamespace).Get(alertSpec.Name, metav1.GetOptions{})
+		if replicaseterr != nil {
+			return &PollErr{
+				Message: fmt.Sprintf("Error fetching ReplicaSet %s: %s", alertSpec.Name, replicaseterr.Error()),
+			}
+		}
+		replicaSets = append(replicaSets, *replicaSet)
+		// If the replicaset name is a wildcard, list based on filter and iterate through
+	} else {
+		list, listerr := clientset.AppsV1().ReplicaSets(alertSpec.ReplicaSetFilterNamespace).List(metav1.ListOptions{
+			LabelSelector:  alertSpec.ReplicaSetFilterLabel,
+			TimeoutSeconds: &timeout,
+		})
+		if listerr != nil {
+			return &PollErr{
+				Message: fmt.Sprintf("Unable to list ReplicaSets: %s", listerr.Error()),
+			}
+		}
+		replicaSets = list.Items
+	}
+
+	now := time.Now()
+	for i := range replicaSets {
+		checkReplicaSet(&replicaSets[i], alertSpec, now, alertFn, alertersConfig)
+	}
+	return nil
+}
+
+// checkReplicaSet alerts on a ReplicaSet that has had fewer available replicas than it wants for
+// longer than the threshold, which catches a rollout stuck on its new ReplicaSet while the Deployment
+// still counts the old one as available, and on ReplicaSets without a controller. When the
+// ReplicaSet started to fall short is remembered across ticks.
+func checkReplicaSet(
+	replicaSet *appsv1.ReplicaSet,
+	alertSpec types.ReplicaSetAlertSpec,
+	now time.Time,
+	alertFn alertFunction,
+	alertersConfig types.AlertersConfig,
+) {
+	resource := resourceID("replicaset", replicaSet.Namespace, replicaSet.Name)
+	desired := int32(1)
+	if replicaSet.Spec.Replicas != nil {
+		desired = *replicaSet.Spec.Replicas
+	}
+	controller := metav1.GetControllerOf(replicaSet)
+
+	threshold := alertSpec.ReportStatus.UnavailableThreshold
+	short := threshold > 0 && replicaSet.Status.AvailableReplicas < desired
+	if shortFor := unhealthyFor("unavailable/"+resource, short, replicaSet.CreationTimestamp.Time, now); short && shortFor > time.Duration(threshold)*time.Second {
+		owner := ""
+		if controller != nil {
+			owner = fmt.Sprintf(" It runs revision %s of %s %s.", replicaSetRevision(replicaSet), controller.Kind, controller.Name)
+		}
+		// ALERT
+		alertmessage := fmt.Sprintf(
+			"ReplicaSet %s/%s has had only %d of %d replicas available for %s, longer than %ds!%s",
+			replicaSet.Namespace, replicaSet.Name, replicaSet.Status.AvailableReplicas, desired, formatLongDuration(shortFor), threshold, owner,
+		)
+		alert := newAlert(resource, "Unavailable", types.SeverityCritical, alertmessage)
+		alertFn(alertSpec.AlerterType, alertSpec.AlerterName, alert, alertersConfig)
+	}
+
+	if alertSpec.ReportStatus.Orphaned && controller == nil {
+		hint := fmt.Sprintf("its %d replicas are not rolled out or scaled by anything", desired)
+		if desired == 0 {
+			hint = "it has no replicas and can likely be deleted"
+		}
+		// ALERT
+		alertmessage := fmt.Sprintf(
+			"ReplicaSet %s/%s is not controlled by a Deployment or any other owner, %s!",
+			replicaSet.Namespace, replicaSet.Name, hint,
+		)
+		alert := newAlert(resource, "Orphaned", types.SeverityWarning, alertmessage)
+		alertFn(alertSpec.AlerterType, alertSpec.AlerterName, alert, alertersConfig)
+	}
+}
+
+// replicaSetRevision returns the revision of its Deployment replicaSet runs, or "(unknown)"
+func replicaSetRevision(replicaSet *appsv1.ReplicaSet) string {
+	if revision := replicaSet.Annotations[deploymentRevisionAnnotation]; revision != "" {
+		return revision
+	}
+	return "(unknown)"
+}
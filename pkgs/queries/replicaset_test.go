@@ -0,0 +1,88 @@
+// Copyright 2019 Bloomberg Finance LP
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package queries
+
+import (
+	"testing"
+	"time"
+
+	"github.com/bloomberg/k8eraid/pkgs/state"
+	. "github.com/bloomberg/k8eraid/pkgs/types"
+
+	"github.com/stretchr/testify/assert"
+	appsv1 "k8s.io/api/apps/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func Test_CheckReplicaSet(t *testing.T) {
+	stateStore = state.NewStore()
+	start := time.Unix(1000, 0)
+	web := &appsv1.Deployment{ObjectMeta: metav1.ObjectMeta{Name: "web", Namespace: metav1.NamespaceDefault, UID: "web"}}
+	replicas := int32(3)
+	replicaSet := &appsv1.ReplicaSet{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:            "web-7",
+			Namespace:       metav1.NamespaceDefault,
+			Annotations:     map[string]string{deploymentRevisionAnnotation: "7"},
+			OwnerReferences: []metav1.OwnerReference{*metav1.NewControllerRef(web, appsv1.SchemeGroupVersion.WithKind("Deployment"))},
+		},
+		Spec:   appsv1.ReplicaSetSpec{Replicas: &replicas},
+		Status: appsv1.ReplicaSetStatus{AvailableReplicas: 1},
+	}
+	alertSpec := ReplicaSetAlertSpec{ReportStatus: ReplicaSetAlertStatus{UnavailableThreshold: 600, Orphaned: true}}
+
+	assert.Empty(t, CheckReplicaSet(replicaSet, alertSpec, start).Alerts, "a ReplicaSet first seen short just started to be")
+	assert.Equal(t, []string{
+		"ReplicaSet default/web-7 has had only 1 of 3 replicas available for 15m0s, longer than 600s! It runs revision 7 of Deployment web.",
+	}, CheckReplicaSet(replicaSet, alertSpec, start.Add(15*time.Minute)).Messages())
+	replicaSet.Status.AvailableReplicas = 3
+	assert.Empty(t, CheckReplicaSet(replicaSet, alertSpec, start.Add(16*time.Minute)).Alerts)
+	replicaSet.Status.AvailableReplicas = 2
+	assert.Empty(t, CheckReplicaSet(replicaSet, alertSpec, start.Add(17*time.Minute)).Alerts, "recovering resets the count")
+
+	orphan := &appsv1.ReplicaSet{ObjectMeta: metav1.ObjectMeta{Name: "legacy", Namespace: metav1.NamespaceDefault}, Status: appsv1.ReplicaSetStatus{AvailableReplicas: 1}}
+	assert.Equal(t, []string{
+		"ReplicaSet default/legacy is not controlled by a Deployment or any other owner, its 1 replicas are not rolled out or scaled by anything!",
+	}, CheckReplicaSet(orphan, alertSpec, start).Messages())
+	orphan.Spec.Replicas = new(int32)
+	assert.Equal(t, []string{
+		"ReplicaSet default/legacy is not controlled by a Deployment or any other owner, it has no replicas and can likely be deleted!",
+	}, CheckReplicaSet(orphan, alertSpec, start).Messages())
+}
+
+func Test_PollReplicaSet(t *testing.T) {
+	stateStore = state.NewStore()
+	_, conf := StubsInit()
+	client := fake.NewSimpleClientset(&appsv1.ReplicaSet{
+		ObjectMeta: metav1.ObjectMeta{Name: "legacy", Namespace: metav1.NamespaceDefault, Labels: map[string]string{"app": "legacy"}},
+	})
+
+	for _, spec := range []ReplicaSetAlertSpec{
+		{Name: "legacy", ReplicaSetFilterNamespace: metav1.NamespaceDefault},
+		{Name: "*", ReplicaSetFilterLabel: "app=legacy"},
+	} {
+		spec.ReportStatus = ReplicaSetAlertStatus{Orphaned: true}
+		keys := []string{}
+		err := PollReplicaSet(client, spec, defaultTickerTime, func(_ string, _ string, alert Alert, _ AlertersConfig) {
+			keys = append(keys, alert.Key)
+		}, conf)
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"replicaset/default/legacy/Orphaned"}, keys, spec.Name)
+	}
+
+	assert.Error(t, PollReplicaSet(client, ReplicaSetAlertSpec{Name: "legacy"}, defaultTickerTime, nil, conf), "named rules need a namespace")
+	assert.Error(t, PollReplicaSet(client, ReplicaSetAlertSpec{Name: "absent", ReplicaSetFilterNamespace: metav1.NamespaceDefault}, defaultTickerTime, nil, conf))
+}
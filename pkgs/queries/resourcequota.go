@@ -0,0 +1,123 @@
+// Copyright 2019 Bloomberg Finance LP
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package queries
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/bloomberg/k8eraid/pkgs/types"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// PollResourceQuota function takes inputs and iterates across ResourceQuotas in the kubernetes cluster, triggering alerts as needed.
+func PollResourceQuota(
+	clientset kubernetes.Interface,
+	alertSpec types.ResourceQuotaAlertSpec,
+	tickertime int64,
+	alertFn alertFunction,
+	alertersConfig types.AlertersConfig,
+) error {
+	var quotas []corev1.ResourceQuota
+
+	// Check rules with matching literal quota name
+	if alertSpec.Name != "*" {
+		if alertSpec.ResourceQuotaFilterNamespace == "" {
+			return &PollErr{
+				Message: fmt.Sprintf("ResourceQuota rule for %s has no namespace filter specified, ignoring", alertSpec.Name),
+			}
+		}
+		quota, quotaerr := clientset.CoreV1().ResourceQuotas(alertSpec.ResourceQuotaFilterNamespace).Get(alertSpec.Name, metav1.GetOptions{})
+		if quotaerr != nil {
+			return &PollErr{
+				Message: fmt.Sprintf("Error fetching ResourceQuota %s: %s", alertSpec.Name, quotaerr.Error()),
+			}
+		}
+		quotas = append(quotas, *quota)
+		// If the quota name is a wildcard, list based on filter and iterate through
+	} else {
+		list, listerr := clientset.CoreV1().ResourceQuotas(alertSpec.ResourceQuotaFilterNamespace).List(metav1.ListOptions{
+			LabelSelector:  alertSpec.ResourceQuotaFilterLabel,
+			TimeoutSeconds: &timeout,
+		})
+		if listerr != nil {
+			return &PollErr{
+				Message: fmt.Sprintf("Unable to list ResourceQuotas: %s", listerr.Error()),
+			}
+		}
+		quotas = list.Items
+	}
+
+	for i := range quotas {
+		checkResourceQuota(&quotas[i], alertSpec, alertFn, alertersConfig)
+	}
+	return nil
+}
+
+// checkResourceQuota alerts on a quota with resources whose used amount is at least the threshold
+// percentage of their hard limit, in one alert naming all of them. Resources with a hard limit of
+// zero forbid any use rather than run out, and are skipped.
+func checkResourceQuota(
+	quota *corev1.ResourceQuota,
+	alertSpec types.ResourceQuotaAlertSpec,
+	alertFn alertFunction,
+	alertersConfig types.AlertersConfig,
+) {
+	threshold := alertSpec.ReportStatus.UsagePercent
+	if threshold <= 0 {
+		return
+	}
+	checked := map[string]bool{}
+	for _, resource := range alertSpec.ReportStatus.Resources {
+		checked[resource] = true
+	}
+
+	var names []string
+	for name := range quota.Status.Hard {
+		if len(checked) == 0 || checked[string(name)] {
+			names = append(names, string(name))
+		}
+	}
+	sort.Strings(names)
+
+	var crossed []string
+	for _, name := range names {
+		hard := quota.Status.Hard[corev1.ResourceName(name)]
+		used := quota.Status.Used[corev1.ResourceName(name)]
+		hardAmount, harderr := quantityFloat(hard)
+		usedAmount, usederr := quantityFloat(used)
+		if harderr != nil || usederr != nil || hardAmount <= 0 {
+			continue
+		}
+		if usedPercent := usedAmount / hardAmount * 100; usedPercent >= threshold {
+			crossed = append(crossed, fmt.Sprintf("%s: %s of %s used (%.1f%%)", name, used.String(), hard.String(), usedPercent))
+		}
+	}
+	if len(crossed) == 0 {
+		return
+	}
+
+	// ALERT
+	alertmessage := fmt.Sprintf(
+		"ResourceQuota %s/%s is running out, at or above the %.0f%% threshold! %s. Objects asking for more are rejected once it is exhausted.",
+		quota.Namespace, quota.Name, threshold, strings.Join(crossed, "; "),
+	)
+	alert := newAlert(resourceID("resourcequota", quota.Namespace, quota.Name), "UsagePercent", types.SeverityWarning, alertmessage)
+	alertFn(alertSpec.AlerterType, alertSpec.AlerterName, alert, alertersConfig)
+}
@@ -0,0 +1,85 @@
+// Copyright 2019 Bloomberg Finance LP
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package queries
+
+import (
+	"testing"
+	"time"
+
+	. "github.com/bloomberg/k8eraid/pkgs/types"
+
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func Test_CheckResourceQuota(t *testing.T) {
+	quota := &corev1.ResourceQuota{
+		ObjectMeta: metav1.ObjectMeta{Name: "compute", Namespace: metav1.NamespaceDefault},
+		Status: corev1.ResourceQuotaStatus{
+			Hard: corev1.ResourceList{
+				corev1.ResourceRequestsCPU:    resource.MustParse("20"),
+				corev1.ResourceRequestsMemory: resource.MustParse("64Gi"),
+				corev1.ResourcePods:           resource.MustParse("50"),
+				corev1.ResourceServices:       resource.MustParse("0"),
+			},
+			Used: corev1.ResourceList{
+				corev1.ResourceRequestsCPU:    resource.MustParse("19500m"),
+				corev1.ResourceRequestsMemory: resource.MustParse("32Gi"),
+				corev1.ResourcePods:           resource.MustParse("45"),
+				corev1.ResourceServices:       resource.MustParse("0"),
+			},
+		},
+	}
+	now := time.Unix(1000, 0)
+
+	assert.Equal(t, []string{
+		"ResourceQuota default/compute is running out, at or above the 90% threshold! pods: 45 of 50 used (90.0%); requests.cpu: 19500m of 20 used (97.5%). Objects asking for more are rejected once it is exhausted.",
+	}, CheckResourceQuota(quota, ResourceQuotaAlertSpec{ReportStatus: ResourceQuotaAlertStatus{UsagePercent: 90}}, now).Messages())
+	assert.Equal(t, []string{
+		"ResourceQuota default/compute is running out, at or above the 50% threshold! requests.memory: 32Gi of 64Gi used (50.0%). Objects asking for more are rejected once it is exhausted.",
+	}, CheckResourceQuota(quota, ResourceQuotaAlertSpec{ReportStatus: ResourceQuotaAlertStatus{UsagePercent: 50, Resources: []string{"requests.memory"}}}, now).Messages())
+	assert.Empty(t, CheckResourceQuota(quota, ResourceQuotaAlertSpec{ReportStatus: ResourceQuotaAlertStatus{UsagePercent: 99}}, now).Alerts)
+	assert.Empty(t, CheckResourceQuota(quota, ResourceQuotaAlertSpec{}, now).Alerts, "the check is off without a threshold")
+}
+
+func Test_PollResourceQuota(t *testing.T) {
+	_, conf := StubsInit()
+	client := fake.NewSimpleClientset(&corev1.ResourceQuota{
+		ObjectMeta: metav1.ObjectMeta{Name: "compute", Namespace: metav1.NamespaceDefault, Labels: map[string]string{"tenant": "shop"}},
+		Status: corev1.ResourceQuotaStatus{
+			Hard: corev1.ResourceList{corev1.ResourcePods: resource.MustParse("10")},
+			Used: corev1.ResourceList{corev1.ResourcePods: resource.MustParse("10")},
+		},
+	})
+
+	for _, spec := range []ResourceQuotaAlertSpec{
+		{Name: "compute", ResourceQuotaFilterNamespace: metav1.NamespaceDefault},
+		{Name: "*", ResourceQuotaFilterLabel: "tenant=shop"},
+	} {
+		spec.ReportStatus = ResourceQuotaAlertStatus{UsagePercent: 80}
+		keys := []string{}
+		err := PollResourceQuota(client, spec, defaultTickerTime, func(_ string, _ string, alert Alert, _ AlertersConfig) {
+			keys = append(keys, alert.Key)
+		}, conf)
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"resourcequota/default/compute/UsagePercent"}, keys, spec.Name)
+	}
+
+	assert.Error(t, PollResourceQuota(client, ResourceQuotaAlertSpec{Name: "compute"}, defaultTickerTime, nil, conf), "named rules need a namespace")
+	assert.Error(t, PollResourceQuota(client, ResourceQuotaAlertSpec{Name: "absent", ResourceQuotaFilterNamespace: metav1.NamespaceDefault}, defaultTickerTime, nil, conf))
+}
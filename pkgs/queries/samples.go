@@ -0,0 +1,498 @@
+// Copyright 2019 Bloomberg Finance LP
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package queries
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/bloomberg/k8eraid/pkgs/state"
+	"github.com/bloomberg/k8eraid/pkgs/types"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/kubernetes"
+)
+
+const (
+	sampleNamespace   = "sample"
+	sampleTickerTime  = int64(30)
+	sampleGRPCAddress = "sample-grpc.sample:50051"
+)
+
+// SampleInput is what SampleAlerts runs the checks against, as built by internal/renderfixtures
+type SampleInput struct {
+	// Clientset and DynamicClient serve synthetic objects built to trip every check
+	Clientset     kubernetes.Interface
+	DynamicClient dynamic.Interface
+	// Now is the time the objects were built relative to
+	Now time.Time
+	// Certificate is a PEM certificate expiring in 10 days and an hour, and Token a service
+	// account token expiring in 3 days, both counted from the Unix epoch
+	Certificate []byte
+	Token       string
+}
+
+// SampleAlerts runs every check against the synthetic objects of input and returns the alerts
+// raised, sorted by key, so their text can be reviewed and snapshot tested. It swaps out the state
+// the pollers share, so it must not run while k8eraid is polling.
+func SampleAlerts(input SampleInput) ([]types.Alert, error) {
+	savedStore, savedUsage, savedProbe, savedAPIProbe := stateStore, nodeVolumeUsage, grpcHealthProbe, apiHealthProbe
+	savedNodeMetrics, savedPodMetrics, savedAPIMetrics := nodeMetricsUsage, podMetricsUsage, apiMetricsProbe
+	defer func() {
+		stateStore, nodeVolumeUsage, grpcHealthProbe, apiHealthProbe = savedStore, savedUsage, savedProbe, savedAPIProbe
+		nodeMetricsUsage, podMetricsUsage, apiMetricsProbe = savedNodeMetrics, savedPodMetrics, savedAPIMetrics
+	}()
+	stateStore = state.NewStore()
+	nodeVolumeUsage = func(_ kubernetes.Interface, nodeName string) (map[string]volumeUsage, error) {
+		return map[string]volumeUsage{
+			sampleNamespace + "/sample-data": {CapacityBytes: 100 << 30, UsedBytes: 95 << 30},
+		}, nil
+	}
+	nodeMetricsUsage = func(_ kubernetes.Interface, nodeName string) (corev1.ResourceList, error) {
+		return corev1.ResourceList{
+			corev1.ResourceCPU:    resource.MustParse("3400m"),
+			corev1.ResourceMemory: resource.MustParse("15Gi"),
+		}, nil
+	}
+	podMetricsUsage = func(_ kubernetes.Interface, _ string, _ string) (map[string]corev1.ResourceList, error) {
+		return map[string]corev1.ResourceList{
+			sampleNamespace + "/sample-restarting": {corev1.ResourceCPU: resource.MustParse("1200m"), corev1.ResourceMemory: resource.MustParse("250Mi")},
+		}, nil
+	}
+	grpcHealthProbe = func(address string, _ string, _ time.Duration) (grpcHealthStatus, error) {
+		if address == sampleGRPCAddress {
+			return grpcHealthUnknown, fmt.Errorf("dial tcp: lookup sample-grpc.sample: no such host")
+		}
+		return grpcHealthNotServing, nil
+	}
+	apiHealthProbe = func(_ kubernetes.Interface, endpoint string) (apiHealthResult, error) {
+		if endpoint == "/readyz" {
+			return apiHealthResult{StatusCode: http.StatusInternalServerError, Body: "[+]ping ok\n[-]etcd failed: reason withheld\nreadyz check failed\n"}, nil
+		}
+		return apiHealthResult{StatusCode: http.StatusOK, Body: "ok", Elapsed: 3 * time.Second}, nil
+	}
+	apiMetricsProbe = func(_ kubernetes.Interface) (string, error) {
+		return `apiserver_requested_deprecated_apis{group="batch",removed_release="1.25",resource="cronjobs",subresource="",version="v1beta1"} 1` + "\n", nil
+	}
+
+	var alerts []types.Alert
+	record := func(_ string, _ string, alert types.Alert, _ types.AlertersConfig) {
+		alerts = append(alerts, alert)
+	}
+	now, clientset, dynamicClient := input.Now, input.Clientset, input.DynamicClient
+	config := types.AlertersConfig{}
+
+	for _, spec := range sampleNodeSpecs() {
+		if err := PollNode(clientset, spec, sampleTickerTime, record, config); err != nil {
+			return nil, err
+		}
+	}
+	stateStore.SwapSnapshot("crashloop/pod/sample/sample-restarting/app", state.Snapshot{
+		"since": now.Add(-20 * time.Minute).UTC().Format(time.RFC3339),
+	})
+	for _, resource := range []corev1.ResourceName{corev1.ResourceCPU, corev1.ResourceMemory} {
+		stateStore.SwapSnapshot("usage/pod/sample/sample-restarting/"+string(resource), state.Snapshot{
+			"since": now.Add(-10 * time.Minute).UTC().Format(time.RFC3339),
+		})
+	}
+	for _, spec := range samplePodSpecs() {
+		if err := PollPod(clientset, spec, sampleTickerTime, record, config); err != nil {
+			return nil, err
+		}
+	}
+	deployment := types.DeploymentAlertSpec{
+		Name:      "sample-web",
+		DepFilter: sampleNamespace,
+		ReportStatus: types.DeploymentAlertStatus{
+			MinReplicas:     3,
+			OOMKills:        types.OOMKillCheck{Threshold: 2},
+			ReplicaSets:     types.ReplicaSetCheck{MaxActive: 1, MaxRevisions: 2},
+			PodSpread:       types.PodSpreadCheck{MaxNodePercent: 50, AntiAffinity: true},
+			PausedThreshold: 3600,
+		},
+	}
+	if err := PollDeployment(clientset, deployment, sampleTickerTime, record, config); err != nil {
+		return nil, err
+	}
+	rollout := types.DeploymentAlertSpec{
+		Name:      "sample-api",
+		DepFilter: sampleNamespace,
+		ReportStatus: types.DeploymentAlertStatus{
+			ProgressDeadlineExceeded: true,
+			UpdateLagThreshold:       1800,
+		},
+	}
+	stateStore.SwapSnapshot("updatelag/deployment/sample/sample-api", state.Snapshot{
+		"since": now.Add(-time.Hour).UTC().Format(time.RFC3339),
+	})
+	if err := PollDeployment(clientset, rollout, sampleTickerTime, record, config); err != nil {
+		return nil, err
+	}
+	daemonset := types.DaemonsetAlertSpec{
+		Name:         "sample-agent",
+		DaemonFilter: sampleNamespace,
+		ReportStatus: types.DaemonsetAlertStatus{CheckReplicas: true, FailedScheduling: true, CoverageThreshold: 600},
+	}
+	stateStore.SwapSnapshot("coverage/daemonset/sample/sample-agent", state.Snapshot{
+		"since": now.Add(-time.Hour).UTC().Format(time.RFC3339),
+	})
+	if err := PollDaemonset(clientset, daemonset, sampleTickerTime, record, config); err != nil {
+		return nil, err
+	}
+	statefulset := types.StatefulsetAlertSpec{
+		Name:              "sample-db",
+		StatefulsetFilter: sampleNamespace,
+		ReportStatus:      types.StatefulsetAlertStatus{CheckReplicas: true},
+	}
+	if err := PollStatefulset(clientset, statefulset, sampleTickerTime, record, config); err != nil {
+		return nil, err
+	}
+	missing := types.StatefulsetAlertSpec{Name: "sample-cache", StatefulsetFilter: sampleNamespace}
+	if err := PollStatefulset(clientset, missing, sampleTickerTime, record, config); err != nil {
+		return nil, err
+	}
+	// the newest ReplicaSet of sample-web started its rollout on an earlier tick
+	stateStore.SwapSnapshot("unavailable/"+resourceID("replicaset", sampleNamespace, "sample-web-3"), state.Snapshot{
+		"since": now.Add(-20 * time.Minute).UTC().Format(time.RFC3339),
+	})
+	replicaSets := types.ReplicaSetAlertSpec{
+		Name:                      "*",
+		ReplicaSetFilterNamespace: sampleNamespace,
+		ReportStatus:              types.ReplicaSetAlertStatus{UnavailableThreshold: 600, Orphaned: true},
+	}
+	if err := PollReplicaSet(clientset, replicaSets, sampleTickerTime, record, config); err != nil {
+		return nil, err
+	}
+	job := types.JobAlertSpec{
+		Name:         "*",
+		ReportStatus: types.JobAlertStatus{BackoffLimitExceeded: true, CompletionDeadline: 3600, FailedPods: 1},
+	}
+	if err := PollJob(clientset, job, sampleTickerTime, record, config); err != nil {
+		return nil, err
+	}
+	cronJob := types.CronJobAlertSpec{
+		Name:          "sample-nightly",
+		CronJobFilter: sampleNamespace,
+		ReportStatus:  types.CronJobAlertStatus{MaxSinceSuccess: 1800, ConsecutiveFailures: 2},
+	}
+	if err := PollCronJob(clientset, cronJob, sampleTickerTime, record, config); err != nil {
+		return nil, err
+	}
+	pvc := types.PVCAlertSpec{
+		Name:               "sample-data",
+		PVCFilterNamespace: sampleNamespace,
+		ReportStatus:       types.PVCAlertStatus{UsagePercent: 90},
+	}
+	if err := PollPVC(clientset, pvc, sampleTickerTime, record, config); err != nil {
+		return nil, err
+	}
+	claims := types.PVCAlertSpec{
+		Name:               "*",
+		PVCFilterNamespace: sampleNamespace,
+		ReportStatus:       types.PVCAlertStatus{PendingThreshold: 600, Lost: true},
+	}
+	if err := PollPVC(clientset, claims, sampleTickerTime, record, config); err != nil {
+		return nil, err
+	}
+	volumes := types.PVAlertSpec{Name: "*", ReportStatus: types.PVAlertStatus{ReclaimErrors: true}}
+	if err := PollPV(clientset, volumes, sampleTickerTime, record, config); err != nil {
+		return nil, err
+	}
+	storageClasses := types.StorageClassAlertSpec{Name: "*", ReportStatus: types.StorageClassAlertStatus{NoDefault: true, ProvisioningFailures: 3}}
+	if err := PollStorageClass(clientset, storageClasses, sampleTickerTime, record, config); err != nil {
+		return nil, err
+	}
+	archive := types.StorageClassAlertSpec{Name: "sample-archive", ReportStatus: types.StorageClassAlertStatus{Missing: true}}
+	if err := PollStorageClass(clientset, archive, sampleTickerTime, record, config); err != nil {
+		return nil, err
+	}
+	namespaces := types.NamespaceAlertSpec{Name: "*", ReportStatus: types.NamespaceAlertStatus{TerminatingThreshold: 1800, NetworkPolicies: true}}
+	if err := PollNamespace(clientset, namespaces, sampleTickerTime, record, config); err != nil {
+		return nil, err
+	}
+	quotas := types.ResourceQuotaAlertSpec{
+		Name:                         "*",
+		ResourceQuotaFilterNamespace: sampleNamespace,
+		ReportStatus:                 types.ResourceQuotaAlertStatus{UsagePercent: 90},
+	}
+	if err := PollResourceQuota(clientset, quotas, sampleTickerTime, record, config); err != nil {
+		return nil, err
+	}
+	// the Services lost their endpoints on an earlier tick
+	stateStore.SwapSnapshot("endpoints/"+resourceID("service", sampleNamespace, "sample-frontend"), state.Snapshot{
+		"since": now.Add(-30 * time.Minute).UTC().Format(time.RFC3339),
+	})
+	stateStore.SwapSnapshot("readypercent/"+resourceID("service", sampleNamespace, "sample-checkout"), state.Snapshot{
+		"since": now.Add(-10 * time.Minute).UTC().Format(time.RFC3339),
+	})
+	services := types.ServiceAlertSpec{
+		Name:                   "*",
+		ServiceFilterNamespace: sampleNamespace,
+		ReportStatus:           types.ServiceAlertStatus{NoReadyEndpoints: true, PendingThreshold: 300, MinReadyPercent: 50},
+	}
+	if err := PollService(clientset, services, sampleTickerTime, record, config); err != nil {
+		return nil, err
+	}
+	ingresses := types.IngressAlertSpec{
+		Name:                   "*",
+		IngressFilterNamespace: sampleNamespace,
+		ReportStatus:           types.IngressAlertStatus{BrokenBackend: true, NoLoadBalancer: true, PendingThreshold: 600},
+	}
+	if err := PollIngress(clientset, ingresses, sampleTickerTime, record, config); err != nil {
+		return nil, err
+	}
+	hpas := types.HPAAlertSpec{
+		Name:               "*",
+		HPAFilterNamespace: sampleNamespace,
+		ReportStatus:       types.HPAAlertStatus{MaxReplicasThreshold: 1800, ScalingLimited: true, FailedGetMetrics: true},
+	}
+	if err := PollHPA(clientset, hpas, sampleTickerTime, record, config); err != nil {
+		return nil, err
+	}
+	// the budget stopped allowing disruptions on an earlier tick
+	stateStore.SwapSnapshot("nodisruptions/"+resourceID("poddisruptionbudget", sampleNamespace, "sample-web"), state.Snapshot{
+		"since": now.Add(-45 * time.Minute).UTC().Format(time.RFC3339),
+	})
+	pdbs := types.PDBAlertSpec{
+		Name:               "*",
+		PDBFilterNamespace: sampleNamespace,
+		ReportStatus:       types.PDBAlertStatus{Unhealthy: true, NoDisruptionsThreshold: 1800},
+	}
+	if err := PollPDB(clientset, pdbs, sampleTickerTime, record, config); err != nil {
+		return nil, err
+	}
+	webhook := types.WebhookAlertSpec{
+		Name:         "sample-policy",
+		ReportStatus: types.WebhookAlertStatus{UnreachableService: true},
+	}
+	if err := PollWebhookConfig(clientset, webhook, sampleTickerTime, record, config); err != nil {
+		return nil, err
+	}
+	unused := types.UnusedAlertSpec{
+		UnusedFilterNamespace: sampleNamespace,
+		ReportStatus:          types.UnusedAlertStatus{PersistentVolumes: true, Secrets: true, ConfigMaps: true},
+	}
+	if err := PollUnused(clientset, unused, sampleTickerTime, record, config); err != nil {
+		return nil, err
+	}
+	workloadResources := types.WorkloadResourceAlertSpec{
+		WorkloadFilterNamespace: sampleNamespace,
+		WorkloadFilterLabel:     "tier=sample-api",
+		ReportStatus:            types.WorkloadResourceAlertStatus{Deployments: true, Statefulsets: true, Requests: true, Limits: true},
+	}
+	if err := PollWorkloadResources(clientset, workloadResources, sampleTickerTime, record, config); err != nil {
+		return nil, err
+	}
+	certificates := types.FieldConditionAlertSpec{
+		Name:                 "*",
+		Group:                "cert-manager.io",
+		Version:              "v1",
+		Resource:             "certificates",
+		FieldFilterNamespace: sampleNamespace,
+		Check:                "CertificateReady",
+		Condition: types.FieldCondition{
+			JSONPath: `{.status.conditions[?(@.type=="Ready")].status}`,
+			Operator: types.OperatorNotEqual,
+			Value:    "True",
+		},
+	}
+	if err := PollFieldCondition(dynamicClient, certificates, sampleTickerTime, record, config); err != nil {
+		return nil, err
+	}
+	csrs := types.CSRAlertSpec{
+		SignerName:   "kubernetes.io/kubelet-serving",
+		ReportStatus: types.CSRAlertStatus{MaxPending: 2, PendingThreshold: 3600},
+	}
+	if err := PollCSR(dynamicClient, csrs, sampleTickerTime, record, config); err != nil {
+		return nil, err
+	}
+	deprecatedAPIs := types.DeprecatedAPIAlertSpec{
+		DeprecatedFilterNamespace: sampleNamespace,
+		ReportStatus:              types.DeprecatedAPIAlertStatus{Objects: true, Requested: true},
+	}
+	if err := PollDeprecatedAPI(clientset, dynamicClient, deprecatedAPIs, sampleTickerTime, record, config); err != nil {
+		return nil, err
+	}
+	events := types.EventAlertSpec{
+		Name:                 "*",
+		EventFilterNamespace: sampleNamespace,
+		EventFilterKind:      "Pod",
+		ReportStatus:         types.EventAlertStatus{Threshold: 3},
+	}
+	if err := PollEvent(clientset, events, sampleTickerTime, record, config); err != nil {
+		return nil, err
+	}
+	probes := types.EventAlertSpec{
+		Name:                 "*",
+		EventFilterNamespace: sampleNamespace,
+		EventFilterKind:      "Pod",
+		EventFilterReasons:   []string{"Unhealthy"},
+		ReportStatus:         types.EventAlertStatus{Threshold: 4, GroupByWorkload: true},
+	}
+	if err := PollEvent(clientset, probes, sampleTickerTime, record, config); err != nil {
+		return nil, err
+	}
+	configMaps := types.ConfigObjectAlertSpec{
+		Name:                  "*",
+		Kind:                  types.ConfigObjectConfigMap,
+		ConfigFilterNamespace: sampleNamespace,
+		ReportStatus:          types.ConfigObjectAlertStatus{Missing: true, Empty: true, Changed: true},
+	}
+	if err := PollConfigObject(clientset, configMaps, sampleTickerTime, record, config); err != nil {
+		return nil, err
+	}
+	controlPlane := types.ControlPlaneAlertSpec{Components: []string{types.ControlPlaneScheduler, types.ControlPlaneControllerManager}}
+	if err := CheckControlPlane(clientset, controlPlane, record, config); err != nil {
+		return nil, err
+	}
+	CheckEtcd(clientset, types.EtcdAlertSpec{Endpoint: "/readyz/etcd"}, record, config)
+
+	// Checks over several ticks are fed fixed series so their text does not depend on the clock
+	start := time.Unix(0, 0).UTC()
+	trend := types.TrendCheck{Window: 3600, MaxDropPerHour: 1}
+	for i, replicas := range []float64{6, 5, 4} {
+		checkTrend("deployment/sample/sample-web", "AvailableTrend", "Deployment sample/sample-web available replicas",
+			replicas, start.Add(time.Duration(i)*20*time.Minute), trend, "", "", record, config)
+	}
+	forecast := types.NodeAlertSpec{
+		Name:         "sample-node-pleg",
+		ReportStatus: types.NodeAlertStatus{PressureForecast: types.NodePressureForecast{Window: 3600, HorizonSeconds: 3600}},
+	}
+	for i, available := range []uint64{30 << 30, 25 << 30, 20 << 30} {
+		forecastPressure("node/sample-node-pleg", "DiskPressureForecast", "sample-node-pleg", string(corev1.NodeDiskPressure),
+			"of the 100.0GiB root filesystem", available, 10<<30, start.Add(time.Duration(i)*20*time.Minute), forecast, record, config)
+	}
+	for _, latency := range []float64{2.5, 3.5} {
+		stateStore.RecordSample(apiLatencyKey, state.Sample{Time: start, Value: latency}, apiLatencyRetention)
+	}
+	CheckAPILatency(types.APILatencyAlertSpec{ThresholdSeconds: 1, Window: 60}, start, record, config)
+	CheckAPIHealth(clientset, types.APIHealthAlertSpec{Endpoints: []string{"/livez", "/readyz"}, ThresholdSeconds: 2}, record, config)
+	tls := configObject{
+		kind:       types.ConfigObjectSecret,
+		namespace:  sampleNamespace,
+		name:       "sample-tls",
+		secretType: corev1.SecretTypeTLS,
+		data:       map[string][]byte{corev1.TLSCertKey: input.Certificate},
+	}
+	checkCertificateExpiry([]configObject{tls}, types.ConfigObjectAlertSpec{ReportStatus: types.ConfigObjectAlertStatus{CertificateExpiryDays: 30}}, start, record, config)
+	token := configObject{
+		kind:       types.ConfigObjectSecret,
+		namespace:  sampleNamespace,
+		name:       "sample-deployer-token",
+		secretType: corev1.SecretTypeServiceAccountToken,
+		data:       map[string][]byte{corev1.ServiceAccountTokenKey: []byte(input.Token)},
+	}
+	checkCredentialExpiry([]configObject{token}, types.ConfigObjectAlertSpec{ReportStatus: types.ConfigObjectAlertStatus{CredentialExpiryDays: 14}}, start, record, config)
+	reportDiff("sample", state.Snapshot{"node/sample-left": "Ready=True", "node/sample-changed": "Ready=True"}, "", "", record, config)
+	reportDiff("sample", state.Snapshot{"node/sample-joined": "Ready=True", "node/sample-changed": "Ready=False"}, "", "", record, config)
+
+	sort.SliceStable(alerts, func(i, j int) bool { return alerts[i].Key < alerts[j].Key })
+	return alerts, nil
+}
+
+func sampleNodeSpecs() []types.NodeAlertSpec {
+	specs := []types.NodeAlertSpec{
+		{Name: "*", NodeFilter: "pool=sample-missing", ReportStatus: types.NodeAlertStatus{MinNodes: 3}},
+		{Name: "*", NodeFilter: "pool=sample", ReportStatus: types.NodeAlertStatus{MinNodes: 2, MinNodesReadyOnly: true}},
+		{Name: "*", NodeFilter: "pool=sample", ReportStatus: types.NodeAlertStatus{ExpectedPoolSize: types.NodePoolSize{Count: 5}}},
+		{
+			Name:       "*",
+			NodeFilter: "pool=sample",
+			ReportStatus: types.NodeAlertStatus{
+				VersionDrift: types.NodeVersionDrift{KernelVersion: nodeVersionMajority, OSImage: "Ubuntu 18.04.2 LTS"},
+			},
+		},
+		{Name: "*", NodeFilter: "pool=sample", ReportStatus: types.NodeAlertStatus{KubeletSkew: types.NodeKubeletSkew{MaxMinorVersions: 2}}},
+		{Name: "*", NodeFilter: "clock=sample", ReportStatus: types.NodeAlertStatus{ClockSkew: types.NodeClockSkew{ThresholdSeconds: 30}}},
+		{Name: "sample-clock-skewed", ReportStatus: types.NodeAlertStatus{ClockSkew: types.NodeClockSkew{ThresholdSeconds: 30, Reference: types.ClockSkewReferenceMonitor}}},
+		{Name: "*", NodeFilter: "clock=sample", ReportStatus: types.NodeAlertStatus{MinNodes: 1, MaxNodes: 2}},
+		{Name: "*", NodeFilter: "clock=sample", ReportStatus: types.NodeAlertStatus{GroupBalance: types.NodeGroupBalance{Groups: []string{"sample-zone-a", "sample-zone-b"}}}},
+		{Name: "sample-node-cordoned", ReportStatus: types.NodeAlertStatus{CordonedThreshold: 86400}},
+		{Name: "sample-node-pleg", ReportStatus: types.NodeAlertStatus{LeaseThreshold: 40}},
+		{
+			Name: "sample-node-current",
+			ReportStatus: types.NodeAlertStatus{
+				TerminationNotices: []types.NodeTerminationNotice{{Taint: "aws-node-termination-handler/*", NoticeSeconds: 120}},
+			},
+		},
+		{Name: "sample-node-pleg", ReportStatus: types.NodeAlertStatus{Utilization: types.NodeUtilization{WarningPercent: 80, CriticalPercent: 90}}},
+		{
+			Name: "sample-node-not-ready",
+			ReportStatus: types.NodeAlertStatus{
+				Taints: []types.NodeTaintMatch{{Key: "node.kubernetes.io/not-ready", ThresholdSeconds: 600, Severity: types.SeverityCritical}},
+			},
+		},
+		{
+			Name: "sample-node-pleg",
+			ReportStatus: types.NodeAlertStatus{
+				ConditionMatches: []types.NodeConditionMatch{{Type: "Ready", MessageContains: "PLEG"}},
+			},
+		},
+	}
+	for _, condition := range sampleNodeConditions {
+		specs = append(specs, types.NodeAlertSpec{
+			Name: sampleNodeName(condition),
+			ReportStatus: types.NodeAlertStatus{
+				NodeReady:              true,
+				NodeOutOfDisk:          true,
+				NodeMemoryPressure:     true,
+				NodeDiskPressure:       true,
+				NodePIDPressure:        true,
+				NodeNetworkUnavailable: true,
+				CustomConditions:       []string{"KernelDeadlock"},
+			},
+		})
+	}
+	return specs
+}
+
+func samplePodSpecs() []types.PodAlertSpec {
+	return []types.PodAlertSpec{
+		{Name: "*", PodFilterLabel: "app=sample-missing", ReportStatus: types.PodAlertStatus{MinPods: 1}},
+		{Name: "sample-restarting", PodFilterNamespace: sampleNamespace, ReportStatus: types.PodAlertStatus{PodRestarts: true, RestartThreshold: 5, OOMKilled: true, CrashLoopThreshold: 600}},
+		{Name: "sample-restarting", PodFilterNamespace: sampleNamespace, ReportStatus: types.PodAlertStatus{Usage: types.PodUsage{CPU: "1", LimitPercent: 90, ThresholdSeconds: 300}}},
+		{Name: "sample-unscheduled", PodFilterNamespace: sampleNamespace, ReportStatus: types.PodAlertStatus{FailedScheduling: true, UnschedulableThreshold: 300}},
+		{Name: "sample-terminating", PodFilterNamespace: sampleNamespace, ReportStatus: types.PodAlertStatus{StuckTerminating: true}},
+		{Name: "sample-grpc", PodFilterNamespace: sampleNamespace, ReportStatus: types.PodAlertStatus{GRPCHealth: types.GRPCHealthCheck{Port: 50051, Service: "sample"}}},
+		{Name: "sample-grpc", PodFilterNamespace: sampleNamespace, ReportStatus: types.PodAlertStatus{GRPCHealth: types.GRPCHealthCheck{Address: sampleGRPCAddress}}},
+		{Name: "sample-orphaned", PodFilterNamespace: sampleNamespace, ReportStatus: types.PodAlertStatus{NodeLost: true}},
+		{Name: "sample-unbounded", PodFilterNamespace: sampleNamespace, ReportStatus: types.PodAlertStatus{MissingLimits: true, LimitRangeViolations: true}},
+		{Name: "sample-bad-image", PodFilterNamespace: sampleNamespace, ReportStatus: types.PodAlertStatus{ImagePullErrors: true}},
+		{Name: "sample-finalized", PodFilterNamespace: sampleNamespace, ReportStatus: types.PodAlertStatus{TerminatingThreshold: 600}},
+		{Name: "*", PodFilterNamespace: sampleNamespace, PodFilterLabel: "app=sample-batch", ReportStatus: types.PodAlertStatus{EvictedThreshold: 2}},
+		{Name: "sample-shell", PodFilterNamespace: sampleNamespace, ReportStatus: types.PodAlertStatus{
+			OrphanedThreshold: 1800,
+			ImagePolicy:       types.ImagePolicy{DisallowLatest: true, AllowedRegistries: []string{"registry.example.com"}},
+		}},
+	}
+}
+
+var sampleNodeConditions = []corev1.NodeConditionType{
+	corev1.NodeReady,
+	corev1.NodeOutOfDisk,
+	corev1.NodeMemoryPressure,
+	corev1.NodeDiskPressure,
+	corev1.NodePIDPressure,
+	corev1.NodeNetworkUnavailable,
+	"KernelDeadlock",
+}
+
+func sampleNodeName(condition corev1.NodeConditionType) string {
+	return fmt.Sprintf("sample-node-%s", condition)
+}
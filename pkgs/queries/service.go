@@ -0,0 +1,176 @@
+// Copyright 2019 Bloomberg Finance LP
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package queries
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/bloomberg/k8eraid/pkgs/types"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/client-go/kubernetes"
+)
+
+// PollService function takes inputs and iterates across Services in the kubernetes cluster, triggering alerts as needed.
+func PollService(
+	clientset kubernetes.Interface,
+	alertSpec types.ServiceAlertSpec,
+	tickertime int64,
+	alertFn alertFunction,
+	alertersConfig types.AlertersConfig,
+) error {
+
+	if alertSpec.ReportStatus.PendingThreshold == 0 {
+		alertSpec.ReportStatus.PendingThreshold = defaultPendingThreshold
+	}
+	if !alertSpec.ReportStatus.NoReadyEndpoints && alertSpec.ReportStatus.MinReadyPercent <= 0 {
+		return nil
+	}
+
+	var services []corev1.Service
+	endpoints := map[string]*corev1.Endpoints{}
+
+	// Check rules with matching literal service name
+	if alertSpec.Name != "*" {
+		if alertSpec.ServiceFilterNamespace == "" {
+			return &PollErr{
+				Message: fmt.Sprintf("Service rule for %s has no namespace filter specified, ignoring", alertSpec.Name),
+			}
+		}
+		service, serviceerr := clientset.CoreV1().Services(alertSpec.ServiceFilterNamespace).Get(alertSpec.Name, metav1.GetOptions{})
+		if serviceerr != nil {
+			return &PollErr{
+				Message: fmt.Sprintf("Error fetching Service %s: %s", alertSpec.Name, serviceerr.Error()),
+			}
+		}
+		services = append(services, *service)
+		// Services whose pods never existed may have no Endpoints object at all
+		serviceEndpoints, endpointserr := clientset.CoreV1().Endpoints(service.Namespace).Get(service.Name, metav1.GetOptions{})
+		if endpointserr != nil && !apierrors.IsNotFound(endpointserr) {
+			return &PollErr{
+				Message: fmt.Sprintf("Error fetching Endpoints of Service %s: %s", alertSpec.Name, endpointserr.Error()),
+			}
+		}
+		if endpointserr == nil {
+			endpoints[service.Namespace+"/"+service.Name] = serviceEndpoints
+		}
+		// If the service name is a wildcard, list based on filter and iterate through
+	} else {
+		list, listerr := clientset.CoreV1().Services(alertSpec.ServiceFilterNamespace).List(metav1.ListOptions{
+			LabelSelector:  alertSpec.ServiceFilterLabel,
+			TimeoutSeconds: &timeout,
+		})
+		if listerr != nil {
+			return &PollErr{
+				Message: fmt.Sprintf("Unable to list Services: %s", listerr.Error()),
+			}
+		}
+		services = list.Items
+		endpointsList, endpointserr := clientset.CoreV1().Endpoints(alertSpec.ServiceFilterNamespace).List(metav1.ListOptions{
+			LabelSelector:  alertSpec.ServiceFilterLabel,
+			TimeoutSeconds: &timeout,
+		})
+		if endpointserr != nil {
+			return &PollErr{
+				Message: fmt.Sprintf("Unable to list Endpoints: %s", endpointserr.Error()),
+			}
+		}
+		for i := range endpointsList.Items {
+			endpoints[endpointsList.Items[i].Namespace+"/"+endpointsList.Items[i].Name] = &endpointsList.Items[i]
+		}
+	}
+
+	for i := range services {
+		checkServiceEndpoints(&services[i], endpoints[services[i].Namespace+"/"+services[i].Name], alertSpec, time.Now(), alertFn, alertersConfig)
+	}
+	return nil
+}
+
+// checkServiceEndpoints alerts on a Service that has had no ready endpoints, or too small a share of
+// ready endpoints, for longer than the pending threshold. Services without a selector get their
+// Endpoints from elsewhere, and ExternalName Services have none, so both are skipped.
+func checkServiceEndpoints(
+	service *corev1.Service,
+	endpoints *corev1.Endpoints,
+	alertSpec types.ServiceAlertSpec,
+	now time.Time,
+	alertFn alertFunction,
+	alertersConfig types.AlertersConfig,
+) {
+	if len(service.Spec.Selector) == 0 || service.Spec.Type == corev1.ServiceTypeExternalName {
+		return
+	}
+	resource := resourceID("service", service.Namespace, service.Name)
+	ready, notReady := 0, 0
+	if endpoints != nil {
+		for _, subset := range endpoints.Subsets {
+			ready += len(subset.Addresses)
+			notReady += len(subset.NotReadyAddresses)
+		}
+	}
+	threshold := time.Duration(alertSpec.ReportStatus.PendingThreshold) * time.Second
+
+	noReady := alertSpec.ReportStatus.NoReadyEndpoints && ready == 0
+	if emptyFor := unhealthyFor("endpoints/"+resource, noReady, service.CreationTimestamp.Time, now); noReady && emptyFor > threshold {
+		alertNoReadyEndpoints(service, notReady, emptyFor, alertSpec, alertFn, alertersConfig)
+	}
+
+	// A Service with no ready endpoint at all is left to the check above when the rule makes it
+	total := ready + notReady
+	readyPercent := 0.0
+	if total > 0 {
+		readyPercent = float64(ready) / float64(total) * 100
+	}
+	minPercent := alertSpec.ReportStatus.MinReadyPercent
+	belowMin := minPercent > 0 && total > 0 && readyPercent < minPercent && !noReady
+	if belowFor := unhealthyFor("readypercent/"+resource, belowMin, service.CreationTimestamp.Time, now); belowMin && belowFor > threshold {
+		// ALERT
+		alertmessage := fmt.Sprintf(
+			"Service %s/%s has had only %d of %d endpoints ready (%.0f%%) for %s, below the %.0f%% minimum!",
+			service.Namespace, service.Name, ready, total, readyPercent, formatLongDuration(belowFor), minPercent,
+		)
+		alert := newAlert(resource, "ReadyPercent", types.SeverityWarning, alertmessage)
+		alertFn(alertSpec.AlerterType, alertSpec.AlerterName, alert, alertersConfig)
+	}
+}
+
+// alertNoReadyEndpoints tells apart a selector matching no pod from pods that are all unready
+func alertNoReadyEndpoints(
+	service *corev1.Service,
+	notReady int,
+	emptyFor time.Duration,
+	alertSpec types.ServiceAlertSpec,
+	alertFn alertFunction,
+	alertersConfig types.AlertersConfig,
+) {
+	resource := resourceID("service", service.Namespace, service.Name)
+	threshold := alertSpec.ReportStatus.PendingThreshold
+	selector := labels.SelectorFromSet(service.Spec.Selector).String()
+	cause := fmt.Sprintf("No pod matches its selector %s.", selector)
+	if notReady > 0 {
+		cause = fmt.Sprintf("%d pods match its selector %s, and none of them is ready.", notReady, selector)
+	}
+	// ALERT
+	alertmessage := fmt.Sprintf(
+		"Service %s/%s has had no ready endpoints for %s, longer than %ds! %s",
+		service.Namespace, service.Name, formatLongDuration(emptyFor), threshold, cause,
+	)
+	alert := newAlert(resource, "NoReadyEndpoints", types.SeverityCritical, alertmessage)
+	alertFn(alertSpec.AlerterType, alertSpec.AlerterName, alert, alertersConfig)
+}
@@ -0,0 +1,147 @@
+// Copyright 2019 Bloomberg Finance LP
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package queries
+
+import (
+	"testing"
+	"time"
+
+	"github.com/bloomberg/k8eraid/pkgs/state"
+	. "github.com/bloomberg/k8eraid/pkgs/types"
+
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func Test_checkServiceEndpoints(t *testing.T) {
+	stateStore = state.NewStore()
+	_, conf := StubsInit()
+	start := time.Unix(1000, 0)
+	service := &corev1.Service{
+		ObjectMeta: metav1.ObjectMeta{Name: "web", Namespace: metav1.NamespaceDefault},
+		Spec:       corev1.ServiceSpec{Selector: map[string]string{"app": "web"}},
+	}
+	notReady := &corev1.Endpoints{Subsets: []corev1.EndpointSubset{{NotReadyAddresses: []corev1.EndpointAddress{{IP: "10.0.0.1"}, {IP: "10.0.0.2"}}}}}
+	ready := &corev1.Endpoints{Subsets: []corev1.EndpointSubset{{Addresses: []corev1.EndpointAddress{{IP: "10.0.0.1"}}}}}
+	alertSpec := ServiceAlertSpec{ReportStatus: ServiceAlertStatus{NoReadyEndpoints: true, PendingThreshold: 300}}
+	check := func(service *corev1.Service, endpoints *corev1.Endpoints, after time.Duration) []string {
+		messages := []string{}
+		checkServiceEndpoints(service, endpoints, alertSpec, start.Add(after), func(_ string, _ string, alert Alert, _ AlertersConfig) {
+			messages = append(messages, alert.Message)
+		}, conf)
+		return messages
+	}
+
+	assert.Empty(t, check(service, nil, 0))
+	assert.Equal(t, []string{
+		"Service default/web has had no ready endpoints for 10m0s, longer than 300s! No pod matches its selector app=web.",
+	}, check(service, nil, 10*time.Minute))
+	assert.Equal(t, []string{
+		"Service default/web has had no ready endpoints for 15m0s, longer than 300s! 2 pods match its selector app=web, and none of them is ready.",
+	}, check(service, notReady, 15*time.Minute))
+
+	assert.Empty(t, check(service, ready, 20*time.Minute))
+	assert.Empty(t, check(service, nil, 21*time.Minute), "a ready endpoint resets the count")
+
+	external := &corev1.Service{
+		ObjectMeta: metav1.ObjectMeta{Name: "db", Namespace: metav1.NamespaceDefault},
+		Spec:       corev1.ServiceSpec{Type: corev1.ServiceTypeExternalName, ExternalName: "db.example.com"},
+	}
+	check(external, nil, 0)
+	assert.Empty(t, check(external, nil, time.Hour), "services without a selector are skipped")
+}
+
+func Test_checkServiceEndpoints_ReadyPercent(t *testing.T) {
+	stateStore = state.NewStore()
+	_, conf := StubsInit()
+	start := time.Unix(1000, 0)
+	service := &corev1.Service{
+		ObjectMeta: metav1.ObjectMeta{Name: "web", Namespace: metav1.NamespaceDefault},
+		Spec:       corev1.ServiceSpec{Selector: map[string]string{"app": "web"}},
+	}
+	endpoints := func(ready int, notReady int) *corev1.Endpoints {
+		subset := corev1.EndpointSubset{}
+		for i := 0; i < ready; i++ {
+			subset.Addresses = append(subset.Addresses, corev1.EndpointAddress{IP: "10.0.0.1"})
+		}
+		for i := 0; i < notReady; i++ {
+			subset.NotReadyAddresses = append(subset.NotReadyAddresses, corev1.EndpointAddress{IP: "10.0.1.1"})
+		}
+		return &corev1.Endpoints{Subsets: []corev1.EndpointSubset{subset}}
+	}
+	check := func(alertSpec ServiceAlertSpec, endpoints *corev1.Endpoints, after time.Duration) []string {
+		messages := []string{}
+		checkServiceEndpoints(service, endpoints, alertSpec, start.Add(after), func(_ string, _ string, alert Alert, _ AlertersConfig) {
+			messages = append(messages, alert.Key+": "+alert.Message)
+		}, conf)
+		return messages
+	}
+	alertSpec := ServiceAlertSpec{ReportStatus: ServiceAlertStatus{MinReadyPercent: 75, PendingThreshold: 300}}
+
+	assert.Empty(t, check(alertSpec, endpoints(2, 2), 0))
+	assert.Equal(t, []string{
+		"service/default/web/ReadyPercent: Service default/web has had only 2 of 4 endpoints ready (50%) for 10m0s, below the 75% minimum!",
+	}, check(alertSpec, endpoints(2, 2), 10*time.Minute))
+	assert.Empty(t, check(alertSpec, endpoints(3, 1), 11*time.Minute), "75% is not below the minimum")
+	assert.Empty(t, check(alertSpec, endpoints(1, 3), 12*time.Minute), "recovering resets the count")
+	assert.Empty(t, check(alertSpec, nil, time.Hour), "a Service without endpoints has no ratio")
+	check(alertSpec, endpoints(0, 3), 2*time.Hour)
+	assert.Equal(t, []string{
+		"service/default/web/ReadyPercent: Service default/web has had only 0 of 3 endpoints ready (0%) for 1h0m0s, below the 75% minimum!",
+	}, check(alertSpec, endpoints(0, 3), 3*time.Hour))
+
+	alertSpec.ReportStatus.NoReadyEndpoints = true
+	check(alertSpec, endpoints(0, 3), 4*time.Hour)
+	assert.Equal(t, []string{
+		"service/default/web/NoReadyEndpoints: Service default/web has had no ready endpoints for 1h0m0s, longer than 300s! 3 pods match its selector app=web, and none of them is ready.",
+	}, check(alertSpec, endpoints(0, 3), 5*time.Hour), "no ready endpoints at all is not reported twice")
+}
+
+func Test_PollService(t *testing.T) {
+	stateStore = state.NewStore()
+	_, conf := StubsInit()
+	client := fake.NewSimpleClientset(
+		&corev1.Service{
+			ObjectMeta: metav1.ObjectMeta{Name: "web", Namespace: metav1.NamespaceDefault, Labels: map[string]string{"tier": "front"}},
+			Spec:       corev1.ServiceSpec{Selector: map[string]string{"app": "web"}},
+		},
+		&corev1.Endpoints{
+			ObjectMeta: metav1.ObjectMeta{Name: "web", Namespace: metav1.NamespaceDefault, Labels: map[string]string{"tier": "front"}},
+			Subsets:    []corev1.EndpointSubset{{Addresses: []corev1.EndpointAddress{{IP: "10.0.0.1"}}}},
+		},
+		&corev1.Service{
+			ObjectMeta: metav1.ObjectMeta{Name: "api", Namespace: metav1.NamespaceDefault, Labels: map[string]string{"tier": "front"}},
+			Spec:       corev1.ServiceSpec{Selector: map[string]string{"app": "api"}},
+		},
+	)
+	specs := []ServiceAlertSpec{
+		{Name: "api", ServiceFilterNamespace: metav1.NamespaceDefault},
+		{Name: "*", ServiceFilterLabel: "tier=front"},
+	}
+	for _, spec := range specs {
+		stateStore.SwapSnapshot("endpoints/service/default/api", state.Snapshot{"since": time.Now().Add(-time.Hour).UTC().Format(time.RFC3339)})
+		spec.ReportStatus = ServiceAlertStatus{NoReadyEndpoints: true, PendingThreshold: 60}
+		keys := []string{}
+		err := PollService(client, spec, defaultTickerTime, func(_ string, _ string, alert Alert, _ AlertersConfig) {
+			keys = append(keys, alert.Key)
+		}, conf)
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"service/default/api/NoReadyEndpoints"}, keys, spec.Name)
+	}
+
+	assert.Error(t, PollService(client, ServiceAlertSpec{Name: "api", ReportStatus: ServiceAlertStatus{NoReadyEndpoints: true}}, defaultTickerTime, nil, conf))
+}
@@ -0,0 +1,57 @@
+// Copyright 2019 Bloomberg Finance LP
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package queries
+
+import (
+	"strings"
+
+	"github.com/bloomberg/k8eraid/pkgs/alerters"
+	"github.com/bloomberg/k8eraid/pkgs/types"
+)
+
+// OverrideSeverities returns an alerters.Middleware giving alerts the severity the overrides set for
+// their resource type and check, instead of the check's default. An override for the alert's
+// resource type wins over one that matches every type.
+func OverrideSeverities(overrides []types.SeverityOverride) alerters.Middleware {
+	return func(next alerters.Alerter) alerters.Alerter {
+		if len(overrides) == 0 {
+			return next
+		}
+		return alerters.AlerterFunc(func(alerterType string, alerterName string, alert types.Alert, alertersConfig types.AlertersConfig) {
+			if severity, ok := overrideSeverity(overrides, alert); ok {
+				alert.Severity = severity
+			}
+			next.Alert(alerterType, alerterName, alert, alertersConfig)
+		})
+	}
+}
+
+func overrideSeverity(overrides []types.SeverityOverride, alert types.Alert) (string, bool) {
+	kind := resourceType(alert.Resource)
+	check := strings.TrimPrefix(alert.Key, alert.Resource+"/")
+	severity, found := "", false
+	for _, override := range overrides {
+		if override.Check != check {
+			continue
+		}
+		if override.ResourceType == kind {
+			return override.Severity, true
+		}
+		if override.ResourceType == "" && !found {
+			severity, found = override.Severity, true
+		}
+	}
+	return severity, found
+}
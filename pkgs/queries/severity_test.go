@@ -0,0 +1,70 @@
+// Copyright 2019 Bloomberg Finance LP
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package queries
+
+import (
+	"testing"
+
+	"github.com/bloomberg/k8eraid/pkgs/alerters"
+	. "github.com/bloomberg/k8eraid/pkgs/types"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_OverrideSeverities(t *testing.T) {
+	_, conf := StubsInit()
+	overrides := []SeverityOverride{
+		{Check: "Ready", Severity: SeverityWarning},
+		{ResourceType: "node", Check: "Ready", Severity: SeverityCritical},
+		{ResourceType: "node", Check: "MemoryPressure", Severity: SeverityInfo},
+	}
+
+	tests := []struct {
+		name     string
+		alert    Alert
+		expected string
+	}{
+		{
+			name:     "resource type specific override",
+			alert:    newAlert("node/a", "MemoryPressure", SeverityWarning, "message"),
+			expected: SeverityInfo,
+		},
+		{
+			name:     "specific override wins over any type",
+			alert:    newAlert("node/a", "Ready", SeverityInfo, "message"),
+			expected: SeverityCritical,
+		},
+		{
+			name:     "override for any type",
+			alert:    newAlert("pod/ns/b", "Ready", SeverityCritical, "message"),
+			expected: SeverityWarning,
+		},
+		{
+			name:     "no override keeps the default",
+			alert:    newAlert("pvc/ns/c", "UsagePercent", SeverityWarning, "message"),
+			expected: SeverityWarning,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(subT *testing.T) {
+			var severity string
+			alerter := OverrideSeverities(overrides)(alerters.AlerterFunc(func(_ string, _ string, alert Alert, _ AlertersConfig) {
+				severity = alert.Severity
+			}))
+			alerter.Alert("stderr", "", test.alert, conf)
+			assert.Equal(subT, test.expected, severity)
+		})
+	}
+}
@@ -0,0 +1,76 @@
+// Copyright 2019 Bloomberg Finance LP
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package queries
+
+import (
+	"strings"
+	"time"
+
+	"github.com/bloomberg/k8eraid/pkgs/alerters"
+	"github.com/bloomberg/k8eraid/pkgs/metrics"
+	"github.com/bloomberg/k8eraid/pkgs/types"
+)
+
+var silencedAlertsCounter = metrics.NewCounterVec(
+	"k8eraid_silenced_alerts_total",
+	"Alerts dropped because the object alerted on carries the silencing annotation.",
+	"resource_type",
+)
+
+// SilenceAnnotated returns an alerters.Middleware dropping the alerts about objects that carry the
+// silencing annotation, until the time it names passes. Alerts about objects that cannot be fetched,
+// or about no single object, are passed on.
+func SilenceAnnotated(objects *ObjectCache, silencing types.Silencing) alerters.Middleware {
+	return func(next alerters.Alerter) alerters.Alerter {
+		if silencing.AnnotationKey == "" {
+			return next
+		}
+		return alerters.AlerterFunc(func(alerterType string, alerterName string, alert types.Alert, alertersConfig types.AlertersConfig) {
+			kind, namespace, name, ok := alertedObject(alert.Resource)
+			_, fetched := ownedObjectGetters[kind]
+			if !ok || !fetched {
+				next.Alert(alerterType, alerterName, alert, alertersConfig)
+				return
+			}
+			object, objecterr := objects.get(kind, namespace, name)
+			if objecterr != nil {
+				errLogger.Printf("Unable to get %s to look up its silencing annotation: %s", alert.Resource, objecterr.Error())
+				next.Alert(alerterType, alerterName, alert, alertersConfig)
+				return
+			}
+			value, annotated := object.GetAnnotations()[silencing.AnnotationKey]
+			if !annotated || !silenced(value, alert.Time) {
+				next.Alert(alerterType, alerterName, alert, alertersConfig)
+				return
+			}
+			silencedAlertsCounter.With(resourceType(alert.Resource)).Inc()
+			logger.Printf("Alert %s silenced by annotation %s=%q", alert.Key, silencing.AnnotationKey, value)
+		})
+	}
+}
+
+// silenced reports whether the silencing annotation value still silences alerts raised at now. A value
+// starting with an RFC 3339 time silences until then, and anything else until it is removed.
+func silenced(value string, now time.Time) bool {
+	fields := strings.Fields(value)
+	if len(fields) == 0 {
+		return true
+	}
+	until, err := time.Parse(time.RFC3339, fields[0])
+	if err != nil {
+		return true
+	}
+	return now.Before(until)
+}
@@ -0,0 +1,78 @@
+// Copyright 2019 Bloomberg Finance LP
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package queries
+
+import (
+	"testing"
+	"time"
+
+	"github.com/bloomberg/k8eraid/pkgs/alerters"
+	. "github.com/bloomberg/k8eraid/pkgs/types"
+
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func Test_SilenceAnnotated(t *testing.T) {
+	_, conf := StubsInit()
+	const key = "k8eraid.io/silence-until"
+	now := time.Date(2019, 6, 1, 12, 0, 0, 0, time.UTC)
+	annotated := func(name string, value string) *corev1.Pod {
+		return &corev1.Pod{ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "payments", Annotations: map[string]string{key: value}}}
+	}
+	client := fake.NewSimpleClientset(
+		&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "payments", Annotations: map[string]string{key: "migrating"}}},
+		annotated("until-later", "2019-06-02T00:00:00Z"),
+		annotated("until-later-with-reason", "2019-06-02T00:00:00Z INC-1234 disk replacement"),
+		annotated("expired", "2019-06-01T00:00:00Z"),
+		annotated("reason-only", "known flaky readiness probe"),
+		&corev1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "plain", Namespace: "payments"}},
+	)
+
+	tests := []struct {
+		name      string
+		resource  string
+		delivered bool
+	}{
+		{name: "silenced until a later time", resource: "pod/payments/until-later"},
+		{name: "silenced with a reason", resource: "pod/payments/until-later-with-reason"},
+		{name: "silence expired", resource: "pod/payments/expired", delivered: true},
+		{name: "silenced until removed", resource: "pod/payments/reason-only"},
+		{name: "namespace annotation does not silence its objects", resource: "pod/payments/plain", delivered: true},
+		{name: "gone object", resource: "pod/payments/gone", delivered: true},
+		{name: "not about a single object", resource: "pods/app=api", delivered: true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(subT *testing.T) {
+			delivered := false
+			alertFn := alerters.Chain(alerters.AlerterFunc(func(_ string, _ string, _ Alert, _ AlertersConfig) {
+				delivered = true
+			}), SilenceAnnotated(NewObjectCache(client), Silencing{AnnotationKey: key})).Alert
+			alert := newAlert(test.resource, "Ready", SeverityCritical, "not ready")
+			alert.Time = now
+			alertFn("stderr", "", alert, conf)
+			assert.Equal(subT, test.delivered, delivered)
+		})
+	}
+
+	delivered := false
+	alertFn := alerters.Chain(alerters.AlerterFunc(func(_ string, _ string, _ Alert, _ AlertersConfig) {
+		delivered = true
+	}), SilenceAnnotated(NewObjectCache(client), Silencing{})).Alert
+	alertFn("stderr", "", newAlert("pod/payments/reason-only", "Ready", SeverityCritical, "not ready"), conf)
+	assert.True(t, delivered, "silencing should be off without an annotation key")
+}
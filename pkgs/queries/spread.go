@@ -0,0 +1,314 @@
+// Copyright 2019 Bloomberg Finance LP
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package queries
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/bloomberg/k8eraid/pkgs/types"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/client-go/kubernetes"
+)
+
+const (
+	defaultZoneLabel     = "failure-domain.beta.kubernetes.io/zone"
+	defaultSpreadMinPods = 2
+)
+
+// spreadWorkload is a Deployment or StatefulSet whose ready pods are counted per node and zone
+type spreadWorkload struct {
+	kind      string
+	namespace string
+	name      string
+	selector  *metav1.LabelSelector
+	template  *corev1.PodTemplateSpec
+}
+
+// checkDeploymentSpread counts the ready pods of a deployment per node and per zone
+func checkDeploymentSpread(
+	clientset kubernetes.Interface,
+	deployment *appsv1.Deployment,
+	alertSpec types.DeploymentAlertSpec,
+	alertFn alertFunction,
+	alertersConfig types.AlertersConfig,
+) error {
+	workload := spreadWorkload{"Deployment", deployment.Namespace, deployment.Name, deployment.Spec.Selector, &deployment.Spec.Template}
+	return checkWorkloadSpread(clientset, workload, alertSpec.ReportStatus.PodSpread, alertSpec.AlerterType, alertSpec.AlerterName, alertFn, alertersConfig)
+}
+
+// checkStatefulsetSpread counts the ready pods of a statefulset per node and per zone
+func checkStatefulsetSpread(
+	clientset kubernetes.Interface,
+	statefulSet *appsv1.StatefulSet,
+	alertSpec types.StatefulsetAlertSpec,
+	alertFn alertFunction,
+	alertersConfig types.AlertersConfig,
+) error {
+	workload := spreadWorkload{"StatefulSet", statefulSet.Namespace, statefulSet.Name, statefulSet.Spec.Selector, &statefulSet.Spec.Template}
+	return checkWorkloadSpread(clientset, workload, alertSpec.ReportStatus.PodSpread, alertSpec.AlerterType, alertSpec.AlerterName, alertFn, alertersConfig)
+}
+
+// checkWorkloadSpread counts the ready pods of a workload per node and per zone. Losing the node or
+// zone running most of them takes the workload down even though its replica counts are healthy.
+// The scheduler only weighs preferred pod anti-affinity, and pods are not moved once placed, so
+// replicas rescheduled after a node failure can pile up on the nodes left despite it.
+func checkWorkloadSpread(
+	clientset kubernetes.Interface,
+	workload spreadWorkload,
+	check types.PodSpreadCheck,
+	alerterType string,
+	alerterName string,
+	alertFn alertFunction,
+	alertersConfig types.AlertersConfig,
+) error {
+	var terms []antiAffinityTerm
+	if check.AntiAffinity {
+		terms = selfAntiAffinityTerms(workload.namespace, workload.template)
+	}
+	if (check.MaxNodePercent <= 0 && check.MaxZonePercent <= 0 && len(terms) == 0) || workload.selector == nil {
+		return nil
+	}
+	if check.ZoneLabel == "" {
+		check.ZoneLabel = defaultZoneLabel
+	}
+	if check.MinPods <= 0 {
+		check.MinPods = defaultSpreadMinPods
+	}
+
+	kind := strings.ToLower(workload.kind)
+	pods, err := clientset.CoreV1().Pods(workload.namespace).List(metav1.ListOptions{
+		LabelSelector:  metav1.FormatLabelSelector(workload.selector),
+		TimeoutSeconds: &timeout,
+	})
+	if err != nil {
+		return &PollErr{
+			Message: fmt.Sprintf("Unable to list pods of %s %s: %s", kind, workload.name, err.Error()),
+		}
+	}
+	nodes := map[string]int{}
+	for _, pod := range pods.Items {
+		if pod.Spec.NodeName != "" && pod.DeletionTimestamp == nil && podReady(&pod) {
+			nodes[pod.Spec.NodeName]++
+		}
+	}
+
+	resource := resourceID(kind, workload.namespace, workload.name)
+	description := fmt.Sprintf("%s %s/%s", workload.kind, workload.namespace, workload.name)
+	if check.MaxNodePercent > 0 {
+		checkSpread(resource, "NodeSpread", description, "node", nodes, check.MaxNodePercent, check.MinPods, alerterType, alerterName, alertFn, alertersConfig)
+	}
+	if check.MaxZonePercent <= 0 && len(terms) == 0 {
+		return nil
+	}
+	// nodes that are gone are left out, their pods cannot be placed in any zone
+	nodeLabels := map[string]map[string]string{}
+	for name := range nodes {
+		node, err := clientset.CoreV1().Nodes().Get(name, metav1.GetOptions{})
+		if apierrors.IsNotFound(err) {
+			continue
+		} else if err != nil {
+			return &PollErr{
+				Message: fmt.Sprintf("Error fetching node %s of %s %s: %s", name, kind, workload.name, err.Error()),
+			}
+		}
+		nodeLabels[name] = node.Labels
+	}
+	if check.MaxZonePercent > 0 {
+		zones := domainCounts(nodes, nodeLabels, check.ZoneLabel)
+		checkSpread(resource, "ZoneSpread", description, "zone", zones, check.MaxZonePercent, check.MinPods, alerterType, alerterName, alertFn, alertersConfig)
+	}
+	for _, term := range terms {
+		if checkAntiAffinity(resource, description, term, domainCounts(nodes, nodeLabels, term.topologyKey), alerterType, alerterName, alertFn, alertersConfig) {
+			break
+		}
+	}
+	return nil
+}
+
+// domainCounts adds up the pods counted per node by the value of the node label key. Pods on nodes
+// without it are left out.
+func domainCounts(nodes map[string]int, nodeLabels map[string]map[string]string, key string) map[string]int {
+	counts := map[string]int{}
+	for name, count := range nodes {
+		if domain := nodeLabels[name][key]; domain != "" {
+			counts[domain] += count
+		}
+	}
+	return counts
+}
+
+// antiAffinityTerm is a pod anti-affinity term of a workload that keeps its own pods apart
+type antiAffinityTerm struct {
+	topologyKey string
+	required    bool
+}
+
+// selfAntiAffinityTerms returns the pod anti-affinity terms of template, required ones first, that
+// select the pods of template itself in their namespace and so ask for one pod per topology domain
+func selfAntiAffinityTerms(namespace string, template *corev1.PodTemplateSpec) []antiAffinityTerm {
+	affinity := template.Spec.Affinity
+	if affinity == nil || affinity.PodAntiAffinity == nil {
+		return nil
+	}
+	selfSelecting := func(term corev1.PodAffinityTerm) bool {
+		if term.TopologyKey == "" || term.LabelSelector == nil {
+			return false
+		}
+		// terms without namespaces apply to the namespace of the pod
+		inNamespace := len(term.Namespaces) == 0
+		for _, termNamespace := range term.Namespaces {
+			inNamespace = inNamespace || termNamespace == namespace
+		}
+		if !inNamespace {
+			return false
+		}
+		selector, err := metav1.LabelSelectorAsSelector(term.LabelSelector)
+		return err == nil && !selector.Empty() && selector.Matches(labels.Set(template.Labels))
+	}
+	var terms []antiAffinityTerm
+	for _, term := range affinity.PodAntiAffinity.RequiredDuringSchedulingIgnoredDuringExecution {
+		if selfSelecting(term) {
+			terms = append(terms, antiAffinityTerm{topologyKey: term.TopologyKey, required: true})
+		}
+	}
+	for _, weighted := range affinity.PodAntiAffinity.PreferredDuringSchedulingIgnoredDuringExecution {
+		if selfSelecting(weighted.PodAffinityTerm) {
+			terms = append(terms, antiAffinityTerm{topologyKey: weighted.PodAffinityTerm.TopologyKey})
+		}
+	}
+	return terms
+}
+
+// checkAntiAffinity alerts when a topology domain runs more than one of the ready pods term keeps
+// apart, reporting whether it did. Required terms are enforced when pods are scheduled, so only
+// nodes relabelled since can break them.
+func checkAntiAffinity(
+	resource string,
+	workload string,
+	term antiAffinityTerm,
+	counts map[string]int,
+	alerterType string,
+	alerterName string,
+	alertFn alertFunction,
+	alertersConfig types.AlertersConfig,
+) bool {
+	if len(counts) == 0 {
+		return false
+	}
+	breakdown := spreadBreakdown(counts)
+	largest := breakdown[0]
+	if counts[largest] <= 1 {
+		return false
+	}
+	kind := "preferred"
+	if term.required {
+		kind = "required"
+	}
+	parts := make([]string, len(breakdown))
+	for i, name := range breakdown {
+		parts[i] = fmt.Sprintf("%s=%d", name, counts[name])
+	}
+	// ALERT
+	alertmessage := fmt.Sprintf(
+		"%s runs %d ready pods on %s=%s despite its %s pod anti-affinity keeping them apart! Pods per %s: %s",
+		workload,
+		counts[largest],
+		term.topologyKey,
+		largest,
+		kind,
+		term.topologyKey,
+		strings.Join(parts, ", "),
+	)
+	alertFn(alerterType, alerterName, newAlert(resource, "AntiAffinity", types.SeverityWarning, alertmessage), alertersConfig)
+	return true
+}
+
+// checkSpread alerts when the domain running most of the counted pods runs more than maxPercent
+// of them
+func checkSpread(
+	resource string,
+	check string,
+	workload string,
+	domain string,
+	counts map[string]int,
+	maxPercent int,
+	minPods int,
+	alerterType string,
+	alerterName string,
+	alertFn alertFunction,
+	alertersConfig types.AlertersConfig,
+) {
+	total := 0
+	for _, count := range counts {
+		total += count
+	}
+	if total < minPods {
+		return
+	}
+	breakdown := spreadBreakdown(counts)
+	largest := breakdown[0]
+	if counts[largest]*100 <= maxPercent*total {
+		return
+	}
+	// ALERT
+	parts := make([]string, len(breakdown))
+	for i, name := range breakdown {
+		parts[i] = fmt.Sprintf("%s=%d", name, counts[name])
+	}
+	alertmessage := fmt.Sprintf(
+		"%s runs %d of %d ready pods (%d%%) on %s %s, more than the %d%% allowed! Pods per %s: %s",
+		workload,
+		counts[largest],
+		total,
+		counts[largest]*100/total,
+		domain,
+		largest,
+		maxPercent,
+		domain,
+		strings.Join(parts, ", "),
+	)
+	alertFn(alerterType, alerterName, newAlert(resource, check, types.SeverityWarning, alertmessage), alertersConfig)
+}
+
+// spreadBreakdown sorts the names of counts by descending count, then by name
+func spreadBreakdown(counts map[string]int) []string {
+	names := make([]string, 0, len(counts))
+	for name := range counts {
+		names = append(names, name)
+	}
+	sort.Slice(names, func(i, j int) bool {
+		if counts[names[i]] != counts[names[j]] {
+			return counts[names[i]] > counts[names[j]]
+		}
+		return names[i] < names[j]
+	})
+	return names
+}
+
+func podReady(pod *corev1.Pod) bool {
+	for _, condition := range pod.Status.Conditions {
+		if condition.Type == corev1.PodReady {
+			return condition.Status == corev1.ConditionTrue
+		}
+	}
+	return false
+}
@@ -0,0 +1,51 @@
+// Copyright 2019 Bloomberg Finance LP
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package queries
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func Test_selfAntiAffinityTerms(t *testing.T) {
+	own := &metav1.LabelSelector{MatchLabels: map[string]string{"app": "web"}}
+	other := &metav1.LabelSelector{MatchLabels: map[string]string{"app": "cache"}}
+	term := func(selector *metav1.LabelSelector, topologyKey string, namespaces ...string) corev1.PodAffinityTerm {
+		return corev1.PodAffinityTerm{LabelSelector: selector, TopologyKey: topologyKey, Namespaces: namespaces}
+	}
+	template := &corev1.PodTemplateSpec{
+		ObjectMeta: metav1.ObjectMeta{Labels: map[string]string{"app": "web", "tier": "front"}},
+		Spec: corev1.PodSpec{Affinity: &corev1.Affinity{PodAntiAffinity: &corev1.PodAntiAffinity{
+			RequiredDuringSchedulingIgnoredDuringExecution: []corev1.PodAffinityTerm{
+				term(other, "kubernetes.io/hostname"),
+				term(own, "zone", "shop"),
+				term(own, "rack", "payments"),
+			},
+			PreferredDuringSchedulingIgnoredDuringExecution: []corev1.WeightedPodAffinityTerm{
+				{Weight: 50, PodAffinityTerm: term(own, "kubernetes.io/hostname")},
+				{Weight: 50, PodAffinityTerm: term(&metav1.LabelSelector{}, "zone")},
+			},
+		}}},
+	}
+
+	assert.Equal(t, []antiAffinityTerm{
+		{topologyKey: "zone", required: true},
+		{topologyKey: "kubernetes.io/hostname"},
+	}, selfAntiAffinityTerms("shop", template), "terms keeping other pods away, or listing other namespaces, do not spread the workload")
+	assert.Empty(t, selfAntiAffinityTerms("shop", &corev1.PodTemplateSpec{}))
+}
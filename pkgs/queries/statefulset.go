@@ -0,0 +1,184 @@
+// Copyright 2019 Bloomberg Finance LP
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package queries
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/bloomberg/k8eraid/pkgs/state"
+	"github.com/bloomberg/k8eraid/pkgs/types"
+
+	appsv1 "k8s.io/api/apps/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// PollStatefulset function takes inputs and iterates across statefulsets in the kubernetes cluster, triggering alerts as needed.
+func PollStatefulset(
+	clientset kubernetes.Interface,
+	alertSpec types.StatefulsetAlertSpec,
+	tickertime int64,
+	alertFn alertFunction,
+	alertersConfig types.AlertersConfig,
+) error {
+
+	if alertSpec.ReportStatus.PendingThreshold == 0 {
+		alertSpec.ReportStatus.PendingThreshold = defaultPendingThreshold
+	}
+
+	// If the statefulset is not wildcard, search by name
+	if alertSpec.Name != "*" {
+		if alertSpec.StatefulsetFilter == "" {
+			return &PollErr{
+				Message: fmt.Sprintf("StatefulSet rule for %s has no namespace filter specified, ignoring", alertSpec.Name),
+			}
+		}
+		statefulset, statefulseterr := clientset.AppsV1().StatefulSets(alertSpec.StatefulsetFilter).Get(alertSpec.Name, metav1.GetOptions{})
+		if apierrors.IsNotFound(statefulseterr) {
+			if !alertSpec.ReportStatus.IgnoreMissing {
+				// ALERT
+				alertmessage := fmt.Sprintf("StatefulSet %s/%s does not exist!", alertSpec.StatefulsetFilter, alertSpec.Name)
+				alert := newAlert(resourceID("statefulset", alertSpec.StatefulsetFilter, alertSpec.Name), "Missing", types.SeverityCritical, alertmessage)
+				alertFn(alertSpec.AlerterType, alertSpec.AlerterName, alert, alertersConfig)
+			}
+			return nil
+		}
+		if statefulseterr != nil {
+			return &PollErr{
+				Message: fmt.Sprintf("Error fetching statefulset %s: %s", alertSpec.Name, statefulseterr.Error()),
+			}
+		}
+
+		checkStatefulset(statefulset, alertSpec, time.Now(), alertFn, alertersConfig)
+		if err := checkStatefulsetSpread(clientset, statefulset, alertSpec, alertFn, alertersConfig); err != nil {
+			return err
+		}
+		// If the statefulset is a wildcard, list statefulsets and iterate through
+	} else {
+		if !strings.Contains(alertSpec.StatefulsetFilter, "=") && alertSpec.StatefulsetFilter != "" {
+			return &PollErr{
+				Message: fmt.Sprintf("StatefulSet rule for global has incorrect filter specified (filter was: %s), ignoring", alertSpec.StatefulsetFilter),
+			}
+		}
+		statefulsets, statefulsetserr := clientset.AppsV1().StatefulSets("").List(metav1.ListOptions{
+			LabelSelector:  alertSpec.StatefulsetFilter,
+			TimeoutSeconds: &timeout,
+		})
+		if statefulsetserr != nil {
+			return &PollErr{
+				Message: fmt.Sprintf("Unable to list StatefulSets: %s", statefulsetserr.Error()),
+			}
+		}
+		for i := range statefulsets.Items {
+			checkStatefulset(&statefulsets.Items[i], alertSpec, time.Now(), alertFn, alertersConfig)
+			if err := checkStatefulsetSpread(clientset, &statefulsets.Items[i], alertSpec, alertFn, alertersConfig); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func checkStatefulset(
+	statefulSet *appsv1.StatefulSet,
+	alertSpec types.StatefulsetAlertSpec,
+	now time.Time,
+	alertFn alertFunction,
+	alertersConfig types.AlertersConfig,
+) {
+	resource := resourceID("statefulset", statefulSet.Namespace, statefulSet.Name)
+	replicas := int32(1)
+	if statefulSet.Spec.Replicas != nil {
+		replicas = *statefulSet.Spec.Replicas
+	}
+
+	// If statefulset hasnt been around longer than threshold, skip the replica check
+	if alertSpec.ReportStatus.CheckReplicas && now.Unix()-statefulSet.CreationTimestamp.Unix() > alertSpec.ReportStatus.PendingThreshold {
+		if statefulSet.Status.ReadyReplicas < replicas {
+			// ALERT
+			alertmessage := fmt.Sprintf(
+				"StatefulSet %s/%s has %d of %d replicas ready!",
+				statefulSet.Namespace, statefulSet.Name, statefulSet.Status.ReadyReplicas, replicas,
+			)
+			alert := newAlert(resource, "CheckReplicas", types.SeverityCritical, alertmessage)
+			alertFn(alertSpec.AlerterType, alertSpec.AlerterName, alert, alertersConfig)
+		}
+	}
+
+	checkStatefulsetRollout(statefulSet, replicas, alertSpec, now, alertFn, alertersConfig)
+}
+
+// checkStatefulsetRollout alerts on a rollout that has not updated the replicas it should within the
+// stall threshold. StatefulSets have no progress deadline, so when k8eraid first saw the update
+// revision is remembered, and forgotten once the rollout is done.
+func checkStatefulsetRollout(
+	statefulSet *appsv1.StatefulSet,
+	replicas int32,
+	alertSpec types.StatefulsetAlertSpec,
+	now time.Time,
+	alertFn alertFunction,
+	alertersConfig types.AlertersConfig,
+) {
+	threshold := alertSpec.ReportStatus.UpdateStallThreshold
+	if threshold <= 0 {
+		return
+	}
+	resource := resourceID("statefulset", statefulSet.Namespace, statefulSet.Name)
+	key := "rollout/" + resource
+	status := statefulSet.Status
+	wanted := statefulsetUpdateTarget(statefulSet, replicas)
+	if status.UpdateRevision == "" || status.UpdateRevision == status.CurrentRevision || status.UpdatedReplicas >= wanted {
+		stateStore.ForgetSnapshot(key)
+		return
+	}
+
+	since := now
+	stateStore.UpdateSnapshot(key, func(previous state.Snapshot, _ bool) state.Snapshot {
+		if recorded, err := time.Parse(time.RFC3339, previous["since"]); err == nil && previous["revision"] == status.UpdateRevision {
+			since = recorded
+		}
+		return state.Snapshot{"revision": status.UpdateRevision, "since": since.UTC().Format(time.RFC3339)}
+	})
+	stalledFor := now.Sub(since)
+	if stalledFor <= time.Duration(threshold)*time.Second {
+		return
+	}
+	// ALERT
+	alertmessage := fmt.Sprintf(
+		"StatefulSet %s/%s has updated %d of %d replicas to revision %s in %s, longer than %ds! Its rollout is stalled.",
+		statefulSet.Namespace, statefulSet.Name, status.UpdatedReplicas, wanted, status.UpdateRevision, formatLongDuration(stalledFor), threshold,
+	)
+	if statefulSet.Spec.UpdateStrategy.Type == appsv1.OnDeleteStatefulSetStrategyType {
+		alertmessage += " Its update strategy is OnDelete, so its pods are only updated once they are deleted."
+	}
+	alert := newAlert(resource, "UpdateStalled", types.SeverityWarning, alertmessage)
+	alertFn(alertSpec.AlerterType, alertSpec.AlerterName, alert, alertersConfig)
+}
+
+// statefulsetUpdateTarget returns how many replicas a rollout updates, leaving out the ordinals below
+// the partition of a partitioned rolling update
+func statefulsetUpdateTarget(statefulSet *appsv1.StatefulSet, replicas int32) int32 {
+	rollingUpdate := statefulSet.Spec.UpdateStrategy.RollingUpdate
+	if statefulSet.Spec.UpdateStrategy.Type == appsv1.OnDeleteStatefulSetStrategyType || rollingUpdate == nil || rollingUpdate.Partition == nil {
+		return replicas
+	}
+	if wanted := replicas - *rollingUpdate.Partition; wanted > 0 {
+		return wanted
+	}
+	return 0
+}
@@ -0,0 +1,207 @@
+// Copyright 2019 Bloomberg Finance LP
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package queries
+
+import (
+	"testing"
+	"time"
+
+	"github.com/bloomberg/k8eraid/pkgs/state"
+	. "github.com/bloomberg/k8eraid/pkgs/types"
+
+	"github.com/stretchr/testify/assert"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func Test_PollStatefulset(t *testing.T) {
+	_, conf := StubsInit()
+	replicas := int32(3)
+	old := metav1.Time{Time: time.Now().Add(-time.Hour)}
+
+	tests := []struct {
+		name        string
+		alertSpec   StatefulsetAlertSpec
+		objects     []runtime.Object
+		expected    []string
+		expectedErr bool
+	}{
+		{
+			name: "every replica ready, no alert",
+			objects: []runtime.Object{&appsv1.StatefulSet{
+				ObjectMeta: metav1.ObjectMeta{Name: "db", Namespace: metav1.NamespaceDefault, CreationTimestamp: old},
+				Spec:       appsv1.StatefulSetSpec{Replicas: &replicas},
+				Status:     appsv1.StatefulSetStatus{ReadyReplicas: 3},
+			}},
+			alertSpec: StatefulsetAlertSpec{Name: "db", StatefulsetFilter: metav1.NamespaceDefault, ReportStatus: StatefulsetAlertStatus{CheckReplicas: true}},
+			expected:  []string{},
+		},
+		{
+			name: "replica not ready, alert",
+			objects: []runtime.Object{&appsv1.StatefulSet{
+				ObjectMeta: metav1.ObjectMeta{Name: "db", Namespace: metav1.NamespaceDefault, CreationTimestamp: old},
+				Spec:       appsv1.StatefulSetSpec{Replicas: &replicas},
+				Status:     appsv1.StatefulSetStatus{ReadyReplicas: 2},
+			}},
+			alertSpec: StatefulsetAlertSpec{Name: "db", StatefulsetFilter: metav1.NamespaceDefault, ReportStatus: StatefulsetAlertStatus{CheckReplicas: true}},
+			expected:  []string{"statefulset/default/db/CheckReplicas"},
+		},
+		{
+			name: "replica not ready within the pending threshold, no alert",
+			objects: []runtime.Object{&appsv1.StatefulSet{
+				ObjectMeta: metav1.ObjectMeta{Name: "db", Namespace: metav1.NamespaceDefault, CreationTimestamp: metav1.Now()},
+				Spec:       appsv1.StatefulSetSpec{Replicas: &replicas},
+			}},
+			alertSpec: StatefulsetAlertSpec{Name: "db", StatefulsetFilter: metav1.NamespaceDefault, ReportStatus: StatefulsetAlertStatus{CheckReplicas: true, PendingThreshold: 60}},
+			expected:  []string{},
+		},
+		{
+			name:      "missing, alert",
+			alertSpec: StatefulsetAlertSpec{Name: "db", StatefulsetFilter: metav1.NamespaceDefault},
+			expected:  []string{"statefulset/default/db/Missing"},
+		},
+		{
+			name:      "missing but ignored, no alert",
+			alertSpec: StatefulsetAlertSpec{Name: "db", StatefulsetFilter: metav1.NamespaceDefault, ReportStatus: StatefulsetAlertStatus{IgnoreMissing: true}},
+			expected:  []string{},
+		},
+		{
+			name:        "no namespace filter, error",
+			alertSpec:   StatefulsetAlertSpec{Name: "db"},
+			expected:    []string{},
+			expectedErr: true,
+		},
+		{
+			name: "wildcard, alert on matching statefulsets",
+			objects: []runtime.Object{
+				&appsv1.StatefulSet{
+					ObjectMeta: metav1.ObjectMeta{Name: "db", Namespace: metav1.NamespaceDefault, CreationTimestamp: old, Labels: map[string]string{"tier": "data"}},
+					Spec:       appsv1.StatefulSetSpec{Replicas: &replicas},
+				},
+				&appsv1.StatefulSet{
+					ObjectMeta: metav1.ObjectMeta{Name: "queue", Namespace: metav1.NamespaceDefault, CreationTimestamp: old},
+					Spec:       appsv1.StatefulSetSpec{Replicas: &replicas},
+				},
+			},
+			alertSpec: StatefulsetAlertSpec{Name: "*", StatefulsetFilter: "tier=data", ReportStatus: StatefulsetAlertStatus{CheckReplicas: true}},
+			expected:  []string{"statefulset/default/db/CheckReplicas"},
+		},
+		{
+			name:        "wildcard with a namespace filter, error",
+			alertSpec:   StatefulsetAlertSpec{Name: "*", StatefulsetFilter: metav1.NamespaceDefault},
+			expected:    []string{},
+			expectedErr: true,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(subT *testing.T) {
+			client := fake.NewSimpleClientset(test.objects...)
+			keys := []string{}
+			alertStub := func(_ string, _ string, alert Alert, _ AlertersConfig) {
+				keys = append(keys, alert.Key)
+			}
+			err := PollStatefulset(client, test.alertSpec, defaultTickerTime, alertStub, conf)
+			assert.Equal(subT, test.expectedErr, err != nil, "unexpected error: %v", err)
+			assert.Equal(subT, test.expected, keys)
+		})
+	}
+}
+
+func Test_checkStatefulsetRollout(t *testing.T) {
+	stateStore = state.NewStore()
+	replicas := int32(3)
+	partition := int32(1)
+	statefulSet := &appsv1.StatefulSet{
+		ObjectMeta: metav1.ObjectMeta{Name: "db", Namespace: metav1.NamespaceDefault},
+		Spec:       appsv1.StatefulSetSpec{Replicas: &replicas},
+		Status:     appsv1.StatefulSetStatus{ReadyReplicas: 3, CurrentRevision: "db-1", UpdateRevision: "db-2", UpdatedReplicas: 1},
+	}
+	alertSpec := StatefulsetAlertSpec{Name: "db", StatefulsetFilter: metav1.NamespaceDefault, ReportStatus: StatefulsetAlertStatus{UpdateStallThreshold: 600}}
+	start := time.Unix(1000, 0)
+
+	assert.Empty(t, CheckStatefulset(statefulSet, alertSpec, start).Keys(), "a rollout seen for the first time is not stalled")
+	assert.Empty(t, CheckStatefulset(statefulSet, alertSpec, start.Add(5*time.Minute)).Keys())
+	assert.Equal(t, []string{
+		"StatefulSet default/db has updated 1 of 3 replicas to revision db-2 in 15m0s, longer than 600s! Its rollout is stalled.",
+	}, CheckStatefulset(statefulSet, alertSpec, start.Add(15*time.Minute)).Messages())
+
+	statefulSet.Status.UpdateRevision = "db-3"
+	assert.Empty(t, CheckStatefulset(statefulSet, alertSpec, start.Add(20*time.Minute)).Keys(), "a new revision restarts the rollout")
+
+	statefulSet.Spec.UpdateStrategy = appsv1.StatefulSetUpdateStrategy{Type: appsv1.OnDeleteStatefulSetStrategyType}
+	assert.Equal(t, []string{
+		"StatefulSet default/db has updated 1 of 3 replicas to revision db-3 in 15m0s, longer than 600s! Its rollout is stalled. Its update strategy is OnDelete, so its pods are only updated once they are deleted.",
+	}, CheckStatefulset(statefulSet, alertSpec, start.Add(35*time.Minute)).Messages())
+
+	statefulSet.Spec.UpdateStrategy = appsv1.StatefulSetUpdateStrategy{
+		Type:          appsv1.RollingUpdateStatefulSetStrategyType,
+		RollingUpdate: &appsv1.RollingUpdateStatefulSetStrategy{Partition: &partition},
+	}
+	statefulSet.Status.UpdatedReplicas = 2
+	assert.Empty(t, CheckStatefulset(statefulSet, alertSpec, start.Add(40*time.Minute)).Keys(), "the ordinals below the partition are not updated")
+	_, remembered := stateStore.SwapSnapshot("rollout/statefulset/default/db", nil)
+	assert.False(t, remembered, "finished rollouts should be forgotten")
+}
+
+func Test_PollStatefulset_PodSpread(t *testing.T) {
+	_, conf := StubsInit()
+	selector := &metav1.LabelSelector{MatchLabels: map[string]string{"app": "db"}}
+	statefulSet := &appsv1.StatefulSet{
+		ObjectMeta: metav1.ObjectMeta{Name: "db", Namespace: metav1.NamespaceDefault},
+		Spec: appsv1.StatefulSetSpec{
+			Selector: selector,
+			Template: corev1.PodTemplateSpec{
+				ObjectMeta: metav1.ObjectMeta{Labels: map[string]string{"app": "db"}},
+				Spec: corev1.PodSpec{Affinity: &corev1.Affinity{PodAntiAffinity: &corev1.PodAntiAffinity{
+					PreferredDuringSchedulingIgnoredDuringExecution: []corev1.WeightedPodAffinityTerm{{
+						Weight:          100,
+						PodAffinityTerm: corev1.PodAffinityTerm{LabelSelector: selector, TopologyKey: "kubernetes.io/hostname"},
+					}},
+				}}},
+			},
+		},
+	}
+	hostNode := func(name string) *corev1.Node {
+		node := readyNode(name, corev1.ConditionTrue)
+		node.Labels = map[string]string{"kubernetes.io/hostname": name}
+		return node
+	}
+	client := fake.NewSimpleClientset(
+		statefulSet,
+		hostNode("node-a"),
+		hostNode("node-b"),
+		scheduledPod("db-0", metav1.NamespaceDefault, "db", "node-a", corev1.ConditionTrue),
+		scheduledPod("db-1", metav1.NamespaceDefault, "db", "node-b", corev1.ConditionTrue),
+		scheduledPod("db-2", metav1.NamespaceDefault, "db", "node-a", corev1.ConditionTrue),
+	)
+
+	var messages []string
+	alertStub := func(_ string, _ string, alert Alert, _ AlertersConfig) {
+		messages = append(messages, alert.Key+": "+alert.Message)
+	}
+	alertSpec := StatefulsetAlertSpec{
+		Name:              "db",
+		StatefulsetFilter: metav1.NamespaceDefault,
+		ReportStatus:      StatefulsetAlertStatus{PodSpread: PodSpreadCheck{MaxNodePercent: 50, AntiAffinity: true}},
+	}
+	assert.NoError(t, PollStatefulset(client, alertSpec, defaultTickerTime, alertStub, conf))
+	assert.Equal(t, []string{
+		"statefulset/default/db/NodeSpread: StatefulSet default/db runs 2 of 3 ready pods (66%) on node node-a, more than the 50% allowed! Pods per node: node-a=2, node-b=1",
+		"statefulset/default/db/AntiAffinity: StatefulSet default/db runs 2 ready pods on kubernetes.io/hostname=node-a despite its preferred pod anti-affinity keeping them apart! Pods per kubernetes.io/hostname: node-a=2, node-b=1",
+	}, messages)
+}
@@ -0,0 +1,247 @@
+// Copyright 2019 Bloomberg Finance LP
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package queries
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/bloomberg/k8eraid/pkgs/types"
+
+	corev1 "k8s.io/api/core/v1"
+	storagev1 "k8s.io/api/storage/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+const (
+	// defaultStorageClassAnnotation marks the StorageClass claims naming no class are provisioned from
+	defaultStorageClassAnnotation = "storageclass.kubernetes.io/is-default-class"
+	// betaDefaultStorageClassAnnotation is the annotation clusters older than 1.6 mark it with
+	betaDefaultStorageClassAnnotation = "storageclass.beta.kubernetes.io/is-default-class"
+	// betaStorageClassAnnotation names the StorageClass of claims created before spec.storageClassName existed
+	betaStorageClassAnnotation = "volume.beta.kubernetes.io/storage-class"
+	// provisioningFailedReason is the reason of the events the PersistentVolume controller records on a
+	// claim it fails to provision a volume for
+	provisioningFailedReason = "ProvisioningFailed"
+)
+
+// defaultProvisioningWindow is how many seconds back provisioning failures are counted when a rule sets no window
+const defaultProvisioningWindow = 3600
+
+// PollStorageClass function takes inputs and iterates across StorageClasses in the kubernetes cluster, triggering alerts as needed.
+func PollStorageClass(
+	clientset kubernetes.Interface,
+	alertSpec types.StorageClassAlertSpec,
+	tickertime int64,
+	alertFn alertFunction,
+	alertersConfig types.AlertersConfig,
+) error {
+	if alertSpec.ReportStatus.ProvisioningWindow == 0 {
+		alertSpec.ReportStatus.ProvisioningWindow = defaultProvisioningWindow
+	}
+	now := time.Now()
+
+	if alertSpec.ReportStatus.NoDefault {
+		all, listerr := clientset.StorageV1().StorageClasses().List(metav1.ListOptions{TimeoutSeconds: &timeout})
+		if listerr != nil {
+			return &PollErr{
+				Message: fmt.Sprintf("Unable to list StorageClasses: %s", listerr.Error()),
+			}
+		}
+		checkDefaultStorageClass(all.Items, alertSpec, alertFn, alertersConfig)
+	}
+
+	var classes []storagev1.StorageClass
+	// If the class is not wildcard, search by name
+	if alertSpec.Name != "*" {
+		class, classerr := clientset.StorageV1().StorageClasses().Get(alertSpec.Name, metav1.GetOptions{})
+		if apierrors.IsNotFound(classerr) && alertSpec.ReportStatus.Missing {
+			return alertMissingStorageClass(clientset, alertSpec, alertFn, alertersConfig)
+		}
+		if classerr != nil {
+			return &PollErr{
+				Message: fmt.Sprintf("Error fetching StorageClass %s: %s", alertSpec.Name, classerr.Error()),
+			}
+		}
+		classes = append(classes, *class)
+		// If the class is a wildcard, list classes and iterate through
+	} else {
+		list, listerr := clientset.StorageV1().StorageClasses().List(metav1.ListOptions{
+			LabelSelector:  alertSpec.StorageClassFilter,
+			TimeoutSeconds: &timeout,
+		})
+		if listerr != nil {
+			return &PollErr{
+				Message: fmt.Sprintf("Unable to list StorageClasses: %s", listerr.Error()),
+			}
+		}
+		classes = list.Items
+	}
+	sort.Slice(classes, func(i, j int) bool { return classes[i].Name < classes[j].Name })
+
+	if alertSpec.ReportStatus.ProvisioningFailures > 0 && len(classes) > 0 {
+		claims, claimserr := clientset.CoreV1().PersistentVolumeClaims("").List(metav1.ListOptions{TimeoutSeconds: &timeout})
+		if claimserr != nil {
+			return &PollErr{
+				Message: fmt.Sprintf("Unable to list PersistentVolumeClaims: %s", claimserr.Error()),
+			}
+		}
+		events, eventserr := clientset.CoreV1().Events("").List(metav1.ListOptions{
+			FieldSelector:  "involvedObject.kind=PersistentVolumeClaim,reason=" + provisioningFailedReason,
+			TimeoutSeconds: &timeout,
+		})
+		if eventserr != nil {
+			return &PollErr{
+				Message: fmt.Sprintf("Unable to list PersistentVolumeClaim events: %s", eventserr.Error()),
+			}
+		}
+		checkProvisioningFailures(classes, claims.Items, events.Items, alertSpec, now, alertFn, alertersConfig)
+	}
+	return nil
+}
+
+// checkDefaultStorageClass alerts when none of classes is marked as the default
+func checkDefaultStorageClass(
+	classes []storagev1.StorageClass,
+	alertSpec types.StorageClassAlertSpec,
+	alertFn alertFunction,
+	alertersConfig types.AlertersConfig,
+) {
+	for _, class := range classes {
+		if isDefaultStorageClass(&class) {
+			return
+		}
+	}
+	// ALERT
+	alertmessage := fmt.Sprintf(
+		"No StorageClass is marked as the default, out of %d! Claims that name no class stay Pending until one is annotated %s=true.",
+		len(classes), defaultStorageClassAnnotation,
+	)
+	alert := newAlert(resourceID("storageclasses", "", ""), "NoDefault", types.SeverityWarning, alertmessage)
+	alertFn(alertSpec.AlerterType, alertSpec.AlerterName, alert, alertersConfig)
+}
+
+// alertMissingStorageClass alerts on the StorageClass a named rule checks no longer existing, naming the
+// Pending claims that request it
+func alertMissingStorageClass(
+	clientset kubernetes.Interface,
+	alertSpec types.StorageClassAlertSpec,
+	alertFn alertFunction,
+	alertersConfig types.AlertersConfig,
+) error {
+	claims, claimserr := clientset.CoreV1().PersistentVolumeClaims("").List(metav1.ListOptions{TimeoutSeconds: &timeout})
+	if claimserr != nil {
+		return &PollErr{
+			Message: fmt.Sprintf("Unable to list PersistentVolumeClaims: %s", claimserr.Error()),
+		}
+	}
+	var pending []string
+	for i := range claims.Items {
+		claim := &claims.Items[i]
+		if claim.Status.Phase == corev1.ClaimPending && claimStorageClass(claim) == alertSpec.Name {
+			pending = append(pending, claim.Namespace+"/"+claim.Name)
+		}
+	}
+	sort.Strings(pending)
+	// ALERT
+	alertmessage := fmt.Sprintf("StorageClass %s does not exist! No volume can be provisioned for the claims requesting it.", alertSpec.Name)
+	if len(pending) > 0 {
+		alertmessage += fmt.Sprintf(" %d Pending claims request it: %s.", len(pending), strings.Join(pending, ", "))
+	}
+	alert := newAlert(resourceID("storageclass", "", alertSpec.Name), "Missing", types.SeverityCritical, alertmessage)
+	alertFn(alertSpec.AlerterType, alertSpec.AlerterName, alert, alertersConfig)
+	return nil
+}
+
+// checkProvisioningFailures alerts on the classes whose claims failed to be provisioned at least as many
+// times as the rule allows over its window, with the latest error. The failures of an event are counted
+// when it was last recorded within the window; the event of a claim failing over and over is a single
+// one whose count rises.
+func checkProvisioningFailures(
+	classes []storagev1.StorageClass,
+	claims []corev1.PersistentVolumeClaim,
+	events []corev1.Event,
+	alertSpec types.StorageClassAlertSpec,
+	now time.Time,
+	alertFn alertFunction,
+	alertersConfig types.AlertersConfig,
+) {
+	classOf := map[string]string{}
+	for i := range claims {
+		classOf[claims[i].Namespace+"/"+claims[i].Name] = claimStorageClass(&claims[i])
+	}
+	since := now.Add(-time.Duration(alertSpec.ReportStatus.ProvisioningWindow) * time.Second)
+	failures := map[string]int32{}
+	failedClaims := map[string]map[string]bool{}
+	latest := map[string]corev1.Event{}
+	for _, event := range events {
+		if event.InvolvedObject.Kind != "PersistentVolumeClaim" || event.Reason != provisioningFailedReason || event.LastTimestamp.Time.Before(since) {
+			continue
+		}
+		claim := event.InvolvedObject.Namespace + "/" + event.InvolvedObject.Name
+		class, known := classOf[claim]
+		if !known {
+			continue
+		}
+		count := event.Count
+		if count == 0 {
+			count = 1
+		}
+		failures[class] += count
+		if failedClaims[class] == nil {
+			failedClaims[class] = map[string]bool{}
+		}
+		failedClaims[class][claim] = true
+		if previous, ok := latest[class]; !ok || previous.LastTimestamp.Before(&event.LastTimestamp) {
+			latest[class] = event
+		}
+	}
+
+	for _, class := range classes {
+		if failures[class.Name] < alertSpec.ReportStatus.ProvisioningFailures {
+			continue
+		}
+		names := make([]string, 0, len(failedClaims[class.Name]))
+		for claim := range failedClaims[class.Name] {
+			names = append(names, claim)
+		}
+		sort.Strings(names)
+		// ALERT
+		alertmessage := fmt.Sprintf(
+			"StorageClass %s failed to provision volumes %d times within %ds, at least %d! Provisioner %s failed for %d claims (%s), latest with: %s",
+			class.Name, failures[class.Name], alertSpec.ReportStatus.ProvisioningWindow, alertSpec.ReportStatus.ProvisioningFailures,
+			class.Provisioner, len(names), strings.Join(names, ", "), latest[class.Name].Message,
+		)
+		alert := newAlert(resourceID("storageclass", "", class.Name), "ProvisioningFailed", types.SeverityCritical, alertmessage)
+		alertFn(alertSpec.AlerterType, alertSpec.AlerterName, alert, alertersConfig)
+	}
+}
+
+// isDefaultStorageClass tells whether class is marked as the default of the cluster
+func isDefaultStorageClass(class *storagev1.StorageClass) bool {
+	return class.Annotations[defaultStorageClassAnnotation] == "true" || class.Annotations[betaDefaultStorageClassAnnotation] == "true"
+}
+
+// claimStorageClass returns the name of the StorageClass claim requests, empty when it names none
+func claimStorageClass(claim *corev1.PersistentVolumeClaim) string {
+	if claim.Spec.StorageClassName != nil {
+		return *claim.Spec.StorageClassName
+	}
+	return claim.Annotations[betaStorageClassAnnotation]
+}
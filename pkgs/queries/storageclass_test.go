@@ -0,0 +1,111 @@
+// Copyright 2019 Bloomberg Finance LP
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package queries
+
+import (
+	"testing"
+	"time"
+
+	. "github.com/bloomberg/k8eraid/pkgs/types"
+
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	storagev1 "k8s.io/api/storage/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func storageClass(name string, annotations map[string]string) *storagev1.StorageClass {
+	return &storagev1.StorageClass{
+		ObjectMeta:  metav1.ObjectMeta{Name: name, Annotations: annotations},
+		Provisioner: "ebs.csi.aws.com",
+	}
+}
+
+func storageClaim(name string, class string, phase corev1.PersistentVolumeClaimPhase) *corev1.PersistentVolumeClaim {
+	return &corev1.PersistentVolumeClaim{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "db"},
+		Spec:       corev1.PersistentVolumeClaimSpec{StorageClassName: &class},
+		Status:     corev1.PersistentVolumeClaimStatus{Phase: phase},
+	}
+}
+
+func Test_PollStorageClass(t *testing.T) {
+	_, conf := StubsInit()
+	now := time.Now()
+	event := func(name string, claim string, count int32, last time.Time, message string) *corev1.Event {
+		return &corev1.Event{
+			ObjectMeta:     metav1.ObjectMeta{Name: name, Namespace: "db"},
+			InvolvedObject: corev1.ObjectReference{Kind: "PersistentVolumeClaim", Namespace: "db", Name: claim},
+			Type:           corev1.EventTypeWarning,
+			Reason:         provisioningFailedReason,
+			Count:          count,
+			LastTimestamp:  metav1.Time{Time: last},
+			Message:        message,
+		}
+	}
+	legacy := storageClaim("data-legacy", "", corev1.ClaimPending)
+	legacy.Spec.StorageClassName = nil
+	legacy.Annotations = map[string]string{betaStorageClassAnnotation: "fast"}
+	client := fake.NewSimpleClientset(
+		storageClass("fast", nil),
+		storageClass("slow", nil),
+		storageClaim("data-0", "fast", corev1.ClaimPending),
+		storageClaim("data-1", "fast", corev1.ClaimPending),
+		storageClaim("data-2", "slow", corev1.ClaimPending),
+		storageClaim("data-3", "retired", corev1.ClaimPending),
+		storageClaim("data-4", "retired", corev1.ClaimBound),
+		legacy,
+		event("data-0.1", "data-0", 3, now.Add(-time.Minute), "volume limit exceeded"),
+		event("data-1.1", "data-1", 1, now.Add(-30*time.Minute), "quota exceeded"),
+		event("data-legacy.1", "data-legacy", 4, now.Add(-2*time.Hour), "outside the window"),
+		event("data-2.1", "data-2", 1, now.Add(-time.Minute), "once"),
+	)
+	poll := func(alertSpec StorageClassAlertSpec) ([]string, error) {
+		messages := []string{}
+		err := PollStorageClass(client, alertSpec, defaultTickerTime, func(_ string, _ string, alert Alert, _ AlertersConfig) {
+			messages = append(messages, alert.Key+": "+alert.Message)
+		}, conf)
+		return messages, err
+	}
+
+	messages, err := poll(StorageClassAlertSpec{Name: "*", ReportStatus: StorageClassAlertStatus{NoDefault: true, ProvisioningFailures: 2}})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{
+		"storageclasses/NoDefault: No StorageClass is marked as the default, out of 2! Claims that name no class stay Pending until one is annotated storageclass.kubernetes.io/is-default-class=true.",
+		"storageclass/fast/ProvisioningFailed: StorageClass fast failed to provision volumes 4 times within 3600s, at least 2! Provisioner ebs.csi.aws.com failed for 2 claims (db/data-0, db/data-1), latest with: volume limit exceeded",
+	}, messages)
+
+	messages, err = poll(StorageClassAlertSpec{Name: "fast", ReportStatus: StorageClassAlertStatus{ProvisioningFailures: 5, ProvisioningWindow: 3 * 3600}})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{
+		"storageclass/fast/ProvisioningFailed: StorageClass fast failed to provision volumes 8 times within 10800s, at least 5! Provisioner ebs.csi.aws.com failed for 3 claims (db/data-0, db/data-1, db/data-legacy), latest with: volume limit exceeded",
+	}, messages, "claims may name their class in the legacy annotation")
+
+	messages, err = poll(StorageClassAlertSpec{Name: "retired", ReportStatus: StorageClassAlertStatus{Missing: true}})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{
+		"storageclass/retired/Missing: StorageClass retired does not exist! No volume can be provisioned for the claims requesting it. 1 Pending claims request it: db/data-3.",
+	}, messages)
+
+	_, err = poll(StorageClassAlertSpec{Name: "retired"})
+	assert.Error(t, err, "a missing class is an error unless the rule alerts on it")
+
+	_, err = client.StorageV1().StorageClasses().Create(storageClass("standard", map[string]string{betaDefaultStorageClassAnnotation: "true"}))
+	assert.NoError(t, err)
+	messages, err = poll(StorageClassAlertSpec{Name: "*", ReportStatus: StorageClassAlertStatus{NoDefault: true}})
+	assert.NoError(t, err)
+	assert.Empty(t, messages)
+}
@@ -0,0 +1,92 @@
+// Copyright 2019 Bloomberg Finance LP
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package queries
+
+import (
+	"fmt"
+	"path"
+	"time"
+
+	"github.com/bloomberg/k8eraid/pkgs/types"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+// checkNodeTermination alerts on every termination notice of the rule that node carries, such as the
+// taint aws-node-termination-handler puts on a spot instance about to be interrupted, so the alert
+// comes before the node disappears rather than after. Taints that do not record when they were added
+// are counted from the first poll that saw them.
+func checkNodeTermination(
+	node *corev1.Node,
+	alertSpec types.NodeAlertSpec,
+	now time.Time,
+	alertFn alertFunction,
+	alertersConfig types.AlertersConfig,
+) {
+	resource := resourceID("node", "", node.Name)
+	for _, notice := range alertSpec.ReportStatus.TerminationNotices {
+		id, description, noticedAt, found := terminationNotice(node, notice)
+		noticedFor := unhealthyFor("termination/"+resource+"/"+notice.Taint+notice.Condition, found, node.CreationTimestamp.Time, now)
+		if !found {
+			continue
+		}
+		if !noticedAt.IsZero() {
+			noticedFor = now.Sub(noticedAt)
+		}
+		outlook := "It may disappear at any time."
+		if notice.NoticeSeconds > 0 {
+			left := time.Duration(notice.NoticeSeconds)*time.Second - noticedFor
+			outlook = fmt.Sprintf("It is expected to terminate in %s.", left.Round(time.Second))
+			if left <= 0 {
+				outlook = fmt.Sprintf("It was expected to terminate %s ago.", (-left).Round(time.Second))
+			}
+		}
+		severity := notice.Severity
+		if severity == "" {
+			severity = types.SeverityCritical
+		}
+		// ALERT
+		alertmessage := fmt.Sprintf(
+			"Node %s received a termination notice (%s) %s ago! %s",
+			node.Name, description, noticedFor.Round(time.Second), outlook,
+		)
+		alert := newAlert(resource, "TerminationNotice/"+id, severity, alertmessage)
+		alertFn(alertSpec.AlerterType, alertSpec.AlerterName, alert, alertersConfig)
+	}
+}
+
+// terminationNotice finds the taint or condition matching notice on node. It returns the taint key or
+// condition type, how to describe it, and when it was put on the node if that is recorded.
+func terminationNotice(node *corev1.Node, notice types.NodeTerminationNotice) (string, string, time.Time, bool) {
+	if notice.Taint != "" {
+		for _, taint := range node.Spec.Taints {
+			if matched, _ := path.Match(notice.Taint, taint.Key); !matched {
+				continue
+			}
+			var added time.Time
+			if taint.TimeAdded != nil {
+				added = taint.TimeAdded.Time
+			}
+			return taint.Key, "taint " + formatTaint(taint), added, true
+		}
+		return "", "", time.Time{}, false
+	}
+	for _, condition := range node.Status.Conditions {
+		if string(condition.Type) == notice.Condition && condition.Status == corev1.ConditionTrue {
+			return notice.Condition, "condition " + notice.Condition + ": " + conditionReason(condition), condition.LastTransitionTime.Time, true
+		}
+	}
+	return "", "", time.Time{}, false
+}
@@ -0,0 +1,79 @@
+// Copyright 2019 Bloomberg Finance LP
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package queries
+
+import (
+	"testing"
+	"time"
+
+	"github.com/bloomberg/k8eraid/pkgs/state"
+	. "github.com/bloomberg/k8eraid/pkgs/types"
+
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func Test_checkNodeTermination(t *testing.T) {
+	stateStore = state.NewStore()
+	_, conf := StubsInit()
+	now := time.Unix(1000000, 0)
+	alertSpec := NodeAlertSpec{Name: "*", ReportStatus: NodeAlertStatus{TerminationNotices: []NodeTerminationNotice{
+		{Taint: "aws-node-termination-handler/spot-*", NoticeSeconds: 120},
+		{Taint: "aws-node-termination-handler/scheduled-maintenance", Severity: SeverityWarning},
+		{Condition: "TerminationScheduled", NoticeSeconds: 60},
+	}}}
+	var alerts []Alert
+	alertStub := func(_ string, _ string, alert Alert, _ AlertersConfig) {
+		alerts = append(alerts, alert)
+	}
+	check := func(node *corev1.Node, at time.Time) []string {
+		alerts = nil
+		checkNodeTermination(node, alertSpec, at, alertStub, conf)
+		messages := []string{}
+		for _, alert := range alerts {
+			messages = append(messages, alert.Key+" ["+alert.Severity+"]: "+alert.Message)
+		}
+		return messages
+	}
+
+	node := readyNode("spot-1", corev1.ConditionTrue)
+	node.CreationTimestamp = metav1.Time{Time: now.Add(-time.Hour)}
+	assert.Empty(t, check(node, now))
+
+	node.Spec.Taints = []corev1.Taint{
+		{Key: "aws-node-termination-handler/spot-itn", Value: "event-1", Effect: corev1.TaintEffectNoSchedule},
+		{Key: "aws-node-termination-handler/scheduled-maintenance", Effect: corev1.TaintEffectNoExecute, TimeAdded: &metav1.Time{Time: now.Add(-10 * time.Minute)}},
+	}
+	node.Status.Conditions = append(node.Status.Conditions, corev1.NodeCondition{
+		Type:               "TerminationScheduled",
+		Status:             corev1.ConditionTrue,
+		Reason:             "Preempted",
+		LastTransitionTime: metav1.Time{Time: now.Add(-90 * time.Second)},
+	})
+	assert.Equal(t, []string{
+		"node/spot-1/TerminationNotice/aws-node-termination-handler/spot-itn [critical]: Node spot-1 received a termination notice (taint aws-node-termination-handler/spot-itn=event-1:NoSchedule) 0s ago! It is expected to terminate in 2m0s.",
+		"node/spot-1/TerminationNotice/aws-node-termination-handler/scheduled-maintenance [warning]: Node spot-1 received a termination notice (taint aws-node-termination-handler/scheduled-maintenance:NoExecute) 10m0s ago! It may disappear at any time.",
+		"node/spot-1/TerminationNotice/TerminationScheduled [critical]: Node spot-1 received a termination notice (condition TerminationScheduled: Preempted) 1m30s ago! It was expected to terminate 30s ago.",
+	}, check(node, now))
+	assert.Equal(t,
+		"node/spot-1/TerminationNotice/aws-node-termination-handler/spot-itn [critical]: Node spot-1 received a termination notice (taint aws-node-termination-handler/spot-itn=event-1:NoSchedule) 30s ago! It is expected to terminate in 1m30s.",
+		check(node, now.Add(30*time.Second))[0], "taints without a time are counted from when they were first seen",
+	)
+
+	node.Spec.Taints = nil
+	node.Status.Conditions = node.Status.Conditions[:1]
+	assert.Empty(t, check(node, now.Add(time.Minute)))
+}
@@ -0,0 +1,83 @@
+// Copyright 2019 Bloomberg Finance LP
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package queries
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/bloomberg/k8eraid/pkgs/state"
+	"github.com/bloomberg/k8eraid/pkgs/types"
+)
+
+const defaultTrendMinSamples = 3
+
+// checkTrend records value for resource and alerts when the signal has been falling faster than the
+// rule allows. The trend is only evaluated once the samples cover at least half of the window, so a
+// couple of noisy ticks after startup or a new object cannot trigger it.
+func checkTrend(
+	resource string,
+	check string,
+	description string,
+	value float64,
+	now time.Time,
+	trend types.TrendCheck,
+	alerterType string,
+	alerterName string,
+	alertFn alertFunction,
+	alertersConfig types.AlertersConfig,
+) {
+	if trend.Window <= 0 {
+		return
+	}
+
+	samples, ok := recordTrend(resource+"/"+check, value, now, time.Duration(trend.Window)*time.Second, trend.MinSamples)
+	if !ok {
+		return
+	}
+	first, last := samples[0], samples[len(samples)-1]
+	span := last.Time.Sub(first.Time)
+
+	perHour := state.Slope(samples) * time.Hour.Seconds()
+	if -perHour > trend.MaxDropPerHour && last.Value < first.Value {
+		// ALERT
+		alertmessage := fmt.Sprintf(
+			"%s fell from %g to %g over the last %s (%.2f per hour), faster than the allowed %.2f per hour!",
+			description,
+			first.Value,
+			last.Value,
+			span.Round(time.Second),
+			-perHour,
+			trend.MaxDropPerHour,
+		)
+		alertFn(alerterType, alerterName, newAlert(resource, check, types.SeverityWarning, alertmessage), alertersConfig)
+	}
+}
+
+// recordTrend records value in the series of key and returns the samples within window once there are
+// at least minSamples of them, 3 when lower than 2, covering at least half of the window
+func recordTrend(key string, value float64, now time.Time, window time.Duration, minSamples int) ([]state.Sample, bool) {
+	if minSamples < 2 {
+		minSamples = defaultTrendMinSamples
+	}
+	samples := stateStore.RecordSample(key, state.Sample{Time: now, Value: value}, window)
+	if len(samples) < minSamples {
+		return nil, false
+	}
+	if samples[len(samples)-1].Time.Sub(samples[0].Time) < window/2 {
+		return nil, false
+	}
+	return samples, true
+}
@@ -0,0 +1,82 @@
+// Copyright 2019 Bloomberg Finance LP
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package queries
+
+import (
+	"testing"
+	"time"
+
+	"github.com/bloomberg/k8eraid/pkgs/state"
+	. "github.com/bloomberg/k8eraid/pkgs/types"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_checkTrend(t *testing.T) {
+	_, conf := StubsInit()
+	trend := TrendCheck{Window: 3600, MaxDropPerHour: 2}
+	start := time.Unix(100000, 0)
+
+	tests := []struct {
+		name        string
+		values      []float64
+		step        time.Duration
+		shouldAlert bool
+	}{
+		{
+			name:   "steady: no alert",
+			values: []float64{10, 10, 10, 10, 10},
+			step:   10 * time.Minute,
+		},
+		{
+			name:   "slow drift within slope: no alert",
+			values: []float64{10, 10, 10, 9, 9},
+			step:   10 * time.Minute,
+		},
+		{
+			name:        "sustained decline: alert",
+			values:      []float64{10, 9, 8, 7, 6},
+			step:        10 * time.Minute,
+			shouldAlert: true,
+		},
+		{
+			name:   "steep decline not yet covering half the window: no alert",
+			values: []float64{10, 8, 6, 4, 2},
+			step:   time.Minute,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(subT *testing.T) {
+			stateStore = state.NewStore()
+			var alerts []Alert
+			alertStub := func(_ string, _ string, alert Alert, _ AlertersConfig) {
+				alerts = append(alerts, alert)
+			}
+			for i, value := range test.values {
+				now := start.Add(time.Duration(i) * test.step)
+				checkTrend("deployment/default/web", "AvailableTrend", "Deployment default/web available replicas", value, now, trend, "stderr", "", alertStub, conf)
+			}
+			if !test.shouldAlert {
+				assert.Empty(subT, alerts)
+				return
+			}
+			if assert.NotEmpty(subT, alerts) {
+				last := alerts[len(alerts)-1]
+				assert.Equal(subT, "deployment/default/web/AvailableTrend", last.Key)
+				assert.Equal(subT, "Deployment default/web available replicas fell from 10 to 6 over the last 40m0s (6.00 per hour), faster than the allowed 2.00 per hour!", last.Message)
+			}
+		})
+	}
+}
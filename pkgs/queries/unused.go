@@ -0,0 +1,279 @@
+// Copyright 2019 Bloomberg Finance LP
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package queries
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/bloomberg/k8eraid/pkgs/state"
+	"github.com/bloomberg/k8eraid/pkgs/types"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+const defaultUnusedThreshold = 7 * 24 * time.Hour
+
+// unusedObject is an object nothing uses. Its alert message is description followed by how long
+// it has been unused.
+type unusedObject struct {
+	resource    string
+	created     time.Time
+	description string
+}
+
+// PollUnused function takes inputs and looks for PersistentVolumes, Secrets and ConfigMaps in the kubernetes cluster that
+// nothing uses, triggering alerts as needed.
+func PollUnused(
+	clientset kubernetes.Interface,
+	alertSpec types.UnusedAlertSpec,
+	tickertime int64,
+	alertFn alertFunction,
+	alertersConfig types.AlertersConfig,
+) error {
+	return checkUnused(clientset, alertSpec, time.Now(), alertFn, alertersConfig)
+}
+
+// checkUnused alerts on every matching object that has been unused for longer than the threshold.
+// When an object becomes unused is remembered across ticks. Objects already unused on the first
+// tick of a rule are taken to have been unused since they were created.
+func checkUnused(
+	clientset kubernetes.Interface,
+	alertSpec types.UnusedAlertSpec,
+	now time.Time,
+	alertFn alertFunction,
+	alertersConfig types.AlertersConfig,
+) error {
+	threshold := defaultUnusedThreshold
+	if alertSpec.ReportStatus.UnusedThreshold > 0 {
+		threshold = time.Duration(alertSpec.ReportStatus.UnusedThreshold) * time.Second
+	}
+	listopts := metav1.ListOptions{
+		LabelSelector:  alertSpec.UnusedFilterLabel,
+		TimeoutSeconds: &timeout,
+	}
+
+	var unused []unusedObject
+	if alertSpec.ReportStatus.PersistentVolumes {
+		volumes, volumeserr := clientset.CoreV1().PersistentVolumes().List(listopts)
+		if volumeserr != nil {
+			return &PollErr{
+				Message: fmt.Sprintf("Unable to list PersistentVolumes: %s", volumeserr.Error()),
+			}
+		}
+		for _, volume := range volumes.Items {
+			if volume.Status.Phase == corev1.VolumeBound {
+				continue
+			}
+			unused = append(unused, unusedObject{
+				resource:    resourceID("persistentvolume", "", volume.Name),
+				created:     volume.CreationTimestamp.Time,
+				description: fmt.Sprintf("PersistentVolume %s is %s and has not been bound to a claim for", volume.Name, volume.Status.Phase),
+			})
+		}
+	}
+
+	if alertSpec.ReportStatus.Secrets || alertSpec.ReportStatus.ConfigMaps {
+		references, err := podSpecReferences(clientset, alertSpec.UnusedFilterNamespace)
+		if err != nil {
+			return err
+		}
+		if alertSpec.ReportStatus.Secrets {
+			secrets, secretserr := clientset.CoreV1().Secrets(alertSpec.UnusedFilterNamespace).List(listopts)
+			if secretserr != nil {
+				return &PollErr{
+					Message: fmt.Sprintf("Unable to list Secrets: %s", secretserr.Error()),
+				}
+			}
+			for _, secret := range secrets.Items {
+				// token Secrets belong to their ServiceAccount, which mounts them on its own
+				if secret.Type == corev1.SecretTypeServiceAccountToken || references.secrets[secret.Namespace+"/"+secret.Name] {
+					continue
+				}
+				unused = append(unused, unusedObject{
+					resource:    resourceID("secret", secret.Namespace, secret.Name),
+					created:     secret.CreationTimestamp.Time,
+					description: fmt.Sprintf("Secret %s/%s is not referenced by any pod or deployment in its namespace and has been unused for", secret.Namespace, secret.Name),
+				})
+			}
+		}
+		if alertSpec.ReportStatus.ConfigMaps {
+			configMaps, configmapserr := clientset.CoreV1().ConfigMaps(alertSpec.UnusedFilterNamespace).List(listopts)
+			if configmapserr != nil {
+				return &PollErr{
+					Message: fmt.Sprintf("Unable to list ConfigMaps: %s", configmapserr.Error()),
+				}
+			}
+			for _, configMap := range configMaps.Items {
+				if references.configMaps[configMap.Namespace+"/"+configMap.Name] {
+					continue
+				}
+				unused = append(unused, unusedObject{
+					resource:    resourceID("configmap", configMap.Namespace, configMap.Name),
+					created:     configMap.CreationTimestamp.Time,
+					description: fmt.Sprintf("ConfigMap %s/%s is not referenced by any pod or deployment in its namespace and has been unused for", configMap.Namespace, configMap.Name),
+				})
+			}
+		}
+	}
+
+	since := unusedSince(specKey("unused", alertSpec.UnusedFilterNamespace, alertSpec.UnusedFilterLabel), unused, now)
+	sort.Slice(unused, func(i, j int) bool { return unused[i].resource < unused[j].resource })
+	for _, object := range unused {
+		unusedFor := now.Sub(since[object.resource])
+		if unusedFor <= threshold {
+			continue
+		}
+		// ALERT
+		alertmessage := fmt.Sprintf("%s %s!", object.description, formatLongDuration(unusedFor))
+		alert := newAlert(object.resource, "Unused", types.SeverityInfo, alertmessage)
+		alertFn(alertSpec.AlerterType, alertSpec.AlerterName, alert, alertersConfig)
+	}
+	return nil
+}
+
+// unusedSince records in the state store since when each of the unused objects of the rule key
+// has been unused, and returns those times. An object that was in use on the previous tick became
+// unused at now.
+func unusedSince(key string, unused []unusedObject, now time.Time) map[string]time.Time {
+	since := map[string]time.Time{}
+	stateStore.UpdateSnapshot(key, func(previous state.Snapshot, ok bool) state.Snapshot {
+		current := state.Snapshot{}
+		for _, object := range unused {
+			at := now
+			if recorded, err := time.Parse(time.RFC3339, previous[object.resource]); err == nil {
+				at = recorded
+			} else if !ok {
+				// nothing is known of what happened before the first tick
+				at = object.created
+			}
+			since[object.resource] = at
+			current[object.resource] = at.UTC().Format(time.RFC3339)
+		}
+		return current
+	})
+	return since
+}
+
+// formatLongDuration rounds to whole days past two days, and to minutes below
+func formatLongDuration(d time.Duration) string {
+	if d >= 48*time.Hour {
+		return fmt.Sprintf("%d days", int64(d/(24*time.Hour)))
+	}
+	return d.Round(time.Minute).String()
+}
+
+// configReferences are the Secrets and ConfigMaps pod specs reference, by namespace/name. The
+// required ones are those the references do not mark optional.
+type configReferences struct {
+	secrets            map[string]bool
+	configMaps         map[string]bool
+	requiredSecrets    map[string]bool
+	requiredConfigMaps map[string]bool
+}
+
+// podSpecReferences collects what the pods and the deployment pod templates of namespace, or of
+// every namespace when it is empty, reference
+func podSpecReferences(clientset kubernetes.Interface, namespace string) (configReferences, error) {
+	references := configReferences{
+		secrets:            map[string]bool{},
+		configMaps:         map[string]bool{},
+		requiredSecrets:    map[string]bool{},
+		requiredConfigMaps: map[string]bool{},
+	}
+	listopts := metav1.ListOptions{TimeoutSeconds: &timeout}
+	pods, podserr := clientset.CoreV1().Pods(namespace).List(listopts)
+	if podserr != nil {
+		return references, &PollErr{
+			Message: fmt.Sprintf("Unable to list pods referencing Secrets and ConfigMaps: %s", podserr.Error()),
+		}
+	}
+	for _, pod := range pods.Items {
+		references.add(pod.Namespace, pod.Spec)
+	}
+	deployments, deploymentserr := clientset.AppsV1().Deployments(namespace).List(listopts)
+	if deploymentserr != nil {
+		return references, &PollErr{
+			Message: fmt.Sprintf("Unable to list deployments referencing Secrets and ConfigMaps: %s", deploymentserr.Error()),
+		}
+	}
+	for _, deployment := range deployments.Items {
+		references.add(deployment.Namespace, deployment.Spec.Template.Spec)
+	}
+	return references, nil
+}
+
+// add records the Secrets and ConfigMaps spec mounts as volumes, reads into the environment of
+// its containers or pulls images with
+func (r configReferences) add(namespace string, spec corev1.PodSpec) {
+	secret := func(name string, optional *bool) {
+		r.secrets[namespace+"/"+name] = true
+		if optional == nil || !*optional {
+			r.requiredSecrets[namespace+"/"+name] = true
+		}
+	}
+	configMap := func(name string, optional *bool) {
+		r.configMaps[namespace+"/"+name] = true
+		if optional == nil || !*optional {
+			r.requiredConfigMaps[namespace+"/"+name] = true
+		}
+	}
+
+	for _, pullSecret := range spec.ImagePullSecrets {
+		secret(pullSecret.Name, nil)
+	}
+	for _, volume := range spec.Volumes {
+		if volume.Secret != nil {
+			secret(volume.Secret.SecretName, volume.Secret.Optional)
+		}
+		if volume.ConfigMap != nil {
+			configMap(volume.ConfigMap.Name, volume.ConfigMap.Optional)
+		}
+		if volume.Projected != nil {
+			for _, source := range volume.Projected.Sources {
+				if source.Secret != nil {
+					secret(source.Secret.Name, source.Secret.Optional)
+				}
+				if source.ConfigMap != nil {
+					configMap(source.ConfigMap.Name, source.ConfigMap.Optional)
+				}
+			}
+		}
+	}
+	for _, container := range append(append([]corev1.Container(nil), spec.InitContainers...), spec.Containers...) {
+		for _, envFrom := range container.EnvFrom {
+			if envFrom.SecretRef != nil {
+				secret(envFrom.SecretRef.Name, envFrom.SecretRef.Optional)
+			}
+			if envFrom.ConfigMapRef != nil {
+				configMap(envFrom.ConfigMapRef.Name, envFrom.ConfigMapRef.Optional)
+			}
+		}
+		for _, env := range container.Env {
+			if env.ValueFrom == nil {
+				continue
+			}
+			if env.ValueFrom.SecretKeyRef != nil {
+				secret(env.ValueFrom.SecretKeyRef.Name, env.ValueFrom.SecretKeyRef.Optional)
+			}
+			if env.ValueFrom.ConfigMapKeyRef != nil {
+				configMap(env.ValueFrom.ConfigMapKeyRef.Name, env.ValueFrom.ConfigMapKeyRef.Optional)
+			}
+		}
+	}
+}
@@ -0,0 +1,154 @@
+// Copyright 2019 Bloomberg Finance LP
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package queries
+
+import (
+	"testing"
+	"time"
+
+	"github.com/bloomberg/k8eraid/pkgs/state"
+	. "github.com/bloomberg/k8eraid/pkgs/types"
+
+	"github.com/stretchr/testify/assert"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+var unusedTime = time.Unix(10000000, 0)
+
+func unusedMeta(name string, age time.Duration) metav1.ObjectMeta {
+	return metav1.ObjectMeta{Name: name, Namespace: metav1.NamespaceDefault, CreationTimestamp: metav1.Time{Time: unusedTime.Add(-age)}}
+}
+
+func unusedObjects() []runtime.Object {
+	month := 30 * 24 * time.Hour
+	return []runtime.Object{
+		&corev1.PersistentVolume{ObjectMeta: metav1.ObjectMeta{Name: "pv-bound", CreationTimestamp: metav1.Time{Time: unusedTime.Add(-month)}}, Status: corev1.PersistentVolumeStatus{Phase: corev1.VolumeBound}},
+		&corev1.PersistentVolume{ObjectMeta: metav1.ObjectMeta{Name: "pv-released", CreationTimestamp: metav1.Time{Time: unusedTime.Add(-month)}}, Status: corev1.PersistentVolumeStatus{Phase: corev1.VolumeReleased}},
+		&corev1.PersistentVolume{ObjectMeta: metav1.ObjectMeta{Name: "pv-new", CreationTimestamp: metav1.Time{Time: unusedTime.Add(-time.Hour)}}, Status: corev1.PersistentVolumeStatus{Phase: corev1.VolumeAvailable}},
+		&corev1.Secret{ObjectMeta: unusedMeta("mounted", month)},
+		&corev1.Secret{ObjectMeta: unusedMeta("pull", month)},
+		&corev1.Secret{ObjectMeta: unusedMeta("env", month)},
+		&corev1.Secret{ObjectMeta: unusedMeta("orphan", month)},
+		&corev1.Secret{ObjectMeta: unusedMeta("default-token-abcde", month), Type: corev1.SecretTypeServiceAccountToken},
+		&corev1.ConfigMap{ObjectMeta: unusedMeta("projected", month)},
+		&corev1.ConfigMap{ObjectMeta: unusedMeta("scaled-down", month)},
+		&corev1.ConfigMap{ObjectMeta: unusedMeta("leftover", month)},
+		&corev1.Pod{
+			ObjectMeta: unusedMeta("web-0", time.Hour),
+			Spec: corev1.PodSpec{
+				ImagePullSecrets: []corev1.LocalObjectReference{{Name: "pull"}},
+				Volumes: []corev1.Volume{
+					{Name: "tls", VolumeSource: corev1.VolumeSource{Secret: &corev1.SecretVolumeSource{SecretName: "mounted"}}},
+					{Name: "config", VolumeSource: corev1.VolumeSource{Projected: &corev1.ProjectedVolumeSource{
+						Sources: []corev1.VolumeProjection{{ConfigMap: &corev1.ConfigMapProjection{LocalObjectReference: corev1.LocalObjectReference{Name: "projected"}}}},
+					}}},
+				},
+				InitContainers: []corev1.Container{{
+					Name: "migrate",
+					Env: []corev1.EnvVar{{Name: "PASSWORD", ValueFrom: &corev1.EnvVarSource{
+						SecretKeyRef: &corev1.SecretKeySelector{LocalObjectReference: corev1.LocalObjectReference{Name: "env"}, Key: "password"},
+					}}},
+				}},
+			},
+		},
+		&appsv1.Deployment{
+			ObjectMeta: unusedMeta("batch", month),
+			Spec: appsv1.DeploymentSpec{Template: corev1.PodTemplateSpec{Spec: corev1.PodSpec{
+				Containers: []corev1.Container{{
+					Name:    "batch",
+					EnvFrom: []corev1.EnvFromSource{{ConfigMapRef: &corev1.ConfigMapEnvSource{LocalObjectReference: corev1.LocalObjectReference{Name: "scaled-down"}}}},
+				}},
+			}}},
+		},
+	}
+}
+
+func Test_checkUnused(t *testing.T) {
+	_, conf := StubsInit()
+
+	tests := []struct {
+		name     string
+		status   UnusedAlertStatus
+		expected []string
+	}{
+		{name: "nothing enabled"},
+		{
+			name:   "persistent volumes",
+			status: UnusedAlertStatus{PersistentVolumes: true},
+			expected: []string{
+				"PersistentVolume pv-released is Released and has not been bound to a claim for 30 days!",
+			},
+		},
+		{
+			name:   "secrets and config maps",
+			status: UnusedAlertStatus{Secrets: true, ConfigMaps: true},
+			expected: []string{
+				"ConfigMap default/leftover is not referenced by any pod or deployment in its namespace and has been unused for 30 days!",
+				"Secret default/orphan is not referenced by any pod or deployment in its namespace and has been unused for 30 days!",
+			},
+		},
+		{
+			name:   "short threshold",
+			status: UnusedAlertStatus{PersistentVolumes: true, UnusedThreshold: 60},
+			expected: []string{
+				"PersistentVolume pv-new is Available and has not been bound to a claim for 1h0m0s!",
+				"PersistentVolume pv-released is Released and has not been bound to a claim for 30 days!",
+			},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(subT *testing.T) {
+			stateStore = state.NewStore()
+			var alerts []string
+			alertStub := func(_ string, _ string, alert Alert, _ AlertersConfig) {
+				assert.Equal(subT, SeverityInfo, alert.Severity)
+				alerts = append(alerts, alert.Message)
+			}
+			alertSpec := UnusedAlertSpec{ReportStatus: test.status}
+			assert.NoError(subT, checkUnused(fake.NewSimpleClientset(unusedObjects()...), alertSpec, unusedTime, alertStub, conf))
+			assert.Equal(subT, test.expected, alerts)
+		})
+	}
+}
+
+func Test_checkUnused_UnusedSince(t *testing.T) {
+	_, conf := StubsInit()
+	stateStore = state.NewStore()
+	var alerts []string
+	alertStub := func(_ string, _ string, alert Alert, _ AlertersConfig) {
+		alerts = append(alerts, alert.Key)
+	}
+	alertSpec := UnusedAlertSpec{
+		UnusedFilterNamespace: metav1.NamespaceDefault,
+		ReportStatus:          UnusedAlertStatus{Secrets: true, UnusedThreshold: 3600},
+	}
+	client := fake.NewSimpleClientset(unusedObjects()...)
+	assert.NoError(t, checkUnused(client, alertSpec, unusedTime, alertStub, conf))
+	assert.Equal(t, []string{"secret/default/orphan/Unused"}, alerts)
+
+	// the pod goes away, so its secrets only start counting as unused now
+	assert.NoError(t, client.CoreV1().Pods(metav1.NamespaceDefault).Delete("web-0", &metav1.DeleteOptions{}))
+	alerts = nil
+	assert.NoError(t, checkUnused(client, alertSpec, unusedTime.Add(time.Minute), alertStub, conf))
+	assert.Equal(t, []string{"secret/default/orphan/Unused"}, alerts)
+
+	alerts = nil
+	assert.NoError(t, checkUnused(client, alertSpec, unusedTime.Add(2*time.Hour), alertStub, conf))
+	assert.Equal(t, []string{"secret/default/env/Unused", "secret/default/mounted/Unused", "secret/default/orphan/Unused", "secret/default/pull/Unused"}, alerts)
+}
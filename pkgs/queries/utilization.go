@@ -0,0 +1,225 @@
+// Copyright 2019 Bloomberg Finance LP
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package queries
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/bloomberg/k8eraid/pkgs/types"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	"k8s.io/client-go/kubernetes"
+)
+
+// nodeMetrics is the subset of a metrics.k8s.io NodeMetrics read by k8eraid
+type nodeMetrics struct {
+	Usage corev1.ResourceList `json:"usage"`
+}
+
+// nodeMetricsUsage fetches the CPU and memory a node uses from the resource metrics API, as
+// metrics-server serves it. It is a variable so tests can stub metrics-server out.
+var nodeMetricsUsage = func(clientset kubernetes.Interface, nodeName string) (corev1.ResourceList, error) {
+	data, err := clientset.CoreV1().RESTClient().Get().
+		AbsPath("/apis/metrics.k8s.io/v1beta1/nodes", nodeName).
+		DoRaw()
+	if err != nil {
+		return nil, err
+	}
+	var metrics nodeMetrics
+	if err := json.Unmarshal(data, &metrics); err != nil {
+		return nil, fmt.Errorf("unable to parse metrics of node %s: %s", nodeName, err.Error())
+	}
+	return metrics.Usage, nil
+}
+
+// podMetricsList is the subset of a metrics.k8s.io PodMetricsList read by k8eraid
+type podMetricsList struct {
+	Items []struct {
+		Metadata struct {
+			Name      string `json:"name"`
+			Namespace string `json:"namespace"`
+		} `json:"metadata"`
+		Containers []struct {
+			Usage corev1.ResourceList `json:"usage"`
+		} `json:"containers"`
+	} `json:"items"`
+}
+
+// podMetricsUsage lists what the pods of namespace, of every namespace when it is empty, matching
+// labelSelector use across their containers from the resource metrics API, by namespace/name. It is a
+// variable so tests can stub metrics-server out.
+var podMetricsUsage = func(clientset kubernetes.Interface, namespace string, labelSelector string) (map[string]corev1.ResourceList, error) {
+	request := clientset.CoreV1().RESTClient().Get().AbsPath("/apis/metrics.k8s.io/v1beta1/pods")
+	if namespace != "" {
+		request = clientset.CoreV1().RESTClient().Get().AbsPath("/apis/metrics.k8s.io/v1beta1/namespaces", namespace, "pods")
+	}
+	if labelSelector != "" {
+		request = request.Param("labelSelector", labelSelector)
+	}
+	data, err := request.DoRaw()
+	if err != nil {
+		return nil, err
+	}
+	var metrics podMetricsList
+	if err := json.Unmarshal(data, &metrics); err != nil {
+		return nil, fmt.Errorf("unable to parse pod metrics: %s", err.Error())
+	}
+	usage := map[string]corev1.ResourceList{}
+	for _, item := range metrics.Items {
+		total := corev1.ResourceList{}
+		for _, container := range item.Containers {
+			for name, quantity := range container.Usage {
+				sum := total[name]
+				sum.Add(quantity)
+				total[name] = sum
+			}
+		}
+		usage[item.Metadata.Namespace+"/"+item.Metadata.Name] = total
+	}
+	return usage, nil
+}
+
+// podUsageChecked tells whether a rule compares the usage of its pods with anything
+func podUsageChecked(usage types.PodUsage) bool {
+	return usage.CPU != "" || usage.Memory != "" || usage.LimitPercent > 0
+}
+
+// checkPodUsage alerts on a pod that has used more CPU or memory than the rule allows, or too large
+// a share of its limits, for longer than the threshold. Pods metrics-server has no metrics for, such
+// as those not running, are skipped.
+func checkPodUsage(
+	pod *corev1.Pod,
+	usage map[string]corev1.ResourceList,
+	alertSpec types.PodAlertSpec,
+	now time.Time,
+	alertFn alertFunction,
+	alertersConfig types.AlertersConfig,
+) {
+	rule := alertSpec.ReportStatus.Usage
+	podUsage, measured := usage[pod.Namespace+"/"+pod.Name]
+	if !podUsageChecked(rule) || !measured {
+		return
+	}
+	resourceName := resourceID("pod", pod.Namespace, pod.Name)
+	allowed := map[corev1.ResourceName]string{corev1.ResourceCPU: rule.CPU, corev1.ResourceMemory: rule.Memory}
+	for _, name := range []corev1.ResourceName{corev1.ResourceCPU, corev1.ResourceMemory} {
+		used, ok := podUsage[name]
+		if !ok {
+			continue
+		}
+		var over []string
+		if limit, err := resource.ParseQuantity(allowed[name]); allowed[name] != "" && err == nil && used.Cmp(limit) > 0 {
+			over = append(over, fmt.Sprintf("above the %s the rule allows", formatResource(string(name), limit)))
+		}
+		if limit, limited := podLimit(pod, name); rule.LimitPercent > 0 && limited && !limit.IsZero() {
+			if percent := float64(used.MilliValue()) / float64(limit.MilliValue()) * 100; percent >= rule.LimitPercent {
+				over = append(over, fmt.Sprintf("%.0f%% of its %s limit", percent, formatResource(string(name), limit)))
+			}
+		}
+		check := "CPUUsage"
+		if name == corev1.ResourceMemory {
+			check = "MemoryUsage"
+		}
+		overFor := unhealthyFor("usage/"+resourceName+"/"+string(name), len(over) > 0, pod.CreationTimestamp.Time, now)
+		if len(over) == 0 || overFor < time.Duration(rule.ThresholdSeconds)*time.Second {
+			continue
+		}
+		// ALERT
+		alertmessage := fmt.Sprintf(
+			"Pod %s/%s has been using %s of %s for %s, %s!",
+			pod.Namespace, pod.Name, formatResource(string(name), used), name, formatLongDuration(overFor), strings.Join(over, " and "),
+		)
+		alertFn(alertSpec.AlerterType, alertSpec.AlerterName, newAlert(resourceName, check, types.SeverityWarning, alertmessage), alertersConfig)
+	}
+}
+
+// podLimit sums the limits the containers of pod set for name, and tells whether they all set one
+func podLimit(pod *corev1.Pod, name corev1.ResourceName) (resource.Quantity, bool) {
+	var total resource.Quantity
+	for _, container := range pod.Spec.Containers {
+		limit, ok := container.Resources.Limits[name]
+		if !ok {
+			return total, false
+		}
+		total.Add(limit)
+	}
+	return total, len(pod.Spec.Containers) > 0
+}
+
+// formatResource renders a quantity of CPU in cores and one of memory using binary units
+func formatResource(name string, quantity resource.Quantity) string {
+	if name == string(corev1.ResourceCPU) {
+		return fmt.Sprintf("%.2f cores", float64(quantity.MilliValue())/1000)
+	}
+	return formatBytes(uint64(quantity.Value()))
+}
+
+// checkNodeUtilization compares what a Ready node uses of its allocatable CPU and memory with the
+// warning and critical percentages of the rule, to warn well before the kubelet reports pressure.
+// Nodes metrics-server has no metrics for yet are skipped.
+func checkNodeUtilization(
+	clientset kubernetes.Interface,
+	node *corev1.Node,
+	alertSpec types.NodeAlertSpec,
+	alertFn alertFunction,
+	alertersConfig types.AlertersConfig,
+) {
+	utilization := alertSpec.ReportStatus.Utilization
+	if (utilization.WarningPercent <= 0 && utilization.CriticalPercent <= 0) || !nodeReady(node) {
+		return
+	}
+	usage, err := nodeMetricsUsage(clientset, node.Name)
+	if err != nil {
+		errLogger.Printf("Metrics unavailable for node %s, skipping its utilization check: %s", node.Name, err.Error())
+		return
+	}
+
+	resources := utilization.Resources
+	if len(resources) == 0 {
+		resources = []string{string(corev1.ResourceCPU), string(corev1.ResourceMemory)}
+	}
+	resource := resourceID("node", "", node.Name)
+	for _, name := range resources {
+		used, measured := usage[corev1.ResourceName(name)]
+		allocatable, known := node.Status.Allocatable[corev1.ResourceName(name)]
+		if !measured || !known || allocatable.IsZero() {
+			continue
+		}
+		percent := float64(used.MilliValue()) / float64(allocatable.MilliValue()) * 100
+		severity, threshold := types.SeverityCritical, utilization.CriticalPercent
+		if threshold <= 0 || percent < threshold {
+			severity, threshold = types.SeverityWarning, utilization.WarningPercent
+		}
+		if threshold <= 0 || percent < threshold {
+			continue
+		}
+		amount := fmt.Sprintf("%.2f of %.2f cores", float64(used.MilliValue())/1000, float64(allocatable.MilliValue())/1000)
+		check := "CPUUtilization"
+		if name == string(corev1.ResourceMemory) {
+			amount = fmt.Sprintf("%s of %s", formatBytes(uint64(used.Value())), formatBytes(uint64(allocatable.Value())))
+			check = "MemoryUtilization"
+		}
+		// ALERT
+		alertmessage := fmt.Sprintf(
+			"Node %s is using %.0f%% of its allocatable %s (%s), above the %.0f%% %s threshold!",
+			node.Name, percent, name, amount, threshold, severity,
+		)
+		alertFn(alertSpec.AlerterType, alertSpec.AlerterName, newAlert(resource, check, severity, alertmessage), alertersConfig)
+	}
+}
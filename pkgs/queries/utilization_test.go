@@ -0,0 +1,158 @@
+// Copyright 2019 Bloomberg Finance LP
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package queries
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/bloomberg/k8eraid/pkgs/state"
+	. "github.com/bloomberg/k8eraid/pkgs/types"
+
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func Test_checkNodeUtilization(t *testing.T) {
+	_, conf := StubsInit()
+	original := nodeMetricsUsage
+	defer func() { nodeMetricsUsage = original }()
+	node := readyNode("worker-1", corev1.ConditionTrue)
+	node.Status.Allocatable = corev1.ResourceList{
+		corev1.ResourceCPU:    resource.MustParse("4"),
+		corev1.ResourceMemory: resource.MustParse("16Gi"),
+	}
+	usage := corev1.ResourceList{
+		corev1.ResourceCPU:    resource.MustParse("3400m"),
+		corev1.ResourceMemory: resource.MustParse("15Gi"),
+	}
+	var usageErr error
+	nodeMetricsUsage = func(_ kubernetes.Interface, _ string) (corev1.ResourceList, error) {
+		return usage, usageErr
+	}
+	check := func(utilization NodeUtilization) []string {
+		alerts := []string{}
+		alertSpec := NodeAlertSpec{Name: "worker-1", ReportStatus: NodeAlertStatus{Utilization: utilization}}
+		checkNodeUtilization(fake.NewSimpleClientset(), node, alertSpec, func(_ string, _ string, alert Alert, _ AlertersConfig) {
+			alerts = append(alerts, alert.Key+" ["+alert.Severity+"]: "+alert.Message)
+		}, conf)
+		return alerts
+	}
+
+	assert.Equal(t, []string{
+		"node/worker-1/CPUUtilization [warning]: Node worker-1 is using 85% of its allocatable cpu (3.40 of 4.00 cores), above the 80% warning threshold!",
+		"node/worker-1/MemoryUtilization [critical]: Node worker-1 is using 94% of its allocatable memory (15.0GiB of 16.0GiB), above the 90% critical threshold!",
+	}, check(NodeUtilization{WarningPercent: 80, CriticalPercent: 90}))
+	assert.Equal(t, []string{
+		"node/worker-1/MemoryUtilization [critical]: Node worker-1 is using 94% of its allocatable memory (15.0GiB of 16.0GiB), above the 90% critical threshold!",
+	}, check(NodeUtilization{CriticalPercent: 90}), "a rule may only have a critical tier")
+	assert.Equal(t, []string{
+		"node/worker-1/CPUUtilization [warning]: Node worker-1 is using 85% of its allocatable cpu (3.40 of 4.00 cores), above the 80% warning threshold!",
+	}, check(NodeUtilization{WarningPercent: 80, Resources: []string{"cpu"}}))
+	assert.Empty(t, check(NodeUtilization{}), "the check is disabled")
+
+	usageErr = errors.New("the server could not find the requested resource (get nodes.metrics.k8s.io worker-1)")
+	assert.Empty(t, check(NodeUtilization{WarningPercent: 80}), "nodes without metrics are skipped")
+	usageErr = nil
+	node.Status.Conditions[0].Status = corev1.ConditionFalse
+	assert.Empty(t, check(NodeUtilization{WarningPercent: 80}), "nodes that are not Ready are skipped")
+}
+
+func Test_checkPodUsage(t *testing.T) {
+	stateStore = state.NewStore()
+	_, conf := StubsInit()
+	start := time.Unix(100000, 0)
+	pod := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: "web-1", Namespace: "shop", CreationTimestamp: metav1.Time{Time: start.Add(-time.Hour)}},
+		Spec: corev1.PodSpec{Containers: []corev1.Container{
+			{Name: "app", Resources: corev1.ResourceRequirements{Limits: corev1.ResourceList{corev1.ResourceCPU: resource.MustParse("1500m"), corev1.ResourceMemory: resource.MustParse("1Gi")}}},
+			{Name: "proxy", Resources: corev1.ResourceRequirements{Limits: corev1.ResourceList{corev1.ResourceCPU: resource.MustParse("500m")}}},
+		}},
+	}
+	usage := map[string]corev1.ResourceList{
+		"shop/web-1": {corev1.ResourceCPU: resource.MustParse("1850m"), corev1.ResourceMemory: resource.MustParse("950Mi")},
+	}
+	alertSpec := PodAlertSpec{ReportStatus: PodAlertStatus{Usage: PodUsage{Memory: "512Mi", LimitPercent: 90, ThresholdSeconds: 300}}}
+	check := func(after time.Duration) []string {
+		alerts := []string{}
+		checkPodUsage(pod, usage, alertSpec, start.Add(after), func(_ string, _ string, alert Alert, _ AlertersConfig) {
+			alerts = append(alerts, alert.Key+": "+alert.Message)
+		}, conf)
+		return alerts
+	}
+
+	assert.Empty(t, check(0), "usage must stay above for the threshold")
+	assert.Equal(t, []string{
+		"pod/shop/web-1/CPUUsage: Pod shop/web-1 has been using 1.85 cores of cpu for 10m0s, 92% of its 2.00 cores limit!",
+		"pod/shop/web-1/MemoryUsage: Pod shop/web-1 has been using 950.0MiB of memory for 10m0s, above the 512.0MiB the rule allows!",
+	}, check(10*time.Minute), "memory is not compared to limits some container does not set")
+
+	pod.Spec.Containers[1].Resources.Limits[corev1.ResourceMemory] = resource.MustParse("0")
+	assert.Equal(t, []string{
+		"pod/shop/web-1/CPUUsage: Pod shop/web-1 has been using 1.85 cores of cpu for 20m0s, 92% of its 2.00 cores limit!",
+		"pod/shop/web-1/MemoryUsage: Pod shop/web-1 has been using 950.0MiB of memory for 20m0s, above the 512.0MiB the rule allows and 93% of its 1.0GiB limit!",
+	}, check(20*time.Minute))
+
+	usage["shop/web-1"] = corev1.ResourceList{corev1.ResourceCPU: resource.MustParse("100m"), corev1.ResourceMemory: resource.MustParse("950Mi")}
+	assert.Equal(t, []string{
+		"pod/shop/web-1/MemoryUsage: Pod shop/web-1 has been using 950.0MiB of memory for 30m0s, above the 512.0MiB the rule allows and 93% of its 1.0GiB limit!",
+	}, check(30*time.Minute))
+	usage["shop/web-1"][corev1.ResourceCPU] = resource.MustParse("1900m")
+	assert.Equal(t, 1, len(check(34*time.Minute)), "a pod back under its limits starts counting again")
+
+	delete(usage, "shop/web-1")
+	assert.Empty(t, check(time.Hour), "pods without metrics are skipped")
+}
+
+func Test_PollPod_Usage(t *testing.T) {
+	stateStore = state.NewStore()
+	_, conf := StubsInit()
+	original := podMetricsUsage
+	defer func() { podMetricsUsage = original }()
+	pod := &corev1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "web-1", Namespace: "shop", Labels: map[string]string{"app": "web"}}}
+	client := fake.NewSimpleClientset(pod)
+	var listed []string
+	podMetricsUsage = func(_ kubernetes.Interface, namespace string, labelSelector string) (map[string]corev1.ResourceList, error) {
+		listed = append(listed, namespace+" "+labelSelector)
+		return map[string]corev1.ResourceList{"shop/web-1": {corev1.ResourceCPU: resource.MustParse("2")}}, nil
+	}
+
+	for _, spec := range []PodAlertSpec{
+		{Name: "web-1", PodFilterNamespace: "shop", ReportStatus: PodAlertStatus{Usage: PodUsage{CPU: "1"}}},
+		{Name: "*", PodFilterNamespace: "shop", PodFilterLabel: "app=web", ReportStatus: PodAlertStatus{Usage: PodUsage{CPU: "1"}}},
+		{Name: "*", PodFilterLabel: "app=web"},
+	} {
+		keys := []string{}
+		assert.NoError(t, PollPod(client, spec, defaultTickerTime, func(_ string, _ string, alert Alert, _ AlertersConfig) {
+			keys = append(keys, alert.Key)
+		}, conf))
+		if podUsageChecked(spec.ReportStatus.Usage) {
+			assert.Equal(t, []string{"pod/shop/web-1/CPUUsage"}, keys, spec.Name)
+		}
+	}
+	assert.Equal(t, []string{"shop ", "shop app=web"}, listed, "pod metrics are listed once per poll, and only for rules checking them")
+
+	podMetricsUsage = func(_ kubernetes.Interface, _ string, _ string) (map[string]corev1.ResourceList, error) {
+		return nil, errors.New("the server could not find the requested resource")
+	}
+	assert.NoError(t, PollPod(client, PodAlertSpec{Name: "web-1", PodFilterNamespace: "shop", ReportStatus: PodAlertStatus{Usage: PodUsage{CPU: "1"}}}, defaultTickerTime, func(_ string, _ string, alert Alert, _ AlertersConfig) {
+		t.Errorf("unexpected alert %s", alert.Key)
+	}, conf), "pod rules keep working without metrics-server")
+}
@@ -0,0 +1,221 @@
+// Copyright 2019 Bloomberg Finance LP
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package queries
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/bloomberg/k8eraid/pkgs/types"
+
+	admissionv1beta1 "k8s.io/api/admissionregistration/v1beta1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// webhookServicePort is the port of its Service the apiserver calls a webhook on
+const webhookServicePort = 443
+
+// admissionWebhook is one webhook of a Validating or MutatingWebhookConfiguration
+type admissionWebhook struct {
+	kind          string
+	configuration string
+	webhook       admissionv1beta1.Webhook
+}
+
+// PollWebhookConfig function takes inputs and iterates across the admission webhook configurations in the kubernetes
+// cluster, triggering alerts as needed.
+func PollWebhookConfig(
+	clientset kubernetes.Interface,
+	alertSpec types.WebhookAlertSpec,
+	tickertime int64,
+	alertFn alertFunction,
+	alertersConfig types.AlertersConfig,
+) error {
+
+	var webhooks []admissionWebhook
+	validating := clientset.AdmissionregistrationV1beta1().ValidatingWebhookConfigurations()
+	mutating := clientset.AdmissionregistrationV1beta1().MutatingWebhookConfigurations()
+
+	// Check rules with matching literal configuration name, which may be either kind
+	if alertSpec.Name != "*" {
+		validatingConfig, validatingerr := validating.Get(alertSpec.Name, metav1.GetOptions{})
+		if validatingerr != nil && !apierrors.IsNotFound(validatingerr) {
+			return &PollErr{
+				Message: fmt.Sprintf("Error fetching ValidatingWebhookConfiguration %s: %s", alertSpec.Name, validatingerr.Error()),
+			}
+		}
+		mutatingConfig, mutatingerr := mutating.Get(alertSpec.Name, metav1.GetOptions{})
+		if mutatingerr != nil && !apierrors.IsNotFound(mutatingerr) {
+			return &PollErr{
+				Message: fmt.Sprintf("Error fetching MutatingWebhookConfiguration %s: %s", alertSpec.Name, mutatingerr.Error()),
+			}
+		}
+		if validatingerr != nil && mutatingerr != nil {
+			return &PollErr{
+				Message: fmt.Sprintf("Error fetching webhook configuration %s: no Validating or MutatingWebhookConfiguration has that name", alertSpec.Name),
+			}
+		}
+		if validatingerr == nil {
+			webhooks = append(webhooks, admissionWebhooks("ValidatingWebhookConfiguration", validatingConfig.Name, validatingConfig.Webhooks)...)
+		}
+		if mutatingerr == nil {
+			webhooks = append(webhooks, admissionWebhooks("MutatingWebhookConfiguration", mutatingConfig.Name, mutatingConfig.Webhooks)...)
+		}
+		// If the configuration name is a wildcard, list both kinds based on filter and iterate through
+	} else {
+		listopts := metav1.ListOptions{
+			LabelSelector:  alertSpec.WebhookFilter,
+			TimeoutSeconds: &timeout,
+		}
+		validatingConfigs, validatingerr := validating.List(listopts)
+		if validatingerr != nil {
+			return &PollErr{
+				Message: fmt.Sprintf("Unable to list ValidatingWebhookConfigurations: %s", validatingerr.Error()),
+			}
+		}
+		for _, config := range validatingConfigs.Items {
+			webhooks = append(webhooks, admissionWebhooks("ValidatingWebhookConfiguration", config.Name, config.Webhooks)...)
+		}
+		mutatingConfigs, mutatingerr := mutating.List(listopts)
+		if mutatingerr != nil {
+			return &PollErr{
+				Message: fmt.Sprintf("Unable to list MutatingWebhookConfigurations: %s", mutatingerr.Error()),
+			}
+		}
+		for _, config := range mutatingConfigs.Items {
+			webhooks = append(webhooks, admissionWebhooks("MutatingWebhookConfiguration", config.Name, config.Webhooks)...)
+		}
+	}
+
+	if alertSpec.ReportStatus.UnreachableService {
+		return checkWebhookServices(clientset, webhooks, alertSpec, alertFn, alertersConfig)
+	}
+	return nil
+}
+
+func admissionWebhooks(kind string, configuration string, webhooks []admissionv1beta1.Webhook) []admissionWebhook {
+	var admission []admissionWebhook
+	for _, webhook := range webhooks {
+		admission = append(admission, admissionWebhook{kind: kind, configuration: configuration, webhook: webhook})
+	}
+	return admission
+}
+
+// checkWebhookServices alerts on webhooks with failurePolicy Fail whose Service does not exist, has
+// no ready endpoints or does not serve the port the apiserver calls, since the apiserver then rejects
+// every request the webhook intercepts. Webhooks called by URL, and the ones failing open, are skipped.
+func checkWebhookServices(
+	clientset kubernetes.Interface,
+	webhooks []admissionWebhook,
+	alertSpec types.WebhookAlertSpec,
+	alertFn alertFunction,
+	alertersConfig types.AlertersConfig,
+) error {
+	problems := map[string]string{}
+	for _, admission := range webhooks {
+		service := admission.webhook.ClientConfig.Service
+		// failurePolicy defaults to Ignore in admissionregistration.k8s.io/v1beta1
+		policy := admission.webhook.FailurePolicy
+		if service == nil || policy == nil || *policy != admissionv1beta1.Fail {
+			continue
+		}
+
+		serviceName := service.Namespace + "/" + service.Name
+		problem, checked := problems[serviceName]
+		if !checked {
+			var err error
+			if problem, err = webhookServiceProblem(clientset, service.Namespace, service.Name); err != nil {
+				return err
+			}
+			problems[serviceName] = problem
+		}
+		if problem == "" {
+			continue
+		}
+
+		// ALERT
+		alertmessage := fmt.Sprintf(
+			"Webhook %s of %s %s has failurePolicy Fail, but its Service %s %s, so the apiserver rejects every request it intercepts!",
+			admission.webhook.Name,
+			admission.kind,
+			admission.configuration,
+			serviceName,
+			problem,
+		)
+		resource := resourceID(strings.ToLower(admission.kind), admission.configuration, admission.webhook.Name)
+		alert := newAlert(resource, "UnreachableService", types.SeverityCritical, alertmessage)
+		alertFn(alertSpec.AlerterType, alertSpec.AlerterName, alert, alertersConfig)
+	}
+	return nil
+}
+
+// webhookServiceProblem describes why the Service namespace/name cannot take webhook calls, or returns
+// "" when it can. admissionregistration.k8s.io/v1beta1 has no port in its service references, the
+// apiserver always calls port 443, so ready endpoints behind a Service without it are no use.
+func webhookServiceProblem(clientset kubernetes.Interface, namespace string, name string) (string, error) {
+	problem, err := serviceProblem(clientset, namespace, name)
+	if problem != "" || err != nil {
+		return problem, err
+	}
+	service, serviceerr := clientset.CoreV1().Services(namespace).Get(name, metav1.GetOptions{})
+	if serviceerr != nil {
+		return "", &PollErr{
+			Message: fmt.Sprintf("Error fetching Service %s/%s: %s", namespace, name, serviceerr.Error()),
+		}
+	}
+	if service.Spec.Type == corev1.ServiceTypeExternalName {
+		return "", nil
+	}
+	for _, port := range service.Spec.Ports {
+		if port.Port == webhookServicePort {
+			return "", nil
+		}
+	}
+	return fmt.Sprintf("does not expose port %d the apiserver calls", webhookServicePort), nil
+}
+
+// serviceProblem describes why the Service namespace/name cannot take calls, or returns "" when it
+// has a ready endpoint
+func serviceProblem(clientset kubernetes.Interface, namespace string, name string) (string, error) {
+	service, serviceerr := clientset.CoreV1().Services(namespace).Get(name, metav1.GetOptions{})
+	if apierrors.IsNotFound(serviceerr) {
+		return "does not exist", nil
+	} else if serviceerr != nil {
+		return "", &PollErr{
+			Message: fmt.Sprintf("Error fetching Service %s/%s: %s", namespace, name, serviceerr.Error()),
+		}
+	}
+	// the apiserver resolves ExternalName services through DNS, there are no endpoints to check
+	if service.Spec.Type == corev1.ServiceTypeExternalName {
+		return "", nil
+	}
+	endpoints, endpointserr := clientset.CoreV1().Endpoints(namespace).Get(name, metav1.GetOptions{})
+	if apierrors.IsNotFound(endpointserr) {
+		return "has no ready endpoints", nil
+	} else if endpointserr != nil {
+		return "", &PollErr{
+			Message: fmt.Sprintf("Error fetching Endpoints %s/%s: %s", namespace, name, endpointserr.Error()),
+		}
+	}
+	for _, subset := range endpoints.Subsets {
+		if len(subset.Addresses) > 0 {
+			return "", nil
+		}
+	}
+	return "has no ready endpoints", nil
+}
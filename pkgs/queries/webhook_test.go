@@ -0,0 +1,152 @@
+// Copyright 2019 Bloomberg Finance LP
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package queries
+
+import (
+	"testing"
+
+	. "github.com/bloomberg/k8eraid/pkgs/types"
+
+	"github.com/stretchr/testify/assert"
+	admissionv1beta1 "k8s.io/api/admissionregistration/v1beta1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+// serviceWebhook builds a webhook calling the Service webhooks/<service> with the given failurePolicy,
+// or none when policy is empty
+func serviceWebhook(name string, service string, policy admissionv1beta1.FailurePolicyType) admissionv1beta1.Webhook {
+	webhook := admissionv1beta1.Webhook{
+		Name: name,
+		ClientConfig: admissionv1beta1.WebhookClientConfig{
+			Service: &admissionv1beta1.ServiceReference{Namespace: "webhooks", Name: service},
+		},
+	}
+	if policy != "" {
+		webhook.FailurePolicy = &policy
+	}
+	return webhook
+}
+
+// webhookServices builds Services in the webhooks namespace, with one ready address for the ready ones
+func webhookServices(ready map[string]bool) []runtime.Object {
+	var objects []runtime.Object
+	for name, isReady := range ready {
+		meta := metav1.ObjectMeta{Name: name, Namespace: "webhooks"}
+		endpoints := &corev1.Endpoints{ObjectMeta: meta, Subsets: []corev1.EndpointSubset{{
+			NotReadyAddresses: []corev1.EndpointAddress{{IP: "10.0.0.2"}},
+		}}}
+		if isReady {
+			endpoints.Subsets[0].Addresses = []corev1.EndpointAddress{{IP: "10.0.0.1"}}
+		}
+		service := &corev1.Service{ObjectMeta: meta, Spec: corev1.ServiceSpec{Ports: []corev1.ServicePort{{Port: 443}}}}
+		objects = append(objects, service, endpoints)
+	}
+	return objects
+}
+
+func Test_PollWebhookConfig_UnreachableService(t *testing.T) {
+	_, conf := StubsInit()
+	url := "https://policy.example.com/validate"
+	fail := admissionv1beta1.Fail
+	urlWebhook := admissionv1beta1.Webhook{
+		Name:          "url.example.com",
+		ClientConfig:  admissionv1beta1.WebhookClientConfig{URL: &url},
+		FailurePolicy: &fail,
+	}
+	wrongPort := webhookServices(map[string]bool{"plaintext": true})
+	wrongPort[0].(*corev1.Service).Spec.Ports[0].Port = 80
+	objects := append(webhookServices(map[string]bool{"healthy": true, "down": false}), wrongPort...)
+	objects = append(objects,
+		&corev1.Service{
+			ObjectMeta: metav1.ObjectMeta{Name: "external", Namespace: "webhooks"},
+			Spec:       corev1.ServiceSpec{Type: corev1.ServiceTypeExternalName, ExternalName: "policy.example.com"},
+		},
+		&admissionv1beta1.ValidatingWebhookConfiguration{
+			ObjectMeta: metav1.ObjectMeta{Name: "policy", Labels: map[string]string{"team": "platform"}},
+			Webhooks: []admissionv1beta1.Webhook{
+				serviceWebhook("healthy.example.com", "healthy", admissionv1beta1.Fail),
+				serviceWebhook("down.example.com", "down", admissionv1beta1.Fail),
+				serviceWebhook("ignored.example.com", "down", admissionv1beta1.Ignore),
+				serviceWebhook("default.example.com", "down", ""),
+				urlWebhook,
+			},
+		},
+		&admissionv1beta1.MutatingWebhookConfiguration{
+			ObjectMeta: metav1.ObjectMeta{Name: "inject"},
+			Webhooks: []admissionv1beta1.Webhook{
+				serviceWebhook("gone.example.com", "gone", admissionv1beta1.Fail),
+				serviceWebhook("external.example.com", "external", admissionv1beta1.Fail),
+				serviceWebhook("plaintext.example.com", "plaintext", admissionv1beta1.Fail),
+			},
+		},
+	)
+
+	down := "validatingwebhookconfiguration/policy/down.example.com/UnreachableService: " +
+		"Webhook down.example.com of ValidatingWebhookConfiguration policy has failurePolicy Fail, but its Service webhooks/down has no ready endpoints, so the apiserver rejects every request it intercepts!"
+	gone := "mutatingwebhookconfiguration/inject/gone.example.com/UnreachableService: " +
+		"Webhook gone.example.com of MutatingWebhookConfiguration inject has failurePolicy Fail, but its Service webhooks/gone does not exist, so the apiserver rejects every request it intercepts!"
+	plaintext := "mutatingwebhookconfiguration/inject/plaintext.example.com/UnreachableService: " +
+		"Webhook plaintext.example.com of MutatingWebhookConfiguration inject has failurePolicy Fail, but its Service webhooks/plaintext does not expose port 443 the apiserver calls, so the apiserver rejects every request it intercepts!"
+
+	tests := []struct {
+		name        string
+		alertSpec   WebhookAlertSpec
+		expected    []string
+		expectedErr bool
+	}{
+		{
+			name:      "every configuration",
+			alertSpec: WebhookAlertSpec{Name: "*", ReportStatus: WebhookAlertStatus{UnreachableService: true}},
+			expected:  []string{down, gone, plaintext},
+		},
+		{
+			name:      "configurations matching the filter",
+			alertSpec: WebhookAlertSpec{Name: "*", WebhookFilter: "team=platform", ReportStatus: WebhookAlertStatus{UnreachableService: true}},
+			expected:  []string{down},
+		},
+		{
+			name:      "configuration by name",
+			alertSpec: WebhookAlertSpec{Name: "inject", ReportStatus: WebhookAlertStatus{UnreachableService: true}},
+			expected:  []string{gone, plaintext},
+		},
+		{
+			name:        "missing configuration",
+			alertSpec:   WebhookAlertSpec{Name: "missing", ReportStatus: WebhookAlertStatus{UnreachableService: true}},
+			expectedErr: true,
+		},
+		{
+			name:      "check disabled",
+			alertSpec: WebhookAlertSpec{Name: "*"},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(subT *testing.T) {
+			var alerts []string
+			alertStub := func(_ string, _ string, alert Alert, _ AlertersConfig) {
+				alerts = append(alerts, alert.Key+": "+alert.Message)
+			}
+			err := PollWebhookConfig(fake.NewSimpleClientset(objects...), test.alertSpec, defaultTickerTime, alertStub, conf)
+			if test.expectedErr {
+				assert.Error(subT, err)
+				return
+			}
+			assert.NoError(subT, err)
+			assert.Equal(subT, test.expected, alerts)
+		})
+	}
+}
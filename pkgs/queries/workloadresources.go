@@ -0,0 +1,110 @@
+// Copyright 2019 Bloomberg Finance LP
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package queries
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/bloomberg/k8eraid/pkgs/types"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// PollWorkloadResources function takes inputs and iterates across Deployments and StatefulSets in the kubernetes cluster, alerting
+// on the pod templates whose containers set no CPU or memory request or limit.
+func PollWorkloadResources(
+	clientset kubernetes.Interface,
+	alertSpec types.WorkloadResourceAlertSpec,
+	tickertime int64,
+	alertFn alertFunction,
+	alertersConfig types.AlertersConfig,
+) error {
+	if !alertSpec.ReportStatus.Requests && !alertSpec.ReportStatus.Limits {
+		return nil
+	}
+	listopts := metav1.ListOptions{
+		LabelSelector:  alertSpec.WorkloadFilterLabel,
+		TimeoutSeconds: &timeout,
+	}
+
+	if alertSpec.ReportStatus.Deployments {
+		deployments, deploymentserr := clientset.AppsV1().Deployments(alertSpec.WorkloadFilterNamespace).List(listopts)
+		if deploymentserr != nil {
+			return &PollErr{
+				Message: fmt.Sprintf("Unable to list Deployments: %s", deploymentserr.Error()),
+			}
+		}
+		for _, deployment := range deployments.Items {
+			resource := resourceID("deployment", deployment.Namespace, deployment.Name)
+			checkTemplateResources("Deployment", resource, &deployment.ObjectMeta, &deployment.Spec.Template.Spec, alertSpec, alertFn, alertersConfig)
+		}
+	}
+	if alertSpec.ReportStatus.Statefulsets {
+		statefulsets, statefulsetserr := clientset.AppsV1().StatefulSets(alertSpec.WorkloadFilterNamespace).List(listopts)
+		if statefulsetserr != nil {
+			return &PollErr{
+				Message: fmt.Sprintf("Unable to list StatefulSets: %s", statefulsetserr.Error()),
+			}
+		}
+		for _, statefulset := range statefulsets.Items {
+			resource := resourceID("statefulset", statefulset.Namespace, statefulset.Name)
+			checkTemplateResources("StatefulSet", resource, &statefulset.ObjectMeta, &statefulset.Spec.Template.Spec, alertSpec, alertFn, alertersConfig)
+		}
+	}
+	return nil
+}
+
+// checkTemplateResources alerts on a workload whose pod template has containers, init containers
+// included, that set no CPU or memory request or limit, as the rule checks them, naming each of them
+// with what it misses
+func checkTemplateResources(
+	kind string,
+	resource string,
+	meta *metav1.ObjectMeta,
+	spec *corev1.PodSpec,
+	alertSpec types.WorkloadResourceAlertSpec,
+	alertFn alertFunction,
+	alertersConfig types.AlertersConfig,
+) {
+	var unbounded []string
+	containers := append(append([]corev1.Container{}, spec.InitContainers...), spec.Containers...)
+	for _, container := range containers {
+		var missing []string
+		for _, name := range limitedResources {
+			if _, ok := container.Resources.Requests[name]; alertSpec.ReportStatus.Requests && !ok {
+				missing = append(missing, string(name)+" request")
+			}
+			if _, ok := container.Resources.Limits[name]; alertSpec.ReportStatus.Limits && !ok {
+				missing = append(missing, string(name)+" limit")
+			}
+		}
+		if len(missing) > 0 {
+			unbounded = append(unbounded, fmt.Sprintf("%s (%s)", container.Name, strings.Join(missing, ", ")))
+		}
+	}
+	if len(unbounded) == 0 {
+		return
+	}
+	// ALERT
+	alertmessage := fmt.Sprintf(
+		"%s %s/%s has a pod template with containers missing resource settings: %s! Its pods can use more of their node than the scheduler accounts for.",
+		kind, meta.Namespace, meta.Name, strings.Join(unbounded, ", "),
+	)
+	alert := newAlert(resource, "MissingResources", types.SeverityWarning, alertmessage)
+	alertFn(alertSpec.AlerterType, alertSpec.AlerterName, alert, alertersConfig)
+}
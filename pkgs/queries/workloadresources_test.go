@@ -0,0 +1,81 @@
+// Copyright 2019 Bloomberg Finance LP
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package queries
+
+import (
+	"testing"
+
+	. "github.com/bloomberg/k8eraid/pkgs/types"
+
+	"github.com/stretchr/testify/assert"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func Test_PollWorkloadResources(t *testing.T) {
+	_, conf := StubsInit()
+	bounded := corev1.ResourceRequirements{
+		Requests: corev1.ResourceList{corev1.ResourceCPU: resource.MustParse("100m"), corev1.ResourceMemory: resource.MustParse("128Mi")},
+		Limits:   corev1.ResourceList{corev1.ResourceCPU: resource.MustParse("1"), corev1.ResourceMemory: resource.MustParse("512Mi")},
+	}
+	template := corev1.PodTemplateSpec{Spec: corev1.PodSpec{
+		InitContainers: []corev1.Container{{Name: "migrate"}},
+		Containers: []corev1.Container{
+			{Name: "app", Resources: bounded},
+			{Name: "proxy", Resources: corev1.ResourceRequirements{Requests: bounded.Requests, Limits: corev1.ResourceList{corev1.ResourceMemory: resource.MustParse("64Mi")}}},
+		},
+	}}
+	labels := map[string]string{"tier": "web"}
+	client := fake.NewSimpleClientset(
+		&appsv1.Deployment{ObjectMeta: metav1.ObjectMeta{Name: "web", Namespace: "shop", Labels: labels}, Spec: appsv1.DeploymentSpec{Template: template}},
+		&appsv1.Deployment{
+			ObjectMeta: metav1.ObjectMeta{Name: "api", Namespace: "shop", Labels: labels},
+			Spec:       appsv1.DeploymentSpec{Template: corev1.PodTemplateSpec{Spec: corev1.PodSpec{Containers: []corev1.Container{{Name: "app", Resources: bounded}}}}},
+		},
+		&appsv1.Deployment{ObjectMeta: metav1.ObjectMeta{Name: "web", Namespace: "staging", Labels: labels}, Spec: appsv1.DeploymentSpec{Template: template}},
+		&appsv1.StatefulSet{ObjectMeta: metav1.ObjectMeta{Name: "db", Namespace: "shop"}, Spec: appsv1.StatefulSetSpec{Template: template}},
+	)
+	poll := func(alertSpec WorkloadResourceAlertSpec) []string {
+		messages := []string{}
+		err := PollWorkloadResources(client, alertSpec, defaultTickerTime, func(_ string, _ string, alert Alert, _ AlertersConfig) {
+			messages = append(messages, alert.Key+": "+alert.Message)
+		}, conf)
+		assert.NoError(t, err)
+		return messages
+	}
+
+	assert.Equal(t, []string{
+		"deployment/shop/web/MissingResources: Deployment shop/web has a pod template with containers missing resource settings:" +
+			" migrate (cpu request, cpu limit, memory request, memory limit), proxy (cpu limit)! Its pods can use more of their node than the scheduler accounts for.",
+		"statefulset/shop/db/MissingResources: StatefulSet shop/db has a pod template with containers missing resource settings:" +
+			" migrate (cpu request, cpu limit, memory request, memory limit), proxy (cpu limit)! Its pods can use more of their node than the scheduler accounts for.",
+	}, poll(WorkloadResourceAlertSpec{
+		WorkloadFilterNamespace: "shop",
+		ReportStatus:            WorkloadResourceAlertStatus{Deployments: true, Statefulsets: true, Requests: true, Limits: true},
+	}))
+	assert.Equal(t, []string{
+		"deployment/shop/web/MissingResources: Deployment shop/web has a pod template with containers missing resource settings:" +
+			" migrate (cpu request, memory request)! Its pods can use more of their node than the scheduler accounts for.",
+		"deployment/staging/web/MissingResources: Deployment staging/web has a pod template with containers missing resource settings:" +
+			" migrate (cpu request, memory request)! Its pods can use more of their node than the scheduler accounts for.",
+	}, poll(WorkloadResourceAlertSpec{
+		WorkloadFilterLabel: "tier=web",
+		ReportStatus:        WorkloadResourceAlertStatus{Deployments: true, Statefulsets: true, Requests: true},
+	}), "the label filter applies across namespaces")
+	assert.Empty(t, poll(WorkloadResourceAlertSpec{ReportStatus: WorkloadResourceAlertStatus{Deployments: true, Statefulsets: true}}))
+}
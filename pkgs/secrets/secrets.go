@@ -0,0 +1,87 @@
+// Copyright 2019 Bloomberg Finance LP
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package secrets resolves the secret references used in alerter configuration.
+//
+// A reference is one of
+//
+//	env:NAME            the value of the NAME environment variable
+//	file:/path          the contents of a file, such as a mounted Secret, without trailing newlines
+//	vault:path#field    the field of a HashiCorp Vault secret, read through the Vault HTTP API
+//
+// The `*EnvVar` fields of the alerters predate references, so a bare value there is still an
+// environment variable name.
+package secrets
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+)
+
+const (
+	envScheme   = "env:"
+	fileScheme  = "file:"
+	vaultScheme = "vault:"
+)
+
+// Lookup resolves ref, treating a value without a scheme as an environment variable name
+func Lookup(ref string) (string, error) {
+	if isReference(ref) {
+		return resolve(ref)
+	}
+	return os.Getenv(ref), nil
+}
+
+// Expand resolves value when it is a reference and returns it unchanged otherwise
+func Expand(value string) (string, error) {
+	if isReference(value) {
+		return resolve(value)
+	}
+	return value, nil
+}
+
+func isReference(value string) bool {
+	return strings.HasPrefix(value, envScheme) ||
+		strings.HasPrefix(value, fileScheme) ||
+		strings.HasPrefix(value, vaultScheme)
+}
+
+func resolve(ref string) (string, error) {
+	switch {
+	case strings.HasPrefix(ref, envScheme):
+		return os.Getenv(strings.TrimPrefix(ref, envScheme)), nil
+	case strings.HasPrefix(ref, fileScheme):
+		data, err := ioutil.ReadFile(strings.TrimPrefix(ref, fileScheme))
+		if err != nil {
+			return "", fmt.Errorf("unable to read secret %s: %s", ref, err.Error())
+		}
+		return strings.TrimRight(string(data), "\r\n"), nil
+	default:
+		path := strings.TrimPrefix(ref, vaultScheme)
+		field := ""
+		if i := strings.LastIndex(path, "#"); i >= 0 {
+			path, field = path[:i], path[i+1:]
+		}
+		if path == "" || field == "" {
+			return "", fmt.Errorf("vault secret %s must be of the form vault:path#field", ref)
+		}
+		client, err := defaultVault()
+		if err != nil {
+			return "", err
+		}
+		return client.read(path, field)
+	}
+}
@@ -0,0 +1,124 @@
+// Copyright 2019 Bloomberg Finance LP
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package secrets
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_Lookup(t *testing.T) {
+	os.Setenv("TEST_SECRET", "from-env")
+	defer os.Unsetenv("TEST_SECRET")
+	dir, err := ioutil.TempDir("", "secrets")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "key")
+	require.NoError(t, ioutil.WriteFile(path, []byte("from-file\n"), 0600))
+
+	tests := []struct {
+		ref     string
+		want    string
+		wantErr bool
+	}{
+		{ref: "TEST_SECRET", want: "from-env"},
+		{ref: "env:TEST_SECRET", want: "from-env"},
+		{ref: "file:" + path, want: "from-file"},
+		{ref: "file:" + filepath.Join(dir, "missing"), wantErr: true},
+		{ref: "vault:secret/data/k8eraid", wantErr: true},
+	}
+	for _, test := range tests {
+		t.Run(test.ref, func(t *testing.T) {
+			got, err := Lookup(test.ref)
+			if test.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, test.want, got)
+		})
+	}
+
+	expanded, err := Expand("https://hooks.example.com/abc")
+	assert.NoError(t, err)
+	assert.Equal(t, "https://hooks.example.com/abc", expanded, "plain values should not be treated as env var names")
+}
+
+func Test_vault_read(t *testing.T) {
+	dir, err := ioutil.TempDir("", "secrets")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	jwtPath := filepath.Join(dir, "token")
+	require.NoError(t, ioutil.WriteFile(jwtPath, []byte("sa-jwt"), 0600))
+
+	logins, reads := 0, 0
+	fail := false
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/v1/auth/kubernetes/login":
+			logins++
+			var body map[string]string
+			require.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+			assert.Equal(t, map[string]string{"role": "k8eraid", "jwt": "sa-jwt"}, body)
+			w.Write([]byte(`{"auth": {"client_token": "s.token", "lease_duration": 3600}}`))
+		case "/v1/secret/data/k8eraid":
+			reads++
+			if fail {
+				w.WriteHeader(http.StatusServiceUnavailable)
+				w.Write([]byte(`{"errors": ["sealed"]}`))
+				return
+			}
+			assert.Equal(t, "s.token", r.Header.Get("X-Vault-Token"))
+			w.Write([]byte(`{"data": {"data": {"pd_key": "abc123"}, "metadata": {"version": 3}}}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	now := time.Unix(1000, 0)
+	v := newVault(server.URL, time.Minute)
+	v.role = "k8eraid"
+	v.jwtPath = jwtPath
+	v.now = func() time.Time { return now }
+
+	value, err := v.read("secret/data/k8eraid", "pd_key")
+	assert.NoError(t, err)
+	assert.Equal(t, "abc123", value)
+
+	_, err = v.read("secret/data/k8eraid", "missing")
+	assert.EqualError(t, err, "vault secret secret/data/k8eraid has no field missing")
+	assert.Equal(t, 1, reads, "reads within the cache TTL should be served from the cache")
+
+	now = now.Add(2 * time.Minute)
+	fail = true
+	value, err = v.read("secret/data/k8eraid", "pd_key")
+	assert.NoError(t, err, "a failed refresh should serve the cached value")
+	assert.Equal(t, "abc123", value)
+	assert.Equal(t, 2, reads)
+	assert.Equal(t, 1, logins)
+
+	_, err = v.read("secret/data/other", "pd_key")
+	assert.Error(t, err)
+}
@@ -0,0 +1,235 @@
+// Copyright 2019 Bloomberg Finance LP
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package secrets
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	defaultVaultCacheTTL  = 300
+	defaultVaultK8sMount  = "kubernetes"
+	serviceAccountJWTPath = "/var/run/secrets/kubernetes.io/serviceaccount/token"
+)
+
+var (
+	errLogger = log.New(os.Stderr, "secrets", log.LstdFlags)
+
+	vaultMu     sync.Mutex
+	vaultClient *vault
+)
+
+// vault reads secrets through the Vault HTTP API and caches them until their lease, or the cache TTL, runs out
+type vault struct {
+	address   string
+	authMount string
+	role      string
+	jwtPath   string
+	cacheTTL  time.Duration
+	client    *http.Client
+	now       func() time.Time
+
+	mu          sync.Mutex
+	token       string
+	tokenExpiry time.Time
+	cache       map[string]cachedSecret
+}
+
+type cachedSecret struct {
+	data    map[string]interface{}
+	expires time.Time
+}
+
+// vaultResponse covers the parts of the read and login responses that are used
+type vaultResponse struct {
+	LeaseDuration int64                  `json:"lease_duration"`
+	Data          map[string]interface{} `json:"data"`
+	Auth          *struct {
+		ClientToken   string `json:"client_token"`
+		LeaseDuration int64  `json:"lease_duration"`
+	} `json:"auth"`
+	Errors []string `json:"errors"`
+}
+
+// defaultVault configures the Vault client from the environment on first use:
+// VAULT_ADDR, then either VAULT_TOKEN or VAULT_K8S_ROLE (with VAULT_K8S_MOUNT) for kubernetes auth,
+// and VAULT_CACHE_TTL for secrets that carry no lease.
+func defaultVault() (*vault, error) {
+	vaultMu.Lock()
+	defer vaultMu.Unlock()
+	if vaultClient != nil {
+		return vaultClient, nil
+	}
+	address := os.Getenv("VAULT_ADDR")
+	if address == "" {
+		return nil, fmt.Errorf("vault secrets need VAULT_ADDR to be set")
+	}
+	cacheTTL := defaultVaultCacheTTL
+	if value := os.Getenv("VAULT_CACHE_TTL"); value != "" {
+		parsed, err := strconv.Atoi(value)
+		if err != nil {
+			return nil, fmt.Errorf("VAULT_CACHE_TTL=%s cannot be converted to int: %s", value, err.Error())
+		}
+		cacheTTL = parsed
+	}
+	mount := os.Getenv("VAULT_K8S_MOUNT")
+	if mount == "" {
+		mount = defaultVaultK8sMount
+	}
+	client := newVault(address, time.Duration(cacheTTL)*time.Second)
+	client.token = os.Getenv("VAULT_TOKEN")
+	client.role = os.Getenv("VAULT_K8S_ROLE")
+	client.authMount = mount
+	if client.token == "" && client.role == "" {
+		return nil, fmt.Errorf("vault secrets need either VAULT_TOKEN or VAULT_K8S_ROLE to be set")
+	}
+	vaultClient = client
+	return vaultClient, nil
+}
+
+func newVault(address string, cacheTTL time.Duration) *vault {
+	return &vault{
+		address:   strings.TrimRight(address, "/"),
+		authMount: defaultVaultK8sMount,
+		jwtPath:   serviceAccountJWTPath,
+		cacheTTL:  cacheTTL,
+		client:    &http.Client{Timeout: 10 * time.Second},
+		now:       time.Now,
+		cache:     map[string]cachedSecret{},
+	}
+}
+
+// read returns field of the secret at path. When a refresh fails the last value read is served
+// until Vault is reachable again, so an outage does not break alert delivery.
+func (v *vault) read(path string, field string) (string, error) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	cached, ok := v.cache[path]
+	if !ok || !v.now().Before(cached.expires) {
+		fresh, err := v.fetch(path)
+		if err != nil {
+			if !ok {
+				return "", err
+			}
+			errLogger.Printf("Unable to refresh vault secret %s, using the cached value: %s", path, err.Error())
+		} else {
+			cached = fresh
+			v.cache[path] = fresh
+		}
+	}
+
+	value, ok := cached.data[field]
+	if !ok {
+		return "", fmt.Errorf("vault secret %s has no field %s", path, field)
+	}
+	if s, ok := value.(string); ok {
+		return s, nil
+	}
+	return fmt.Sprint(value), nil
+}
+
+func (v *vault) fetch(path string) (cachedSecret, error) {
+	token, err := v.clientToken()
+	if err != nil {
+		return cachedSecret{}, err
+	}
+	req, err := http.NewRequest(http.MethodGet, v.address+"/v1/"+strings.TrimLeft(path, "/"), nil)
+	if err != nil {
+		return cachedSecret{}, err
+	}
+	req.Header.Set("X-Vault-Token", token)
+	resp, err := v.do(req)
+	if err != nil {
+		// a revoked or expired token is replaced on the next read
+		v.tokenExpiry = time.Time{}
+		return cachedSecret{}, fmt.Errorf("unable to read vault secret %s: %s", path, err.Error())
+	}
+
+	data := resp.Data
+	// KV version 2 nests the secret under data.data next to its metadata
+	if inner, ok := data["data"].(map[string]interface{}); ok {
+		if _, ok := data["metadata"]; ok {
+			data = inner
+		}
+	}
+	ttl := v.cacheTTL
+	if resp.LeaseDuration > 0 {
+		ttl = time.Duration(resp.LeaseDuration) * time.Second
+	}
+	return cachedSecret{data: data, expires: v.now().Add(ttl)}, nil
+}
+
+// clientToken returns the static token, or logs in with the kubernetes auth method when the last login expired
+func (v *vault) clientToken() (string, error) {
+	if v.role == "" {
+		return v.token, nil
+	}
+	if v.token != "" && v.now().Before(v.tokenExpiry) {
+		return v.token, nil
+	}
+	jwt, err := ioutil.ReadFile(v.jwtPath)
+	if err != nil {
+		return "", fmt.Errorf("unable to read service account token for vault login: %s", err.Error())
+	}
+	body, _ := json.Marshal(map[string]string{"role": v.role, "jwt": strings.TrimSpace(string(jwt))})
+	req, err := http.NewRequest(http.MethodPost, v.address+"/v1/auth/"+v.authMount+"/login", bytes.NewReader(body))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := v.do(req)
+	if err != nil {
+		return "", fmt.Errorf("vault kubernetes login failed: %s", err.Error())
+	}
+	if resp.Auth == nil || resp.Auth.ClientToken == "" {
+		return "", fmt.Errorf("vault kubernetes login returned no token")
+	}
+	v.token = resp.Auth.ClientToken
+	lease := v.cacheTTL
+	if resp.Auth.LeaseDuration > 0 {
+		// renew a little early so the token does not expire between check and use
+		lease = time.Duration(resp.Auth.LeaseDuration) * time.Second * 9 / 10
+	}
+	v.tokenExpiry = v.now().Add(lease)
+	return v.token, nil
+}
+
+func (v *vault) do(req *http.Request) (*vaultResponse, error) {
+	resp, err := v.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	var decoded vaultResponse
+	if err := json.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(&decoded); err != nil && resp.StatusCode < 300 {
+		return nil, fmt.Errorf("invalid vault response: %s", err.Error())
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return nil, fmt.Errorf("HTTP Status Code: %d: %s", resp.StatusCode, strings.Join(decoded.Errors, ", "))
+	}
+	return &decoded, nil
+}
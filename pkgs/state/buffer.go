@@ -0,0 +1,63 @@
+// Copyright 2019 Bloomberg Finance LP
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package state
+
+import (
+	"sync"
+	"time"
+)
+
+// SampleBuffer collects the samples of one series and records them in a Store in batches, so a
+// signal sampled on every apiserver call takes the store's lock once per batch instead of once per
+// sample. It is safe for concurrent use.
+type SampleBuffer struct {
+	mu      sync.Mutex
+	key     string
+	window  time.Duration
+	size    int
+	pending []Sample
+}
+
+// NewSampleBuffer returns a SampleBuffer for the series key, recording once size samples are
+// pending and trimming the series to window like RecordSample
+func NewSampleBuffer(key string, window time.Duration, size int) *SampleBuffer {
+	if size < 1 {
+		size = 1
+	}
+	return &SampleBuffer{key: key, window: window, size: size, pending: make([]Sample, 0, size)}
+}
+
+// Add buffers sample, and records the pending samples in store once the buffer is full
+func (b *SampleBuffer) Add(store *Store, sample Sample) {
+	b.mu.Lock()
+	b.pending = append(b.pending, sample)
+	if len(b.pending) < b.size {
+		b.mu.Unlock()
+		return
+	}
+	batch := b.pending
+	b.pending = make([]Sample, 0, b.size)
+	b.mu.Unlock()
+	store.RecordSamples(b.key, batch, b.window)
+}
+
+// Flush records the pending samples in store, so readers of the series see every sample added so far
+func (b *SampleBuffer) Flush(store *Store) {
+	b.mu.Lock()
+	batch := b.pending
+	b.pending = make([]Sample, 0, b.size)
+	b.mu.Unlock()
+	store.RecordSamples(b.key, batch, b.window)
+}
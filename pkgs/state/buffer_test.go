@@ -0,0 +1,100 @@
+// Copyright 2019 Bloomberg Finance LP
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package state
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_SampleBuffer(t *testing.T) {
+	s := NewStore()
+	start := time.Unix(1000, 0)
+	b := NewSampleBuffer("latency", 2*time.Minute, 3)
+	for i := 0; i < 4; i++ {
+		b.Add(s, Sample{Time: start.Add(time.Duration(i) * time.Minute), Value: float64(i)})
+	}
+	assert.Equal(t, 3, len(s.Samples("latency", time.Time{})), "a full batch should be recorded")
+
+	b.Flush(s)
+	assert.Equal(t, []Sample{
+		{Time: start.Add(time.Minute), Value: 1},
+		{Time: start.Add(2 * time.Minute), Value: 2},
+		{Time: start.Add(3 * time.Minute), Value: 3},
+	}, s.Samples("latency", time.Time{}), "flushing should record the pending sample and trim the series to the window")
+	b.Flush(s)
+	assert.Equal(t, 3, len(s.Samples("latency", time.Time{})), "flushing twice should not record anything twice")
+}
+
+func Test_SampleBuffer_Concurrent(t *testing.T) {
+	s := NewStore()
+	b := NewSampleBuffer("latency", time.Hour, 16)
+	now := time.Now()
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				b.Add(s, Sample{Time: now, Value: 1})
+			}
+		}()
+	}
+	wg.Wait()
+	b.Flush(s)
+	assert.Equal(t, 800, len(s.Samples("latency", time.Time{})))
+}
+
+// The benchmarks below record samples from b.N calls spread over GOMAXPROCS concurrent pollers, as
+// with go test -bench . -cpu 1,4,16, comparing a store write per sample with batched writes, and
+// writes to one series with writes to the series of many rules.
+
+func BenchmarkStore_RecordSample(b *testing.B) {
+	s := NewStore()
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			s.RecordSample("latency", Sample{Time: time.Now(), Value: 1}, time.Millisecond)
+		}
+	})
+}
+
+func BenchmarkSampleBuffer_Add(b *testing.B) {
+	s := NewStore()
+	buffer := NewSampleBuffer("latency", time.Millisecond, 64)
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			buffer.Add(s, Sample{Time: time.Now(), Value: 1})
+		}
+	})
+}
+
+func BenchmarkStore_SwapSnapshot_ManyRules(b *testing.B) {
+	s := NewStore()
+	keys := make([]string, 64)
+	for i := range keys {
+		keys[i] = fmt.Sprintf("nodes/rule-%d", i)
+	}
+	b.RunParallel(func(pb *testing.PB) {
+		i := 0
+		for pb.Next() {
+			s.SwapSnapshot(keys[i%len(keys)], Snapshot{})
+			i++
+		}
+	})
+}
@@ -0,0 +1,130 @@
+// Copyright 2019 Bloomberg Finance LP
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package state
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// persistVersion is bumped whenever the saved form changes incompatibly
+const persistVersion = 1
+
+// savedStore is the form a Store is saved in
+type savedStore struct {
+	Version   int                             `json:"version"`
+	SavedAt   time.Time                       `json:"savedAt"`
+	Snapshots map[string]Snapshot             `json:"snapshots"`
+	Series    map[string][]Sample             `json:"series"`
+	Events    map[string]map[string]time.Time `json:"events"`
+	Active    map[string]ActiveAlert          `json:"active"`
+}
+
+// Save writes everything the store remembers to path as JSON. The file is written next to path
+// and renamed over it, so a crash while saving never leaves a truncated file behind.
+func (s *Store) Save(path string, now time.Time) error {
+	data, err := s.marshal(now)
+	if err != nil {
+		return err
+	}
+
+	tmp, err := ioutil.TempFile(filepath.Dir(path), filepath.Base(path)+".tmp")
+	if err != nil {
+		return fmt.Errorf("unable to create a temporary file for %s: %s", path, err.Error())
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("unable to write %s: %s", tmp.Name(), err.Error())
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("unable to write %s: %s", tmp.Name(), err.Error())
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return fmt.Errorf("unable to replace %s: %s", path, err.Error())
+	}
+	return nil
+}
+
+// marshal renders the store in its saved form. Every shard stays locked until the store is
+// marshalled, so the file is a consistent picture of a single moment.
+func (s *Store) marshal(now time.Time) ([]byte, error) {
+	saved := savedStore{
+		Version:   persistVersion,
+		SavedAt:   now,
+		Snapshots: map[string]Snapshot{},
+		Series:    map[string][]Sample{},
+		Events:    map[string]map[string]time.Time{},
+		Active:    map[string]ActiveAlert{},
+	}
+	for i := range s.shards {
+		shard := &s.shards[i]
+		shard.mu.Lock()
+		defer shard.mu.Unlock()
+		for key, snapshot := range shard.snapshots {
+			saved.Snapshots[key] = snapshot
+		}
+		for key, series := range shard.series {
+			saved.Series[key] = series
+		}
+		for key, events := range shard.events {
+			saved.Events[key] = events
+		}
+		for key, alert := range shard.active {
+			saved.Active[key] = alert
+		}
+	}
+	return json.Marshal(saved)
+}
+
+// Load reads a store written by Save. A missing file gives an empty store, as on the very first
+// start.
+func Load(path string) (*Store, error) {
+	store := NewStore()
+	data, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return store, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("unable to read %s: %s", path, err.Error())
+	}
+	var saved savedStore
+	if err := json.Unmarshal(data, &saved); err != nil {
+		return nil, fmt.Errorf("unable to parse %s: %s", path, err.Error())
+	}
+	if saved.Version != persistVersion {
+		return nil, fmt.Errorf("%s was saved with version %d, expected %d", path, saved.Version, persistVersion)
+	}
+	// restored state expires as if it was written on load
+	for key, snapshot := range saved.Snapshots {
+		store.shard(key).snapshots[key] = snapshot
+		store.shard(key).touch(key)
+	}
+	for key, series := range saved.Series {
+		store.shard(key).series[key] = series
+		store.shard(key).touch(key)
+	}
+	for key, events := range saved.Events {
+		store.shard(key).events[key] = events
+		store.shard(key).touch(key)
+	}
+	for key, alert := range saved.Active {
+		store.shard(key).active[key] = alert
+	}
+	return store, nil
+}
@@ -0,0 +1,358 @@
+// Copyright 2019 Bloomberg Finance LP
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package state
+
+import (
+	"hash/fnv"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Snapshot maps the resource IDs a rule matched on one tick to a summary of their state
+type Snapshot map[string]string
+
+// Change is a resource whose summary differs between two snapshots
+type Change struct {
+	Resource string
+	Before   string
+	After    string
+}
+
+// Diff lists what changed between two snapshots, sorted by resource
+type Diff struct {
+	Added   []Change
+	Removed []Change
+	Changed []Change
+}
+
+// Empty reports whether the snapshots were identical
+func (d Diff) Empty() bool {
+	return len(d.Added) == 0 && len(d.Removed) == 0 && len(d.Changed) == 0
+}
+
+// DiffSnapshots compares the snapshot of a previous tick with the current one
+func DiffSnapshots(before Snapshot, after Snapshot) Diff {
+	var diff Diff
+	for resource, summary := range after {
+		if previous, ok := before[resource]; !ok {
+			diff.Added = append(diff.Added, Change{Resource: resource, After: summary})
+		} else if previous != summary {
+			diff.Changed = append(diff.Changed, Change{Resource: resource, Before: previous, After: summary})
+		}
+	}
+	for resource, summary := range before {
+		if _, ok := after[resource]; !ok {
+			diff.Removed = append(diff.Removed, Change{Resource: resource, Before: summary})
+		}
+	}
+	for _, changes := range [][]Change{diff.Added, diff.Removed, diff.Changed} {
+		sort.Slice(changes, func(i, j int) bool { return changes[i].Resource < changes[j].Resource })
+	}
+	return diff
+}
+
+// storeShards is how many independently locked parts a Store is split in, so pollers writing
+// the state of different rules rarely wait on each other
+const storeShards = 16
+
+// Store remembers what k8eraid observed on previous ticks. It is safe for concurrent use. Its state
+// is spread over shards by key, each with its own lock.
+type Store struct {
+	shards [storeShards]storeShard
+}
+
+// storeShard holds the state of the keys hashing to it
+type storeShard struct {
+	mu        sync.Mutex
+	snapshots map[string]Snapshot
+	series    map[string][]Sample
+	events    map[string]map[string]time.Time
+	active    map[string]ActiveAlert
+	// touched is when each key of snapshots, series and events was last written
+	touched map[string]time.Time
+}
+
+// touch records that key was written now. shard.mu must be held.
+func (shard *storeShard) touch(key string) {
+	shard.touched[key] = time.Now()
+}
+
+// NewStore returns an empty Store
+func NewStore() *Store {
+	s := &Store{}
+	for i := range s.shards {
+		s.shards[i] = storeShard{
+			snapshots: map[string]Snapshot{},
+			series:    map[string][]Sample{},
+			events:    map[string]map[string]time.Time{},
+			active:    map[string]ActiveAlert{},
+			touched:   map[string]time.Time{},
+		}
+	}
+	return s
+}
+
+// shard returns the shard holding the state of key
+func (s *Store) shard(key string) *storeShard {
+	hash := fnv.New32a()
+	hash.Write([]byte(key))
+	return &s.shards[hash.Sum32()%storeShards]
+}
+
+// SwapSnapshot records the current snapshot for key and returns the previous one.
+// ok is false when nothing was recorded for key yet.
+func (s *Store) SwapSnapshot(key string, current Snapshot) (previous Snapshot, ok bool) {
+	shard := s.shard(key)
+	shard.mu.Lock()
+	defer shard.mu.Unlock()
+	previous, ok = shard.snapshots[key]
+	shard.snapshots[key] = current
+	shard.touch(key)
+	return previous, ok
+}
+
+// UpdateSnapshot replaces the snapshot for key with what update returns for the previous one.
+// ok is false when nothing was recorded for key yet.
+func (s *Store) UpdateSnapshot(key string, update func(previous Snapshot, ok bool) Snapshot) {
+	shard := s.shard(key)
+	shard.mu.Lock()
+	defer shard.mu.Unlock()
+	previous, ok := shard.snapshots[key]
+	shard.snapshots[key] = update(previous, ok)
+	shard.touch(key)
+}
+
+// ForgetSnapshot drops the snapshot for key, if any
+func (s *Store) ForgetSnapshot(key string) {
+	shard := s.shard(key)
+	shard.mu.Lock()
+	defer shard.mu.Unlock()
+	delete(shard.snapshots, key)
+}
+
+// Sample is one observation of a numeric signal
+type Sample struct {
+	Time  time.Time
+	Value float64
+}
+
+// RecordSample appends sample to the series for key, forgets samples older than window
+// and returns a copy of what is left, oldest first.
+func (s *Store) RecordSample(key string, sample Sample, window time.Duration) []Sample {
+	shard := s.shard(key)
+	shard.mu.Lock()
+	defer shard.mu.Unlock()
+	shard.recordSamples(key, []Sample{sample}, window)
+	return append([]Sample(nil), shard.series[key]...)
+}
+
+// RecordSamples appends samples to the series for key under a single lock, and forgets samples
+// older than window before the last of them
+func (s *Store) RecordSamples(key string, samples []Sample, window time.Duration) {
+	if len(samples) == 0 {
+		return
+	}
+	shard := s.shard(key)
+	shard.mu.Lock()
+	shard.recordSamples(key, samples, window)
+	shard.mu.Unlock()
+}
+
+// recordSamples appends samples to the series for key and trims it to window. shard.mu must be held.
+func (shard *storeShard) recordSamples(key string, samples []Sample, window time.Duration) {
+	series := append(shard.series[key], samples...)
+	cutoff := samples[len(samples)-1].Time.Add(-window)
+	first := 0
+	for first < len(series) && series[first].Time.Before(cutoff) {
+		first++
+	}
+	shard.series[key] = series[first:]
+	shard.touch(key)
+}
+
+// Samples returns a copy of the samples recorded for key at or after since, oldest first
+func (s *Store) Samples(key string, since time.Time) []Sample {
+	shard := s.shard(key)
+	shard.mu.Lock()
+	defer shard.mu.Unlock()
+	var samples []Sample
+	for _, sample := range shard.series[key] {
+		if !sample.Time.Before(since) {
+			samples = append(samples, sample)
+		}
+	}
+	return samples
+}
+
+// Slope is the least squares slope of samples in units per second
+func Slope(samples []Sample) float64 {
+	if len(samples) < 2 {
+		return 0
+	}
+	origin := samples[0].Time
+	var sumX, sumY, sumXY, sumXX float64
+	for _, sample := range samples {
+		x := sample.Time.Sub(origin).Seconds()
+		sumX += x
+		sumY += sample.Value
+		sumXY += x * sample.Value
+		sumXX += x * x
+	}
+	n := float64(len(samples))
+	denominator := n*sumXX - sumX*sumX
+	if denominator == 0 {
+		return 0
+	}
+	return (n*sumXY - sumX*sumY) / denominator
+}
+
+// RecordEvents merges events, keyed by a unique ID and mapped to when they happened, into the ones
+// seen for key and returns how many distinct events happened within window before now. Seeing the
+// same event on several ticks only counts it once.
+func (s *Store) RecordEvents(key string, events map[string]time.Time, now time.Time, window time.Duration) int {
+	shard := s.shard(key)
+	shard.mu.Lock()
+	defer shard.mu.Unlock()
+	seen, ok := shard.events[key]
+	if !ok {
+		seen = map[string]time.Time{}
+		shard.events[key] = seen
+	}
+	for id, at := range events {
+		seen[id] = at
+	}
+	shard.touch(key)
+	cutoff := now.Add(-window)
+	for id, at := range seen {
+		if at.Before(cutoff) {
+			delete(seen, id)
+		}
+	}
+	return len(seen)
+}
+
+// Expire drops the snapshots, series and events last written before cutoff and returns how many
+// keys it dropped. Pollers write the state of the objects they see on every tick, so a cutoff a few
+// ticks back forgets the objects that were deleted. Active alerts are left to ResolveAlerts.
+func (s *Store) Expire(cutoff time.Time) int {
+	expired := 0
+	for i := range s.shards {
+		shard := &s.shards[i]
+		shard.mu.Lock()
+		for key, at := range shard.touched {
+			if at.Before(cutoff) {
+				delete(shard.snapshots, key)
+				delete(shard.series, key)
+				delete(shard.events, key)
+				delete(shard.touched, key)
+				expired++
+			}
+		}
+		shard.mu.Unlock()
+	}
+	return expired
+}
+
+// ActiveAlert is an alert that was raised recently and has not resolved yet
+type ActiveAlert struct {
+	Key          string
+	Severity     string
+	ResourceType string
+	FirstSeen    time.Time
+	LastSeen     time.Time
+	// AcknowledgedAt is when someone took the alert on, zero while it is unacknowledged
+	AcknowledgedAt time.Time
+	AcknowledgedBy string
+}
+
+// FireAlert marks alert as active at its LastSeen time, keeping when it first fired and whether it
+// was acknowledged if it already was. It returns true when the alert was not active before.
+func (s *Store) FireAlert(alert ActiveAlert) bool {
+	shard := s.shard(alert.Key)
+	shard.mu.Lock()
+	defer shard.mu.Unlock()
+	previous, ok := shard.active[alert.Key]
+	if ok {
+		alert.FirstSeen = previous.FirstSeen
+		alert.AcknowledgedAt, alert.AcknowledgedBy = previous.AcknowledgedAt, previous.AcknowledgedBy
+	} else {
+		alert.FirstSeen = alert.LastSeen
+	}
+	shard.active[alert.Key] = alert
+	return !ok
+}
+
+// ActiveAlert returns the active alert of key, and whether there is one
+func (s *Store) ActiveAlert(key string) (ActiveAlert, bool) {
+	shard := s.shard(key)
+	shard.mu.Lock()
+	defer shard.mu.Unlock()
+	alert, ok := shard.active[key]
+	return alert, ok
+}
+
+// AcknowledgeAlert marks the active alert of key as acknowledged by who at now, or unacknowledged
+// when who is empty. It returns false when no alert of key is active. The acknowledgement lasts
+// until the alert resolves.
+func (s *Store) AcknowledgeAlert(key string, who string, now time.Time) bool {
+	shard := s.shard(key)
+	shard.mu.Lock()
+	defer shard.mu.Unlock()
+	alert, ok := shard.active[key]
+	if !ok {
+		return false
+	}
+	if who == "" {
+		alert.AcknowledgedAt, alert.AcknowledgedBy = time.Time{}, ""
+	} else {
+		alert.AcknowledgedAt, alert.AcknowledgedBy = now, who
+	}
+	shard.active[key] = alert
+	return true
+}
+
+// ResolveAlerts drops the active alerts last raised before cutoff and returns them, sorted by key
+func (s *Store) ResolveAlerts(cutoff time.Time) []ActiveAlert {
+	var resolved []ActiveAlert
+	for i := range s.shards {
+		shard := &s.shards[i]
+		shard.mu.Lock()
+		for key, alert := range shard.active {
+			if alert.LastSeen.Before(cutoff) {
+				resolved = append(resolved, alert)
+				delete(shard.active, key)
+			}
+		}
+		shard.mu.Unlock()
+	}
+	sort.Slice(resolved, func(i, j int) bool { return resolved[i].Key < resolved[j].Key })
+	return resolved
+}
+
+// ActiveAlerts returns the alerts that are currently active, sorted by key
+func (s *Store) ActiveAlerts() []ActiveAlert {
+	active := []ActiveAlert{}
+	for i := range s.shards {
+		shard := &s.shards[i]
+		shard.mu.Lock()
+		for _, alert := range shard.active {
+			active = append(active, alert)
+		}
+		shard.mu.Unlock()
+	}
+	sort.Slice(active, func(i, j int) bool { return active[i].Key < active[j].Key })
+	return active
+}
@@ -0,0 +1,220 @@
+// Copyright 2019 Bloomberg Finance LP
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package state
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_DiffSnapshots(t *testing.T) {
+	before := Snapshot{"node/a": "Ready=True", "node/b": "Ready=True", "node/c": "Ready=True"}
+	after := Snapshot{"node/a": "Ready=True", "node/b": "Ready=False", "node/d": "Ready=True"}
+
+	diff := DiffSnapshots(before, after)
+	assert.Equal(t, []Change{{Resource: "node/d", After: "Ready=True"}}, diff.Added)
+	assert.Equal(t, []Change{{Resource: "node/c", Before: "Ready=True"}}, diff.Removed)
+	assert.Equal(t, []Change{{Resource: "node/b", Before: "Ready=True", After: "Ready=False"}}, diff.Changed)
+	assert.False(t, diff.Empty())
+	assert.True(t, DiffSnapshots(after, after).Empty())
+}
+
+func Test_Store_SwapSnapshot(t *testing.T) {
+	s := NewStore()
+	_, ok := s.SwapSnapshot("nodes", Snapshot{"node/a": "Ready=True"})
+	assert.False(t, ok)
+
+	previous, ok := s.SwapSnapshot("nodes", Snapshot{})
+	assert.True(t, ok)
+	assert.Equal(t, Snapshot{"node/a": "Ready=True"}, previous)
+}
+
+func Test_Store_UpdateSnapshot(t *testing.T) {
+	s := NewStore()
+	s.UpdateSnapshot("unused", func(previous Snapshot, ok bool) Snapshot {
+		assert.False(t, ok)
+		return Snapshot{"secret/a/b": "first"}
+	})
+	s.UpdateSnapshot("unused", func(previous Snapshot, ok bool) Snapshot {
+		assert.True(t, ok)
+		assert.Equal(t, Snapshot{"secret/a/b": "first"}, previous)
+		return Snapshot{}
+	})
+	previous, _ := s.SwapSnapshot("unused", Snapshot{})
+	assert.Equal(t, Snapshot{}, previous)
+}
+
+func Test_Store_ForgetSnapshot(t *testing.T) {
+	s := NewStore()
+	s.SwapSnapshot("paused", Snapshot{"since": "2019-06-01T00:00:00Z"})
+	s.ForgetSnapshot("paused")
+	s.ForgetSnapshot("never-recorded")
+	_, ok := s.SwapSnapshot("paused", Snapshot{})
+	assert.False(t, ok)
+}
+
+func Test_Store_RecordSample(t *testing.T) {
+	s := NewStore()
+	start := time.Unix(1000, 0)
+	for i := 0; i < 5; i++ {
+		s.RecordSample("replicas", Sample{Time: start.Add(time.Duration(i) * time.Minute), Value: float64(10 - i)}, 2*time.Minute)
+	}
+	samples := s.RecordSample("replicas", Sample{Time: start.Add(5 * time.Minute), Value: 5}, 2*time.Minute)
+	assert.Equal(t, []Sample{
+		{Time: start.Add(3 * time.Minute), Value: 7},
+		{Time: start.Add(4 * time.Minute), Value: 6},
+		{Time: start.Add(5 * time.Minute), Value: 5},
+	}, samples)
+	assert.InDelta(t, -1.0/60, Slope(samples), 1e-9)
+	assert.Equal(t, samples[1:], s.Samples("replicas", start.Add(4*time.Minute)))
+	assert.Empty(t, s.Samples("other", start))
+}
+
+func Test_Slope(t *testing.T) {
+	start := time.Unix(0, 0)
+	assert.Equal(t, 0.0, Slope([]Sample{{Time: start, Value: 3}}))
+	assert.Equal(t, 0.0, Slope([]Sample{{Time: start, Value: 3}, {Time: start, Value: 1}}))
+	assert.InDelta(t, 0.5, Slope([]Sample{
+		{Time: start, Value: 0},
+		{Time: start.Add(2 * time.Second), Value: 1},
+		{Time: start.Add(4 * time.Second), Value: 2},
+	}), 1e-9)
+}
+
+func Test_Store_RecordEvents(t *testing.T) {
+	s := NewStore()
+	now := time.Unix(10000, 0)
+	assert.Equal(t, 2, s.RecordEvents("oom", map[string]time.Time{
+		"a": now.Add(-time.Minute),
+		"b": now.Add(-2 * time.Minute),
+		"c": now.Add(-time.Hour),
+	}, now, 30*time.Minute))
+	assert.Equal(t, 3, s.RecordEvents("oom", map[string]time.Time{
+		"a": now.Add(-time.Minute),
+		"d": now,
+	}, now, 30*time.Minute), "events already seen should only count once")
+	assert.Equal(t, 2, s.RecordEvents("oom", nil, now.Add(20*time.Minute), 21*time.Minute+30*time.Second))
+}
+
+func Test_Store_Expire(t *testing.T) {
+	s := NewStore()
+	now := time.Unix(10000, 0)
+	s.SwapSnapshot("crashloop/pod/ns/gone/app", Snapshot{"since": "2019-06-01T00:00:00Z"})
+	s.RecordSample("restarts/pod/ns/gone/app", Sample{Time: now, Value: 1}, time.Hour)
+	s.RecordEvents("deployment/ns/gone/OOMKilled", map[string]time.Time{"a": now}, now, time.Hour)
+	s.FireAlert(ActiveAlert{Key: "pod/ns/gone/RestartRate", LastSeen: now})
+	time.Sleep(time.Millisecond)
+	cutoff := time.Now()
+	time.Sleep(time.Millisecond)
+	s.RecordSample("restarts/pod/ns/kept/app", Sample{Time: now, Value: 1}, time.Hour)
+
+	assert.Equal(t, 3, s.Expire(cutoff))
+	_, ok := s.SwapSnapshot("crashloop/pod/ns/gone/app", Snapshot{})
+	assert.False(t, ok)
+	assert.Empty(t, s.Samples("restarts/pod/ns/gone/app", time.Time{}))
+	assert.Equal(t, 0, s.RecordEvents("deployment/ns/gone/OOMKilled", nil, now, time.Hour))
+	assert.Len(t, s.Samples("restarts/pod/ns/kept/app", time.Time{}), 1, "state written since the cutoff should be kept")
+	assert.Len(t, s.ActiveAlerts(), 1, "active alerts are left to ResolveAlerts")
+}
+
+func Test_Store_ActiveAlerts(t *testing.T) {
+	s := NewStore()
+	start := time.Unix(1000, 0)
+	assert.True(t, s.FireAlert(ActiveAlert{Key: "node/a/Ready", Severity: "critical", ResourceType: "node", LastSeen: start}))
+	assert.True(t, s.FireAlert(ActiveAlert{Key: "pod/ns/b/Ready", Severity: "warning", ResourceType: "pod", LastSeen: start}))
+	assert.False(t, s.FireAlert(ActiveAlert{Key: "node/a/Ready", Severity: "critical", ResourceType: "node", LastSeen: start.Add(time.Minute)}))
+	assert.Equal(t, []ActiveAlert{
+		{Key: "node/a/Ready", Severity: "critical", ResourceType: "node", FirstSeen: start, LastSeen: start.Add(time.Minute)},
+		{Key: "pod/ns/b/Ready", Severity: "warning", ResourceType: "pod", FirstSeen: start, LastSeen: start},
+	}, s.ActiveAlerts())
+
+	resolved := s.ResolveAlerts(start.Add(30 * time.Second))
+	assert.Equal(t, 1, len(resolved))
+	assert.Equal(t, "pod/ns/b/Ready", resolved[0].Key)
+	assert.Equal(t, 1, len(s.ActiveAlerts()))
+	assert.Empty(t, s.ResolveAlerts(start.Add(30*time.Second)), "resolved alerts should only be reported once")
+}
+
+func Test_Store_AcknowledgeAlert(t *testing.T) {
+	s := NewStore()
+	start := time.Unix(1000, 0)
+	assert.False(t, s.AcknowledgeAlert("node/a/Ready", "alice", start), "only active alerts can be acknowledged")
+
+	s.FireAlert(ActiveAlert{Key: "node/a/Ready", Severity: "critical", LastSeen: start})
+	assert.True(t, s.AcknowledgeAlert("node/a/Ready", "alice", start.Add(time.Minute)))
+	s.FireAlert(ActiveAlert{Key: "node/a/Ready", Severity: "critical", LastSeen: start.Add(2 * time.Minute)})
+	alert, ok := s.ActiveAlert("node/a/Ready")
+	assert.True(t, ok)
+	assert.Equal(t, "alice", alert.AcknowledgedBy, "raising the alert again should keep its acknowledgement")
+	assert.Equal(t, start.Add(time.Minute), alert.AcknowledgedAt)
+
+	assert.True(t, s.AcknowledgeAlert("node/a/Ready", "", start.Add(3*time.Minute)))
+	alert, _ = s.ActiveAlert("node/a/Ready")
+	assert.True(t, alert.AcknowledgedAt.IsZero())
+
+	s.AcknowledgeAlert("node/a/Ready", "bob", start.Add(3*time.Minute))
+	s.ResolveAlerts(start.Add(time.Hour))
+	s.FireAlert(ActiveAlert{Key: "node/a/Ready", Severity: "critical", LastSeen: start.Add(2 * time.Hour)})
+	alert, _ = s.ActiveAlert("node/a/Ready")
+	assert.Empty(t, alert.AcknowledgedBy, "an alert firing again after it resolved should need a new acknowledgement")
+}
+
+func Test_Store_SaveLoad(t *testing.T) {
+	dir, err := ioutil.TempDir("", "k8eraid-state")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "state.json")
+
+	empty, err := Load(path)
+	require.NoError(t, err, "a missing file should give an empty store")
+	assert.Empty(t, empty.ActiveAlerts())
+
+	start := time.Unix(1000, 0)
+	s := NewStore()
+	s.SwapSnapshot("nodes", Snapshot{"node/a": "Ready=True"})
+	s.RecordSample("trend", Sample{Time: start, Value: 3}, time.Hour)
+	s.RecordEvents("oom", map[string]time.Time{"pod/a/1": start}, start, time.Hour)
+	s.FireAlert(ActiveAlert{Key: "node/a/Ready", Severity: "critical", ResourceType: "node", LastSeen: start})
+	require.NoError(t, s.Save(path, start))
+
+	loaded, err := Load(path)
+	require.NoError(t, err)
+	previous, ok := loaded.SwapSnapshot("nodes", Snapshot{})
+	assert.True(t, ok)
+	assert.Equal(t, Snapshot{"node/a": "Ready=True"}, previous)
+	samples := loaded.Samples("trend", start)
+	if assert.Equal(t, 1, len(samples)) {
+		assert.Equal(t, 3.0, samples[0].Value)
+	}
+	assert.Equal(t, 1, loaded.RecordEvents("oom", map[string]time.Time{"pod/a/1": start}, start, time.Hour), "a restored event should not be counted twice")
+	active := loaded.ActiveAlerts()
+	if assert.Equal(t, 1, len(active)) {
+		assert.Equal(t, "node/a/Ready", active[0].Key)
+		assert.True(t, active[0].FirstSeen.Equal(start))
+	}
+
+	require.NoError(t, ioutil.WriteFile(path, []byte(`{"version": 0}`), 0644))
+	_, err = Load(path)
+	assert.Error(t, err, "a file of another version should not be loaded")
+	files, err := ioutil.ReadDir(dir)
+	require.NoError(t, err)
+	assert.Equal(t, 1, len(files), "no temporary file should be left behind")
+}
@@ -0,0 +1,50 @@
+// Copyright 2019 Bloomberg Finance LP
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package types
+
+import (
+	"time"
+)
+
+// Alert severities
+const (
+	SeverityCritical = "critical"
+	SeverityWarning  = "warning"
+	SeverityInfo     = "info"
+)
+
+// Alert is a single alert raised by a poller
+type Alert struct {
+	// Key identifies the condition being alerted on, e.g. node/worker-1/Ready
+	Key string `json:"key"`
+	// Resource identifies the object the alert is about, e.g. node/worker-1
+	Resource string    `json:"resource"`
+	Severity string    `json:"severity"`
+	Message  string    `json:"message"`
+	Time     time.Time `json:"time"`
+	Resolved bool      `json:"resolved"`
+	// DetectedAfter is how long after the alerted condition last transitioned the alert was raised,
+	// zero for alerts that are not about a condition transition
+	DetectedAfter time.Duration `json:"detectedAfter,omitempty"`
+	// Owners are the ownership labels and annotations of the object alerted on, or of its namespace
+	Owners map[string]string `json:"owners,omitempty"`
+	// Mention is the Slack handle or channel of the owners, for the slack alerter to mention
+	Mention string `json:"mention,omitempty"`
+	// EscalationLevel is the level of its rule's escalation chain the alert was re-routed to, 2 or
+	// more, and zero for alerts delivered to the rule's own alerter
+	EscalationLevel int `json:"escalationLevel,omitempty"`
+	// Cluster is the name of the cluster the alert is about, empty unless k8eraid knows it
+	Cluster string `json:"cluster,omitempty"`
+}
@@ -0,0 +1,45 @@
+// Copyright 2019 Bloomberg Finance LP
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package types
+
+// AlerterOverride re-routes the alerts bound for one alerter to another
+type AlerterOverride struct {
+	// AlerterType and AlerterName are the alerter whose alerts are re-routed. An empty AlerterName
+	// matches every alerter of the type, and an empty AlerterType every alerter.
+	AlerterType string `json:"alerterType"`
+	AlerterName string `json:"alerterName"`
+	// ToAlerterType and ToAlerterName are the alerter the alerts go to instead
+	ToAlerterType string `json:"toAlerterType"`
+	ToAlerterName string `json:"toAlerterName"`
+}
+
+// ClusterAlerters are the alerter overrides of each cluster, by cluster name, so one config shared
+// by the k8eraid of several clusters pages the right rotation for each
+type ClusterAlerters map[string][]AlerterOverride
+
+// Route returns the alerter the alerts of cluster bound for alerterType and alerterName go to, the
+// first of the cluster's overrides matching them winning
+func (c ClusterAlerters) Route(cluster string, alerterType string, alerterName string) (string, string) {
+	for _, override := range c[cluster] {
+		if override.AlerterType != "" && override.AlerterType != alerterType {
+			continue
+		}
+		if override.AlerterName != "" && override.AlerterName != alerterName {
+			continue
+		}
+		return override.ToAlerterType, override.ToAlerterName
+	}
+	return alerterType, alerterName
+}
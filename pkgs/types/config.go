@@ -20,15 +20,192 @@ import (
 
 // ConfigRules represents the structure of the config file for k8eraid
 type ConfigRules struct {
-	Deployments    []DeploymentAlertSpec `json:"deployments"`
-	Pods           []PodAlertSpec        `json:"pods"`
-	Daemonsets     []DaemonsetAlertSpec  `json:"daemonsets"`
-	Nodes          []NodeAlertSpec       `json:"nodes"`
-	AlertersConfig AlertersConfig        `json:"alerters"`
+	Deployments       []DeploymentAlertSpec       `json:"deployments"`
+	Pods              []PodAlertSpec              `json:"pods"`
+	Daemonsets        []DaemonsetAlertSpec        `json:"daemonsets"`
+	Statefulsets      []StatefulsetAlertSpec      `json:"statefulsets"`
+	ReplicaSets       []ReplicaSetAlertSpec       `json:"replicaSets"`
+	Jobs              []JobAlertSpec              `json:"jobs"`
+	CronJobs          []CronJobAlertSpec          `json:"cronJobs"`
+	Nodes             []NodeAlertSpec             `json:"nodes"`
+	PVCs              []PVCAlertSpec              `json:"persistentVolumeClaims"`
+	PVs               []PVAlertSpec               `json:"persistentVolumes"`
+	StorageClasses    []StorageClassAlertSpec     `json:"storageClasses"`
+	Namespaces        []NamespaceAlertSpec        `json:"namespaces"`
+	ResourceQuotas    []ResourceQuotaAlertSpec    `json:"resourceQuotas"`
+	Services          []ServiceAlertSpec          `json:"services"`
+	Ingresses         []IngressAlertSpec          `json:"ingresses"`
+	HPAs              []HPAAlertSpec              `json:"horizontalPodAutoscalers"`
+	PDBs              []PDBAlertSpec              `json:"podDisruptionBudgets"`
+	Webhooks          []WebhookAlertSpec          `json:"webhookConfigurations"`
+	Unused            []UnusedAlertSpec           `json:"unusedResources"`
+	WorkloadResources []WorkloadResourceAlertSpec `json:"workloadResources"`
+	DeprecatedAPIs    []DeprecatedAPIAlertSpec    `json:"deprecatedApis"`
+	FieldConditions   []FieldConditionAlertSpec   `json:"fieldConditions"`
+	CSRs              []CSRAlertSpec              `json:"certificateSigningRequests"`
+	Events            []EventAlertSpec            `json:"events"`
+	ConfigObjects     []ConfigObjectAlertSpec     `json:"configObjects"`
+	APILatency        APILatencyAlertSpec         `json:"apiserverLatency"`
+	APIHealth         APIHealthAlertSpec          `json:"apiserverHealth"`
+	ControlPlane      ControlPlaneAlertSpec       `json:"controlPlane"`
+	Etcd              EtcdAlertSpec               `json:"etcd"`
+	Severities        []SeverityOverride          `json:"severities"`
+	Ownership         Ownership                   `json:"ownership"`
+	Silencing         Silencing                   `json:"silencing"`
+	ClusterAlerters   ClusterAlerters             `json:"clusterAlerters"`
+	AlertersConfig    AlertersConfig              `json:"alerters"`
+	CheckToggles
+}
+
+// CheckToggles switch whole kinds of rules off, whatever the rules themselves say. A kind left
+// unset is enabled.
+type CheckToggles struct {
+	EnableDeploymentChecks       *bool `json:"enableDeploymentChecks"`
+	EnablePodChecks              *bool `json:"enablePodChecks"`
+	EnableDaemonsetChecks        *bool `json:"enableDaemonsetChecks"`
+	EnableStatefulsetChecks      *bool `json:"enableStatefulsetChecks"`
+	EnableReplicaSetChecks       *bool `json:"enableReplicaSetChecks"`
+	EnableJobChecks              *bool `json:"enableJobChecks"`
+	EnableCronJobChecks          *bool `json:"enableCronJobChecks"`
+	EnableNodeChecks             *bool `json:"enableNodeChecks"`
+	EnablePVCChecks              *bool `json:"enablePersistentVolumeClaimChecks"`
+	EnablePVChecks               *bool `json:"enablePersistentVolumeChecks"`
+	EnableStorageClassChecks     *bool `json:"enableStorageClassChecks"`
+	EnableNamespaceChecks        *bool `json:"enableNamespaceChecks"`
+	EnableResourceQuotaChecks    *bool `json:"enableResourceQuotaChecks"`
+	EnableServiceChecks          *bool `json:"enableServiceChecks"`
+	EnableIngressChecks          *bool `json:"enableIngressChecks"`
+	EnableHPAChecks              *bool `json:"enableHorizontalPodAutoscalerChecks"`
+	EnablePDBChecks              *bool `json:"enablePodDisruptionBudgetChecks"`
+	EnableAPILatencyChecks       *bool `json:"enableApiserverLatencyChecks"`
+	EnableAPIHealthChecks        *bool `json:"enableApiserverHealthChecks"`
+	EnableControlPlaneChecks     *bool `json:"enableControlPlaneChecks"`
+	EnableEtcdChecks             *bool `json:"enableEtcdChecks"`
+	EnableWebhookChecks          *bool `json:"enableWebhookConfigurationChecks"`
+	EnableUnusedChecks           *bool `json:"enableUnusedResourceChecks"`
+	EnableWorkloadResourceChecks *bool `json:"enableWorkloadResourceChecks"`
+	EnableDeprecatedAPIChecks    *bool `json:"enableDeprecatedApiChecks"`
+	EnableFieldConditionChecks   *bool `json:"enableFieldConditionChecks"`
+	EnableCSRChecks              *bool `json:"enableCertificateSigningRequestChecks"`
+	EnableEventChecks            *bool `json:"enableEventChecks"`
+	EnableConfigObjectChecks     *bool `json:"enableConfigObjectChecks"`
+}
+
+// EnabledRules returns a copy of c without the rules of the kinds its check toggles switch off
+func (c ConfigRules) EnabledRules() ConfigRules {
+	if !enabled(c.EnableDeploymentChecks) {
+		c.Deployments = nil
+	}
+	if !enabled(c.EnablePodChecks) {
+		c.Pods = nil
+	}
+	if !enabled(c.EnableDaemonsetChecks) {
+		c.Daemonsets = nil
+	}
+	if !enabled(c.EnableStatefulsetChecks) {
+		c.Statefulsets = nil
+	}
+	if !enabled(c.EnableReplicaSetChecks) {
+		c.ReplicaSets = nil
+	}
+	if !enabled(c.EnableJobChecks) {
+		c.Jobs = nil
+	}
+	if !enabled(c.EnableCronJobChecks) {
+		c.CronJobs = nil
+	}
+	if !enabled(c.EnableNodeChecks) {
+		c.Nodes = nil
+	}
+	if !enabled(c.EnablePVCChecks) {
+		c.PVCs = nil
+	}
+	if !enabled(c.EnablePVChecks) {
+		c.PVs = nil
+	}
+	if !enabled(c.EnableStorageClassChecks) {
+		c.StorageClasses = nil
+	}
+	if !enabled(c.EnableNamespaceChecks) {
+		c.Namespaces = nil
+	}
+	if !enabled(c.EnableResourceQuotaChecks) {
+		c.ResourceQuotas = nil
+	}
+	if !enabled(c.EnableServiceChecks) {
+		c.Services = nil
+	}
+	if !enabled(c.EnableIngressChecks) {
+		c.Ingresses = nil
+	}
+	if !enabled(c.EnableHPAChecks) {
+		c.HPAs = nil
+	}
+	if !enabled(c.EnablePDBChecks) {
+		c.PDBs = nil
+	}
+	if !enabled(c.EnableWebhookChecks) {
+		c.Webhooks = nil
+	}
+	if !enabled(c.EnableUnusedChecks) {
+		c.Unused = nil
+	}
+	if !enabled(c.EnableWorkloadResourceChecks) {
+		c.WorkloadResources = nil
+	}
+	if !enabled(c.EnableDeprecatedAPIChecks) {
+		c.DeprecatedAPIs = nil
+	}
+	if !enabled(c.EnableFieldConditionChecks) {
+		c.FieldConditions = nil
+	}
+	if !enabled(c.EnableCSRChecks) {
+		c.CSRs = nil
+	}
+	if !enabled(c.EnableEventChecks) {
+		c.Events = nil
+	}
+	if !enabled(c.EnableConfigObjectChecks) {
+		c.ConfigObjects = nil
+	}
+	if !enabled(c.EnableAPILatencyChecks) {
+		c.APILatency = APILatencyAlertSpec{}
+	}
+	if !enabled(c.EnableAPIHealthChecks) {
+		c.APIHealth = APIHealthAlertSpec{}
+	}
+	if !enabled(c.EnableControlPlaneChecks) {
+		c.ControlPlane = ControlPlaneAlertSpec{}
+	}
+	if !enabled(c.EnableEtcdChecks) {
+		c.Etcd = EtcdAlertSpec{}
+	}
+	return c
+}
+
+// Policies for the objects a wildcard rule matched but fails to fetch or check, set by the rule's
+// OnObjectError. Rules without one use OnObjectErrorAbort.
+const (
+	// OnObjectErrorAbort stops the poll at the first failing object, skipping the remaining ones
+	OnObjectErrorAbort = "abort"
+	// OnObjectErrorContinue logs and counts the failure, and carries on with the remaining objects
+	OnObjectErrorContinue = "continue"
+)
+
+func enabled(toggle *bool) bool {
+	return toggle == nil || *toggle
 }
 
 // Alerter types
 
+// AlertTemplates are text/template strings rendered with the Alert for every message an alerter sends.
+// TitleTemplate replaces the alerter's subject, MessageTemplate replaces the message body.
+// Alerters without a separate title prefix the body with the title.
+type AlertTemplates struct {
+	TitleTemplate   string `json:"titleTemplate"`
+	MessageTemplate string `json:"messageTemplate"`
+}
+
 // SMTPAlerterConfig struct contains the data needed to trigger an SMTP alert
 type SMTPAlerterConfig struct {
 	Name           string `json:"name"`
@@ -38,6 +215,7 @@ type SMTPAlerterConfig struct {
 	Port           int    `json:"port"`
 	Subject        string `json:"subject"`
 	PasswordEnvVar string `json:"passwordEnvVar"`
+	AlertTemplates
 }
 
 // PDAlerterConfig struct contains the needed data for triggering a Pager Duty type alert
@@ -46,13 +224,15 @@ type PDAlerterConfig struct {
 	ServiceKeyEnvVar string `json:"serviceKeyEnvVar"`
 	ProxyServer      string `json:"proxyServer"`
 	Subject          string `json:"subject"`
+	AlertTemplates
 }
 
 // PDAlertDetails contains the needed data to put into the body of a Pager Duty type alert
 type PDAlertDetails struct {
-	Subject string    `json:"subject"`
-	Message string    `json:"message"`
-	Time    time.Time `json:"time"`
+	Subject string            `json:"subject"`
+	Message string            `json:"message"`
+	Time    time.Time         `json:"time"`
+	Owners  map[string]string `json:"owners,omitempty"`
 }
 
 // WebhookAlerterConfig struct contains the data needed to trigger an SMTP alert
@@ -61,21 +241,59 @@ type WebhookAlerterConfig struct {
 	Server      string `json:"server"`
 	ProxyServer string `json:"proxyServer"`
 	Subject     string `json:"subject"`
+	AlertTemplates
 }
 
 // WebhookAlertDetails contains the needed data to put into the body of a Webhook type alert
 type WebhookAlertDetails struct {
-	Subject string    `json:"subject"`
-	Msg     string    `json:"message"`
-	Time    time.Time `json:"time"`
+	Subject string            `json:"subject"`
+	Msg     string            `json:"message"`
+	Time    time.Time         `json:"time"`
+	Owners  map[string]string `json:"owners,omitempty"`
+}
+
+// SQLAlerterConfig struct contains the data needed to record alerts into a SQL table
+type SQLAlerterConfig struct {
+	Name          string `json:"name"`
+	Driver        string `json:"driver"`
+	DSNEnvVar     string `json:"dsnEnvVar"`
+	Table         string `json:"table"`
+	MaxOpenConns  int    `json:"maxOpenConns"`
+	BatchSize     int    `json:"batchSize"`
+	FlushInterval int64  `json:"flushInterval"`
+	AlertTemplates
+}
+
+// TelegramAlerterConfig struct contains the data needed to send a Telegram message through the Bot API
+type TelegramAlerterConfig struct {
+	Name           string `json:"name"`
+	BotTokenEnvVar string `json:"botTokenEnvVar"`
+	ChatID         string `json:"chatID"`
+	ParseMode      string `json:"parseMode"`
+	Subject        string `json:"subject"`
+	Server         string `json:"server"`
+	ProxyServer    string `json:"proxyServer"`
+	AlertTemplates
+}
+
+// CSVAlerterConfig struct contains the data needed to append alerts to a daily csv file
+type CSVAlerterConfig struct {
+	Name          string `json:"name"`
+	Directory     string `json:"directory"`
+	FilePrefix    string `json:"filePrefix"`
+	FlushInterval int64  `json:"flushInterval"`
+	AlertTemplates
 }
 
 // AlerterTypes are the actual types of alerter structs
 type AlerterTypes struct {
-	PDAlerterList      []PDAlerterConfig      `json:"pagerdutyV2"`
-	SlackAlerterList   []SlackAlerterConfig   `json:"slack"`
-	SMTPAlerterList    []SMTPAlerterConfig    `json:"smtp"`
-	WebhookAlerterList []WebhookAlerterConfig `json:"webhook"`
+	PDAlerterList       []PDAlerterConfig       `json:"pagerdutyV2"`
+	SlackAlerterList    []SlackAlerterConfig    `json:"slack"`
+	SMTPAlerterList     []SMTPAlerterConfig     `json:"smtp"`
+	WebhookAlerterList  []WebhookAlerterConfig  `json:"webhook"`
+	SQLAlerterList      []SQLAlerterConfig      `json:"sql"`
+	TelegramAlerterList []TelegramAlerterConfig `json:"telegram"`
+	CSVAlerterList      []CSVAlerterConfig      `json:"csv"`
 }
 
 // AlertersConfig is the top level struct containing alerter configuration data
@@ -83,9 +301,10 @@ type AlertersConfig struct {
 	Types AlerterTypes `json:"alerters"`
 }
 
-//SlackAlerterConfig configures a Slack Alerter
+// SlackAlerterConfig configures a Slack Alerter
 type SlackAlerterConfig struct {
 	Name        string `json:"name"`
 	WebhookURL  string `json:"webhookURL"`
 	ProxyServer string `json:"proxyServer"`
+	AlertTemplates
 }
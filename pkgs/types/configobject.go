@@ -0,0 +1,55 @@
+// Copyright 2019 Bloomberg Finance LP
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package types
+
+// Kinds of objects a config object rule checks
+const (
+	ConfigObjectConfigMap = "ConfigMap"
+	ConfigObjectSecret    = "Secret"
+)
+
+// ConfigObjectAlertStatus represents the checks to alert on for ConfigMaps and Secrets
+type ConfigObjectAlertStatus struct {
+	// Missing alerts on a named object that does not exist and, for * rules, on the objects the pods
+	// and deployments of their namespace reference without marking them optional that do not exist
+	Missing bool `json:"missing"`
+	// Empty alerts on objects without any data
+	Empty bool `json:"empty"`
+	// Changed alerts on objects whose data changed since the previous tick
+	Changed bool `json:"changed"`
+	// CertificateExpiryDays alerts on kubernetes.io/tls Secrets with a certificate that expires within
+	// this many days. Zero disables the check, which only applies to Secrets.
+	CertificateExpiryDays int64 `json:"certificateExpiryDays"`
+	// CredentialExpiryDays alerts on Secrets holding a credential that expires within this many days: the
+	// token of kubernetes.io/service-account-token Secrets, and the client certificates and tokens of the
+	// kubeconfig files under KubeconfigKeys. Zero disables the check, which only applies to Secrets.
+	CredentialExpiryDays int64 `json:"credentialExpiryDays"`
+	// KubeconfigKeys are the Secret keys holding kubeconfig files, ["kubeconfig", "config"] when unset
+	KubeconfigKeys []string `json:"kubeconfigKeys"`
+}
+
+// ConfigObjectAlertSpec represents the configuration for alerting on ConfigMaps or Secrets
+type ConfigObjectAlertSpec struct {
+	Name string `json:"name"`
+	// Kind is ConfigMap or Secret
+	Kind                  string                  `json:"kind"`
+	ConfigFilterNamespace string                  `json:"filterNamespace"`
+	ConfigFilterLabel     string                  `json:"filterLabel"`
+	AlerterType           string                  `json:"alerterType"`
+	AlerterName           string                  `json:"alerterName"`
+	ReportStatus          ConfigObjectAlertStatus `json:"reportStatus"`
+	// Escalation is the rule's escalation chain
+	Escalation []EscalationLevel `json:"escalation"`
+}
@@ -0,0 +1,32 @@
+// Copyright 2019 Bloomberg Finance LP
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package types
+
+// Control plane components a controlPlane check can watch
+const (
+	ControlPlaneScheduler         = "scheduler"
+	ControlPlaneControllerManager = "controller-manager"
+)
+
+// ControlPlaneAlertSpec represents the configuration for alerting on the health of the scheduler and
+// controller-manager
+type ControlPlaneAlertSpec struct {
+	// Components are the components checked, scheduler and controller-manager. None disables the check.
+	Components  []string `json:"components"`
+	AlerterType string   `json:"alerterType"`
+	AlerterName string   `json:"alerterName"`
+	// Escalation is the rule's escalation chain
+	Escalation []EscalationLevel `json:"escalation"`
+}
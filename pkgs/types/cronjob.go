@@ -0,0 +1,36 @@
+// Copyright 2019 Bloomberg Finance LP
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package types
+
+// CronJobAlertStatus represents the thresholds to alert on for CronJobs
+type CronJobAlertStatus struct {
+	// MaxSinceSuccess alerts on CronJobs whose last successful run completed more than this many seconds ago. Zero disables the check.
+	MaxSinceSuccess int64 `json:"maxSinceSuccess"`
+	// Suspended alerts on CronJobs that are suspended, and so schedule no runs
+	Suspended bool `json:"suspended"`
+	// ConsecutiveFailures alerts on CronJobs whose latest this many finished runs all failed. Zero disables the check.
+	ConsecutiveFailures int `json:"consecutiveFailures"`
+}
+
+// CronJobAlertSpec represents a single configuration for monitoring a CronJob
+type CronJobAlertSpec struct {
+	Name          string             `json:"name"`
+	CronJobFilter string             `json:"filter"`
+	AlerterType   string             `json:"alerterType"`
+	AlerterName   string             `json:"alerterName"`
+	ReportStatus  CronJobAlertStatus `json:"reportStatus"`
+	// Escalation is the rule's escalation chain
+	Escalation []EscalationLevel `json:"escalation"`
+}
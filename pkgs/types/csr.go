@@ -0,0 +1,38 @@
+// Copyright 2019 Bloomberg Finance LP
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package types
+
+// CSRAlertStatus represents the thresholds for alerting on pending CertificateSigningRequests
+type CSRAlertStatus struct {
+	// MaxPending alerts when more CSRs than this are pending. Zero disables the check.
+	MaxPending int `json:"maxPending"`
+	// PendingThreshold alerts on every CSR pending for longer than this many seconds. Zero disables the check.
+	PendingThreshold int64 `json:"pendingThreshold"`
+}
+
+// CSRAlertSpec represents the configuration for alerting on a backlog of CertificateSigningRequests
+// that are neither approved nor denied
+type CSRAlertSpec struct {
+	// SignerName limits the rule to the CSRs of one signer, such as kubernetes.io/kubelet-serving. Clusters
+	// older than 1.18 do not record signers, so their CSRs only match rules without one.
+	SignerName string `json:"signerName"`
+	// Version of the certificates.k8s.io API read, v1beta1 when unset. Clusters from 1.22 on only serve v1.
+	Version      string         `json:"version"`
+	AlerterType  string         `json:"alerterType"`
+	AlerterName  string         `json:"alerterName"`
+	ReportStatus CSRAlertStatus `json:"reportStatus"`
+	// Escalation is the rule's escalation chain
+	Escalation []EscalationLevel `json:"escalation"`
+}
@@ -19,6 +19,10 @@ type DaemonsetAlertStatus struct {
 	FailedScheduling bool  `json:"failedScheduling"`
 	CheckReplicas    bool  `json:"checkReplicas"`
 	PendingThreshold int64 `json:"pendingThreshold"`
+	ReportDiff       bool  `json:"reportDiff"`
+	// CoverageThreshold alerts on DaemonSets that have been missing ready pods on some of their nodes, or running
+	// pods on nodes they should not, for longer than this many seconds. Zero disables the check.
+	CoverageThreshold int64 `json:"coverageThreshold"`
 }
 
 // DaemonsetAlertSpec represents a single configuration for monitoring a DaemonSet
@@ -28,4 +32,8 @@ type DaemonsetAlertSpec struct {
 	AlerterType  string               `json:"alerterType"`
 	AlerterName  string               `json:"alerterName"`
 	ReportStatus DaemonsetAlertStatus `json:"reportStatus"`
+	// OnObjectError is the rule's OnObjectError policy
+	OnObjectError string `json:"onObjectError"`
+	// Escalation is the rule's escalation chain
+	Escalation []EscalationLevel `json:"escalation"`
 }
@@ -18,6 +18,54 @@ package types
 type DeploymentAlertStatus struct {
 	MinReplicas      int32 `json:"minReplicas"`
 	PendingThreshold int64 `json:"pendingThreshold"`
+	ReportDiff       bool  `json:"reportDiff"`
+	// AvailableTrend alerts on a sustained decline of available replicas
+	AvailableTrend TrendCheck `json:"availableTrend"`
+	// OOMKills alerts when the Deployment's containers are OOMKilled too often
+	OOMKills OOMKillCheck `json:"oomKills"`
+	// ReplicaSets alerts on ReplicaSets left behind by stuck or rolled back rollouts
+	ReplicaSets ReplicaSetCheck `json:"replicaSets"`
+	// PodSpread alerts when the Deployment's ready pods are concentrated on one node or zone
+	PodSpread PodSpreadCheck `json:"podSpread"`
+	// PausedThreshold alerts on Deployments whose rollout has been paused for longer than this many seconds. Zero disables the check.
+	PausedThreshold int64 `json:"pausedThreshold"`
+	// ProgressDeadlineExceeded alerts on Deployments whose rollout the controller gave up on past its progressDeadlineSeconds
+	ProgressDeadlineExceeded bool `json:"progressDeadlineExceeded"`
+	// UpdateLagThreshold alerts on Deployments with fewer updated replicas than they want for longer than this many seconds. Zero disables the check.
+	UpdateLagThreshold int64 `json:"updateLagThreshold"`
+	// ImagePolicy alerts on Deployments whose pod template runs images the policy disallows
+	ImagePolicy ImagePolicy `json:"imagePolicy"`
+}
+
+// PodSpreadCheck represents how much of a workload's ready pods a single node or zone may run
+type PodSpreadCheck struct {
+	// AntiAffinity alerts when a node, zone or other topology domain runs several ready pods the pod
+	// anti-affinity of the workload's template keeps apart from each other
+	AntiAffinity bool `json:"antiAffinity"`
+	// MaxNodePercent is the share of ready pods one node may run, in percent. Zero disables the node check.
+	MaxNodePercent int `json:"maxNodePercent"`
+	// MaxZonePercent is the share of ready pods one zone may run, in percent. Zero disables the zone check.
+	MaxZonePercent int `json:"maxZonePercent"`
+	// ZoneLabel is the node label holding the zone, failure-domain.beta.kubernetes.io/zone when unset
+	ZoneLabel string `json:"zoneLabel"`
+	// MinPods is the number of ready pods below which the spread is not checked, 2 when unset
+	MinPods int `json:"minPods"`
+}
+
+// ReplicaSetCheck represents how many ReplicaSets a Deployment may own
+type ReplicaSetCheck struct {
+	// MaxActive is the number of ReplicaSets with replicas above which a rollout is considered stuck. Zero disables the check.
+	MaxActive int `json:"maxActive"`
+	// MaxRevisions is the number of ReplicaSets, scaled down or not, above which revision history is excessive. Zero disables the check.
+	MaxRevisions int `json:"maxRevisions"`
+}
+
+// OOMKillCheck represents how many OOMKilled containers across a Deployment's pods are tolerated
+type OOMKillCheck struct {
+	// Threshold is the number of OOM kills within Window that triggers an alert. Zero disables the check.
+	Threshold int `json:"threshold"`
+	// Window is the number of seconds OOM kills are counted over, 3600 when unset
+	Window int64 `json:"window"`
 }
 
 // DeploymentAlertSpec represents a Deployment Alert Rule
@@ -27,4 +75,8 @@ type DeploymentAlertSpec struct {
 	AlerterType  string                `json:"alerterType"`
 	AlerterName  string                `json:"alerterName"`
 	ReportStatus DeploymentAlertStatus `json:"reportStatus"`
+	// OnObjectError is the rule's OnObjectError policy
+	OnObjectError string `json:"onObjectError"`
+	// Escalation is the rule's escalation chain
+	Escalation []EscalationLevel `json:"escalation"`
 }
@@ -0,0 +1,38 @@
+// Copyright 2019 Bloomberg Finance LP
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package types
+
+// DeprecatedAPIAlertStatus represents which uses of deprecated API versions to alert on
+type DeprecatedAPIAlertStatus struct {
+	// Objects alerts on objects last applied with a deprecated API version, as the last-applied-configuration annotation of kubectl records it
+	Objects bool `json:"objects"`
+	// Requested alerts on the deprecated API versions clients requested since the apiserver started, as its
+	// apiserver_requested_deprecated_apis metric reports them. Apiservers older than 1.19 have no such metric.
+	Requested bool `json:"requested"`
+	// TargetVersion only alerts on API versions removed in this Kubernetes version or earlier, such as v1.22. Empty alerts on all of them.
+	TargetVersion string `json:"targetVersion"`
+}
+
+// DeprecatedAPIAlertSpec represents the configuration for alerting on deprecated API versions still in use ahead of cluster upgrades
+type DeprecatedAPIAlertSpec struct {
+	// DeprecatedFilterNamespace limits the objects checked to one namespace, leaving cluster-scoped objects out
+	DeprecatedFilterNamespace string                   `json:"filterNamespace"`
+	DeprecatedFilterLabel     string                   `json:"filterLabel"`
+	AlerterType               string                   `json:"alerterType"`
+	AlerterName               string                   `json:"alerterName"`
+	ReportStatus              DeprecatedAPIAlertStatus `json:"reportStatus"`
+	// Escalation is the rule's escalation chain
+	Escalation []EscalationLevel `json:"escalation"`
+}
@@ -0,0 +1,25 @@
+// Copyright 2019 Bloomberg Finance LP
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package types
+
+// EscalationLevel re-routes the critical alerts of a rule to another alerter once they have been
+// firing for AfterSeconds without being acknowledged. The rule's own alerter is level 1, and the
+// levels of its escalation chain are 2, 3 and so on. An alert only moves up the chain when its check
+// raises it again, so checks alerting once on a transition, such as node Ready, never escalate.
+type EscalationLevel struct {
+	AfterSeconds int64  `json:"afterSeconds"`
+	AlerterType  string `json:"alerterType"`
+	AlerterName  string `json:"alerterName"`
+}
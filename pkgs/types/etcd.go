@@ -0,0 +1,28 @@
+// Copyright 2019 Bloomberg Finance LP
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package types
+
+// EtcdAlertSpec represents the configuration for alerting on the health of etcd, as the apiserver sees it
+type EtcdAlertSpec struct {
+	// Endpoint is the health endpoint of the apiserver checking etcd, /readyz/etcd, or /healthz/etcd before
+	// Kubernetes 1.16. Empty disables the check.
+	Endpoint string `json:"endpoint"`
+	// ThresholdSeconds is the latency of the endpoint above which to alert. Zero disables the latency check.
+	ThresholdSeconds float64 `json:"thresholdSeconds"`
+	AlerterType      string  `json:"alerterType"`
+	AlerterName      string  `json:"alerterName"`
+	// Escalation is the rule's escalation chain
+	Escalation []EscalationLevel `json:"escalation"`
+}
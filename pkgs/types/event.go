@@ -0,0 +1,43 @@
+// Copyright 2019 Bloomberg Finance LP
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package types
+
+// EventAlertStatus represents the thresholds to alert on for Events
+type EventAlertStatus struct {
+	// Threshold alerts on an object once this many matching events were recorded for it with the
+	// same reason within a tick. It defaults to 1.
+	Threshold int `json:"threshold"`
+	// GroupByWorkload counts the events of pods for the workload that controls them, such as their
+	// Deployment, instead of for each pod, so that e.g. failed probes spread across replicas add up
+	GroupByWorkload bool `json:"groupByWorkload"`
+}
+
+// EventAlertSpec represents the configuration for alerting on the Events recorded for objects
+type EventAlertSpec struct {
+	// Name is the involved object whose events are counted, or * for every object
+	Name                 string `json:"name"`
+	EventFilterNamespace string `json:"filterNamespace"`
+	// EventFilterKind only counts the events of objects of this kind, such as Pod
+	EventFilterKind string `json:"filterKind"`
+	// EventFilterType only counts events of this type, Warning when unset
+	EventFilterType string `json:"filterType"`
+	// EventFilterReasons only counts events with one of these reasons, any reason when empty
+	EventFilterReasons []string         `json:"filterReasons"`
+	AlerterType        string           `json:"alerterType"`
+	AlerterName        string           `json:"alerterName"`
+	ReportStatus       EventAlertStatus `json:"reportStatus"`
+	// Escalation is the rule's escalation chain
+	Escalation []EscalationLevel `json:"escalation"`
+}
@@ -0,0 +1,98 @@
+// Copyright 2019 Bloomberg Finance LP
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package types
+
+import (
+	"strings"
+
+	"k8s.io/client-go/util/jsonpath"
+)
+
+// Operators comparing the field of a FieldCondition with its value
+const (
+	OperatorEqual          = "=="
+	OperatorNotEqual       = "!="
+	OperatorLess           = "<"
+	OperatorLessOrEqual    = "<="
+	OperatorGreater        = ">"
+	OperatorGreaterOrEqual = ">="
+)
+
+// ValidOperator reports whether operator is one of the known field condition operators
+func ValidOperator(operator string) bool {
+	switch operator {
+	case OperatorEqual, OperatorNotEqual, OperatorLess, OperatorLessOrEqual, OperatorGreater, OperatorGreaterOrEqual:
+		return true
+	}
+	return false
+}
+
+// FieldCondition compares a field of an object, selected by a JSONPath expression, with a literal
+// value or with another field of the same object
+type FieldCondition struct {
+	// JSONPath selects the field, such as {.spec.replicas}. The braces and the leading dot may be left out.
+	JSONPath string `json:"jsonPath"`
+	// Operator is one of ==, !=, <, <=, > and >=
+	Operator string `json:"operator"`
+	// Value is the literal the field is compared with. Fields and values that both read as numbers or
+	// quantities compare as numbers, anything else compares as text.
+	Value string `json:"value"`
+	// CompareJSONPath selects the field of the object compared with instead of Value
+	CompareJSONPath string `json:"compareJsonPath"`
+	// MissingValue stands in for a field the object does not have. Objects missing a field are not
+	// alerted on when unset. Kubernetes leaves zero counts such as status.readyReplicas out of objects.
+	MissingValue *string `json:"missingValue"`
+}
+
+// FieldConditionAlertSpec represents the configuration for alerting on objects of any resource, custom
+// resources included, whose fields match a condition
+type FieldConditionAlertSpec struct {
+	// Name is the object checked, or * for every object matching the filters
+	Name                 string         `json:"name"`
+	Group                string         `json:"group"`
+	Version              string         `json:"version"`
+	Resource             string         `json:"resource"`
+	FieldFilterNamespace string         `json:"filterNamespace"`
+	FieldFilterLabel     string         `json:"filterLabel"`
+	Condition            FieldCondition `json:"condition"`
+	// Check names the check in alert keys and severity overrides, FieldCondition when unset
+	Check string `json:"check"`
+	// Severity of the alerts, warning when unset
+	Severity    string `json:"severity"`
+	AlerterType string `json:"alerterType"`
+	AlerterName string `json:"alerterName"`
+	// Escalation is the rule's escalation chain
+	Escalation []EscalationLevel `json:"escalation"`
+}
+
+// NormalizeJSONPath turns a bare path such as spec.replicas into the {.spec.replicas} template the
+// JSONPath evaluator expects. Expressions already in braces are returned as they are.
+func NormalizeJSONPath(expression string) string {
+	expression = strings.TrimSpace(expression)
+	if strings.Contains(expression, "{") {
+		return expression
+	}
+	return "{." + strings.TrimPrefix(expression, ".") + "}"
+}
+
+// ParseJSONPath parses expression, normalized first, for evaluation against objects. Missing keys
+// evaluate to no result.
+func ParseJSONPath(expression string) (*jsonpath.JSONPath, error) {
+	parser := jsonpath.New("condition").AllowMissingKeys(true)
+	if err := parser.Parse(NormalizeJSONPath(expression)); err != nil {
+		return nil, err
+	}
+	return parser, nil
+}
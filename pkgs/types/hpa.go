@@ -0,0 +1,37 @@
+// Copyright 2019 Bloomberg Finance LP
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package types
+
+// HPAAlertStatus represents the thresholds to alert on for HorizontalPodAutoscalers
+type HPAAlertStatus struct {
+	// MaxReplicasThreshold alerts on autoscalers that have been at their maxReplicas for longer than this many seconds. Zero disables the check.
+	MaxReplicasThreshold int64 `json:"maxReplicasThreshold"`
+	// ScalingLimited alerts on autoscalers that want more replicas than their maxReplicas allows
+	ScalingLimited bool `json:"scalingLimited"`
+	// FailedGetMetrics alerts on autoscalers that cannot fetch the metrics they scale on, or the scale of their target
+	FailedGetMetrics bool `json:"failedGetMetrics"`
+}
+
+// HPAAlertSpec represents the configuration for alerting on HorizontalPodAutoscalers
+type HPAAlertSpec struct {
+	Name               string         `json:"name"`
+	HPAFilterNamespace string         `json:"filterNamespace"`
+	HPAFilterLabel     string         `json:"filterLabel"`
+	AlerterType        string         `json:"alerterType"`
+	AlerterName        string         `json:"alerterName"`
+	ReportStatus       HPAAlertStatus `json:"reportStatus"`
+	// Escalation is the rule's escalation chain
+	Escalation []EscalationLevel `json:"escalation"`
+}
@@ -0,0 +1,36 @@
+// Copyright 2019 Bloomberg Finance LP
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package types
+
+// JobAlertStatus represents the thresholds to alert on for batch Jobs
+type JobAlertStatus struct {
+	// BackoffLimitExceeded alerts on Jobs that failed because their pods failed more often than their backoffLimit allows
+	BackoffLimitExceeded bool `json:"backoffLimitExceeded"`
+	// CompletionDeadline alerts on Jobs that have not completed this many seconds after they started. Zero disables the check.
+	CompletionDeadline int64 `json:"completionDeadline"`
+	// FailedPods alerts on Jobs with at least this many failed pods. Zero disables the check.
+	FailedPods int32 `json:"failedPods"`
+}
+
+// JobAlertSpec represents a single configuration for monitoring a batch Job
+type JobAlertSpec struct {
+	Name         string         `json:"name"`
+	JobFilter    string         `json:"filter"`
+	AlerterType  string         `json:"alerterType"`
+	AlerterName  string         `json:"alerterName"`
+	ReportStatus JobAlertStatus `json:"reportStatus"`
+	// Escalation is the rule's escalation chain
+	Escalation []EscalationLevel `json:"escalation"`
+}
@@ -0,0 +1,39 @@
+// Copyright 2019 Bloomberg Finance LP
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package types
+
+// APILatencyAlertSpec represents the configuration for alerting on slow apiserver calls made by the pollers
+type APILatencyAlertSpec struct {
+	// ThresholdSeconds is the mean call latency above which to alert. Zero disables the check.
+	ThresholdSeconds float64 `json:"thresholdSeconds"`
+	// Window is the number of seconds of calls the mean is taken over, 300 when unset
+	Window      int64  `json:"window"`
+	AlerterType string `json:"alerterType"`
+	AlerterName string `json:"alerterName"`
+	// Escalation is the rule's escalation chain
+	Escalation []EscalationLevel `json:"escalation"`
+}
+
+// APIHealthAlertSpec represents the configuration for alerting on the health endpoints of the apiserver
+type APIHealthAlertSpec struct {
+	// Endpoints are the paths checked, such as /healthz, /livez and /readyz. None disables the check.
+	Endpoints []string `json:"endpoints"`
+	// ThresholdSeconds is the latency of an endpoint above which to alert. Zero disables the latency check.
+	ThresholdSeconds float64 `json:"thresholdSeconds"`
+	AlerterType      string  `json:"alerterType"`
+	AlerterName      string  `json:"alerterName"`
+	// Escalation is the rule's escalation chain
+	Escalation []EscalationLevel `json:"escalation"`
+}
@@ -0,0 +1,34 @@
+// Copyright 2019 Bloomberg Finance LP
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package types
+
+// NamespaceAlertStatus represents the thresholds to alert on for Namespaces
+type NamespaceAlertStatus struct {
+	// TerminatingThreshold alerts on namespaces that have been Terminating for longer than this many seconds. Zero disables the check.
+	TerminatingThreshold int64 `json:"terminatingThreshold"`
+	// NetworkPolicies alerts on namespaces that run pods but contain no NetworkPolicy, so none of their pods is isolated
+	NetworkPolicies bool `json:"networkPolicies"`
+}
+
+// NamespaceAlertSpec represents the configuration for alerting on Namespaces
+type NamespaceAlertSpec struct {
+	Name            string               `json:"name"`
+	NamespaceFilter string               `json:"filter"`
+	AlerterType     string               `json:"alerterType"`
+	AlerterName     string               `json:"alerterName"`
+	ReportStatus    NamespaceAlertStatus `json:"reportStatus"`
+	// Escalation is the rule's escalation chain
+	Escalation []EscalationLevel `json:"escalation"`
+}
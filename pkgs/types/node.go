@@ -14,6 +14,179 @@
 
 package types
 
+import (
+	"regexp"
+	"strconv"
+)
+
+// NodeConditionMatch matches a node condition by its reason or message, regardless of its status
+type NodeConditionMatch struct {
+	Type            string `json:"type"`
+	ReasonContains  string `json:"reasonContains"`
+	MessageContains string `json:"messageContains"`
+}
+
+// NodeTaintMatch matches the taints of a node that must not stay on it for long
+type NodeTaintMatch struct {
+	// Key is the taint key, or a glob pattern of keys such as maintenance.example.com/*
+	Key string `json:"key"`
+	// Value and Effect narrow the match when set
+	Value  string `json:"value"`
+	Effect string `json:"effect"`
+	// ThresholdSeconds is how long a node may carry a matching taint before it alerts
+	ThresholdSeconds int64 `json:"thresholdSeconds"`
+	// Severity of the alert, warning when unset
+	Severity string `json:"severity"`
+}
+
+// NodeTerminationNotice matches the taint or condition a termination handler, such as
+// aws-node-termination-handler, puts on a node about to be reclaimed
+type NodeTerminationNotice struct {
+	// Taint is the taint key, or a glob pattern of keys such as aws-node-termination-handler/*
+	Taint string `json:"taint"`
+	// Condition is a condition type that is True while the notice stands, used when Taint is empty
+	Condition string `json:"condition"`
+	// NoticeSeconds is how long before the termination the notice is given, e.g. 120 for AWS spot
+	// interruptions, so the alert can tell the time left. Zero leaves it out.
+	NoticeSeconds int64 `json:"noticeSeconds"`
+	// Severity of the alert, critical when unset
+	Severity string `json:"severity"`
+}
+
+// NodeVersionDrift lists the node versions that must agree across the matched nodes. Each field is
+// either empty to skip it, "majority" to expect the most common value among the matched nodes, or
+// the exact value expected.
+type NodeVersionDrift struct {
+	KernelVersion           string `json:"kernelVersion"`
+	OSImage                 string `json:"osImage"`
+	ContainerRuntimeVersion string `json:"containerRuntimeVersion"`
+}
+
+// NodeKubeletSkew is how far the kubelet version of a node may be from the apiserver version
+type NodeKubeletSkew struct {
+	// MaxMinorVersions is how many minor versions a kubelet may be behind the apiserver. Zero disables
+	// the comparison, and kubelets newer than the apiserver always alert when it is set.
+	MaxMinorVersions int `json:"maxMinorVersions"`
+	// MinVersion is the oldest kubelet version allowed, e.g. v1.12.5. Empty disables the comparison.
+	MinVersion string `json:"minVersion"`
+}
+
+// kubernetesVersionPattern matches the major, minor and patch versions at the start of a Kubernetes
+// git version, such as v1.13.4-gke.10
+var kubernetesVersionPattern = regexp.MustCompile(`^v?(\d+)\.(\d+)(?:\.(\d+))?`)
+
+// KubernetesVersion is the numeric part of a Kubernetes git version
+type KubernetesVersion struct {
+	Major, Minor, Patch int
+}
+
+// ParseKubernetesVersion reads the major, minor and patch versions of gitVersion
+func ParseKubernetesVersion(gitVersion string) (KubernetesVersion, bool) {
+	match := kubernetesVersionPattern.FindStringSubmatch(gitVersion)
+	if match == nil {
+		return KubernetesVersion{}, false
+	}
+	var parsed KubernetesVersion
+	parsed.Major, _ = strconv.Atoi(match[1])
+	parsed.Minor, _ = strconv.Atoi(match[2])
+	if match[3] != "" {
+		parsed.Patch, _ = strconv.Atoi(match[3])
+	}
+	return parsed, true
+}
+
+// Less tells whether v is an older version than other
+func (v KubernetesVersion) Less(other KubernetesVersion) bool {
+	if v.Major != other.Major {
+		return v.Major < other.Major
+	}
+	if v.Minor != other.Minor {
+		return v.Minor < other.Minor
+	}
+	return v.Patch < other.Patch
+}
+
+// NodePoolSize is how many instances of the cloud provider are expected to have joined as the matched nodes
+type NodePoolSize struct {
+	// Count is the number of instances expected. Zero disables the check unless Provider is set.
+	Count int `json:"count"`
+	// Provider names a registered instance provider listing the expected instances instead of Count
+	Provider string `json:"provider"`
+	// Pool identifies the instance group to the provider, e.g. the name of an autoscaling group
+	Pool string `json:"pool"`
+}
+
+// NodeGroupBalance groups the matched nodes by the value of a label, e.g. their zone, and checks
+// every group keeps enough usable nodes
+type NodeGroupBalance struct {
+	// Label is the node label to group by, failure-domain.beta.kubernetes.io/zone when unset
+	Label string `json:"label"`
+	// Groups lists label values expected to have nodes, so a group whose nodes are all gone is still checked
+	Groups []string `json:"groups"`
+	// MinPerGroup is the number of Ready, schedulable nodes every group needs. Zero disables the check.
+	MinPerGroup int `json:"minPerGroup"`
+	// MaxRatio is how many times more usable nodes the largest group may have than the smallest. Zero disables the check.
+	MaxRatio float64 `json:"maxRatio"`
+}
+
+// NodeCapacityType classifies nodes by the value of a capacity type label, e.g. karpenter.sh/capacity-type
+type NodeCapacityType struct {
+	// Label is the node label holding the capacity type. Empty disables the classification.
+	Label string `json:"label"`
+	// Interruptible lists the values, matched case insensitively, of nodes expected to be
+	// reclaimed at any time. It is ["spot"] when unset.
+	Interruptible []string `json:"interruptible"`
+	// InterruptibleReadySeverity replaces the severity of Ready alerts on interruptible nodes, e.g. info
+	InterruptibleReadySeverity string `json:"interruptibleReadySeverity"`
+	// SuppressInterruptibleReady drops Ready alerts on interruptible nodes altogether
+	SuppressInterruptibleReady bool `json:"suppressInterruptibleReady"`
+}
+
+// Clock skew references the clock of a node is compared with
+const (
+	ClockSkewReferenceNodes   = "nodes"
+	ClockSkewReferenceMonitor = "monitor"
+)
+
+// NodeClockSkew is how far the clock of a node may be from the clocks of the other matched nodes, or from k8eraid's
+type NodeClockSkew struct {
+	// ThresholdSeconds is the estimated offset above which a node alerts. Zero disables the check.
+	ThresholdSeconds int64 `json:"thresholdSeconds"`
+	// MinNodes is the number of nodes with a recent heartbeat needed to estimate offsets, 3 when unset.
+	// It only applies to the nodes reference.
+	MinNodes int `json:"minNodes"`
+	// Reference is what node clocks are compared with, nodes for the median of the matched nodes or
+	// monitor for the clock of k8eraid. Nodes when unset.
+	Reference string `json:"reference"`
+}
+
+// NodePressureForecast alerts before a node reaches MemoryPressure or DiskPressure, by extrapolating the
+// trend of its available memory and root filesystem space
+type NodePressureForecast struct {
+	// Window is the number of seconds of samples the trend is fitted over. Zero disables the check.
+	Window int64 `json:"window"`
+	// HorizonSeconds is how far ahead pressure is projected, alerting when it would start sooner
+	HorizonSeconds int64 `json:"horizonSeconds"`
+	// MemoryAvailableBytes is the available memory below which the node is under pressure,
+	// the kubelet's default eviction threshold of 100Mi when unset
+	MemoryAvailableBytes uint64 `json:"memoryAvailableBytes"`
+	// NodeFsAvailablePercent is the available share of the root filesystem below which the node is
+	// under pressure, the kubelet's default eviction threshold of 10% when unset
+	NodeFsAvailablePercent float64 `json:"nodefsAvailablePercent"`
+	// MinSamples is the number of samples needed before the trend is evaluated, 3 when unset
+	MinSamples int `json:"minSamples"`
+}
+
+// NodeUtilization is how much of its allocatable CPU and memory a node may use, as metrics-server measures it
+type NodeUtilization struct {
+	// WarningPercent and CriticalPercent are the shares of allocatable at or above which a node
+	// alerts with that severity. Zero disables a tier.
+	WarningPercent  float64 `json:"warningPercent"`
+	CriticalPercent float64 `json:"criticalPercent"`
+	// Resources lists the resources compared, cpu and memory when unset
+	Resources []string `json:"resources"`
+}
+
 // NodeAlertStatus represents the thresholds to alert on for Nodes
 type NodeAlertStatus struct {
 	PendingThreshold   int64 `json:"pendingThreshold"`
@@ -21,7 +194,47 @@ type NodeAlertStatus struct {
 	NodeMemoryPressure bool  `json:"memoryPressure"`
 	NodeDiskPressure   bool  `json:"diskPressure"`
 	NodeReady          bool  `json:"readiness"`
-	MinNodes           int32 `json:"minNodes"`
+	// NodePIDPressure and NodeNetworkUnavailable alert on changes of those conditions like the ones above
+	NodePIDPressure        bool  `json:"pidPressure"`
+	NodeNetworkUnavailable bool  `json:"networkUnavailable"`
+	MinNodes               int32 `json:"minNodes"`
+	// MaxNodes is the number of listed nodes above which the rule alerts, e.g. on runaway autoscaling. Zero disables the check.
+	MaxNodes int32 `json:"maxNodes"`
+	// CustomConditions lists other condition types, such as the KernelDeadlock node-problem-detector
+	// sets, that alert when they change
+	CustomConditions []string `json:"customConditions"`
+	// MinNodesReadyOnly only counts Ready, schedulable nodes toward MinNodes
+	MinNodesReadyOnly bool `json:"minNodesReadyOnly"`
+	ReportDiff        bool `json:"reportDiff"`
+	// ConditionMatches alert whenever a condition carries a matching reason or message
+	ConditionMatches []NodeConditionMatch `json:"conditionMatches"`
+	// VersionDrift alerts on nodes whose kernel, OS image or container runtime differs from the rest
+	VersionDrift NodeVersionDrift `json:"versionDrift"`
+	// KubeletSkew alerts on nodes whose kubelet is too old, or too far from the apiserver version
+	KubeletSkew NodeKubeletSkew `json:"kubeletSkew"`
+	// ExpectedPoolSize alerts when fewer nodes joined than the cloud provider runs instances for
+	ExpectedPoolSize NodePoolSize `json:"expectedPoolSize"`
+	// GroupBalance alerts when a group of nodes, e.g. a zone, is lost, too small, or out of balance with the others
+	GroupBalance NodeGroupBalance `json:"groupBalance"`
+	// ConditionPendingThresholds overrides PendingThreshold for the conditions of the given types,
+	// e.g. to give MemoryPressure longer than Ready to settle on a node that just joined
+	ConditionPendingThresholds map[string]int64 `json:"conditionPendingThresholds"`
+	// CapacityType tells interruptible nodes, e.g. spot instances, apart so their expected churn is alerted on differently
+	CapacityType NodeCapacityType `json:"capacityType"`
+	// ClockSkew alerts on nodes whose clock is off compared to the rest of the matched nodes
+	ClockSkew NodeClockSkew `json:"clockSkew"`
+	// PressureForecast alerts when a node is projected to come under memory or disk pressure soon
+	PressureForecast NodePressureForecast `json:"pressureForecast"`
+	// CordonedThreshold alerts on nodes cordoned for longer than this many seconds. Zero disables the check.
+	CordonedThreshold int64 `json:"cordonedThreshold"`
+	// Taints alert on nodes carrying a matching taint for longer than its threshold
+	Taints []NodeTaintMatch `json:"taints"`
+	// LeaseThreshold alerts on nodes whose kubelet has not renewed its Lease for this many seconds. Zero disables the check.
+	LeaseThreshold int64 `json:"leaseThreshold"`
+	// TerminationNotices alert as soon as a node carries a matching termination notice, before it disappears
+	TerminationNotices []NodeTerminationNotice `json:"terminationNotices"`
+	// Utilization alerts on nodes using a large share of their allocatable CPU or memory
+	Utilization NodeUtilization `json:"utilization"`
 }
 
 // NodeAlertSpec represents the configuration for alerting on Node issues
@@ -31,4 +244,8 @@ type NodeAlertSpec struct {
 	AlerterType  string          `json:"alerterType"`
 	AlerterName  string          `json:"alerterName"`
 	ReportStatus NodeAlertStatus `json:"reportStatus"`
+	// OnObjectError is the rule's OnObjectError policy
+	OnObjectError string `json:"onObjectError"`
+	// Escalation is the rule's escalation chain
+	Escalation []EscalationLevel `json:"escalation"`
 }
@@ -0,0 +1,25 @@
+// Copyright 2019 Bloomberg Finance LP
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package types
+
+// Ownership names the labels and annotations that tell who owns the objects alerted on
+type Ownership struct {
+	// Keys are the label and annotation keys added to every alert, e.g. team or owner. A key set on
+	// the object alerted on wins over the same key on its namespace, and labels win over annotations.
+	Keys []string `json:"keys"`
+	// SlackMentionKey is the key, looked up like Keys, whose value is the Slack handle or channel the
+	// slack alerter mentions, e.g. slack-channel
+	SlackMentionKey string `json:"slackMentionKey"`
+}
@@ -0,0 +1,35 @@
+// Copyright 2019 Bloomberg Finance LP
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package types
+
+// PDBAlertStatus represents the thresholds to alert on for PodDisruptionBudgets
+type PDBAlertStatus struct {
+	// Unhealthy alerts on budgets with fewer healthy pods than they want, which already allow no disruption
+	Unhealthy bool `json:"unhealthy"`
+	// NoDisruptionsThreshold alerts on budgets that have allowed no disruptions for longer than this many seconds. Zero disables the check.
+	NoDisruptionsThreshold int64 `json:"noDisruptionsThreshold"`
+}
+
+// PDBAlertSpec represents the configuration for alerting on PodDisruptionBudgets
+type PDBAlertSpec struct {
+	Name               string         `json:"name"`
+	PDBFilterNamespace string         `json:"filterNamespace"`
+	PDBFilterLabel     string         `json:"filterLabel"`
+	AlerterType        string         `json:"alerterType"`
+	AlerterName        string         `json:"alerterName"`
+	ReportStatus       PDBAlertStatus `json:"reportStatus"`
+	// Escalation is the rule's escalation chain
+	Escalation []EscalationLevel `json:"escalation"`
+}
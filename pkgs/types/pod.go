@@ -21,6 +21,74 @@ type PodAlertStatus struct {
 	FailedScheduling bool  `json:"failedScheduling"`
 	PendingThreshold int64 `json:"pendingThreshold"`
 	StuckTerminating bool  `json:"stuckTerminating"`
+	ReportDiff       bool  `json:"reportDiff"`
+	// GRPCHealth actively probes the gRPC health service of the matched pods
+	GRPCHealth GRPCHealthCheck `json:"grpcHealth"`
+	// NodeLost alerts on pods still bound to a node that was lost or no longer exists
+	NodeLost bool `json:"nodeLost"`
+	// MissingLimits alerts on pods with containers that set no CPU or memory limit
+	MissingLimits bool `json:"missingLimits"`
+	// LimitRangeViolations alerts on pods whose containers fall outside the container limits of a LimitRange of their namespace
+	LimitRangeViolations bool `json:"limitRangeViolations"`
+	// RestartThreshold alerts on pods with a container restarted more than this many times. Zero disables the check.
+	RestartThreshold int32 `json:"restartThreshold"`
+	// RestartIncrease alerts on pods with a container restarted more than this many times within RestartWindow. Zero disables the check.
+	RestartIncrease int32 `json:"restartIncrease"`
+	// RestartWindow is the window of RestartIncrease in seconds, 3600 when unset
+	RestartWindow int64 `json:"restartWindow"`
+	// OOMKilled alerts on pods with a container OOMKilled since the previous poll
+	OOMKilled bool `json:"oomKilled"`
+	// CrashLoopThreshold alerts on pods with a container crash looping for longer than this many seconds. Zero disables the check.
+	CrashLoopThreshold int64 `json:"crashLoopThreshold"`
+	// ImagePullErrors alerts on pods older than PendingThreshold with a container whose image cannot be pulled
+	ImagePullErrors bool `json:"imagePullErrors"`
+	// UnschedulableThreshold alerts on pods the scheduler has found no node for for longer than this many seconds, with its reason. Zero disables the check.
+	UnschedulableThreshold int64 `json:"unschedulableThreshold"`
+	// TerminatingThreshold alerts on pods still present this many seconds after their deletion deadline passed. Zero disables the check.
+	TerminatingThreshold int64 `json:"terminatingThreshold"`
+	// EvictedThreshold alerts on namespaces with more than this many evicted pods matching a wildcard rule. Zero disables the check.
+	EvictedThreshold int `json:"evictedThreshold"`
+	// OrphanedThreshold alerts on pods without an owner that have been running for longer than this many seconds. Zero disables the check.
+	OrphanedThreshold int64 `json:"orphanedThreshold"`
+	// OrphanedAllowlist are glob patterns, such as "debug-*", of the names of pods allowed to run without an owner
+	OrphanedAllowlist []string `json:"orphanedAllowlist"`
+	// Usage alerts on pods using more CPU or memory than allowed, as metrics-server measures it
+	Usage PodUsage `json:"usage"`
+	// ImagePolicy alerts on pods with containers running images the policy disallows
+	ImagePolicy ImagePolicy `json:"imagePolicy"`
+}
+
+// ImagePolicy represents which container images a pod spec may use
+type ImagePolicy struct {
+	// DisallowLatest alerts on images with the latest tag or no tag, unless they are pinned to a digest
+	DisallowLatest bool `json:"disallowLatest"`
+	// AllowedRegistries are glob patterns, such as "registry.example.com" or "*.dkr.ecr.*.amazonaws.com", of the
+	// registries images may be pulled from. Images that name no registry are pulled from docker.io. Empty allows any registry.
+	AllowedRegistries []string `json:"allowedRegistries"`
+}
+
+// PodUsage is how much CPU and memory a pod may use before it alerts
+type PodUsage struct {
+	// CPU and Memory are the quantities, e.g. 500m or 1Gi, a pod may use across its containers. Empty disables them.
+	CPU    string `json:"cpu"`
+	Memory string `json:"memory"`
+	// LimitPercent is the share of its CPU or memory limits a pod may use. Zero disables it, and pods
+	// with a container setting no limit for a resource are not compared for it.
+	LimitPercent float64 `json:"limitPercent"`
+	// ThresholdSeconds is how long a pod must use more before it alerts
+	ThresholdSeconds int64 `json:"thresholdSeconds"`
+}
+
+// GRPCHealthCheck represents where and how to call grpc.health.v1.Health/Check
+type GRPCHealthCheck struct {
+	// Port is the port of each pod's IP serving the health service. Zero disables the check unless Address is set.
+	Port int `json:"port"`
+	// Address is a host:port, e.g. of a Service, probed once per poll instead of every pod
+	Address string `json:"address"`
+	// Service is the service name asked about, empty for the health of the whole server
+	Service string `json:"service"`
+	// TimeoutSeconds bounds each probe, 5 when unset
+	TimeoutSeconds int64 `json:"timeoutSeconds"`
 }
 
 // PodAlertSpec represents the configuration for alerting on Pods
@@ -31,4 +99,8 @@ type PodAlertSpec struct {
 	AlerterType        string         `json:"alerterType"`
 	AlerterName        string         `json:"alerterName"`
 	ReportStatus       PodAlertStatus `json:"reportStatus"`
+	// OnObjectError is the rule's OnObjectError policy
+	OnObjectError string `json:"onObjectError"`
+	// Escalation is the rule's escalation chain
+	Escalation []EscalationLevel `json:"escalation"`
 }
@@ -0,0 +1,36 @@
+// Copyright 2019 Bloomberg Finance LP
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package types
+
+// PVAlertStatus represents the thresholds to alert on for PersistentVolumes
+type PVAlertStatus struct {
+	// FailedThreshold alerts on volumes that have been Failed for longer than this many seconds. Zero disables the check.
+	FailedThreshold int64 `json:"failedThreshold"`
+	// ReleasedThreshold alerts on volumes that have been Released for longer than this many seconds. Zero disables the check.
+	ReleasedThreshold int64 `json:"releasedThreshold"`
+	// ReclaimErrors alerts on volumes not bound to a claim whose deletion or recycling failed
+	ReclaimErrors bool `json:"reclaimErrors"`
+}
+
+// PVAlertSpec represents the configuration for alerting on PersistentVolumes
+type PVAlertSpec struct {
+	Name         string        `json:"name"`
+	PVFilter     string        `json:"filter"`
+	AlerterType  string        `json:"alerterType"`
+	AlerterName  string        `json:"alerterName"`
+	ReportStatus PVAlertStatus `json:"reportStatus"`
+	// Escalation is the rule's escalation chain
+	Escalation []EscalationLevel `json:"escalation"`
+}
@@ -0,0 +1,36 @@
+// Copyright 2019 Bloomberg Finance LP
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package types
+
+// PVCAlertStatus represents the thresholds to alert on for PersistentVolumeClaims
+type PVCAlertStatus struct {
+	UsagePercent float64 `json:"usagePercent"`
+	// PendingThreshold alerts on claims still Pending this many seconds after they were created. Zero disables the check.
+	PendingThreshold int64 `json:"pendingThreshold"`
+	// Lost alerts on claims whose PersistentVolume went away
+	Lost bool `json:"lost"`
+}
+
+// PVCAlertSpec represents the configuration for alerting on PersistentVolumeClaims
+type PVCAlertSpec struct {
+	Name               string         `json:"name"`
+	PVCFilterNamespace string         `json:"filterNamespace"`
+	PVCFilterLabel     string         `json:"filterLabel"`
+	AlerterType        string         `json:"alerterType"`
+	AlerterName        string         `json:"alerterName"`
+	ReportStatus       PVCAlertStatus `json:"reportStatus"`
+	// Escalation is the rule's escalation chain
+	Escalation []EscalationLevel `json:"escalation"`
+}
@@ -0,0 +1,35 @@
+// Copyright 2019 Bloomberg Finance LP
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package types
+
+// ReplicaSetAlertStatus represents the thresholds to alert on for ReplicaSets
+type ReplicaSetAlertStatus struct {
+	// UnavailableThreshold alerts on ReplicaSets that have had fewer available replicas than they want for longer than this many seconds. Zero disables the check.
+	UnavailableThreshold int64 `json:"unavailableThreshold"`
+	// Orphaned alerts on ReplicaSets that no Deployment, or other controller, controls
+	Orphaned bool `json:"orphaned"`
+}
+
+// ReplicaSetAlertSpec represents the configuration for alerting on ReplicaSets
+type ReplicaSetAlertSpec struct {
+	Name                      string                `json:"name"`
+	ReplicaSetFilterNamespace string                `json:"filterNamespace"`
+	ReplicaSetFilterLabel     string                `json:"filterLabel"`
+	AlerterType               string                `json:"alerterType"`
+	AlerterName               string                `json:"alerterName"`
+	ReportStatus              ReplicaSetAlertStatus `json:"reportStatus"`
+	// Escalation is the rule's escalation chain
+	Escalation []EscalationLevel `json:"escalation"`
+}
@@ -0,0 +1,35 @@
+// Copyright 2019 Bloomberg Finance LP
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package types
+
+// ResourceQuotaAlertStatus represents the thresholds to alert on for ResourceQuotas
+type ResourceQuotaAlertStatus struct {
+	// UsagePercent alerts on quotas with a resource whose used amount is at least this percentage of its hard limit. Zero disables the check.
+	UsagePercent float64 `json:"usagePercent"`
+	// Resources limits the check to these resources of the quotas, e.g. requests.cpu or pods. Empty checks every resource.
+	Resources []string `json:"resources"`
+}
+
+// ResourceQuotaAlertSpec represents the configuration for alerting on ResourceQuotas
+type ResourceQuotaAlertSpec struct {
+	Name                         string                   `json:"name"`
+	ResourceQuotaFilterNamespace string                   `json:"filterNamespace"`
+	ResourceQuotaFilterLabel     string                   `json:"filterLabel"`
+	AlerterType                  string                   `json:"alerterType"`
+	AlerterName                  string                   `json:"alerterName"`
+	ReportStatus                 ResourceQuotaAlertStatus `json:"reportStatus"`
+	// Escalation is the rule's escalation chain
+	Escalation []EscalationLevel `json:"escalation"`
+}
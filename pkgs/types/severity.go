@@ -0,0 +1,33 @@
+// Copyright 2019 Bloomberg Finance LP
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package types
+
+// SeverityOverride sets the severity of the alerts a check raises, in place of the check's default
+type SeverityOverride struct {
+	// ResourceType is the kind of object alerted on, e.g. node or pod. Empty matches every kind.
+	ResourceType string `json:"resourceType"`
+	// Check is the condition alerted on, the last part of the alert key, e.g. MemoryPressure
+	Check    string `json:"check"`
+	Severity string `json:"severity"`
+}
+
+// ValidSeverity reports whether severity is one of the alert severities
+func ValidSeverity(severity string) bool {
+	switch severity {
+	case SeverityCritical, SeverityWarning, SeverityInfo:
+		return true
+	}
+	return false
+}
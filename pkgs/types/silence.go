@@ -0,0 +1,23 @@
+// Copyright 2019 Bloomberg Finance LP
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package types
+
+// Silencing names the annotation with which the owners of an object silence its alerts themselves
+type Silencing struct {
+	// AnnotationKey is the annotation, e.g. k8eraid.io/silence-until, whose value is an RFC 3339 time
+	// the alerts of the object are silenced until, optionally followed by a reason. A value that is only
+	// a reason silences them until the annotation is removed. Unset disables silencing.
+	AnnotationKey string `json:"annotationKey"`
+}
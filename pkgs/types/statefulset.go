@@ -0,0 +1,39 @@
+// Copyright 2019 Bloomberg Finance LP
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package types
+
+// StatefulsetAlertStatus represents the thresholds to alert on for StatefulSets
+type StatefulsetAlertStatus struct {
+	// CheckReplicas alerts when fewer replicas are ready than the StatefulSet wants
+	CheckReplicas    bool  `json:"checkReplicas"`
+	PendingThreshold int64 `json:"pendingThreshold"`
+	// UpdateStallThreshold alerts on rollouts that have not finished updating every replica after this many seconds. Zero disables the check.
+	UpdateStallThreshold int64 `json:"updateStallThreshold"`
+	// PodSpread alerts when the StatefulSet's ready pods are concentrated on one node or zone
+	PodSpread PodSpreadCheck `json:"podSpread"`
+	// IgnoreMissing stops a rule naming a single StatefulSet from alerting when it does not exist
+	IgnoreMissing bool `json:"ignoreMissing"`
+}
+
+// StatefulsetAlertSpec represents a single configuration for monitoring a StatefulSet
+type StatefulsetAlertSpec struct {
+	Name              string                 `json:"name"`
+	StatefulsetFilter string                 `json:"filter"`
+	AlerterType       string                 `json:"alerterType"`
+	AlerterName       string                 `json:"alerterName"`
+	ReportStatus      StatefulsetAlertStatus `json:"reportStatus"`
+	// Escalation is the rule's escalation chain
+	Escalation []EscalationLevel `json:"escalation"`
+}
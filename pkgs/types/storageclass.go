@@ -0,0 +1,38 @@
+// Copyright 2019 Bloomberg Finance LP
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package types
+
+// StorageClassAlertStatus represents the problems to alert on for StorageClasses
+type StorageClassAlertStatus struct {
+	// NoDefault alerts when no StorageClass is marked as the default, so claims that name no class are never provisioned
+	NoDefault bool `json:"noDefault"`
+	// Missing alerts when the StorageClass a named rule checks does not exist
+	Missing bool `json:"missing"`
+	// ProvisioningFailures alerts on classes that failed to provision volumes at least this many times within ProvisioningWindow. Zero disables the check.
+	ProvisioningFailures int32 `json:"provisioningFailures"`
+	// ProvisioningWindow is how many seconds back provisioning failures are counted, an hour when unset
+	ProvisioningWindow int64 `json:"provisioningWindow"`
+}
+
+// StorageClassAlertSpec represents the configuration for alerting on StorageClasses
+type StorageClassAlertSpec struct {
+	Name               string                  `json:"name"`
+	StorageClassFilter string                  `json:"filter"`
+	AlerterType        string                  `json:"alerterType"`
+	AlerterName        string                  `json:"alerterName"`
+	ReportStatus       StorageClassAlertStatus `json:"reportStatus"`
+	// Escalation is the rule's escalation chain
+	Escalation []EscalationLevel `json:"escalation"`
+}
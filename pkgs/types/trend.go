@@ -0,0 +1,25 @@
+// Copyright 2019 Bloomberg Finance LP
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package types
+
+// TrendCheck alerts when a signal keeps falling over a rolling window, even while it is above any static threshold
+type TrendCheck struct {
+	// Window is the number of seconds of samples the trend is fitted over. Zero disables the check.
+	Window int64 `json:"window"`
+	// MaxDropPerHour is the steepest decline per hour tolerated before alerting
+	MaxDropPerHour float64 `json:"maxDropPerHour"`
+	// MinSamples is the number of samples needed before the trend is evaluated, 3 when unset
+	MinSamples int `json:"minSamples"`
+}
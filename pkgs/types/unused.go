@@ -0,0 +1,39 @@
+// Copyright 2019 Bloomberg Finance LP
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package types
+
+// UnusedAlertStatus represents the kinds of unused objects to alert on
+type UnusedAlertStatus struct {
+	// PersistentVolumes alerts on PersistentVolumes that are not bound to a claim
+	PersistentVolumes bool `json:"persistentVolumes"`
+	// Secrets alerts on Secrets that no pod or deployment of their namespace references
+	Secrets bool `json:"secrets"`
+	// ConfigMaps alerts on ConfigMaps that no pod or deployment of their namespace references
+	ConfigMaps bool `json:"configMaps"`
+	// UnusedThreshold is how many seconds an object must have been unused before it is alerted on, a week when unset
+	UnusedThreshold int64 `json:"unusedThreshold"`
+}
+
+// UnusedAlertSpec represents the configuration for alerting on PersistentVolumes, Secrets and
+// ConfigMaps that nothing uses
+type UnusedAlertSpec struct {
+	UnusedFilterNamespace string            `json:"filterNamespace"`
+	UnusedFilterLabel     string            `json:"filterLabel"`
+	AlerterType           string            `json:"alerterType"`
+	AlerterName           string            `json:"alerterName"`
+	ReportStatus          UnusedAlertStatus `json:"reportStatus"`
+	// Escalation is the rule's escalation chain
+	Escalation []EscalationLevel `json:"escalation"`
+}
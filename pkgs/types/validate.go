@@ -0,0 +1,508 @@
+// Copyright 2019 Bloomberg Finance LP
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package types
+
+import (
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/api/resource"
+)
+
+// ValidationError lists every conflict found in a config
+type ValidationError struct {
+	Problems []string
+}
+
+func (e *ValidationError) Error() string {
+	return fmt.Sprintf("invalid config: %s", strings.Join(e.Problems, "; "))
+}
+
+// Validate checks that no two rules of a kind share a name and filters, that alerter names are
+// unique within their type, that every rule routes to an alerter that is defined, and that severity
+// overrides are unambiguous and use known severities, that the JSONPath expressions of field
+// conditions parse, that escalation levels wait longer and longer, and that cluster alerter overrides
+// route to defined alerters.
+func (c *ConfigRules) Validate() error {
+	v := &validator{
+		alerters: c.AlertersConfig.Types.names(),
+		seen:     map[string]string{},
+	}
+	v.checkAlerterNames(c.AlertersConfig.Types)
+
+	for i, rule := range c.Deployments {
+		v.checkRule(fmt.Sprintf("deployments[%d]", i), fmt.Sprintf("name %q, filter %q", rule.Name, rule.DepFilter), rule.AlerterType, rule.AlerterName)
+		v.checkOnObjectError(fmt.Sprintf("deployments[%d]", i), rule.OnObjectError)
+		v.checkEscalation(fmt.Sprintf("deployments[%d]", i), rule.Escalation)
+		v.checkImagePolicy(fmt.Sprintf("deployments[%d].reportStatus.imagePolicy", i), rule.ReportStatus.ImagePolicy)
+	}
+	for i, rule := range c.Pods {
+		v.checkRule(fmt.Sprintf("pods[%d]", i), fmt.Sprintf("name %q, filterNamespace %q, filterLabel %q", rule.Name, rule.PodFilterNamespace, rule.PodFilterLabel), rule.AlerterType, rule.AlerterName)
+		v.checkOnObjectError(fmt.Sprintf("pods[%d]", i), rule.OnObjectError)
+		v.checkEscalation(fmt.Sprintf("pods[%d]", i), rule.Escalation)
+		for j, pattern := range rule.ReportStatus.OrphanedAllowlist {
+			if _, err := path.Match(pattern, ""); err != nil {
+				v.problems = append(v.problems, fmt.Sprintf("pods[%d].reportStatus.orphanedAllowlist[%d] is %q, not a valid glob pattern", i, j, pattern))
+			}
+		}
+		v.checkPodUsage(fmt.Sprintf("pods[%d].reportStatus.usage", i), rule.ReportStatus.Usage)
+		v.checkImagePolicy(fmt.Sprintf("pods[%d].reportStatus.imagePolicy", i), rule.ReportStatus.ImagePolicy)
+	}
+	for i, rule := range c.Daemonsets {
+		v.checkRule(fmt.Sprintf("daemonsets[%d]", i), fmt.Sprintf("name %q, filter %q", rule.Name, rule.DaemonFilter), rule.AlerterType, rule.AlerterName)
+		v.checkOnObjectError(fmt.Sprintf("daemonsets[%d]", i), rule.OnObjectError)
+		v.checkEscalation(fmt.Sprintf("daemonsets[%d]", i), rule.Escalation)
+	}
+	for i, rule := range c.Statefulsets {
+		v.checkRule(fmt.Sprintf("statefulsets[%d]", i), fmt.Sprintf("name %q, filter %q", rule.Name, rule.StatefulsetFilter), rule.AlerterType, rule.AlerterName)
+		v.checkEscalation(fmt.Sprintf("statefulsets[%d]", i), rule.Escalation)
+	}
+	for i, rule := range c.ReplicaSets {
+		v.checkRule(fmt.Sprintf("replicaSets[%d]", i), fmt.Sprintf("name %q, filterNamespace %q, filterLabel %q", rule.Name, rule.ReplicaSetFilterNamespace, rule.ReplicaSetFilterLabel), rule.AlerterType, rule.AlerterName)
+		v.checkEscalation(fmt.Sprintf("replicaSets[%d]", i), rule.Escalation)
+	}
+	for i, rule := range c.Jobs {
+		v.checkRule(fmt.Sprintf("jobs[%d]", i), fmt.Sprintf("name %q, filter %q", rule.Name, rule.JobFilter), rule.AlerterType, rule.AlerterName)
+		v.checkEscalation(fmt.Sprintf("jobs[%d]", i), rule.Escalation)
+	}
+	for i, rule := range c.CronJobs {
+		v.checkRule(fmt.Sprintf("cronJobs[%d]", i), fmt.Sprintf("name %q, filter %q", rule.Name, rule.CronJobFilter), rule.AlerterType, rule.AlerterName)
+		v.checkEscalation(fmt.Sprintf("cronJobs[%d]", i), rule.Escalation)
+	}
+	for i, rule := range c.Nodes {
+		v.checkRule(fmt.Sprintf("nodes[%d]", i), fmt.Sprintf("name %q, filter %q", rule.Name, rule.NodeFilter), rule.AlerterType, rule.AlerterName)
+		if severity := rule.ReportStatus.CapacityType.InterruptibleReadySeverity; severity != "" && !ValidSeverity(severity) {
+			v.problems = append(v.problems, fmt.Sprintf(
+				"nodes[%d] has unknown interruptibleReadySeverity %q, expected %s, %s or %s",
+				i, severity, SeverityCritical, SeverityWarning, SeverityInfo,
+			))
+		}
+		for j, match := range rule.ReportStatus.Taints {
+			if _, err := path.Match(match.Key, ""); err != nil || match.Key == "" {
+				v.problems = append(v.problems, fmt.Sprintf("nodes[%d].reportStatus.taints[%d] has key %q, not a valid glob pattern", i, j, match.Key))
+			}
+			if match.Severity != "" && !ValidSeverity(match.Severity) {
+				v.problems = append(v.problems, fmt.Sprintf(
+					"nodes[%d].reportStatus.taints[%d] has unknown severity %q, expected %s, %s or %s",
+					i, j, match.Severity, SeverityCritical, SeverityWarning, SeverityInfo,
+				))
+			}
+		}
+		if status := rule.ReportStatus; status.MaxNodes > 0 && status.MaxNodes < status.MinNodes {
+			v.problems = append(v.problems, fmt.Sprintf("nodes[%d].reportStatus.maxNodes is %d, below its minNodes of %d", i, status.MaxNodes, status.MinNodes))
+		}
+		if balance := rule.ReportStatus.GroupBalance; balance.MinPerGroup < 0 {
+			v.problems = append(v.problems, fmt.Sprintf("nodes[%d].reportStatus.groupBalance.minPerGroup is %d, expected a positive number", i, balance.MinPerGroup))
+		}
+		if ratio := rule.ReportStatus.GroupBalance.MaxRatio; ratio != 0 && ratio < 1 {
+			v.problems = append(v.problems, fmt.Sprintf("nodes[%d].reportStatus.groupBalance.maxRatio is %g, expected at least 1", i, ratio))
+		}
+		for j, notice := range rule.ReportStatus.TerminationNotices {
+			if _, err := path.Match(notice.Taint, ""); err != nil || (notice.Taint == "" && notice.Condition == "") {
+				v.problems = append(v.problems, fmt.Sprintf("nodes[%d].reportStatus.terminationNotices[%d] has taint %q, expected a valid glob pattern or a condition", i, j, notice.Taint))
+			}
+			if notice.Severity != "" && !ValidSeverity(notice.Severity) {
+				v.problems = append(v.problems, fmt.Sprintf(
+					"nodes[%d].reportStatus.terminationNotices[%d] has unknown severity %q, expected %s, %s or %s",
+					i, j, notice.Severity, SeverityCritical, SeverityWarning, SeverityInfo,
+				))
+			}
+		}
+		v.checkNodeUtilization(fmt.Sprintf("nodes[%d].reportStatus.utilization", i), rule.ReportStatus.Utilization)
+		if skew := rule.ReportStatus.KubeletSkew; skew.MaxMinorVersions < 0 {
+			v.problems = append(v.problems, fmt.Sprintf("nodes[%d].reportStatus.kubeletSkew.maxMinorVersions is %d, expected a positive number", i, skew.MaxMinorVersions))
+		}
+		switch reference := rule.ReportStatus.ClockSkew.Reference; reference {
+		case "", ClockSkewReferenceNodes, ClockSkewReferenceMonitor:
+		default:
+			v.problems = append(v.problems, fmt.Sprintf(
+				"nodes[%d].reportStatus.clockSkew has unknown reference %q, expected %s or %s",
+				i, reference, ClockSkewReferenceNodes, ClockSkewReferenceMonitor,
+			))
+		}
+		if minimum := rule.ReportStatus.KubeletSkew.MinVersion; minimum != "" {
+			if _, parsed := ParseKubernetesVersion(minimum); !parsed {
+				v.problems = append(v.problems, fmt.Sprintf("nodes[%d].reportStatus.kubeletSkew.minVersion is %q, not a version such as v1.12.5", i, minimum))
+			}
+		}
+		v.checkOnObjectError(fmt.Sprintf("nodes[%d]", i), rule.OnObjectError)
+		v.checkEscalation(fmt.Sprintf("nodes[%d]", i), rule.Escalation)
+	}
+	for i, rule := range c.PVCs {
+		v.checkRule(fmt.Sprintf("persistentVolumeClaims[%d]", i), fmt.Sprintf("name %q, filterNamespace %q, filterLabel %q", rule.Name, rule.PVCFilterNamespace, rule.PVCFilterLabel), rule.AlerterType, rule.AlerterName)
+		v.checkEscalation(fmt.Sprintf("persistentVolumeClaims[%d]", i), rule.Escalation)
+	}
+	for i, rule := range c.PVs {
+		v.checkRule(fmt.Sprintf("persistentVolumes[%d]", i), fmt.Sprintf("name %q, filter %q", rule.Name, rule.PVFilter), rule.AlerterType, rule.AlerterName)
+		v.checkEscalation(fmt.Sprintf("persistentVolumes[%d]", i), rule.Escalation)
+	}
+	for i, rule := range c.StorageClasses {
+		path := fmt.Sprintf("storageClasses[%d]", i)
+		v.checkRule(path, fmt.Sprintf("name %q, filter %q", rule.Name, rule.StorageClassFilter), rule.AlerterType, rule.AlerterName)
+		if rule.Name == "*" && rule.ReportStatus.Missing {
+			v.problems = append(v.problems, fmt.Sprintf("%s checks missing, which only applies to rules naming a StorageClass", path))
+		}
+		v.checkEscalation(path, rule.Escalation)
+	}
+	for i, rule := range c.Namespaces {
+		v.checkRule(fmt.Sprintf("namespaces[%d]", i), fmt.Sprintf("name %q, filter %q", rule.Name, rule.NamespaceFilter), rule.AlerterType, rule.AlerterName)
+		v.checkEscalation(fmt.Sprintf("namespaces[%d]", i), rule.Escalation)
+	}
+	for i, rule := range c.ResourceQuotas {
+		v.checkRule(fmt.Sprintf("resourceQuotas[%d]", i), fmt.Sprintf("name %q, filterNamespace %q, filterLabel %q", rule.Name, rule.ResourceQuotaFilterNamespace, rule.ResourceQuotaFilterLabel), rule.AlerterType, rule.AlerterName)
+		v.checkEscalation(fmt.Sprintf("resourceQuotas[%d]", i), rule.Escalation)
+	}
+	for i, rule := range c.Services {
+		v.checkRule(fmt.Sprintf("services[%d]", i), fmt.Sprintf("name %q, filterNamespace %q, filterLabel %q", rule.Name, rule.ServiceFilterNamespace, rule.ServiceFilterLabel), rule.AlerterType, rule.AlerterName)
+		v.checkEscalation(fmt.Sprintf("services[%d]", i), rule.Escalation)
+	}
+	for i, rule := range c.Ingresses {
+		v.checkRule(fmt.Sprintf("ingresses[%d]", i), fmt.Sprintf("name %q, filterNamespace %q, filterLabel %q", rule.Name, rule.IngressFilterNamespace, rule.IngressFilterLabel), rule.AlerterType, rule.AlerterName)
+		v.checkEscalation(fmt.Sprintf("ingresses[%d]", i), rule.Escalation)
+	}
+	for i, rule := range c.HPAs {
+		v.checkRule(fmt.Sprintf("horizontalPodAutoscalers[%d]", i), fmt.Sprintf("name %q, filterNamespace %q, filterLabel %q", rule.Name, rule.HPAFilterNamespace, rule.HPAFilterLabel), rule.AlerterType, rule.AlerterName)
+		v.checkEscalation(fmt.Sprintf("horizontalPodAutoscalers[%d]", i), rule.Escalation)
+	}
+	for i, rule := range c.PDBs {
+		v.checkRule(fmt.Sprintf("podDisruptionBudgets[%d]", i), fmt.Sprintf("name %q, filterNamespace %q, filterLabel %q", rule.Name, rule.PDBFilterNamespace, rule.PDBFilterLabel), rule.AlerterType, rule.AlerterName)
+		v.checkEscalation(fmt.Sprintf("podDisruptionBudgets[%d]", i), rule.Escalation)
+	}
+	for i, rule := range c.Webhooks {
+		v.checkRule(fmt.Sprintf("webhookConfigurations[%d]", i), fmt.Sprintf("name %q, filter %q", rule.Name, rule.WebhookFilter), rule.AlerterType, rule.AlerterName)
+		v.checkEscalation(fmt.Sprintf("webhookConfigurations[%d]", i), rule.Escalation)
+	}
+	for i, rule := range c.WorkloadResources {
+		v.checkRule(fmt.Sprintf("workloadResources[%d]", i), fmt.Sprintf("filterNamespace %q, filterLabel %q", rule.WorkloadFilterNamespace, rule.WorkloadFilterLabel), rule.AlerterType, rule.AlerterName)
+		v.checkEscalation(fmt.Sprintf("workloadResources[%d]", i), rule.Escalation)
+	}
+	for i, rule := range c.DeprecatedAPIs {
+		v.checkRule(fmt.Sprintf("deprecatedApis[%d]", i), fmt.Sprintf("filterNamespace %q, filterLabel %q", rule.DeprecatedFilterNamespace, rule.DeprecatedFilterLabel), rule.AlerterType, rule.AlerterName)
+		if target := rule.ReportStatus.TargetVersion; target != "" {
+			if _, parsed := ParseKubernetesVersion(target); !parsed {
+				v.problems = append(v.problems, fmt.Sprintf("deprecatedApis[%d].reportStatus.targetVersion is %q, not a version such as v1.22", i, target))
+			}
+		}
+		v.checkEscalation(fmt.Sprintf("deprecatedApis[%d]", i), rule.Escalation)
+	}
+	for i, rule := range c.Unused {
+		v.checkRule(fmt.Sprintf("unusedResources[%d]", i), fmt.Sprintf("filterNamespace %q, filterLabel %q", rule.UnusedFilterNamespace, rule.UnusedFilterLabel), rule.AlerterType, rule.AlerterName)
+		v.checkEscalation(fmt.Sprintf("unusedResources[%d]", i), rule.Escalation)
+	}
+	for i, rule := range c.FieldConditions {
+		path := fmt.Sprintf("fieldConditions[%d]", i)
+		v.checkRule(path, fmt.Sprintf("resource %q, name %q, filterNamespace %q, filterLabel %q, check %q", rule.Resource, rule.Name, rule.FieldFilterNamespace, rule.FieldFilterLabel, rule.Check), rule.AlerterType, rule.AlerterName)
+		v.checkFieldCondition(path, rule)
+		v.checkEscalation(path, rule.Escalation)
+	}
+	for i, rule := range c.CSRs {
+		v.checkRule(fmt.Sprintf("certificateSigningRequests[%d]", i), fmt.Sprintf("signerName %q", rule.SignerName), rule.AlerterType, rule.AlerterName)
+		v.checkEscalation(fmt.Sprintf("certificateSigningRequests[%d]", i), rule.Escalation)
+	}
+	for i, rule := range c.Events {
+		v.checkRule(fmt.Sprintf("events[%d]", i), fmt.Sprintf("name %q, filterNamespace %q, filterKind %q", rule.Name, rule.EventFilterNamespace, rule.EventFilterKind), rule.AlerterType, rule.AlerterName)
+		v.checkEscalation(fmt.Sprintf("events[%d]", i), rule.Escalation)
+	}
+	for i, rule := range c.ConfigObjects {
+		path := fmt.Sprintf("configObjects[%d]", i)
+		v.checkRule(path, fmt.Sprintf("kind %q, name %q, filterNamespace %q, filterLabel %q", rule.Kind, rule.Name, rule.ConfigFilterNamespace, rule.ConfigFilterLabel), rule.AlerterType, rule.AlerterName)
+		if rule.Kind != ConfigObjectConfigMap && rule.Kind != ConfigObjectSecret {
+			v.problems = append(v.problems, fmt.Sprintf("%s has unknown kind %q, expected %s or %s", path, rule.Kind, ConfigObjectConfigMap, ConfigObjectSecret))
+		} else if rule.Kind != ConfigObjectSecret && rule.ReportStatus.CertificateExpiryDays > 0 {
+			v.problems = append(v.problems, fmt.Sprintf("%s checks certificateExpiryDays, which only applies to Secrets", path))
+		} else if rule.Kind != ConfigObjectSecret && rule.ReportStatus.CredentialExpiryDays > 0 {
+			v.problems = append(v.problems, fmt.Sprintf("%s checks credentialExpiryDays, which only applies to Secrets", path))
+		}
+		v.checkEscalation(path, rule.Escalation)
+	}
+	if c.APILatency.ThresholdSeconds > 0 {
+		v.checkAlerter("apiserverLatency", c.APILatency.AlerterType, c.APILatency.AlerterName)
+		v.checkEscalation("apiserverLatency", c.APILatency.Escalation)
+	}
+	if len(c.APIHealth.Endpoints) > 0 {
+		v.checkAlerter("apiserverHealth", c.APIHealth.AlerterType, c.APIHealth.AlerterName)
+		v.checkEscalation("apiserverHealth", c.APIHealth.Escalation)
+		for i, endpoint := range c.APIHealth.Endpoints {
+			if !strings.HasPrefix(endpoint, "/") {
+				v.problems = append(v.problems, fmt.Sprintf("apiserverHealth.endpoints[%d] is %q, expected a path starting with /", i, endpoint))
+			}
+		}
+	}
+	if len(c.ControlPlane.Components) > 0 {
+		v.checkAlerter("controlPlane", c.ControlPlane.AlerterType, c.ControlPlane.AlerterName)
+		v.checkEscalation("controlPlane", c.ControlPlane.Escalation)
+		for i, component := range c.ControlPlane.Components {
+			if component != ControlPlaneScheduler && component != ControlPlaneControllerManager {
+				v.problems = append(v.problems, fmt.Sprintf("controlPlane.components[%d] is %q, expected %s or %s", i, component, ControlPlaneScheduler, ControlPlaneControllerManager))
+			}
+		}
+	}
+	if c.Etcd.Endpoint != "" {
+		v.checkAlerter("etcd", c.Etcd.AlerterType, c.Etcd.AlerterName)
+		v.checkEscalation("etcd", c.Etcd.Escalation)
+		if !strings.HasPrefix(c.Etcd.Endpoint, "/") {
+			v.problems = append(v.problems, fmt.Sprintf("etcd.endpoint is %q, expected a path starting with /", c.Etcd.Endpoint))
+		}
+	}
+	v.checkSeverities(c.Severities)
+	v.checkClusterAlerters(c.ClusterAlerters)
+
+	if len(v.problems) > 0 {
+		return &ValidationError{Problems: v.problems}
+	}
+	return nil
+}
+
+// names indexes how many alerters of each type use each name
+func (t AlerterTypes) names() map[string]map[string]int {
+	names := map[string]map[string]int{
+		"pagerdutyV2": {},
+		"slack":       {},
+		"smtp":        {},
+		"webhook":     {},
+		"sql":         {},
+		"telegram":    {},
+		"csv":         {},
+	}
+	for _, alerter := range t.PDAlerterList {
+		names["pagerdutyV2"][alerter.Name]++
+	}
+	for _, alerter := range t.SlackAlerterList {
+		names["slack"][alerter.Name]++
+	}
+	for _, alerter := range t.SMTPAlerterList {
+		names["smtp"][alerter.Name]++
+	}
+	for _, alerter := range t.WebhookAlerterList {
+		names["webhook"][alerter.Name]++
+	}
+	for _, alerter := range t.SQLAlerterList {
+		names["sql"][alerter.Name]++
+	}
+	for _, alerter := range t.TelegramAlerterList {
+		names["telegram"][alerter.Name]++
+	}
+	for _, alerter := range t.CSVAlerterList {
+		names["csv"][alerter.Name]++
+	}
+	return names
+}
+
+type validator struct {
+	alerters map[string]map[string]int
+	seen     map[string]string
+	problems []string
+}
+
+func (v *validator) checkAlerterNames(t AlerterTypes) {
+	reported := map[string]bool{}
+	// walk the lists in config order so problems are reported deterministically
+	check := func(alerterType string, name string) {
+		key := alerterType + "/" + name
+		if v.alerters[alerterType][name] > 1 && !reported[key] {
+			reported[key] = true
+			v.problems = append(v.problems, fmt.Sprintf("alerters.%s has %d alerters named %q", alerterType, v.alerters[alerterType][name], name))
+		}
+	}
+	for _, alerter := range t.PDAlerterList {
+		check("pagerdutyV2", alerter.Name)
+	}
+	for _, alerter := range t.SlackAlerterList {
+		check("slack", alerter.Name)
+	}
+	for _, alerter := range t.SMTPAlerterList {
+		check("smtp", alerter.Name)
+	}
+	for _, alerter := range t.WebhookAlerterList {
+		check("webhook", alerter.Name)
+	}
+	for _, alerter := range t.SQLAlerterList {
+		check("sql", alerter.Name)
+	}
+	for _, alerter := range t.TelegramAlerterList {
+		check("telegram", alerter.Name)
+	}
+	for _, alerter := range t.CSVAlerterList {
+		check("csv", alerter.Name)
+	}
+}
+
+// checkRule reports a rule that repeats the identity of an earlier rule of the same kind, or routes nowhere.
+// Rules with the same identity would share their state between ticks.
+func (v *validator) checkRule(path string, identity string, alerterType string, alerterName string) {
+	kind := path[:strings.Index(path, "[")]
+	key := kind + "|" + identity
+	if first, ok := v.seen[key]; ok {
+		v.problems = append(v.problems, fmt.Sprintf("%s duplicates %s (%s)", path, first, identity))
+	} else {
+		v.seen[key] = path
+	}
+	v.checkAlerter(path, alerterType, alerterName)
+}
+
+func (v *validator) checkAlerter(path string, alerterType string, alerterName string) {
+	if alerterType == "" || alerterType == "stderr" {
+		return
+	}
+	names, ok := v.alerters[alerterType]
+	if !ok {
+		v.problems = append(v.problems, fmt.Sprintf("%s uses unknown alerterType %q", path, alerterType))
+		return
+	}
+	if names[alerterName] == 0 {
+		v.problems = append(v.problems, fmt.Sprintf("%s references undefined %s alerter %q", path, alerterType, alerterName))
+	}
+}
+
+func (v *validator) checkClusterAlerters(clusters ClusterAlerters) {
+	names := make([]string, 0, len(clusters))
+	for cluster := range clusters {
+		names = append(names, cluster)
+	}
+	sort.Strings(names)
+	for _, cluster := range names {
+		for i, override := range clusters[cluster] {
+			v.checkAlerter(fmt.Sprintf("clusterAlerters[%q][%d]", cluster, i), override.ToAlerterType, override.ToAlerterName)
+		}
+	}
+}
+
+func (v *validator) checkOnObjectError(path string, policy string) {
+	if policy != "" && policy != OnObjectErrorAbort && policy != OnObjectErrorContinue {
+		v.problems = append(v.problems, fmt.Sprintf(
+			"%s has unknown onObjectError %q, expected %s or %s",
+			path, policy, OnObjectErrorAbort, OnObjectErrorContinue,
+		))
+	}
+}
+
+// checkPodUsage reports usage limits that are not quantities or percentages
+func (v *validator) checkPodUsage(path string, usage PodUsage) {
+	names := []string{"cpu", "memory"}
+	for i, quantity := range []string{usage.CPU, usage.Memory} {
+		if _, err := resource.ParseQuantity(quantity); quantity != "" && err != nil {
+			v.problems = append(v.problems, fmt.Sprintf("%s.%s is %q, not a quantity such as 500m or 1Gi", path, names[i], quantity))
+		}
+	}
+	if usage.LimitPercent < 0 || usage.LimitPercent > 100 {
+		v.problems = append(v.problems, fmt.Sprintf("%s.limitPercent is %g, expected a percentage between 0 and 100", path, usage.LimitPercent))
+	}
+}
+
+// checkImagePolicy reports allowed registries that are not glob patterns
+func (v *validator) checkImagePolicy(policyPath string, policy ImagePolicy) {
+	for i, pattern := range policy.AllowedRegistries {
+		if _, err := path.Match(pattern, ""); err != nil || pattern == "" {
+			v.problems = append(v.problems, fmt.Sprintf("%s.allowedRegistries[%d] is %q, not a valid glob pattern", policyPath, i, pattern))
+		}
+	}
+}
+
+// checkNodeUtilization reports utilization thresholds that are not percentages, or tiers that can
+// never be told apart
+func (v *validator) checkNodeUtilization(path string, utilization NodeUtilization) {
+	names := []string{"warningPercent", "criticalPercent"}
+	for i, percent := range []float64{utilization.WarningPercent, utilization.CriticalPercent} {
+		if percent < 0 || percent > 100 {
+			v.problems = append(v.problems, fmt.Sprintf("%s.%s is %g, expected a percentage between 0 and 100", path, names[i], percent))
+		}
+	}
+	if utilization.WarningPercent > 0 && utilization.CriticalPercent > 0 && utilization.WarningPercent >= utilization.CriticalPercent {
+		v.problems = append(v.problems, fmt.Sprintf(
+			"%s.warningPercent is %g, expected less than criticalPercent %g",
+			path, utilization.WarningPercent, utilization.CriticalPercent,
+		))
+	}
+	for j, resource := range utilization.Resources {
+		if resource != "cpu" && resource != "memory" {
+			v.problems = append(v.problems, fmt.Sprintf("%s.resources[%d] is %q, expected cpu or memory", path, j, resource))
+		}
+	}
+}
+
+// checkFieldCondition reports a field condition rule that names no resource, or whose condition
+// would fail to evaluate on every object
+func (v *validator) checkFieldCondition(path string, rule FieldConditionAlertSpec) {
+	if rule.Version == "" || rule.Resource == "" {
+		v.problems = append(v.problems, fmt.Sprintf("%s needs a version and a resource", path))
+	}
+	if rule.Name == "" {
+		v.problems = append(v.problems, fmt.Sprintf("%s has no name, expected an object name or *", path))
+	}
+	condition := rule.Condition
+	if strings.TrimSpace(condition.JSONPath) == "" {
+		v.problems = append(v.problems, fmt.Sprintf("%s has no condition.jsonPath", path))
+	} else if _, err := ParseJSONPath(condition.JSONPath); err != nil {
+		v.problems = append(v.problems, fmt.Sprintf("%s has invalid condition.jsonPath %q: %s", path, condition.JSONPath, err.Error()))
+	}
+	if condition.CompareJSONPath != "" {
+		if _, err := ParseJSONPath(condition.CompareJSONPath); err != nil {
+			v.problems = append(v.problems, fmt.Sprintf("%s has invalid condition.compareJsonPath %q: %s", path, condition.CompareJSONPath, err.Error()))
+		}
+		if condition.Value != "" {
+			v.problems = append(v.problems, fmt.Sprintf("%s sets both condition.value and condition.compareJsonPath", path))
+		}
+	}
+	if !ValidOperator(condition.Operator) {
+		v.problems = append(v.problems, fmt.Sprintf(
+			"%s has unknown condition.operator %q, expected %s, %s, %s, %s, %s or %s",
+			path, condition.Operator, OperatorEqual, OperatorNotEqual, OperatorLess, OperatorLessOrEqual, OperatorGreater, OperatorGreaterOrEqual,
+		))
+	}
+	if rule.Severity != "" && !ValidSeverity(rule.Severity) {
+		v.problems = append(v.problems, fmt.Sprintf(
+			"%s has unknown severity %q, expected %s, %s or %s",
+			path, rule.Severity, SeverityCritical, SeverityWarning, SeverityInfo,
+		))
+	}
+}
+
+// checkEscalation reports escalation levels that would never be reached or route nowhere
+func (v *validator) checkEscalation(path string, levels []EscalationLevel) {
+	var previous int64
+	for i, level := range levels {
+		levelPath := fmt.Sprintf("%s.escalation[%d]", path, i)
+		if level.AfterSeconds <= previous {
+			v.problems = append(v.problems, fmt.Sprintf("%s has afterSeconds %d, expected more than %d", levelPath, level.AfterSeconds, previous))
+		} else {
+			previous = level.AfterSeconds
+		}
+		v.checkAlerter(levelPath, level.AlerterType, level.AlerterName)
+	}
+}
+
+func (v *validator) checkSeverities(overrides []SeverityOverride) {
+	seen := map[[2]string]int{}
+	for i, override := range overrides {
+		path := fmt.Sprintf("severities[%d]", i)
+		if override.Check == "" {
+			v.problems = append(v.problems, fmt.Sprintf("%s has no check", path))
+		}
+		if !ValidSeverity(override.Severity) {
+			v.problems = append(v.problems, fmt.Sprintf(
+				"%s has unknown severity %q, expected %s, %s or %s",
+				path, override.Severity, SeverityCritical, SeverityWarning, SeverityInfo,
+			))
+		}
+		key := [2]string{override.ResourceType, override.Check}
+		if first, ok := seen[key]; ok {
+			v.problems = append(v.problems, fmt.Sprintf("%s duplicates severities[%d] (resourceType %q, check %q)", path, first, override.ResourceType, override.Check))
+		} else {
+			seen[key] = i
+		}
+	}
+}
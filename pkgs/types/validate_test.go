@@ -0,0 +1,139 @@
+// Copyright 2019 Bloomberg Finance LP
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package types
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_ConfigRules_Validate(t *testing.T) {
+	tests := []struct {
+		name     string
+		config   string
+		problems []string
+	}{
+		{
+			name: "valid",
+			config: `{
+				"deployments": [
+					{"name": "web", "filter": "default", "alerterType": "smtp", "alerterName": "mail"},
+					{"name": "web", "filter": "staging", "alerterType": "smtp", "alerterName": "mail"}
+				],
+				"nodes": [{"name": "*", "alerterType": "stderr", "onObjectError": "continue"}],
+				"severities": [
+					{"resourceType": "node", "check": "MemoryPressure", "severity": "critical"},
+					{"check": "MemoryPressure", "severity": "info"}
+				],
+				"alerters": {"alerters": {"smtp": [{"name": "mail"}], "slack": [{"name": "mail"}]}}
+			}`,
+		},
+		{
+			name: "conflicts",
+			config: `{
+				"deployments": [
+					{"name": "web", "filter": "default", "alerterType": "smtp", "alerterName": "mail"},
+					{"name": "web", "filter": "default", "alerterType": "pagerdutyV2", "alerterName": "pager"}
+				],
+				"pods": [{"name": "*", "reportStatus": {"orphanedAllowlist": ["debug-*", "[debug"], "usage": {"cpu": "500m", "memory": "1 GB", "limitPercent": 150}, "imagePolicy": {"allowedRegistries": ["registry.example.com", ""]}}}],
+				"daemonsets": [{"name": "agent", "filter": "default", "alerterType": "pager", "onObjectError": "skip"}],
+				"nodes": [
+					{"name": "*", "reportStatus": {"capacityType": {"label": "karpenter.sh/capacity-type", "interruptibleReadySeverity": "low"}, "taints": [{"key": ""}, {"key": "maintenance/*", "severity": "page"}], "terminationNotices": [{"noticeSeconds": 120}, {"condition": "SpotInterrupted", "severity": "low"}]}},
+					{"name": "*", "filter": "pool=workers", "reportStatus": {"minNodes": 3, "maxNodes": 2, "groupBalance": {"minPerGroup": -1, "maxRatio": 0.5}, "utilization": {"warningPercent": 90, "criticalPercent": 120, "resources": ["cpu", "disk"]}, "clockSkew": {"thresholdSeconds": 30, "reference": "ntp"}, "kubeletSkew": {"maxMinorVersions": -1, "minVersion": "latest"}}}
+				],
+				"persistentVolumeClaims": [{"name": "data", "escalation": [
+					{"afterSeconds": 600, "alerterType": "smtp", "alerterName": "mail"},
+					{"afterSeconds": 600, "alerterType": "slack", "alerterName": "managers"}
+				]}],
+				"storageClasses": [{"name": "*", "reportStatus": {"missing": true}}],
+				"deprecatedApis": [{"reportStatus": {"objects": true, "targetVersion": "next"}}],
+				"configObjects": [
+					{"name": "*", "kind": "configmap"},
+					{"name": "*", "kind": "ConfigMap", "reportStatus": {"certificateExpiryDays": 30}},
+					{"name": "*", "kind": "ConfigMap", "filterNamespace": "ci", "reportStatus": {"credentialExpiryDays": 30}}
+				],
+				"apiserverLatency": {"thresholdSeconds": 1, "alerterType": "webhook", "alerterName": "hook"},
+				"apiserverHealth": {"endpoints": ["/livez", "readyz"]},
+				"controlPlane": {"components": ["scheduler", "kube-scheduler"]},
+				"etcd": {"endpoint": "readyz/etcd"},
+				"severities": [
+					{"resourceType": "node", "check": "Ready", "severity": "page"},
+					{"resourceType": "node", "severity": "warning"},
+					{"resourceType": "node", "check": "Ready", "severity": "warning"}
+				],
+				"clusterAlerters": {
+					"prod-eu": [{"alerterType": "smtp", "toAlerterType": "pagerdutyV2", "toAlerterName": "prod-eu"}],
+					"dev": [{"toAlerterType": "smtp", "toAlerterName": "mail"}]
+				},
+				"alerters": {"alerters": {"smtp": [{"name": "mail"}, {"name": "mail"}]}}
+			}`,
+			problems: []string{
+				`alerters.smtp has 2 alerters named "mail"`,
+				`deployments[1] duplicates deployments[0] (name "web", filter "default")`,
+				`deployments[1] references undefined pagerdutyV2 alerter "pager"`,
+				`pods[0].reportStatus.orphanedAllowlist[1] is "[debug", not a valid glob pattern`,
+				`pods[0].reportStatus.usage.memory is "1 GB", not a quantity such as 500m or 1Gi`,
+				`pods[0].reportStatus.usage.limitPercent is 150, expected a percentage between 0 and 100`,
+				`pods[0].reportStatus.imagePolicy.allowedRegistries[1] is "", not a valid glob pattern`,
+				`daemonsets[0] uses unknown alerterType "pager"`,
+				`daemonsets[0] has unknown onObjectError "skip", expected abort or continue`,
+				`nodes[0] has unknown interruptibleReadySeverity "low", expected critical, warning or info`,
+				`nodes[0].reportStatus.taints[0] has key "", not a valid glob pattern`,
+				`nodes[0].reportStatus.taints[1] has unknown severity "page", expected critical, warning or info`,
+				`nodes[0].reportStatus.terminationNotices[0] has taint "", expected a valid glob pattern or a condition`,
+				`nodes[0].reportStatus.terminationNotices[1] has unknown severity "low", expected critical, warning or info`,
+				`nodes[1].reportStatus.maxNodes is 2, below its minNodes of 3`,
+				`nodes[1].reportStatus.groupBalance.minPerGroup is -1, expected a positive number`,
+				`nodes[1].reportStatus.groupBalance.maxRatio is 0.5, expected at least 1`,
+				`nodes[1].reportStatus.utilization.criticalPercent is 120, expected a percentage between 0 and 100`,
+				`nodes[1].reportStatus.utilization.resources[1] is "disk", expected cpu or memory`,
+				`nodes[1].reportStatus.kubeletSkew.maxMinorVersions is -1, expected a positive number`,
+				`nodes[1].reportStatus.clockSkew has unknown reference "ntp", expected nodes or monitor`,
+				`nodes[1].reportStatus.kubeletSkew.minVersion is "latest", not a version such as v1.12.5`,
+				`persistentVolumeClaims[0].escalation[1] has afterSeconds 600, expected more than 600`,
+				`persistentVolumeClaims[0].escalation[1] references undefined slack alerter "managers"`,
+				`storageClasses[0] checks missing, which only applies to rules naming a StorageClass`,
+				`deprecatedApis[0].reportStatus.targetVersion is "next", not a version such as v1.22`,
+				`configObjects[0] has unknown kind "configmap", expected ConfigMap or Secret`,
+				`configObjects[1] checks certificateExpiryDays, which only applies to Secrets`,
+				`configObjects[2] checks credentialExpiryDays, which only applies to Secrets`,
+				`apiserverLatency references undefined webhook alerter "hook"`,
+				`apiserverHealth.endpoints[1] is "readyz", expected a path starting with /`,
+				`controlPlane.components[1] is "kube-scheduler", expected scheduler or controller-manager`,
+				`etcd.endpoint is "readyz/etcd", expected a path starting with /`,
+				`severities[0] has unknown severity "page", expected critical, warning or info`,
+				`severities[1] has no check`,
+				`severities[2] duplicates severities[0] (resourceType "node", check "Ready")`,
+				`clusterAlerters["prod-eu"][0] references undefined pagerdutyV2 alerter "prod-eu"`,
+			},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var config ConfigRules
+			assert.NoError(t, json.Unmarshal([]byte(test.config), &config))
+			err := config.Validate()
+			if test.problems == nil {
+				assert.NoError(t, err)
+				return
+			}
+			if assert.IsType(t, &ValidationError{}, err) {
+				assert.Equal(t, test.problems, err.(*ValidationError).Problems)
+			}
+		})
+	}
+}
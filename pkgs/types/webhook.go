@@ -0,0 +1,33 @@
+// Copyright 2019 Bloomberg Finance LP
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package types
+
+// WebhookAlertStatus represents the problems to alert on for admission webhooks
+type WebhookAlertStatus struct {
+	// UnreachableService alerts on webhooks with failurePolicy Fail whose Service has no ready endpoints
+	UnreachableService bool `json:"unreachableService"`
+}
+
+// WebhookAlertSpec represents the configuration for alerting on the webhooks of
+// ValidatingWebhookConfigurations and MutatingWebhookConfigurations
+type WebhookAlertSpec struct {
+	Name          string             `json:"name"`
+	WebhookFilter string             `json:"filter"`
+	AlerterType   string             `json:"alerterType"`
+	AlerterName   string             `json:"alerterName"`
+	ReportStatus  WebhookAlertStatus `json:"reportStatus"`
+	// Escalation is the rule's escalation chain
+	Escalation []EscalationLevel `json:"escalation"`
+}
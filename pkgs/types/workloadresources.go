@@ -0,0 +1,39 @@
+// Copyright 2019 Bloomberg Finance LP
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package types
+
+// WorkloadResourceAlertStatus represents which workloads to check and which resource settings their pod templates must have
+type WorkloadResourceAlertStatus struct {
+	// Deployments checks the pod templates of Deployments
+	Deployments bool `json:"deployments"`
+	// Statefulsets checks the pod templates of StatefulSets
+	Statefulsets bool `json:"statefulsets"`
+	// Requests alerts on pod templates with containers that set no CPU or memory request
+	Requests bool `json:"requests"`
+	// Limits alerts on pod templates with containers that set no CPU or memory limit
+	Limits bool `json:"limits"`
+}
+
+// WorkloadResourceAlertSpec represents the configuration for alerting on Deployments and
+// StatefulSets whose pod templates leave their containers unbounded
+type WorkloadResourceAlertSpec struct {
+	WorkloadFilterNamespace string                      `json:"filterNamespace"`
+	WorkloadFilterLabel     string                      `json:"filterLabel"`
+	AlerterType             string                      `json:"alerterType"`
+	AlerterName             string                      `json:"alerterName"`
+	ReportStatus            WorkloadResourceAlertStatus `json:"reportStatus"`
+	// Escalation is the rule's escalation chain
+	Escalation []EscalationLevel `json:"escalation"`
+}